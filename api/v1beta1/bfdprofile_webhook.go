@@ -32,7 +32,7 @@ func (bfdProfile *BFDProfile) SetupWebhookWithManager(mgr ctrl.Manager) error {
 		Complete()
 }
 
-//+kubebuilder:webhook:verbs=create;delete,path=/validate-metallb-io-v1beta1-bfdprofile,mutating=false,failurePolicy=fail,groups=metallb.io,resources=bfdprofiles,versions=v1beta1,name=bfdprofilevalidationwebhook.metallb.io,sideEffects=None,admissionReviewVersions=v1
+//+kubebuilder:webhook:verbs=create;update;delete,path=/validate-metallb-io-v1beta1-bfdprofile,mutating=false,failurePolicy=fail,groups=metallb.io,resources=bfdprofiles,versions=v1beta1,name=bfdprofilevalidationwebhook.metallb.io,sideEffects=None,admissionReviewVersions=v1
 
 var _ webhook.Validator = &BFDProfile{}
 
@@ -44,12 +44,14 @@ func (bfdProfile *BFDProfile) ValidateCreate() error {
 		return fmt.Errorf("resource must be created in %s namespace", MetalLBNamespace)
 	}
 
-	return nil
+	return validateBFDTimers(bfdProfile.Spec)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for BFDProfile.
 func (bfdProfile *BFDProfile) ValidateUpdate(old runtime.Object) error {
-	return nil
+	level.Debug(Logger).Log("webhook", "bfdProfile", "action", "update", "name", bfdProfile.Name, "namespace", bfdProfile.Namespace)
+
+	return validateBFDTimers(bfdProfile.Spec)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for BFDProfile.
@@ -68,3 +70,53 @@ func (bfdProfile *BFDProfile) ValidateDelete() error {
 	}
 	return nil
 }
+
+const (
+	// The defaults FRR applies to the unset timers.
+	defaultBFDInterval     = 300
+	defaultBFDEchoInterval = 50
+	defaultBFDMultiplier   = 3
+
+	// minBFDDetectionTime is the shortest detection time, in milliseconds,
+	// accepted for a BFD session. Shorter ones are not practical and make
+	// the sessions flap.
+	minBFDDetectionTime = 50
+)
+
+// validateBFDTimers checks that the detection times resulting from the
+// timers of the profile are not shorter than minBFDDetectionTime, and that
+// the echo packets, when enabled, are sent often enough to detect a failure
+// before the control ones.
+func validateBFDTimers(spec BFDProfileSpec) error {
+	valueOrDefault := func(v *uint32, d uint32) uint32 {
+		if v == nil {
+			return d
+		}
+		return *v
+	}
+	multiplier := valueOrDefault(spec.DetectMultiplier, defaultBFDMultiplier)
+	transmit := valueOrDefault(spec.TransmitInterval, defaultBFDInterval)
+	receive := valueOrDefault(spec.ReceiveInterval, defaultBFDInterval)
+
+	if transmit*multiplier < minBFDDetectionTime {
+		return fmt.Errorf("transmitInterval %dms * detectMultiplier %d gives a detection time of %dms to the peer, it must be at least %dms",
+			transmit, multiplier, transmit*multiplier, minBFDDetectionTime)
+	}
+	if receive*multiplier < minBFDDetectionTime {
+		return fmt.Errorf("receiveInterval %dms * detectMultiplier %d gives a detection time of %dms, it must be at least %dms",
+			receive, multiplier, receive*multiplier, minBFDDetectionTime)
+	}
+	if spec.EchoMode == nil || !*spec.EchoMode {
+		return nil
+	}
+	echo := valueOrDefault(spec.EchoInterval, defaultBFDEchoInterval)
+	if echo*multiplier < minBFDDetectionTime {
+		return fmt.Errorf("echoInterval %dms * detectMultiplier %d gives an echo detection time of %dms, it must be at least %dms",
+			echo, multiplier, echo*multiplier, minBFDDetectionTime)
+	}
+	if echo >= transmit*multiplier {
+		return fmt.Errorf("echoInterval %dms must be shorter than the detection time of transmitInterval %dms * detectMultiplier %d = %dms, or the echo packets can't detect a failure",
+			echo, transmit, multiplier, transmit*multiplier)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-kit/log"
 	"go.universe.tf/metallb/api/v1beta2"
+	"go.universe.tf/metallb/internal/pointer"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -36,6 +37,7 @@ func TestValidateBFDProfile(t *testing.T) {
 
 	const (
 		isNew int = iota
+		isUpdate
 		isDel
 	)
 	tests := []struct {
@@ -66,6 +68,91 @@ func TestValidateBFDProfile(t *testing.T) {
 			},
 			failValidate: true,
 		},
+		{
+			desc:         "Default timers",
+			validateType: isNew,
+			bfdProfile: &BFDProfile{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bfdprofile1",
+					Namespace: MetalLBTestNameSpace,
+				},
+			},
+		},
+		{
+			desc:         "Transmit detection time too short",
+			validateType: isNew,
+			bfdProfile: &BFDProfile{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bfdprofile1",
+					Namespace: MetalLBTestNameSpace,
+				},
+				Spec: BFDProfileSpec{
+					TransmitInterval: pointer.Uint32Ptr(10),
+					DetectMultiplier: pointer.Uint32Ptr(2),
+				},
+			},
+			failValidate: true,
+		},
+		{
+			desc:         "Receive detection time too short on update",
+			validateType: isUpdate,
+			bfdProfile: &BFDProfile{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bfdprofile1",
+					Namespace: MetalLBTestNameSpace,
+				},
+				Spec: BFDProfileSpec{
+					ReceiveInterval:  pointer.Uint32Ptr(20),
+					DetectMultiplier: pointer.Uint32Ptr(2),
+				},
+			},
+			failValidate: true,
+		},
+		{
+			desc:         "Short intervals with a high multiplier",
+			validateType: isUpdate,
+			bfdProfile: &BFDProfile{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bfdprofile1",
+					Namespace: MetalLBTestNameSpace,
+				},
+				Spec: BFDProfileSpec{
+					ReceiveInterval:  pointer.Uint32Ptr(10),
+					TransmitInterval: pointer.Uint32Ptr(10),
+					DetectMultiplier: pointer.Uint32Ptr(5),
+				},
+			},
+		},
+		{
+			desc:         "Echo interval longer than the detection time",
+			validateType: isNew,
+			bfdProfile: &BFDProfile{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bfdprofile1",
+					Namespace: MetalLBTestNameSpace,
+				},
+				Spec: BFDProfileSpec{
+					TransmitInterval: pointer.Uint32Ptr(100),
+					EchoInterval:     pointer.Uint32Ptr(300),
+					EchoMode:         pointer.BoolPtr(true),
+				},
+			},
+			failValidate: true,
+		},
+		{
+			desc:         "Echo interval ignored without echo mode",
+			validateType: isNew,
+			bfdProfile: &BFDProfile{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bfdprofile1",
+					Namespace: MetalLBTestNameSpace,
+				},
+				Spec: BFDProfileSpec{
+					TransmitInterval: pointer.Uint32Ptr(100),
+					EchoInterval:     pointer.Uint32Ptr(300),
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -73,6 +160,8 @@ func TestValidateBFDProfile(t *testing.T) {
 		switch test.validateType {
 		case isNew:
 			err = test.bfdProfile.ValidateCreate()
+		case isUpdate:
+			err = test.bfdProfile.ValidateUpdate(nil)
 		case isDel:
 			err = test.bfdProfile.ValidateDelete()
 		}
@@ -80,5 +169,8 @@ func TestValidateBFDProfile(t *testing.T) {
 		if test.failValidate && err == nil {
 			t.Fatalf("test %s failed, expecting error", test.desc)
 		}
+		if !test.failValidate && err != nil {
+			t.Fatalf("test %s failed, unexpected error %s", test.desc, err)
+		}
 	}
 }
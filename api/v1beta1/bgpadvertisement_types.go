@@ -36,11 +36,47 @@ type BGPAdvertisementSpec struct {
 	// +optional
 	AggregationLengthV6 *int32 `json:"aggregationLengthV6,omitempty"`
 
+	// AdditionalAggregationLengths are the other aggregation lengths the IPv4 addresses are
+	// advertised with at the same time, for example to announce both the /32s and a covering /24.
+	// +optional
+	AdditionalAggregationLengths []int32 `json:"additionalAggregationLengths,omitempty"`
+
+	// AdditionalAggregationLengthsV6 are the other aggregation lengths the IPv6 addresses are
+	// advertised with at the same time.
+	// +optional
+	AdditionalAggregationLengthsV6 []int32 `json:"additionalAggregationLengthsV6,omitempty"`
+
+	// PointToPointPairs advertises each IPv4 address as the /31 covering it and its paired
+	// address, instead of a /32. The paired addresses must not be assigned to other services,
+	// so the selected pools must either allocate only one address of each pair, for example
+	// with an even allocationStride, or not contain the paired addresses at all.
+	// It can't be combined with an aggregationLength other than 32.
+	// +optional
+	PointToPointPairs bool `json:"pointToPointPairs,omitempty"`
+
 	// The BGP LOCAL_PREF attribute which is used by BGP best path algorithm,
 	// Path with higher localpref is preferred over one with lower localpref.
 	// +optional
 	LocalPref uint32 `json:"localPref,omitempty"`
 
+	// LinkBandwidthPerEndpoint is the bandwidth in Mbps each ready endpoint of a service
+	// on a node accounts for. When set, the node sets the link-bandwidth extended community
+	// of the announcement to this value times its number of ready endpoints of the service,
+	// so that the routers supporting it spread the traffic in proportion among the nodes.
+	// Available only in FRR mode.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=25600
+	// +optional
+	LinkBandwidthPerEndpoint uint32 `json:"linkBandwidthPerEndpoint,omitempty"`
+
+	// Color is the value of the color extended community attached to the announcement,
+	// used by the routers to steer the traffic into a segment routing policy.
+	// Available only in FRR mode.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4294967295
+	// +optional
+	Color uint32 `json:"color,omitempty"`
+
 	// The BGP communities to be associated with the announcement. Each item can be a
 	// community of the form 1234:1234 or the name of an alias defined in the Community CRD.
 	// +optional
@@ -59,10 +95,133 @@ type BGPAdvertisementSpec struct {
 	// +optional
 	NodeSelectors []metav1.LabelSelector `json:"nodeSelectors,omitempty"`
 
+	// The BGP ORIGIN attribute of the announcement, one of igp, egp or incomplete.
+	// When empty, the IPs are announced with the IGP origin.
+	// +kubebuilder:validation:Enum=igp;egp;incomplete
+	// +optional
+	Origin string `json:"origin,omitempty"`
+
 	// Peers limits the bgppeer to advertise the ips of the selected pools to.
 	// When empty, the loadbalancer IP is announced to all the BGPPeers configured.
 	// +optional
 	Peers []string `json:"peers,omitempty"`
+
+	// PeerAggregations overrides the aggregation lengths for the given BGPPeers, so the IPs
+	// can be advertised aggregated to some peers and not to the others.
+	// +optional
+	PeerAggregations []PeerAggregation `json:"peerAggregations,omitempty"`
+
+	// PeerCommunities adds communities to the announcements made to the given BGPPeers,
+	// on top of the communities of the advertisement, so each peer can be sent its own.
+	// +optional
+	PeerCommunities []PeerCommunities `json:"peerCommunities,omitempty"`
+
+	// EVPN, when set, advertises the IPs as EVPN type-5 routes out of the VRF of the BGPPeers
+	// selected by this advertisement. Available only in FRR mode.
+	// +optional
+	EVPN *EVPNAdvertisement `json:"evpn,omitempty"`
+
+	// IPFamily limits the advertisement to the IPs of the given family, so the v4 and v6 IPs
+	// of a dual stack service can be advertised with different attributes.
+	// When empty, the advertisement applies to the IPs of both families.
+	// +kubebuilder:validation:Enum=ipv4;ipv6
+	// +optional
+	IPFamily string `json:"ipFamily,omitempty"`
+
+	// FallbackToSelectedNodes makes the selected nodes announce the IPs of the services
+	// with the Local traffic policy when none of them has a ready endpoint, instead of
+	// withdrawing the announcement. The traffic then takes an extra hop to reach the endpoints.
+	// +optional
+	FallbackToSelectedNodes bool `json:"fallbackToSelectedNodes,omitempty"`
+
+	// NotReadyGracePeriod keeps announcing the IPs of a service for the given period once
+	// none of its endpoints is ready, from the nodes with an endpoint whether it is ready
+	// or not, to ride through the rolling deploys instead of withdrawing the announcement.
+	// When not set, the announcement is withdrawn as soon as no endpoint is ready.
+	// +optional
+	NotReadyGracePeriod *metav1.Duration `json:"notReadyGracePeriod,omitempty"`
+
+	// SummaryOnly suppresses the more specific prefixes covered by the aggregate of the
+	// IPs, advertised by other advertisements or as additional aggregation lengths to the
+	// same peers, so that only the aggregate is advertised. It requires an aggregationLength
+	// shorter than 32 or an aggregationLengthV6 shorter than 128.
+	// +optional
+	SummaryOnly bool `json:"summaryOnly,omitempty"`
+
+	// TopologyKey is the label grouping the nodes, for example by rack. When set, a node
+	// announces the IPs of a service only if the service has a ready endpoint on a node
+	// of its group, with the same value of the label. Combined with the aggregation length,
+	// each group advertises the aggregates of the IPs of the services with endpoints in it.
+	// +optional
+	TopologyKey string `json:"topologyKey,omitempty"`
+
+	// SingleNode makes only one of the selected nodes announce the IPs of a service, instead
+	// of all of them. The node is elected among the ones with a running speaker like in
+	// layer2 mode, and another one takes over when it fails. With the Local traffic policy,
+	// only the nodes with a ready endpoint are elected.
+	// +optional
+	SingleNode bool `json:"singleNode,omitempty"`
+
+	// ServiceLabelCommunities adds to the announcement of the IPs of a service the community
+	// the value of one of its labels maps to. The services without the label, or with a value
+	// not mapped to any community, are announced with the other communities only.
+	// +optional
+	ServiceLabelCommunities *ServiceLabelCommunities `json:"serviceLabelCommunities,omitempty"`
+}
+
+// ServiceLabelCommunities maps the values of a label of the services to BGP communities.
+type ServiceLabelCommunities struct {
+	// Label is the key of the label of the services.
+	Label string `json:"label"`
+
+	// Communities maps the values of the label to communities. Each community can be of
+	// the form 1234:1234 or the name of an alias defined in the Community CRD.
+	Communities map[string]string `json:"communities"`
+}
+
+// EVPNAdvertisement defines how the IPs are exported as EVPN type-5 routes.
+type EVPNAdvertisement struct {
+	// The L3 VNI of the VRF the routes are exported from.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=16777215
+	VNI uint32 `json:"vni"`
+
+	// The route distinguisher of the type-5 routes, of the form ASN:NN or IP:NN.
+	// When empty, FRR derives it from the router ID.
+	// +optional
+	RouteDistinguisher string `json:"routeDistinguisher,omitempty"`
+
+	// The route target of the type-5 routes, of the form ASN:NN or IP:NN.
+	// When empty, FRR derives it from the ASN and the VNI.
+	// +optional
+	RouteTarget string `json:"routeTarget,omitempty"`
+}
+
+// PeerAggregation defines the aggregation lengths used when advertising to a BGPPeer.
+type PeerAggregation struct {
+	// Peer is the name of the BGPPeer the aggregation lengths apply to.
+	Peer string `json:"peer"`
+
+	// The aggregation length of the IPv4 addresses advertised to the peer.
+	// Defaults to the aggregationLength of the advertisement.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	AggregationLength *int32 `json:"aggregationLength,omitempty"`
+
+	// The aggregation length of the IPv6 addresses advertised to the peer.
+	// Defaults to the aggregationLengthV6 of the advertisement.
+	// +optional
+	AggregationLengthV6 *int32 `json:"aggregationLengthV6,omitempty"`
+}
+
+// PeerCommunities defines the communities added to the announcements made to a BGPPeer.
+type PeerCommunities struct {
+	// Peer is the name of the BGPPeer the communities are added for.
+	Peer string `json:"peer"`
+
+	// The communities added to the announcements made to the peer. Each community can be
+	// of the form 1234:1234 or the name of an alias defined in the Community CRD.
+	Communities []string `json:"communities"`
 }
 
 // BGPAdvertisementStatus defines the observed state of BGPAdvertisement.
@@ -19,6 +19,9 @@ package v1beta1
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
 
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
@@ -46,6 +49,11 @@ func (bgpAdv *BGPAdvertisement) ValidateCreate() error {
 		return fmt.Errorf("resource must be created in %s namespace", MetalLBNamespace)
 	}
 
+	if err := validateCommunities(bgpAdv); err != nil {
+		level.Error(Logger).Log("webhook", "bgpadvertisement", "action", "create", "name", bgpAdv.Name, "namespace", bgpAdv.Namespace, "error", err)
+		return err
+	}
+
 	existingBGPAdvList, err := getExistingBGPAdvs()
 	if err != nil {
 		return err
@@ -74,6 +82,11 @@ func (bgpAdv *BGPAdvertisement) ValidateCreate() error {
 func (bgpAdv *BGPAdvertisement) ValidateUpdate(old runtime.Object) error {
 	level.Debug(Logger).Log("webhook", "bgpadvertisement", "action", "update", "name", bgpAdv.Name, "namespace", bgpAdv.Namespace)
 
+	if err := validateCommunities(bgpAdv); err != nil {
+		level.Error(Logger).Log("webhook", "bgpadvertisement", "action", "update", "name", bgpAdv.Name, "namespace", bgpAdv.Namespace, "error", err)
+		return err
+	}
+
 	bgpAdvs, err := getExistingBGPAdvs()
 	if err != nil {
 		return err
@@ -123,3 +136,68 @@ func bgpAdvListWithUpdate(existing *BGPAdvertisementList, toAdd *BGPAdvertisemen
 	res.Items = append(res.Items, *toAdd.DeepCopy())
 	return res
 }
+
+// validateCommunities checks that the communities of the advertisement which
+// are not aliases are well-formed. Without this, a mistyped community is
+// taken for the name of an alias not defined yet and silently ignored.
+func validateCommunities(bgpAdv *BGPAdvertisement) error {
+	existing, err := getExistingCommunities()
+	if err != nil {
+		return err
+	}
+	aliases := map[string]bool{}
+	for _, c := range existing.Items {
+		for _, alias := range c.Spec.Communities {
+			aliases[alias.Name] = true
+		}
+	}
+
+	communities := append([]string{}, bgpAdv.Spec.Communities...)
+	if bgpAdv.Spec.ServiceLabelCommunities != nil {
+		for _, c := range bgpAdv.Spec.ServiceLabelCommunities.Communities {
+			communities = append(communities, c)
+		}
+	}
+	for _, p := range bgpAdv.Spec.PeerCommunities {
+		communities = append(communities, p.Communities...)
+	}
+	for _, c := range communities {
+		if aliases[c] {
+			continue
+		}
+		if err := validateInlineCommunity(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var communitySections = []string{"first", "second", "third"}
+
+// validateInlineCommunity checks the community is well-formed when written
+// inline, that is when it contains a colon or only digits. Any other value
+// is the name of an alias.
+func validateInlineCommunity(c string) error {
+	if !strings.Contains(c, ":") && strings.TrimLeftFunc(c, unicode.IsDigit) != "" {
+		return nil
+	}
+	sections := strings.Split(c, ":")
+	switch len(sections) {
+	case 2:
+		for i, s := range sections {
+			if _, err := strconv.ParseUint(s, 10, 16); err != nil {
+				return fmt.Errorf("invalid community %q: the %s section %q is not a number between 0 and 65535", c, communitySections[i], s)
+			}
+		}
+		return nil
+	case 3:
+		for i, s := range sections {
+			if _, err := strconv.ParseUint(s, 10, 32); err != nil {
+				return fmt.Errorf("invalid large community %q: the %s section %q is not a number between 0 and 4294967295", c, communitySections[i], s)
+			}
+		}
+		return fmt.Errorf("invalid community %q: large communities are not supported", c)
+	default:
+		return fmt.Errorf("invalid community %q: expected the form <0-65535>:<0-65535>", c)
+	}
+}
@@ -37,11 +37,16 @@ func TestValidateBGPAdvertisement(t *testing.T) {
 	getExistingIPAddressPools = func() (*IPAddressPoolList, error) {
 		return &IPAddressPoolList{}, nil
 	}
+	toRestoreCommunities := getExistingCommunities
+	getExistingCommunities = func() (*CommunityList, error) {
+		return &CommunityList{}, nil
+	}
 
 	defer func() {
 		getExistingBGPAdvs = toRestore
 		getExistingAddressPools = toRestoreAddresspools
 		getExistingIPAddressPools = toRestoreIPAddressPools
+		getExistingCommunities = toRestoreCommunities
 	}()
 
 	tests := []struct {
@@ -150,3 +155,124 @@ func TestValidateBGPAdvertisement(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateBGPAdvertisementCommunities(t *testing.T) {
+	MetalLBNamespace = MetalLBTestNameSpace
+	Logger = log.NewNopLogger()
+
+	toRestore := getExistingBGPAdvs
+	getExistingBGPAdvs = func() (*BGPAdvertisementList, error) {
+		return &BGPAdvertisementList{}, nil
+	}
+	toRestoreAddresspools := getExistingAddressPools
+	getExistingAddressPools = func() (*AddressPoolList, error) {
+		return &AddressPoolList{}, nil
+	}
+	toRestoreIPAddressPools := getExistingIPAddressPools
+	getExistingIPAddressPools = func() (*IPAddressPoolList, error) {
+		return &IPAddressPoolList{}, nil
+	}
+	toRestoreCommunities := getExistingCommunities
+	getExistingCommunities = func() (*CommunityList, error) {
+		return &CommunityList{
+			Items: []Community{
+				{
+					Spec: CommunitySpec{
+						Communities: []CommunityAlias{
+							{Name: "odd:alias", Value: "64512:1"},
+						},
+					},
+				},
+			},
+		}, nil
+	}
+
+	defer func() {
+		getExistingBGPAdvs = toRestore
+		getExistingAddressPools = toRestoreAddresspools
+		getExistingIPAddressPools = toRestoreIPAddressPools
+		getExistingCommunities = toRestoreCommunities
+	}()
+
+	tests := []struct {
+		desc        string
+		communities []string
+		labels      map[string]string
+		peers       []PeerCommunities
+		expectedErr string
+	}{
+		{
+			desc:        "valid communities and aliases",
+			communities: []string{"64512:100", "0:65535", "odd:alias", "not-defined-yet"},
+		},
+		{
+			desc:        "first section out of range",
+			communities: []string{"65536:100"},
+			expectedErr: `invalid community "65536:100": the first section "65536" is not a number between 0 and 65535`,
+		},
+		{
+			desc:        "second section not a number",
+			communities: []string{"64512:1OO"},
+			expectedErr: `invalid community "64512:1OO": the second section "1OO" is not a number between 0 and 65535`,
+		},
+		{
+			desc:        "missing section",
+			communities: []string{"64512"},
+			expectedErr: `invalid community "64512": expected the form <0-65535>:<0-65535>`,
+		},
+		{
+			desc:        "too many sections",
+			communities: []string{"1:2:3:4"},
+			expectedErr: `invalid community "1:2:3:4": expected the form <0-65535>:<0-65535>`,
+		},
+		{
+			desc:        "large community out of range",
+			communities: []string{"4294967296:1:1"},
+			expectedErr: `invalid large community "4294967296:1:1": the first section "4294967296" is not a number between 0 and 4294967295`,
+		},
+		{
+			desc:        "large community",
+			communities: []string{"4200000000:1:1"},
+			expectedErr: `invalid community "4200000000:1:1": large communities are not supported`,
+		},
+		{
+			desc:        "invalid peer community",
+			peers:       []PeerCommunities{{Peer: "transit", Communities: []string{"64512:1", "64512:1:"}}},
+			expectedErr: `invalid large community "64512:1:": the third section "" is not a number between 0 and 4294967295`,
+		},
+		{
+			desc:        "invalid service label community",
+			labels:      map[string]string{"gold": "64512:-1"},
+			expectedErr: `invalid community "64512:-1": the second section "-1" is not a number between 0 and 65535`,
+		},
+	}
+	for _, test := range tests {
+		mock := &mockValidator{}
+		Validator = mock
+		bgpAdv := &BGPAdvertisement{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-bgpadv",
+				Namespace: MetalLBTestNameSpace,
+			},
+			Spec: BGPAdvertisementSpec{
+				Communities:     test.communities,
+				PeerCommunities: test.peers,
+			},
+		}
+		if test.labels != nil {
+			bgpAdv.Spec.ServiceLabelCommunities = &ServiceLabelCommunities{
+				Label:       "tier",
+				Communities: test.labels,
+			}
+		}
+		for _, validate := range []func() error{bgpAdv.ValidateCreate, func() error { return bgpAdv.ValidateUpdate(nil) }} {
+			err := validate()
+			if test.expectedErr == "" && err != nil {
+				t.Fatalf("test %s failed, unexpected error %s", test.desc, err)
+			}
+			if test.expectedErr != "" && (err == nil || err.Error() != test.expectedErr) {
+				t.Fatalf("test %s failed, expected error %q, got %v", test.desc, test.expectedErr, err)
+			}
+		}
+	}
+}
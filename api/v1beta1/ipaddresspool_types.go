@@ -40,6 +40,15 @@ type IPAddressPoolSpec struct {
 	// +kubebuilder:default:=false
 	AvoidBuggyIPs bool `json:"avoidBuggyIPs,omitempty"`
 
+	// AvoidLastOctets prevents the IPv4 addresses whose last octet is in the
+	// list from being allocated from the pool, for example to avoid the
+	// addresses some devices mishandle. AvoidBuggyIPs is the same as listing
+	// 0 and 255.
+	// +kubebuilder:validation:items:Minimum=0
+	// +kubebuilder:validation:items:Maximum=255
+	// +optional
+	AvoidLastOctets []int `json:"avoidLastOctets,omitempty"`
+
 	// AllocateTo makes ip pool allocation to specific namespace and/or service.
 	// The controller will use the pool with lowest value of priority in case of
 	// multiple matches. A pool with no priority set will be used only if the
@@ -49,6 +58,48 @@ type IPAddressPoolSpec struct {
 	// multiple IPAddressPools have the same priority, choice will be random.
 	// +optional
 	AllocateTo *ServiceAllocation `json:"serviceAllocation,omitempty"`
+
+	// ContiguousNamespaces makes the controller try to keep the IPs allocated to the
+	// services of a namespace contiguous, by handing out the address following the
+	// highest one already allocated to the namespace. This is best effort: when that
+	// address is taken, the IP is allocated as usual.
+	// +optional
+	ContiguousNamespaces bool `json:"contiguousNamespaces,omitempty"`
+
+	// AllocationStride limits the addresses allocated from the pool to the ones
+	// whose value modulo the stride equals the AllocationOffset, for example to
+	// hand out only the even addresses with a stride of 2. Defaults to 1, all
+	// the addresses being allocatable.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	AllocationStride uint32 `json:"allocationStride,omitempty"`
+
+	// AllocationOffset is the remainder the addresses allocated from the pool
+	// must have when divided by the AllocationStride. Must be lower than the stride.
+	// +optional
+	AllocationOffset uint32 `json:"allocationOffset,omitempty"`
+
+	// AllocationMode is the order the addresses of the pool are allocated in:
+	// lowest hands out the lowest free address, highest the highest one, for
+	// example to keep the low addresses of the range for the infrastructure.
+	// When not set, the allocation strategy of the controller is used.
+	// +kubebuilder:validation:Enum=lowest;highest
+	// +optional
+	AllocationMode string `json:"allocationMode,omitempty"`
+
+	// PreferBGP makes the speakers announce the IPs of a pool advertised via both
+	// BGP and L2 only via BGP while they have an established session with the peers
+	// of its BGPAdvertisements, falling back to L2 when they have none. Available
+	// only in native mode.
+	// +optional
+	PreferBGP bool `json:"preferBGP,omitempty"`
+
+	// AnnouncementPriority orders the announcement of the IPs of the pool when a speaker
+	// processes all the services, for example after a restart: the services of the pools
+	// with a higher priority are announced first. The metallb.universe.tf/announcement-priority
+	// annotation of a service overrides it. Defaults to 0.
+	// +optional
+	AnnouncementPriority int32 `json:"announcementPriority,omitempty"`
 }
 
 // ServiceAllocation defines ip pool allocation to namespace and/or service.
@@ -63,12 +114,27 @@ type ServiceAllocation struct {
 	// ServiceSelectors list of label selector to select service(s) for which ip pool
 	// can be used for ip allocation.
 	ServiceSelectors []metav1.LabelSelector `json:"serviceSelectors,omitempty"`
+	// ServicePorts list of ports, or port ranges of the form "min-max", the
+	// service must expose at least one of for the ip pool to be used for its
+	// ip allocation.
+	// +optional
+	ServicePorts []string `json:"servicePorts,omitempty"`
 }
 
 // IPAddressPoolStatus defines the observed state of IPAddressPool.
 type IPAddressPoolStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// AssignedIPv4 is the number of IPv4 addresses assigned to services.
+	AssignedIPv4 int64 `json:"assignedIPv4,omitempty"`
+	// AssignedIPv6 is the number of IPv6 addresses assigned to services.
+	AssignedIPv6 int64 `json:"assignedIPv6,omitempty"`
+	// AvailableIPv4 is the number of IPv4 addresses still available.
+	AvailableIPv4 int64 `json:"availableIPv4,omitempty"`
+	// AvailableIPv6 is the number of IPv6 addresses still available.
+	AvailableIPv6 int64 `json:"availableIPv6,omitempty"`
+	// AssignedCount is the number of addresses assigned to services.
+	AssignedCount int64 `json:"assignedCount,omitempty"`
+	// AvailableCount is the number of addresses still available.
+	AvailableCount int64 `json:"availableCount,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -77,6 +143,8 @@ type IPAddressPoolStatus struct {
 // +kubebuilder:printcolumn:name="Auto Assign",type=boolean,JSONPath=`.spec.autoAssign`
 // +kubebuilder:printcolumn:name="Avoid Buggy IPs",type=boolean,JSONPath=`.spec.avoidBuggyIPs`
 // +kubebuilder:printcolumn:name="Addresses",type=string,JSONPath=`.spec.addresses`
+// +kubebuilder:printcolumn:name="Assigned",type=integer,JSONPath=`.status.assignedCount`
+// +kubebuilder:printcolumn:name="Available",type=integer,JSONPath=`.status.availableCount`
 
 // IPAddressPool represents a pool of IP addresses that can be allocated
 // to LoadBalancer services.
@@ -17,24 +17,38 @@ limitations under the License.
 package v1beta1
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"net"
+	"strings"
 
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
+const (
+	// PoolProtectionFinalizer makes the deletion of the IPAddressPool wait
+	// until none of its IPs is assigned to a service anymore.
+	PoolProtectionFinalizer = "metallb.io/pool-protection"
+	// ForceDeletionAnnotation, set to "true", allows deleting the
+	// IPAddressPool while its IPs are still assigned to services.
+	ForceDeletionAnnotation = "metallb.io/force-deletion"
+)
+
 func (ipAddress *IPAddressPool) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(ipAddress).
 		Complete()
 }
 
-//+kubebuilder:webhook:verbs=create;update,path=/validate-metallb-io-v1beta1-ipaddresspool,mutating=false,failurePolicy=fail,groups=metallb.io,resources=ipaddresspools,versions=v1beta1,name=ipaddresspoolvalidationwebhook.metallb.io,sideEffects=None,admissionReviewVersions=v1
+//+kubebuilder:webhook:verbs=create;update;delete,path=/validate-metallb-io-v1beta1-ipaddresspool,mutating=false,failurePolicy=fail,groups=metallb.io,resources=ipaddresspools,versions=v1beta1,name=ipaddresspoolvalidationwebhook.metallb.io,sideEffects=None,admissionReviewVersions=v1
 
 var _ webhook.Validator = &IPAddressPool{}
 
@@ -87,9 +101,89 @@ func (ipAddress *IPAddressPool) ValidateUpdate(old runtime.Object) error {
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for IPAddressPool.
 func (ipAddress *IPAddressPool) ValidateDelete() error {
+	level.Debug(Logger).Log("webhook", "ipaddresspool", "action", "delete", "name", ipAddress.Name, "namespace", ipAddress.Namespace)
+
+	// With the finalizer, the pool is removed only once it's not in use.
+	if ipAddress.Annotations[ForceDeletionAnnotation] == "true" || controllerutil.ContainsFinalizer(ipAddress, PoolProtectionFinalizer) {
+		return nil
+	}
+
+	existingServices, err := getExistingServices()
+	if err != nil {
+		return err
+	}
+	inUse, err := ServicesWithIPsFromPool(ipAddress, existingServices.Items)
+	if err != nil {
+		return err
+	}
+	if len(inUse) > 0 {
+		return fmt.Errorf("Failed to delete IPAddressPool %s, IPs assigned to services %s", ipAddress.Name, strings.Join(inUse, ", "))
+	}
 	return nil
 }
 
+// ServicesWithIPsFromPool returns the namespace/name of the services having
+// an IP of the pool in their status.
+func ServicesWithIPsFromPool(pool *IPAddressPool, services []corev1.Service) ([]string, error) {
+	contains := []func(net.IP) bool{}
+	for _, addr := range pool.Spec.Addresses {
+		c, err := addressContains(addr)
+		if err != nil {
+			return nil, err
+		}
+		contains = append(contains, c)
+	}
+
+	res := []string{}
+	for _, svc := range services {
+	ingress:
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			ip := net.ParseIP(ingress.IP)
+			if ip == nil {
+				continue
+			}
+			for _, c := range contains {
+				if c(ip) {
+					res = append(res, svc.Namespace+"/"+svc.Name)
+					break ingress
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// addressContains returns a function telling if an IP is part of the CIDR
+// or the "start-end" range of IPs.
+func addressContains(addr string) (func(net.IP) bool, error) {
+	if !strings.Contains(addr, "-") {
+		_, cidr, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q in pool: %s", addr, err)
+		}
+		return cidr.Contains, nil
+	}
+	bounds := strings.SplitN(addr, "-", 2)
+	start, end := net.ParseIP(strings.TrimSpace(bounds[0])), net.ParseIP(strings.TrimSpace(bounds[1]))
+	if start == nil || end == nil {
+		return nil, fmt.Errorf("invalid IP range %q in pool", addr)
+	}
+	start, end = start.To16(), end.To16()
+	return func(ip net.IP) bool {
+		ip = ip.To16()
+		return bytes.Compare(ip, start) >= 0 && bytes.Compare(ip, end) <= 0
+	}, nil
+}
+
+var getExistingServices = func() (*corev1.ServiceList, error) {
+	existingServiceList := &corev1.ServiceList{}
+	err := WebhookClient.List(context.Background(), existingServiceList)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get existing Service objects")
+	}
+	return existingServiceList, nil
+}
+
 var getExistingIPAddressPools = func() (*IPAddressPoolList, error) {
 	existingIPAddressPoolList := &IPAddressPoolList{}
 	err := WebhookClient.List(context.Background(), existingIPAddressPoolList, &client.ListOptions{Namespace: MetalLBNamespace})
@@ -3,10 +3,12 @@
 package v1beta1
 
 import (
+	"sort"
 	"testing"
 
 	"github.com/go-kit/log"
 	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -145,3 +147,118 @@ func TestValidateIPAddressPool(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateIPAddressPoolDelete(t *testing.T) {
+	MetalLBNamespace = MetalLBTestNameSpace
+	Logger = log.NewNopLogger()
+
+	serviceWithIP := func(name, ip string) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{{IP: ip}},
+				},
+			},
+		}
+	}
+	toRestoreServices := getExistingServices
+	getExistingServices = func() (*corev1.ServiceList, error) {
+		return &corev1.ServiceList{
+			Items: []corev1.Service{
+				serviceWithIP("svc1", "10.20.0.1"),
+				serviceWithIP("svc2", "192.168.10.5"),
+				serviceWithIP("svc3", "10.30.0.1"),
+			},
+		}, nil
+	}
+	defer func() {
+		getExistingServices = toRestoreServices
+	}()
+
+	tests := []struct {
+		desc          string
+		ipAddressPool *IPAddressPool
+		failValidate  bool
+	}{
+		{
+			desc: "Delete unused pool",
+			ipAddressPool: &IPAddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-ippool", Namespace: MetalLBTestNameSpace},
+				Spec:       IPAddressPoolSpec{Addresses: []string{"10.40.0.0/16"}},
+			},
+		},
+		{
+			desc: "Delete pool with a CIDR in use",
+			ipAddressPool: &IPAddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-ippool", Namespace: MetalLBTestNameSpace},
+				Spec:       IPAddressPoolSpec{Addresses: []string{"10.20.0.0/16"}},
+			},
+			failValidate: true,
+		},
+		{
+			desc: "Delete pool with a range in use",
+			ipAddressPool: &IPAddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-ippool", Namespace: MetalLBTestNameSpace},
+				Spec:       IPAddressPoolSpec{Addresses: []string{"192.168.10.0-192.168.10.10"}},
+			},
+			failValidate: true,
+		},
+		{
+			desc: "Delete pool in use with the protection finalizer",
+			ipAddressPool: &IPAddressPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test-ippool",
+					Namespace:  MetalLBTestNameSpace,
+					Finalizers: []string{PoolProtectionFinalizer},
+				},
+				Spec: IPAddressPoolSpec{Addresses: []string{"10.20.0.0/16"}},
+			},
+		},
+		{
+			desc: "Forced delete of a pool in use",
+			ipAddressPool: &IPAddressPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-ippool",
+					Namespace:   MetalLBTestNameSpace,
+					Annotations: map[string]string{ForceDeletionAnnotation: "true"},
+				},
+				Spec: IPAddressPoolSpec{Addresses: []string{"10.20.0.0/16"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		err := test.ipAddressPool.ValidateDelete()
+		if test.failValidate && err == nil {
+			t.Fatalf("test %s failed, expecting error", test.desc)
+		}
+		if !test.failValidate && err != nil {
+			t.Fatalf("test %s failed, unexpected error %s", test.desc, err)
+		}
+	}
+}
+
+func TestServicesWithIPsFromPool(t *testing.T) {
+	pool := &IPAddressPool{
+		Spec: IPAddressPoolSpec{Addresses: []string{"10.20.0.0/24", "10.30.0.10-10.30.0.20", "fc00:f853:ccd:e799::/124"}},
+	}
+	services := []corev1.Service{}
+	for name, ip := range map[string]string{"cidr": "10.20.0.1", "range": "10.30.0.15", "outofrange": "10.30.0.21", "v6": "fc00:f853:ccd:e799::1", "none": ""} {
+		svc := corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}}
+		if ip != "" {
+			svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: ip}}
+		}
+		services = append(services, svc)
+	}
+
+	got, err := ServicesWithIPsFromPool(pool, services)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	sort.Strings(got)
+	want := []string{"ns/cidr", "ns/range", "ns/v6"}
+	if !cmp.Equal(want, got) {
+		t.Fatalf("unexpected services using the pool: %s", cmp.Diff(want, got))
+	}
+}
@@ -39,6 +39,39 @@ type L2AdvertisementSpec struct {
 	// If the field is not set, we advertise from all the interfaces on the host.
 	// +optional
 	Interfaces []string `json:"interfaces,omitempty"`
+	// NodePreferences makes the election of the node announcing the LoadBalancer IP prefer
+	// the nodes matching the given selectors. The preferred node is the eligible one with
+	// the highest sum of weights of the preferences it matches. When empty, all the nodes
+	// are equally preferred.
+	// +optional
+	NodePreferences []NodePreference `json:"nodePreferences,omitempty"`
+	// FallbackToSelectedNodes makes the selected nodes announce the IPs of the services
+	// with the Local traffic policy when none of them has a ready endpoint, instead of
+	// withdrawing the announcement. The traffic then takes an extra hop to reach the endpoints.
+	// +optional
+	FallbackToSelectedNodes bool `json:"fallbackToSelectedNodes,omitempty"`
+	// NotReadyGracePeriod keeps announcing the IPs of a service for the given period once
+	// none of its endpoints is ready, from the nodes with an endpoint whether it is ready
+	// or not, to ride through the rolling deploys instead of withdrawing the announcement.
+	// When not set, the announcement is withdrawn as soon as no endpoint is ready.
+	// +optional
+	NotReadyGracePeriod *metav1.Duration `json:"notReadyGracePeriod,omitempty"`
+	// The name of the host VRF to announce the LoadBalancer IPs in. The IPs are
+	// announced only from the interfaces enslaved to the VRF, which must exist
+	// on the node. When empty, the IPs are announced from the interfaces not
+	// belonging to any VRF.
+	// +optional
+	VRF string `json:"vrf,omitempty"`
+}
+
+// NodePreference associates a weight to the nodes matching a selector.
+type NodePreference struct {
+	// The weight associated to the nodes matching the preference.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+	// The selector of the nodes the weight is associated to.
+	Preference metav1.LabelSelector `json:"preference"`
 }
 
 // L2AdvertisementStatus defines the observed state of L2Advertisement.
@@ -0,0 +1,56 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MetalLBMaintenanceSpec defines the desired state of MetalLBMaintenance.
+type MetalLBMaintenanceSpec struct {
+	// Reason is a free form description of the maintenance, reported
+	// in the logs of the speakers.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.spec.reason`
+
+// MetalLBMaintenance puts MetalLB in maintenance mode: while at least one
+// exists, the speakers withdraw all the L2 and BGP announcements, and they
+// resume them once all are deleted.
+type MetalLBMaintenance struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MetalLBMaintenanceSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MetalLBMaintenanceList contains a list of MetalLBMaintenance.
+type MetalLBMaintenanceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MetalLBMaintenance `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MetalLBMaintenance{}, &MetalLBMaintenanceList{})
+}
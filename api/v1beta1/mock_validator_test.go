@@ -13,6 +13,7 @@ type mockValidator struct {
 	bgpAdvs        *BGPAdvertisementList
 	l2Advs         *L2AdvertisementList
 	communities    *CommunityList
+	nodeAdvs       *NodeAdvertisementList
 	forceError     bool
 }
 
@@ -29,6 +30,8 @@ func (m *mockValidator) Validate(objects ...client.ObjectList) error {
 			m.ipAddressPools = list
 		case *CommunityList:
 			m.communities = list
+		case *NodeAdvertisementList:
+			m.nodeAdvs = list
 		default:
 			panic("unexpected type")
 		}
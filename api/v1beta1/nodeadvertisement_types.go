@@ -0,0 +1,120 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeAdvertisementSpec defines the desired state of NodeAdvertisement.
+type NodeAdvertisementSpec struct {
+	// The prefixes to advertise from each selected node, in CIDR notation.
+	// +kubebuilder:validation:MinItems=1
+	Prefixes []string `json:"prefixes"`
+
+	// NodeSelectors allows to limit the nodes advertising the prefixes. When empty, all the nodes advertise them.
+	// +optional
+	NodeSelectors []metav1.LabelSelector `json:"nodeSelectors,omitempty"`
+
+	// Peers limits the bgppeers to advertise the prefixes to.
+	// When empty, the prefixes are announced to all the BGPPeers configured.
+	// +optional
+	Peers []string `json:"peers,omitempty"`
+
+	// The BGP communities to be associated with the announcement. Each item can be a
+	// community of the form 1234:1234 or the name of an alias defined in the Community CRD.
+	// +optional
+	Communities []string `json:"communities,omitempty"`
+
+	// The BGP LOCAL_PREF attribute which is used by BGP best path algorithm,
+	// Path with higher localpref is preferred over one with lower localpref.
+	// +optional
+	LocalPref uint32 `json:"localPref,omitempty"`
+
+	// HealthCheck, when set, makes each node advertise the prefixes only while the
+	// check performed by the speaker running on the node succeeds.
+	// +optional
+	HealthCheck *NodeHealthCheck `json:"healthCheck,omitempty"`
+}
+
+// NodeHealthCheck defines the check the speaker performs against a local endpoint.
+type NodeHealthCheck struct {
+	// The address the check connects to. Defaults to 127.0.0.1.
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// The port the check connects to.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port uint16 `json:"port"`
+
+	// Path, when set, makes the check an HTTP GET of the given path, which succeeds
+	// when the response status is 2xx. Otherwise, the check succeeds when a TCP
+	// connection can be established.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// How often the check is performed. Defaults to 5s.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// How long a check can take before being considered failed. Defaults to 1s.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// The number of consecutive failures after which the prefixes are withdrawn. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold uint32 `json:"failureThreshold,omitempty"`
+}
+
+// NodeAdvertisementStatus defines the observed state of NodeAdvertisement.
+type NodeAdvertisementStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Prefixes",type=string,JSONPath=`.spec.prefixes`
+//+kubebuilder:printcolumn:name="Peers",type=string,JSONPath=`.spec.peers`
+//+kubebuilder:printcolumn:name="Node Selectors",type=string,JSONPath=`.spec.nodeSelectors`,priority=10
+
+// NodeAdvertisement allows to advertise a set of prefixes via BGP from
+// each of the selected nodes, independently of the services. The
+// announcement can be conditioned to a health check performed locally
+// on each node.
+type NodeAdvertisement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodeAdvertisementSpec   `json:"spec,omitempty"`
+	Status NodeAdvertisementStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NodeAdvertisementList contains a list of NodeAdvertisement.
+type NodeAdvertisementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NodeAdvertisement `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NodeAdvertisement{}, &NodeAdvertisementList{})
+}
@@ -0,0 +1,105 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (nodeAdv *NodeAdvertisement) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(nodeAdv).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-metallb-io-v1beta1-nodeadvertisement,mutating=false,failurePolicy=fail,groups=metallb.io,resources=nodeadvertisements,versions=v1beta1,name=nodeadvertisementvalidationwebhook.metallb.io,sideEffects=None,admissionReviewVersions=v1
+
+var _ webhook.Validator = &NodeAdvertisement{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for NodeAdvertisement.
+func (nodeAdv *NodeAdvertisement) ValidateCreate() error {
+	level.Debug(Logger).Log("webhook", "nodeadvertisement", "action", "create", "name", nodeAdv.Name, "namespace", nodeAdv.Namespace)
+
+	if nodeAdv.Namespace != MetalLBNamespace {
+		return fmt.Errorf("resource must be created in %s namespace", MetalLBNamespace)
+	}
+
+	existingNodeAdvList, err := getExistingNodeAdvs()
+	if err != nil {
+		return err
+	}
+
+	toValidate := nodeAdvListWithUpdate(existingNodeAdvList, nodeAdv)
+	err = Validator.Validate(toValidate)
+	if err != nil {
+		level.Error(Logger).Log("webhook", "nodeadvertisement", "action", "create", "name", nodeAdv.Name, "namespace", nodeAdv.Namespace, "error", err)
+		return err
+	}
+	return nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for NodeAdvertisement.
+func (nodeAdv *NodeAdvertisement) ValidateUpdate(old runtime.Object) error {
+	level.Debug(Logger).Log("webhook", "nodeadvertisement", "action", "update", "name", nodeAdv.Name, "namespace", nodeAdv.Namespace)
+
+	existingNodeAdvList, err := getExistingNodeAdvs()
+	if err != nil {
+		return err
+	}
+
+	toValidate := nodeAdvListWithUpdate(existingNodeAdvList, nodeAdv)
+	err = Validator.Validate(toValidate)
+	if err != nil {
+		level.Error(Logger).Log("webhook", "nodeadvertisement", "action", "update", "name", nodeAdv.Name, "namespace", nodeAdv.Namespace, "error", err)
+		return err
+	}
+	return nil
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for NodeAdvertisement.
+func (nodeAdv *NodeAdvertisement) ValidateDelete() error {
+	return nil
+}
+
+var getExistingNodeAdvs = func() (*NodeAdvertisementList, error) {
+	existingNodeAdvList := &NodeAdvertisementList{}
+	err := WebhookClient.List(context.Background(), existingNodeAdvList, &client.ListOptions{Namespace: MetalLBNamespace})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get existing NodeAdvertisement objects")
+	}
+	return existingNodeAdvList, nil
+}
+
+func nodeAdvListWithUpdate(existing *NodeAdvertisementList, toAdd *NodeAdvertisement) *NodeAdvertisementList {
+	res := existing.DeepCopy()
+	for i, item := range res.Items { // We override the element with the fresh copy
+		if item.Name == toAdd.Name {
+			res.Items[i] = *toAdd.DeepCopy()
+			return res
+		}
+	}
+	res.Items = append(res.Items, *toAdd.DeepCopy())
+	return res
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateNodeAdvertisement(t *testing.T) {
+	MetalLBNamespace = MetalLBTestNameSpace
+	Logger = log.NewNopLogger()
+
+	nodeAdv := NodeAdvertisement{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-nodeadv",
+			Namespace: MetalLBTestNameSpace,
+		},
+		Spec: NodeAdvertisementSpec{
+			Prefixes: []string{"10.10.10.10/32"},
+		},
+	}
+
+	toRestoreNodeAdvs := getExistingNodeAdvs
+	getExistingNodeAdvs = func() (*NodeAdvertisementList, error) {
+		return &NodeAdvertisementList{
+			Items: []NodeAdvertisement{
+				nodeAdv,
+			},
+		}, nil
+	}
+	defer func() {
+		getExistingNodeAdvs = toRestoreNodeAdvs
+	}()
+
+	tests := []struct {
+		desc         string
+		nodeAdv      *NodeAdvertisement
+		isNew        bool
+		failValidate bool
+		expected     *NodeAdvertisementList
+	}{
+		{
+			desc: "Second NodeAdvertisement",
+			nodeAdv: &NodeAdvertisement{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-nodeadv1",
+					Namespace: MetalLBTestNameSpace,
+				},
+			},
+			isNew: true,
+			expected: &NodeAdvertisementList{
+				Items: []NodeAdvertisement{
+					nodeAdv,
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test-nodeadv1",
+							Namespace: MetalLBTestNameSpace,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "Same NodeAdvertisement, update",
+			nodeAdv: &NodeAdvertisement{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-nodeadv",
+					Namespace: MetalLBTestNameSpace,
+				},
+				Spec: NodeAdvertisementSpec{
+					Prefixes: []string{"10.10.10.11/32"},
+				},
+			},
+			isNew: false,
+			expected: &NodeAdvertisementList{
+				Items: []NodeAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test-nodeadv",
+							Namespace: MetalLBTestNameSpace,
+						},
+						Spec: NodeAdvertisementSpec{
+							Prefixes: []string{"10.10.10.11/32"},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "Validation must fail if created in different namespace",
+			nodeAdv: &NodeAdvertisement{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-nodeadv1",
+					Namespace: "default",
+				},
+			},
+			isNew:        true,
+			expected:     nil,
+			failValidate: true,
+		},
+	}
+	for _, test := range tests {
+		var err error
+		mock := &mockValidator{}
+		Validator = mock
+		mock.forceError = test.failValidate
+
+		if test.isNew {
+			err = test.nodeAdv.ValidateCreate()
+		} else {
+			err = test.nodeAdv.ValidateUpdate(nil)
+		}
+		if test.failValidate && err == nil {
+			t.Fatalf("test %s failed, expecting error", test.desc)
+		}
+		if !cmp.Equal(test.expected, mock.nodeAdvs) {
+			t.Fatalf("test %s failed, %s", test.desc, cmp.Diff(test.expected, mock.nodeAdvs))
+		}
+	}
+}
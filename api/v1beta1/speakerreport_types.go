@@ -0,0 +1,68 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SpeakerReportStatus is what the speaker running on a node reports.
+type SpeakerReportStatus struct {
+	// Node is the name of the node the speaker runs on.
+	// +optional
+	Node string `json:"node,omitempty"`
+
+	// AnnouncedServices are the namespace/name of the services announced from the node,
+	// sorted and limited to the first 5000.
+	// +optional
+	AnnouncedServices []string `json:"announcedServices,omitempty"`
+
+	// AnnouncedServicesTruncated tells more services than the ones listed are announced
+	// from the node.
+	// +optional
+	AnnouncedServicesTruncated bool `json:"announcedServicesTruncated,omitempty"`
+
+	// Interfaces are the names of the network interfaces of the node, sorted.
+	// +optional
+	Interfaces []string `json:"interfaces,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.status.node`
+
+// SpeakerReport holds what the speaker running on a node reports to the
+// controller. Each speaker writes the one named after its node, only when
+// enabled.
+type SpeakerReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status SpeakerReportStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SpeakerReportList contains a list of SpeakerReport.
+type SpeakerReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SpeakerReport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SpeakerReport{}, &SpeakerReportList{})
+}
@@ -8,6 +8,7 @@
 package v1beta1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -313,6 +314,16 @@ func (in *BGPAdvertisementSpec) DeepCopyInto(out *BGPAdvertisementSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.AdditionalAggregationLengths != nil {
+		in, out := &in.AdditionalAggregationLengths, &out.AdditionalAggregationLengths
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdditionalAggregationLengthsV6 != nil {
+		in, out := &in.AdditionalAggregationLengthsV6, &out.AdditionalAggregationLengthsV6
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
 	if in.Communities != nil {
 		in, out := &in.Communities, &out.Communities
 		*out = make([]string, len(*in))
@@ -328,6 +339,35 @@ func (in *BGPAdvertisementSpec) DeepCopyInto(out *BGPAdvertisementSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.PeerAggregations != nil {
+		in, out := &in.PeerAggregations, &out.PeerAggregations
+		*out = make([]PeerAggregation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PeerCommunities != nil {
+		in, out := &in.PeerCommunities, &out.PeerCommunities
+		*out = make([]PeerCommunities, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EVPN != nil {
+		in, out := &in.EVPN, &out.EVPN
+		*out = new(EVPNAdvertisement)
+		**out = **in
+	}
+	if in.NotReadyGracePeriod != nil {
+		in, out := &in.NotReadyGracePeriod, &out.NotReadyGracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ServiceLabelCommunities != nil {
+		in, out := &in.ServiceLabelCommunities, &out.ServiceLabelCommunities
+		*out = new(ServiceLabelCommunities)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPAdvertisementSpec.
@@ -453,6 +493,21 @@ func (in *BGPPeerStatus) DeepCopy() *BGPPeerStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EVPNAdvertisement) DeepCopyInto(out *EVPNAdvertisement) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EVPNAdvertisement.
+func (in *EVPNAdvertisement) DeepCopy() *EVPNAdvertisement {
+	if in == nil {
+		return nil
+	}
+	out := new(EVPNAdvertisement)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IPAddressPool) DeepCopyInto(out *IPAddressPool) {
 	*out = *in
@@ -525,6 +580,16 @@ func (in *IPAddressPoolSpec) DeepCopyInto(out *IPAddressPoolSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.AvoidLastOctets != nil {
+		in, out := &in.AvoidLastOctets, &out.AvoidLastOctets
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllocateTo != nil {
+		in, out := &in.AllocateTo, &out.AllocateTo
+		*out = new(ServiceAllocation)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressPoolSpec.
@@ -619,6 +684,18 @@ func (in *L2AdvertisementSpec) DeepCopyInto(out *L2AdvertisementSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NodePreferences != nil {
+		in, out := &in.NodePreferences, &out.NodePreferences
+		*out = make([]NodePreference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NotReadyGracePeriod != nil {
+		in, out := &in.NotReadyGracePeriod, &out.NotReadyGracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2AdvertisementSpec.
@@ -696,6 +773,236 @@ func (in *MatchExpression) DeepCopy() *MatchExpression {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalLBMaintenance) DeepCopyInto(out *MetalLBMaintenance) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalLBMaintenance.
+func (in *MetalLBMaintenance) DeepCopy() *MetalLBMaintenance {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalLBMaintenance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetalLBMaintenance) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalLBMaintenanceList) DeepCopyInto(out *MetalLBMaintenanceList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MetalLBMaintenance, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalLBMaintenanceList.
+func (in *MetalLBMaintenanceList) DeepCopy() *MetalLBMaintenanceList {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalLBMaintenanceList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetalLBMaintenanceList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalLBMaintenanceSpec) DeepCopyInto(out *MetalLBMaintenanceSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalLBMaintenanceSpec.
+func (in *MetalLBMaintenanceSpec) DeepCopy() *MetalLBMaintenanceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalLBMaintenanceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeAdvertisement) DeepCopyInto(out *NodeAdvertisement) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeAdvertisement.
+func (in *NodeAdvertisement) DeepCopy() *NodeAdvertisement {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeAdvertisement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NodeAdvertisement) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeAdvertisementList) DeepCopyInto(out *NodeAdvertisementList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NodeAdvertisement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeAdvertisementList.
+func (in *NodeAdvertisementList) DeepCopy() *NodeAdvertisementList {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeAdvertisementList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NodeAdvertisementList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeAdvertisementSpec) DeepCopyInto(out *NodeAdvertisementSpec) {
+	*out = *in
+	if in.Prefixes != nil {
+		in, out := &in.Prefixes, &out.Prefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeSelectors != nil {
+		in, out := &in.NodeSelectors, &out.NodeSelectors
+		*out = make([]v1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Peers != nil {
+		in, out := &in.Peers, &out.Peers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Communities != nil {
+		in, out := &in.Communities, &out.Communities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(NodeHealthCheck)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeAdvertisementSpec.
+func (in *NodeAdvertisementSpec) DeepCopy() *NodeAdvertisementSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeAdvertisementSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeAdvertisementStatus) DeepCopyInto(out *NodeAdvertisementStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeAdvertisementStatus.
+func (in *NodeAdvertisementStatus) DeepCopy() *NodeAdvertisementStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeAdvertisementStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeHealthCheck) DeepCopyInto(out *NodeHealthCheck) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeHealthCheck.
+func (in *NodeHealthCheck) DeepCopy() *NodeHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodePreference) DeepCopyInto(out *NodePreference) {
+	*out = *in
+	in.Preference.DeepCopyInto(&out.Preference)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePreference.
+func (in *NodePreference) DeepCopy() *NodePreference {
+	if in == nil {
+		return nil
+	}
+	out := new(NodePreference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeSelector) DeepCopyInto(out *NodeSelector) {
 	*out = *in
@@ -725,6 +1032,195 @@ func (in *NodeSelector) DeepCopy() *NodeSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerAggregation) DeepCopyInto(out *PeerAggregation) {
+	*out = *in
+	if in.AggregationLength != nil {
+		in, out := &in.AggregationLength, &out.AggregationLength
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AggregationLengthV6 != nil {
+		in, out := &in.AggregationLengthV6, &out.AggregationLengthV6
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerAggregation.
+func (in *PeerAggregation) DeepCopy() *PeerAggregation {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerAggregation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerCommunities) DeepCopyInto(out *PeerCommunities) {
+	*out = *in
+	if in.Communities != nil {
+		in, out := &in.Communities, &out.Communities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerCommunities.
+func (in *PeerCommunities) DeepCopy() *PeerCommunities {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerCommunities)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAllocation) DeepCopyInto(out *ServiceAllocation) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelectors != nil {
+		in, out := &in.NamespaceSelectors, &out.NamespaceSelectors
+		*out = make([]v1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ServiceSelectors != nil {
+		in, out := &in.ServiceSelectors, &out.ServiceSelectors
+		*out = make([]v1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ServicePorts != nil {
+		in, out := &in.ServicePorts, &out.ServicePorts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAllocation.
+func (in *ServiceAllocation) DeepCopy() *ServiceAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceLabelCommunities) DeepCopyInto(out *ServiceLabelCommunities) {
+	*out = *in
+	if in.Communities != nil {
+		in, out := &in.Communities, &out.Communities
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLabelCommunities.
+func (in *ServiceLabelCommunities) DeepCopy() *ServiceLabelCommunities {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceLabelCommunities)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpeakerReport) DeepCopyInto(out *SpeakerReport) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpeakerReport.
+func (in *SpeakerReport) DeepCopy() *SpeakerReport {
+	if in == nil {
+		return nil
+	}
+	out := new(SpeakerReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SpeakerReport) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpeakerReportList) DeepCopyInto(out *SpeakerReportList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SpeakerReport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpeakerReportList.
+func (in *SpeakerReportList) DeepCopy() *SpeakerReportList {
+	if in == nil {
+		return nil
+	}
+	out := new(SpeakerReportList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SpeakerReportList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpeakerReportStatus) DeepCopyInto(out *SpeakerReportStatus) {
+	*out = *in
+	if in.AnnouncedServices != nil {
+		in, out := &in.AnnouncedServices, &out.AnnouncedServices
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Interfaces != nil {
+		in, out := &in.Interfaces, &out.Interfaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpeakerReportStatus.
+func (in *SpeakerReportStatus) DeepCopy() *SpeakerReportStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SpeakerReportStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Community) DeepCopyInto(out *Community) {
 	*out = *in
@@ -40,6 +40,19 @@ type BGPPeerSpec struct {
 	// +optional
 	SrcAddress string `json:"sourceAddress,omitempty"`
 
+	// Source port, or range of source ports of the form "min-max", to use when
+	// establishing the session. The first available port of the range is used.
+	// When not set, an ephemeral port is used. Available only in native mode.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(-[0-9]+)?$`
+	SrcPortRange string `json:"sourcePortRange,omitempty"`
+
+	// TCPKeepalive enables the TCP keepalives on the socket of the session, to
+	// detect a dead peer faster than with the BGP hold time when BFD is not
+	// used. Available only in native mode.
+	// +optional
+	TCPKeepalive *TCPKeepalive `json:"tcpKeepalive,omitempty"`
+
 	// Port to dial when establishing the session.
 	// +optional
 	// +kubebuilder:validation:Minimum=0
@@ -83,13 +96,99 @@ type BGPPeerSpec struct {
 	// +optional
 	EBGPMultiHop bool `json:"ebgpMultiHop,omitempty"`
 
+	// MinimumTTL enables the Generalized TTL Security Mechanism (RFC5082):
+	// the BGP packets are sent with a TTL of 255, and the ones received from
+	// the BGPPeer with a lower TTL than this are dropped. Must be 255 for the
+	// eBGP peers that are not multi-hops away. Available only in FRR mode.
+	// +optional
+	// +kubebuilder:validation:Minimum=2
+	// +kubebuilder:validation:Maximum=255
+	MinimumTTL *uint32 `json:"minimumTtl,omitempty"`
+
 	// To set if we want to peer with the BGPPeer using an interface belonging to
 	// a host vrf
 	// +optional
 	VRFName string `json:"vrf,omitempty"`
+
+	// ImportFilter selects the routes accepted from the BGPPeer. When not set,
+	// all the routes received from the peer are rejected. Available only in FRR mode.
+	// +optional
+	ImportFilter *ImportFilter `json:"importFilter,omitempty"`
+
+	// AddPath enables the BGP additional paths capability (RFC7911) with the
+	// BGPPeer, to exchange multiple paths for the same prefix. The capability
+	// is used only if the BGPPeer supports it. Available only in FRR mode.
+	// +optional
+	AddPath *AddPath `json:"addPath,omitempty"`
+
+	// AddressFamilies are the families of the IPs advertised to the BGPPeer, for the
+	// BGPPeers supporting only one of them. In FRR mode, only the address families
+	// listed are activated for the BGPPeer. When empty, the IPs of both families are
+	// advertised.
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	AddressFamilies []AddressFamily `json:"addressFamilies,omitempty"`
+
+	// NextHopSelf sets the speaker as the next hop of the routes sent to the
+	// BGPPeer, also for the ones learned from other peers. Meant for the iBGP
+	// peering with route reflectors, the next hop being already set to the
+	// speaker for eBGP peers. Available only in FRR mode.
+	// +optional
+	NextHopSelf bool `json:"nextHopSelf,omitempty"`
 	// Add future BGP configuration here
 }
 
+// AddressFamily is the family of the IPs advertised to a BGPPeer.
+// +kubebuilder:validation:Enum=ipv4;ipv6
+type AddressFamily string
+
+// AddPath defines the directions the additional paths are exchanged with a
+// BGPPeer.
+type AddPath struct {
+	// Send advertises to the BGPPeer all the paths known for a prefix,
+	// instead of the best one only.
+	// +optional
+	Send bool `json:"send,omitempty"`
+
+	// Receive accepts multiple paths for the same prefix from the BGPPeer.
+	// +optional
+	Receive bool `json:"receive,omitempty"`
+}
+
+// TCPKeepalive defines the TCP keepalives sent on the socket of a BGP session.
+// The system defaults are used for the values not set.
+type TCPKeepalive struct {
+	// Idle is the time the connection must be idle before the first keepalive
+	// probe is sent, in whole seconds.
+	// +optional
+	Idle metav1.Duration `json:"idle,omitempty"`
+
+	// Interval is the time between two keepalive probes, in whole seconds.
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
+
+	// Count is the number of unanswered keepalive probes after which the
+	// connection is dropped.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=127
+	Count uint32 `json:"count,omitempty"`
+}
+
+// ImportFilter defines the routes accepted from a BGPPeer. A route is accepted
+// when it matches all the criteria set.
+type ImportFilter struct {
+	// Prefixes is the list of the prefixes accepted from the peer, each of the form
+	// "<prefix> [ge <length>] [le <length>]".
+	// +optional
+	Prefixes []string `json:"prefixes,omitempty"`
+
+	// Communities is the list of the communities a route must carry one of to be
+	// accepted, each of the form 1234:1234 or the name of an alias defined in the Community CRD.
+	// +optional
+	Communities []string `json:"communities,omitempty"`
+}
+
 // BGPPeerStatus defines the observed state of Peer.
 type BGPPeerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -12,6 +12,21 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddPath) DeepCopyInto(out *AddPath) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddPath.
+func (in *AddPath) DeepCopy() *AddPath {
+	if in == nil {
+		return nil
+	}
+	out := new(AddPath)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BGPPeer) DeepCopyInto(out *BGPPeer) {
 	*out = *in
@@ -74,6 +89,11 @@ func (in *BGPPeerList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BGPPeerSpec) DeepCopyInto(out *BGPPeerSpec) {
 	*out = *in
+	if in.TCPKeepalive != nil {
+		in, out := &in.TCPKeepalive, &out.TCPKeepalive
+		*out = new(TCPKeepalive)
+		**out = **in
+	}
 	out.HoldTime = in.HoldTime
 	out.KeepaliveTime = in.KeepaliveTime
 	if in.NodeSelectors != nil {
@@ -84,6 +104,26 @@ func (in *BGPPeerSpec) DeepCopyInto(out *BGPPeerSpec) {
 		}
 	}
 	out.PasswordSecret = in.PasswordSecret
+	if in.MinimumTTL != nil {
+		in, out := &in.MinimumTTL, &out.MinimumTTL
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.ImportFilter != nil {
+		in, out := &in.ImportFilter, &out.ImportFilter
+		*out = new(ImportFilter)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AddPath != nil {
+		in, out := &in.AddPath, &out.AddPath
+		*out = new(AddPath)
+		**out = **in
+	}
+	if in.AddressFamilies != nil {
+		in, out := &in.AddressFamilies, &out.AddressFamilies
+		*out = make([]AddressFamily, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPPeerSpec.
@@ -110,3 +150,45 @@ func (in *BGPPeerStatus) DeepCopy() *BGPPeerStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImportFilter) DeepCopyInto(out *ImportFilter) {
+	*out = *in
+	if in.Prefixes != nil {
+		in, out := &in.Prefixes, &out.Prefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Communities != nil {
+		in, out := &in.Communities, &out.Communities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImportFilter.
+func (in *ImportFilter) DeepCopy() *ImportFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportFilter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TCPKeepalive) DeepCopyInto(out *TCPKeepalive) {
+	*out = *in
+	out.Idle = in.Idle
+	out.Interval = in.Interval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TCPKeepalive.
+func (in *TCPKeepalive) DeepCopy() *TCPKeepalive {
+	if in == nil {
+		return nil
+	}
+	out := new(TCPKeepalive)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -3,11 +3,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
+	"strings"
 	"testing"
 
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/internal/allocator"
 	"go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/k8s/controllers"
@@ -15,6 +18,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/google/go-cmp/cmp"
+	ptu "github.com/prometheus/client_golang/prometheus/testutil"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -74,6 +78,9 @@ func selector(s string) labels.Selector {
 type testK8S struct {
 	updateService       *v1.Service
 	updateServiceStatus *v1.ServiceStatus
+	poolStatus          map[string]metallbv1beta1.IPAddressPoolStatus
+	poolStatusWrites    int
+	poolStatusErr       error
 	loggedWarning       bool
 	t                   *testing.T
 }
@@ -83,6 +90,18 @@ func (s *testK8S) UpdateStatus(svc *v1.Service) error {
 	return nil
 }
 
+func (s *testK8S) UpdatePoolStatus(name string, status metallbv1beta1.IPAddressPoolStatus) error {
+	s.poolStatusWrites++
+	if s.poolStatusErr != nil {
+		return s.poolStatusErr
+	}
+	if s.poolStatus == nil {
+		s.poolStatus = map[string]metallbv1beta1.IPAddressPoolStatus{}
+	}
+	s.poolStatus[name] = status
+	return nil
+}
+
 func (s *testK8S) Infof(_ *v1.Service, evtType string, msg string, args ...interface{}) {
 	s.t.Logf("k8s Info event %q: %s", evtType, fmt.Sprintf(msg, args...))
 }
@@ -1108,6 +1127,216 @@ func TestDeleteRecyclesIP(t *testing.T) {
 		t.Fatal("svc2 didn't get an IP")
 	}
 }
+
+func TestPendingServices(t *testing.T) {
+	k := &testK8S{t: t}
+	c := &controller{
+		ips:    allocator.New(),
+		client: k,
+	}
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name:       "default",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/32")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+
+	services := map[string]*v1.Service{
+		"allocated": {
+			Spec: v1.ServiceSpec{
+				Type:       "LoadBalancer",
+				ClusterIPs: []string{"1.2.3.4"},
+			},
+		},
+		"exhausted": {
+			Spec: v1.ServiceSpec{
+				Type:       "LoadBalancer",
+				ClusterIPs: []string{"1.2.3.5"},
+			},
+		},
+		"nopool": {
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					annotationAddressPool: "unknown",
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Type:       "LoadBalancer",
+				ClusterIPs: []string{"1.2.3.6"},
+			},
+		},
+		"familymismatch": {
+			Spec: v1.ServiceSpec{
+				Type:       "LoadBalancer",
+				ClusterIPs: []string{"1000::1"},
+			},
+		},
+	}
+	for _, name := range []string{"allocated", "exhausted", "nopool", "familymismatch"} {
+		if c.SetBalancer(l, name, services[name], epslices.EpsOrSlices{}) == controllers.SyncStateError {
+			t.Fatalf("SetBalancer %s failed", name)
+		}
+	}
+
+	expected := map[string]float64{
+		allocator.ReasonExhausted:      1,
+		allocator.ReasonNoPool:         1,
+		allocator.ReasonFamilyMismatch: 1,
+		allocator.ReasonIPTaken:        0,
+	}
+	for reason, value := range expected {
+		if got := ptu.ToFloat64(pendingServices.WithLabelValues(reason)); got != value {
+			t.Errorf("reason %s: expected %f pending services, got %f", reason, value, got)
+		}
+	}
+
+	// Deleting the allocated service makes room for the exhausted one.
+	c.SetBalancer(l, "allocated", nil, epslices.EpsOrSlices{})
+	if c.SetBalancer(l, "exhausted", services["exhausted"], epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer exhausted failed")
+	}
+	c.SetBalancer(l, "nopool", nil, epslices.EpsOrSlices{})
+	for _, reason := range []string{allocator.ReasonExhausted, allocator.ReasonNoPool} {
+		if got := ptu.ToFloat64(pendingServices.WithLabelValues(reason)); got != 0 {
+			t.Errorf("reason %s: expected no pending services, got %f", reason, got)
+		}
+	}
+}
+
+func TestPoolStatus(t *testing.T) {
+	k := &testK8S{t: t}
+	c := &controller{
+		ips:    allocator.New(),
+		client: k,
+	}
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name:       "default",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/30"), ipnet("1000::/127")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+	c.poolStatus.flush(l, k)
+	want := metallbv1beta1.IPAddressPoolStatus{
+		AvailableIPv4:  4,
+		AvailableIPv6:  2,
+		AvailableCount: 6,
+	}
+	if diff := cmp.Diff(want, k.poolStatus["default"]); diff != "" {
+		t.Fatalf("unexpected pool status after SetPools (-want +got)\n%s", diff)
+	}
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:       "LoadBalancer",
+			ClusterIPs: []string{"1.2.3.4", "1000::"},
+		},
+	}
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	c.poolStatus.flush(l, k)
+	want = metallbv1beta1.IPAddressPoolStatus{
+		AssignedIPv4:   1,
+		AssignedIPv6:   1,
+		AvailableIPv4:  3,
+		AvailableIPv6:  1,
+		AssignedCount:  2,
+		AvailableCount: 4,
+	}
+	if diff := cmp.Diff(want, k.poolStatus["default"]); diff != "" {
+		t.Fatalf("unexpected pool status after SetBalancer (-want +got)\n%s", diff)
+	}
+
+	// Unchanged counters must not be written again.
+	k.poolStatus = nil
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	c.poolStatus.flush(l, k)
+	if k.poolStatus != nil {
+		t.Fatalf("pool status written with unchanged counters")
+	}
+
+	if c.SetBalancer(l, "test", nil, epslices.EpsOrSlices{}) != controllers.SyncStateReprocessAll {
+		t.Fatal("SetBalancer with nil LB didn't tell us to reprocess all balancers")
+	}
+	c.poolStatus.flush(l, k)
+	want = metallbv1beta1.IPAddressPoolStatus{
+		AvailableIPv4:  4,
+		AvailableIPv6:  2,
+		AvailableCount: 6,
+	}
+	if diff := cmp.Diff(want, k.poolStatus["default"]); diff != "" {
+		t.Fatalf("unexpected pool status after deletion (-want +got)\n%s", diff)
+	}
+}
+
+func TestPoolStatusCoalesced(t *testing.T) {
+	k := &testK8S{t: t}
+	c := &controller{
+		ips:    allocator.New(),
+		client: k,
+	}
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name:       "default",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+	for i := 0; i < 10; i++ {
+		svc := &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:       "LoadBalancer",
+				ClusterIPs: []string{"1.2.3.4"},
+			},
+		}
+		if c.SetBalancer(l, fmt.Sprintf("default/svc%d", i), svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+			t.Fatalf("SetBalancer svc%d failed", i)
+		}
+	}
+	if k.poolStatusWrites != 0 {
+		t.Fatalf("expected no write before flushing, got %d", k.poolStatusWrites)
+	}
+
+	// A failed write is retried with the next flush.
+	k.poolStatusErr = errors.New("conflict")
+	c.poolStatus.flush(l, k)
+	if k.poolStatusWrites != 1 {
+		t.Fatalf("expected a single write for the pool, got %d", k.poolStatusWrites)
+	}
+	k.poolStatusErr = nil
+	c.poolStatus.flush(l, k)
+	if k.poolStatusWrites != 2 {
+		t.Fatalf("expected the failed write to be retried, got %d writes", k.poolStatusWrites)
+	}
+	if got := k.poolStatus["default"].AssignedCount; got != 10 {
+		t.Fatalf("expected 10 assigned IPs, got %d", got)
+	}
+
+	c.poolStatus.flush(l, k)
+	if k.poolStatusWrites != 2 {
+		t.Fatalf("expected no write with nothing queued, got %d writes", k.poolStatusWrites)
+	}
+}
+
 func TestControllerDualStackConfig(t *testing.T) {
 	k := &testK8S{t: t}
 	c := &controller{
@@ -1192,3 +1421,138 @@ func TestControllerDualStackConfig(t *testing.T) {
 		t.Fatalf("SetPools that deletes the config was accepted")
 	}
 }
+
+func TestRequestedIPsFamilyMismatch(t *testing.T) {
+	k := &testK8S{t: t}
+	c := &controller{
+		ips:    allocator.New(),
+		client: k,
+	}
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name:       "default",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/24"), ipnet("1000::/120")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+
+	tests := []struct {
+		desc       string
+		clusterIPs []string
+		requested  string
+		errorMsg   string
+	}{
+		{
+			desc:       "ipv6 requested for an ipv4 service",
+			clusterIPs: []string{"10.0.0.1"},
+			requested:  "1000::1",
+			errorMsg:   "a single stack IPv4 service must request one IPv4 address",
+		},
+		{
+			desc:       "ipv4 requested for an ipv6 service",
+			clusterIPs: []string{"fd00::1"},
+			requested:  "1.2.3.1",
+			errorMsg:   "a single stack IPv6 service must request one IPv6 address",
+		},
+		{
+			desc:       "single ip requested for a dual stack service",
+			clusterIPs: []string{"10.0.0.1", "fd00::1"},
+			requested:  "1.2.3.1",
+			errorMsg:   "a dual stack service must request one IPv4 and one IPv6 address",
+		},
+		{
+			desc:       "two ipv4 requested",
+			clusterIPs: []string{"10.0.0.1", "fd00::1"},
+			requested:  "1.2.3.1,1.2.3.2",
+			errorMsg:   "at most one IPv4 and one IPv6 address can be requested",
+		},
+		{
+			desc:       "matching families",
+			clusterIPs: []string{"10.0.0.1", "fd00::1"},
+			requested:  "1.2.3.1,1000::1",
+		},
+	}
+	for _, test := range tests {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					annotationLoadBalancerIPs: test.requested,
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Type:       "LoadBalancer",
+				ClusterIPs: test.clusterIPs,
+			},
+		}
+		_, err := c.allocateIPs("test", svc)
+		c.ips.Unassign("test")
+		if test.errorMsg == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", test.desc, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.errorMsg) {
+			t.Errorf("%s: expected error containing %q, got %v", test.desc, test.errorMsg, err)
+		}
+	}
+}
+
+func TestIgnorePoolSelectorsAnnotation(t *testing.T) {
+	k := &testK8S{t: t}
+	c := &controller{
+		ips:    allocator.New(),
+		client: k,
+	}
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"restricted": {
+			Name:               "restricted",
+			CIDR:               []*net.IPNet{ipnet("1.2.3.0/24")},
+			ServiceAllocations: &config.ServiceAllocation{Namespaces: sets.New("allowed")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "other",
+			Annotations: map[string]string{
+				annotationLoadBalancerIPs: "1.2.3.1",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Type:       "LoadBalancer",
+			ClusterIPs: []string{"10.0.0.1"},
+		},
+	}
+
+	k.reset()
+	c.SetBalancer(l, "other/test", svc, epslices.EpsOrSlices{})
+	if !k.loggedWarning {
+		t.Error("requesting an IP of a pool not selecting the service didn't log an error")
+	}
+	if got := k.gotService(svc); got != nil && len(got.Status.LoadBalancer.Ingress) != 0 {
+		t.Errorf("service got ingress %v from a pool not selecting it", got.Status.LoadBalancer.Ingress)
+	}
+
+	svc.Annotations[annotationIgnorePoolSelectors] = "true"
+	k.reset()
+	c.SetBalancer(l, "other/test", svc, epslices.EpsOrSlices{})
+	if k.loggedWarning {
+		t.Error("requesting an IP ignoring the pool selectors logged an error")
+	}
+	got := k.gotService(svc)
+	if got == nil || len(got.Status.LoadBalancer.Ingress) != 1 || got.Status.LoadBalancer.Ingress[0].IP != "1.2.3.1" {
+		t.Errorf("expected the service to be assigned 1.2.3.1, got %v", got)
+	}
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"go.universe.tf/metallb/internal/config"
+	v1 "k8s.io/api/core/v1"
+)
+
+// checkExternalIPs warns when the external IPs of the service fall within
+// a pool. MetalLB does not manage them, and may assign the same IPs to
+// other services, so that the traffic to them collides. The warning is
+// emitted when the colliding IPs of the service change.
+func (c *controller) checkExternalIPs(l log.Logger, key string, svc *v1.Service) {
+	colliding := externalIPsInPools(svc, c.pools)
+	if len(colliding) == 0 {
+		delete(c.externalIPsWarned, key)
+		return
+	}
+	msg := strings.Join(colliding, ", ")
+	if c.externalIPsWarned[key] == msg {
+		return
+	}
+	if c.externalIPsWarned == nil {
+		c.externalIPsWarned = map[string]string{}
+	}
+	c.externalIPsWarned[key] = msg
+	level.Warn(l).Log("event", "externalIPsInPool", "ips", msg, "msg", "external IPs of the service fall within a pool")
+	c.client.Errorf(svc, "ExternalIPInPool", "External IPs %s fall within MetalLB pools, which may assign them to other services", msg)
+}
+
+// externalIPsInPools returns the external IPs of the service falling
+// within a pool, formatted as "ip (pool)". The IPs assigned to the service
+// by MetalLB are not reported.
+func externalIPsInPools(svc *v1.Service, pools *config.Pools) []string {
+	if len(svc.Spec.ExternalIPs) == 0 || pools == nil {
+		return nil
+	}
+	assigned := map[string]bool{}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		assigned[ingress.IP] = true
+	}
+	names := make([]string, 0, len(pools.ByName))
+	for name := range pools.ByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var res []string
+	for _, s := range svc.Spec.ExternalIPs {
+		ip := net.ParseIP(s)
+		if ip == nil || assigned[ip.String()] {
+			continue
+		}
+	pools:
+		for _, name := range names {
+			for _, cidr := range pools.ByName[name].CIDR {
+				if cidr.Contains(ip) {
+					res = append(res, fmt.Sprintf("%s (%s)", ip, name))
+					break pools
+				}
+			}
+		}
+	}
+	return res
+}
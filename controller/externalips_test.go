@@ -0,0 +1,116 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/internal/allocator"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestExternalIPsInPools(t *testing.T) {
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"pool1": {CIDR: []*net.IPNet{ipnet("1.2.3.0/24")}},
+		"pool2": {CIDR: []*net.IPNet{ipnet("1.2.4.0/24"), ipnet("1000::/120")}},
+	}}
+
+	tests := []struct {
+		desc        string
+		externalIPs []string
+		ingress     []string
+		want        []string
+	}{
+		{
+			desc: "no external ips",
+		},
+		{
+			desc:        "outside of the pools",
+			externalIPs: []string{"10.0.0.1", "2000::1"},
+		},
+		{
+			desc:        "within the pools",
+			externalIPs: []string{"1.2.3.4", "10.0.0.1", "1.2.4.5", "1000::1"},
+			want:        []string{"1.2.3.4 (pool1)", "1.2.4.5 (pool2)", "1000::1 (pool2)"},
+		},
+		{
+			desc:        "assigned to the service",
+			externalIPs: []string{"1.2.3.4", "1.2.4.5"},
+			ingress:     []string{"1.2.3.4"},
+			want:        []string{"1.2.4.5 (pool2)"},
+		},
+		{
+			desc:        "invalid ip",
+			externalIPs: []string{"foo"},
+		},
+	}
+
+	for _, test := range tests {
+		svc := &v1.Service{Spec: v1.ServiceSpec{ExternalIPs: test.externalIPs}}
+		for _, ip := range test.ingress {
+			svc.Status.LoadBalancer.Ingress = append(svc.Status.LoadBalancer.Ingress, v1.LoadBalancerIngress{IP: ip})
+		}
+		if diff := cmp.Diff(test.want, externalIPsInPools(svc, pools)); diff != "" {
+			t.Errorf("%s: unexpected colliding ips (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestCheckExternalIPs(t *testing.T) {
+	k := &testK8S{t: t}
+	c := &controller{
+		ips:         allocator.New(),
+		client:      k,
+		checkExtIPs: true,
+	}
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name: "default",
+			CIDR: []*net.IPNet{ipnet("1.2.3.0/24")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:        "ClusterIP",
+			ClusterIPs:  []string{"10.96.0.1"},
+			ExternalIPs: []string{"1.2.3.4"},
+		},
+	}
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	if !k.loggedWarning {
+		t.Fatal("expected a warning about the external IP within the pool")
+	}
+
+	// Not warned again while the colliding IPs don't change.
+	k.reset()
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	if k.loggedWarning {
+		t.Fatal("expected no warning for the same external IPs")
+	}
+
+	svc.Spec.ExternalIPs = []string{"10.0.0.1"}
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	if k.loggedWarning {
+		t.Fatal("expected no warning for an external IP outside of the pools")
+	}
+	if _, ok := c.externalIPsWarned["test"]; ok {
+		t.Fatal("expected the service not to be tracked anymore")
+	}
+}
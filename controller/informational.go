@@ -0,0 +1,88 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"net"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "k8s.io/api/core/v1"
+
+	"go.universe.tf/metallb/internal/allocator/k8salloc"
+)
+
+const (
+	// annotationAllocateInformationalIP requests, on a service not of type
+	// LoadBalancer, an IP that is recorded on the service but never
+	// announced.
+	annotationAllocateInformationalIP = "metallb.universe.tf/allocate-informational-ip"
+	// annotationInformationalIP holds the comma separated IPs allocated to
+	// a service not of type LoadBalancer.
+	annotationInformationalIP = "metallb.universe.tf/informational-ip"
+)
+
+// wantsInformationalIP tells if the service is not a LoadBalancer and an
+// informational IP must be allocated to it.
+func (c *controller) wantsInformationalIP(svc *v1.Service) bool {
+	return c.informationalIPs &&
+		svc.Spec.Type != v1.ServiceTypeLoadBalancer &&
+		svc.Annotations[annotationAllocateInformationalIP] == "true"
+}
+
+// convergeInformationalIP allocates an IP to a service not of type
+// LoadBalancer and records it in the informational IP annotation. The
+// status of the service is left empty, so the speakers never announce it.
+func (c *controller) convergeInformationalIP(l log.Logger, key string, svc *v1.Service) {
+	c.ips.Unassign(reallocationKey(key))
+	delete(svc.Annotations, annotationReallocationStarted)
+	delete(svc.Annotations, annotationIPAllocateFromPool)
+	svc.Status.LoadBalancer = v1.LoadBalancerStatus{}
+
+	if len(svc.Spec.ClusterIPs) == 0 && svc.Spec.ClusterIP == "" {
+		level.Info(l).Log("event", "clearAssignment", "reason", "noClusterIPs", "msg", "No ClusterIPs")
+		c.clearInformationalIP(key, svc)
+		return
+	}
+
+	current := []net.IP{}
+	if s := svc.Annotations[annotationInformationalIP]; s != "" {
+		for _, ip := range strings.Split(s, ",") {
+			if parsed := net.ParseIP(strings.TrimSpace(ip)); parsed != nil {
+				current = append(current, parsed)
+			}
+		}
+	}
+	if len(current) != 0 {
+		desiredPool := svc.Annotations[annotationAddressPool]
+		err := c.ips.Assign(key, svc, current, k8salloc.Ports(svc), k8salloc.SharingKey(svc), k8salloc.BackendKey(svc))
+		if err == nil && (desiredPool == "" || c.ips.Pool(key) == desiredPool) {
+			return
+		}
+		level.Info(l).Log("event", "clearAssignment", "error", err, "msg", "current informational IP not allowed anymore, clearing")
+		c.clearInformationalIP(key, svc)
+	}
+
+	ips, err := c.allocateIPs(key, svc)
+	if err != nil {
+		level.Error(l).Log("op", "allocateIPs", "error", err, "msg", "informational IP allocation failed")
+		c.client.Errorf(svc, "AllocationFailed", "Failed to allocate informational IP for %q: %s", key, err)
+		return
+	}
+	ipStrings := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		ipStrings = append(ipStrings, ip.String())
+	}
+	if svc.Annotations == nil {
+		svc.Annotations = make(map[string]string)
+	}
+	svc.Annotations[annotationInformationalIP] = strings.Join(ipStrings, ",")
+	level.Info(l).Log("event", "ipAllocated", "ip", ips, "msg", "informational IP address assigned by controller")
+	c.client.Infof(svc, "IPAllocated", "Assigned informational IP %q", ips)
+}
+
+func (c *controller) clearInformationalIP(key string, svc *v1.Service) {
+	c.ips.Unassign(key)
+	delete(svc.Annotations, annotationInformationalIP)
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"net"
+	"testing"
+
+	"go.universe.tf/metallb/internal/allocator"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+
+	"github.com/go-kit/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInformationalIP(t *testing.T) {
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name:       "default",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/31")},
+		},
+	}}
+	nodePort := func(annotations map[string]string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+			Spec: v1.ServiceSpec{
+				Type:       "NodePort",
+				ClusterIPs: []string{"10.0.0.1"},
+			},
+		}
+	}
+
+	tests := []struct {
+		desc    string
+		enabled bool
+		svc     *v1.Service
+		wantIP  string
+	}{
+		{
+			desc:    "flag disabled",
+			enabled: false,
+			svc:     nodePort(map[string]string{annotationAllocateInformationalIP: "true"}),
+		},
+		{
+			desc:    "annotation not set",
+			enabled: true,
+			svc:     nodePort(nil),
+		},
+		{
+			desc:    "annotation not true",
+			enabled: true,
+			svc:     nodePort(map[string]string{annotationAllocateInformationalIP: "yes"}),
+		},
+		{
+			desc:    "allocated",
+			enabled: true,
+			svc:     nodePort(map[string]string{annotationAllocateInformationalIP: "true"}),
+			wantIP:  "1.2.3.0",
+		},
+		{
+			desc:    "current IP kept",
+			enabled: true,
+			svc: nodePort(map[string]string{
+				annotationAllocateInformationalIP: "true",
+				annotationInformationalIP:         "1.2.3.1",
+			}),
+			wantIP: "1.2.3.1",
+		},
+		{
+			desc:    "stale IP cleared",
+			enabled: false,
+			svc: nodePort(map[string]string{
+				annotationAllocateInformationalIP: "true",
+				annotationInformationalIP:         "1.2.3.1",
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			k := &recordingK8S{testK8S: testK8S{t: t}}
+			c := &controller{
+				ips:              allocator.New(),
+				client:           k,
+				informationalIPs: test.enabled,
+			}
+			if c.SetPools(l, pools) == controllers.SyncStateError {
+				t.Fatal("SetPools failed")
+			}
+			if c.SetBalancer(l, "test", test.svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+				t.Fatal("SetBalancer failed")
+			}
+			svc := test.svc
+			if k.updated != nil {
+				svc = k.updated
+			}
+			if got := svc.Annotations[annotationInformationalIP]; got != test.wantIP {
+				t.Fatalf("expected informational ip %q, got %q", test.wantIP, got)
+			}
+			if len(svc.Status.LoadBalancer.Ingress) != 0 {
+				t.Fatalf("expected no ingress, got %v", svc.Status.LoadBalancer.Ingress)
+			}
+			if (c.ips.Pool("test") != "") != (test.wantIP != "") {
+				t.Fatalf("unexpected allocation from pool %q", c.ips.Pool("test"))
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"go.universe.tf/metallb/internal/allocator"
+)
+
+// ipamRetryInterval is how often the requests which failed because the
+// external IPAM was unavailable are retried.
+const ipamRetryInterval = 30 * time.Second
+
+// retryIPAMAfter reprocesses the services in the given delay, unless a
+// reprocessing is already due by then.
+func (c *controller) retryIPAMAfter(d time.Duration) {
+	now := time.Now()
+	at := now.Add(d)
+	if now.Before(c.ipamRetryAt) && !at.Before(c.ipamRetryAt) {
+		return
+	}
+	c.ipamRetryAt = at
+	c.reprocessAfter(d)
+}
+
+// ipamRequest is the body of the requests sent to the external IPAM
+// webhook.
+type ipamRequest struct {
+	Service string   `json:"service"`
+	Pool    string   `json:"pool"`
+	IPs     []string `json:"ips"`
+}
+
+// ipamWebhook reserves and releases the addresses with an external IPAM,
+// by posting them to the /reserve and /release paths of its URL. A 409
+// Conflict answer to a reservation refuses the addresses. The failed
+// releases are retried by run until they succeed, or the addresses are
+// reserved again.
+type ipamWebhook struct {
+	logger log.Logger
+	url    string
+	client *http.Client
+
+	// mu serializes the requests, so that a retried release can't
+	// overtake a later reservation of the same address.
+	mu sync.Mutex
+	// failedReleases holds the service and pool of the addresses whose
+	// release failed, by address.
+	failedReleases map[string]ipamRelease
+}
+
+// ipamRelease is the release of an address which failed.
+type ipamRelease struct {
+	svcKey string
+	pool   string
+}
+
+func newIPAMWebhook(l log.Logger, url string, timeout time.Duration) *ipamWebhook {
+	return &ipamWebhook{
+		logger:         l,
+		url:            strings.TrimSuffix(url, "/"),
+		client:         &http.Client{Timeout: timeout},
+		failedReleases: map[string]ipamRelease{},
+	}
+}
+
+func (w *ipamWebhook) Reserve(svcKey, pool string, ips []net.IP) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// The addresses are in use again, their release must not be
+	// retried anymore.
+	for _, ip := range ips {
+		delete(w.failedReleases, ip.String())
+	}
+	status, err := w.post("reserve", svcKey, pool, ips)
+	if err != nil {
+		return err
+	}
+	switch {
+	case status == http.StatusConflict:
+		return allocator.ErrRefused
+	case status < 200 || status >= 300:
+		return fmt.Errorf("unexpected status %d", status)
+	}
+	return nil
+}
+
+func (w *ipamWebhook) Release(svcKey, pool string, ips []net.IP) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.release(svcKey, pool, ips); err != nil {
+		level.Error(w.logger).Log("op", "releaseIPs", "service", svcKey, "pool", pool, "ips", fmt.Sprint(ips), "error", err, "msg", "failed to release the IPs with the external IPAM, retrying")
+		for _, ip := range ips {
+			w.failedReleases[ip.String()] = ipamRelease{svcKey: svcKey, pool: pool}
+		}
+	}
+}
+
+// retryReleases retries the failed releases, keeping the ones failing
+// again.
+func (w *ipamWebhook) retryReleases() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ip, r := range w.failedReleases {
+		if err := w.release(r.svcKey, r.pool, []net.IP{net.ParseIP(ip)}); err != nil {
+			level.Error(w.logger).Log("op", "releaseIPs", "service", r.svcKey, "pool", r.pool, "ips", ip, "error", err, "msg", "failed to release the IPs with the external IPAM, retrying")
+			continue
+		}
+		delete(w.failedReleases, ip)
+	}
+}
+
+// run retries the failed releases every interval, until stop is closed.
+func (w *ipamWebhook) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.retryReleases()
+		}
+	}
+}
+
+func (w *ipamWebhook) release(svcKey, pool string, ips []net.IP) error {
+	status, err := w.post("release", svcKey, pool, ips)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("unexpected status %d", status)
+	}
+	return nil
+}
+
+func (w *ipamWebhook) post(path, svcKey, pool string, ips []net.IP) (int, error) {
+	req := ipamRequest{Service: svcKey, Pool: pool}
+	for _, ip := range ips {
+		req.IPs = append(req.IPs, ip.String())
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := w.client.Post(w.url+"/"+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
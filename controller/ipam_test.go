@@ -0,0 +1,163 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"go.universe.tf/metallb/internal/allocator"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestIPAMWebhook(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ipamRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode the request: %s", err)
+		}
+		requests = append(requests, r.URL.Path+" "+req.Service+" "+req.Pool+" "+req.IPs[0])
+		switch req.IPs[0] {
+		case "10.0.0.1":
+			w.WriteHeader(http.StatusConflict)
+		case "10.0.0.2":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	ipam := newIPAMWebhook(log.NewNopLogger(), srv.URL+"/", time.Second)
+	if err := ipam.Reserve("ns/svc", "pool", []net.IP{net.ParseIP("10.0.0.0")}); err != nil {
+		t.Fatalf("expected the reservation to succeed, got %s", err)
+	}
+	if err := ipam.Reserve("ns/svc", "pool", []net.IP{net.ParseIP("10.0.0.1")}); !errors.Is(err, allocator.ErrRefused) {
+		t.Fatalf("expected the reservation to be refused, got %v", err)
+	}
+	if err := ipam.Reserve("ns/svc", "pool", []net.IP{net.ParseIP("10.0.0.2")}); err == nil || errors.Is(err, allocator.ErrRefused) {
+		t.Fatalf("expected the reservation to fail, got %v", err)
+	}
+	ipam.Release("ns/svc", "pool", []net.IP{net.ParseIP("10.0.0.0")})
+
+	expected := []string{
+		"/reserve ns/svc pool 10.0.0.0",
+		"/reserve ns/svc pool 10.0.0.1",
+		"/reserve ns/svc pool 10.0.0.2",
+		"/release ns/svc pool 10.0.0.0",
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Fatalf("expected requests %v, got %v", expected, requests)
+	}
+
+	srv.Close()
+	if err := ipam.Reserve("ns/svc", "pool", []net.IP{net.ParseIP("10.0.0.0")}); err == nil {
+		t.Fatalf("expected the reservation to fail once the webhook is unreachable")
+	}
+}
+
+func TestIPAMWebhookRetriesReleases(t *testing.T) {
+	var released []string
+	failing := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ipamRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode the request: %s", err)
+		}
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/release" {
+			released = append(released, req.IPs...)
+		}
+	}))
+	defer srv.Close()
+
+	ipam := newIPAMWebhook(log.NewNopLogger(), srv.URL, time.Second)
+	ipam.Release("ns/svc", "pool", []net.IP{net.ParseIP("10.0.0.0"), net.ParseIP("10.0.0.1")})
+	if len(ipam.failedReleases) != 2 {
+		t.Fatalf("expected the two failed releases to be kept, got %v", ipam.failedReleases)
+	}
+
+	// An address reserved again must not be released anymore.
+	_ = ipam.Reserve("ns/other", "pool", []net.IP{net.ParseIP("10.0.0.1")})
+	failing = false
+	ipam.retryReleases()
+	if !reflect.DeepEqual(released, []string{"10.0.0.0"}) {
+		t.Fatalf("expected only 10.0.0.0 to be released, got %v", released)
+	}
+	if len(ipam.failedReleases) != 0 {
+		t.Fatalf("expected no failed release left, got %v", ipam.failedReleases)
+	}
+}
+
+type failingIPAM struct {
+	err error
+}
+
+func (f *failingIPAM) Reserve(svcKey, pool string, ips []net.IP) error {
+	return f.err
+}
+
+func (f *failingIPAM) Release(svcKey, pool string, ips []net.IP) {}
+
+func TestControllerIPAMUnavailable(t *testing.T) {
+	k := &testK8S{t: t}
+	ipam := &failingIPAM{err: errors.New("timeout")}
+	c := &controller{
+		ips:    allocator.New(),
+		client: k,
+	}
+	c.ips.SetIPAM(ipam)
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name:       "default",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:       "LoadBalancer",
+			ClusterIPs: []string{"10.0.0.1"},
+		},
+		Status: statusAssigned([]string{"1.2.3.4"}),
+	}
+
+	// The IP of an existing service is kept while the IPAM can't
+	// confirm it.
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	if k.updateServiceStatus != nil {
+		t.Fatalf("expected the service status not to be updated, got %v", k.updateServiceStatus)
+	}
+	if c.pending["test"] != allocator.ReasonIPAM || c.ipamRetryAt.IsZero() {
+		t.Fatalf("expected the service to be retried, pending %q", c.pending["test"])
+	}
+
+	// The IP is cleared once the IPAM refuses it.
+	ipam.err = allocator.ErrRefused
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	if k.updateServiceStatus == nil || len(k.updateServiceStatus.LoadBalancer.Ingress) != 0 {
+		t.Fatalf("expected the IP to be cleared, got %v", k.updateServiceStatus)
+	}
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "k8s.io/api/core/v1"
+)
+
+// errIPWaited is returned when the IP requested by a service is free, but
+// other services have been waiting for it for longer.
+var errIPWaited = errors.New("other services are waiting for the requested IP")
+
+// ipWaiters holds, for each requested IP in use, the services waiting for
+// it to be released, in the order they requested it.
+type ipWaiters map[string][]string
+
+// position returns the position of the service in the queue of the IP,
+// -1 if it is not waiting for it.
+func (w ipWaiters) position(ip, key string) int {
+	for i, k := range w[ip] {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove takes the service out of the queues of the IPs not in keep.
+func (w ipWaiters) remove(key string, keep []net.IP) {
+	kept := map[string]bool{}
+	for _, ip := range keep {
+		kept[ip.String()] = true
+	}
+	for ip, keys := range w {
+		if kept[ip] {
+			continue
+		}
+		i := w.position(ip, key)
+		if i < 0 {
+			continue
+		}
+		keys = append(keys[:i], keys[i+1:]...)
+		if len(keys) == 0 {
+			delete(w, ip)
+			continue
+		}
+		w[ip] = keys
+	}
+}
+
+// queueForIPs queues the service for the requested IPs other services hold
+// or wait for, telling with an event the IPs it starts waiting for.
+func (c *controller) queueForIPs(l log.Logger, key string, svc *v1.Service, ips []net.IP) {
+	if c.ipWaiters == nil {
+		c.ipWaiters = ipWaiters{}
+	}
+	for _, ip := range ips {
+		ahead := len(c.ipWaiters[ip.String()])
+		if (ahead == 0 && !c.ips.InUse(ip)) || c.ipWaiters.position(ip.String(), key) >= 0 {
+			continue
+		}
+		c.ipWaiters[ip.String()] = append(c.ipWaiters[ip.String()], key)
+		level.Info(l).Log("event", "waitingForIP", "ip", ip, "ahead", ahead, "msg", "requested IP in use, waiting for it to be released")
+		c.client.Infof(svc, "WaitingForIP", "Requested IP %q is in use, waiting for it to be released (%d services waiting ahead)", ip, ahead)
+	}
+}
+
+// checkWaiters returns errIPWaited if one of the requested IPs is waited
+// for by other services queued before the given one.
+func (c *controller) checkWaiters(key string, ips []net.IP) error {
+	for _, ip := range ips {
+		if i := c.ipWaiters.position(ip.String(), key); i != 0 && len(c.ipWaiters[ip.String()]) > 0 {
+			return fmt.Errorf("%w %s", errIPWaited, ip)
+		}
+	}
+	return nil
+}
+
+// waitedIPsReleased tells if any of the IPs released by a service, held
+// before and not anymore, is waited for by other services. The IP may
+// still be shared with other services, the waiters being retried anyway.
+func (c *controller) waitedIPsReleased(before, after []net.IP) bool {
+	held := map[string]bool{}
+	for _, ip := range after {
+		held[ip.String()] = true
+	}
+	for _, ip := range before {
+		if !held[ip.String()] && len(c.ipWaiters[ip.String()]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// heldIPs returns the IPs allocated to the service, with the ones it keeps
+// while being reallocated.
+func (c *controller) heldIPs(key string) []net.IP {
+	res := append([]net.IP{}, c.ips.IPs(key)...)
+	return append(res, c.ips.IPs(reallocationKey(key))...)
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"net"
+	"testing"
+
+	"go.universe.tf/metallb/internal/allocator"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+
+	"github.com/go-kit/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestWaitForRequestedIPs(t *testing.T) {
+	k := &testK8S{t: t}
+	c := &controller{
+		ips:        allocator.New(),
+		client:     k,
+		waitForIPs: true,
+	}
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name:       "default",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+
+	service := func(ip string) *v1.Service {
+		return &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:           "LoadBalancer",
+				ClusterIPs:     []string{"10.0.0.1"},
+				LoadBalancerIP: ip,
+			},
+		}
+	}
+	setBalancer := func(name string, svc *v1.Service) controllers.SyncState {
+		t.Helper()
+		res := c.SetBalancer(l, name, svc, epslices.EpsOrSlices{})
+		if res == controllers.SyncStateError {
+			t.Fatalf("SetBalancer %s failed", name)
+		}
+		return res
+	}
+	assigned := func(name, ip string) {
+		t.Helper()
+		ips := c.ips.IPs(name)
+		if len(ips) != 1 || ips[0].String() != ip {
+			t.Errorf("expected %s to be assigned %s, got %v", name, ip, ips)
+		}
+	}
+	waiting := func(name string) {
+		t.Helper()
+		if c.isServiceAllocated(name) || c.pending[name] != allocator.ReasonIPTaken {
+			t.Errorf("expected %s to be waiting for its IP", name)
+		}
+	}
+
+	setBalancer("s1", service("1.2.3.4"))
+	setBalancer("s2", service("1.2.3.4"))
+	setBalancer("s3", service("1.2.3.4"))
+	assigned("s1", "1.2.3.4")
+	waiting("s2")
+	waiting("s3")
+	if k.loggedWarning {
+		t.Error("expected no warning event for the services waiting for the IP")
+	}
+
+	// Releasing the IP reprocesses the services, the first waiter
+	// getting it whatever the order they come in.
+	if res := setBalancer("s1", service("1.2.3.5")); res != controllers.SyncStateReprocessAll {
+		t.Errorf("expected the services to be reprocessed once the IP is released, got %v", res)
+	}
+	assigned("s1", "1.2.3.5")
+	setBalancer("s3", service("1.2.3.4"))
+	waiting("s3")
+	setBalancer("s2", service("1.2.3.4"))
+	assigned("s2", "1.2.3.4")
+
+	// A service not requesting the IP anymore stops waiting for it.
+	setBalancer("s3", service("1.2.3.6"))
+	assigned("s3", "1.2.3.6")
+	setBalancer("s4", service("1.2.3.4"))
+	waiting("s4")
+	if res := c.SetBalancer(l, "s2", nil, epslices.EpsOrSlices{}); res != controllers.SyncStateReprocessAll {
+		t.Errorf("expected the services to be reprocessed once s2 is deleted, got %v", res)
+	}
+	setBalancer("s4", service("1.2.3.4"))
+	assigned("s4", "1.2.3.4")
+	if len(c.ipWaiters) != 0 {
+		t.Errorf("expected no service left waiting, got %v", c.ipWaiters)
+	}
+}
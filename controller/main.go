@@ -19,23 +19,58 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
+	"time"
 
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/internal/allocator"
 	"go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/k8s"
 	"go.universe.tf/metallb/internal/k8s/controllers"
 	"go.universe.tf/metallb/internal/k8s/epslices"
 	"go.universe.tf/metallb/internal/logging"
+	"go.universe.tf/metallb/internal/metrics"
 	"go.universe.tf/metallb/internal/version"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
-// Service offers methods to mutate a Kubernetes service object.
+var pendingServices = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "metallb",
+	Subsystem: "controller",
+	Name:      "pending_services",
+	Help:      "Number of LoadBalancer services waiting for an IP, per reason of the allocation failure.",
+}, []string{
+	"reason",
+})
+
+var servicesReconciledSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "metallb",
+	Subsystem: "controller",
+	Name:      "services_reconciled_seconds",
+	Help:      "Seconds it took since the controller started to reconcile the existing services and rebuild the allocations, zero until then.",
+})
+
+// pendingReasons are the reasons reported by the pending services gauge.
+var pendingReasons = []string{
+	allocator.ReasonExhausted,
+	allocator.ReasonNoPool,
+	allocator.ReasonFamilyMismatch,
+	allocator.ReasonIPTaken,
+	allocator.ReasonIPAM,
+	reasonThrottled,
+	reasonReleased,
+}
+
+// Service offers methods to mutate a Kubernetes service object, and the
+// status of the address pools.
 type service interface {
 	UpdateStatus(svc *v1.Service) error
+	UpdatePoolStatus(name string, status metallbv1beta1.IPAddressPoolStatus) error
 	Infof(svc *v1.Service, desc, msg string, args ...interface{})
 	Errorf(svc *v1.Service, desc, msg string, args ...interface{})
 }
@@ -44,9 +79,47 @@ type controller struct {
 	client service
 	pools  *config.Pools
 	ips    *allocator.Allocator
+
+	// poolCounters are the pool counters last queued to poolStatus, to
+	// be written into the pools status.
+	poolCounters map[string]allocator.PoolCounters
+	poolStatus   poolStatusWriter
+	// reprocessAll triggers the reconciliation of all the services.
+	reprocessAll func()
+	// pending holds the reason why the services whose allocation
+	// failed are waiting for an IP.
+	pending map[string]string
+	// reallocationGrace is how long a service being reallocated keeps
+	// its previous IPs next to the new ones.
+	reallocationGrace time.Duration
+	// informationalIPs enables allocating the IPs requested with the
+	// allocate-informational-ip annotation to the services not of type
+	// LoadBalancer.
+	informationalIPs bool
+	// zoneAware makes the allocator prefer the pools associated with the
+	// topology zone most of the endpoints of the service run in.
+	zoneAware bool
+	// throttle bounds the rate of the allocations, nil if unbounded.
+	throttle *allocationThrottle
+	// ipamRetryAt is when the services are next reprocessed because
+	// the external IPAM was unavailable.
+	ipamRetryAt time.Time
+	// waitForIPs keeps the services whose requested IPs are in use
+	// queued in ipWaiters, to assign them the IPs in turn once released.
+	waitForIPs bool
+	ipWaiters  ipWaiters
+	// checkExtIPs enables warning about the external IPs of the
+	// services falling within the pools, with the colliding IPs last
+	// warned about per service in externalIPsWarned.
+	checkExtIPs       bool
+	externalIPsWarned map[string]string
+	// started is when the controller started, for reporting how long it
+	// took to reconcile the existing services.
+	started    time.Time
+	reconciled bool
 }
 
-func (c *controller) SetBalancer(l log.Logger, name string, svcRo *v1.Service, _ epslices.EpsOrSlices) controllers.SyncState {
+func (c *controller) SetBalancer(l log.Logger, name string, svcRo *v1.Service, eps epslices.EpsOrSlices) controllers.SyncState {
 	level.Debug(l).Log("event", "startUpdate", "msg", "start of service update")
 	defer level.Debug(l).Log("event", "endUpdate", "msg", "end of service update")
 
@@ -70,8 +143,27 @@ func (c *controller) SetBalancer(l log.Logger, name string, svcRo *v1.Service, _
 	// a reason.
 	svc := svcRo.DeepCopy()
 	successRes := controllers.SyncStateSuccess
+	if c.checkExtIPs {
+		c.checkExternalIPs(l, name, svc)
+	}
 	wasAllocated := c.isServiceAllocated(name)
+	c.clearPending(name)
+	if c.waitForIPs {
+		// The service stops waiting for the IPs it does not request anymore.
+		desired, _, _ := getDesiredLbIPs(svc)
+		if svc.Spec.Type != v1.ServiceTypeLoadBalancer || releaseRequested(svc) {
+			desired = nil
+		}
+		c.ipWaiters.remove(name, desired)
+	}
+	heldBefore := c.heldIPs(name)
+	if c.zoneAware {
+		c.ips.SetServiceZone(name, majorityZone(eps))
+	}
+	c.ips.SetIgnorePoolSelectors(name, svc.Annotations[annotationIgnorePoolSelectors] == "true")
 	c.convergeBalancer(l, name, svc)
+	c.updatePoolStatus()
+	c.updatePendingServices()
 
 	if wasAllocated && !c.isServiceAllocated(name) { // convergeBalancer may deallocate our service and this means it did it.
 		// if the service was deallocated, it may have have left room
@@ -79,6 +171,10 @@ func (c *controller) SetBalancer(l log.Logger, name string, svcRo *v1.Service, _
 		level.Info(l).Log("event", "serviceUpdated", "msg", "removed loadbalancer from service, services will be reprocessed")
 		successRes = controllers.SyncStateReprocessAll
 	}
+	if c.waitForIPs && c.waitedIPsReleased(heldBefore, c.heldIPs(name)) {
+		level.Info(l).Log("event", "serviceUpdated", "msg", "released IPs other services wait for, services will be reprocessed")
+		successRes = controllers.SyncStateReprocessAll
+	}
 	if reflect.DeepEqual(svcRo, svc) {
 		level.Debug(l).Log("event", "noChange", "msg", "service converged, no change")
 		return successRes
@@ -107,8 +203,44 @@ func (c *controller) SetBalancer(l log.Logger, name string, svcRo *v1.Service, _
 }
 
 func (c *controller) deleteBalancer(l log.Logger, name string) {
+	c.clearPending(name)
+	delete(c.externalIPsWarned, name)
+	c.ipWaiters.remove(name, nil)
+	if c.throttle != nil {
+		c.throttle.forget(name)
+	}
+	c.ips.SetServiceZone(name, "")
+	c.ips.SetIgnorePoolSelectors(name, false)
+	c.updatePendingServices()
+	c.ips.Unassign(reallocationKey(name))
 	if c.ips.Unassign(name) {
 		level.Info(l).Log("event", "serviceDeleted", "msg", "service deleted")
+		c.updatePoolStatus()
+	}
+}
+
+// setPending records that the service is waiting for an IP because of
+// the given reason.
+func (c *controller) setPending(name, reason string) {
+	if c.pending == nil {
+		c.pending = map[string]string{}
+	}
+	c.pending[name] = reason
+}
+
+func (c *controller) clearPending(name string) {
+	delete(c.pending, name)
+}
+
+// updatePendingServices sets the pending services gauge from the reasons
+// recorded for the services waiting for an IP.
+func (c *controller) updatePendingServices() {
+	counts := map[string]int{}
+	for _, reason := range c.pending {
+		counts[reason]++
+	}
+	for _, reason := range pendingReasons {
+		pendingServices.WithLabelValues(reason).Set(float64(counts[reason]))
 	}
 }
 
@@ -126,10 +258,15 @@ func (c *controller) SetPools(l log.Logger, pools *config.Pools) controllers.Syn
 		return controllers.SyncStateError
 	}
 	c.pools = pools
+	c.updatePoolStatus()
 	return controllers.SyncStateReprocessAll
 }
 
 func main() {
+	prometheus.MustRegister(pendingServices)
+	prometheus.MustRegister(throttledAllocations)
+	prometheus.MustRegister(servicesReconciledSeconds)
+
 	var (
 		port                = flag.Int("port", 7472, "HTTP listening port for Prometheus metrics")
 		namespace           = flag.String("namespace", os.Getenv("METALLB_NAMESPACE"), "config / memberlist secret namespace")
@@ -142,7 +279,28 @@ func main() {
 		certDir             = flag.String("cert-dir", "/tmp/k8s-webhook-server/serving-certs", "The directory where certs are stored")
 		certServiceName     = flag.String("cert-service-name", "webhook-service", "The service name used to generate the TLS cert's hostname")
 		loadBalancerClass   = flag.String("lb-class", "", "load balancer class. When enabled, metallb will handle only services whose spec.loadBalancerClass matches the given lb class")
+		claimClassless      = flag.Bool("lb-class-claim-classless", false, "when lb-class is set, also handle the services with no spec.loadBalancerClass")
 		webhookMode         = flag.String("webhook-mode", "enabled", "webhook mode: can be enabled, disabled or only webhook if we want the controller to act as webhook endpoint only")
+		allocationStrategy  = flag.String("allocation-strategy", string(allocator.StrategyLowest), "strategy used to pick the IP assigned to a service: lowest assigns the lowest free IP, hash derives it from the service namespace, name and UID")
+		poolDistribution    = flag.String("pool-distribution-strategy", string(allocator.DistributionFill), "strategy used to pick the pool a service is allocated from among the matching pools with the same priority: fill allocates from one pool until it's exhausted, spread from the pool with the lowest share of IPs in use, most-free from the pool with the most free IPs")
+		ipamWebhookURL      = flag.String("ipam-webhook-url", "", "URL of an external IPAM webhook the IPs are reserved with before being assigned, and released with once freed. Empty disables it")
+		ipamWebhookTimeout  = flag.Duration("ipam-webhook-timeout", 5*time.Second, "timeout of the requests to the external IPAM webhook, the allocation failing when it expires")
+		metricsPrefix       = flag.String("metrics-prefix", metrics.DefaultPrefix, "prefix of the names of the exported Prometheus metrics")
+		reallocationGrace   = flag.Duration("reallocation-grace-period", 30*time.Second, "how long a service moved to another pool with the reallocate-from-pool annotation keeps its previous IP next to the new one")
+		zoneAware           = flag.Bool("zone-aware-allocation", false, "prefer the pools whose topology.kubernetes.io/zone label matches the zone most of the endpoints of the service run in, requires the controller to watch the endpoint slices")
+		informationalIPs    = flag.Bool("informational-ips", false, "allocate an IP, recorded in an annotation and never announced, to the services not of type LoadBalancer with the metallb.universe.tf/allocate-informational-ip annotation set to true")
+		alignDualStack      = flag.Bool("align-dual-stack", false, "assign to the dual stack services, when possible, the IPv4 and the IPv6 addresses at the same index among the addresses of their family in the pool")
+		allocationRate      = flag.Float64("allocation-rate", 0, "maximum number of IP allocations per second, the services exceeding it staying pending until their turn comes. 0 disables the limit")
+		allocationBurst     = flag.Int("allocation-burst", 10, "number of IP allocations allowed at once above the allocation rate")
+		waitForIPs          = flag.Bool("wait-for-requested-ips", false, "keep the services whose requested loadBalancerIPs are in use waiting for them, and assign the IPs once released to the waiting services in the order they requested them")
+		configFile          = flag.String("config-file", "", "path of a file holding the MetalLB resources to use instead of the ones in the cluster, reloaded when it changes or on SIGHUP")
+		assignmentMetrics   = flag.Bool("assignment-metrics", false, "export the metallb_allocator_assignment metric, mapping each assigned IP to its pool and service")
+		assignmentMaxSeries = flag.Int("assignment-metrics-max-series", 5000, "maximum number of assigned IPs exported by the assignment metric, the others being counted in metallb_allocator_assignments_not_exported")
+		checkExternalIPs    = flag.Bool("check-external-ips", false, "emit a warning event on the services whose spec.externalIPs fall within a pool, as MetalLB may assign them to other services")
+		checkL2Interfaces   = flag.Bool("check-l2-interfaces", false, "flag the L2Advertisements none of the selected nodes has any interface of, from the SpeakerReports of the speakers run with --report-interfaces")
+		checkAnnounced      = flag.Bool("check-announced-services", false, "export whether each LoadBalancer service is announced by any node, from the SpeakerReports of the speakers run with --report-announced-services")
+		annotateNodes       = flag.Bool("annotate-announcing-nodes", false, "list in the metallb.universe.tf/announcing-nodes annotation of the services the nodes the speakers report announcing them from, requires --check-announced-services")
+		kubeVIPConfigMap    = flag.String("import-kube-vip-configmap", "", "namespace/name of a kube-vip cloud provider ConfigMap whose address ranges are imported and kept in sync as IPAddressPools. Empty disables the import")
 	)
 	flag.Parse()
 
@@ -163,8 +321,51 @@ func main() {
 		*namespace = string(bs)
 	}
 
+	if err := metrics.ValidatePrefix(*metricsPrefix); err != nil {
+		level.Error(logger).Log("op", "startup", "error", err, "msg", "invalid metrics prefix")
+		os.Exit(1)
+	}
+
 	c := &controller{
-		ips: allocator.New(),
+		ips:               allocator.New(),
+		reallocationGrace: *reallocationGrace,
+		informationalIPs:  *informationalIPs,
+		zoneAware:         *zoneAware,
+		waitForIPs:        *waitForIPs,
+		checkExtIPs:       *checkExternalIPs,
+	}
+	c.started = time.Now()
+	if err := c.ips.SetStrategy(allocator.Strategy(*allocationStrategy)); err != nil {
+		level.Error(logger).Log("op", "startup", "error", err, "msg", "invalid allocation strategy")
+		os.Exit(1)
+	}
+	if err := c.ips.SetDistribution(allocator.Distribution(*poolDistribution)); err != nil {
+		level.Error(logger).Log("op", "startup", "error", err, "msg", "invalid pool distribution strategy")
+		os.Exit(1)
+	}
+	c.ips.SetAlignDualStack(*alignDualStack)
+	if *assignmentMetrics {
+		if *assignmentMaxSeries < 1 {
+			level.Error(logger).Log("op", "startup", "max-series", *assignmentMaxSeries, "msg", "invalid assignment metrics limit, must be positive")
+			os.Exit(1)
+		}
+		c.ips.SetAssignmentMetrics(*assignmentMaxSeries)
+	}
+	if *annotateNodes && !*checkAnnounced {
+		level.Error(logger).Log("op", "startup", "msg", "--annotate-announcing-nodes requires --check-announced-services")
+		os.Exit(1)
+	}
+	if *allocationRate < 0 || (*allocationRate > 0 && *allocationBurst < 1) {
+		level.Error(logger).Log("op", "startup", "rate", *allocationRate, "burst", *allocationBurst, "msg", "invalid allocation rate limit, the rate must not be negative and the burst must be positive")
+		os.Exit(1)
+	}
+	if *allocationRate > 0 {
+		c.throttle = newAllocationThrottle(*allocationRate, *allocationBurst)
+	}
+	if *ipamWebhookURL != "" {
+		ipam := newIPAMWebhook(logger, *ipamWebhookURL, *ipamWebhookTimeout)
+		c.ips.SetIPAM(ipam)
+		go ipam.run(ipamRetryInterval, nil)
 	}
 
 	bgpType, present := os.LookupEnv("METALLB_BGP_TYPE")
@@ -177,14 +378,18 @@ func main() {
 	cfg := &k8s.Config{
 		ProcessName:     "metallb-controller",
 		MetricsPort:     *port,
+		MetricsPrefix:   *metricsPrefix,
 		EnablePprof:     *enablePprof,
 		Logger:          logger,
 		DisableEpSlices: *disableEpSlices,
+		ReadEndpoints:   *zoneAware,
 
 		Namespace: *namespace,
 		Listener: k8s.Listener{
-			ServiceChanged: c.SetBalancer,
-			PoolChanged:    c.SetPools,
+			ServiceChanged:     c.SetBalancer,
+			PoolChanged:        c.SetPools,
+			ServicePriority:    assignedFirst,
+			ServicesReconciled: c.ServicesReconciled,
 		},
 		ValidateConfig:      validation,
 		EnableWebhook:       true,
@@ -192,6 +397,21 @@ func main() {
 		CertDir:             *certDir,
 		CertServiceName:     *certServiceName,
 		LoadBalancerClass:   *loadBalancerClass,
+		ClaimClassless:      *claimClassless,
+		ConfigFile:          *configFile,
+		// The L2Advertisements of the configuration file are not
+		// checked, the MetalLB CRDs may not be installed.
+		CheckL2Interfaces:       *checkL2Interfaces && *configFile == "",
+		CheckAnnounced:          *checkAnnounced,
+		AnnotateAnnouncingNodes: *annotateNodes,
+	}
+	if *kubeVIPConfigMap != "" {
+		ns, name, ok := strings.Cut(*kubeVIPConfigMap, "/")
+		if !ok || ns == "" || name == "" {
+			level.Error(logger).Log("op", "startup", "configmap", *kubeVIPConfigMap, "msg", "invalid kube-vip configmap, must be namespace/name")
+			os.Exit(1)
+		}
+		cfg.KubeVIPConfigMap = types.NamespacedName{Namespace: ns, Name: name}
 	}
 	switch *webhookMode {
 	case "enabled":
@@ -199,6 +419,9 @@ func main() {
 		cfg.EnableWebhook = false
 	case "onlywebhook":
 		cfg.Listener = k8s.Listener{}
+		cfg.CheckL2Interfaces = false
+		cfg.CheckAnnounced = false
+		cfg.KubeVIPConfigMap = types.NamespacedName{}
 	default:
 		level.Error(logger).Log("op", "startup", "error", "invalid webhookmode value", "value", *webhookMode)
 		os.Exit(1)
@@ -219,6 +442,8 @@ func main() {
 	}
 
 	c.client = client
+	c.reprocessAll = client.ForceSync
+	go c.poolStatus.run(logger, client, poolStatusFlushInterval, nil)
 	if err := client.Run(nil); err != nil {
 		level.Error(logger).Log("op", "startup", "error", err, "msg", "failed to run k8s client")
 		os.Exit(1)
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+// poolStatusFlushInterval is how often the changed status of the pools is
+// written, so that the allocation of many services at once results in a
+// single write per pool.
+const poolStatusFlushInterval = 5 * time.Second
+
+// updatePoolStatus queues the address accounting of the pools whose
+// counters changed since the last call, to be written into their status.
+func (c *controller) updatePoolStatus() {
+	counters := c.ips.Counters()
+	for name, cnt := range counters {
+		if last, ok := c.poolCounters[name]; ok && last == cnt {
+			continue
+		}
+		c.poolStatus.set(name, metallbv1beta1.IPAddressPoolStatus{
+			AssignedIPv4:   cnt.AssignedIPv4,
+			AssignedIPv6:   cnt.AssignedIPv6,
+			AvailableIPv4:  cnt.AvailableIPv4,
+			AvailableIPv6:  cnt.AvailableIPv6,
+			AssignedCount:  cnt.AssignedIPv4 + cnt.AssignedIPv6,
+			AvailableCount: saturatedSum(cnt.AvailableIPv4, cnt.AvailableIPv6),
+		})
+	}
+	c.poolCounters = counters
+}
+
+// saturatedSum returns a+b, capped to math.MaxInt64.
+func saturatedSum(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// poolStatusWriter coalesces the writes of the status of the pools: only
+// the latest status queued for a pool is written, when flushed.
+type poolStatusWriter struct {
+	sync.Mutex
+	pending map[string]metallbv1beta1.IPAddressPoolStatus
+}
+
+// set queues the status of the pool, replacing the one not written yet.
+func (w *poolStatusWriter) set(name string, status metallbv1beta1.IPAddressPoolStatus) {
+	w.Lock()
+	defer w.Unlock()
+	if w.pending == nil {
+		w.pending = map[string]metallbv1beta1.IPAddressPoolStatus{}
+	}
+	w.pending[name] = status
+}
+
+// flush writes the queued status of the pools. The ones failing to be
+// written are queued again, unless a newer status was queued meanwhile.
+func (w *poolStatusWriter) flush(l log.Logger, client service) {
+	w.Lock()
+	pending := w.pending
+	w.pending = nil
+	w.Unlock()
+
+	for name, status := range pending {
+		if err := client.UpdatePoolStatus(name, status); err != nil {
+			level.Error(l).Log("op", "updatePoolStatus", "pool", name, "error", err, "msg", "failed to update pool status")
+			w.Lock()
+			if _, ok := w.pending[name]; !ok {
+				if w.pending == nil {
+					w.pending = map[string]metallbv1beta1.IPAddressPoolStatus{}
+				}
+				w.pending[name] = status
+			}
+			w.Unlock()
+		}
+	}
+}
+
+// run flushes the queued status of the pools every interval, until stop
+// is closed.
+func (w *poolStatusWriter) run(l log.Logger, client service, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.flush(l, client)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "k8s.io/api/core/v1"
+
+	"go.universe.tf/metallb/internal/allocator/k8salloc"
+	"go.universe.tf/metallb/internal/ipfamily"
+)
+
+const (
+	// annotationReallocateFromPool requests to move the service to the
+	// given pool without losing its current IPs until the new ones are
+	// announced.
+	annotationReallocateFromPool = "metallb.universe.tf/reallocate-from-pool"
+	// annotationReallocationStarted is the time the reallocation of the
+	// service started. While it's set, the status of the service holds the
+	// current IPs followed by the ones allocated from the target pool.
+	annotationReallocationStarted = "metallb.universe.tf/reallocation-started"
+)
+
+// reallocationKey is the key the IPs allocated from the target pool are
+// assigned to while the old ones are still in use.
+func reallocationKey(key string) string {
+	return key + "/reallocation"
+}
+
+// convergeReallocation moves the service to the pool requested with the
+// reallocate-from-pool annotation. The IPs of the target pool are first
+// added to the status of the service, next to the current ones, and only
+// after the grace period the current IPs are released and removed from
+// the status. It returns true if the service is being reallocated and
+// no further convergence is needed.
+func (c *controller) convergeReallocation(l log.Logger, key string, svc *v1.Service) bool {
+	target := svc.Annotations[annotationReallocateFromPool]
+	started, inProgress := svc.Annotations[annotationReallocationStarted]
+	lbIPs := []net.IP{}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ip := net.ParseIP(ingress.IP); ip != nil {
+			lbIPs = append(lbIPs, ip)
+		}
+	}
+
+	if !inProgress {
+		if target == "" || len(lbIPs) == 0 {
+			return false
+		}
+		return c.startReallocation(l, key, svc, target, lbIPs)
+	}
+
+	// The family of a service is immutable, so the current IPs and the
+	// new ones are as many.
+	if len(lbIPs) == 0 || len(lbIPs)%2 != 0 {
+		level.Error(l).Log("event", "reallocation", "ips", lbIPs, "msg", "unexpected IPs while reallocating, clearing the reallocation")
+		delete(svc.Annotations, annotationReallocationStarted)
+		c.ips.Unassign(reallocationKey(key))
+		return false
+	}
+	current, next := lbIPs[:len(lbIPs)/2], lbIPs[len(lbIPs)/2:]
+	abort := func() bool {
+		c.ips.Unassign(reallocationKey(key))
+		delete(svc.Annotations, annotationReallocationStarted)
+		setIngress(svc, current)
+		return false
+	}
+
+	if target == "" {
+		level.Info(l).Log("event", "reallocation", "msg", "reallocation annotation removed, keeping the current IPs")
+		c.client.Infof(svc, "ReallocationCancelled", "Keeping IP %q", current)
+		return abort()
+	}
+	err := c.ips.Assign(reallocationKey(key), svc, next, k8salloc.Ports(svc), k8salloc.SharingKey(svc), k8salloc.BackendKey(svc))
+	if err != nil || c.ips.Pool(reallocationKey(key)) != target {
+		level.Error(l).Log("event", "reallocation", "error", err, "ips", next, "msg", "new IPs not allowed anymore, keeping the current IPs")
+		c.client.Errorf(svc, "ReallocationFailed", "Failed to reallocate from pool %q: new IPs %q not allowed anymore", target, next)
+		return abort()
+	}
+	// The current IPs not being valid anymore is not a reason to hold the
+	// new ones back.
+	currentValid := c.ips.Assign(key, svc, current, k8salloc.Ports(svc), k8salloc.SharingKey(svc), k8salloc.BackendKey(svc)) == nil
+
+	startedAt, err := time.Parse(time.RFC3339, started)
+	if err != nil {
+		level.Error(l).Log("event", "reallocation", "error", err, "msg", "invalid reallocation start time, completing the reallocation")
+	}
+	if remaining := c.reallocationGrace - time.Since(startedAt); err == nil && currentValid && remaining > 0 {
+		c.reprocessAfter(remaining)
+		return true
+	}
+
+	c.ips.Unassign(reallocationKey(key))
+	c.ips.Unassign(key)
+	if err := c.ips.Assign(key, svc, next, k8salloc.Ports(svc), k8salloc.SharingKey(svc), k8salloc.BackendKey(svc)); err != nil {
+		level.Error(l).Log("bug", "true", "error", err, "ips", next, "msg", "internal error: failed to assign the reallocated IPs")
+		c.client.Errorf(svc, "InternalError", "failed to assign the reallocated IPs")
+		return abort()
+	}
+	delete(svc.Annotations, annotationReallocationStarted)
+	setIngress(svc, next)
+	svc.Annotations[annotationIPAllocateFromPool] = target
+	level.Info(l).Log("event", "reallocationDone", "ip", next, "pool", target, "msg", "previous IP released")
+	c.client.Infof(svc, "ReallocationDone", "Released IP %q, moved to IP %q of pool %q", current, next, target)
+	return true
+}
+
+// startReallocation allocates the IPs from the target pool, if the service
+// isn't allocated from it already, and adds them to the status next to the
+// current ones.
+func (c *controller) startReallocation(l log.Logger, key string, svc *v1.Service, target string, lbIPs []net.IP) bool {
+	if desiredPool := svc.Annotations[annotationAddressPool]; desiredPool != "" && desiredPool != target {
+		c.client.Errorf(svc, "ReallocationFailed", "%s %q conflicts with %s %q", annotationReallocateFromPool, target, annotationAddressPool, desiredPool)
+		return false
+	}
+	if desiredLbIPs, _, err := getDesiredLbIPs(svc); err != nil || len(desiredLbIPs) > 0 {
+		c.client.Errorf(svc, "ReallocationFailed", "%s can't be used with requested loadbalancer IPs", annotationReallocateFromPool)
+		return false
+	}
+	// Let the regular convergence handle the current IPs not being valid.
+	if err := c.ips.Assign(key, svc, lbIPs, k8salloc.Ports(svc), k8salloc.SharingKey(svc), k8salloc.BackendKey(svc)); err != nil {
+		return false
+	}
+	if c.ips.Pool(key) == target {
+		return false
+	}
+
+	serviceIPFamily, err := ipfamily.ForService(svc)
+	if err != nil {
+		return false
+	}
+	next, err := c.ips.AllocateFromPool(reallocationKey(key), svc, serviceIPFamily, target, k8salloc.Ports(svc), k8salloc.SharingKey(svc), k8salloc.BackendKey(svc))
+	if err != nil {
+		level.Error(l).Log("op", "allocateIPs", "error", err, "pool", target, "msg", "IP reallocation failed, keeping the current IPs")
+		c.client.Errorf(svc, "ReallocationFailed", "Failed to allocate IP from pool %q for %q: %s", target, key, err)
+		return false
+	}
+
+	setIngress(svc, append(lbIPs, next...))
+	svc.Annotations[annotationReallocationStarted] = time.Now().UTC().Format(time.RFC3339)
+	level.Info(l).Log("event", "reallocationStarted", "ip", next, "pool", target, "msg", "IP allocated from the target pool, previous IP kept during the grace period")
+	c.client.Infof(svc, "ReallocationStarted", "Assigned IP %q from pool %q, releasing IP %q in %s", next, target, lbIPs, c.reallocationGrace)
+	c.reprocessAfter(c.reallocationGrace)
+	return true
+}
+
+func setIngress(svc *v1.Service, ips []net.IP) {
+	ingress := []v1.LoadBalancerIngress{}
+	for _, ip := range ips {
+		ingress = append(ingress, v1.LoadBalancerIngress{IP: ip.String()})
+	}
+	svc.Status.LoadBalancer.Ingress = ingress
+}
+
+// reprocessAfter triggers the reconciliation of all the services after the
+// given delay.
+func (c *controller) reprocessAfter(d time.Duration) {
+	if c.reprocessAll == nil {
+		return
+	}
+	time.AfterFunc(d, c.reprocessAll)
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"go.universe.tf/metallb/internal/allocator"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// recordingK8S records the whole services written by the controller,
+// annotations included.
+type recordingK8S struct {
+	testK8S
+	updated *v1.Service
+}
+
+func (s *recordingK8S) UpdateStatus(svc *v1.Service) error {
+	s.updated = svc.DeepCopy()
+	return nil
+}
+
+func ingressIPs(svc *v1.Service) []string {
+	res := []string{}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		res = append(res, ingress.IP)
+	}
+	return res
+}
+
+func TestReallocation(t *testing.T) {
+	k := &recordingK8S{testK8S: testK8S{t: t}}
+	c := &controller{
+		ips:               allocator.New(),
+		client:            k,
+		reallocationGrace: time.Hour,
+	}
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"old": {
+			Name:       "old",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/32")},
+		},
+		"new": {
+			Name: "new",
+			CIDR: []*net.IPNet{ipnet("1.2.4.0/32")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:       "LoadBalancer",
+			ClusterIPs: []string{"10.0.0.1"},
+		},
+	}
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	svc = k.updated
+	if diff := cmp.Diff([]string{"1.2.3.0"}, ingressIPs(svc)); diff != "" {
+		t.Fatalf("unexpected ips (-want +got)\n%s", diff)
+	}
+
+	// Requesting the reallocation adds the new IP next to the current one.
+	svc.Annotations[annotationReallocateFromPool] = "new"
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	svc = k.updated
+	if diff := cmp.Diff([]string{"1.2.3.0", "1.2.4.0"}, ingressIPs(svc)); diff != "" {
+		t.Fatalf("unexpected ips while reallocating (-want +got)\n%s", diff)
+	}
+	if _, ok := svc.Annotations[annotationReallocationStarted]; !ok {
+		t.Fatalf("expected the reallocation start time to be set")
+	}
+
+	// During the grace period the previous IP is kept.
+	k.updated = nil
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	if k.updated != nil {
+		t.Fatalf("expected the service not to change during the grace period, got ips %v", ingressIPs(k.updated))
+	}
+	other := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{annotationAddressPool: "old"},
+		},
+		Spec: v1.ServiceSpec{
+			Type:       "LoadBalancer",
+			ClusterIPs: []string{"10.0.0.2"},
+		},
+	}
+	if c.SetBalancer(l, "other", other, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	if k.updated != nil {
+		t.Fatalf("expected the previous IP to be still in use, got ips %v", ingressIPs(k.updated))
+	}
+
+	// Once the grace period elapsed, the previous IP is released.
+	c.reallocationGrace = 0
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	svc = k.updated
+	if diff := cmp.Diff([]string{"1.2.4.0"}, ingressIPs(svc)); diff != "" {
+		t.Fatalf("unexpected ips after the reallocation (-want +got)\n%s", diff)
+	}
+	if _, ok := svc.Annotations[annotationReallocationStarted]; ok {
+		t.Fatalf("expected the reallocation start time to be cleared")
+	}
+	if svc.Annotations[annotationIPAllocateFromPool] != "new" {
+		t.Fatalf("expected the service to be allocated from the new pool, got %q", svc.Annotations[annotationIPAllocateFromPool])
+	}
+
+	k.updated = nil
+	if c.SetBalancer(l, "other", other, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	if k.updated == nil {
+		t.Fatalf("expected the released IP to be assigned to the other service")
+	}
+	if diff := cmp.Diff([]string{"1.2.3.0"}, ingressIPs(k.updated)); diff != "" {
+		t.Fatalf("unexpected ips of the other service (-want +got)\n%s", diff)
+	}
+
+	// The service is stable once reallocated.
+	k.updated = nil
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	if k.updated != nil {
+		t.Fatalf("expected the reallocated service not to change, got ips %v", ingressIPs(k.updated))
+	}
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ServicesReconciled reports how long it took since the controller started
+// to reprocess all the services with the pools loaded, so that the
+// allocator holds the IPs of all the existing services. The pools are
+// never unset once loaded, so none of the services was skipped while
+// reprocessing them. It doesn't affect the readiness of the controller,
+// which serves the webhooks also while the configuration is invalid.
+func (c *controller) ServicesReconciled(l log.Logger) {
+	if c.reconciled {
+		return
+	}
+	if c.pools == nil || c.pools.ByName == nil {
+		return
+	}
+	elapsed := time.Since(c.started)
+	servicesReconciledSeconds.Set(elapsed.Seconds())
+	c.reconciled = true
+	level.Info(l).Log("event", "servicesReconciled", "elapsed", elapsed, "msg", "existing services reconciled")
+}
+
+// assignedFirst is the priority of the services when all of them are
+// reprocessed, the ones already holding IPs being handled first so that
+// their IPs are not assigned to the new services.
+func assignedFirst(svc *v1.Service) int {
+	if len(svc.Status.LoadBalancer.Ingress) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	ptu "github.com/prometheus/client_golang/prometheus/testutil"
+	"go.universe.tf/metallb/internal/allocator"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestServicesReconciled(t *testing.T) {
+	k := &testK8S{t: t}
+	c := &controller{
+		ips:     allocator.New(),
+		client:  k,
+		started: time.Now().Add(-time.Minute),
+	}
+	l := log.NewNopLogger()
+	servicesReconciledSeconds.Set(0)
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:       "LoadBalancer",
+			ClusterIPs: []string{"10.96.0.1"},
+		},
+	}
+
+	// The services handled before the pools are loaded are not allocated.
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	c.ServicesReconciled(l)
+	if c.reconciled || ptu.ToFloat64(servicesReconciledSeconds) != 0 {
+		t.Fatal("expected the services not to be reconciled before the pools are loaded")
+	}
+
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name:       "default",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	c.ServicesReconciled(l)
+	if !c.reconciled || ptu.ToFloat64(servicesReconciledSeconds) < time.Minute.Seconds() {
+		t.Fatal("expected the services to be reported as reconciled")
+	}
+}
+
+func TestAssignedFirst(t *testing.T) {
+	assigned := &v1.Service{}
+	assigned.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}
+	if assignedFirst(assigned) <= assignedFirst(&v1.Service{}) {
+		t.Fatal("expected the services with IPs to have a higher priority")
+	}
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "k8s.io/api/core/v1"
+)
+
+// annotationReleaseIP requests to release the IPs of the service while
+// keeping it, for another service to take them over. The service stays
+// pending until the annotation is removed.
+const annotationReleaseIP = "metallb.universe.tf/release-ip"
+
+// reasonReleased is the pending reason of the services whose IPs were
+// released with the release-ip annotation.
+const reasonReleased = "released"
+
+// releaseRequested tells if the IPs of the service are to be released.
+func releaseRequested(svc *v1.Service) bool {
+	return svc.Annotations[annotationReleaseIP] == "true"
+}
+
+// convergeRelease releases the IPs of the service if requested with the
+// release-ip annotation. The annotation being part of the service, the
+// IPs are not allocated again when the controller restarts. It returns
+// true if the service is released and no further convergence is needed.
+func (c *controller) convergeRelease(l log.Logger, key string, svc *v1.Service) bool {
+	if !releaseRequested(svc) {
+		return false
+	}
+	if c.isServiceAllocated(key) || len(svc.Status.LoadBalancer.Ingress) > 0 {
+		level.Info(l).Log("event", "clearAssignment", "reason", "releaseRequested", "msg", "releasing the IPs as requested by the annotation")
+		c.client.Infof(svc, "IPReleased", "Released the IPs as requested by the %s annotation", annotationReleaseIP)
+	}
+	c.clearServiceState(key, svc)
+	c.setPending(key, reasonReleased)
+	return true
+}
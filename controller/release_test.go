@@ -0,0 +1,121 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"net"
+	"testing"
+
+	"go.universe.tf/metallb/internal/allocator"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+
+	"github.com/go-kit/log"
+	ptu "github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReleaseIP(t *testing.T) {
+	k := &recordingK8S{testK8S: testK8S{t: t}}
+	newController := func() *controller {
+		return &controller{
+			ips:    allocator.New(),
+			client: k,
+		}
+	}
+	c := newController()
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name:       "default",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/32")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+
+	svc1 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "test1", Annotations: map[string]string{}},
+		Spec: v1.ServiceSpec{
+			Type:       "LoadBalancer",
+			ClusterIPs: []string{"1.2.3.4"},
+		},
+	}
+	svc2 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "test2"},
+		Spec: v1.ServiceSpec{
+			Type:       "LoadBalancer",
+			ClusterIPs: []string{"1.2.3.5"},
+			// The service waits for the IP of the first one.
+			LoadBalancerIP: "1.2.3.0",
+		},
+	}
+	if c.SetBalancer(l, "test1", svc1, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer test1 failed")
+	}
+	svc1 = k.updated
+	if got := ingressIPs(svc1); len(got) != 1 || got[0] != "1.2.3.0" {
+		t.Fatalf("expected test1 to get 1.2.3.0, got %v", got)
+	}
+	if c.SetBalancer(l, "test2", svc2, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer test2 failed")
+	}
+
+	// Releasing the IP keeps the service pending, and makes the other
+	// services reprocessed.
+	svc1.Annotations[annotationReleaseIP] = "true"
+	if c.SetBalancer(l, "test1", svc1, epslices.EpsOrSlices{}) != controllers.SyncStateReprocessAll {
+		t.Fatal("releasing the IP of test1 did not reprocess the services")
+	}
+	svc1 = k.updated
+	if got := ingressIPs(svc1); len(got) != 0 {
+		t.Fatalf("expected test1 to have no IP, got %v", got)
+	}
+	if _, ok := svc1.Annotations[annotationIPAllocateFromPool]; ok {
+		t.Fatal("expected the pool annotation of test1 to be removed")
+	}
+	if got := ptu.ToFloat64(pendingServices.WithLabelValues(reasonReleased)); got != 1 {
+		t.Fatalf("expected 1 released pending service, got %f", got)
+	}
+
+	if c.SetBalancer(l, "test2", svc2, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer test2 failed")
+	}
+	if got := ingressIPs(k.updated); len(got) != 1 || got[0] != "1.2.3.0" {
+		t.Fatalf("expected test2 to take over 1.2.3.0, got %v", got)
+	}
+
+	// After a restart, the released service is not allocated again even
+	// if it is processed first.
+	c = newController()
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+	k.updated = nil
+	if c.SetBalancer(l, "test1", svc1, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer test1 failed")
+	}
+	if k.updated != nil || c.isServiceAllocated("test1") {
+		t.Fatal("expected test1 to stay released after a restart")
+	}
+
+	// Removing the annotation makes the service wait for an IP again.
+	delete(svc1.Annotations, annotationReleaseIP)
+	if c.SetBalancer(l, "test2", svc2, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer test2 failed")
+	}
+	if c.SetBalancer(l, "test1", svc1, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer test1 failed")
+	}
+	if got := ptu.ToFloat64(pendingServices.WithLabelValues(allocator.ReasonExhausted)); got != 1 {
+		t.Fatalf("expected 1 exhausted pending service, got %f", got)
+	}
+	if got := ptu.ToFloat64(pendingServices.WithLabelValues(reasonReleased)); got != 0 {
+		t.Fatalf("expected no released pending service, got %f", got)
+	}
+}
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"reflect"
@@ -25,6 +26,7 @@ import (
 	"github.com/go-kit/log/level"
 	v1 "k8s.io/api/core/v1"
 
+	"go.universe.tf/metallb/internal/allocator"
 	"go.universe.tf/metallb/internal/allocator/k8salloc"
 	"go.universe.tf/metallb/internal/ipfamily"
 )
@@ -33,13 +35,24 @@ const (
 	annotationAddressPool        = "metallb.universe.tf/address-pool"
 	annotationLoadBalancerIPs    = "metallb.universe.tf/loadBalancerIPs"
 	annotationIPAllocateFromPool = "metallb.universe.tf/ip-allocated-from-pool"
+	// annotationIgnorePoolSelectors allows the service to get the IPs it
+	// requests from a pool whose serviceAllocation doesn't select it.
+	annotationIgnorePoolSelectors = "metallb.universe.tf/ignore-pool-selectors"
 )
 
+// errFamilyMismatch is returned when the IPs requested for a service don't
+// match its family.
+var errFamilyMismatch = errors.New("does not match the ipFamily of the service")
+
 func (c *controller) convergeBalancer(l log.Logger, key string, svc *v1.Service) {
 	lbIPs := []net.IP{}
 	var err error
 	// Not a LoadBalancer, early exit. It might have been a balancer
 	// in the past, so we still need to clear LB state.
+	if c.wantsInformationalIP(svc) {
+		c.convergeInformationalIP(l, key, svc)
+		return
+	}
 	if svc.Spec.Type != v1.ServiceTypeLoadBalancer {
 		level.Debug(l).Log("event", "clearAssignment", "reason", "notLoadBalancer", "msg", "not a LoadBalancer")
 		c.clearServiceState(key, svc)
@@ -56,6 +69,14 @@ func (c *controller) convergeBalancer(l log.Logger, key string, svc *v1.Service)
 		return
 	}
 
+	if c.convergeRelease(l, key, svc) {
+		return
+	}
+
+	if c.convergeReallocation(l, key, svc) {
+		return
+	}
+
 	// The assigned LB IP(s) is the end state of convergence. If there's
 	// none or a malformed one, nuke all controlled state so that we
 	// start converging from a clean slate.
@@ -95,6 +116,15 @@ func (c *controller) convergeBalancer(l log.Logger, key string, svc *v1.Service)
 		// This assign is idempotent if the config is consistent,
 		// otherwise it'll fail and tell us why.
 		if err = c.ips.Assign(key, svc, lbIPs, k8salloc.Ports(svc), k8salloc.SharingKey(svc), k8salloc.BackendKey(svc)); err != nil {
+			// The external IPAM being unavailable says nothing about
+			// the IP, e.g. when the existing services are reserved
+			// again on startup. Keep it and retry.
+			if allocator.PendingReason(err) == allocator.ReasonIPAM && !errors.Is(err, allocator.ErrRefused) {
+				level.Error(l).Log("event", "ipamUnavailable", "ip", lbIPs, "error", err, "msg", "failed to confirm the current IP with the external IPAM, retrying")
+				c.setPending(key, allocator.ReasonIPAM)
+				c.retryIPAMAfter(ipamRetryInterval)
+				return
+			}
 			level.Info(l).Log("event", "clearAssignment", "error", err, "msg", "current IP not allowed by config, clearing")
 			c.clearServiceState(key, svc)
 			lbIPs = []net.IP{}
@@ -127,10 +157,36 @@ func (c *controller) convergeBalancer(l log.Logger, key string, svc *v1.Service)
 
 	// If lbIP is still nil at this point, try to allocate.
 	if len(lbIPs) == 0 {
+		if c.throttle != nil {
+			if d := c.throttle.wait(key); d > 0 {
+				level.Info(l).Log("event", "allocationThrottled", "retryIn", d, "msg", "allocation rate limit reached, deferring the allocation")
+				c.setPending(key, reasonThrottled)
+				c.retryThrottledAfter(d)
+				return
+			}
+		}
 		lbIPs, err = c.allocateIPs(key, svc)
+		if err != nil && c.waitForIPs && pendingReason(err) == allocator.ReasonIPTaken {
+			desired, _, _ := getDesiredLbIPs(svc)
+			c.queueForIPs(l, key, svc, desired)
+			c.setPending(key, allocator.ReasonIPTaken)
+			// Reprocessed once another service releases one of the IPs.
+			return
+		}
 		if err != nil {
 			level.Error(l).Log("op", "allocateIPs", "error", err, "msg", "IP allocation failed")
-			c.client.Errorf(svc, "AllocationFailed", "Failed to allocate IP for %q: %s", key, err)
+			reason := "AllocationFailed"
+			switch {
+			case errors.Is(err, errFamilyMismatch):
+				reason = "IPFamilyMismatch"
+			case errors.Is(err, allocator.ErrPoolNotAllowed):
+				reason = "PoolNotAllowed"
+				err = fmt.Errorf("%w, set the %s annotation to \"true\" to use it anyway", err, annotationIgnorePoolSelectors)
+			}
+			c.client.Errorf(svc, reason, "Failed to allocate IP for %q: %s", key, err)
+			if reason := pendingReason(err); reason != "" {
+				c.setPending(key, reason)
+			}
 			// The outer controller loop will retry converging this
 			// service when another service gets deleted, so there's
 			// nothing to do here but wait to get called again later.
@@ -172,7 +228,10 @@ func (c *controller) convergeBalancer(l log.Logger, key string, svc *v1.Service)
 // this controller.
 func (c *controller) clearServiceState(key string, svc *v1.Service) {
 	c.ips.Unassign(key)
+	c.ips.Unassign(reallocationKey(key))
 	delete(svc.Annotations, annotationIPAllocateFromPool)
+	delete(svc.Annotations, annotationReallocationStarted)
+	delete(svc.Annotations, annotationInformationalIP)
 	svc.Status.LoadBalancer = v1.LoadBalancerStatus{}
 }
 
@@ -195,11 +254,17 @@ func (c *controller) allocateIPs(key string, svc *v1.Service) ([]net.IP, error)
 	// If the user asked for a specific IPs, try that.
 	if len(desiredLbIPs) > 0 {
 		if serviceIPFamily != desiredLbIPFamily {
-			return nil, fmt.Errorf("requested loadBalancer IP(s) %q does not match the ipFamily of the service", desiredLbIPs)
+			return nil, familyMismatchError(desiredLbIPs, desiredLbIPFamily, serviceIPFamily)
+		}
+		if c.waitForIPs {
+			if err := c.checkWaiters(key, desiredLbIPs); err != nil {
+				return nil, err
+			}
 		}
 		if err := c.ips.Assign(key, svc, desiredLbIPs, k8salloc.Ports(svc), k8salloc.SharingKey(svc), k8salloc.BackendKey(svc)); err != nil {
 			return nil, err
 		}
+		c.ipWaiters.remove(key, nil)
 		return desiredLbIPs, nil
 	}
 	// Otherwise, did the user ask for a specific pool?
@@ -216,6 +281,33 @@ func (c *controller) allocateIPs(key string, svc *v1.Service) ([]net.IP, error)
 	return c.ips.Allocate(key, svc, serviceIPFamily, k8salloc.Ports(svc), k8salloc.SharingKey(svc), k8salloc.BackendKey(svc))
 }
 
+// familyMismatchError returns the error telling the requested IPs don't
+// match the family of the service, and what the service expects instead.
+func familyMismatchError(desired []net.IP, desiredFamily, serviceFamily ipfamily.Family) error {
+	var hint string
+	switch serviceFamily {
+	case ipfamily.DualStack:
+		hint = "a dual stack service must request one IPv4 and one IPv6 address"
+	case ipfamily.IPv4:
+		hint = "a single stack IPv4 service must request one IPv4 address, or have its ipFamilies changed"
+	case ipfamily.IPv6:
+		hint = "a single stack IPv6 service must request one IPv6 address, or have its ipFamilies changed"
+	}
+	return fmt.Errorf("requested loadBalancer IP(s) %q (%s) %w (%s): %s", desired, desiredFamily, errFamilyMismatch, serviceFamily, hint)
+}
+
+// pendingReason returns the reason why the allocation failed with err, or
+// an empty string if it's not one of the reasons tracked.
+func pendingReason(err error) string {
+	if errors.Is(err, errFamilyMismatch) {
+		return allocator.ReasonFamilyMismatch
+	}
+	if errors.Is(err, errIPWaited) {
+		return allocator.ReasonIPTaken
+	}
+	return allocator.PendingReason(err)
+}
+
 func (c *controller) isServiceAllocated(key string) bool {
 	return c.ips.Pool(key) != ""
 }
@@ -241,7 +333,7 @@ func getDesiredLbIPs(svc *v1.Service) ([]net.IP, ipfamily.Family, error) {
 		}
 		desiredLbIPFamily, err := ipfamily.ForAddressesIPs(desiredLbIPs)
 		if err != nil {
-			return nil, "", err
+			return nil, "", fmt.Errorf("invalid %s %q: at most one IPv4 and one IPv6 address can be requested", annotationLoadBalancerIPs, desiredLbIPsStr)
 		}
 		return desiredLbIPs, desiredLbIPFamily, nil
 	}
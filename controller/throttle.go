@@ -0,0 +1,80 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// reasonThrottled is the pending reason of the services whose allocation
+// waits for the allocation rate limit.
+const reasonThrottled = "throttled"
+
+var throttledAllocations = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "metallb",
+	Subsystem: "controller",
+	Name:      "throttled_allocations_total",
+	Help:      "Number of allocations of IPs to services deferred by the allocation rate limit.",
+})
+
+// allocationThrottle bounds the rate of the allocations of IPs with a
+// token bucket. The services exceeding it reserve a token in the order
+// they come, and are allocated in turn once their reservation is due, so
+// that none of them starves.
+type allocationThrottle struct {
+	limiter      *rate.Limiter
+	reservations map[string]*rate.Reservation
+	// retryAt is when the services are next reprocessed for the
+	// reservations getting due.
+	retryAt time.Time
+}
+
+func newAllocationThrottle(r float64, burst int) *allocationThrottle {
+	return &allocationThrottle{
+		limiter:      rate.NewLimiter(rate.Limit(r), burst),
+		reservations: map[string]*rate.Reservation{},
+	}
+}
+
+// wait returns how long the allocation of the service must be deferred,
+// zero if it can proceed now.
+func (t *allocationThrottle) wait(key string) time.Duration {
+	r, ok := t.reservations[key]
+	if !ok {
+		r = t.limiter.Reserve()
+		if r.Delay() == 0 {
+			return 0
+		}
+		t.reservations[key] = r
+		throttledAllocations.Inc()
+	}
+	if d := r.Delay(); d > 0 {
+		return d
+	}
+	delete(t.reservations, key)
+	return 0
+}
+
+// forget cancels the reservation of a service not waiting for an
+// allocation anymore, giving its token back.
+func (t *allocationThrottle) forget(key string) {
+	if r, ok := t.reservations[key]; ok {
+		r.Cancel()
+		delete(t.reservations, key)
+	}
+}
+
+// retryThrottledAfter reprocesses the services once the first of the
+// reservations is due, in the given delay.
+func (c *controller) retryThrottledAfter(d time.Duration) {
+	now := time.Now()
+	at := now.Add(d)
+	if now.Before(c.throttle.retryAt) && !at.Before(c.throttle.retryAt) {
+		return
+	}
+	c.throttle.retryAt = at
+	c.reprocessAfter(d)
+}
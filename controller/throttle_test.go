@@ -0,0 +1,110 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"go.universe.tf/metallb/internal/allocator"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+
+	"github.com/go-kit/log"
+	ptu "github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestAllocationThrottle(t *testing.T) {
+	k := &testK8S{t: t}
+	reprocessed := make(chan struct{}, 10)
+	c := &controller{
+		ips:    allocator.New(),
+		client: k,
+		// One allocation right away, then one every 200ms.
+		throttle:     newAllocationThrottle(5, 1),
+		reprocessAll: func() { reprocessed <- struct{}{} },
+	}
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name:       "default",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+
+	services := map[string]*v1.Service{}
+	for i := 0; i < 3; i++ {
+		services[fmt.Sprintf("s%d", i)] = &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:       "LoadBalancer",
+				ClusterIPs: []string{fmt.Sprintf("10.0.0.%d", i)},
+			},
+		}
+	}
+	setBalancer := func(name string) {
+		t.Helper()
+		if c.SetBalancer(l, name, services[name], epslices.EpsOrSlices{}) == controllers.SyncStateError {
+			t.Fatalf("SetBalancer %s failed", name)
+		}
+	}
+	allocated := func(names ...string) {
+		t.Helper()
+		for _, name := range names {
+			if !c.isServiceAllocated(name) {
+				t.Errorf("expected %s to be allocated", name)
+			}
+		}
+	}
+	throttled := func(names ...string) {
+		t.Helper()
+		for _, name := range names {
+			if c.isServiceAllocated(name) || c.pending[name] != reasonThrottled {
+				t.Errorf("expected %s to be throttled", name)
+			}
+		}
+	}
+
+	before := ptu.ToFloat64(throttledAllocations)
+	for _, name := range []string{"s0", "s1", "s2"} {
+		setBalancer(name)
+	}
+	allocated("s0")
+	throttled("s1", "s2")
+	if got := ptu.ToFloat64(throttledAllocations) - before; got != 2 {
+		t.Errorf("expected 2 throttled allocations, got %v", got)
+	}
+	if got := ptu.ToFloat64(pendingServices.WithLabelValues(reasonThrottled)); got != 2 {
+		t.Errorf("expected 2 throttled pending services, got %v", got)
+	}
+
+	// The services are reprocessed once the first reservation is due,
+	// and each one takes its turn whatever the order they come in.
+	select {
+	case <-reprocessed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the services to be reprocessed")
+	}
+	setBalancer("s2")
+	setBalancer("s1")
+	allocated("s0", "s1")
+	throttled("s2")
+
+	time.Sleep(250 * time.Millisecond)
+	setBalancer("s2")
+	allocated("s0", "s1", "s2")
+	if got := ptu.ToFloat64(throttledAllocations) - before; got != 2 {
+		t.Errorf("expected the retries not to count as throttled allocations, got %v", got)
+	}
+	if got := ptu.ToFloat64(pendingServices.WithLabelValues(reasonThrottled)); got != 0 {
+		t.Errorf("expected no throttled pending services, got %v", got)
+	}
+}
@@ -0,0 +1,33 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"go.universe.tf/metallb/internal/k8s/epslices"
+)
+
+// majorityZone returns the topology zone most of the ready endpoints of
+// the service run in, the lowest one in case of a tie, or an empty string
+// if it's not known. The zone of the endpoints is the one of their node,
+// and it's only reported by the endpoint slices.
+func majorityZone(eps epslices.EpsOrSlices) string {
+	if eps.Type != epslices.Slices {
+		return ""
+	}
+	count := map[string]int{}
+	for _, slice := range eps.SlicesVal {
+		for _, ep := range slice.Endpoints {
+			if !epslices.IsConditionReady(ep.Conditions) || ep.Zone == nil || *ep.Zone == "" {
+				continue
+			}
+			count[*ep.Zone]++
+		}
+	}
+	res := ""
+	for zone, n := range count {
+		if n > count[res] || (n == count[res] && zone < res) {
+			res = zone
+		}
+	}
+	return res
+}
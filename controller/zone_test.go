@@ -0,0 +1,67 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	"go.universe.tf/metallb/internal/pointer"
+
+	discovery "k8s.io/api/discovery/v1"
+)
+
+func TestMajorityZone(t *testing.T) {
+	endpoint := func(zone string, ready bool) discovery.Endpoint {
+		return discovery.Endpoint{
+			Zone:       pointer.StrPtr(zone),
+			Conditions: discovery.EndpointConditions{Ready: pointer.BoolPtr(ready)},
+		}
+	}
+	tests := []struct {
+		desc     string
+		eps      epslices.EpsOrSlices
+		expected string
+	}{
+		{
+			desc:     "no endpoints",
+			eps:      epslices.EpsOrSlices{Type: epslices.Slices},
+			expected: "",
+		},
+		{
+			desc: "endpoints without zone",
+			eps: epslices.EpsOrSlices{
+				Type: epslices.Eps,
+			},
+			expected: "",
+		},
+		{
+			desc: "majority",
+			eps: epslices.EpsOrSlices{
+				Type: epslices.Slices,
+				SlicesVal: []discovery.EndpointSlice{
+					{Endpoints: []discovery.Endpoint{endpoint("zone-a", true), endpoint("zone-b", true)}},
+					{Endpoints: []discovery.Endpoint{endpoint("zone-b", true), endpoint("zone-a", false), endpoint("zone-a", false)}},
+				},
+			},
+			expected: "zone-b",
+		},
+		{
+			desc: "tie",
+			eps: epslices.EpsOrSlices{
+				Type: epslices.Slices,
+				SlicesVal: []discovery.EndpointSlice{
+					{Endpoints: []discovery.Endpoint{endpoint("zone-b", true), endpoint("zone-a", true), endpoint("", true)}},
+				},
+			},
+			expected: "zone-a",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := majorityZone(test.eps); got != test.expected {
+				t.Errorf("expected zone %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
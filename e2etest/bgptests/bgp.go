@@ -558,6 +558,103 @@ var _ = ginkgo.Describe("BGP", func() {
 		)
 	})
 
+	ginkgo.Context("BFD with a degraded link", func() {
+		bfdPeersUp := func(nodes []corev1.Node) error {
+			for _, c := range FRRContainers {
+				bfdPeers, err := frr.BFDPeers(c.Executor)
+				if err != nil {
+					return err
+				}
+				err = frr.BFDPeersMatchNodes(nodes, bfdPeers, ipfamily.IPv4, c.RouterConfig.VRF)
+				if err != nil {
+					return err
+				}
+				for ip, peer := range bfdPeers {
+					if peer.Status != "up" {
+						return fmt.Errorf("bfd session with %s on %s is %s", ip, c.Name, peer.Status)
+					}
+				}
+			}
+			return nil
+		}
+
+		ginkgo.DescribeTable("should react to the link degradation", func(degradation linkDegradation, sessionsDown bool) {
+			bfd := metallbv1beta1.BFDProfile{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "fast",
+				},
+				Spec: metallbv1beta1.BFDProfileSpec{
+					ReceiveInterval:  pointer.Uint32Ptr(300),
+					TransmitInterval: pointer.Uint32Ptr(300),
+					DetectMultiplier: pointer.Uint32Ptr(3),
+				},
+			}
+			resources := metallbconfig.ClusterResources{
+				Pools: []metallbv1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "bfd-test",
+						},
+						Spec: metallbv1beta1.IPAddressPoolSpec{
+							Addresses: []string{v4PoolAddresses},
+						},
+					},
+				},
+				Peers:       metallb.WithBFD(metallb.PeersForContainers(FRRContainers, ipfamily.IPv4), bfd.Name),
+				BGPAdvs:     []metallbv1beta1.BGPAdvertisement{emptyBGPAdvertisement},
+				BFDProfiles: []metallbv1beta1.BFDProfile{bfd},
+			}
+			err := ConfigUpdater.Update(resources)
+			framework.ExpectNoError(err)
+
+			for _, c := range FRRContainers {
+				err := frrcontainer.PairWithNodes(cs, c, ipfamily.IPv4, func(container *frrcontainer.FRR) {
+					container.NeighborConfig.BFDEnabled = true
+				})
+				framework.ExpectNoError(err)
+			}
+
+			svc, _ := testservice.CreateWithBackend(cs, f.Namespace.Name, "external-local-lb", testservice.TrafficPolicyCluster)
+			defer testservice.Delete(cs, svc)
+
+			allNodes, err := cs.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+			framework.ExpectNoError(err)
+
+			for _, c := range FRRContainers {
+				validateFRRPeeredWithAllNodes(cs, c, ipfamily.IPv4)
+			}
+			Eventually(func() error {
+				return bfdPeersUp(allNodes.Items)
+			}, 4*time.Minute, 1*time.Second).Should(BeNil())
+
+			withDegradedLinks(FRRContainers, degradation, func() {
+				if sessionsDown {
+					ginkgo.By("checking the bfd sessions go down")
+					Eventually(func() error {
+						return bfdPeersUp(allNodes.Items)
+					}, time.Minute, time.Second).ShouldNot(BeNil())
+					return
+				}
+				ginkgo.By("checking the bfd sessions stay up")
+				Consistently(func() error {
+					return bfdPeersUp(allNodes.Items)
+				}, 30*time.Second, time.Second).Should(BeNil())
+			})
+
+			ginkgo.By("checking the sessions recover once the link is restored")
+			Eventually(func() error {
+				return bfdPeersUp(allNodes.Items)
+			}, 4*time.Minute, 1*time.Second).Should(BeNil())
+			for _, c := range FRRContainers {
+				validateFRRPeeredWithAllNodes(cs, c, ipfamily.IPv4)
+				validateService(cs, svc, allNodes.Items, c)
+			}
+		},
+			ginkgo.Entry("full packet loss", linkDegradation{LossPercent: 100}, true),
+			ginkgo.Entry("latency below the detection time", linkDegradation{Delay: 100 * time.Millisecond}, false),
+		)
+	})
+
 	ginkgo.Context("validate configuration changes", func() {
 		ginkgo.DescribeTable("should work after subsequent configuration updates", func(addressRange string, ipFamily ipfamily.Family) {
 			var services []*corev1.Service
@@ -652,6 +749,41 @@ var _ = ginkgo.Describe("BGP", func() {
 			ginkgo.Entry("IPV4", ipfamily.IPv4),
 			ginkgo.Entry("IPV6", ipfamily.IPv6))
 
+		ginkgo.DescribeTable("configure a peer and validate the session goes through the BGP states", func(ipFamily ipfamily.Family) {
+			var c *frrcontainer.FRR
+			for _, container := range FRRContainers {
+				if container.RouterConfig.VRF == "" {
+					c = container
+					break
+				}
+			}
+			if c == nil {
+				ginkgo.Skip("no FRR container in the default vrf")
+			}
+			allNodes, err := cs.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+			framework.ExpectNoError(err)
+			nodeIPs, err := k8s.NodeIPsForFamily(allNodes.Items[:1], ipFamily, "")
+			framework.ExpectNoError(err)
+
+			err = frrcontainer.PairWithNodes(cs, c, ipFamily)
+			framework.ExpectNoError(err)
+			recorder := recordNeighborStates(c, nodeIPs[0], 100*time.Millisecond)
+			defer recorder.Stop()
+
+			ginkgo.By(fmt.Sprintf("configure FRR peer [%s]", c.Name))
+			resources := metallbconfig.ClusterResources{
+				Peers:   metallb.PeersForContainers([]*frrcontainer.FRR{c}, ipFamily),
+				BGPAdvs: []metallbv1beta1.BGPAdvertisement{emptyBGPAdvertisement},
+			}
+			err = ConfigUpdater.Update(resources)
+			framework.ExpectNoError(err)
+
+			Eventually(recorder, 4*time.Minute, time.Second).Should(haveTransitions(bgpStateIdle, bgpStateEstablished))
+			Expect(recorder.Stop()).To(haveTransitionedWithin(bgpStateIdle, bgpStateEstablished, 2*time.Minute))
+		},
+			ginkgo.Entry("IPV4", ipfamily.IPv4),
+			ginkgo.Entry("IPV6", ipfamily.IPv6))
+
 		ginkgo.DescribeTable("configure bgp advertisement and verify it gets propagated",
 			func(rangeWithAdvertisement string, rangeWithoutAdvertisement string, advertisement metallbv1beta1.BGPAdvertisement, legacy bool,
 				ipFamily ipfamily.Family, communities []metallbv1beta1.Community) {
@@ -535,26 +535,42 @@ func addMultiHopToNodes(cs *clientset.Clientset, targetNetwork, multiHopNetwork
 
 // validateContainersNames validates that the given string is a comma separated list of containers names.
 // The valid names are: ibgp-single-hop / ibgp-multi-hop / ebgp-single-hop / ebgp-multi-hop.
+// ExternalContainerRoles are the names the external containers can be
+// passed with, each one telling the role of the container.
+var ExternalContainerRoles = []string{"ibgp-single-hop", "ibgp-multi-hop", "ebgp-single-hop", "ebgp-multi-hop"}
+
+// ValidateExternalContainers checks the given comma separated list of
+// external containers names, so that a wrong one is reported before any
+// setup is done.
+func ValidateExternalContainers(containerNames string) error {
+	return validateContainersNames(containerNames)
+}
+
 func validateContainersNames(containerNames string) error {
 	if len(containerNames) == 0 {
 		return fmt.Errorf("Failed to validate containers names: got empty string")
 	}
-	validNames := map[string]bool{
-		"ibgp-single-hop": true,
-		"ibgp-multi-hop":  true,
-		"ebgp-single-hop": true,
-		"ebgp-multi-hop":  true,
+	validNames := map[string]bool{}
+	for _, r := range ExternalContainerRoles {
+		validNames[r] = true
 	}
 	names := strings.Split(containerNames, ",")
+	multiHop := false
 	for _, n := range names {
 		v, ok := validNames[n]
 		if !ok {
-			return fmt.Errorf("Failed to validate container name: %s invalid name", n)
+			return fmt.Errorf("Failed to validate container name: %q invalid name, valid names are: %s", n, strings.Join(ExternalContainerRoles, ", "))
 		}
 		if !v {
 			return fmt.Errorf("Failed to validate container name: %s duplicate name", n)
 		}
 		validNames[n] = false
+		if strings.Contains(n, "multi-hop") {
+			multiHop = true
+		}
+	}
+	if multiHop && validNames["ebgp-single-hop"] {
+		return fmt.Errorf("Failed to validate containers names: the multi-hop containers require the ebgp-single-hop one")
 	}
 
 	return nil
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package bgptests
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateContainersNames(t *testing.T) {
+	tests := []struct {
+		desc     string
+		names    string
+		errorMsg string
+	}{
+		{
+			desc:  "single hop",
+			names: "ibgp-single-hop,ebgp-single-hop",
+		},
+		{
+			desc:  "multi hop with the ebgp single hop",
+			names: "ebgp-single-hop,ibgp-multi-hop,ebgp-multi-hop",
+		},
+		{
+			desc:     "empty",
+			names:    "",
+			errorMsg: "got empty string",
+		},
+		{
+			desc:     "typo",
+			names:    "ibgp-single-hop,ebgp-singlehop",
+			errorMsg: "valid names are: ibgp-single-hop, ibgp-multi-hop, ebgp-single-hop, ebgp-multi-hop",
+		},
+		{
+			desc:     "duplicate",
+			names:    "ibgp-single-hop,ibgp-single-hop",
+			errorMsg: "duplicate name",
+		},
+		{
+			desc:     "multi hop without the ebgp single hop",
+			names:    "ibgp-single-hop,ibgp-multi-hop",
+			errorMsg: "require the ebgp-single-hop",
+		},
+	}
+	for _, test := range tests {
+		err := validateContainersNames(test.names)
+		if test.errorMsg == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.desc, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.errorMsg) {
+			t.Errorf("%s: expected error containing %q, got %v", test.desc, test.errorMsg, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package bgptests
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onsi/gomega/types"
+	"go.universe.tf/metallb/e2etest/pkg/executor"
+	"go.universe.tf/metallb/e2etest/pkg/frr"
+)
+
+// The states of the BGP finite state machine, as reported by FRR.
+const (
+	bgpStateIdle        = "Idle"
+	bgpStateConnect     = "Connect"
+	bgpStateActive      = "Active"
+	bgpStateOpenSent    = "OpenSent"
+	bgpStateOpenConfirm = "OpenConfirm"
+	bgpStateEstablished = "Established"
+)
+
+// stateTransition is a state of a neighbor observed while polling, with
+// the time it was first seen at.
+type stateTransition struct {
+	State string
+	At    time.Time
+}
+
+func (t stateTransition) String() string {
+	return fmt.Sprintf("%s@%s", t.State, t.At.Format("15:04:05.000"))
+}
+
+// neighborStateRecorder polls the neighbor of an FRR instance and records
+// the sequence of the states its session goes through. The states lasting
+// less than the polling interval may not be observed.
+type neighborStateRecorder struct {
+	sync.Mutex
+	transitions []stateTransition
+	stop        chan struct{}
+	stopOnce    sync.Once
+	done        chan struct{}
+}
+
+// recordNeighborStates starts recording the states of the session of the
+// given neighbor of the FRR instance, polled every interval, until stopped.
+// A neighbor not known to FRR yet is recorded in the Idle state.
+func recordNeighborStates(exec executor.Executor, neighbor string, interval time.Duration) *neighborStateRecorder {
+	r := &neighborStateRecorder{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			state := bgpStateIdle
+			if n, err := frr.NeighborInfo(neighbor, exec); err == nil {
+				state = n.State
+			}
+			r.observe(state, time.Now())
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return r
+}
+
+func (r *neighborStateRecorder) observe(state string, at time.Time) {
+	r.Lock()
+	defer r.Unlock()
+	if len(r.transitions) > 0 && r.transitions[len(r.transitions)-1].State == state {
+		return
+	}
+	r.transitions = append(r.transitions, stateTransition{State: state, At: at})
+}
+
+// Transitions returns the states observed so far, in order.
+func (r *neighborStateRecorder) Transitions() []stateTransition {
+	r.Lock()
+	defer r.Unlock()
+	return append([]stateTransition{}, r.transitions...)
+}
+
+// Stop stops the polling and returns the states observed. It can be called
+// more than once.
+func (r *neighborStateRecorder) Stop() []stateTransition {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+	return r.Transitions()
+}
+
+// haveTransitions succeeds if the given states were observed in this order,
+// other states being allowed in between as the short lived ones may be
+// missed or repeated by the polling.
+func haveTransitions(states ...string) types.GomegaMatcher {
+	return &transitionsMatcher{states: states}
+}
+
+// haveTransitionedWithin succeeds if the to state was observed at most d
+// after the last observation of the from state preceding it.
+func haveTransitionedWithin(from, to string, d time.Duration) types.GomegaMatcher {
+	return &transitionsMatcher{states: []string{from, to}, within: d}
+}
+
+type transitionsMatcher struct {
+	states []string
+	within time.Duration
+	// The elapsed time between the states, when they were observed.
+	elapsed time.Duration
+}
+
+func (m *transitionsMatcher) Match(actual interface{}) (bool, error) {
+	transitions, err := toTransitions(actual)
+	if err != nil {
+		return false, err
+	}
+	if m.within == 0 {
+		return containsStates(transitions, m.states), nil
+	}
+	from, to := m.states[0], m.states[1]
+	lastFrom := -1
+	for i, t := range transitions {
+		switch {
+		case t.State == from:
+			lastFrom = i
+		case t.State == to && lastFrom >= 0:
+			m.elapsed = t.At.Sub(transitions[lastFrom].At)
+			return m.elapsed <= m.within, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *transitionsMatcher) FailureMessage(actual interface{}) string {
+	if m.within != 0 && m.elapsed != 0 {
+		return fmt.Sprintf("Expected the transition %s within %s, took %s in\n\t%v", strings.Join(m.states, "->"), m.within, m.elapsed, describeTransitions(actual))
+	}
+	return fmt.Sprintf("Expected the transitions %s in\n\t%v", strings.Join(m.states, "->"), describeTransitions(actual))
+}
+
+func (m *transitionsMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected not to have the transitions %s in\n\t%v", strings.Join(m.states, "->"), describeTransitions(actual))
+}
+
+func describeTransitions(actual interface{}) interface{} {
+	if transitions, err := toTransitions(actual); err == nil {
+		return transitions
+	}
+	return actual
+}
+
+func toTransitions(actual interface{}) ([]stateTransition, error) {
+	switch a := actual.(type) {
+	case []stateTransition:
+		return a, nil
+	case *neighborStateRecorder:
+		return a.Transitions(), nil
+	}
+	return nil, fmt.Errorf("expected the transitions of a neighbor, got %T", actual)
+}
+
+// containsStates tells if the states appear in the transitions in order.
+func containsStates(transitions []stateTransition, states []string) bool {
+	i := 0
+	for _, t := range transitions {
+		if i == len(states) {
+			break
+		}
+		if t.State == states[i] {
+			i++
+		}
+	}
+	return i == len(states)
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package bgptests
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNeighborTransitions(t *testing.T) {
+	start := time.Now()
+	transitions := []stateTransition{}
+	for i, s := range []string{bgpStateIdle, bgpStateConnect, bgpStateActive, bgpStateConnect, bgpStateOpenSent, bgpStateOpenConfirm, bgpStateEstablished} {
+		transitions = append(transitions, stateTransition{State: s, At: start.Add(time.Duration(i) * time.Second)})
+	}
+
+	tests := []struct {
+		desc     string
+		states   []string
+		within   time.Duration
+		expected bool
+	}{
+		{
+			desc:     "full sequence",
+			states:   []string{bgpStateIdle, bgpStateConnect, bgpStateActive, bgpStateConnect, bgpStateOpenSent, bgpStateOpenConfirm, bgpStateEstablished},
+			expected: true,
+		},
+		{
+			desc:     "states in between",
+			states:   []string{bgpStateIdle, bgpStateOpenSent, bgpStateEstablished},
+			expected: true,
+		},
+		{
+			desc:   "wrong order",
+			states: []string{bgpStateEstablished, bgpStateIdle},
+		},
+		{
+			desc:   "state not observed",
+			states: []string{bgpStateIdle, "Clearing"},
+		},
+		{
+			desc:     "transition in time",
+			states:   []string{bgpStateConnect, bgpStateEstablished},
+			within:   3 * time.Second,
+			expected: true,
+		},
+		{
+			desc:   "transition too slow",
+			states: []string{bgpStateIdle, bgpStateEstablished},
+			within: 3 * time.Second,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			matcher := haveTransitions(test.states...)
+			if test.within != 0 {
+				matcher = haveTransitionedWithin(test.states[0], test.states[1], test.within)
+			}
+			got, err := matcher.Match(transitions)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.expected {
+				t.Fatalf("expected %v, got %v: %s", test.expected, got, matcher.FailureMessage(transitions))
+			}
+		})
+	}
+}
+
+func TestNeighborStateRecorder(t *testing.T) {
+	r := &neighborStateRecorder{}
+	now := time.Now()
+	for i, s := range []string{bgpStateIdle, bgpStateIdle, bgpStateConnect, bgpStateEstablished, bgpStateEstablished} {
+		r.observe(s, now.Add(time.Duration(i)*time.Second))
+	}
+	got := r.Transitions()
+	if len(got) != 3 {
+		t.Fatalf("expected the repeated states to be recorded once, got %v", got)
+	}
+	if !got[1].At.Equal(now.Add(2 * time.Second)) {
+		t.Fatalf("expected the transition to be recorded when first observed, got %v", got[1])
+	}
+}
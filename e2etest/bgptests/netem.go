@@ -0,0 +1,70 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package bgptests
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	frrcontainer "go.universe.tf/metallb/e2etest/pkg/frr/container"
+	"go.universe.tf/metallb/e2etest/pkg/netdev"
+	"k8s.io/kubernetes/test/e2e/framework"
+)
+
+// linkDegradation describes the impairment applied with tc netem to the
+// traffic an external container sends towards the cluster.
+type linkDegradation struct {
+	// The percentage of the packets dropped.
+	LossPercent int
+	// The delay added to each packet.
+	Delay time.Duration
+}
+
+func (d linkDegradation) netemArgs() []string {
+	res := []string{}
+	if d.LossPercent > 0 {
+		res = append(res, "loss", strconv.Itoa(d.LossPercent)+"%")
+	}
+	if d.Delay > 0 {
+		res = append(res, "delay", fmt.Sprintf("%dms", d.Delay.Milliseconds()))
+	}
+	return res
+}
+
+// degradeLink applies the given degradation to the interface of the
+// container facing the cluster, returning the function restoring it.
+func degradeLink(c *frrcontainer.FRR, d linkDegradation) (func() error, error) {
+	dev, err := netdev.ForAddress(c, c.Ipv4, c.Ipv6)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"qdisc", "add", "dev", dev, "root", "netem"}, d.netemArgs()...)
+	out, err := c.Exec("tc", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to degrade the link of %s: %w %s", c.Name, err, out)
+	}
+	return func() error {
+		out, err := c.Exec("tc", "qdisc", "del", "dev", dev, "root")
+		if err != nil {
+			return fmt.Errorf("failed to restore the link of %s: %w %s", c.Name, err, out)
+		}
+		return nil
+	}, nil
+}
+
+// withDegradedLinks runs the given assertions while the links of the
+// containers are degraded, restoring them afterwards.
+func withDegradedLinks(containers []*frrcontainer.FRR, d linkDegradation, assertions func()) {
+	for _, c := range containers {
+		ginkgo.By(fmt.Sprintf("degrading the link of %s with %v", c.Name, d.netemArgs()))
+		restore, err := degradeLink(c, d)
+		framework.ExpectNoError(err)
+		defer func(c *frrcontainer.FRR) {
+			ginkgo.By(fmt.Sprintf("restoring the link of %s", c.Name))
+			framework.ExpectNoError(restore())
+		}(c)
+	}
+	assertions()
+}
@@ -52,6 +52,9 @@ var (
 	prometheusNamespace string
 	nodeNics            string
 	localNics           string
+	nodeNicsV6          string
+	localNicsV6         string
+	nicPair             int
 	externalContainers  string
 	runOnHost           bool
 	bgpNativeMode       bool
@@ -77,14 +80,24 @@ func handleFlags() {
 	flag.StringVar(&l2tests.IPV6ServiceRange, "ipv6-service-range", "0", "a range of IPv6 addresses for MetalLB to use when running in layer2 mode")
 	flag.StringVar(&nodeNics, "node-nics", "", "node's interfaces list separated by comma and used when running in interface selector")
 	flag.StringVar(&localNics, "local-nics", "", "local interfaces list separated by comma and used when running in interface selector")
+	flag.StringVar(&nodeNicsV6, "node-nics-v6", "", "node's interfaces list separated by comma and used when running in interface selector with IPv6 services, node-nics is used if not set")
+	flag.StringVar(&localNicsV6, "local-nics-v6", "", "local interfaces list separated by comma and used when running in interface selector with IPv6 services, local-nics is used if not set")
+	flag.IntVar(&nicPair, "nic-pair", -1, "index in the node and local interfaces lists of the single pair of interfaces used by the interface selector tests, all the pairs are used if negative")
 	flag.BoolVar(&useOperator, "use-operator", false, "set this to true to run the tests using operator custom resources")
 	flag.StringVar(&reportPath, "report-path", "/tmp/report", "the path to be used to dump test failure information")
 	flag.StringVar(&prometheusNamespace, "prometheus-namespace", "monitoring", "the namespace prometheus is running in (if running)")
-	flag.StringVar(&externalContainers, "external-containers", "", "a comma separated list of external containers names to use for the test. (valid parameters are: ibgp-single-hop / ibgp-multi-hop / ebgp-single-hop / ebgp-multi-hop)")
+	flag.StringVar(&externalContainers, "external-containers", "", fmt.Sprintf("a comma separated list of external containers names to use for the test. (valid parameters are: %s)", strings.Join(bgptests.ExternalContainerRoles, " / ")))
 	flag.BoolVar(&bgpNativeMode, "bgp-native-mode", false, "says if we are testing against a deployment using bgp native mode")
 
 	flag.Parse()
 
+	if externalContainers != "" {
+		if err := bgptests.ValidateExternalContainers(externalContainers); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -external-containers: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
 	if _, res := os.LookupEnv("RUN_FRR_CONTAINER_ON_HOST_NETWORK"); res {
 		runOnHost = true
 	}
@@ -178,6 +191,11 @@ var _ = ginkgo.BeforeSuite(func() {
 	l2tests.PrometheusNamespace = prometheusNamespace
 	l2tests.NodeNics = strings.Split(nodeNics, ",")
 	l2tests.LocalNics = strings.Split(localNics, ",")
+	if nodeNicsV6 != "" || localNicsV6 != "" {
+		l2tests.NodeNicsV6 = strings.Split(nodeNicsV6, ",")
+		l2tests.LocalNicsV6 = strings.Split(localNicsV6, ",")
+	}
+	l2tests.NicPair = nicPair
 })
 
 var _ = ginkgo.AfterSuite(func() {
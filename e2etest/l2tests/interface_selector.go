@@ -11,6 +11,7 @@ import (
 	"github.com/onsi/gomega"
 	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
 	internalconfig "go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/ipfamily"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,8 +31,38 @@ import (
 var (
 	NodeNics  []string
 	LocalNics []string
+	// The interfaces used when testing the IPv6 services, NodeNics
+	// and LocalNics being used when not set.
+	NodeNicsV6  []string
+	LocalNicsV6 []string
+	// NicPair is the index of the single pair of node's and local
+	// interfaces the interface selector tests use, all of them being
+	// used when negative.
+	NicPair = -1
 )
 
+// nicsForFamily returns the node's and the local interfaces to use when
+// testing the services of the given family.
+func nicsForFamily(family ipfamily.Family) ([]string, []string) {
+	if family == ipfamily.IPv6 && len(NodeNicsV6) > 0 {
+		return NodeNicsV6, LocalNicsV6
+	}
+	return NodeNics, LocalNics
+}
+
+// nicPairs returns the indexes of the pairs of interfaces to test among the
+// given number of pairs.
+func nicPairs(count int) []int {
+	if NicPair >= 0 {
+		return []int{NicPair}
+	}
+	res := make([]int, count)
+	for i := range res {
+		res[i] = i
+	}
+	return res
+}
+
 var _ = ginkgo.Describe("L2-interface selector", func() {
 	var cs clientset.Interface
 
@@ -56,6 +87,12 @@ var _ = ginkgo.Describe("L2-interface selector", func() {
 		if len(NodeNics) != len(LocalNics) {
 			framework.Fail("Local interfaces can't correspond to cluster node's interfaces")
 		}
+		if len(NodeNicsV6) != len(LocalNicsV6) {
+			framework.Fail("Local IPv6 interfaces can't correspond to cluster node's IPv6 interfaces")
+		}
+		if NicPair >= len(NodeNics) || (len(NodeNicsV6) > 0 && NicPair >= len(NodeNicsV6)) {
+			framework.Failf("The interfaces pair %d is out of the interfaces lists", NicPair)
+		}
 		cs = f.ClientSet
 		ginkgo.By("Clearing any previous configuration")
 
@@ -84,7 +121,9 @@ var _ = ginkgo.Describe("L2-interface selector", func() {
 			framework.ExpectNoError(err)
 		})
 
-		ginkgo.It("Validate the LB IP's mac", func() {
+		ginkgo.DescribeTable("Validate the LB IP's mac", func(family ipfamily.Family, tweak service.Tweak) {
+			nodeNics, localNics := nicsForFamily(family)
+			pair := nicPairs(len(nodeNics))[0]
 			resources := internalconfig.ClusterResources{
 				L2Advs: []metallbv1beta1.L2Advertisement{
 					{
@@ -92,7 +131,7 @@ var _ = ginkgo.Describe("L2-interface selector", func() {
 							Name: "with-interfaces",
 						},
 						Spec: metallbv1beta1.L2AdvertisementSpec{
-							Interfaces: []string{NodeNics[0]},
+							Interfaces: []string{nodeNics[pair]},
 						},
 					},
 				},
@@ -100,7 +139,7 @@ var _ = ginkgo.Describe("L2-interface selector", func() {
 			err := ConfigUpdater.Update(resources)
 			framework.ExpectNoError(err)
 
-			svc, _ := service.CreateWithBackend(cs, f.Namespace.Name, "lb-service")
+			svc, _ := service.CreateWithBackend(cs, f.Namespace.Name, "lb-service", tweak)
 			defer func() {
 				err := cs.CoreV1().Services(svc.Namespace).Delete(context.TODO(), svc.Name, metav1.DeleteOptions{})
 				framework.ExpectNoError(err)
@@ -115,7 +154,7 @@ var _ = ginkgo.Describe("L2-interface selector", func() {
 			}, 1*time.Minute, 1*time.Second).Should(gomega.Not(gomega.HaveOccurred()))
 			speakerPod, err := metallb.SpeakerPodInNode(cs, svcNode.Name)
 			framework.ExpectNoError(err)
-			selectorMac, err := mac.GetIfaceMac(NodeNics[0], executor.ForPod(speakerPod.Namespace, speakerPod.Name, "speaker"))
+			selectorMac, err := mac.GetIfaceMac(nodeNics[pair], executor.ForPod(speakerPod.Namespace, speakerPod.Name, "speaker"))
 			framework.ExpectNoError(err)
 
 			ingressIP := e2eservice.GetIngressPoint(&svc.Status.LoadBalancer.Ingress[0])
@@ -123,7 +162,7 @@ var _ = ginkgo.Describe("L2-interface selector", func() {
 			framework.ExpectNoError(err)
 
 			gomega.Eventually(func() string {
-				err := mac.RequestAddressResolutionFromIface(ingressIP, LocalNics[0], executor.Host)
+				err := mac.RequestAddressResolutionFromIface(ingressIP, localNics[pair], executor.Host)
 				if err != nil {
 					return err.Error()
 				}
@@ -137,10 +176,14 @@ var _ = ginkgo.Describe("L2-interface selector", func() {
 				}
 				return ingressMac.String()
 			}, 1*time.Minute, 1*time.Second).Should(gomega.Equal(selectorMac.String()))
-		})
-
-		ginkgo.It("Modify L2 interface", func() {
-			svc, _ := service.CreateWithBackend(cs, f.Namespace.Name, "lb-service")
+		},
+			ginkgo.Entry("IPV4", ipfamily.IPv4, func(_ *corev1.Service) {}),
+			ginkgo.Entry("IPV6", ipfamily.IPv6, service.ForceV6),
+		)
+
+		ginkgo.DescribeTable("Modify L2 interface", func(family ipfamily.Family, tweak service.Tweak) {
+			nodeNics, localNics := nicsForFamily(family)
+			svc, _ := service.CreateWithBackend(cs, f.Namespace.Name, "lb-service", tweak)
 			defer func() {
 				err := cs.CoreV1().Services(svc.Namespace).Delete(context.TODO(), svc.Name, metav1.DeleteOptions{})
 				framework.ExpectNoError(err)
@@ -148,7 +191,7 @@ var _ = ginkgo.Describe("L2-interface selector", func() {
 
 			ingressIP := e2eservice.GetIngressPoint(&svc.Status.LoadBalancer.Ingress[0])
 
-			for i := range NodeNics {
+			for _, i := range nicPairs(len(nodeNics)) {
 				resources := internalconfig.ClusterResources{
 					L2Advs: []metallbv1beta1.L2Advertisement{
 						{
@@ -156,26 +199,29 @@ var _ = ginkgo.Describe("L2-interface selector", func() {
 								Name: "with-interfaces",
 							},
 							Spec: metallbv1beta1.L2AdvertisementSpec{
-								Interfaces: []string{NodeNics[i]},
+								Interfaces: []string{nodeNics[i]},
 							},
 						},
 					},
 				}
 				err := ConfigUpdater.Update(resources)
 				framework.ExpectNoError(err)
-				for j := range LocalNics {
+				for j := range localNics {
 					if j == i {
 						continue
 					}
 					gomega.Eventually(func() error {
-						return mac.RequestAddressResolutionFromIface(ingressIP, LocalNics[j], executor.Host)
+						return mac.RequestAddressResolutionFromIface(ingressIP, localNics[j], executor.Host)
 					}, 10*time.Second, 1*time.Second).Should(gomega.HaveOccurred())
 				}
 				gomega.Eventually(func() error {
-					return mac.RequestAddressResolutionFromIface(ingressIP, LocalNics[i], executor.Host)
+					return mac.RequestAddressResolutionFromIface(ingressIP, localNics[i], executor.Host)
 				}, 10*time.Second, 1*time.Second).Should(gomega.Not(gomega.HaveOccurred()))
 			}
-		})
+		},
+			ginkgo.Entry("IPV4", ipfamily.IPv4, func(_ *corev1.Service) {}),
+			ginkgo.Entry("IPV6", ipfamily.IPv6, service.ForceV6),
+		)
 
 		ginkgo.It("Specify not existing interfaces", func() {
 			resources := internalconfig.ClusterResources{
@@ -3,7 +3,12 @@
 package k8s
 
 import (
+	"context"
 	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,10 +16,29 @@ import (
 	"github.com/openshift-kni/k8sreporter"
 	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
 	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	"go.universe.tf/metallb/e2etest/pkg/executor"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
+// The profiles collected from the MetalLB pods exposing the pprof endpoint,
+// by name, with the path they are served on.
+var profiles = map[string]string{
+	"goroutine": "goroutine?debug=2",
+	"heap":      "heap?debug=1",
+}
+
+// profilesReport holds what is needed to collect the profiles of the MetalLB
+// pods when dumping the report of a failed test.
+var profilesReport struct {
+	cs        clientset.Interface
+	path      string
+	namespace string
+}
+
 func InitReporter(kubeconfig, path, namespace string) *k8sreporter.KubernetesReporter {
 	// When using custom crds, we need to add them to the scheme
 	addToScheme := func(s *runtime.Scheme) error {
@@ -58,10 +82,87 @@ func InitReporter(kubeconfig, path, namespace string) *k8sreporter.KubernetesRep
 	if err != nil {
 		log.Fatalf("Failed to initialize the reporter %s", err)
 	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		log.Fatalf("Failed to initialize the reporter %s", err)
+	}
+	profilesReport.cs, err = clientset.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("Failed to initialize the reporter %s", err)
+	}
+	profilesReport.path = path
+	profilesReport.namespace = namespace
+
 	return reporter
 }
 
 func DumpInfo(reporter *k8sreporter.KubernetesReporter, testName string) {
 	testNameNoSpaces := strings.Replace(ginkgo.CurrentSpecReport().LeafNodeText, " ", "-", -1)
 	reporter.Dump(10*time.Minute, testNameNoSpaces)
+	dumpProfiles(testNameNoSpaces)
+}
+
+// dumpProfiles writes the goroutine and heap profiles of the MetalLB pods
+// into the report of the given test. The profiles are fetched from the
+// monitoring port of each pod, running wget from the controller pod.
+// Pods not running with --enable-pprof are skipped.
+func dumpProfiles(testName string) {
+	if profilesReport.cs == nil {
+		return
+	}
+	pods, err := profilesReport.cs.CoreV1().Pods(profilesReport.namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		log.Printf("Failed to list the pods to collect the profiles from %s", err)
+		return
+	}
+	var controller *corev1.Pod
+	for i, p := range pods.Items {
+		if p.Labels["component"] == "controller" && p.Status.Phase == corev1.PodRunning {
+			controller = &pods.Items[i]
+			break
+		}
+	}
+	if controller == nil {
+		log.Printf("No running controller pod to collect the profiles from")
+		return
+	}
+
+	dir := filepath.Join(profilesReport.path, testName, "pprof")
+	podExecutor := executor.ForPod(controller.Namespace, controller.Name, "controller")
+	for _, p := range pods.Items {
+		port := monitoringPort(p)
+		if port == 0 || p.Status.PodIP == "" {
+			continue
+		}
+		for name, profile := range profiles {
+			url := "http://" + net.JoinHostPort(p.Status.PodIP, strconv.Itoa(port)) + "/debug/pprof/" + profile
+			res, err := podExecutor.Exec("wget", "-qO-", url)
+			if err != nil {
+				log.Printf("Failed to collect the %s profile of %s, is pprof enabled? %s", name, p.Name, err)
+				break
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				log.Printf("Failed to create the profiles directory %s", err)
+				return
+			}
+			file := filepath.Join(dir, p.Name+"-"+name+".txt")
+			if err := os.WriteFile(file, []byte(res), 0644); err != nil {
+				log.Printf("Failed to write the %s profile of %s %s", name, p.Name, err)
+			}
+		}
+	}
+}
+
+// monitoringPort returns the port the metrics and the debug endpoints of
+// the pod are served on, or 0 if there is none.
+func monitoringPort(pod corev1.Pod) int {
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == "monitoring" {
+				return int(p.ContainerPort)
+			}
+		}
+	}
+	return 0
 }
@@ -4,6 +4,8 @@ package collector
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -105,11 +107,27 @@ var (
 type bgp struct {
 	Log    log.Logger
 	frrCli vtysh.Cli
+
+	capabilityMismatch *prometheus.CounterVec
+	mu                 sync.Mutex
+	// opensReceived holds, per peer and vrf, the number of OPEN messages
+	// received when the capabilities were last checked.
+	opensReceived map[string]int
 }
 
 func NewBGP(l log.Logger) *bgp {
 	log := log.With(l, "collector", bgpmetrics.Subsystem)
-	return &bgp{Log: log, frrCli: vtysh.Run}
+	return &bgp{
+		Log:    log,
+		frrCli: vtysh.Run,
+		capabilityMismatch: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: bgpmetrics.Namespace,
+			Subsystem: bgpmetrics.Subsystem,
+			Name:      bgpmetrics.CapabilityMismatch.Name,
+			Help:      bgpmetrics.CapabilityMismatch.Help,
+		}, []string{"peer", "vrf", "capability"}),
+		opensReceived: map[string]int{},
+	}
 }
 
 func (c *bgp) Describe(ch chan<- *prometheus.Desc) {
@@ -125,6 +143,7 @@ func (c *bgp) Describe(ch chan<- *prometheus.Desc) {
 	ch <- routeRefreshSentedDesc
 	ch <- totalSentDesc
 	ch <- totalReceivedDesc
+	c.capabilityMismatch.Describe(ch)
 }
 
 func (c *bgp) Collect(ch chan<- prometheus.Metric) {
@@ -135,6 +154,43 @@ func (c *bgp) Collect(ch chan<- prometheus.Metric) {
 	}
 
 	updateNeighborsMetrics(ch, neighbors)
+	c.updateCapabilityMetrics(neighbors)
+	c.capabilityMismatch.Collect(ch)
+}
+
+// updateCapabilityMetrics logs the capabilities negotiated with each peer
+// and counts the ones advertised by FRR but not by the peer, each time a
+// new OPEN message is received from it.
+func (c *bgp) updateCapabilityMetrics(neighbors map[string][]*bgpfrr.Neighbor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for vrf, nn := range neighbors {
+		for _, n := range nn {
+			peerLabel := fmt.Sprintf("%s:%d", n.Ip.String(), n.Port)
+			key := peerLabel + "/" + vrf
+			if c.opensReceived[key] == n.MsgStats.OpensReceived {
+				continue
+			}
+			c.opensReceived[key] = n.MsgStats.OpensReceived
+
+			negotiated, declined := []string{}, []string{}
+			for name, state := range n.Capabilities {
+				switch state {
+				case bgpfrr.CapabilityNegotiated:
+					negotiated = append(negotiated, name)
+				case bgpfrr.CapabilityAdvertised:
+					declined = append(declined, name)
+				}
+			}
+			sort.Strings(negotiated)
+			sort.Strings(declined)
+			level.Debug(c.Log).Log("peer", peerLabel, "vrf", vrf, "capabilities", fmt.Sprint(negotiated), "msg", "capabilities negotiated with the peer")
+			for _, name := range declined {
+				level.Warn(c.Log).Log("peer", peerLabel, "vrf", vrf, "capability", name, "msg", "peer didn't negotiate a capability advertised by FRR")
+				c.capabilityMismatch.WithLabelValues(peerLabel, vrf, name).Inc()
+			}
+		}
+	}
 }
 
 func updateNeighborsMetrics(ch chan<- prometheus.Metric, neighbors map[string][]*bgpfrr.Neighbor) {
@@ -4,6 +4,7 @@ package collector
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
 	"text/template"
 
@@ -487,3 +488,61 @@ func TestCollect(t *testing.T) {
 
 	}
 }
+
+func TestCapabilityMismatch(t *testing.T) {
+	neighbors := func(opensReceived int) string {
+		return fmt.Sprintf(`{
+  "172.18.0.5":{
+    "remoteAs":64512,
+    "localAs":64513,
+    "bgpState":"Established",
+    "portForeign":179,
+    "neighborCapabilities":{
+      "4byteAs":"advertised",
+      "routeRefresh":"advertisedAndReceivedOldNew",
+      "multiprotocolExtensions":{
+        "ipv4Unicast":{
+          "advertisedAndReceived":true
+        }
+      }
+    },
+    "messageStats":{
+      "opensRecv":%d
+    }
+  }
+}`, opensReceived)
+	}
+
+	collector := NewBGP(log.NewNopLogger())
+	opens := 1
+	collector.frrCli = func(args string) (string, error) {
+		switch args {
+		case "show bgp vrf all json":
+			return vrfVtysh, nil
+		case "show bgp vrf default neighbors json":
+			return neighbors(opens), nil
+		}
+		return "{}", nil
+	}
+
+	mismatches := func() float64 {
+		return testutil.ToFloat64(collector.capabilityMismatch.WithLabelValues("172.18.0.5:179", "default", "4byteAs"))
+	}
+	testutil.CollectAndCount(collector)
+	if got := mismatches(); got != 1 {
+		t.Fatalf("expected 1 mismatch, got %v", got)
+	}
+	// The same session must not be counted again.
+	testutil.CollectAndCount(collector)
+	if got := mismatches(); got != 1 {
+		t.Fatalf("expected 1 mismatch after scraping the same session, got %v", got)
+	}
+	opens = 2
+	testutil.CollectAndCount(collector)
+	if got := mismatches(); got != 2 {
+		t.Fatalf("expected 2 mismatches after a new OPEN, got %v", got)
+	}
+	if got := testutil.ToFloat64(collector.capabilityMismatch.WithLabelValues("172.18.0.5:179", "default", "routeRefresh")); got != 0 {
+		t.Fatalf("expected no mismatch for a negotiated capability, got %v", got)
+	}
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.universe.tf/metallb/frr-tools/metrics/vtysh"
+	bgpmetrics "go.universe.tf/metallb/internal/bgp/metrics"
+)
+
+// afiSummary is the summary of an address family of a VRF, as returned by
+// "show bgp vrf <vrf> summary json".
+type afiSummary struct {
+	RIBCount        int `json:"ribCount"`
+	RIBMemory       int `json:"ribMemory"`
+	PeerMemory      int `json:"peerMemory"`
+	PeerGroupMemory int `json:"peerGroupMemory"`
+}
+
+var ribLabels = []string{"vrf", "afi"}
+
+var (
+	ribEntriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(bgpmetrics.Namespace, bgpmetrics.Subsystem, "frr_rib_entries"),
+		"Number of entries of the FRR BGP RIB",
+		ribLabels,
+		nil,
+	)
+
+	memoryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(bgpmetrics.Namespace, bgpmetrics.Subsystem, "frr_memory_bytes"),
+		"Memory used by FRR for the BGP RIB, the peers and the peer groups",
+		ribLabels,
+		nil,
+	)
+)
+
+// rib exposes the size of the FRR BGP tables. As fetching them is expensive
+// with many routes, they are polled periodically and the last values are
+// reported on scrape, instead of querying FRR on each scrape.
+type rib struct {
+	Log    log.Logger
+	frrCli vtysh.Cli
+
+	mu sync.Mutex
+	// summaries holds the last summaries fetched, per vrf and address family.
+	summaries map[string]map[string]afiSummary
+}
+
+func NewRIB(l log.Logger) *rib {
+	log := log.With(l, "collector", "rib")
+	return &rib{
+		Log:       log,
+		frrCli:    vtysh.Run,
+		summaries: map[string]map[string]afiSummary{},
+	}
+}
+
+// Run polls FRR every interval, forever.
+func (c *rib) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.poll()
+		<-ticker.C
+	}
+}
+
+func (c *rib) poll() {
+	summaries, err := getBGPSummaries(c.frrCli)
+	if err != nil {
+		level.Error(c.Log).Log("error", err, "msg", "failed to fetch the BGP summary from FRR")
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.summaries = summaries
+}
+
+func (c *rib) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ribEntriesDesc
+	ch <- memoryDesc
+}
+
+func (c *rib) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for vrf, afis := range c.summaries {
+		for afi, s := range afis {
+			ch <- prometheus.MustNewConstMetric(ribEntriesDesc, prometheus.GaugeValue, float64(s.RIBCount), vrf, afi)
+			ch <- prometheus.MustNewConstMetric(memoryDesc, prometheus.GaugeValue, float64(s.RIBMemory+s.PeerMemory+s.PeerGroupMemory), vrf, afi)
+		}
+	}
+}
+
+func getBGPSummaries(frrCli vtysh.Cli) (map[string]map[string]afiSummary, error) {
+	vrfs, err := vtysh.VRFs(frrCli)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make(map[string]map[string]afiSummary, 0)
+	for _, vrf := range vrfs {
+		res, err := frrCli(fmt.Sprintf("show bgp vrf %s summary json", vrf))
+		if err != nil {
+			return nil, err
+		}
+
+		afis := map[string]afiSummary{}
+		err = json.Unmarshal([]byte(res), &afis)
+		if err != nil {
+			return nil, err
+		}
+		summaries[vrf] = afis
+	}
+	return summaries, nil
+}
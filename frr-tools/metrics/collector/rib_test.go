@@ -0,0 +1,96 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const summaryVtysh = `{
+  "ipv4Unicast":{
+    "routerId":"172.18.0.4",
+    "as":64512,
+    "vrfId":0,
+    "vrfName":"default",
+    "tableVersion":3,
+    "ribCount":3,
+    "ribMemory":552,
+    "peerCount":1,
+    "peerMemory":724368,
+    "peerGroupCount":0,
+    "peerGroupMemory":0,
+    "peers":{
+      "172.18.0.5":{
+        "remoteAs":64512,
+        "state":"Established",
+        "pfxRcd":0,
+        "pfxSnt":3
+      }
+    },
+    "failedPeers":0,
+    "totalPeers":1,
+    "dynamicPeers":0
+  },
+  "ipv6Unicast":{
+    "routerId":"172.18.0.4",
+    "as":64512,
+    "vrfId":0,
+    "vrfName":"default",
+    "tableVersion":1,
+    "ribCount":1,
+    "ribMemory":184,
+    "peerCount":1,
+    "peerMemory":724368,
+    "peerGroupCount":1,
+    "peerGroupMemory":64,
+    "peers":{},
+    "failedPeers":0,
+    "totalPeers":1,
+    "dynamicPeers":0
+  }
+}`
+
+func TestRIB(t *testing.T) {
+	collector := NewRIB(log.NewNopLogger())
+	cmdOutput := map[string]string{
+		"show bgp vrf all json":             vrfVtysh,
+		"show bgp vrf default summary json": summaryVtysh,
+	}
+	collector.frrCli = func(args string) (string, error) {
+		res, ok := cmdOutput[args]
+		if !ok {
+			return "{}", nil
+		}
+		return res, nil
+	}
+
+	if n := testutil.CollectAndCount(collector); n != 0 {
+		t.Fatalf("expected no metrics before polling FRR, got %d", n)
+	}
+
+	collector.poll()
+	expected := `
+	# HELP metallb_bgp_frr_memory_bytes Memory used by FRR for the BGP RIB, the peers and the peer groups
+	# TYPE metallb_bgp_frr_memory_bytes gauge
+	metallb_bgp_frr_memory_bytes{afi="ipv4Unicast",vrf="default"} 724920
+	metallb_bgp_frr_memory_bytes{afi="ipv6Unicast",vrf="default"} 724616
+	# HELP metallb_bgp_frr_rib_entries Number of entries of the FRR BGP RIB
+	# TYPE metallb_bgp_frr_rib_entries gauge
+	metallb_bgp_frr_rib_entries{afi="ipv4Unicast",vrf="default"} 3
+	metallb_bgp_frr_rib_entries{afi="ipv6Unicast",vrf="default"} 1
+	`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Fatalf("unexpected metrics: %s", err)
+	}
+
+	// A failure fetching the summary keeps the last values.
+	cmdOutput["show bgp vrf default summary json"] = "not json"
+	collector.poll()
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Fatalf("unexpected metrics after a failed poll: %s", err)
+	}
+}
@@ -19,21 +19,27 @@ import (
 	"go.universe.tf/metallb/frr-tools/metrics/liveness"
 	"go.universe.tf/metallb/frr-tools/metrics/vtysh"
 	"go.universe.tf/metallb/internal/logging"
+	"go.universe.tf/metallb/internal/metrics"
 	"go.universe.tf/metallb/internal/version"
 )
 
 var (
-	metricsPort = flag.Uint("metrics-port", 7473, "Port to listen on for web interface.")
-	metricsPath = flag.String("metrics-path", "/metrics", "Path under which to expose metrics.")
+	metricsPort   = flag.Uint("metrics-port", 7473, "Port to listen on for web interface.")
+	metricsPath   = flag.String("metrics-path", "/metrics", "Path under which to expose metrics.")
+	metricsPrefix = flag.String("metrics-prefix", metrics.DefaultPrefix, "Prefix of the names of the exported metrics.")
+	ribInterval   = flag.Duration("rib-poll-interval", 30*time.Second, "Interval of the polling of the size of the FRR BGP RIB.")
 )
 
-func metricsHandler(logger log.Logger) http.Handler {
+func metricsHandler(logger log.Logger, prefix string, ribInterval time.Duration) http.Handler {
 	BGPCollector := collector.NewBGP(logger)
 	BFDCollector := collector.NewBFD(logger)
+	RIBCollector := collector.NewRIB(logger)
+	go RIBCollector.Run(ribInterval)
 
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(BGPCollector)
 	registry.MustRegister(BFDCollector)
+	registry.MustRegister(RIBCollector)
 
 	gatherers := prometheus.Gatherers{
 		prometheus.DefaultGatherer,
@@ -46,7 +52,7 @@ func metricsHandler(logger log.Logger) http.Handler {
 		Registry:      registry,
 	}
 
-	return promhttp.HandlerFor(gatherers, handlerOpts)
+	return promhttp.HandlerFor(metrics.WithPrefix(gatherers, prefix), handlerOpts)
 }
 
 func main() {
@@ -60,8 +66,18 @@ func main() {
 
 	level.Info(logger).Log("version", version.Version(), "commit", version.CommitHash(), "branch", version.Branch(), "goversion", version.GoString(), "msg", "FRR metrics exporter starting "+version.String())
 
+	if err := metrics.ValidatePrefix(*metricsPrefix); err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+
+	if *ribInterval <= 0 {
+		level.Error(logger).Log("error", fmt.Sprintf("invalid rib poll interval %s, must be positive", *ribInterval))
+		os.Exit(1)
+	}
+
 	mux := http.NewServeMux()
-	mux.Handle(*metricsPath, metricsHandler(logger))
+	mux.Handle(*metricsPath, metricsHandler(logger, *metricsPrefix, *ribInterval))
 	mux.Handle("/livez", liveness.Handler(vtysh.Run, logger))
 	level.Info(logger).Log("msg", "Starting exporter", "metricsPath", metricsPath, "port", metricsPort)
 
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package allocator
+
+import (
+	"math/big"
+	"net"
+
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/ipfamily"
+	v1 "k8s.io/api/core/v1"
+)
+
+// SetAlignDualStack sets whether the IPv4 and IPv6 addresses assigned to a
+// dual stack service are picked, when possible, at the same index among the
+// addresses of their family in the pool.
+func (a *Allocator) SetAlignDualStack(align bool) {
+	a.alignDualStack = align
+}
+
+// pickAligned returns an IPv4 and an IPv6 address of the pool at the same
+// index among the addresses of their family, or nil if there is none. The
+// search starts from the IPv4 address the allocation strategy picks, and
+// wraps around to the start of the pool.
+func (a *Allocator) pickAligned(pool *config.Pool, svcKey string, svc *v1.Service, ports []Port, sharingKey, backendKey string, skip map[string]bool) []net.IP {
+	v4, v6 := familyCIDRs(pool, ipfamily.IPv4), familyCIDRs(pool, ipfamily.IPv6)
+	if len(v4) == 0 || len(v6) == 0 {
+		return nil
+	}
+
+	var start *big.Int
+	for _, cidr := range v4 {
+		if ip := a.getIPFromCIDR(cidr, pool, svcKey, svc, ports, sharingKey, backendKey, skip); ip != nil {
+			start = addressIndex(v4, ip)
+			break
+		}
+	}
+	if start == nil {
+		return nil
+	}
+
+	sk := &key{
+		sharing: sharingKey,
+		backend: backendKey,
+	}
+	ip4, ip6 := a.alignedFrom(v4, v6, start, pool, svcKey, ports, sk, skip)
+	if ip4 == nil && start.Sign() > 0 {
+		ip4, ip6 = a.alignedFrom(v4, v6, new(big.Int), pool, svcKey, ports, sk, skip)
+	}
+	if ip4 == nil {
+		return nil
+	}
+	// Same order as when allocating the families independently.
+	if ipfamily.ForCIDR(pool.CIDR[0]) == ipfamily.IPv6 {
+		return []net.IP{ip6, ip4}
+	}
+	return []net.IP{ip4, ip6}
+}
+
+// alignedFrom returns the first pair of assignable addresses of v4 and v6
+// with the same index, not lower than idx. Each family in turn moves the
+// search to the index of its first assignable address, until both agree.
+func (a *Allocator) alignedFrom(v4, v6 []*net.IPNet, idx *big.Int, pool *config.Pool, svcKey string, ports []Port, sk *key, skip map[string]bool) (net.IP, net.IP) {
+	for {
+		ip4, idx4 := a.firstAssignableFrom(v4, idx, pool, svcKey, ports, sk, skip)
+		if ip4 == nil {
+			return nil, nil
+		}
+		ip6, idx6 := a.firstAssignableFrom(v6, idx4, pool, svcKey, ports, sk, skip)
+		if ip6 == nil {
+			return nil, nil
+		}
+		if idx6.Cmp(idx4) == 0 {
+			return ip4, ip6
+		}
+		idx = idx6
+	}
+}
+
+// firstAssignableFrom returns the first address of cidrs assignable to the
+// service, not lower than the one at index idx, along with its index.
+func (a *Allocator) firstAssignableFrom(cidrs []*net.IPNet, idx *big.Int, pool *config.Pool, svcKey string, ports []Port, sk *key, skip map[string]bool) (net.IP, *big.Int) {
+	base := new(big.Int)
+	for _, cidr := range cidrs {
+		r := cidrRange(cidr)
+		first := new(big.Int).SetBytes(r.first[:])
+		size := new(big.Int).SetBytes(r.last[:])
+		size.Sub(size, first).Add(size, big.NewInt(1))
+
+		offset := new(big.Int).Sub(idx, base)
+		if offset.Cmp(size) >= 0 {
+			base.Add(base, size)
+			continue
+		}
+		if offset.Sign() > 0 {
+			new(big.Int).Add(first, offset).FillBytes(r.first[:])
+		}
+		if ip := a.firstAssignable(r, cidr, pool, svcKey, ports, sk, skip); ip != nil {
+			pos := new(big.Int).SetBytes(ip.To16())
+			return ip, pos.Sub(pos, first).Add(pos, base)
+		}
+		base.Add(base, size)
+	}
+	return nil, nil
+}
+
+// familyCIDRs returns the CIDRs of the pool of the given family, in the
+// order of the pool.
+func familyCIDRs(pool *config.Pool, family ipfamily.Family) []*net.IPNet {
+	res := []*net.IPNet{}
+	for _, cidr := range pool.CIDR {
+		if ipfamily.ForCIDR(cidr) == family {
+			res = append(res, cidr)
+		}
+	}
+	return res
+}
+
+// addressIndex returns the index of ip among the addresses of cidrs, taken
+// in order, or nil if none of them contains it.
+func addressIndex(cidrs []*net.IPNet, ip net.IP) *big.Int {
+	base := new(big.Int)
+	for _, cidr := range cidrs {
+		r := cidrRange(cidr)
+		first := new(big.Int).SetBytes(r.first[:])
+		if cidr.Contains(ip) {
+			pos := new(big.Int).SetBytes(ip.To16())
+			return pos.Sub(pos, first).Add(pos, base)
+		}
+		size := new(big.Int).SetBytes(r.last[:])
+		size.Sub(size, first).Add(size, big.NewInt(1))
+		base.Add(base, size)
+	}
+	return nil
+}
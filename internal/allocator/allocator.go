@@ -9,6 +9,7 @@ import (
 	"net"
 	"sort"
 	"strings"
+	"time"
 
 	"go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/ipfamily"
@@ -18,6 +19,14 @@ import (
 	"github.com/mikioh/ipaddr"
 )
 
+// errNoAvailableIPs is returned when no pool could satisfy an
+// allocation request.
+var errNoAvailableIPs = errors.New("no available IPs")
+
+// ErrPoolNotAllowed is returned when the IPs assigned to a service
+// belong to a pool whose service allocation excludes the service.
+var ErrPoolNotAllowed = errors.New("service not allowed to use the pool")
+
 // An Allocator tracks IP address pools and allocates addresses from them.
 type Allocator struct {
 	pools *config.Pools
@@ -27,6 +36,19 @@ type Allocator struct {
 	portsInUse      map[string]map[Port]string // ip.String() -> Port -> svc
 	servicesOnIP    map[string]map[string]bool // ip.String() -> svc -> allocated?
 	poolIPsInUse    map[string]map[string]int  // poolName -> ip.String() -> number of users
+	poolFamilyInUse map[string]*familyCount    // poolName -> number of IPs in use per family
+	poolFamilySize  map[string]*familyCount    // poolName -> number of IPs per family
+	ipsWithKey      *ipSet                     // the ips with an entry in sharingKeyForIP
+
+	strategy       strategy
+	distribution   Distribution
+	alignDualStack bool
+	ipam           IPAM
+	assignments    *assignments
+	serviceZones   map[string]string // svc -> preferred topology zone
+	// The services allowed to be assigned the IPs of the pools whose
+	// service allocation excludes them.
+	ignorePoolSelectors map[string]bool
 }
 
 // Port represents one port in use by a service.
@@ -62,7 +84,27 @@ func New() *Allocator {
 		portsInUse:      map[string]map[Port]string{},
 		servicesOnIP:    map[string]map[string]bool{},
 		poolIPsInUse:    map[string]map[string]int{},
+		poolFamilyInUse: map[string]*familyCount{},
+		poolFamilySize:  map[string]*familyCount{},
+		ipsWithKey:      &ipSet{},
+
+		strategy:     lowestStrategy{},
+		distribution: DistributionFill,
+		serviceZones: map[string]string{},
+
+		ignorePoolSelectors: map[string]bool{},
+	}
+}
+
+// SetStrategy sets the strategy used to pick the address assigned to a
+// service among the free ones.
+func (a *Allocator) SetStrategy(s Strategy) error {
+	st, ok := strategies[s]
+	if !ok {
+		return fmt.Errorf("unknown allocation strategy %q", s)
 	}
+	a.strategy = st
+	return nil
 }
 
 // SetPools updates the set of address pools that the allocator owns.
@@ -95,7 +137,7 @@ func (a *Allocator) SetPools(pools *config.Pools) error {
 			return fmt.Errorf("can't retrieve new pool for assigned IPs: service %q cannot own %q under new config", svc, alloc.ips)
 		}
 		if pool.Name != alloc.pool {
-			a.Unassign(svc)
+			a.unassign(svc)
 			alloc.pool = pool.Name
 			// Use the internal assign, we know for a fact the IP is
 			// still usable.
@@ -103,6 +145,14 @@ func (a *Allocator) SetPools(pools *config.Pools) error {
 		}
 	}
 
+	a.poolFamilySize = map[string]*familyCount{}
+	for n, p := range a.pools.ByName {
+		a.poolFamilySize[n] = &familyCount{
+			ipv4: poolCountForFamily(p, ipfamily.IPv4),
+			ipv6: poolCountForFamily(p, ipfamily.IPv6),
+		}
+	}
+
 	// Refresh or initiate stats
 	for n, p := range a.pools.ByName {
 		stats.poolCapacity.WithLabelValues(n).Set(float64(poolCount(p)))
@@ -112,13 +162,59 @@ func (a *Allocator) SetPools(pools *config.Pools) error {
 	return nil
 }
 
+// PoolCounters holds the address accounting of a pool, per IP family.
+type PoolCounters struct {
+	AssignedIPv4  int64
+	AssignedIPv6  int64
+	AvailableIPv4 int64
+	AvailableIPv6 int64
+}
+
+// Counters returns the address accounting of every known pool, keyed
+// by pool name. The counters are kept up to date as the IPs are assigned
+// and released, so this is cheap regardless of the number of services.
+func (a *Allocator) Counters() map[string]PoolCounters {
+	res := map[string]PoolCounters{}
+	for n := range a.pools.ByName {
+		inUse, size := a.poolFamilyInUse[n], a.poolFamilySize[n]
+		if inUse == nil {
+			inUse = &familyCount{}
+		}
+		if size == nil {
+			size = &familyCount{}
+		}
+		res[n] = PoolCounters{
+			AssignedIPv4:  inUse.ipv4,
+			AssignedIPv6:  inUse.ipv6,
+			AvailableIPv4: size.ipv4 - inUse.ipv4,
+			AvailableIPv6: size.ipv6 - inUse.ipv6,
+		}
+	}
+	return res
+}
+
+// familyCount is a number of IPs per family.
+type familyCount struct {
+	ipv4 int64
+	ipv6 int64
+}
+
+func (c *familyCount) add(ip net.IP, n int64) {
+	if ipfamily.ForAddress(ip) == ipfamily.IPv4 {
+		c.ipv4 += n
+		return
+	}
+	c.ipv6 += n
+}
+
 // assign unconditionally updates internal state to reflect svc's
 // allocation of alloc. Caller must ensure that this call is safe.
 func (a *Allocator) assign(svc string, alloc *alloc) {
-	a.Unassign(svc)
+	a.unassign(svc)
 	a.allocated[svc] = alloc
 	for _, ip := range alloc.ips {
 		a.sharingKeyForIP[ip.String()] = &alloc.key
+		a.ipsWithKey.add(toIPAddr(ip))
 		if a.portsInUse[ip.String()] == nil {
 			a.portsInUse[ip.String()] = map[Port]string{}
 		}
@@ -133,7 +229,15 @@ func (a *Allocator) assign(svc string, alloc *alloc) {
 			a.poolIPsInUse[alloc.pool] = map[string]int{}
 		}
 		a.poolIPsInUse[alloc.pool][ip.String()]++
+		if a.poolIPsInUse[alloc.pool][ip.String()] == 1 {
+			if a.poolFamilyInUse[alloc.pool] == nil {
+				a.poolFamilyInUse[alloc.pool] = &familyCount{}
+			}
+			a.poolFamilyInUse[alloc.pool].add(ip, 1)
+		}
 	}
+	a.exportAssignment(svc)
+	a.updateNotExported()
 	stats.poolCapacity.WithLabelValues(alloc.pool).Set(float64(poolCount(a.pools.ByName[alloc.pool])))
 	stats.poolActive.WithLabelValues(alloc.pool).Set(float64(len(a.poolIPsInUse[alloc.pool])))
 }
@@ -143,14 +247,14 @@ func (a *Allocator) assign(svc string, alloc *alloc) {
 func (a *Allocator) Assign(svcKey string, svc *v1.Service, ips []net.IP, ports []Port, sharingKey, backendKey string) error {
 	pool := poolFor(a.pools.ByName, ips)
 	if pool == nil {
-		return fmt.Errorf("%q is not allowed in config", ips)
+		return &allocationError{ReasonNoPool, fmt.Errorf("%q is not allowed in config", ips)}
 	}
 	sk := &key{
 		sharing: sharingKey,
 		backend: backendKey,
 	}
-	if !a.isPoolCompatibleWithService(pool, svc) {
-		return fmt.Errorf("pool %s not compatible for ip assignment", pool.Name)
+	if reason := poolIncompatibility(pool, svc); reason != "" && !a.ignorePoolSelectors[svcKey] {
+		return &allocationError{ReasonNoPool, fmt.Errorf("%w %s: %s", ErrPoolNotAllowed, pool.Name, reason)}
 	}
 	// Check the dual-stack constraints:
 	// - Two addresses
@@ -159,7 +263,7 @@ func (a *Allocator) Assign(svcKey string, svc *v1.Service, ips []net.IP, ports [
 		return fmt.Errorf("more than two addresses %q", ips)
 	}
 	if len(ips) == 2 && (ipfamily.ForAddress(ips[0]) == ipfamily.ForAddress(ips[1])) {
-		return fmt.Errorf("%q %q has the same family", ips[0], ips[1])
+		return &allocationError{ReasonFamilyMismatch, fmt.Errorf("%q %q has the same family", ips[0], ips[1])}
 	}
 
 	for _, ip := range ips {
@@ -167,15 +271,20 @@ func (a *Allocator) Assign(svcKey string, svc *v1.Service, ips []net.IP, ports [
 		// sharing key, and have non-overlapping ports. If not, the
 		// proposed IP needs to be allowed by configuration.
 		if err := a.checkSharing(svcKey, ip.String(), ports, sk); err != nil {
-			return err
+			return &allocationError{ReasonIPTaken, err}
 		}
 	}
 
+	if err := a.reserve(svcKey, pool.Name, ips); err != nil {
+		return err
+	}
+
 	// Either the IP is entirely unused, or the requested use is
 	// compatible with existing uses. Assign! But unassign first, in
 	// case we're mutating an existing service (see the "already have
 	// an allocation" block above). Unassigning is idempotent, so it's
 	// unconditionally safe to do.
+	previous := a.allocated[svcKey]
 	alloc := &alloc{
 		pool:  pool.Name,
 		ips:   ips,
@@ -184,11 +293,25 @@ func (a *Allocator) Assign(svcKey string, svc *v1.Service, ips []net.IP, ports [
 	}
 	copy(alloc.ports, ports)
 	a.assign(svcKey, alloc)
+	if previous != nil {
+		a.release(svcKey, previous)
+	}
 	return nil
 }
 
 // Unassign frees the IP associated with service, if any.
 func (a *Allocator) Unassign(svc string) bool {
+	al := a.allocated[svc]
+	if !a.unassign(svc) {
+		return false
+	}
+	a.release(svc, al)
+	return true
+}
+
+// unassign frees the IP associated with service, if any, without releasing
+// it with the external IPAM.
+func (a *Allocator) unassign(svc string) bool {
 	if a.allocated[svc] == nil {
 		return false
 	}
@@ -207,20 +330,31 @@ func (a *Allocator) Unassign(svc string) bool {
 		if len(a.portsInUse[ip.String()]) == 0 {
 			delete(a.portsInUse, ip.String())
 			delete(a.sharingKeyForIP, ip.String())
+			a.ipsWithKey.remove(toIPAddr(ip))
 		}
 		a.poolIPsInUse[al.pool][ip.String()]--
 		if a.poolIPsInUse[al.pool][ip.String()] == 0 {
 			// Explicitly delete unused IPs from the pool, so that len()
 			// is an accurate count of IPs in use.
 			delete(a.poolIPsInUse[al.pool], ip.String())
+			a.poolFamilyInUse[al.pool].add(ip, -1)
 		}
 	}
 	stats.poolActive.WithLabelValues(al.pool).Set(float64(len(a.poolIPsInUse[al.pool])))
+	a.unexportAssignment(svc)
+	a.updateNotExported()
 	return true
 }
 
 // AllocateFromPool assigns an available IP from pool to service.
 func (a *Allocator) AllocateFromPool(svcKey string, svc *v1.Service, serviceIPFamily ipfamily.Family, poolName string, ports []Port, sharingKey, backendKey string) ([]net.IP, error) {
+	start := time.Now()
+	ips, err := a.allocateFromPool(svcKey, svc, serviceIPFamily, poolName, ports, sharingKey, backendKey)
+	observeAllocation(start, err)
+	return ips, err
+}
+
+func (a *Allocator) allocateFromPool(svcKey string, svc *v1.Service, serviceIPFamily ipfamily.Family, poolName string, ports []Port, sharingKey, backendKey string) ([]net.IP, error) {
 	if alloc := a.allocated[svcKey]; alloc != nil {
 		// Handle the case where the svc has already been assigned an IP but from the wrong family.
 		// This "should-not-happen" since the "serviceIPFamily" is an immutable field in services.
@@ -239,7 +373,39 @@ func (a *Allocator) AllocateFromPool(svcKey string, svc *v1.Service, serviceIPFa
 
 	pool := a.pools.ByName[poolName]
 	if pool == nil {
-		return nil, fmt.Errorf("unknown pool %q", poolName)
+		return nil, &allocationError{ReasonNoPool, fmt.Errorf("unknown pool %q", poolName)}
+	}
+
+	// The addresses refused by the external IPAM are skipped when picking
+	// the next ones.
+	refused := map[string]bool{}
+	for attempt := 1; ; attempt++ {
+		ips, err := a.pickFromPool(pool, svcKey, svc, serviceIPFamily, ports, sharingKey, backendKey, refused)
+		if err != nil {
+			return nil, err
+		}
+		err = a.Assign(svcKey, svc, ips, ports, sharingKey, backendKey)
+		if err == nil {
+			return ips, nil
+		}
+		if !errors.Is(err, ErrRefused) || attempt == maxIPAMAttempts {
+			return nil, err
+		}
+		for _, ip := range ips {
+			refused[ip.String()] = true
+		}
+	}
+}
+
+// pickFromPool returns the addresses of the pool that can be assigned to
+// the service, one per family of the service, skipping the given ones.
+// When aligning the dual stack services, the families are allocated
+// independently only if no pair of addresses with the same index is free.
+func (a *Allocator) pickFromPool(pool *config.Pool, svcKey string, svc *v1.Service, serviceIPFamily ipfamily.Family, ports []Port, sharingKey, backendKey string, skip map[string]bool) ([]net.IP, error) {
+	if serviceIPFamily == ipfamily.DualStack && a.alignDualStack {
+		if ips := a.pickAligned(pool, svcKey, svc, ports, sharingKey, backendKey, skip); ips != nil {
+			return ips, nil
+		}
 	}
 
 	ips := []net.IP{}
@@ -252,13 +418,15 @@ func (a *Allocator) AllocateFromPool(svcKey string, svc *v1.Service, serviceIPFa
 		ipfamilySel[serviceIPFamily] = true
 	}
 
-	for _, cidr := range pool.CIDR {
+	poolFamilies := map[ipfamily.Family]bool{}
+	for _, cidr := range poolCIDRs(pool) {
 		cidrIPFamily := ipfamily.ForCIDR(cidr)
+		poolFamilies[cidrIPFamily] = true
 		if _, ok := ipfamilySel[cidrIPFamily]; !ok {
 			// Not the right ip-family
 			continue
 		}
-		ip := a.getIPFromCIDR(cidr, pool.AvoidBuggyIPs, svcKey, ports, sharingKey, backendKey)
+		ip := a.getIPFromCIDR(cidr, pool, svcKey, svc, ports, sharingKey, backendKey, skip)
 		if ip != nil {
 			ips = append(ips, ip)
 			delete(ipfamilySel, cidrIPFamily)
@@ -267,10 +435,13 @@ func (a *Allocator) AllocateFromPool(svcKey string, svc *v1.Service, serviceIPFa
 
 	if len(ipfamilySel) > 0 {
 		// Woops, run out of IPs :( Fail.
-		return nil, fmt.Errorf("no available IPs in pool %q for %s IPFamily", poolName, serviceIPFamily)
-	}
-	err := a.Assign(svcKey, svc, ips, ports, sharingKey, backendKey)
-	if err != nil {
+		err := fmt.Errorf("%w in pool %q for %s IPFamily", errNoAvailableIPs, pool.Name, serviceIPFamily)
+		for family := range ipfamilySel {
+			if !poolFamilies[family] {
+				// The pool can't ever serve the service.
+				return nil, &allocationError{ReasonFamilyMismatch, err}
+			}
+		}
 		return nil, err
 	}
 	return ips, nil
@@ -278,28 +449,63 @@ func (a *Allocator) AllocateFromPool(svcKey string, svc *v1.Service, serviceIPFa
 
 // Allocate assigns any available and assignable IP to service.
 func (a *Allocator) Allocate(svcKey string, svc *v1.Service, serviceIPFamily ipfamily.Family, ports []Port, sharingKey, backendKey string) ([]net.IP, error) {
+	start := time.Now()
+	ips, err := a.allocate(svcKey, svc, serviceIPFamily, ports, sharingKey, backendKey)
+	observeAllocation(start, err)
+	return ips, err
+}
+
+func (a *Allocator) allocate(svcKey string, svc *v1.Service, serviceIPFamily ipfamily.Family, ports []Port, sharingKey, backendKey string) ([]net.IP, error) {
 	if alloc := a.allocated[svcKey]; alloc != nil {
 		if err := a.Assign(svcKey, svc, alloc.ips, ports, sharingKey, backendKey); err != nil {
 			return nil, err
 		}
 		return alloc.ips, nil
 	}
+	// The reason of the failure is no-pool when no pool is a candidate,
+	// family-mismatch when none of the candidates has addresses of the
+	// family of the service, ipam when the external IPAM failed for one
+	// of them, exhausted otherwise.
+	reason := ReasonNoPool
+	var ipamErr error
+	tryPool := func(pool *config.Pool) ([]net.IP, error) {
+		ips, err := a.allocateFromPool(svcKey, svc, serviceIPFamily, pool.Name, ports, sharingKey, backendKey)
+		if err != nil {
+			switch {
+			case PendingReason(err) == ReasonIPAM:
+				ipamErr = err
+			case PendingReason(err) != ReasonFamilyMismatch:
+				reason = ReasonExhausted
+			case reason == ReasonNoPool:
+				reason = ReasonFamilyMismatch
+			}
+		}
+		return ips, err
+	}
 	pinnedPools := a.pinnedPoolsForService(svc)
+	a.distribute(pinnedPools, serviceIPFamily)
+	a.preferZone(pinnedPools, svcKey)
 	for _, pool := range pinnedPools {
-		if ips, err := a.AllocateFromPool(svcKey, svc, serviceIPFamily, pool.Name, ports, sharingKey, backendKey); err == nil {
+		if ips, err := tryPool(pool); err == nil {
 			return ips, nil
 		}
 	}
-	for _, pool := range a.pools.ByName {
-		if !pool.AutoAssign || pool.ServiceAllocations != nil {
-			continue
-		}
-		if ips, err := a.AllocateFromPool(svcKey, svc, serviceIPFamily, pool.Name, ports, sharingKey, backendKey); err == nil {
+	autoAssignPools := a.autoAssignPools()
+	a.distribute(autoAssignPools, serviceIPFamily)
+	a.preferZone(autoAssignPools, svcKey)
+	for _, pool := range autoAssignPools {
+		if ips, err := tryPool(pool); err == nil {
 			return ips, nil
 		}
 	}
 
-	return nil, errors.New("no available IPs")
+	if ipamErr != nil {
+		return nil, ipamErr
+	}
+	if reason == ReasonExhausted {
+		return nil, errNoAvailableIPs
+	}
+	return nil, &allocationError{reason, errNoAvailableIPs}
 }
 
 // This method returns sorted ip pools which are allocatable for given service.
@@ -326,25 +532,68 @@ func (a *Allocator) pinnedPoolsForService(svc *v1.Service) []*config.Pool {
 			pools = append(pools, svcPool)
 		}
 	}
+	for _, portsPoolName := range a.pools.ByServicePorts {
+		if portsPool, ok := a.pools.ByName[portsPoolName]; ok {
+			// The pools selecting namespaces or services were considered above.
+			allocations := portsPool.ServiceAllocations
+			if allocations.Namespaces.Len() > 0 || len(allocations.ServiceSelectors) > 0 {
+				continue
+			}
+			if !portsPool.AutoAssign || !a.isPoolCompatibleWithService(portsPool, svc) {
+				continue
+			}
+			pools = append(pools, portsPool)
+		}
+	}
 	sortPools(pools)
 	return pools
 }
 
 func (a *Allocator) isPoolCompatibleWithService(p *config.Pool, svc *v1.Service) bool {
-	if p.ServiceAllocations != nil && p.ServiceAllocations.Namespaces.Len() > 0 &&
-		!p.ServiceAllocations.Namespaces.Has(svc.Namespace) {
-		return false
+	return poolIncompatibility(p, svc) == ""
+}
+
+// poolIncompatibility returns why the service allocation of the pool
+// excludes the service, or an empty string if the service can use it.
+func poolIncompatibility(p *config.Pool, svc *v1.Service) string {
+	if p.ServiceAllocations == nil {
+		return ""
+	}
+	if p.ServiceAllocations.Namespaces.Len() > 0 && !p.ServiceAllocations.Namespaces.Has(svc.Namespace) {
+		return fmt.Sprintf("namespace %q not selected by the pool", svc.Namespace)
+	}
+	if !p.ServiceAllocations.MatchesPorts(servicePorts(svc)) {
+		return fmt.Sprintf("ports %v not in the service ports of the pool", servicePorts(svc))
 	}
-	if p.ServiceAllocations != nil && len(p.ServiceAllocations.ServiceSelectors) > 0 {
+	if len(p.ServiceAllocations.ServiceSelectors) > 0 {
 		svcLabels := labels.Set(svc.Labels)
 		for _, svcSelector := range p.ServiceAllocations.ServiceSelectors {
 			if svcSelector.Matches(svcLabels) {
-				return true
+				return ""
 			}
 		}
-		return false
+		return "labels not matching the service selectors of the pool"
 	}
-	return true
+	return ""
+}
+
+// SetIgnorePoolSelectors allows the IPs assigned to the service to
+// belong to a pool whose service allocation excludes it.
+func (a *Allocator) SetIgnorePoolSelectors(svcKey string, ignore bool) {
+	if !ignore {
+		delete(a.ignorePoolSelectors, svcKey)
+		return
+	}
+	a.ignorePoolSelectors[svcKey] = true
+}
+
+// servicePorts returns the ports exposed by the service.
+func servicePorts(svc *v1.Service) []int {
+	res := make([]int, 0, len(svc.Spec.Ports))
+	for _, p := range svc.Spec.Ports {
+		res = append(res, int(p.Port))
+	}
+	return res
 }
 
 // Pool returns the pool from which service's IP was allocated. If
@@ -359,6 +608,19 @@ func (a *Allocator) Pool(svc string) string {
 	return ""
 }
 
+// IPs returns the IPs allocated to the service, if any.
+func (a *Allocator) IPs(svc string) []net.IP {
+	if alloc := a.allocated[svc]; alloc != nil {
+		return alloc.ips
+	}
+	return nil
+}
+
+// InUse tells if the IP is allocated to any service.
+func (a *Allocator) InUse(ip net.IP) bool {
+	return len(a.servicesOnIP[ip.String()]) > 0
+}
+
 func sortPools(pools []*config.Pool) {
 	// A lower value for pool priority equals a higher priority and sort
 	// pools from higher to low priority. when no priority (0) set on
@@ -395,8 +657,26 @@ func sharingOK(existing, new *key) error {
 
 // poolCount returns the number of addresses in the pool.
 func poolCount(p *config.Pool) int64 {
-	var total int64
+	return cidrsCount(p.CIDR, p)
+}
+
+// poolCountForFamily returns the number of addresses of the given family
+// in the pool.
+func poolCountForFamily(p *config.Pool, family ipfamily.Family) int64 {
+	cidrs := []*net.IPNet{}
 	for _, cidr := range p.CIDR {
+		if ipfamily.ForCIDR(cidr) == family {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrsCount(cidrs, p)
+}
+
+// cidrsCount returns the number of addresses of the cidrs that can be
+// allocated from the pool, leaving out the ones excluded by the pool.
+func cidrsCount(cidrs []*net.IPNet, p *config.Pool) int64 {
+	var total int64
+	for _, cidr := range cidrs {
 		o, b := cidr.Mask.Size()
 		if b-o >= 62 {
 			// An enormous ipv6 range is allocated which will never run out.
@@ -406,25 +686,10 @@ func poolCount(p *config.Pool) int64 {
 		sz := int64(math.Pow(2, float64(b-o)))
 
 		cur := ipaddr.NewCursor([]ipaddr.Prefix{*ipaddr.NewPrefix(cidr)})
-		firstIP := cur.First().IP
-		lastIP := cur.Last().IP
-
-		if p.AvoidBuggyIPs {
-			if o <= 24 {
-				// A pair of buggy IPs occur for each /24 present in the range.
-				buggies := int64(math.Pow(2, float64(24-o))) * 2
-				sz -= buggies
-			} else {
-				// Ranges smaller than /24 contain 1 buggy IP if they
-				// start/end on a /24 boundary, otherwise they contain
-				// none.
-				if ipConfusesBuggyFirmwares(firstIP) {
-					sz--
-				}
-				if ipConfusesBuggyFirmwares(lastIP) {
-					sz--
-				}
-			}
+		sz = stridedCount(p, cur.First().IP, sz)
+
+		if avoided := p.AvoidedLastOctets(); len(avoided) > 0 {
+			sz -= avoidedCount(p, cidr, avoided)
 		}
 		total += sz
 	}
@@ -436,7 +701,10 @@ func poolFor(pools map[string]*config.Pool, ips []net.IP) *config.Pool {
 	for _, p := range pools {
 		cnt := 0
 		for _, ip := range ips {
-			if p.AvoidBuggyIPs && ipConfusesBuggyFirmwares(ip) {
+			if p.AvoidsIP(ip) {
+				continue
+			}
+			if p.StrideDistance(ip) != 0 {
 				continue
 			}
 			for _, cidr := range p.CIDR {
@@ -453,36 +721,152 @@ func poolFor(pools map[string]*config.Pool, ips []net.IP) *config.Pool {
 	return nil
 }
 
-// ipConfusesBuggyFirmwares returns true if ip is an IPv4 address ending in 0 or 255.
-//
-// Such addresses can confuse smurf protection on crappy CPE
-// firmwares, leading to packet drops.
-func ipConfusesBuggyFirmwares(ip net.IP) bool {
-	ip = ip.To4()
-	if ip == nil {
-		return false
-	}
-	return ip[3] == 0 || ip[3] == 255
-}
-
-func (a *Allocator) getIPFromCIDR(cidr *net.IPNet, avoidBuggyIPs bool, svc string, ports []Port, sharingKey, backendKey string) net.IP {
+// getIPFromCIDR returns the first IP of cidr that can be assigned to svc,
+// starting from the address chosen by the allocation strategy, skipping the
+// given ones. The allocation mode of the pool, when set, overrides the
+// strategy. The pools keeping the namespaces contiguous first try the
+// address following the highest one of the namespace of svc.
+func (a *Allocator) getIPFromCIDR(cidr *net.IPNet, pool *config.Pool, svcKey string, svc *v1.Service, ports []Port, sharingKey, backendKey string, skip map[string]bool) net.IP {
 	sk := &key{
 		sharing: sharingKey,
 		backend: backendKey,
 	}
-	c := ipaddr.NewCursor([]ipaddr.Prefix{*ipaddr.NewPrefix(cidr)})
-	for pos := c.First(); pos != nil; pos = c.Next() {
-		if avoidBuggyIPs && ipConfusesBuggyFirmwares(pos.IP) {
-			continue
+	if pool.ContiguousNamespaces {
+		if next, ok := a.namespaceNext(pool.Name, cidr, svcKey); ok {
+			if ip := a.firstAssignable(ipRange{first: next, last: next}, cidr, pool, svcKey, ports, sk, skip); ip != nil {
+				return ip
+			}
 		}
-		if a.checkSharing(svc, pos.IP.String(), ports, sk) != nil {
-			continue
+	}
+	bounds := cidrRange(cidr)
+	switch pool.AllocationMode {
+	case config.AllocationHighest:
+		return a.lastAssignable(bounds, cidr, pool, svcKey, ports, sk, skip)
+	case config.AllocationLowest:
+		return a.firstAssignable(bounds, cidr, pool, svcKey, ports, sk, skip)
+	}
+	start := a.strategy.start(bounds, svcKey, svc)
+	if ip := a.firstAssignable(ipRange{first: start, last: bounds.last}, cidr, pool, svcKey, ports, sk, skip); ip != nil {
+		return ip
+	}
+	if start == bounds.first {
+		return nil
+	}
+	last, _ := start.prev()
+	return a.firstAssignable(ipRange{first: bounds.first, last: last}, cidr, pool, svcKey, ports, sk, skip)
+}
+
+// firstAssignable returns the lowest IP of r that can be assigned to svc.
+//
+// Addresses not used by any service are always assignable, and are found
+// by skipping over the ranges of used addresses. Used addresses are only
+// candidates when the service allows sharing (svc has no allocation at
+// this point, so without a sharing key a used address always belongs to
+// someone else), in which case they are checked one by one.
+func (a *Allocator) firstAssignable(r ipRange, cidr *net.IPNet, pool *config.Pool, svc string, ports []Port, sk *key, skip map[string]bool) net.IP {
+	usable := func(ip net.IP) bool {
+		return !pool.AvoidsIP(ip) && pool.StrideDistance(ip) == 0 && !skip[ip.String()]
+	}
+	for pos := r.first; pos.cmp(r.last) <= 0; {
+		used, isUsed := a.ipsWithKey.rangeFor(pos)
+		if !isUsed {
+			ip := ipFromIPAddr(pos, cidr)
+			if usable(ip) {
+				return ip
+			}
+			used = ipRange{first: pos, last: pos}
+		} else if sk.sharing != "" {
+			for cur := pos; cur.cmp(used.last) <= 0 && cur.cmp(r.last) <= 0; {
+				ip := ipFromIPAddr(cur, cidr)
+				if usable(ip) && a.checkSharing(svc, ip.String(), ports, sk) == nil {
+					return ip
+				}
+				var ok bool
+				if cur, ok = cur.next(); !ok {
+					break
+				}
+			}
+		}
+		var ok bool
+		if pos, ok = used.last.next(); !ok {
+			break
+		}
+	}
+	return nil
+}
+
+// lastAssignable returns the highest IP of r that can be assigned to svc,
+// walking the addresses down in the same way as firstAssignable.
+func (a *Allocator) lastAssignable(r ipRange, cidr *net.IPNet, pool *config.Pool, svc string, ports []Port, sk *key, skip map[string]bool) net.IP {
+	usable := func(ip net.IP) bool {
+		return !pool.AvoidsIP(ip) && pool.StrideDistance(ip) == 0 && !skip[ip.String()]
+	}
+	for pos := r.last; pos.cmp(r.first) >= 0; {
+		used, isUsed := a.ipsWithKey.rangeFor(pos)
+		if !isUsed {
+			ip := ipFromIPAddr(pos, cidr)
+			if usable(ip) {
+				return ip
+			}
+			used = ipRange{first: pos, last: pos}
+		} else if sk.sharing != "" {
+			for cur := pos; cur.cmp(used.first) >= 0 && cur.cmp(r.first) >= 0; {
+				ip := ipFromIPAddr(cur, cidr)
+				if usable(ip) && a.checkSharing(svc, ip.String(), ports, sk) == nil {
+					return ip
+				}
+				var ok bool
+				if cur, ok = cur.prev(); !ok {
+					break
+				}
+			}
+		}
+		var ok bool
+		if pos, ok = used.first.prev(); !ok {
+			break
 		}
-		return pos.IP
 	}
 	return nil
 }
 
+// poolCIDRs returns the CIDRs of the pool in the order they are searched
+// for a free address: the order of the pool, or from the highest to the
+// lowest when the pool allocates its highest addresses first. The families
+// keep the order of the pool, so that the IPs of the dual stack services
+// are in the same order in both cases.
+func poolCIDRs(pool *config.Pool) []*net.IPNet {
+	if pool.AllocationMode != config.AllocationHighest {
+		return pool.CIDR
+	}
+	familyRank := map[ipfamily.Family]int{}
+	for _, cidr := range pool.CIDR {
+		if _, ok := familyRank[ipfamily.ForCIDR(cidr)]; !ok {
+			familyRank[ipfamily.ForCIDR(cidr)] = len(familyRank)
+		}
+	}
+	res := make([]*net.IPNet, len(pool.CIDR))
+	copy(res, pool.CIDR)
+	sort.SliceStable(res, func(i, j int) bool {
+		ri, rj := familyRank[ipfamily.ForCIDR(res[i])], familyRank[ipfamily.ForCIDR(res[j])]
+		if ri != rj {
+			return ri < rj
+		}
+		return cidrRange(res[i]).first.cmp(cidrRange(res[j]).first) > 0
+	})
+	return res
+}
+
+// ipFromIPAddr converts ip back to a net.IP of the same length as the
+// addresses of cidr.
+func ipFromIPAddr(ip ipAddr, cidr *net.IPNet) net.IP {
+	res := make(net.IP, net.IPv6len)
+	copy(res, ip[:])
+	if cidr.IP.To4() != nil {
+		return res.To4()
+	}
+	return res
+}
+
 func (a *Allocator) checkSharing(svc string, ip string, ports []Port, sk *key) error {
 	if existingSK := a.sharingKeyForIP[ip]; existingSK != nil {
 		if err := sharingOK(existingSK, sk); err != nil {
@@ -3,6 +3,8 @@
 package allocator
 
 import (
+	"errors"
+	"fmt"
 	"math"
 	"net"
 	"reflect"
@@ -14,9 +16,12 @@ import (
 	"go.universe.tf/metallb/internal/ipfamily"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 
+	"github.com/prometheus/client_golang/prometheus"
 	ptu "github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -1971,6 +1976,51 @@ func TestPoolCount(t *testing.T) {
 			},
 			want: 381,
 		},
+		{
+			desc: "BGP /24, even IPs",
+			pool: &config.Pool{
+				CIDR:             []*net.IPNet{ipnet("1.2.3.0/24")},
+				AllocationStride: 2,
+			},
+			want: 128,
+		},
+		{
+			desc: "BGP /24 and /25, odd IPs, no buggy IPs",
+			pool: &config.Pool{
+				CIDR:             []*net.IPNet{ipnet("1.2.3.0/24"), ipnet("2.3.4.128/25")},
+				AvoidBuggyIPs:    true,
+				AllocationStride: 2,
+				AllocationOffset: 1,
+			},
+			want: 190,
+		},
+		{
+			desc: "BGP /16, even IPs, no buggy IPs",
+			pool: &config.Pool{
+				CIDR:             []*net.IPNet{ipnet("1.2.0.0/16")},
+				AvoidBuggyIPs:    true,
+				AllocationStride: 2,
+			},
+			want: 32512,
+		},
+		{
+			desc: "BGP /24 and /25, no buggy IPs, avoiding some last octets",
+			pool: &config.Pool{
+				CIDR:            []*net.IPNet{ipnet("1.2.3.0/24"), ipnet("2.3.4.128/25")},
+				AvoidBuggyIPs:   true,
+				AvoidLastOctets: []uint8{1, 200, 255},
+			},
+			want: 378,
+		},
+		{
+			desc: "BGP /23, even IPs, avoiding some last octets",
+			pool: &config.Pool{
+				CIDR:             []*net.IPNet{ipnet("1.2.2.0/23")},
+				AvoidLastOctets:  []uint8{1, 2, 4},
+				AllocationStride: 2,
+			},
+			want: 252,
+		},
 		{
 			desc: "BGP a BIG ipv6 range",
 			pool: &config.Pool{
@@ -1989,6 +2039,87 @@ func TestPoolCount(t *testing.T) {
 	}
 }
 
+func TestCounters(t *testing.T) {
+	alloc := New()
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"test": {
+			Name:          "test",
+			AutoAssign:    true,
+			AvoidBuggyIPs: true,
+			CIDR:          []*net.IPNet{ipnet("1.2.3.0/24"), ipnet("1000::/126")},
+		},
+		"other": {
+			Name: "other",
+			CIDR: []*net.IPNet{ipnet("2.3.4.0/30")},
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+	if err := alloc.Assign("s1", svc, []net.IP{net.ParseIP("1.2.3.1")}, nil, "", ""); err != nil {
+		t.Fatalf("Assign(s1, 1.2.3.1): %s", err)
+	}
+	if err := alloc.Assign("s2", svc, []net.IP{net.ParseIP("1000::1")}, ports("tcp/443"), "share", ""); err != nil {
+		t.Fatalf("Assign(s2, 1000::1): %s", err)
+	}
+	// Sharing an address must not count it twice.
+	if err := alloc.Assign("s3", svc, []net.IP{net.ParseIP("1000::1")}, ports("tcp/80"), "share", ""); err != nil {
+		t.Fatalf("Assign(s3, 1000::1): %s", err)
+	}
+
+	want := map[string]PoolCounters{
+		"test": {
+			AssignedIPv4:  1,
+			AssignedIPv6:  1,
+			AvailableIPv4: 253,
+			AvailableIPv6: 3,
+		},
+		"other": {
+			AvailableIPv4: 4,
+		},
+	}
+	if got := alloc.Counters(); !reflect.DeepEqual(want, got) {
+		t.Errorf("unexpected counters, want %v, got %v", want, got)
+	}
+
+	alloc.Unassign("s1")
+	want["test"] = PoolCounters{
+		AssignedIPv6:  1,
+		AvailableIPv4: 254,
+		AvailableIPv6: 3,
+	}
+	if got := alloc.Counters(); !reflect.DeepEqual(want, got) {
+		t.Errorf("unexpected counters after unassign, want %v, got %v", want, got)
+	}
+
+	// The counters follow the addresses moved to another pool.
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"test": {
+			Name:       "test",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+		},
+		"other": {
+			Name: "other",
+			CIDR: []*net.IPNet{ipnet("2.3.4.0/30"), ipnet("1000::/126")},
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+	want = map[string]PoolCounters{
+		"test": {
+			AvailableIPv4: 256,
+		},
+		"other": {
+			AssignedIPv6:  1,
+			AvailableIPv4: 4,
+			AvailableIPv6: 3,
+		},
+	}
+	if got := alloc.Counters(); !reflect.DeepEqual(want, got) {
+		t.Errorf("unexpected counters after moving pools, want %v, got %v", want, got)
+	}
+}
+
 func TestPoolMetrics(t *testing.T) {
 	alloc := New()
 	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
@@ -2128,8 +2259,544 @@ func TestPoolMetrics(t *testing.T) {
 	}
 }
 
+func TestRangeAllocation(t *testing.T) {
+	tests := []struct {
+		desc            string
+		addresses       string
+		avoidBuggyIPs   bool
+		avoidLastOctets []uint8
+		expected        []string
+	}{
+		{
+			desc:      "non aligned range",
+			addresses: "10.0.0.5-10.0.0.9",
+			expected:  []string{"10.0.0.5", "10.0.0.6", "10.0.0.7", "10.0.0.8", "10.0.0.9"},
+		},
+		{
+			desc:          "range across a /24 boundary, avoiding buggy ips",
+			addresses:     "10.0.0.253-10.0.1.1",
+			avoidBuggyIPs: true,
+			expected:      []string{"10.0.0.253", "10.0.0.254", "10.0.1.1"},
+		},
+		{
+			desc:            "range avoiding some last octets",
+			addresses:       "10.0.0.1-10.0.0.6",
+			avoidLastOctets: []uint8{2, 5},
+			expected:        []string{"10.0.0.1", "10.0.0.3", "10.0.0.4", "10.0.0.6"},
+		},
+		{
+			desc:            "range across a /24 boundary, avoiding buggy ips and some last octets",
+			addresses:       "10.0.0.254-10.0.1.3",
+			avoidBuggyIPs:   true,
+			avoidLastOctets: []uint8{1},
+			expected:        []string{"10.0.0.254", "10.0.1.2", "10.0.1.3"},
+		},
+		{
+			desc:      "single address range",
+			addresses: "10.0.0.37-10.0.0.37",
+			expected:  []string{"10.0.0.37"},
+		},
+		{
+			desc:      "ipv6 non aligned range",
+			addresses: "1000::fe-1000::101",
+			expected:  []string{"1000::fe", "1000::ff", "1000::100", "1000::101"},
+		},
+	}
+
+	for _, test := range tests {
+		cidrs, err := config.ParseCIDR(test.addresses)
+		if err != nil {
+			t.Fatalf("%s: failed to parse %s: %s", test.desc, test.addresses, err)
+		}
+		pool := &config.Pool{
+			Name:            "test",
+			AutoAssign:      true,
+			AvoidBuggyIPs:   test.avoidBuggyIPs,
+			AvoidLastOctets: test.avoidLastOctets,
+			CIDR:            cidrs,
+		}
+		alloc := New()
+		if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{"test": pool}}); err != nil {
+			t.Fatalf("%s: SetPools: %s", test.desc, err)
+		}
+		if c := poolCount(pool); c != int64(len(test.expected)) {
+			t.Errorf("%s: pool count is %d, expected %d", test.desc, c, len(test.expected))
+		}
+
+		family := ipfamily.IPv4
+		if net.ParseIP(test.expected[0]).To4() == nil {
+			family = ipfamily.IPv6
+		}
+		got := []string{}
+		for i := range test.expected {
+			ips, err := alloc.Allocate(fmt.Sprintf("s%d", i), svc, family, nil, "", "")
+			if err != nil {
+				t.Fatalf("%s: allocation %d failed: %s", test.desc, i, err)
+			}
+			got = append(got, ips[0].String())
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: got allocations %v, expected %v", test.desc, got, test.expected)
+		}
+		if _, err := alloc.Allocate("exhausted", svc, family, nil, "", ""); err == nil {
+			t.Errorf("%s: expected the range to be exhausted", test.desc)
+		}
+	}
+}
+
+func TestHashStrategy(t *testing.T) {
+	newAllocator := func() *Allocator {
+		alloc := New()
+		if err := alloc.SetStrategy(StrategyHash); err != nil {
+			t.Fatalf("SetStrategy: %s", err)
+		}
+		if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+			"test": {
+				Name:       "test",
+				AutoAssign: true,
+				CIDR:       []*net.IPNet{ipnet("10.0.0.0/24"), ipnet("1000::/112")},
+			},
+		}}); err != nil {
+			t.Fatalf("SetPools: %s", err)
+		}
+		return alloc
+	}
+	service := func(uid string) *v1.Service {
+		s := svc.DeepCopy()
+		s.UID = types.UID(uid)
+		return s
+	}
+
+	// The same service gets the same IP from an empty pool.
+	first, err := newAllocator().Allocate("ns/s1", service("uid1"), ipfamily.DualStack, nil, "", "")
+	if err != nil {
+		t.Fatalf("Allocate: %s", err)
+	}
+	second, err := newAllocator().Allocate("ns/s1", service("uid1"), ipfamily.DualStack, nil, "", "")
+	if err != nil {
+		t.Fatalf("Allocate: %s", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("same service got different IPs: %v and %v", first, second)
+	}
+
+	// A recreated service gets a different IP.
+	other, err := newAllocator().Allocate("ns/s1", service("uid2"), ipfamily.DualStack, nil, "", "")
+	if err != nil {
+		t.Fatalf("Allocate: %s", err)
+	}
+	if reflect.DeepEqual(first, other) {
+		t.Errorf("services with different UIDs got the same IPs: %v", first)
+	}
+
+	// Collisions probe forward, and wrap around to the start of the CIDR.
+	alloc := New()
+	if err := alloc.SetStrategy(StrategyHash); err != nil {
+		t.Fatalf("SetStrategy: %s", err)
+	}
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"test": {
+			Name:       "test",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("10.0.0.0/30")},
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+	got := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		ips, err := alloc.Allocate(fmt.Sprintf("ns/s%d", i), service(fmt.Sprintf("uid%d", i)), ipfamily.IPv4, nil, "", "")
+		if err != nil {
+			t.Fatalf("Allocate %d: %s", i, err)
+		}
+		got[ips[0].String()] = true
+	}
+	if len(got) != 4 {
+		t.Errorf("expected the 4 addresses of the pool to be used, got %v", got)
+	}
+	if _, err := alloc.Allocate("ns/s5", service("uid5"), ipfamily.IPv4, nil, "", ""); err == nil {
+		t.Error("expected the pool to be exhausted")
+	}
+
+	if err := alloc.SetStrategy("random"); err == nil {
+		t.Error("expected an unknown strategy to be rejected")
+	}
+}
+
+func TestPoolDistribution(t *testing.T) {
+	tests := []struct {
+		desc         string
+		distribution Distribution
+		services     int
+		// The number of IPv6 services allocated from each pool.
+		expected map[string]int
+	}{
+		{
+			desc:         "fill",
+			distribution: DistributionFill,
+			services:     4,
+			expected:     map[string]int{"pool1": 4, "pool2": 0, "pool3": 0},
+		},
+		{
+			desc:         "spread",
+			distribution: DistributionSpread,
+			services:     4,
+			expected:     map[string]int{"pool1": 1, "pool2": 1, "pool3": 2},
+		},
+		{
+			desc:         "most-free, larger pool first",
+			distribution: DistributionMostFree,
+			services:     4,
+			expected:     map[string]int{"pool1": 0, "pool2": 0, "pool3": 4},
+		},
+		{
+			desc:         "most-free, same number of free addresses left",
+			distribution: DistributionMostFree,
+			services:     10,
+			expected:     map[string]int{"pool1": 2, "pool2": 2, "pool3": 6},
+		},
+	}
+
+	for _, test := range tests {
+		alloc := New()
+		if err := alloc.SetDistribution(test.distribution); err != nil {
+			t.Fatalf("%s: SetDistribution: %s", test.desc, err)
+		}
+		// pool3 is twice as large, and the IPv4 addresses of pool1 are
+		// ignored for the IPv6 services.
+		if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+			"pool1": {
+				Name:       "pool1",
+				AutoAssign: true,
+				CIDR:       []*net.IPNet{ipnet("10.0.0.0/30"), ipnet("1000::/126")},
+			},
+			"pool2": {
+				Name:       "pool2",
+				AutoAssign: true,
+				CIDR:       []*net.IPNet{ipnet("2000::/126")},
+			},
+			"pool3": {
+				Name:       "pool3",
+				AutoAssign: true,
+				CIDR:       []*net.IPNet{ipnet("3000::/125")},
+			},
+		}}); err != nil {
+			t.Fatalf("%s: SetPools: %s", test.desc, err)
+		}
+		if _, err := alloc.Allocate("ns/v4", svc, ipfamily.IPv4, nil, "", ""); err != nil {
+			t.Fatalf("%s: Allocate: %s", test.desc, err)
+		}
+
+		got := map[string]int{"pool1": 0, "pool2": 0, "pool3": 0}
+		for i := 0; i < test.services; i++ {
+			svcKey := fmt.Sprintf("ns/s%d", i)
+			if _, err := alloc.Allocate(svcKey, svc, ipfamily.IPv6, nil, "", ""); err != nil {
+				t.Fatalf("%s: Allocate %d: %s", test.desc, i, err)
+			}
+			got[alloc.Pool(svcKey)]++
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: got allocations %v, expected %v", test.desc, got, test.expected)
+		}
+	}
+
+	if err := New().SetDistribution("random"); err == nil {
+		t.Error("expected an unknown distribution strategy to be rejected")
+	}
+}
+
+type fakeIPAM struct {
+	refused  map[string]bool
+	failing  bool
+	reserved map[string]string // ip -> service
+}
+
+func (f *fakeIPAM) Reserve(svcKey, pool string, ips []net.IP) error {
+	if f.failing {
+		return errors.New("timeout")
+	}
+	for _, ip := range ips {
+		if f.refused[ip.String()] {
+			return ErrRefused
+		}
+	}
+	for _, ip := range ips {
+		f.reserved[ip.String()] = svcKey
+	}
+	return nil
+}
+
+func (f *fakeIPAM) Release(svcKey, pool string, ips []net.IP) {
+	for _, ip := range ips {
+		delete(f.reserved, ip.String())
+	}
+}
+
+func TestIPAM(t *testing.T) {
+	ipam := &fakeIPAM{
+		refused:  map[string]bool{"10.0.0.0": true, "10.0.0.1": true},
+		reserved: map[string]string{},
+	}
+	alloc := New()
+	alloc.SetIPAM(ipam)
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"test": {
+			Name:       "test",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("10.0.0.0/30")},
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+
+	// The addresses refused by the IPAM are skipped.
+	ips, err := alloc.Allocate("ns/s1", svc, ipfamily.IPv4, nil, "share", "")
+	if err != nil {
+		t.Fatalf("Allocate: %s", err)
+	}
+	if ips[0].String() != "10.0.0.2" || ipam.reserved["10.0.0.2"] != "ns/s1" {
+		t.Fatalf("expected 10.0.0.2 to be reserved for ns/s1, got %v and %v", ips, ipam.reserved)
+	}
+
+	// Sharing an address doesn't reserve it again.
+	if err := alloc.Assign("ns/s2", svc, ips, nil, "share", ""); err != nil {
+		t.Fatalf("Assign: %s", err)
+	}
+	if ipam.reserved["10.0.0.2"] != "ns/s1" {
+		t.Fatalf("expected 10.0.0.2 to be still reserved for ns/s1, got %v", ipam.reserved)
+	}
+
+	// A failing IPAM fails the allocation without assigning anything.
+	ipam.failing = true
+	_, err = alloc.Allocate("ns/s3", svc, ipfamily.IPv4, nil, "", "")
+	if err == nil || PendingReason(err) != ReasonIPAM {
+		t.Fatalf("expected the allocation to fail because of the IPAM, got %v", err)
+	}
+	if alloc.Pool("ns/s3") != "" {
+		t.Fatalf("expected ns/s3 not to be allocated")
+	}
+	ipam.failing = false
+
+	// The addresses are released once no service uses them.
+	alloc.Unassign("ns/s1")
+	if _, ok := ipam.reserved["10.0.0.2"]; !ok {
+		t.Fatalf("expected 10.0.0.2 to be still reserved while ns/s2 uses it")
+	}
+	alloc.Unassign("ns/s2")
+	if len(ipam.reserved) != 0 {
+		t.Fatalf("expected all the addresses to be released, got %v", ipam.reserved)
+	}
+
+	// Moving a service to another address releases the previous one.
+	if err := alloc.Assign("ns/s4", svc, []net.IP{net.ParseIP("10.0.0.2")}, nil, "", ""); err != nil {
+		t.Fatalf("Assign: %s", err)
+	}
+	if err := alloc.Assign("ns/s4", svc, []net.IP{net.ParseIP("10.0.0.3")}, nil, "", ""); err != nil {
+		t.Fatalf("Assign: %s", err)
+	}
+	if !reflect.DeepEqual(ipam.reserved, map[string]string{"10.0.0.3": "ns/s4"}) {
+		t.Fatalf("expected only 10.0.0.3 to be reserved, got %v", ipam.reserved)
+	}
+}
+
+func TestAllocationDurationMetrics(t *testing.T) {
+	alloc := New()
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"test": {
+			Name:       "test",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.4/31")},
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+
+	before := map[string]uint64{}
+	for _, o := range []string{outcomeSuccess, outcomeExhausted, outcomeError} {
+		before[o] = allocationSamples(t, o)
+	}
+
+	for _, s := range []string{"s1", "s2", "s3"} {
+		_, _ = alloc.Allocate(s, svc, ipfamily.IPv4, nil, "", "")
+	}
+	_, _ = alloc.AllocateFromPool("s4", svc, ipfamily.IPv4, "unknown", nil, "", "")
+
+	tests := map[string]uint64{
+		outcomeSuccess:   2,
+		outcomeExhausted: 1,
+		outcomeError:     1,
+	}
+	for o, expected := range tests {
+		if got := allocationSamples(t, o) - before[o]; got != expected {
+			t.Errorf("outcome %q: got %d samples, expected %d", o, got, expected)
+		}
+	}
+}
+
+func TestServicePortsAllocation(t *testing.T) {
+	alloc := New()
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"public": {
+			Name:       "public",
+			AutoAssign: true,
+			ServiceAllocations: &config.ServiceAllocation{
+				ServicePorts: []config.PortRange{{Min: 443, Max: 443}}},
+			CIDR: []*net.IPNet{ipnet("1.2.3.0/24")},
+		},
+		"internal": {
+			Name:       "internal",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("10.0.0.0/24")},
+		},
+	},
+		ByServicePorts: []string{"public"},
+	}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+
+	serviceWithPorts := func(ports ...int32) *v1.Service {
+		res := &v1.Service{}
+		for _, p := range ports {
+			res.Spec.Ports = append(res.Spec.Ports, v1.ServicePort{Port: p})
+		}
+		return res
+	}
+
+	tests := []struct {
+		desc string
+		svc  *v1.Service
+		pool string
+	}{
+		{
+			desc: "port matching the public pool",
+			svc:  serviceWithPorts(443),
+			pool: "public",
+		},
+		{
+			desc: "one of the ports matching the public pool",
+			svc:  serviceWithPorts(80, 443),
+			pool: "public",
+		},
+		{
+			desc: "port not matching the public pool",
+			svc:  serviceWithPorts(80),
+			pool: "internal",
+		},
+	}
+	for i, test := range tests {
+		key := fmt.Sprintf("s%d", i)
+		if _, err := alloc.Allocate(key, test.svc, ipfamily.IPv4, nil, "", ""); err != nil {
+			t.Fatalf("%s: Allocate: %s", test.desc, err)
+		}
+		if got := alloc.Pool(key); got != test.pool {
+			t.Errorf("%s: expected pool %q, got %q", test.desc, test.pool, got)
+		}
+	}
+
+	if err := alloc.Assign("s4", serviceWithPorts(80), []net.IP{net.ParseIP("1.2.3.100")}, nil, "", ""); err == nil {
+		t.Errorf("expected assigning an IP of the public pool to a service not exposing its ports to fail")
+	}
+}
+
+func TestPendingReason(t *testing.T) {
+	alloc := New()
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"test": {
+			Name:       "test",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.4/32")},
+		},
+		"manual": {
+			Name:       "manual",
+			AutoAssign: false,
+			CIDR:       []*net.IPNet{ipnet("1.2.4.0/24")},
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+	if _, err := alloc.Allocate("s1", svc, ipfamily.IPv4, nil, "", ""); err != nil {
+		t.Fatalf("Allocate: %s", err)
+	}
+
+	tests := []struct {
+		desc     string
+		allocate func() error
+		reason   string
+	}{
+		{
+			desc: "pool exhausted",
+			allocate: func() error {
+				_, err := alloc.Allocate("s2", svc, ipfamily.IPv4, nil, "", "")
+				return err
+			},
+			reason: ReasonExhausted,
+		},
+		{
+			desc: "no pool for the family",
+			allocate: func() error {
+				_, err := alloc.Allocate("s2", svc, ipfamily.IPv6, nil, "", "")
+				return err
+			},
+			reason: ReasonFamilyMismatch,
+		},
+		{
+			desc: "unknown pool",
+			allocate: func() error {
+				_, err := alloc.AllocateFromPool("s2", svc, ipfamily.IPv4, "unknown", nil, "", "")
+				return err
+			},
+			reason: ReasonNoPool,
+		},
+		{
+			desc: "ip not in any pool",
+			allocate: func() error {
+				return alloc.Assign("s2", svc, []net.IP{net.ParseIP("10.0.0.1")}, nil, "", "")
+			},
+			reason: ReasonNoPool,
+		},
+		{
+			desc: "ip taken",
+			allocate: func() error {
+				return alloc.Assign("s2", svc, []net.IP{net.ParseIP("1.2.3.4")}, nil, "", "")
+			},
+			reason: ReasonIPTaken,
+		},
+	}
+	for _, test := range tests {
+		err := test.allocate()
+		if err == nil {
+			t.Errorf("%s: expected allocation to fail", test.desc)
+			continue
+		}
+		if got := PendingReason(err); got != test.reason {
+			t.Errorf("%s: expected reason %q, got %q", test.desc, test.reason, got)
+		}
+	}
+
+	noPools := New()
+	if err := noPools.SetPools(&config.Pools{ByName: map[string]*config.Pool{}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+	_, err := noPools.Allocate("s1", svc, ipfamily.IPv4, nil, "", "")
+	if got := PendingReason(err); got != ReasonNoPool {
+		t.Errorf("no pools: expected reason %q, got %q", ReasonNoPool, got)
+	}
+}
+
 // Some helpers.
 
+func allocationSamples(t *testing.T, outcome string) uint64 {
+	m := &dto.Metric{}
+	h, ok := stats.allocDuration.WithLabelValues(outcome).(prometheus.Histogram)
+	if !ok {
+		t.Fatalf("allocation duration for %q is not a histogram", outcome)
+	}
+	if err := h.Write(m); err != nil {
+		t.Fatalf("failed to read allocation duration for %q: %s", outcome, err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
 func assigned(a *Allocator, svc string) []string {
 	res := []string{}
 	if alloc := a.allocated[svc]; alloc != nil {
@@ -2183,3 +2850,457 @@ func compareIPs(ips1, ips2 []string) bool {
 	}
 	return true
 }
+
+func BenchmarkAllocateIPv4Slash16(b *testing.B) {
+	benchmarkAllocate(b, "10.0.0.0/16", ipfamily.IPv4)
+}
+
+func BenchmarkAllocateIPv6Slash112(b *testing.B) {
+	benchmarkAllocate(b, "1000::/112", ipfamily.IPv6)
+}
+
+// benchmarkAllocate measures the cost of finding a free address in a pool
+// where the lower half of the addresses are already in use.
+func benchmarkAllocate(b *testing.B, cidr string, family ipfamily.Family) {
+	alloc := New()
+	pool := ipnet(cidr)
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"test": {
+			Name:       "test",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{pool},
+		},
+	}}); err != nil {
+		b.Fatalf("SetPools: %s", err)
+	}
+
+	ones, bits := pool.Mask.Size()
+	used := 1 << (bits - ones - 1)
+	ip := ipFromIPAddr(cidrRange(pool).first, pool)
+	for i := 0; i < used; i++ {
+		if err := alloc.Assign(fmt.Sprintf("used%d", i), svc, []net.IP{ip}, nil, "", ""); err != nil {
+			b.Fatalf("Assign: %s", err)
+		}
+		next, _ := toIPAddr(ip).next()
+		ip = ipFromIPAddr(next, pool)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := alloc.AllocateFromPool("bench", svc, family, "test", nil, "", ""); err != nil {
+			b.Fatalf("AllocateFromPool: %s", err)
+		}
+		alloc.Unassign("bench")
+	}
+}
+
+func TestServiceZone(t *testing.T) {
+	alloc := New()
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"pool-a": {
+			Name:       "pool-a",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("10.0.0.0/32")},
+			Zone:       "zone-a",
+		},
+		"pool-b": {
+			Name:       "pool-b",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("10.0.1.0/31")},
+			Zone:       "zone-b",
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+
+	tests := []struct {
+		svcKey   string
+		zone     string
+		expected string
+	}{
+		// Without a zone the pools are tried in order.
+		{"ns/no-zone", "", "pool-a"},
+		{"ns/zone-b", "zone-b", "pool-b"},
+		// pool-a is exhausted, so the pools of other zones are used.
+		{"ns/zone-a", "zone-a", "pool-b"},
+		{"ns/zone-c", "zone-c", ""},
+	}
+	for _, test := range tests {
+		alloc.SetServiceZone(test.svcKey, test.zone)
+		_, err := alloc.Allocate(test.svcKey, svc, ipfamily.IPv4, nil, "", "")
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("%s: expected the allocation to fail, got pool %q", test.svcKey, alloc.Pool(test.svcKey))
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Allocate: %s", test.svcKey, err)
+		}
+		if got := alloc.Pool(test.svcKey); got != test.expected {
+			t.Errorf("%s: got pool %q, expected %q", test.svcKey, got, test.expected)
+		}
+	}
+}
+
+func TestContiguousNamespaces(t *testing.T) {
+	alloc := New()
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"pool": {
+			Name:                 "pool",
+			AutoAssign:           true,
+			CIDR:                 []*net.IPNet{ipnet("10.0.0.0/28")},
+			ContiguousNamespaces: true,
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+
+	tests := []struct {
+		svcKey   string
+		unassign bool
+		expected string
+	}{
+		{svcKey: "ns1/a", expected: "10.0.0.0"},
+		{svcKey: "ns2/a", expected: "10.0.0.1"},
+		{svcKey: "ns2/b", expected: "10.0.0.2"},
+		// The address following 10.0.0.0 is taken, so the lowest free one is used.
+		{svcKey: "ns1/b", expected: "10.0.0.3"},
+		{svcKey: "ns2/a", unassign: true},
+		// 10.0.0.1 is free, but the address following the highest one of ns1 is preferred.
+		{svcKey: "ns1/c", expected: "10.0.0.4"},
+		// No address allocated to ns3 yet.
+		{svcKey: "ns3/a", expected: "10.0.0.1"},
+	}
+	for _, test := range tests {
+		if test.unassign {
+			alloc.Unassign(test.svcKey)
+			continue
+		}
+		ips, err := alloc.Allocate(test.svcKey, svc, ipfamily.IPv4, nil, "", "")
+		if err != nil {
+			t.Fatalf("%s: Allocate: %s", test.svcKey, err)
+		}
+		if len(ips) != 1 || ips[0].String() != test.expected {
+			t.Errorf("%s: got ips %v, expected %s", test.svcKey, ips, test.expected)
+		}
+	}
+}
+
+func TestIgnorePoolSelectors(t *testing.T) {
+	alloc := New()
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"pool": {
+			Name:               "pool",
+			AutoAssign:         true,
+			CIDR:               []*net.IPNet{ipnet("10.0.0.0/30")},
+			ServiceAllocations: &config.ServiceAllocation{Namespaces: sets.New("ns1")},
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "svc",
+			Namespace: "ns2",
+		},
+	}
+	ips := []net.IP{net.ParseIP("10.0.0.1")}
+
+	err := alloc.Assign("ns2/svc", service, ips, nil, "", "")
+	if !errors.Is(err, ErrPoolNotAllowed) {
+		t.Fatalf("expected ErrPoolNotAllowed, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `namespace "ns2"`) {
+		t.Errorf("expected the error to mention the namespace, got %q", err)
+	}
+	if reason := PendingReason(err); reason != ReasonNoPool {
+		t.Errorf("expected reason %q, got %q", ReasonNoPool, reason)
+	}
+
+	alloc.SetIgnorePoolSelectors("ns2/svc", true)
+	if err := alloc.Assign("ns2/svc", service, ips, nil, "", ""); err != nil {
+		t.Fatalf("Assign with ignored pool selectors: %s", err)
+	}
+	if pool := alloc.Pool("ns2/svc"); pool != "pool" {
+		t.Errorf("expected pool %q, got %q", "pool", pool)
+	}
+
+	// The pool selectors are enforced again once the override is removed.
+	alloc.Unassign("ns2/svc")
+	alloc.SetIgnorePoolSelectors("ns2/svc", false)
+	if err := alloc.Assign("ns2/svc", service, ips, nil, "", ""); !errors.Is(err, ErrPoolNotAllowed) {
+		t.Fatalf("expected ErrPoolNotAllowed, got %v", err)
+	}
+}
+
+func TestAllocationStride(t *testing.T) {
+	alloc := New()
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"pool": {
+			Name:             "pool",
+			AutoAssign:       true,
+			CIDR:             []*net.IPNet{ipnet("10.0.0.0/29"), ipnet("fc00::/125")},
+			AllocationStride: 4,
+			AllocationOffset: 2,
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+
+	for i, want := range []string{"10.0.0.2", "10.0.0.6"} {
+		ips, err := alloc.Allocate(fmt.Sprintf("s%d", i), svc, ipfamily.IPv4, nil, "", "")
+		if err != nil {
+			t.Fatalf("s%d: Allocate: %s", i, err)
+		}
+		if len(ips) != 1 || ips[0].String() != want {
+			t.Errorf("s%d: got ips %v, expected %s", i, ips, want)
+		}
+	}
+	if _, err := alloc.Allocate("s2", svc, ipfamily.IPv4, nil, "", ""); err == nil {
+		t.Error("expected the pool to be exhausted after two IPv4 allocations")
+	}
+
+	ips, err := alloc.Allocate("s6", svc, ipfamily.IPv6, nil, "", "")
+	if err != nil {
+		t.Fatalf("s6: Allocate: %s", err)
+	}
+	if len(ips) != 1 || ips[0].String() != "fc00::2" {
+		t.Errorf("s6: got ips %v, expected fc00::2", ips)
+	}
+
+	// Requesting an address not matching the stride fails.
+	if err := alloc.Assign("s7", svc, []net.IP{net.ParseIP("fc00::3")}, nil, "", ""); err == nil {
+		t.Error("assigning an address not matching the stride succeeded")
+	}
+
+	counters := alloc.Counters()["pool"]
+	if counters.AvailableIPv4 != 0 || counters.AvailableIPv6 != 1 {
+		t.Errorf("expected 0 IPv4 and 1 IPv6 available, got %d and %d", counters.AvailableIPv4, counters.AvailableIPv6)
+	}
+}
+
+func TestAlignDualStack(t *testing.T) {
+	newAllocator := func(align bool) *Allocator {
+		alloc := New()
+		alloc.SetAlignDualStack(align)
+		if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+			"pool": {
+				Name:       "pool",
+				AutoAssign: true,
+				CIDR: []*net.IPNet{
+					ipnet("10.0.0.0/30"),
+					ipnet("10.0.1.0/30"),
+					ipnet("fc00::/126"),
+					ipnet("fc00::1:0/126"),
+				},
+			},
+		}}); err != nil {
+			t.Fatalf("SetPools: %s", err)
+		}
+		// Hold the first IPv6 address, and the second and third IPv4 ones.
+		for svcKey, ip := range map[string]string{"v6": "fc00::", "v4a": "10.0.0.1", "v4b": "10.0.0.2"} {
+			if err := alloc.Assign(svcKey, svc, []net.IP{net.ParseIP(ip)}, nil, "", ""); err != nil {
+				t.Fatalf("Assign %s to %s: %s", ip, svcKey, err)
+			}
+		}
+		return alloc
+	}
+
+	tests := []struct {
+		desc  string
+		align bool
+		want  []string
+	}{
+		{
+			desc: "independent",
+			want: []string{"10.0.0.0", "fc00::1"},
+		},
+		{
+			desc:  "aligned",
+			align: true,
+			want:  []string{"10.0.0.3", "fc00::3"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			alloc := newAllocator(test.align)
+			ips, err := alloc.Allocate("ds", svc, ipfamily.DualStack, nil, "", "")
+			if err != nil {
+				t.Fatalf("Allocate: %s", err)
+			}
+			if got := fmt.Sprint(ips); got != fmt.Sprint(test.want) {
+				t.Errorf("got ips %s, expected %v", got, test.want)
+			}
+		})
+	}
+
+	// The index spans the CIDRs of the family: once the first CIDRs are
+	// full, the fifth IPv4 address is paired with the fifth IPv6 one.
+	alloc := newAllocator(true)
+	for svcKey, ip := range map[string]string{"v4c": "10.0.0.0", "v4d": "10.0.0.3", "v6b": "fc00::1:0"} {
+		if err := alloc.Assign(svcKey, svc, []net.IP{net.ParseIP(ip)}, nil, "", ""); err != nil {
+			t.Fatalf("Assign %s to %s: %s", ip, svcKey, err)
+		}
+	}
+	ips, err := alloc.Allocate("ds", svc, ipfamily.DualStack, nil, "", "")
+	if err != nil {
+		t.Fatalf("Allocate: %s", err)
+	}
+	if len(ips) != 2 || ips[0].String() != "10.0.1.1" || ips[1].String() != "fc00::1:1" {
+		t.Errorf("got ips %v, expected [10.0.1.1 fc00::1:1]", ips)
+	}
+
+	// Without any aligned pair left, the families are allocated
+	// independently.
+	for svcKey, ip := range map[string]string{"v6c": "fc00::1:2", "v6d": "fc00::1:3", "v6e": "fc00::2"} {
+		if err := alloc.Assign(svcKey, svc, []net.IP{net.ParseIP(ip)}, nil, "", ""); err != nil {
+			t.Fatalf("Assign %s to %s: %s", ip, svcKey, err)
+		}
+	}
+	ips, err = alloc.Allocate("ds2", svc, ipfamily.DualStack, nil, "", "")
+	if err != nil {
+		t.Fatalf("Allocate: %s", err)
+	}
+	if len(ips) != 2 || ips[0].String() != "10.0.1.0" || ips[1].String() != "fc00::1" {
+		t.Errorf("got ips %v, expected [10.0.1.0 fc00::1]", ips)
+	}
+}
+
+func TestAllocationHighest(t *testing.T) {
+	alloc := New()
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"pool": {
+			Name:           "pool",
+			AutoAssign:     true,
+			CIDR:           []*net.IPNet{ipnet("10.0.0.0/30"), ipnet("fc00::/127"), ipnet("10.0.1.0/31")},
+			AllocationMode: config.AllocationHighest,
+		},
+		"edges": {
+			Name:           "edges",
+			AutoAssign:     false,
+			CIDR:           []*net.IPNet{ipnet("::/127"), ipnet("ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/127")},
+			AllocationMode: config.AllocationHighest,
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+
+	// The highest CIDR is used first, from its last address downward.
+	for i, want := range []string{"10.0.1.1", "10.0.1.0", "10.0.0.3"} {
+		ips, err := alloc.Allocate(fmt.Sprintf("s%d", i), svc, ipfamily.IPv4, nil, "", "")
+		if err != nil {
+			t.Fatalf("s%d: Allocate: %s", i, err)
+		}
+		if len(ips) != 1 || ips[0].String() != want {
+			t.Errorf("s%d: got ips %v, expected %s", i, ips, want)
+		}
+	}
+
+	// A released address is the next one handed out if it's the highest free.
+	alloc.Unassign("s1")
+	ips, err := alloc.Allocate("s3", svc, ipfamily.IPv4, nil, "", "")
+	if err != nil {
+		t.Fatalf("s3: Allocate: %s", err)
+	}
+	if len(ips) != 1 || ips[0].String() != "10.0.1.0" {
+		t.Errorf("s3: got ips %v, expected 10.0.1.0", ips)
+	}
+
+	// A service sharing the address of another one gets the highest
+	// address it can share.
+	ports := []Port{{Proto: "TCP", Port: 80}}
+	ips, err = alloc.Allocate("s4", svc, ipfamily.IPv4, ports, "key", "")
+	if err != nil {
+		t.Fatalf("s4: Allocate: %s", err)
+	}
+	if len(ips) != 1 || ips[0].String() != "10.0.0.2" {
+		t.Errorf("s4: got ips %v, expected 10.0.0.2", ips)
+	}
+	ips, err = alloc.Allocate("s5", svc, ipfamily.IPv4, []Port{{Proto: "TCP", Port: 443}}, "key", "")
+	if err != nil {
+		t.Fatalf("s5: Allocate: %s", err)
+	}
+	if len(ips) != 1 || ips[0].String() != "10.0.0.2" {
+		t.Errorf("s5: got ips %v, expected 10.0.0.2", ips)
+	}
+
+	// The families of the dual stack services keep the order of the pool.
+	ips, err = alloc.Allocate("s6", svc, ipfamily.DualStack, nil, "", "")
+	if err != nil {
+		t.Fatalf("s6: Allocate: %s", err)
+	}
+	if len(ips) != 2 || ips[0].String() != "10.0.0.1" || ips[1].String() != "fc00::1" {
+		t.Errorf("s6: got ips %v, expected [10.0.0.1 fc00::1]", ips)
+	}
+
+	// The search stops at the bounds of the address space.
+	for i, want := range []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe", "::1", "::"} {
+		ips, err := alloc.AllocateFromPool(fmt.Sprintf("e%d", i), svc, ipfamily.IPv6, "edges", nil, "", "")
+		if err != nil {
+			t.Fatalf("e%d: AllocateFromPool: %s", i, err)
+		}
+		if len(ips) != 1 || ips[0].String() != want {
+			t.Errorf("e%d: got ips %v, expected %s", i, ips, want)
+		}
+	}
+	if _, err := alloc.AllocateFromPool("e4", svc, ipfamily.IPv6, "edges", nil, "", ""); err == nil {
+		t.Error("expected the edges pool to be exhausted")
+	}
+}
+
+func TestAssignmentMetrics(t *testing.T) {
+	alloc := New()
+	if err := alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"pool": {
+			Name:       "pool",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("10.0.0.0/30"), ipnet("fc00::/126")},
+		},
+	}}); err != nil {
+		t.Fatalf("SetPools: %s", err)
+	}
+	defer alloc.SetAssignmentMetrics(0)
+
+	allocate := func(svcKey string, family ipfamily.Family) {
+		t.Helper()
+		if _, err := alloc.Allocate(svcKey, svc, family, nil, "", ""); err != nil {
+			t.Fatalf("%s: Allocate: %s", svcKey, err)
+		}
+	}
+	check := func(desc string, series, notExported int) {
+		t.Helper()
+		if got := ptu.CollectAndCount(assignmentStats.assignment); got != series {
+			t.Errorf("%s: expected %d assignment series, got %d", desc, series, got)
+		}
+		if got := ptu.ToFloat64(assignmentStats.notExported); got != float64(notExported) {
+			t.Errorf("%s: expected %d assignments not exported, got %f", desc, notExported, got)
+		}
+	}
+
+	// The services allocated before enabling the metric are exported.
+	allocate("ns/a", ipfamily.IPv4)
+	check("disabled", 0, 0)
+	alloc.SetAssignmentMetrics(2)
+	check("enabled", 1, 0)
+	if got := ptu.ToFloat64(assignmentStats.assignment.WithLabelValues("10.0.0.0", "pool", "ns", "a")); got != 1 {
+		t.Errorf("expected the assignment of ns/a to be 1, got %f", got)
+	}
+
+	// A service whose IPs exceed the limit waits for room to be exported.
+	allocate("ns/b", ipfamily.DualStack)
+	check("over the limit", 1, 2)
+	allocate("ns/c", ipfamily.IPv4)
+	check("at the limit", 2, 2)
+	alloc.Unassign("ns/a")
+	check("not enough room", 1, 2)
+	alloc.Unassign("ns/c")
+	check("room made", 2, 0)
+	if got := ptu.ToFloat64(assignmentStats.assignment.WithLabelValues("fc00::", "pool", "ns", "b")); got != 1 {
+		t.Errorf("expected the IPv6 assignment of ns/b to be 1, got %f", got)
+	}
+
+	alloc.SetAssignmentMetrics(0)
+	check("disabled again", 0, 0)
+}
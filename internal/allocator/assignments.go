@@ -0,0 +1,143 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package allocator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var assignmentStats = struct {
+	assignment  *prometheus.GaugeVec
+	notExported prometheus.Gauge
+}{
+	assignment: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "metallb",
+		Subsystem: "allocator",
+		Name:      "assignment",
+		Help:      "Always 1, labelled with an assigned IP, its pool and the service it is assigned to",
+	}, []string{
+		"ip",
+		"pool",
+		"namespace",
+		"service",
+	}),
+	notExported: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "metallb",
+		Subsystem: "allocator",
+		Name:      "assignments_not_exported",
+		Help:      "Number of assigned IPs missing from the assignment metric because of its series limit",
+	}),
+}
+
+func init() {
+	prometheus.MustRegister(assignmentStats.assignment)
+	prometheus.MustRegister(assignmentStats.notExported)
+}
+
+// assignments tracks the series of the assignment metric, limited to
+// maxSeries. The services whose IPs don't fit are exported once the
+// series of other services are removed.
+type assignments struct {
+	maxSeries int
+	series    map[string][]prometheus.Labels // svc -> series of its IPs
+	count     int
+	pending   map[string]bool // the services not exported
+}
+
+// SetAssignmentMetrics enables the assignment metric, exporting at most
+// maxSeries assigned IPs. A limit of 0 disables the metric.
+func (a *Allocator) SetAssignmentMetrics(maxSeries int) {
+	assignmentStats.assignment.Reset()
+	if maxSeries <= 0 {
+		a.assignments = nil
+		a.updateNotExported()
+		return
+	}
+	a.assignments = &assignments{
+		maxSeries: maxSeries,
+		series:    map[string][]prometheus.Labels{},
+		pending:   map[string]bool{},
+	}
+	svcs := make([]string, 0, len(a.allocated))
+	for svc := range a.allocated {
+		svcs = append(svcs, svc)
+	}
+	sort.Strings(svcs)
+	for _, svc := range svcs {
+		a.exportAssignment(svc)
+	}
+	a.updateNotExported()
+}
+
+// exportAssignment adds the series of the IPs of svc, or records it as
+// pending if they exceed the limit.
+func (a *Allocator) exportAssignment(svc string) {
+	if a.assignments == nil {
+		return
+	}
+	al := a.allocated[svc]
+	if al == nil {
+		return
+	}
+	if a.assignments.count+len(al.ips) > a.assignments.maxSeries {
+		a.assignments.pending[svc] = true
+		return
+	}
+	namespace, name := "", svc
+	if i := strings.Index(svc, "/"); i >= 0 {
+		namespace, name = svc[:i], svc[i+1:]
+	}
+	for _, ip := range al.ips {
+		labels := prometheus.Labels{
+			"ip":        ip.String(),
+			"pool":      al.pool,
+			"namespace": namespace,
+			"service":   name,
+		}
+		assignmentStats.assignment.With(labels).Set(1)
+		a.assignments.series[svc] = append(a.assignments.series[svc], labels)
+	}
+	a.assignments.count += len(al.ips)
+	delete(a.assignments.pending, svc)
+}
+
+// unexportAssignment removes the series of the IPs of svc, and exports
+// the pending services fitting in the room left, in the order of their
+// keys.
+func (a *Allocator) unexportAssignment(svc string) {
+	if a.assignments == nil {
+		return
+	}
+	delete(a.assignments.pending, svc)
+	series, ok := a.assignments.series[svc]
+	if !ok {
+		return
+	}
+	for _, labels := range series {
+		assignmentStats.assignment.Delete(labels)
+	}
+	a.assignments.count -= len(series)
+	delete(a.assignments.series, svc)
+
+	pending := make([]string, 0, len(a.assignments.pending))
+	for s := range a.assignments.pending {
+		pending = append(pending, s)
+	}
+	sort.Strings(pending)
+	for _, s := range pending {
+		a.exportAssignment(s)
+	}
+}
+
+func (a *Allocator) updateNotExported() {
+	notExported := 0
+	if a.assignments != nil {
+		for svc := range a.assignments.pending {
+			notExported += len(a.allocated[svc].ips)
+		}
+	}
+	assignmentStats.notExported.Set(float64(notExported))
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package allocator
+
+import (
+	"net"
+	"strings"
+)
+
+// namespaceNext returns the address of cidr following the highest one
+// allocated from the pool to the other services of the namespace of
+// svcKey, and false if the namespace has none or the highest one is the
+// last address of cidr.
+func (a *Allocator) namespaceNext(pool string, cidr *net.IPNet, svcKey string) (ipAddr, bool) {
+	ns := namespaceOf(svcKey)
+	var highest ipAddr
+	found := false
+	for key, alloc := range a.allocated {
+		if key == svcKey || alloc.pool != pool || namespaceOf(key) != ns {
+			continue
+		}
+		for _, ip := range alloc.ips {
+			if !cidr.Contains(ip) {
+				continue
+			}
+			if addr := toIPAddr(ip); !found || addr.cmp(highest) > 0 {
+				highest, found = addr, true
+			}
+		}
+	}
+	if !found {
+		return ipAddr{}, false
+	}
+	next, ok := highest.next()
+	if !ok || next.cmp(cidrRange(cidr).last) > 0 {
+		return ipAddr{}, false
+	}
+	return next, true
+}
+
+// namespaceOf returns the namespace part of a "namespace/name" service key.
+func namespaceOf(svcKey string) string {
+	ns, _, found := strings.Cut(svcKey, "/")
+	if !found {
+		return ""
+	}
+	return ns
+}
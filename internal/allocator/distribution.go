@@ -0,0 +1,142 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package allocator
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/ipfamily"
+)
+
+// Distribution is the name of the strategy used to pick the pool a service
+// is allocated from among the pools matching it equally.
+type Distribution string
+
+const (
+	// DistributionFill allocates from the same pool until it's exhausted
+	// before moving to the next one.
+	DistributionFill Distribution = "fill"
+	// DistributionSpread allocates from the pool with the lowest share of
+	// addresses in use for the family of the service, so that the pools
+	// fill proportionally to their size.
+	DistributionSpread Distribution = "spread"
+	// DistributionMostFree allocates from the pool with the highest number
+	// of free addresses for the family of the service, so that the pools
+	// are left with the same number of free addresses.
+	DistributionMostFree Distribution = "most-free"
+)
+
+// SetDistribution sets the strategy used to pick the pool a service is
+// allocated from among the pools with the same priority.
+func (a *Allocator) SetDistribution(d Distribution) error {
+	switch d {
+	case DistributionFill, DistributionSpread, DistributionMostFree:
+	default:
+		return fmt.Errorf("unknown distribution strategy %q", d)
+	}
+	a.distribution = d
+	return nil
+}
+
+// autoAssignPools returns the pools not reserved to some services that can
+// be allocated from automatically, sorted by name.
+func (a *Allocator) autoAssignPools() []*config.Pool {
+	var pools []*config.Pool
+	for _, pool := range a.pools.ByName {
+		if !pool.AutoAssign || pool.ServiceAllocations != nil {
+			continue
+		}
+		pools = append(pools, pool)
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].Name < pools[j].Name
+	})
+	return pools
+}
+
+// distribute reorders the pools with the same priority according to the
+// distribution strategy, the pools being already sorted by priority.
+func (a *Allocator) distribute(pools []*config.Pool, serviceIPFamily ipfamily.Family) {
+	// The pools with the lowest score come first.
+	score := map[string]float64{}
+	switch a.distribution {
+	case DistributionSpread:
+		for _, p := range pools {
+			score[p.Name] = a.poolUsage(p, serviceIPFamily)
+		}
+	case DistributionMostFree:
+		for _, p := range pools {
+			score[p.Name] = -float64(a.poolFree(p, serviceIPFamily))
+		}
+	default:
+		return
+	}
+	sort.SliceStable(pools, func(i, j int) bool {
+		if pi, pj := poolPriority(pools[i]), poolPriority(pools[j]); pi != pj {
+			return pi < pj
+		}
+		if si, sj := score[pools[i].Name], score[pools[j].Name]; si != sj {
+			return si < sj
+		}
+		return pools[i].Name < pools[j].Name
+	})
+}
+
+// poolUsage returns the share of the addresses of the given family in use
+// in the pool, the highest of the two for dual stack services. A pool with
+// no address of the family is considered full.
+func (a *Allocator) poolUsage(p *config.Pool, serviceIPFamily ipfamily.Family) float64 {
+	families := []ipfamily.Family{serviceIPFamily}
+	if serviceIPFamily == ipfamily.DualStack {
+		families = []ipfamily.Family{ipfamily.IPv4, ipfamily.IPv6}
+	}
+	inUse := a.poolInUse(p)
+	var res float64
+	for _, family := range families {
+		size := poolCountForFamily(p, family)
+		if size == 0 {
+			return math.Inf(1)
+		}
+		res = math.Max(res, float64(inUse[family])/float64(size))
+	}
+	return res
+}
+
+// poolFree returns the number of free addresses of the given family in the
+// pool, the lowest of the two for dual stack services.
+func (a *Allocator) poolFree(p *config.Pool, serviceIPFamily ipfamily.Family) int64 {
+	families := []ipfamily.Family{serviceIPFamily}
+	if serviceIPFamily == ipfamily.DualStack {
+		families = []ipfamily.Family{ipfamily.IPv4, ipfamily.IPv6}
+	}
+	inUse := a.poolInUse(p)
+	res := int64(math.MaxInt64)
+	for _, family := range families {
+		if free := poolCountForFamily(p, family) - inUse[family]; free < res {
+			res = free
+		}
+	}
+	return res
+}
+
+// poolInUse returns the number of addresses in use in the pool, per family.
+func (a *Allocator) poolInUse(p *config.Pool) map[ipfamily.Family]int64 {
+	res := map[ipfamily.Family]int64{}
+	for ip := range a.poolIPsInUse[p.Name] {
+		res[ipfamily.ForAddress(net.ParseIP(ip))]++
+	}
+	return res
+}
+
+// poolPriority returns the priority of the pool for sorting, the pools
+// with no priority coming last.
+func poolPriority(p *config.Pool) int {
+	if p.ServiceAllocations == nil || p.ServiceAllocations.Priority == 0 {
+		return math.MaxInt
+	}
+	return p.ServiceAllocations.Priority
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// maxIPAMAttempts is the number of addresses of a pool proposed to the
+// external IPAM before giving up on the pool.
+const maxIPAMAttempts = 10
+
+// ErrRefused is returned by an IPAM refusing to reserve some addresses, for
+// example because they are owned by someone else. Other addresses of the
+// pool are tried instead.
+var ErrRefused = errors.New("addresses refused by the IPAM")
+
+// IPAM is an external address manager confirming the addresses assigned by
+// the allocator.
+type IPAM interface {
+	// Reserve reserves the ips for the service, returning an error
+	// wrapping ErrRefused if they can't be used. It must be idempotent,
+	// as the addresses of the existing services are reserved again when
+	// the allocator is rebuilt.
+	Reserve(svcKey, pool string, ips []net.IP) error
+	// Release releases the ips not used by any service anymore.
+	Release(svcKey, pool string, ips []net.IP)
+}
+
+// SetIPAM sets the external IPAM the addresses are reserved with before
+// being assigned, nil to disable it.
+func (a *Allocator) SetIPAM(ipam IPAM) {
+	a.ipam = ipam
+}
+
+// reserve reserves with the external IPAM the ips not used by any service
+// yet.
+func (a *Allocator) reserve(svcKey, pool string, ips []net.IP) error {
+	if a.ipam == nil {
+		return nil
+	}
+	var unused []net.IP
+	for _, ip := range ips {
+		if len(a.servicesOnIP[ip.String()]) == 0 {
+			unused = append(unused, ip)
+		}
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+	if err := a.ipam.Reserve(svcKey, pool, unused); err != nil {
+		return &allocationError{ReasonIPAM, fmt.Errorf("failed to reserve %q with the external IPAM: %w", unused, err)}
+	}
+	return nil
+}
+
+// release releases with the external IPAM the ips of the given allocation
+// not used by any service anymore.
+func (a *Allocator) release(svcKey string, alloc *alloc) {
+	if a.ipam == nil {
+		return
+	}
+	var unused []net.IP
+	for _, ip := range alloc.ips {
+		if len(a.servicesOnIP[ip.String()]) == 0 {
+			unused = append(unused, ip)
+		}
+	}
+	if len(unused) > 0 {
+		a.ipam.Release(svcKey, alloc.pool, unused)
+	}
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package allocator
+
+import (
+	"bytes"
+	"net"
+	"sort"
+)
+
+// ipAddr is the 16 bytes representation of an IP address, IPv4 addresses
+// being mapped into the IPv6 space so that both families sort together.
+type ipAddr [16]byte
+
+func toIPAddr(ip net.IP) ipAddr {
+	var res ipAddr
+	copy(res[:], ip.To16())
+	return res
+}
+
+func (a ipAddr) cmp(b ipAddr) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+// next returns the address following a, and false if a is the last
+// address of the space.
+func (a ipAddr) next() (ipAddr, bool) {
+	for i := len(a) - 1; i >= 0; i-- {
+		a[i]++
+		if a[i] != 0 {
+			return a, true
+		}
+	}
+	return a, false
+}
+
+// prev returns the address preceding a, and false if a is the first
+// address of the space.
+func (a ipAddr) prev() (ipAddr, bool) {
+	for i := len(a) - 1; i >= 0; i-- {
+		a[i]--
+		if a[i] != 0xff {
+			return a, true
+		}
+	}
+	return a, false
+}
+
+// ipRange is an inclusive range of addresses.
+type ipRange struct {
+	first ipAddr
+	last  ipAddr
+}
+
+// ipSet tracks a set of addresses as a sorted list of disjoint and
+// non adjacent ranges, so that membership lookups and the search for
+// the next address not in the set are logarithmic in the number of
+// ranges instead of linear in the number of addresses.
+type ipSet struct {
+	ranges []ipRange
+}
+
+// search returns the index of the first range ending at or after ip.
+func (s *ipSet) search(ip ipAddr) int {
+	return sort.Search(len(s.ranges), func(i int) bool {
+		return s.ranges[i].last.cmp(ip) >= 0
+	})
+}
+
+// rangeFor returns the range containing ip, if any.
+func (s *ipSet) rangeFor(ip ipAddr) (ipRange, bool) {
+	i := s.search(ip)
+	if i < len(s.ranges) && s.ranges[i].first.cmp(ip) <= 0 {
+		return s.ranges[i], true
+	}
+	return ipRange{}, false
+}
+
+// add inserts ip in the set, merging it with the adjacent ranges.
+func (s *ipSet) add(ip ipAddr) {
+	i := s.search(ip)
+	if i < len(s.ranges) && s.ranges[i].first.cmp(ip) <= 0 {
+		return
+	}
+	mergesPrev := false
+	if i > 0 {
+		if n, ok := s.ranges[i-1].last.next(); ok && n == ip {
+			mergesPrev = true
+		}
+	}
+	mergesNext := false
+	if i < len(s.ranges) {
+		if p, ok := s.ranges[i].first.prev(); ok && p == ip {
+			mergesNext = true
+		}
+	}
+
+	switch {
+	case mergesPrev && mergesNext:
+		s.ranges[i-1].last = s.ranges[i].last
+		s.ranges = append(s.ranges[:i], s.ranges[i+1:]...)
+	case mergesPrev:
+		s.ranges[i-1].last = ip
+	case mergesNext:
+		s.ranges[i].first = ip
+	default:
+		s.ranges = append(s.ranges, ipRange{})
+		copy(s.ranges[i+1:], s.ranges[i:])
+		s.ranges[i] = ipRange{first: ip, last: ip}
+	}
+}
+
+// remove deletes ip from the set, splitting the range containing it
+// if needed.
+func (s *ipSet) remove(ip ipAddr) {
+	i := s.search(ip)
+	if i == len(s.ranges) || s.ranges[i].first.cmp(ip) > 0 {
+		return
+	}
+	r := s.ranges[i]
+	switch {
+	case r.first == ip && r.last == ip:
+		s.ranges = append(s.ranges[:i], s.ranges[i+1:]...)
+	case r.first == ip:
+		s.ranges[i].first, _ = ip.next()
+	case r.last == ip:
+		s.ranges[i].last, _ = ip.prev()
+	default:
+		before, _ := ip.prev()
+		after, _ := ip.next()
+		s.ranges[i].last = before
+		s.ranges = append(s.ranges, ipRange{})
+		copy(s.ranges[i+2:], s.ranges[i+1:])
+		s.ranges[i+1] = ipRange{first: after, last: r.last}
+	}
+}
+
+// cidrRange returns the range of addresses covered by cidr.
+func cidrRange(cidr *net.IPNet) ipRange {
+	first := toIPAddr(cidr.IP.Mask(cidr.Mask))
+	last := first
+	mask := cidr.Mask
+	offset := len(last) - len(mask)
+	for i := range mask {
+		last[offset+i] |= ^mask[i]
+	}
+	return ipRange{first: first, last: last}
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package allocator
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestIPSet(t *testing.T) {
+	addr := func(s string) ipAddr {
+		return toIPAddr(net.ParseIP(s))
+	}
+	rng := func(first, last string) ipRange {
+		return ipRange{first: addr(first), last: addr(last)}
+	}
+
+	tests := []struct {
+		desc     string
+		add      []string
+		remove   []string
+		expected []ipRange
+	}{
+		{
+			desc:     "single ip",
+			add:      []string{"1.2.3.4"},
+			expected: []ipRange{rng("1.2.3.4", "1.2.3.4")},
+		},
+		{
+			desc:     "adjacent ips are merged",
+			add:      []string{"1.2.3.4", "1.2.3.6", "1.2.3.5"},
+			expected: []ipRange{rng("1.2.3.4", "1.2.3.6")},
+		},
+		{
+			desc:     "merge across a byte boundary",
+			add:      []string{"1.2.3.255", "1.2.4.0"},
+			expected: []ipRange{rng("1.2.3.255", "1.2.4.0")},
+		},
+		{
+			desc:     "disjoint ips stay sorted",
+			add:      []string{"1.2.3.10", "1.2.3.1", "1000::1", "1.2.3.5"},
+			expected: []ipRange{rng("1.2.3.1", "1.2.3.1"), rng("1.2.3.5", "1.2.3.5"), rng("1.2.3.10", "1.2.3.10"), rng("1000::1", "1000::1")},
+		},
+		{
+			desc:     "remove splits a range",
+			add:      []string{"1.2.3.4", "1.2.3.5", "1.2.3.6"},
+			remove:   []string{"1.2.3.5"},
+			expected: []ipRange{rng("1.2.3.4", "1.2.3.4"), rng("1.2.3.6", "1.2.3.6")},
+		},
+		{
+			desc:     "remove the edges",
+			add:      []string{"1.2.3.4", "1.2.3.5", "1.2.3.6"},
+			remove:   []string{"1.2.3.4", "1.2.3.6", "1.2.3.7"},
+			expected: []ipRange{rng("1.2.3.5", "1.2.3.5")},
+		},
+		{
+			desc:     "remove everything",
+			add:      []string{"1.2.3.4"},
+			remove:   []string{"1.2.3.4"},
+			expected: []ipRange{},
+		},
+	}
+
+	for _, test := range tests {
+		s := &ipSet{}
+		for _, ip := range test.add {
+			s.add(addr(ip))
+		}
+		for _, ip := range test.remove {
+			s.remove(addr(ip))
+		}
+		if len(s.ranges) == 0 && len(test.expected) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(s.ranges, test.expected) {
+			t.Errorf("%s: got ranges %v, expected %v", test.desc, s.ranges, test.expected)
+		}
+		for _, ip := range test.remove {
+			if _, ok := s.rangeFor(addr(ip)); ok {
+				t.Errorf("%s: %s still in the set after removal", test.desc, ip)
+			}
+		}
+	}
+}
+
+func TestCIDRRange(t *testing.T) {
+	tests := []struct {
+		cidr  string
+		first string
+		last  string
+	}{
+		{"10.0.0.0/16", "10.0.0.0", "10.0.255.255"},
+		{"1.2.3.4/32", "1.2.3.4", "1.2.3.4"},
+		{"1000::/112", "1000::", "1000::ffff"},
+	}
+	for _, test := range tests {
+		r := cidrRange(ipnet(test.cidr))
+		if r.first != toIPAddr(net.ParseIP(test.first)) || r.last != toIPAddr(net.ParseIP(test.last)) {
+			t.Errorf("%s: got range %v, expected %s-%s", test.cidr, r, test.first, test.last)
+		}
+	}
+}
@@ -2,12 +2,24 @@
 
 package allocator
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	outcomeSuccess   = "success"
+	outcomeExhausted = "exhausted"
+	outcomeError     = "error"
+)
 
 var stats = struct {
 	poolCapacity  *prometheus.GaugeVec
 	poolActive    *prometheus.GaugeVec
 	poolAllocated *prometheus.GaugeVec
+	allocDuration *prometheus.HistogramVec
 }{
 	poolCapacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "metallb",
@@ -33,10 +45,72 @@ var stats = struct {
 	}, []string{
 		"pool",
 	}),
+	allocDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "metallb",
+		Subsystem: "allocator",
+		Name:      "allocation_duration_seconds",
+		Help:      "Time spent allocating an IP to a service, per outcome",
+		Buckets:   prometheus.ExponentialBuckets(0.00001, 4, 10),
+	}, []string{
+		"outcome",
+	}),
 }
 
 func init() {
 	prometheus.MustRegister(stats.poolCapacity)
 	prometheus.MustRegister(stats.poolActive)
 	prometheus.MustRegister(stats.poolAllocated)
+	prometheus.MustRegister(stats.allocDuration)
+}
+
+// Reasons why a service can't get an IP, as returned by PendingReason.
+const (
+	ReasonExhausted      = "exhausted"
+	ReasonNoPool         = "no-pool"
+	ReasonFamilyMismatch = "family-mismatch"
+	ReasonIPTaken        = "explicit-ip-taken"
+	ReasonIPAM           = "ipam"
+)
+
+// allocationError is an allocation failure classified with the reason why
+// the service can't get an IP. It doesn't change the message of the
+// underlying error.
+type allocationError struct {
+	reason string
+	err    error
+}
+
+func (e *allocationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *allocationError) Unwrap() error {
+	return e.err
+}
+
+// PendingReason returns the reason why the allocation failed with err,
+// or an empty string if err does not come from a rejection of the
+// allocator.
+func PendingReason(err error) string {
+	var allocErr *allocationError
+	switch {
+	case errors.As(err, &allocErr):
+		return allocErr.reason
+	case errors.Is(err, errNoAvailableIPs):
+		return ReasonExhausted
+	}
+	return ""
+}
+
+// observeAllocation records the time elapsed since start, labelled with
+// the outcome derived from err.
+func observeAllocation(start time.Time, err error) {
+	outcome := outcomeSuccess
+	switch {
+	case errors.Is(err, errNoAvailableIPs):
+		outcome = outcomeExhausted
+	case err != nil:
+		outcome = outcomeError
+	}
+	stats.allocDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
 }
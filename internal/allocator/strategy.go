@@ -0,0 +1,60 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package allocator
+
+import (
+	"hash/fnv"
+	"math/big"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Strategy is the name of the strategy used to pick the address handed out
+// to a service among the free addresses of a CIDR.
+type Strategy string
+
+const (
+	// StrategyLowest assigns the lowest free address.
+	StrategyLowest Strategy = "lowest"
+	// StrategyHash assigns the address derived from a hash of the
+	// service namespace, name and UID, probing forward on collisions, so
+	// that a given service always gets the same address from an empty pool.
+	StrategyHash Strategy = "hash"
+)
+
+// strategy returns where the search for a free address of r starts for
+// the given service. The search continues up to the end of r, and then
+// wraps around to its start.
+type strategy interface {
+	start(r ipRange, svcKey string, svc *v1.Service) ipAddr
+}
+
+var strategies = map[Strategy]strategy{
+	StrategyLowest: lowestStrategy{},
+	StrategyHash:   hashStrategy{},
+}
+
+type lowestStrategy struct{}
+
+func (lowestStrategy) start(r ipRange, _ string, _ *v1.Service) ipAddr {
+	return r.first
+}
+
+type hashStrategy struct{}
+
+func (hashStrategy) start(r ipRange, svcKey string, svc *v1.Service) ipAddr {
+	h := fnv.New64a()
+	h.Write([]byte(svcKey))
+	if svc != nil {
+		h.Write([]byte(svc.UID))
+	}
+	first := new(big.Int).SetBytes(r.first[:])
+	size := new(big.Int).SetBytes(r.last[:])
+	size.Sub(size, first).Add(size, big.NewInt(1))
+
+	offset := new(big.Int).SetUint64(h.Sum64())
+	offset.Mod(offset, size)
+	var res ipAddr
+	first.Add(first, offset).FillBytes(res[:])
+	return res
+}
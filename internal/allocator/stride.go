@@ -0,0 +1,61 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package allocator
+
+import (
+	"encoding/binary"
+	"net"
+
+	"go.universe.tf/metallb/internal/config"
+)
+
+// stridedCount returns how many of the size addresses starting from first
+// satisfy the allocation stride of the pool.
+func stridedCount(p *config.Pool, first net.IP, size int64) int64 {
+	if p.AllocationStride <= 1 {
+		return size
+	}
+	d := int64(p.StrideDistance(first))
+	if d >= size {
+		return 0
+	}
+	return (size-1-d)/int64(p.AllocationStride) + 1
+}
+
+// avoidedCount returns how many of the addresses of the IPv4 cidr with one
+// of the avoided last octets satisfy the allocation stride of the pool.
+func avoidedCount(p *config.Pool, cidr *net.IPNet, avoided []uint8) int64 {
+	ip := cidr.IP.Mask(cidr.Mask).To4()
+	if ip == nil {
+		return 0
+	}
+	ones, _ := cidr.Mask.Size()
+	base := binary.BigEndian.Uint32(ip)
+	var res int64
+	buf := make(net.IP, net.IPv4len)
+	if ones > 24 {
+		// Ranges smaller than /24 contain each avoided last octet at
+		// most once.
+		for i := uint32(0); i < 1<<(32-ones); i++ {
+			binary.BigEndian.PutUint32(buf, base+i)
+			if p.AvoidsIP(buf) && p.StrideDistance(buf) == 0 {
+				res++
+			}
+		}
+		return res
+	}
+	if p.AllocationStride <= 1 {
+		// Each avoided last octet occurs once for each /24 present in
+		// the range.
+		return int64(1<<(24-ones)) * int64(len(avoided))
+	}
+	for i := uint32(0); i < 1<<(24-ones); i++ {
+		for _, last := range avoided {
+			binary.BigEndian.PutUint32(buf, base+i<<8+uint32(last))
+			if p.StrideDistance(buf) == 0 {
+				res++
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package allocator
+
+import (
+	"sort"
+
+	"go.universe.tf/metallb/internal/config"
+)
+
+// SetServiceZone sets the topology zone the pools associated with are
+// preferred when allocating to the service. An empty zone clears it.
+func (a *Allocator) SetServiceZone(svcKey, zone string) {
+	if zone == "" {
+		delete(a.serviceZones, svcKey)
+		return
+	}
+	a.serviceZones[svcKey] = zone
+}
+
+// preferZone moves the pools associated with the zone of the service
+// before the other ones with the same priority, keeping their order
+// otherwise. The pools being already sorted by priority, the other pools
+// are still tried when none of the pools of the zone can be allocated from.
+func (a *Allocator) preferZone(pools []*config.Pool, svcKey string) {
+	zone := a.serviceZones[svcKey]
+	if zone == "" {
+		return
+	}
+	sort.SliceStable(pools, func(i, j int) bool {
+		if pi, pj := poolPriority(pools[i]), poolPriority(pools[j]); pi != pj {
+			return pi < pj
+		}
+		return pools[i].Zone == zone && pools[j].Zone != zone
+	})
+}
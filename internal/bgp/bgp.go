@@ -10,6 +10,7 @@ import (
 
 	"github.com/go-kit/log"
 	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/ipfamily"
 )
 
 // Advertisement represents one network path and its BGP attributes.
@@ -24,6 +25,18 @@ type Advertisement struct {
 	// Used to declare the intent of announcing IPs
 	// only to the BGPPeers in this list.
 	Peers []string
+	// When set, the prefix is also exported as an EVPN type-5 route.
+	EVPN *config.EVPN
+	// The ORIGIN attribute of the route, empty means IGP.
+	Origin string
+	// The bandwidth in Mbps of the link-bandwidth extended community,
+	// 0 means not set.
+	Bandwidth uint32
+	// The value of the color extended community, 0 means not set.
+	Color uint32
+	// When set, the more specific prefixes covered by Prefix are not
+	// advertised to the same peers.
+	SummaryOnly bool
 }
 
 // Equal returns true if a and b are equivalent advertisements.
@@ -34,11 +47,27 @@ func (a *Advertisement) Equal(b *Advertisement) bool {
 	if a.LocalPref != b.LocalPref {
 		return false
 	}
+	if a.Origin != b.Origin {
+		return false
+	}
+	if a.Bandwidth != b.Bandwidth {
+		return false
+	}
+	if a.Color != b.Color {
+		return false
+	}
+	if a.SummaryOnly != b.SummaryOnly {
+		return false
+	}
 
 	if !reflect.DeepEqual(a.Peers, b.Peers) {
 		return false
 	}
 
+	if !reflect.DeepEqual(a.EVPN, b.EVPN) {
+		return false
+	}
+
 	return reflect.DeepEqual(a.Communities, b.Communities)
 }
 
@@ -60,9 +89,17 @@ type Session interface {
 	Set(advs ...*Advertisement) error
 }
 
+// EstablishedReporter is implemented by the sessions able to tell if they
+// are established with their peer.
+type EstablishedReporter interface {
+	Established() bool
+}
+
 type SessionParameters struct {
 	PeerAddress   string
 	SourceAddress net.IP
+	SourcePorts   *config.PortRange
+	TCPKeepalive  *config.TCPKeepalive
 	MyASN         uint32
 	RouterID      net.IP
 	PeerASN       uint32
@@ -72,8 +109,14 @@ type SessionParameters struct {
 	CurrentNode   string
 	BFDProfile    string
 	EBGPMultiHop  bool
+	MinimumTTL    *uint32
 	VRFName       string
 	SessionName   string
+	ImportFilter  *config.ImportFilter
+	AddPath       *config.AddPath
+	NextHopSelf   bool
+	// The families the IPs are advertised with, empty means both.
+	AddressFamilies []ipfamily.Family
 }
 type SessionManager interface {
 	NewSession(logger log.Logger, args SessionParameters) (Session, error)
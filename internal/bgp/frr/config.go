@@ -48,6 +48,17 @@ type routerConfig struct {
 	VRF          string
 	IPV4Prefixes []string
 	IPV6Prefixes []string
+	EVPN         *evpnConfig
+}
+
+// evpnConfig holds the settings used to export the prefixes of a vrf
+// router as EVPN type-5 routes.
+type evpnConfig struct {
+	VNI                uint32
+	RouteDistinguisher string
+	RouteTarget        string
+	IPV4Prefixes       []string
+	IPV6Prefixes       []string
 }
 
 type BFDProfile struct {
@@ -77,6 +88,30 @@ type neighborConfig struct {
 	VRFName             string
 	HasV4Advertisements bool
 	HasV6Advertisements bool
+	ImportFilter        *importFilterConfig
+	// AddPathTx sends all the paths of the prefixes to the neighbor.
+	AddPathTx bool
+	// AddPathRxDisabled refuses the additional paths from the neighbor,
+	// which are accepted by default once the capability is negotiated.
+	AddPathRxDisabled bool
+	// NextHopSelf sets the speaker as the next hop of all the routes
+	// sent to the neighbor.
+	NextHopSelf bool
+	// TTLSecurityHops is the number of hops the neighbor is away from, the
+	// packets received with a lower TTL being dropped. Zero disables it.
+	TTLSecurityHops uint32
+	// IPv4Enabled and IPv6Enabled activate the address families of the
+	// neighbor.
+	IPv4Enabled bool
+	IPv6Enabled bool
+}
+
+// importFilterConfig holds the routes accepted from a neighbor, the
+// prefixes being rendered as prefix list entries.
+type importFilterConfig struct {
+	IPV4Prefixes []string
+	IPV6Prefixes []string
+	Communities  []string
 }
 
 func (n *neighborConfig) ID() string {
@@ -91,6 +126,9 @@ type advertisementConfig struct {
 	Prefix      string
 	Communities []string
 	LocalPref   uint32
+	Origin      string
+	Bandwidth   uint32
+	Color       uint32
 }
 
 // routerName() defines the format of the key of the "Routers" map in the
@@ -129,6 +167,15 @@ func templateConfig(data interface{}) (string, error) {
 			"localPrefPrefixList": func(neighbor *neighborConfig, localPreference uint32) string {
 				return fmt.Sprintf("%s-%d-%s-localpref-prefixes", neighbor.ID(), localPreference, neighbor.IPFamily)
 			},
+			"originPrefixList": func(neighbor *neighborConfig, origin string) string {
+				return fmt.Sprintf("%s-%s-%s-origin-prefixes", neighbor.ID(), origin, neighbor.IPFamily)
+			},
+			"bandwidthPrefixList": func(neighbor *neighborConfig, bandwidth uint32) string {
+				return fmt.Sprintf("%s-%d-%s-bandwidth-prefixes", neighbor.ID(), bandwidth, neighbor.IPFamily)
+			},
+			"colorPrefixList": func(neighbor *neighborConfig, color uint32) string {
+				return fmt.Sprintf("%s-%d-%s-color-prefixes", neighbor.ID(), color, neighbor.IPFamily)
+			},
 			"communityPrefixList": func(neighbor *neighborConfig, community string) string {
 				return fmt.Sprintf("%s-%s-%s-community-prefixes", neighbor.ID(), community, neighbor.IPFamily)
 			},
@@ -219,7 +266,12 @@ func generateAndReloadConfigFile(config *frrConfig, l log.Logger) error {
 		return err
 	}
 
+	before := statusFileModTime()
 	err = reloadConfig()
+	if err == nil && reloader.timeout > 0 {
+		err = waitForReload(before, reloader.timeout)
+	}
+	recordReload(err)
 	if err != nil {
 		level.Error(l).Log("op", "reload", "error", err, "cause", "reload", "config", config)
 		return err
@@ -239,6 +291,7 @@ func debouncer(body func(config *frrConfig) error,
 		var config *frrConfig
 		var timeOut <-chan time.Time
 		timerSet := false
+		failures := 0
 		for {
 			select {
 			case newCfg, ok := <-reload:
@@ -263,10 +316,16 @@ func debouncer(body func(config *frrConfig) error,
 			case <-timeOut:
 				err := body(config)
 				if err != nil {
+					failures++
+					level.Info(l).Log("op", "reload", "action", "retry", "attempt", failures, "in", failureRetryInterval)
 					timeOut = time.After(failureRetryInterval)
 					timerSet = true
 					continue
 				}
+				if failures > 0 {
+					level.Info(l).Log("op", "reload", "action", "recovered", "failedAttempts", failures)
+				}
+				failures = 0
 				timerSet = false
 			}
 		}
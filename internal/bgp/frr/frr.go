@@ -28,6 +28,9 @@ type sessionManager struct {
 	bfdProfiles  []BFDProfile
 	reloadConfig chan reloadEvent
 	logLevel     string
+	// holdDownUntil is when the advertisements withheld after FRR
+	// started are advertised again.
+	holdDownUntil time.Time
 	sync.Mutex
 }
 
@@ -52,6 +55,48 @@ func sessionName(s session) string {
 	return baseName + "/" + s.VRFName
 }
 
+// enabledFamilies returns whether the IPv4 and the IPv6 address families are
+// activated for a session advertising the given families, both of them when
+// none is given.
+func enabledFamilies(families []ipfamily.Family) (bool, bool) {
+	if len(families) == 0 {
+		return true, true
+	}
+	v4, v6 := false, false
+	for _, f := range families {
+		switch f {
+		case ipfamily.IPv4:
+			v4 = true
+		case ipfamily.IPv6:
+			v6 = true
+		}
+	}
+	return v4, v6
+}
+
+// importFilterFor renders the import filter of a session.
+func importFilterFor(f *metallbconfig.ImportFilter) *importFilterConfig {
+	res := &importFilterConfig{}
+	for _, p := range f.Prefixes {
+		entry := p.Prefix.String()
+		if p.GE != 0 {
+			entry += fmt.Sprintf(" ge %d", p.GE)
+		}
+		if p.LE != 0 {
+			entry += fmt.Sprintf(" le %d", p.LE)
+		}
+		if ipfamily.ForCIDR(p.Prefix) == ipfamily.IPv4 {
+			res.IPV4Prefixes = append(res.IPV4Prefixes, entry)
+			continue
+		}
+		res.IPV6Prefixes = append(res.IPV6Prefixes, entry)
+	}
+	for _, c := range f.Communities {
+		res.Communities = append(res.Communities, metallbconfig.CommunityToString(c))
+	}
+	return res
+}
+
 func validate(adv *bgp.Advertisement) error {
 	if len(adv.Communities) > 63 {
 		return fmt.Errorf("max supported communities is 63, got %d", len(adv.Communities))
@@ -180,6 +225,7 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 		return nil, err
 	}
 
+	holdingDown := sm.holdingDown()
 	config := &frrConfig{
 		Hostname:    hostname,
 		Loglevel:    sm.logLevel,
@@ -193,6 +239,9 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 		vrf          string
 		ipV4Prefixes map[string]string
 		ipV6Prefixes map[string]string
+		evpn         *metallbconfig.EVPN
+		evpnV4       map[string]string
+		evpnV6       map[string]string
 	}
 
 	routers := make(map[string]*router)
@@ -215,6 +264,8 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 				neighbors:    make(map[string]*neighborConfig),
 				ipV4Prefixes: make(map[string]string),
 				ipV6Prefixes: make(map[string]string),
+				evpnV4:       make(map[string]string),
+				evpnV6:       make(map[string]string),
 				vrf:          s.VRFName,
 			}
 			if s.RouterID != nil {
@@ -253,6 +304,19 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 			if s.SourceAddress != nil {
 				neighbor.SrcAddr = s.SourceAddress.String()
 			}
+			if s.ImportFilter != nil {
+				neighbor.ImportFilter = importFilterFor(s.ImportFilter)
+			}
+			if s.AddPath != nil {
+				neighbor.AddPathTx = s.AddPath.Send
+				neighbor.AddPathRxDisabled = !s.AddPath.Receive
+			}
+			neighbor.NextHopSelf = s.NextHopSelf
+			neighbor.IPv4Enabled, neighbor.IPv6Enabled = enabledFamilies(s.AddressFamilies)
+			if s.MinimumTTL != nil {
+				// FRR drops the packets with a TTL lower than 256 minus the hops.
+				neighbor.TTLSecurityHops = 256 - *s.MinimumTTL
+			}
 			rout.neighbors[neighborName] = neighbor
 		}
 
@@ -260,6 +324,9 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 		   duplicate prefixes and can, therefore, just add them to the
 		   'neighbor.Advertisements' list. */
 		for _, adv := range s.advertised {
+			if holdingDown {
+				break
+			}
 			if !adv.MatchesPeer(s.SessionName) {
 				continue
 			}
@@ -280,6 +347,9 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 				Prefix:      prefix,
 				Communities: communities,
 				LocalPref:   adv.LocalPref,
+				Origin:      adv.Origin,
+				Bandwidth:   adv.Bandwidth,
+				Color:       adv.Color,
 			}
 
 			neighbor.Advertisements = append(neighbor.Advertisements, &advConfig)
@@ -291,6 +361,22 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 				rout.ipV6Prefixes[prefix] = prefix
 				neighbor.HasV6Advertisements = true
 			}
+
+			// EVPN type-5 routes are exported from a vrf, the advertisements
+			// towards the sessions in the default vrf are plain unicast ones.
+			if adv.EVPN == nil || rout.vrf == "" {
+				continue
+			}
+			if rout.evpn != nil && *rout.evpn != *adv.EVPN {
+				return nil, fmt.Errorf("conflicting evpn settings for vrf %s: %+v, %+v", rout.vrf, *rout.evpn, *adv.EVPN)
+			}
+			rout.evpn = adv.EVPN
+			switch family {
+			case ipfamily.IPv4:
+				rout.evpnV4[prefix] = prefix
+			case ipfamily.IPv6:
+				rout.evpnV6[prefix] = prefix
+			}
 		}
 	}
 
@@ -303,6 +389,15 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 			IPV4Prefixes: sortMap(r.ipV4Prefixes),
 			IPV6Prefixes: sortMap(r.ipV6Prefixes),
 		}
+		if r.evpn != nil {
+			toAdd.EVPN = &evpnConfig{
+				VNI:                r.evpn.VNI,
+				RouteDistinguisher: r.evpn.RouteDistinguisher,
+				RouteTarget:        r.evpn.RouteTarget,
+				IPV4Prefixes:       sortMap(r.evpnV4),
+				IPV6Prefixes:       sortMap(r.evpnV6),
+			}
+		}
 		config.Routers = append(config.Routers, toAdd)
 	}
 	return config, nil
@@ -322,10 +417,15 @@ func NewSessionManager(l log.Logger, logLevel logging.Level) *sessionManager {
 		return generateAndReloadConfigFile(config, l)
 	}
 
-	debouncer(reload, res.reloadConfig, debounceTimeout, failureTimeout, l)
+	reloader = reloaderOptionsFromEnv(l)
+	debouncer(reload, res.reloadConfig, debounceTimeout, reloader.retryInterval, l)
 
 	reloadValidator(l, res.reloadConfig)
 
+	if restartHoldDown > 0 {
+		res.watchFRRStart(l)
+	}
+
 	return res
 }
 
@@ -341,7 +441,7 @@ func reloadValidator(l log.Logger, reload chan<- reloadEvent) {
 	}()
 }
 
-const statusFileName = "/etc/frr_reloader/.status"
+var statusFileName = "/etc/frr_reloader/.status"
 
 func validateReload(l log.Logger, prevReloadTimeStamp *string, reload chan<- reloadEvent) {
 	bytes, err := os.ReadFile(statusFileName)
@@ -16,7 +16,9 @@ import (
 	"github.com/go-kit/log"
 	"go.universe.tf/metallb/internal/bgp"
 	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/ipfamily"
 	"go.universe.tf/metallb/internal/logging"
+	"go.universe.tf/metallb/internal/pointer"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
@@ -125,6 +127,92 @@ func TestSingleEBGPSessionMultiHop(t *testing.T) {
 	testCheckConfigFile(t)
 }
 
+func TestSingleSessionWithImportFilter(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := NewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer",
+			ImportFilter: &config.ImportFilter{
+				Prefixes: []config.ImportPrefix{
+					{Prefix: &net.IPNet{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(8, 32)}, GE: 24, LE: 28},
+					{Prefix: &net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(32, 128)}, LE: 64},
+				},
+				Communities: []uint32{0xfde80001},
+			}})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	testCheckConfigFile(t)
+}
+
+func TestSingleSessionWithAddPath(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := NewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer",
+			AddPath:       &config.AddPath{Send: true},
+		})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	testCheckConfigFile(t)
+}
+
+func TestSingleSessionWithNextHopSelf(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := NewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       100,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer",
+			NextHopSelf:   true,
+		})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	testCheckConfigFile(t)
+}
+
 func TestSingleEBGPSessionOneHop(t *testing.T) {
 	testSetup(t)
 
@@ -493,6 +581,246 @@ func TestSingleAdvertisement(t *testing.T) {
 	testCheckConfigFile(t)
 }
 
+func TestAdvertisementOrigin(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := NewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	adv1 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.10"),
+			Mask: net.CIDRMask(32, 32),
+		},
+		Origin: config.OriginIncomplete,
+	}
+	adv2 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("2001:db8::10"),
+			Mask: net.CIDRMask(128, 128),
+		},
+		Origin: config.OriginEGP,
+	}
+	adv3 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.11"),
+			Mask: net.CIDRMask(32, 32),
+		},
+	}
+
+	err = session.Set(adv1, adv2, adv3)
+	if err != nil {
+		t.Fatalf("Could not advertise prefix: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestAdvertisementBandwidth(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := NewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	adv1 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.10"),
+			Mask: net.CIDRMask(32, 32),
+		},
+		Bandwidth: 300,
+	}
+	adv2 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("2001:db8::10"),
+			Mask: net.CIDRMask(128, 128),
+		},
+		Bandwidth: 100,
+	}
+	adv3 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.11"),
+			Mask: net.CIDRMask(32, 32),
+		},
+	}
+
+	err = session.Set(adv1, adv2, adv3)
+	if err != nil {
+		t.Fatalf("Could not advertise prefix: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestAdvertisementColor(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := NewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	adv1 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.10"),
+			Mask: net.CIDRMask(32, 32),
+		},
+		Color: 100,
+	}
+	adv2 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("2001:db8::10"),
+			Mask: net.CIDRMask(128, 128),
+		},
+		Color:       4294967295,
+		Communities: []uint32{1111, 2222},
+	}
+	adv3 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.11"),
+			Mask: net.CIDRMask(32, 32),
+		},
+	}
+
+	err = session.Set(adv1, adv2, adv3)
+	if err != nil {
+		t.Fatalf("Could not advertise prefix: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestMinimumTTL(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := NewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			EBGPMultiHop:  true,
+			MinimumTTL:    pointer.Uint32Ptr(253),
+			SessionName:   "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	session1, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.253:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       300,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			MinimumTTL:    pointer.Uint32Ptr(255),
+			SessionName:   "test-peer1"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session1.Close()
+
+	testCheckConfigFile(t)
+}
+
+func TestAddressFamilies(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := NewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:     "10.2.2.254:179",
+			SourceAddress:   net.ParseIP("10.1.1.254"),
+			MyASN:           100,
+			RouterID:        net.ParseIP("10.1.1.254"),
+			PeerASN:         200,
+			HoldTime:        time.Second,
+			KeepAliveTime:   time.Second,
+			CurrentNode:     "hostname",
+			AddressFamilies: []ipfamily.Family{ipfamily.IPv4},
+			SessionName:     "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	session1, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:     "[2001:db8::1]:179",
+			MyASN:           100,
+			RouterID:        net.ParseIP("10.1.1.254"),
+			PeerASN:         300,
+			HoldTime:        time.Second,
+			KeepAliveTime:   time.Second,
+			CurrentNode:     "hostname",
+			AddressFamilies: []ipfamily.Family{ipfamily.IPv6},
+			SessionName:     "test-peer1"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session1.Close()
+
+	testCheckConfigFile(t)
+}
+
 func TestSingleAdvertisementNoRouterID(t *testing.T) {
 	testSetup(t)
 
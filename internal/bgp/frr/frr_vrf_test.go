@@ -654,3 +654,104 @@ func TestTwoAdvertisementsTwoSessionsVRFWithPeerSelector(t *testing.T) {
 
 	testCheckConfigFile(t)
 }
+
+func TestAdvertisementEVPN(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := NewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer",
+			VRFName:       "red"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	evpn := &config.EVPN{
+		VNI:                100,
+		RouteDistinguisher: "10.1.1.254:100",
+		RouteTarget:        "100:100",
+	}
+	adv1 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.10"),
+			Mask: net.CIDRMask(32, 32),
+		},
+		EVPN: evpn,
+	}
+	adv2 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("2001:db8::10"),
+			Mask: net.CIDRMask(128, 128),
+		},
+		EVPN: evpn,
+	}
+	adv3 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.11"),
+			Mask: net.CIDRMask(32, 32),
+		},
+	}
+
+	err = session.Set(adv1, adv2, adv3)
+	if err != nil {
+		t.Fatalf("Could not advertise prefix: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestAdvertisementEVPNConflict(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := NewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer",
+			VRFName:       "red"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	adv1 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.10"),
+			Mask: net.CIDRMask(32, 32),
+		},
+		EVPN: &config.EVPN{VNI: 100},
+	}
+	adv2 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.11"),
+			Mask: net.CIDRMask(32, 32),
+		},
+		EVPN: &config.EVPN{VNI: 200},
+	}
+
+	err = session.Set(adv1, adv2)
+	if err == nil {
+		t.Fatalf("Expected error for conflicting evpn settings")
+	}
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// restartHoldDown is how long the advertisements are withheld from the
+// peers after FRR starts. Zero advertises them right away.
+var restartHoldDown time.Duration
+
+// SetRestartHoldDown sets how long the advertisements are withheld from the
+// peers after FRR (re)starts, giving it time to establish the sessions and
+// learn the routes before announcing the services. It must be called before
+// creating the session manager.
+func SetRestartHoldDown(d time.Duration) {
+	restartHoldDown = d
+}
+
+// procDir is where the FRR processes are looked for. The speaker shares the
+// process namespace of the pod with the FRR container.
+var procDir = "/proc"
+
+// bgpdPollInterval is how often the bgpd process is checked for restarts.
+var bgpdPollInterval = 5 * time.Second
+
+// userHZ is the unit of the start time of the processes in /proc.
+const userHZ = 100
+
+// watchFRRStart starts the hold-down each time a new bgpd process is found,
+// for the part of the hold-down not already elapsed since it started.
+func (sm *sessionManager) watchFRRStart(l log.Logger) {
+	go func() {
+		lastPid := 0
+		for {
+			pid, age, err := bgpdProcess()
+			if err != nil {
+				level.Error(l).Log("op", "holdDown", "error", err, "msg", "failed to check the bgpd process")
+			} else if pid != 0 && pid != lastPid {
+				lastPid = pid
+				if remaining := restartHoldDown - age; remaining > 0 {
+					sm.holdDown(l, remaining)
+				}
+			}
+			time.Sleep(bgpdPollInterval)
+		}
+	}()
+}
+
+// holdDown withholds the advertisements for d, then advertises them again.
+func (sm *sessionManager) holdDown(l log.Logger, d time.Duration) {
+	sm.Lock()
+	defer sm.Unlock()
+
+	sm.holdDownUntil = time.Now().Add(d)
+	level.Info(l).Log("op", "holdDown", "duration", d, "msg", "FRR started, withholding the advertisements")
+	sm.reloadHoldDown(l)
+
+	time.AfterFunc(d, func() {
+		sm.Lock()
+		defer sm.Unlock()
+		if sm.holdingDown() {
+			// Extended by a later restart.
+			return
+		}
+		level.Info(l).Log("op", "holdDown", "msg", "hold-down over, advertising")
+		sm.reloadHoldDown(l)
+	})
+}
+
+// holdingDown tells if the advertisements are withheld. It must be called
+// with the session manager locked.
+func (sm *sessionManager) holdingDown() bool {
+	return time.Now().Before(sm.holdDownUntil)
+}
+
+func (sm *sessionManager) reloadHoldDown(l log.Logger) {
+	config, err := sm.createConfig()
+	if err != nil {
+		level.Error(l).Log("op", "holdDown", "error", err, "msg", "failed to create the configuration")
+		return
+	}
+	sm.reloadConfig <- reloadEvent{config: config}
+}
+
+// bgpdProcess returns the pid of the bgpd process and how long ago it
+// started, or a zero pid if it is not running.
+func bgpdProcess() (int, time.Duration, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(procDir, e.Name(), "comm"))
+		if err != nil || strings.TrimSpace(string(comm)) != "bgpd" {
+			continue
+		}
+		age, err := processAge(e.Name())
+		if err != nil {
+			return 0, 0, err
+		}
+		return pid, age, nil
+	}
+	return 0, 0, nil
+}
+
+// processAge returns how long ago the process started, from its start time
+// in the stat file against the uptime of the system.
+func processAge(pid string) (time.Duration, error) {
+	stat, err := os.ReadFile(filepath.Join(procDir, pid, "stat"))
+	if err != nil {
+		return 0, err
+	}
+	// The name of the command may contain spaces, the fields that
+	// matter follow it. The start time is the 22nd field.
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 {
+		return 0, fmt.Errorf("invalid stat file for process %s", pid)
+	}
+	fields := strings.Fields(string(stat)[end+1:])
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("invalid stat file for process %s", pid)
+	}
+	start, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid start time for process %s: %w", pid, err)
+	}
+
+	uptime, err := os.ReadFile(filepath.Join(procDir, "uptime"))
+	if err != nil {
+		return 0, err
+	}
+	up := strings.Fields(string(uptime))
+	if len(up) == 0 {
+		return 0, fmt.Errorf("invalid uptime %q", uptime)
+	}
+	seconds, err := strconv.ParseFloat(up[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid uptime %q: %w", uptime, err)
+	}
+	age := time.Duration(seconds*float64(time.Second)) - time.Duration(start)*time.Second/userHZ
+	if age < 0 {
+		return 0, nil
+	}
+	return age, nil
+}
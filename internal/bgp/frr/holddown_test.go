@@ -0,0 +1,121 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"go.universe.tf/metallb/internal/bgp"
+)
+
+func TestBgpdProcess(t *testing.T) {
+	oldProcDir := procDir
+	defer func() {
+		procDir = oldProcDir
+	}()
+	procDir = t.TempDir()
+
+	writeFile := func(name, content string) {
+		path := filepath.Join(procDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("failed to create %s: %s", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err)
+		}
+	}
+
+	writeFile("uptime", "1000.50 3000.00\n")
+	writeFile("1/comm", "sleep\n")
+	writeFile("1/stat", "1 (sleep) S 0 1 1 0 -1 4194560 0 0 0 0 0 0 0 0 20 0 1 0 100 0 0\n")
+	if err := os.Mkdir(filepath.Join(procDir, "self"), 0700); err != nil {
+		t.Fatalf("failed to create self: %s", err)
+	}
+
+	pid, _, err := bgpdProcess()
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if pid != 0 {
+		t.Fatalf("expected no bgpd process, got %d", pid)
+	}
+
+	// Started at 900s, the command name containing a space.
+	writeFile("42/comm", "bgpd\n")
+	writeFile("42/stat", "42 (bgpd x) S 1 42 42 0 -1 4194560 0 0 0 0 0 0 0 0 20 0 1 0 90000 0 0\n")
+	pid, age, err := bgpdProcess()
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if pid != 42 {
+		t.Fatalf("expected bgpd process 42, got %d", pid)
+	}
+	if age != 100500*time.Millisecond {
+		t.Fatalf("expected the process to be 100.5s old, got %s", age)
+	}
+
+	writeFile("42/stat", "42 (bgpd) S 1\n")
+	if _, _, err := bgpdProcess(); err == nil {
+		t.Fatal("expected an error for an invalid stat file")
+	}
+}
+
+func TestHoldDown(t *testing.T) {
+	sm := &sessionManager{
+		sessions:     map[string]*session{},
+		bfdProfiles:  []BFDProfile{},
+		reloadConfig: make(chan reloadEvent, 2),
+	}
+	s := &session{
+		SessionParameters: bgp.SessionParameters{
+			PeerAddress: "10.2.2.254:179",
+			MyASN:       100,
+			PeerASN:     200,
+			CurrentNode: "hostname",
+			SessionName: "test-peer",
+		},
+		sessionManager: sm,
+		logger:         log.NewNopLogger(),
+		advertised: []*bgp.Advertisement{{
+			Prefix: &net.IPNet{IP: net.ParseIP("172.16.1.10"), Mask: net.CIDRMask(32, 32)},
+		}},
+	}
+	_ = sm.addSession(s)
+
+	advertisements := func(config *frrConfig) int {
+		res := 0
+		for _, r := range config.Routers {
+			for _, n := range r.Neighbors {
+				res += len(n.Advertisements)
+			}
+		}
+		return res
+	}
+
+	sm.holdDown(log.NewNopLogger(), 50*time.Millisecond)
+	select {
+	case e := <-sm.reloadConfig:
+		if n := advertisements(e.config); n != 0 {
+			t.Fatalf("expected no advertisements during the hold-down, got %d", n)
+		}
+		if len(e.config.Routers) != 1 || len(e.config.Routers[0].Neighbors) != 1 {
+			t.Fatal("expected the neighbor to be configured during the hold-down")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a reload when the hold-down starts")
+	}
+
+	select {
+	case e := <-sm.reloadConfig:
+		if n := advertisements(e.config); n != 1 {
+			t.Fatalf("expected 1 advertisement after the hold-down, got %d", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a reload when the hold-down ends")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -16,14 +17,25 @@ type Neighbor struct {
 	Ip             net.IP
 	VRF            string
 	Connected      bool
+	State          string // The state of the BGP FSM, as in Idle or Established.
 	LocalAS        string
 	RemoteAS       string
 	PrefixSent     int
 	Port           int
 	RemoteRouterID string
 	MsgStats       MessageStats
+	// Capabilities maps the name of each capability to its negotiation
+	// state, one of CapabilityAdvertised, CapabilityReceived and
+	// CapabilityNegotiated.
+	Capabilities map[string]string
 }
 
+const (
+	CapabilityAdvertised = "advertised"
+	CapabilityReceived   = "received"
+	CapabilityNegotiated = "advertisedAndReceived"
+)
+
 type Route struct {
 	Destination *net.IPNet
 	NextHops    []net.IP
@@ -45,6 +57,7 @@ type FRRNeighbor struct {
 	AddressFamilyInfo map[string]struct {
 		SentPrefixCounter int `json:"sentPrefixCounter"`
 	} `json:"addressFamilyInfo"`
+	NeighborCapabilities map[string]json.RawMessage `json:"neighborCapabilities"`
 }
 
 type MessageStats struct {
@@ -130,12 +143,14 @@ func ParseNeighbour(vtyshRes string) (*Neighbor, error) {
 		return &Neighbor{
 			Ip:             ip,
 			Connected:      connected,
+			State:          n.BgpState,
 			LocalAS:        strconv.Itoa(n.LocalAs),
 			RemoteAS:       strconv.Itoa(n.RemoteAs),
 			PrefixSent:     prefixSent,
 			Port:           n.PortForeign,
 			RemoteRouterID: n.RemoteRouterID,
 			MsgStats:       n.MsgStats,
+			Capabilities:   parseCapabilities(n.NeighborCapabilities),
 		}, nil
 	}
 	return nil, errors.New("no peers were returned")
@@ -167,17 +182,73 @@ func ParseNeighbours(vtyshRes string) ([]*Neighbor, error) {
 		res = append(res, &Neighbor{
 			Ip:             ip,
 			Connected:      connected,
+			State:          n.BgpState,
 			LocalAS:        strconv.Itoa(n.LocalAs),
 			RemoteAS:       strconv.Itoa(n.RemoteAs),
 			PrefixSent:     prefixSent,
 			Port:           n.PortForeign,
 			RemoteRouterID: n.RemoteRouterID,
 			MsgStats:       n.MsgStats,
+			Capabilities:   parseCapabilities(n.NeighborCapabilities),
 		})
 	}
 	return res, nil
 }
 
+// parseCapabilities returns the negotiation state of the capabilities
+// reported by FRR. A capability is either reported with its state, as in
+// "4byteAs":"advertisedAndReceived", or per address family, as in
+// "multiprotocolExtensions":{"ipv4Unicast":{"advertised":true}}, in which
+// case it's named after the capability, the address family and the
+// direction if any, as in addPath/ipv4Unicast/tx. The entries that are
+// not capabilities are ignored.
+func parseCapabilities(raw map[string]json.RawMessage) map[string]string {
+	res := map[string]string{}
+	for name, v := range raw {
+		var state string
+		if err := json.Unmarshal(v, &state); err == nil {
+			if s := capabilityState(state); s != "" {
+				res[name] = s
+			}
+			continue
+		}
+		perFamily := map[string]map[string]bool{}
+		if err := json.Unmarshal(v, &perFamily); err != nil {
+			continue
+		}
+		for family, states := range perFamily {
+			for k, set := range states {
+				if !set {
+					continue
+				}
+				key := name + "/" + family
+				if strings.HasPrefix(k, "tx") || strings.HasPrefix(k, "rx") {
+					key = key + "/" + k[:2]
+					k = strings.ToLower(k[2:3]) + k[3:]
+				}
+				s := capabilityState(k)
+				if s == "" || res[key] == CapabilityNegotiated {
+					continue
+				}
+				res[key] = s
+			}
+		}
+	}
+	return res
+}
+
+func capabilityState(s string) string {
+	switch {
+	case strings.HasPrefix(s, CapabilityNegotiated):
+		return CapabilityNegotiated
+	case strings.HasPrefix(s, CapabilityAdvertised):
+		return CapabilityAdvertised
+	case strings.HasPrefix(s, CapabilityReceived):
+		return CapabilityReceived
+	}
+	return ""
+}
+
 // parseRoute takes the result of a show bgp neighbor
 // and parses the informations related to all the neighbours.
 func ParseRoutes(vtyshRes string) (map[string]Route, error) {
@@ -166,6 +166,9 @@ func TestNeighbour(t *testing.T) {
 			if tt.status != "Established" && n.Connected == true {
 				t.Fatal("Expected connected", false, "got", n.Connected)
 			}
+			if n.State != tt.status {
+				t.Fatal("Expected state", tt.status, "got", n.State)
+			}
 			if tt.ipv4PrefixSent+tt.ipv6PrefixSent != n.PrefixSent {
 				t.Fatal("Expected prefix sent", tt.ipv4PrefixSent+tt.ipv6PrefixSent, "got", n.PrefixSent)
 			}
@@ -782,3 +785,52 @@ func TestVRFs(t *testing.T) {
 		t.Fatalf("unexpected vrf list: %s", cmp.Diff(parsed, expected))
 	}
 }
+
+func TestCapabilities(t *testing.T) {
+	sample := `{
+  "172.18.0.5":{
+    "remoteAs":64512,
+    "localAs":64513,
+    "bgpState":"Established",
+    "neighborCapabilities":{
+      "4byteAs":"advertised",
+      "addPath":{
+        "ipv4Unicast":{
+          "txAdvertised":true,
+          "rxAdvertisedAndReceived":true
+        }
+      },
+      "routeRefresh":"advertisedAndReceivedOldNew",
+      "multiprotocolExtensions":{
+        "ipv4Unicast":{
+          "advertisedAndReceived":true
+        },
+        "ipv6Unicast":{
+          "received":true
+        }
+      },
+      "hostName":{
+        "advHostName":"kind-control-plane",
+        "rcvHostName":"bgpd"
+      },
+      "gracefulRestartRemoteTimerMsecs":120000,
+      "addressFamiliesByPeer":"none"
+    }
+  }
+}`
+	n, err := ParseNeighbour(sample)
+	if err != nil {
+		t.Fatalf("Failed to parse %s", err)
+	}
+	expected := map[string]string{
+		"4byteAs":                             CapabilityAdvertised,
+		"addPath/ipv4Unicast/tx":              CapabilityAdvertised,
+		"addPath/ipv4Unicast/rx":              CapabilityNegotiated,
+		"routeRefresh":                        CapabilityNegotiated,
+		"multiprotocolExtensions/ipv4Unicast": CapabilityNegotiated,
+		"multiprotocolExtensions/ipv6Unicast": CapabilityReceived,
+	}
+	if !cmp.Equal(n.Capabilities, expected) {
+		t.Fatalf("unexpected capabilities: %s", cmp.Diff(n.Capabilities, expected))
+	}
+}
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+const defaultReloaderMaxFailures = 3
+
+// reloaderOptions controls how the speaker talks to the frr reloader. They
+// are read from the environment of the speaker, as the other reloader
+// parameters are.
+type reloaderOptions struct {
+	// timeout is how long to wait for the reloader to report the outcome
+	// of a reload in the status file. Zero means the outcome is not waited
+	// for, and only checked periodically.
+	timeout time.Duration
+	// retryInterval is how long to wait before retrying a failed reload.
+	retryInterval time.Duration
+	// maxFailures is the number of consecutive failed reloads after which
+	// the reloader is considered unavailable.
+	maxFailures int
+}
+
+var reloader = reloaderOptions{
+	retryInterval: failureTimeout,
+	maxFailures:   defaultReloaderMaxFailures,
+}
+
+// statusPollInterval is how often the status file is checked while waiting
+// for the outcome of a reload.
+var statusPollInterval = 200 * time.Millisecond
+
+// reloaderOptionsFromEnv returns the reloader options overridden by the
+// FRR_RELOADER_TIMEOUT, FRR_RELOADER_RETRY_INTERVAL and
+// FRR_RELOADER_MAX_FAILURES environment variables. Invalid values are
+// logged and ignored.
+func reloaderOptionsFromEnv(l log.Logger) reloaderOptions {
+	res := reloaderOptions{
+		retryInterval: failureTimeout,
+		maxFailures:   defaultReloaderMaxFailures,
+	}
+
+	durations := map[string]*time.Duration{
+		"FRR_RELOADER_TIMEOUT":        &res.timeout,
+		"FRR_RELOADER_RETRY_INTERVAL": &res.retryInterval,
+	}
+	for name, d := range durations {
+		value, found := os.LookupEnv(name)
+		if !found {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			level.Error(l).Log("op", "reloaderOptions", "error", fmt.Sprintf("invalid duration %q", value), "env", name, "default", *d)
+			continue
+		}
+		*d = parsed
+	}
+	if res.retryInterval == 0 {
+		level.Error(l).Log("op", "reloaderOptions", "error", "the retry interval must be positive", "env", "FRR_RELOADER_RETRY_INTERVAL", "default", failureTimeout)
+		res.retryInterval = failureTimeout
+	}
+
+	if value, found := os.LookupEnv("FRR_RELOADER_MAX_FAILURES"); found {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			level.Error(l).Log("op", "reloaderOptions", "error", fmt.Sprintf("invalid number of failures %q", value), "env", "FRR_RELOADER_MAX_FAILURES", "default", res.maxFailures)
+		} else {
+			res.maxFailures = parsed
+		}
+	}
+	return res
+}
+
+// statusFileModTime returns the last time the reloader wrote the status
+// file, or the zero time if it never did.
+func statusFileModTime() time.Time {
+	info, err := os.Stat(statusFileName)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// waitForReload waits for the reloader to write the outcome of a reload in
+// the status file, which happens when its modification time moves past the
+// one read before signalling the reloader. It returns an error if the
+// reload failed or if the reloader did not answer within the timeout.
+func waitForReload(before time.Time, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if modTime := statusFileModTime(); !modTime.IsZero() && !modTime.Equal(before) {
+			content, err := os.ReadFile(statusFileName)
+			if err != nil {
+				return err
+			}
+			status := strings.Fields(string(content))
+			if len(status) != 2 {
+				return fmt.Errorf("malformed reloader status %q", string(content))
+			}
+			if status[1] != "success" {
+				return fmt.Errorf("the reloader failed to apply the configuration")
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the reloader did not report the outcome of the reload within %s", timeout)
+		}
+		time.Sleep(statusPollInterval)
+	}
+}
+
+// reloaderHealth tracks the consecutive failed reloads, to tell apart a
+// reloader that is unavailable from a transient failure.
+var reloaderHealth = struct {
+	sync.Mutex
+	failures  int
+	lastError error
+}{}
+
+func recordReload(err error) {
+	reloaderHealth.Lock()
+	defer reloaderHealth.Unlock()
+	if err == nil {
+		reloaderHealth.failures = 0
+		reloaderHealth.lastError = nil
+		return
+	}
+	reloaderHealth.failures++
+	reloaderHealth.lastError = err
+}
+
+// ReloaderHealthy returns an error if the last reloads of the FRR
+// configuration failed persistently, meaning the speaker is not able to
+// apply its configuration.
+func ReloaderHealthy() error {
+	reloaderHealth.Lock()
+	defer reloaderHealth.Unlock()
+	if reloaderHealth.failures < reloader.maxFailures {
+		return nil
+	}
+	return fmt.Errorf("%d consecutive frr reloads failed, last error: %w", reloaderHealth.failures, reloaderHealth.lastError)
+}
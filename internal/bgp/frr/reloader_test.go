@@ -0,0 +1,82 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestWaitForReload(t *testing.T) {
+	oldStatusFile, oldPollInterval := statusFileName, statusPollInterval
+	defer func() {
+		statusFileName, statusPollInterval = oldStatusFile, oldPollInterval
+	}()
+	statusFileName = filepath.Join(t.TempDir(), ".status")
+	statusPollInterval = time.Millisecond
+
+	writeStatus := func(status string, modTime time.Time) {
+		if err := os.WriteFile(statusFileName, []byte(status), 0600); err != nil {
+			t.Fatalf("failed to write the status file: %s", err)
+		}
+		if err := os.Chtimes(statusFileName, modTime, modTime); err != nil {
+			t.Fatalf("failed to set the status file time: %s", err)
+		}
+	}
+
+	if err := waitForReload(statusFileModTime(), 10*time.Millisecond); err == nil {
+		t.Fatal("expected a timeout without a status file")
+	}
+
+	before := time.Now().Add(-time.Minute)
+	writeStatus("1 success", before)
+	if err := waitForReload(statusFileModTime(), 10*time.Millisecond); err == nil {
+		t.Fatal("expected a timeout with a stale status file")
+	}
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		writeStatus("2 success", time.Now())
+	}()
+	if err := waitForReload(before, time.Second); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	before = statusFileModTime()
+	writeStatus("3 failure", before.Add(time.Second))
+	if err := waitForReload(before, time.Second); err == nil {
+		t.Fatal("expected an error for a failed reload")
+	}
+}
+
+func TestReloaderHealthy(t *testing.T) {
+	oldReloader := reloader
+	defer func() {
+		reloader = oldReloader
+		recordReload(nil)
+	}()
+	t.Setenv("FRR_RELOADER_MAX_FAILURES", "2")
+	t.Setenv("FRR_RELOADER_TIMEOUT", "invalid")
+	reloader = reloaderOptionsFromEnv(log.NewNopLogger())
+	if reloader.maxFailures != 2 || reloader.timeout != 0 || reloader.retryInterval != failureTimeout {
+		t.Fatalf("unexpected reloader options %+v", reloader)
+	}
+
+	recordReload(errors.New("failed"))
+	if err := ReloaderHealthy(); err != nil {
+		t.Fatalf("expected healthy after a single failure, got %s", err)
+	}
+	recordReload(errors.New("failed"))
+	if err := ReloaderHealthy(); err == nil {
+		t.Fatal("expected unhealthy after consecutive failures")
+	}
+	recordReload(nil)
+	if err := ReloaderHealthy(); err != nil {
+		t.Fatalf("expected healthy after a successful reload, got %s", err)
+	}
+}
@@ -25,4 +25,9 @@ var (
 		Name: "announced_prefixes_total",
 		Help: "Number of prefixes currently being advertised on the BGP session",
 	}
+
+	CapabilityMismatch = metric{
+		Name: "capability_mismatch_total",
+		Help: "Number of BGP OPEN messages from the peer not negotiating a capability advertised by MetalLB, or refusing it",
+	}
 )
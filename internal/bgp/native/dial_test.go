@@ -0,0 +1,111 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package native
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.universe.tf/metallb/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+func TestDialSourcePorts(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// The port of the listener is in use, so the next one of the range is
+	// expected to be picked.
+	port := uint16(lis.Addr().(*net.TCPAddr).Port)
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port)))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, err := dialMD5(ctx, addr, net.ParseIP("127.0.0.1"), &config.PortRange{Min: port, Max: port + 1}, "")
+	if err != nil {
+		t.Fatalf("dial failed: %s", err)
+	}
+	defer conn.Close()
+	if got := conn.LocalAddr().(*net.TCPAddr).Port; got != int(port)+1 {
+		t.Fatalf("expected source port %d, got %d", port+1, got)
+	}
+
+	_, err = dialMD5(ctx, addr, net.ParseIP("127.0.0.1"), &config.PortRange{Min: port, Max: port}, "")
+	if err == nil {
+		t.Fatalf("expected dial to fail with no available source port")
+	}
+}
+
+func TestTCPKeepalive(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := dialMD5(ctx, lis.Addr().String(), nil, nil, "")
+	if err != nil {
+		t.Fatalf("dial failed: %s", err)
+	}
+	defer conn.Close()
+	err = setTCPKeepalive(conn, &config.TCPKeepalive{Idle: 10 * time.Second, Interval: 3 * time.Second, Count: 4})
+	if err != nil {
+		t.Fatalf("failed to set the keepalives: %s", err)
+	}
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("failed to get the raw conn: %s", err)
+	}
+	expected := []struct {
+		level int
+		opt   int
+		value int
+	}{
+		{unix.SOL_SOCKET, unix.SO_KEEPALIVE, 1},
+		{unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, 10},
+		{unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, 3},
+		{unix.IPPROTO_TCP, unix.TCP_KEEPCNT, 4},
+	}
+	err = raw.Control(func(fd uintptr) {
+		for _, e := range expected {
+			got, err := unix.GetsockoptInt(int(fd), e.level, e.opt)
+			if err != nil {
+				t.Errorf("getsockopt %d failed: %s", e.opt, err)
+				continue
+			}
+			if got != e.value {
+				t.Errorf("expected sockopt %d to be %d, got %d", e.opt, e.value, got)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatalf("failed to control the raw conn: %s", err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"go.universe.tf/metallb/internal/bgp"
+	"go.universe.tf/metallb/internal/config"
 )
 
 func sendOpen(w io.Writer, asn uint32, routerID net.IP, holdTime time.Duration) error {
@@ -96,6 +97,58 @@ type openResult struct {
 	mp6      bool
 	// Four-byte ASN supported
 	fbasn bool
+	// The names of the capabilities advertised by the peer.
+	capabilities []string
+}
+
+// advertisedCapabilities are the names of the capabilities sent by
+// sendOpen.
+var advertisedCapabilities = []string{"multiprotocol-ipv4-unicast", "multiprotocol-ipv6-unicast", "4-byte-asn"}
+
+var capabilityNames = map[uint8]string{
+	1:  "multiprotocol",
+	2:  "route-refresh",
+	5:  "extended-nexthop",
+	6:  "extended-message",
+	64: "graceful-restart",
+	65: "4-byte-asn",
+	69: "add-path",
+	70: "enhanced-route-refresh",
+	71: "long-lived-graceful-restart",
+	73: "fqdn",
+}
+
+func capabilityName(code uint8) string {
+	if n, ok := capabilityNames[code]; ok {
+		return n
+	}
+	return fmt.Sprintf("unknown-%d", code)
+}
+
+// declinedCapabilities returns the capabilities sent by sendOpen that the
+// peer didn't advertise back.
+func (o *openResult) declinedCapabilities() []string {
+	received := map[string]bool{}
+	for _, c := range o.capabilities {
+		received[c] = true
+	}
+	res := []string{}
+	for _, c := range advertisedCapabilities {
+		if !received[c] {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// unsupportedCapabilityError is returned when the peer refuses the OPEN
+// because of one of the capabilities advertised.
+type unsupportedCapabilityError struct {
+	capability string
+}
+
+func (e unsupportedCapabilityError) Error() string {
+	return fmt.Sprintf("got BGP notification code 0x0207 (%s): %s", notificationCodes[0x0207], e.capability)
 }
 
 var notificationCodes = map[uint16]string{
@@ -149,6 +202,15 @@ func readNotification(r io.Reader) error {
 	if err := binary.Read(r, binary.BigEndian, &code); err != nil {
 		return err
 	}
+	if code == 0x0207 {
+		// The data holds the capabilities refused, we report the first.
+		capability := "unknown"
+		var capCode uint8
+		if err := binary.Read(r, binary.BigEndian, &capCode); err == nil {
+			capability = capabilityName(capCode)
+		}
+		return unsupportedCapabilityError{capability: capability}
+	}
 	v, ok := notificationCodes[code]
 	if !ok {
 		v = "unknown code"
@@ -170,7 +232,7 @@ func readOpen(r io.Reader) (*openResult, error) {
 		return nil, fmt.Errorf("synchronization error, incorrect header marker")
 	}
 	if hdr.Type == 3 {
-		return nil, readNotification(r)
+		return nil, readNotification(io.LimitReader(r, int64(hdr.Len)-19))
 	}
 	if hdr.Type != 1 {
 		return nil, fmt.Errorf("message type is not OPEN, got %d, want 1", hdr.Type)
@@ -264,6 +326,7 @@ func readCapabilities(r io.Reader, ret *openResult) error {
 				return err
 			}
 			ret.fbasn = true
+			ret.capabilities = append(ret.capabilities, capabilityName(cap.Code))
 		case 1:
 			af := struct{ AFI, SAFI uint16 }{}
 			if err := binary.Read(&lr, binary.BigEndian, &af); err != nil {
@@ -272,10 +335,15 @@ func readCapabilities(r io.Reader, ret *openResult) error {
 			switch {
 			case af.AFI == 1 && af.SAFI == 1:
 				ret.mp4 = true
+				ret.capabilities = append(ret.capabilities, "multiprotocol-ipv4-unicast")
 			case af.AFI == 2 && af.SAFI == 1:
 				ret.mp6 = true
+				ret.capabilities = append(ret.capabilities, "multiprotocol-ipv6-unicast")
+			default:
+				ret.capabilities = append(ret.capabilities, fmt.Sprintf("multiprotocol-%d-%d", af.AFI, af.SAFI))
 			}
 		default:
+			ret.capabilities = append(ret.capabilities, capabilityName(cap.Code))
 			// TODO: only ignore capabilities that we know are fine to
 			// ignore.
 			if _, err := io.Copy(io.Discard, &lr); err != nil {
@@ -338,7 +406,7 @@ func encodePathAttrs(b *bytes.Buffer, asn uint32, ibgp, fbasn bool, nextHop net.
 	b.Write([]byte{
 		0x40, 1, // mandatory, origin
 		1, // len
-		0, // igb
+		originCode(adv.Origin),
 
 		0x40, 2, // mandatory, as-path
 	})
@@ -399,6 +467,19 @@ func encodePathAttrs(b *bytes.Buffer, asn uint32, ibgp, fbasn bool, nextHop net.
 	return nil
 }
 
+// originCode returns the value of the ORIGIN path attribute for the
+// given origin, IGP being the default.
+func originCode(origin string) byte {
+	switch origin {
+	case config.OriginEGP:
+		return 1
+	case config.OriginIncomplete:
+		return 2
+	default:
+		return 0
+	}
+}
+
 func sendWithdraw(w io.Writer, prefixes []*net.IPNet) error {
 	var b bytes.Buffer
 
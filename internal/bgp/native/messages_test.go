@@ -4,11 +4,16 @@ package native
 
 import (
 	"bytes"
+	"errors"
 	"net"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/internal/bgp"
+	"go.universe.tf/metallb/internal/config"
 )
 
 // Just test that sendOpen and readOpen can at least talk to each other.
@@ -94,3 +99,65 @@ func TestOpenFourByteASN(t *testing.T) {
 		}
 	}
 }
+
+func TestOpenCapabilities(t *testing.T) {
+	// BGP OPEN from Arista EOS 4.13.10M, no 4-byte ASN support
+	b := bytes.NewBuffer([]byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0x00, 0x2b, 0x01, 0x04, 0xfd, 0xe9, 0x00, 0xb4, 0xb9, 0xec, 0xf0, 0x40, 0x0e, 0x02,
+		0x0c, 0x01, 0x04, 0x00, 0x01, 0x00, 0x01, 0x02, 0x00, 0x40, 0x02, 0x00, 0xb4,
+	})
+	open, err := readOpen(b)
+	if err != nil {
+		t.Fatalf("readOpen: %v", err)
+	}
+	if diff := cmp.Diff([]string{"multiprotocol-ipv4-unicast", "route-refresh", "graceful-restart"}, open.capabilities); diff != "" {
+		t.Errorf("unexpected capabilities (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"multiprotocol-ipv6-unicast", "4-byte-asn"}, open.declinedCapabilities()); diff != "" {
+		t.Errorf("unexpected declined capabilities (-want +got)\n%s", diff)
+	}
+}
+
+func TestUnsupportedCapabilityNotification(t *testing.T) {
+	// NOTIFICATION refusing the add-path capability.
+	b := bytes.NewBuffer([]byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0x00, 0x1b, 0x03, 0x02, 0x07, 0x45, 0x04, 0x00, 0x01, 0x01, 0x01,
+	})
+	_, err := readOpen(b)
+	var capErr unsupportedCapabilityError
+	if !errors.As(err, &capErr) {
+		t.Fatalf("expected an unsupported capability error, got %v", err)
+	}
+	if capErr.capability != "add-path" {
+		t.Errorf("expected the add-path capability, got %q", capErr.capability)
+	}
+}
+
+func TestPathAttrsOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   byte
+	}{
+		{"", 0},
+		{config.OriginIGP, 0},
+		{config.OriginEGP, 1},
+		{config.OriginIncomplete, 2},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		adv := &bgp.Advertisement{
+			Prefix: &net.IPNet{IP: net.ParseIP("1.2.3.4"), Mask: net.CIDRMask(32, 32)},
+			Origin: test.origin,
+		}
+		if err := encodePathAttrs(&b, 64512, false, true, net.ParseIP("10.0.0.1").To4(), adv); err != nil {
+			t.Fatalf("%q: encodePathAttrs: %s", test.origin, err)
+		}
+		// The ORIGIN attribute comes first: flags, type, length and value.
+		attr := b.Bytes()[:4]
+		if want := []byte{0x40, 1, 1, test.want}; !bytes.Equal(attr, want) {
+			t.Errorf("%q: wrong ORIGIN attribute, want %v, got %v", test.origin, want, attr)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"net"
 	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
@@ -35,6 +36,9 @@ type session struct {
 
 	newHoldTime chan bool
 	backoff     backoff
+	// established is set along with conn, and readable without waiting
+	// for a connection attempt holding mu.
+	established atomic.Bool
 
 	mu             sync.Mutex
 	cond           *sync.Cond
@@ -201,7 +205,7 @@ func (s *session) connect() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	deadline, _ := ctx.Deadline()
-	conn, err := dialMD5(ctx, s.PeerAddress, s.SourceAddress, s.Password)
+	conn, err := dialMD5(ctx, s.PeerAddress, s.SourceAddress, s.SourcePorts, s.Password)
 	if err != nil {
 		return fmt.Errorf("dial %q: %s", s.PeerAddress, err)
 	}
@@ -211,6 +215,13 @@ func (s *session) connect() error {
 		return fmt.Errorf("setting deadline on conn to %q: %s", s.PeerAddress, err)
 	}
 
+	if s.TCPKeepalive != nil {
+		if err = setTCPKeepalive(conn, s.TCPKeepalive); err != nil {
+			conn.Close()
+			return fmt.Errorf("setting tcp keepalive on conn to %q: %s", s.PeerAddress, err)
+		}
+	}
+
 	addr, ok := conn.LocalAddr().(*net.TCPAddr)
 	if !ok {
 		conn.Close()
@@ -234,8 +245,17 @@ func (s *session) connect() error {
 	op, err := readOpen(conn)
 	if err != nil {
 		conn.Close()
+		var capErr unsupportedCapabilityError
+		if errors.As(err, &capErr) {
+			stats.CapabilityMismatch(s.PeerAddress, capErr.capability)
+		}
 		return fmt.Errorf("read OPEN from %q: %s", s.PeerAddress, err)
 	}
+	level.Debug(s.logger).Log("event", "capabilitiesNegotiated", "capabilities", fmt.Sprint(op.capabilities), "msg", "capabilities advertised by the peer")
+	for _, c := range op.declinedCapabilities() {
+		level.Warn(s.logger).Log("event", "capabilityDeclined", "capability", c, "msg", "peer didn't negotiate a capability advertised by MetalLB")
+		stats.CapabilityMismatch(s.PeerAddress, c)
+	}
 	if op.asn != s.PeerASN {
 		conn.Close()
 		return fmt.Errorf("unexpected peer ASN %d, want %d", op.asn, s.PeerASN)
@@ -272,6 +292,7 @@ func (s *session) connect() error {
 	}
 
 	s.conn = conn
+	s.established.Store(true)
 	return nil
 }
 
@@ -414,7 +435,7 @@ func (s *session) consumeBGP(conn io.ReadCloser) {
 		}
 		if hdr.Type == 3 {
 			// TODO: propagate better than just logging directly.
-			err := readNotification(conn)
+			err := readNotification(io.LimitReader(conn, int64(hdr.Len)-19))
 			level.Error(s.logger).Log("event", "peerNotification", "error", err, "msg", "peer sent notification, closing session")
 			return
 		}
@@ -469,6 +490,7 @@ func (s *session) abort() {
 	if s.conn != nil {
 		s.conn.Close()
 		s.conn = nil
+		s.established.Store(false)
 		stats.SessionDown(s.PeerAddress)
 	}
 	// Next time we retry the connection, we can just skip straight to
@@ -480,6 +502,11 @@ func (s *session) abort() {
 	s.cond.Broadcast()
 }
 
+// Established returns true if the session is established with the peer.
+func (s *session) Established() bool {
+	return s.established.Load()
+}
+
 // Close shuts down the BGP session.
 func (s *session) Close() error {
 	s.mu.Lock()
@@ -512,7 +539,10 @@ type tcpmd5sig struct {
 // proper TCP MD5 options when the password is not empty. Works by manupulating
 // the low level FD's, skipping the net.Conn API as it has not hooks to set
 // the neccessary sockopts for TCP MD5.
-func dialMD5(ctx context.Context, addr string, srcAddr net.IP, password string) (net.Conn, error) {
+//
+// When srcPorts is not nil, the socket is bound to the first port of the range
+// that is not in use.
+func dialMD5(ctx context.Context, addr string, srcAddr net.IP, srcPorts *config.PortRange, password string) (net.Conn, error) {
 	// If srcAddr exists on any of the local network interfaces, use it as the
 	// source address of the TCP socket. Otherwise, use the IPv6 unspecified
 	// address ("::") to let the kernel figure out the source address.
@@ -591,7 +621,10 @@ func dialMD5(ctx context.Context, addr string, srcAddr net.IP, password string)
 	}()
 
 	if password != "" {
-		sig := buildTCPMD5Sig(raddr.IP, password)
+		sig, err := buildTCPMD5Sig(raddr.IP, password)
+		if err != nil {
+			return nil, err
+		}
 		b := *(*[unsafe.Sizeof(sig)]byte)(unsafe.Pointer(&sig))
 		// Better way may be available in  Go 1.11, see go-review.googlesource.com/c/go/+/72810
 		if err = os.NewSyscallError("setsockopt", unix.SetsockoptString(fd, unix.IPPROTO_TCP, tcpMD5SIG, string(b[:]))); err != nil {
@@ -599,8 +632,12 @@ func dialMD5(ctx context.Context, addr string, srcAddr net.IP, password string)
 		}
 	}
 
-	if err = unix.Bind(fd, la); err != nil {
-		return nil, os.NewSyscallError("bind", err)
+	if srcPorts == nil {
+		if err = unix.Bind(fd, la); err != nil {
+			return nil, os.NewSyscallError("bind", err)
+		}
+	} else if err = bindPortRange(fd, la, srcPorts); err != nil {
+		return nil, err
 	}
 
 	err = unix.Connect(fd, ra)
@@ -665,8 +702,11 @@ func dialMD5(ctx context.Context, addr string, srcAddr net.IP, password string)
 	}
 }
 
-func buildTCPMD5Sig(addr net.IP, key string) tcpmd5sig {
+func buildTCPMD5Sig(addr net.IP, key string) (tcpmd5sig, error) {
 	t := tcpmd5sig{}
+	if len(key) > len(t.key) {
+		return t, fmt.Errorf("TCP MD5 password of %d bytes, the maximum is %d", len(key), len(t.key))
+	}
 	if addr.To4() != nil {
 		t.ssFamily = unix.AF_INET
 		copy(t.ss[2:], addr.To4())
@@ -678,7 +718,7 @@ func buildTCPMD5Sig(addr net.IP, key string) tcpmd5sig {
 	t.keylen = uint16(len(key))
 	copy(t.key[0:], []byte(key))
 
-	return t
+	return t, nil
 }
 
 // localAddressExists returns true if the address addr exists on any of the
@@ -703,3 +743,69 @@ func localAddressExists(ifs []net.Interface, addr net.IP) bool {
 
 	return false
 }
+
+// bindPortRange binds the socket to the first port of the range that is
+// available. SO_REUSEADDR is set so that a port left in TIME_WAIT by a
+// previous session with the peer can be bound again.
+func bindPortRange(fd int, la unix.Sockaddr, ports *config.PortRange) error {
+	if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+		return os.NewSyscallError("setsockopt", err)
+	}
+	for port := int(ports.Min); port <= int(ports.Max); port++ {
+		switch sa := la.(type) {
+		case *unix.SockaddrInet4:
+			sa.Port = port
+		case *unix.SockaddrInet6:
+			sa.Port = port
+		}
+		err := unix.Bind(fd, la)
+		if err == nil {
+			return nil
+		}
+		if err != unix.EADDRINUSE {
+			return os.NewSyscallError("bind", err)
+		}
+	}
+	return fmt.Errorf("no available source port in range %d-%d", ports.Min, ports.Max)
+}
+
+// setTCPKeepalive enables the TCP keepalives on the connection, overriding
+// the system defaults with the values set. It must be called once the
+// connection is wrapped in a net.Conn, as the wrapping sets the keepalives
+// to the Go defaults.
+func setTCPKeepalive(conn net.Conn, keepalive *config.TCPKeepalive) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return fmt.Errorf("unexpected connection type %T", conn)
+	}
+	raw, err := tcpConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	opts := []struct {
+		level int
+		opt   int
+		value int
+	}{
+		{unix.SOL_SOCKET, unix.SO_KEEPALIVE, 1},
+		{unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, int(keepalive.Idle.Seconds())},
+		{unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, int(keepalive.Interval.Seconds())},
+		{unix.IPPROTO_TCP, unix.TCP_KEEPCNT, int(keepalive.Count)},
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		for _, o := range opts {
+			if o.value == 0 {
+				continue
+			}
+			if sockErr = unix.SetsockoptInt(int(fd), o.level, o.opt, o.value); sockErr != nil {
+				sockErr = os.NewSyscallError("setsockopt", sockErr)
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
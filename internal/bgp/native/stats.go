@@ -37,13 +37,21 @@ var stats = metrics{
 		Name:      "pending_prefixes_total",
 		Help:      "Number of prefixes that should be advertised on the BGP session",
 	}, labels),
+
+	capabilityMismatch: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: bgpmetrics.Namespace,
+		Subsystem: bgpmetrics.Subsystem,
+		Name:      bgpmetrics.CapabilityMismatch.Name,
+		Help:      bgpmetrics.CapabilityMismatch.Help,
+	}, []string{"peer", "capability"}),
 }
 
 type metrics struct {
-	sessionUp       *prometheus.GaugeVec
-	updatesSent     *prometheus.CounterVec
-	prefixes        *prometheus.GaugeVec
-	pendingPrefixes *prometheus.GaugeVec
+	sessionUp          *prometheus.GaugeVec
+	updatesSent        *prometheus.CounterVec
+	prefixes           *prometheus.GaugeVec
+	pendingPrefixes    *prometheus.GaugeVec
+	capabilityMismatch *prometheus.CounterVec
 }
 
 func init() {
@@ -51,6 +59,7 @@ func init() {
 	prometheus.MustRegister(stats.updatesSent)
 	prometheus.MustRegister(stats.prefixes)
 	prometheus.MustRegister(stats.pendingPrefixes)
+	prometheus.MustRegister(stats.capabilityMismatch)
 }
 
 func (m *metrics) NewSession(addr string) {
@@ -65,6 +74,7 @@ func (m *metrics) DeleteSession(addr string) {
 	m.prefixes.DeleteLabelValues(addr)
 	m.pendingPrefixes.DeleteLabelValues(addr)
 	m.updatesSent.DeleteLabelValues(addr)
+	m.capabilityMismatch.DeletePartialMatch(prometheus.Labels{"peer": addr})
 }
 
 func (m *metrics) SessionUp(addr string) {
@@ -89,3 +99,7 @@ func (m *metrics) AdvertisedPrefixes(addr string, n int) {
 	m.prefixes.WithLabelValues(addr).Set(float64(n))
 	m.pendingPrefixes.WithLabelValues(addr).Set(float64(n))
 }
+
+func (m *metrics) CapabilityMismatch(addr, capability string) {
+	m.capabilityMismatch.WithLabelValues(addr, capability).Inc()
+}
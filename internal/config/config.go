@@ -17,6 +17,7 @@ package config // import "go.universe.tf/metallb/internal/config"
 import (
 	"bytes"
 	"fmt"
+	"math/big"
 	"net"
 	"reflect"
 	"sort"
@@ -28,23 +29,27 @@ import (
 	"github.com/pkg/errors"
 	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
 	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	"go.universe.tf/metallb/internal/ipfamily"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 type ClusterResources struct {
-	Pools              []metallbv1beta1.IPAddressPool    `json:"ipaddresspools"`
-	Peers              []metallbv1beta2.BGPPeer          `json:"bgppeers"`
-	BFDProfiles        []metallbv1beta1.BFDProfile       `json:"bfdprofiles"`
-	BGPAdvs            []metallbv1beta1.BGPAdvertisement `json:"bgpadvertisements"`
-	L2Advs             []metallbv1beta1.L2Advertisement  `json:"l2advertisements"`
-	LegacyAddressPools []metallbv1beta1.AddressPool      `json:"legacyaddresspools"`
-	Communities        []metallbv1beta1.Community        `json:"communities"`
-	PasswordSecrets    map[string]corev1.Secret          `json:"passwordsecrets"`
-	Nodes              []corev1.Node                     `json:"nodes"`
-	Namespaces         []corev1.Namespace                `json:"namespaces"`
+	Pools              []metallbv1beta1.IPAddressPool      `json:"ipaddresspools"`
+	Peers              []metallbv1beta2.BGPPeer            `json:"bgppeers"`
+	BFDProfiles        []metallbv1beta1.BFDProfile         `json:"bfdprofiles"`
+	BGPAdvs            []metallbv1beta1.BGPAdvertisement   `json:"bgpadvertisements"`
+	L2Advs             []metallbv1beta1.L2Advertisement    `json:"l2advertisements"`
+	LegacyAddressPools []metallbv1beta1.AddressPool        `json:"legacyaddresspools"`
+	Communities        []metallbv1beta1.Community          `json:"communities"`
+	NodeAdvs           []metallbv1beta1.NodeAdvertisement  `json:"nodeadvertisements"`
+	Maintenances       []metallbv1beta1.MetalLBMaintenance `json:"metallbmaintenances"`
+	PasswordSecrets    map[string]corev1.Secret            `json:"passwordsecrets"`
+	Nodes              []corev1.Node                       `json:"nodes"`
+	Namespaces         []corev1.Namespace                  `json:"namespaces"`
 }
 
 // Config is a parsed MetalLB configuration.
@@ -55,8 +60,38 @@ type Config struct {
 	Pools *Pools
 	// BFD profiles that can be used by peers.
 	BFDProfiles map[string]*BFDProfile
+	// Prefixes advertised by the nodes, independently of the services.
+	NodeAdvertisements map[string]*NodeAdvertisement
+	// The score of the nodes set with the NodeScoreAnnotation, the lowest
+	// scoring node is preferred when electing the node announcing an L2 IP.
+	NodeScores map[string]int
+	// The weight of the nodes set with the NodeL2WeightAnnotation. The
+	// nodes win the election of the L2 IPs with a probability proportional
+	// to their weight.
+	NodeL2Weights map[string]int
+	// The reason of each MetalLBMaintenance, by name. While there's at
+	// least one, all the announcements are withdrawn.
+	Maintenances map[string]string
 }
 
+// InMaintenance returns true if MetalLB is in maintenance mode, in which
+// case nothing must be announced.
+func (c *Config) InMaintenance() bool {
+	return len(c.Maintenances) > 0
+}
+
+// NodeScoreAnnotation is the node annotation holding the score of the node,
+// for example a load metric fed by an external controller.
+const NodeScoreAnnotation = "metallb.universe.tf/l2-score"
+
+// NodeL2WeightAnnotation is the node annotation holding the weight of the
+// node in the election of the L2 IPs, for example to reflect its capacity.
+const NodeL2WeightAnnotation = "metallb.universe.tf/l2-weight"
+
+// ServiceAnnouncingNodesAnnotation is the service annotation the controller
+// lists the comma separated names of the nodes announcing the service in.
+const ServiceAnnouncingNodesAnnotation = "metallb.universe.tf/announcing-nodes"
+
 // Pools contains address pools and its namespace/service specific allocations.
 type Pools struct {
 	// ByName a map containing all configured pools.
@@ -65,8 +100,19 @@ type Pools struct {
 	ByNamespace map[string][]string
 	// ByServiceSelector contains pool names which has service selection labels.
 	ByServiceSelector []string
+	// ByServicePorts contains pool names which has service ports selection.
+	ByServicePorts []string
 }
 
+// AllocationMode is the order the addresses of a pool are allocated in.
+type AllocationMode string
+
+// Supported allocation modes.
+const (
+	AllocationLowest  AllocationMode = "lowest"
+	AllocationHighest AllocationMode = "highest"
+)
+
 // Proto holds the protocol we are speaking.
 type Proto string
 
@@ -92,6 +138,12 @@ type Peer struct {
 	Addr net.IP
 	// Source address to use when establishing the session.
 	SrcAddr net.IP
+	// Optional range of source ports to use when establishing the session,
+	// nil means an ephemeral port is used.
+	SrcPorts *PortRange
+	// Optional TCP keepalives of the socket of the session, nil
+	// means the keepalives are not enabled.
+	TCPKeepalive *TCPKeepalive
 	// Port to dial when establishing the session.
 	Port uint16
 	// Requested BGP hold time, per RFC4271.
@@ -109,11 +161,85 @@ type Peer struct {
 	BFDProfile string
 	// Optional ebgp peer is multi-hops away.
 	EBGPMultiHop bool
+	// Optional minimum TTL of the packets received from the peer, enabling
+	// the TTL security.
+	MinimumTTL *uint32
 	// Optional name of the vrf to establish the session from
 	VRF string
+	// Optional filter of the routes accepted from the peer, nil
+	// means all the routes are rejected.
+	ImportFilter *ImportFilter
+	// Optional directions the additional paths are exchanged with
+	// the peer, nil means the additional paths are not sent.
+	AddPath *AddPath
+	// Optional setting of the speaker as the next hop of all the
+	// routes sent to the peer.
+	NextHopSelf bool
+	// The families of the IPs advertised to the peer, empty means
+	// both families.
+	AddressFamilies []ipfamily.Family
+	// Labels of the BGPPeer, for the speakers to select the peers
+	// they establish sessions with.
+	Labels map[string]string
 	// TODO: more BGP session settings
 }
 
+// AdvertisesFamily tells if the IPs of the family are advertised to the peer.
+func (p *Peer) AdvertisesFamily(family ipfamily.Family) bool {
+	if len(p.AddressFamilies) == 0 {
+		return true
+	}
+	for _, f := range p.AddressFamilies {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+// AddPath holds the directions the additional paths are exchanged
+// with a peer.
+type AddPath struct {
+	Send    bool
+	Receive bool
+}
+
+// TCPKeepalive holds the TCP keepalive settings of the socket of a
+// session. The zero values mean the system default is used.
+type TCPKeepalive struct {
+	Idle     time.Duration
+	Interval time.Duration
+	Count    uint32
+}
+
+// PortRange is an inclusive range of ports.
+type PortRange struct {
+	Min uint16
+	Max uint16
+}
+
+// Overlaps returns true if the two ranges have at least one port in common.
+func (r *PortRange) Overlaps(other *PortRange) bool {
+	return r.Min <= other.Max && other.Min <= r.Max
+}
+
+// ImportFilter selects the routes accepted from a peer. A route is
+// accepted when it matches all the criteria set.
+type ImportFilter struct {
+	// The prefixes accepted.
+	Prefixes []ImportPrefix
+	// The communities a route must carry one of.
+	Communities []uint32
+}
+
+// ImportPrefix is an entry of a prefix list. GE and LE are the optional
+// bounds of the length of the matched prefixes, 0 when not set.
+type ImportPrefix struct {
+	Prefix *net.IPNet
+	GE     int
+	LE     int
+}
+
 // Pool is the configuration of an IP address pool.
 type Pool struct {
 	// Pool Name
@@ -128,6 +254,9 @@ type Pool struct {
 	// unusable, for maximum compatibility with ancient parts of the
 	// internet.
 	AvoidBuggyIPs bool
+	// The sorted last octets of the IPv4 addresses not to allocate from
+	// the pool, on top of the ones avoided by AvoidBuggyIPs.
+	AvoidLastOctets []uint8
 	// If false, prevents IP addresses to be automatically assigned
 	// from this pool.
 	AutoAssign bool
@@ -141,6 +270,81 @@ type Pool struct {
 	cidrsPerAddresses map[string][]*net.IPNet
 
 	ServiceAllocations *ServiceAllocation
+
+	// The topology zone the pool is associated with, set with the
+	// topology.kubernetes.io/zone label of the pool.
+	Zone string
+
+	// If true, the allocator tries to hand out the address following
+	// the highest one allocated to the namespace of the service.
+	ContiguousNamespaces bool
+
+	// When greater than 1, only the addresses whose value modulo the
+	// stride equals AllocationOffset are allocated from the pool.
+	AllocationStride uint32
+	AllocationOffset uint32
+
+	// The order the addresses of the pool are allocated in, empty
+	// meaning the allocation strategy of the controller is used.
+	AllocationMode AllocationMode
+
+	// If true and the pool is advertised via both BGP and L2, its IPs
+	// are announced via L2 only when the node has no established BGP
+	// session with the peers of its BGP advertisements.
+	PreferBGP bool
+
+	// The services of the pools with a higher priority are announced
+	// first when a speaker processes all of them.
+	AnnouncementPriority int
+}
+
+// StrideDistance returns the number of addresses between ip and the
+// next address satisfying the allocation stride of the pool, zero if ip
+// satisfies it.
+func (p *Pool) StrideDistance(ip net.IP) uint32 {
+	if p.AllocationStride <= 1 {
+		return 0
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	var mod big.Int
+	mod.Mod(new(big.Int).SetBytes(ip), big.NewInt(int64(p.AllocationStride)))
+	rem := uint32(mod.Uint64())
+	return (p.AllocationOffset + p.AllocationStride - rem) % p.AllocationStride
+}
+
+// AvoidsIP returns true if the IP must not be allocated from the pool
+// because of its last octet.
+func (p *Pool) AvoidsIP(ip net.IP) bool {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return false
+	}
+	if p.AvoidBuggyIPs && (ip4[3] == 0 || ip4[3] == 255) {
+		return true
+	}
+	for _, o := range p.AvoidLastOctets {
+		if ip4[3] == o {
+			return true
+		}
+	}
+	return false
+}
+
+// AvoidedLastOctets returns the sorted last octets of the IPv4 addresses
+// not allocated from the pool.
+func (p *Pool) AvoidedLastOctets() []uint8 {
+	if !p.AvoidBuggyIPs {
+		return p.AvoidLastOctets
+	}
+	return sortedOctets(append([]int{0, 255}, octetsToInts(p.AvoidLastOctets)...))
+}
+
+// DualMode returns true if the pool is advertised via both L2 and BGP, in
+// which case its IPs are announced with both protocols.
+func (p *Pool) DualMode() bool {
+	return len(p.L2Advertisements) > 0 && len(p.BGPAdvertisements) > 0
 }
 
 // ServiceAllocation makes ip pool allocation to specific namespace and/or service.
@@ -152,6 +356,25 @@ type ServiceAllocation struct {
 	// Service selectors to select service for which ip pool can be used
 	// for ip allocation.
 	ServiceSelectors []labels.Selector
+	// Ranges of ports the service must expose one of for the ip pool to
+	// be used for its ip allocation.
+	ServicePorts []PortRange
+}
+
+// MatchesPorts returns true if one of the given ports is in the service
+// ports of the allocation, or if no service ports are set.
+func (s *ServiceAllocation) MatchesPorts(ports []int) bool {
+	if len(s.ServicePorts) == 0 {
+		return true
+	}
+	for _, port := range ports {
+		for _, r := range s.ServicePorts {
+			if port >= int(r.Min) && port <= int(r.Max) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // BGPAdvertisement describes one translation from an IP address to a BGP advertisement.
@@ -165,9 +388,23 @@ type BGPAdvertisement struct {
 	// Optional, defaults to 128 (i.e. no aggregation) if not
 	// specified.
 	AggregationLengthV6 int
+	// The other lengths the IP addresses are advertised with at the
+	// same time, by family.
+	AdditionalAggregationLengths   []int
+	AdditionalAggregationLengthsV6 []int
+	// Advertise each IPv4 address as the /31 covering it and its
+	// paired address.
+	PointToPointPairs bool
+	// Suppress the more specific prefixes covered by the aggregates.
+	SummaryOnly bool
 	// Value of the LOCAL_PREF BGP path attribute. Used only when
 	// advertising to IBGP peers (i.e. Peer.MyASN == Peer.ASN).
 	LocalPref uint32
+	// The bandwidth in Mbps of the link-bandwidth extended community
+	// per ready endpoint of the service on the node, 0 means unset.
+	LinkBandwidthPerEndpoint uint32
+	// The value of the color extended community, 0 means unset.
+	Color uint32
 	// Value of the COMMUNITIES path attribute.
 	Communities map[uint32]bool
 	// The map of nodes allowed for this advertisement
@@ -175,6 +412,113 @@ type BGPAdvertisement struct {
 	// Used to declare the intent of announcing IPs
 	// only to the BGPPeers in this list.
 	Peers []string
+	// The aggregation lengths overridden for some peers, by peer name.
+	PeerAggregations map[string]*PeerAggregation
+	// The communities added for some peers, by peer name.
+	PeerCommunities map[string]map[uint32]bool
+	// When set, the IPs are advertised as EVPN type-5 routes.
+	EVPN *EVPN
+	// The family of the IPs the advertisement applies to, empty
+	// means both families.
+	IPFamily ipfamily.Family
+	// Announce the services with the Local traffic policy from the
+	// selected nodes when none of them has a ready endpoint.
+	FallbackToSelectedNodes bool
+	// How long the IPs of a service are still announced from the
+	// nodes with an endpoint, ready or not, once none is ready.
+	NotReadyGracePeriod time.Duration
+	// Announce the IPs of a service from a single elected node.
+	SingleNode bool
+	// Value of the ORIGIN BGP path attribute, empty means IGP.
+	Origin string
+	// The label grouping the nodes. When set, a node announces the
+	// IPs of a service only if it has a ready endpoint in the group.
+	TopologyKey string
+	// The group of the selected nodes having the TopologyKey label,
+	// by node name.
+	NodeGroups map[string]string
+	// The label of the services whose values map to the communities
+	// in ServiceLabelCommunities, empty if none.
+	ServiceLabel            string
+	ServiceLabelCommunities map[string]uint32
+}
+
+// The values of the ORIGIN BGP path attribute.
+const (
+	OriginIGP        = "igp"
+	OriginEGP        = "egp"
+	OriginIncomplete = "incomplete"
+)
+
+// MaxLinkBandwidth is the highest bandwidth in Mbps of the link-bandwidth
+// extended community.
+const MaxLinkBandwidth = 25600
+
+// minTTLSecurity is the lowest minimum TTL of the packets received from a
+// peer, as FRR accepts the peers at most 254 hops away.
+const minTTLSecurity = 2
+
+// PeerAggregation holds the aggregation lengths a BGPAdvertisement uses
+// for a given peer.
+type PeerAggregation struct {
+	AggregationLength   int
+	AggregationLengthV6 int
+}
+
+// MatchesIP tells if the advertisement applies to the given IP.
+func (a *BGPAdvertisement) MatchesIP(ip net.IP) bool {
+	if a.IPFamily == "" {
+		return true
+	}
+	return a.IPFamily == ipfamily.ForAddress(ip)
+}
+
+// EVPN describes how the IPs of an advertisement are exported as EVPN type-5 routes.
+type EVPN struct {
+	// The L3 VNI of the VRF the routes are exported from.
+	VNI uint32
+	// The route distinguisher, empty means derived by the router.
+	RouteDistinguisher string
+	// The route target to export with, empty means derived by the router.
+	RouteTarget string
+}
+
+// NodeAdvertisement describes a set of prefixes advertised via BGP by each of
+// the selected nodes, independently of the services.
+type NodeAdvertisement struct {
+	// The name of the advertisement.
+	Name string
+	// The prefixes to advertise.
+	Prefixes []*net.IPNet
+	// Value of the LOCAL_PREF BGP path attribute. Used only when
+	// advertising to IBGP peers (i.e. Peer.MyASN == Peer.ASN).
+	LocalPref uint32
+	// Value of the COMMUNITIES path attribute.
+	Communities map[uint32]bool
+	// The map of nodes advertising the prefixes.
+	Nodes map[string]bool
+	// Used to declare the intent of announcing the prefixes
+	// only to the BGPPeers in this list.
+	Peers []string
+	// When set, each node advertises the prefixes only while the
+	// check succeeds locally.
+	HealthCheck *NodeHealthCheck
+}
+
+// NodeHealthCheck describes the check a speaker performs against a local
+// endpoint before advertising the prefixes of a NodeAdvertisement.
+type NodeHealthCheck struct {
+	// The endpoint to check, in host:port form.
+	Address string
+	// When not empty, the check is an HTTP GET of the path instead
+	// of a TCP connection.
+	Path string
+	// How often the check is performed.
+	Interval time.Duration
+	// How long a check can take before being considered failed.
+	Timeout time.Duration
+	// The consecutive failures after which the prefixes are withdrawn.
+	FailureThreshold int
 }
 
 type L2Advertisement struct {
@@ -184,6 +528,17 @@ type L2Advertisement struct {
 	Interfaces []string
 	// AllInterfaces tells if all the interfaces are allowed for this advertisement
 	AllInterfaces bool
+	// The weight used to prefer a node when electing the announcing one, nil
+	// when all the nodes are equally preferred.
+	NodeWeights map[string]int
+	// Announce the services with the Local traffic policy from the
+	// selected nodes when none of them has a ready endpoint.
+	FallbackToSelectedNodes bool
+	// How long the IPs of a service are still announced from the
+	// nodes with an endpoint, ready or not, once none is ready.
+	NotReadyGracePeriod time.Duration
+	// The host VRF the IPs are announced in, empty for the default one.
+	VRF string
 }
 
 // BFDProfile describes a BFD profile to be applied to a set of peers.
@@ -226,6 +581,18 @@ func For(resources ClusterResources, validate Validate) (*Config, error) {
 		return nil, err
 	}
 
+	cfg.NodeAdvertisements, err = nodeAdvertisementsFor(resources, cfg.Pools)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.NodeScores = nodeScores(resources.Nodes)
+	cfg.NodeL2Weights, err = nodeL2Weights(resources.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Maintenances = maintenances(resources.Maintenances)
+
 	err = validateConfig(cfg)
 	if err != nil {
 		return nil, err
@@ -250,8 +617,12 @@ func bfdProfilesFor(resources ClusterResources) (map[string]*BFDProfile, error)
 
 func peersFor(resources ClusterResources, BFDProfiles map[string]*BFDProfile) (map[string]*Peer, error) {
 	var res map[string]*Peer = make(map[string]*Peer)
+	communities, err := communitiesFromCrs(resources.Communities)
+	if err != nil {
+		return nil, err
+	}
 	for _, p := range resources.Peers {
-		peer, err := peerFromCR(p, resources.PasswordSecrets)
+		peer, err := peerFromCR(p, resources.PasswordSecrets, communities)
 		if err != nil {
 			return nil, errors.Wrapf(err, "parsing peer %s", p.Name)
 		}
@@ -267,6 +638,10 @@ func peersFor(resources ClusterResources, BFDProfiles map[string]*BFDProfile) (m
 			if reflect.DeepEqual(peer, ep) {
 				return nil, fmt.Errorf("peer %s already exists", p.Name)
 			}
+			// The same source port can't be bound by two sessions at the same time.
+			if peer.SrcPorts != nil && ep.SrcPorts != nil && peer.SrcPorts.Overlaps(ep.SrcPorts) {
+				return nil, fmt.Errorf("peer %s source ports %d-%d overlap with the ones of peer %s", p.Name, peer.SrcPorts.Min, peer.SrcPorts.Max, ep.Name)
+			}
 		}
 		res[peer.Name] = peer
 	}
@@ -348,7 +723,36 @@ func poolsFor(resources ClusterResources) (*Pools, error) {
 		pools[p.Name] = pool
 	}
 	return &Pools{ByName: pools, ByNamespace: poolsByNamespace(pools),
-		ByServiceSelector: poolsByServiceSelector(pools)}, nil
+		ByServiceSelector: poolsByServiceSelector(pools),
+		ByServicePorts:    poolsByServicePorts(pools)}, nil
+}
+
+func nodeAdvertisementsFor(resources ClusterResources, pools *Pools) (map[string]*NodeAdvertisement, error) {
+	if len(resources.NodeAdvs) == 0 {
+		return nil, nil
+	}
+	communities, err := communitiesFromCrs(resources.Communities)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*NodeAdvertisement)
+	for _, crdAd := range resources.NodeAdvs {
+		ad, err := nodeAdvertisementFromCR(crdAd, communities, resources.Nodes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing node advertisement %s", crdAd.Name)
+		}
+		for _, prefix := range ad.Prefixes {
+			for _, pool := range pools.ByName {
+				for _, cidr := range pool.CIDR {
+					if cidrsOverlap(prefix, cidr) {
+						return nil, fmt.Errorf("prefix %s of node advertisement %s overlaps with CIDR %s of pool %s", prefix, ad.Name, cidr, pool.Name)
+					}
+				}
+			}
+		}
+		res[ad.Name] = ad
+	}
+	return res, nil
 }
 
 func communitiesFromCrs(cs []metallbv1beta1.Community) (map[string]uint32, error) {
@@ -368,7 +772,7 @@ func communitiesFromCrs(cs []metallbv1beta1.Community) (map[string]uint32, error
 	return communities, nil
 }
 
-func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secret) (*Peer, error) {
+func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secret, communities map[string]uint32) (*Peer, error) {
 	if p.Spec.MyASN == 0 {
 		return nil, errors.New("missing local ASN")
 	}
@@ -378,6 +782,16 @@ func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secr
 	if p.Spec.ASN == p.Spec.MyASN && p.Spec.EBGPMultiHop {
 		return nil, errors.New("invalid ebgp-multihop parameter set for an ibgp peer")
 	}
+	if p.Spec.MinimumTTL != nil {
+		if *p.Spec.MinimumTTL < minTTLSecurity || *p.Spec.MinimumTTL > 255 {
+			return nil, fmt.Errorf("invalid minimum ttl %d, must be between %d and 255", *p.Spec.MinimumTTL, minTTLSecurity)
+		}
+		// Without multi hop, an eBGP peer is expected to be directly connected,
+		// so its packets reach the node with their TTL unchanged.
+		if p.Spec.ASN != p.Spec.MyASN && !p.Spec.EBGPMultiHop && *p.Spec.MinimumTTL != 255 {
+			return nil, fmt.Errorf("invalid minimum ttl %d for an ebgp peer one hop away, must be 255 unless ebgp-multihop is set", *p.Spec.MinimumTTL)
+		}
+	}
 	ip := net.ParseIP(p.Spec.Address)
 	if ip == nil {
 		return nil, fmt.Errorf("invalid BGPPeer address %q", p.Spec.Address)
@@ -415,6 +829,22 @@ func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secr
 		return nil, fmt.Errorf("invalid source IP %q", p.Spec.SrcAddress)
 	}
 
+	var srcPorts *PortRange
+	if p.Spec.SrcPortRange != "" {
+		srcPorts, err = parsePortRange(p.Spec.SrcPortRange)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source port range %q: %s", p.Spec.SrcPortRange, err)
+		}
+	}
+
+	var tcpKeepalive *TCPKeepalive
+	if p.Spec.TCPKeepalive != nil {
+		tcpKeepalive, err = tcpKeepaliveFromCR(p.Spec.TCPKeepalive)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tcp keepalive for peer %s: %s", p.Name, err)
+		}
+	}
+
 	err = validateLabelSelectorDuplicate(p.Spec.NodeSelectors, "nodeSelectors")
 	if err != nil {
 		return nil, err
@@ -438,24 +868,196 @@ func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secr
 		return nil, err
 	}
 
+	var addPath *AddPath
+	if p.Spec.AddPath != nil {
+		addPath = &AddPath{Send: p.Spec.AddPath.Send, Receive: p.Spec.AddPath.Receive}
+	}
+
+	var importFilter *ImportFilter
+	if p.Spec.ImportFilter != nil {
+		importFilter, err = importFilterFromCR(p.Spec.ImportFilter, communities)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid import filter for peer %s", p.Name)
+		}
+	}
+
+	addressFamilies, err := addressFamiliesFromCR(p.Spec.AddressFamilies)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid address families for peer %s", p.Name)
+	}
+
 	return &Peer{
-		Name:          p.Name,
-		MyASN:         p.Spec.MyASN,
-		ASN:           p.Spec.ASN,
-		Addr:          ip,
-		SrcAddr:       src,
-		Port:          p.Spec.Port,
-		HoldTime:      holdTime,
-		KeepaliveTime: keepaliveTime,
-		RouterID:      routerID,
-		NodeSelectors: nodeSels,
-		Password:      password,
-		BFDProfile:    p.Spec.BFDProfile,
-		EBGPMultiHop:  p.Spec.EBGPMultiHop,
-		VRF:           p.Spec.VRFName,
+		Name:            p.Name,
+		MyASN:           p.Spec.MyASN,
+		ASN:             p.Spec.ASN,
+		Addr:            ip,
+		SrcAddr:         src,
+		SrcPorts:        srcPorts,
+		TCPKeepalive:    tcpKeepalive,
+		Port:            p.Spec.Port,
+		HoldTime:        holdTime,
+		KeepaliveTime:   keepaliveTime,
+		RouterID:        routerID,
+		NodeSelectors:   nodeSels,
+		Password:        password,
+		BFDProfile:      p.Spec.BFDProfile,
+		EBGPMultiHop:    p.Spec.EBGPMultiHop,
+		MinimumTTL:      p.Spec.MinimumTTL,
+		VRF:             p.Spec.VRFName,
+		ImportFilter:    importFilter,
+		AddPath:         addPath,
+		NextHopSelf:     p.Spec.NextHopSelf,
+		AddressFamilies: addressFamilies,
+		Labels:          p.Labels,
 	}, nil
 }
 
+// addressFamiliesFromCR returns the families of the IPs advertised to a
+// peer, nil meaning both. The list, when set, must hold at least one family.
+func addressFamiliesFromCR(families []metallbv1beta2.AddressFamily) ([]ipfamily.Family, error) {
+	if families == nil {
+		return nil, nil
+	}
+	if len(families) == 0 {
+		return nil, errors.New("at least one address family must be set")
+	}
+	res := make([]ipfamily.Family, 0, len(families))
+	seen := map[ipfamily.Family]bool{}
+	for _, f := range families {
+		family := ipfamily.Family(f)
+		if family != ipfamily.IPv4 && family != ipfamily.IPv6 {
+			return nil, fmt.Errorf("invalid address family %q, must be ipv4 or ipv6", f)
+		}
+		if seen[family] {
+			return nil, fmt.Errorf("duplicate address family %q", f)
+		}
+		seen[family] = true
+		res = append(res, family)
+	}
+	return res, nil
+}
+
+// parsePortRange parses a port, or a range of ports of the form "min-max".
+func parsePortRange(r string) (*PortRange, error) {
+	bounds := strings.SplitN(r, "-", 2)
+	min, err := strconv.ParseUint(bounds[0], 10, 16)
+	if err != nil || min == 0 {
+		return nil, fmt.Errorf("invalid port %q", bounds[0])
+	}
+	max := min
+	if len(bounds) == 2 {
+		max, err = strconv.ParseUint(bounds[1], 10, 16)
+		if err != nil || max == 0 {
+			return nil, fmt.Errorf("invalid port %q", bounds[1])
+		}
+	}
+	if min > max {
+		return nil, fmt.Errorf("the first port %d is greater than the last port %d", min, max)
+	}
+	return &PortRange{Min: uint16(min), Max: uint16(max)}, nil
+}
+
+// maxTCPKeepaliveTime is the longest idle time and interval the kernel
+// accepts for the TCP keepalives.
+const maxTCPKeepaliveTime = 32767 * time.Second
+
+func tcpKeepaliveFromCR(k *metallbv1beta2.TCPKeepalive) (*TCPKeepalive, error) {
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{{"idle", k.Idle.Duration}, {"interval", k.Interval.Duration}} {
+		if d.value < 0 || d.value > maxTCPKeepaliveTime {
+			return nil, fmt.Errorf("%s %q must be between 0 and %s", d.name, d.value, maxTCPKeepaliveTime)
+		}
+		if d.value%time.Second != 0 {
+			return nil, fmt.Errorf("%s %q must be a whole number of seconds", d.name, d.value)
+		}
+	}
+	if k.Count > 127 {
+		return nil, fmt.Errorf("count %d must be lower than 128", k.Count)
+	}
+	return &TCPKeepalive{
+		Idle:     k.Idle.Duration,
+		Interval: k.Interval.Duration,
+		Count:    k.Count,
+	}, nil
+}
+
+func importFilterFromCR(f *metallbv1beta2.ImportFilter, communities map[string]uint32) (*ImportFilter, error) {
+	if len(f.Prefixes) == 0 && len(f.Communities) == 0 {
+		return nil, errors.New("at least one of prefixes and communities must be set")
+	}
+	if err := validateDuplicate(f.Prefixes, "prefixes"); err != nil {
+		return nil, err
+	}
+	if err := validateDuplicate(f.Communities, "communities"); err != nil {
+		return nil, err
+	}
+	res := &ImportFilter{}
+	for _, p := range f.Prefixes {
+		prefix, err := parseImportPrefix(p)
+		if err != nil {
+			return nil, err
+		}
+		res.Prefixes = append(res.Prefixes, prefix)
+	}
+	for _, c := range f.Communities {
+		v, err := getCommunityValue(c, communities)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid community %q", c)
+		}
+		res.Communities = append(res.Communities, v)
+	}
+	return res, nil
+}
+
+// parseImportPrefix parses a prefix list entry of the form
+// "<prefix> [ge <length>] [le <length>]", enforcing the same constraints
+// as FRR: length < ge <= le <= max length.
+func parseImportPrefix(entry string) (ImportPrefix, error) {
+	fields := strings.Fields(entry)
+	if len(fields) == 0 {
+		return ImportPrefix{}, fmt.Errorf("empty prefix")
+	}
+	ip, prefix, err := net.ParseCIDR(fields[0])
+	if err != nil {
+		return ImportPrefix{}, fmt.Errorf("invalid prefix %q", fields[0])
+	}
+	if !ip.Equal(prefix.IP) {
+		return ImportPrefix{}, fmt.Errorf("invalid prefix %q, host bits must not be set", fields[0])
+	}
+	res := ImportPrefix{Prefix: prefix}
+	length, maxLength := prefix.Mask.Size()
+
+	fields = fields[1:]
+	for len(fields) > 0 {
+		if len(fields) < 2 {
+			return ImportPrefix{}, fmt.Errorf("invalid prefix entry %q, missing length after %s", entry, fields[0])
+		}
+		v, err := strconv.Atoi(fields[1])
+		if err != nil || v <= length || v > maxLength {
+			return ImportPrefix{}, fmt.Errorf("invalid prefix entry %q, %s length must be in the %d-%d range", entry, fields[0], length+1, maxLength)
+		}
+		switch {
+		case fields[0] == "ge" && res.GE == 0 && res.LE == 0:
+			res.GE = v
+		case fields[0] == "le" && res.LE == 0:
+			res.LE = v
+		default:
+			return ImportPrefix{}, fmt.Errorf("invalid prefix entry %q, unexpected %s", entry, fields[0])
+		}
+		fields = fields[2:]
+	}
+	if res.GE != 0 && res.LE != 0 && res.GE > res.LE {
+		return ImportPrefix{}, fmt.Errorf("invalid prefix entry %q, ge must not be greater than le", entry)
+	}
+	return res, nil
+}
+
+// maxPasswordLength is the length of the longest TCP MD5 key the kernel
+// accepts, TCP_MD5SIG_MAXKEYLEN.
+const maxPasswordLength = 80
+
 func passwordForPeer(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secret) (string, error) {
 	if p.Spec.Password != "" && p.Spec.PasswordSecret.Name != "" {
 		return "", fmt.Errorf("can not have both password and secret ref set in peer config %q/%q", p.Namespace,
@@ -479,9 +1081,45 @@ func passwordForPeer(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1
 		}
 		password = string(srcPass)
 	}
+	if len(password) > maxPasswordLength {
+		return "", fmt.Errorf("password of peer config %q/%q longer than %d characters", p.Namespace, p.Name, maxPasswordLength)
+	}
 	return password, nil
 }
 
+// avoidedLastOctetsFromCR returns the sorted, deduplicated last octets of
+// the addresses avoided by a pool.
+func avoidedLastOctetsFromCR(octets []int) ([]uint8, error) {
+	for _, o := range octets {
+		if o < 0 || o > 255 {
+			return nil, fmt.Errorf("octet %d must be between 0 and 255", o)
+		}
+	}
+	return sortedOctets(octets), nil
+}
+
+// sortedOctets returns the given octets sorted and deduplicated.
+func sortedOctets(octets []int) []uint8 {
+	seen := map[int]bool{}
+	res := []uint8{}
+	for _, o := range octets {
+		if !seen[o] {
+			seen[o] = true
+			res = append(res, uint8(o))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+func octetsToInts(octets []uint8) []int {
+	res := make([]int, 0, len(octets))
+	for _, o := range octets {
+		res = append(res, int(o))
+	}
+	return res
+}
+
 func addressPoolFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Namespace) (*Pool, error) {
 	if p.Name == "" {
 		return nil, errors.New("missing pool name")
@@ -491,6 +1129,11 @@ func addressPoolFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Names
 		Name:          p.Name,
 		AvoidBuggyIPs: p.Spec.AvoidBuggyIPs,
 		AutoAssign:    true,
+		Zone:          p.Labels[corev1.LabelTopologyZone],
+
+		ContiguousNamespaces: p.Spec.ContiguousNamespaces,
+		PreferBGP:            p.Spec.PreferBGP,
+		AnnouncementPriority: int(p.Spec.AnnouncementPriority),
 	}
 
 	if p.Spec.AutoAssign != nil {
@@ -501,6 +1144,31 @@ func addressPoolFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Names
 		return nil, errors.New("pool has no prefixes defined")
 	}
 
+	if len(p.Spec.AvoidLastOctets) > 0 {
+		octets, err := avoidedLastOctetsFromCR(p.Spec.AvoidLastOctets)
+		if err != nil {
+			return nil, fmt.Errorf("invalid avoidLastOctets in pool %q: %s", p.Name, err)
+		}
+		ret.AvoidLastOctets = octets
+	}
+
+	if p.Spec.AllocationStride > 1 {
+		if p.Spec.AllocationOffset >= p.Spec.AllocationStride {
+			return nil, fmt.Errorf("invalid allocationOffset %d in pool %q: must be lower than the allocationStride %d", p.Spec.AllocationOffset, p.Name, p.Spec.AllocationStride)
+		}
+		ret.AllocationStride = p.Spec.AllocationStride
+		ret.AllocationOffset = p.Spec.AllocationOffset
+	} else if p.Spec.AllocationOffset != 0 {
+		return nil, fmt.Errorf("invalid allocationOffset %d in pool %q: requires an allocationStride greater than 1", p.Spec.AllocationOffset, p.Name)
+	}
+
+	switch mode := AllocationMode(p.Spec.AllocationMode); mode {
+	case "", AllocationLowest, AllocationHighest:
+		ret.AllocationMode = mode
+	default:
+		return nil, fmt.Errorf("invalid allocationMode %q in pool %q: must be %s or %s", p.Spec.AllocationMode, p.Name, AllocationLowest, AllocationHighest)
+	}
+
 	ret.cidrsPerAddresses = map[string][]*net.IPNet{}
 	for _, cidr := range p.Spec.Addresses {
 		nets, err := ParseCIDR(cidr)
@@ -511,6 +1179,10 @@ func addressPoolFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Names
 		ret.cidrsPerAddresses[cidr] = nets
 	}
 
+	if ret.AllocationStride > 1 && !hasStridedAddress(ret) {
+		return nil, fmt.Errorf("pool %q has no address matching the allocationStride %d and allocationOffset %d", p.Name, ret.AllocationStride, ret.AllocationOffset)
+	}
+
 	serviceAllocations, err := addressPoolServiceAllocationsFromCR(p, namespaces)
 	if err != nil {
 		return nil, err
@@ -520,6 +1192,21 @@ func addressPoolFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Names
 	return ret, nil
 }
 
+// hasStridedAddress tells if at least one of the addresses of the pool
+// satisfies its allocation stride.
+func hasStridedAddress(p *Pool) bool {
+	for _, cidr := range p.CIDR {
+		ones, bits := cidr.Mask.Size()
+		if bits-ones >= 32 {
+			return true
+		}
+		if uint64(p.StrideDistance(cidr.IP.Mask(cidr.Mask))) < uint64(1)<<(bits-ones) {
+			return true
+		}
+	}
+	return false
+}
+
 func addressPoolServiceAllocationsFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Namespace) (*ServiceAllocation, error) {
 	if p.Spec.AllocateTo == nil {
 		return nil, nil
@@ -545,6 +1232,13 @@ func addressPoolServiceAllocationsFromCR(p metallbv1beta1.IPAddressPool, namespa
 		}
 		serviceAllocations.ServiceSelectors = append(serviceAllocations.ServiceSelectors, l)
 	}
+	for _, ports := range p.Spec.AllocateTo.ServicePorts {
+		r, err := parsePortRange(ports)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid service port range %q in ip pool %s", ports, p.Name)
+		}
+		serviceAllocations.ServicePorts = append(serviceAllocations.ServicePorts, *r)
+	}
 	return serviceAllocations, nil
 }
 
@@ -576,6 +1270,18 @@ func poolsByServiceSelector(pools map[string]*Pool) []string {
 	return poolsByServiceSelector
 }
 
+func poolsByServicePorts(pools map[string]*Pool) []string {
+	var poolsByServicePorts []string
+	for _, pool := range pools {
+		if pool.ServiceAllocations == nil || len(pool.ServiceAllocations.ServicePorts) == 0 {
+			continue
+		}
+		poolsByServicePorts = append(poolsByServicePorts, pool.Name)
+	}
+	sort.Strings(poolsByServicePorts)
+	return poolsByServicePorts
+}
+
 func addressPoolFromLegacyCR(p metallbv1beta1.AddressPool, bgpCommunities map[string]uint32, allNodes map[string]bool) (*Pool, error) {
 	if p.Name == "" {
 		return nil, errors.New("missing pool name")
@@ -678,6 +1384,9 @@ func setL2AdvertisementsToPools(ipPools []metallbv1beta1.IPAddressPool, l2Advs [
 		// No pool selector means select all pools
 		if len(l2Adv.Spec.IPAddressPools) == 0 && len(l2Adv.Spec.IPAddressPoolSelectors) == 0 {
 			for _, pool := range ipPoolMap {
+				if err := validateL2VRF(pool, l2Adv.Name, adv); err != nil {
+					return err
+				}
 				if !containsAdvertisement(pool.L2Advertisements, adv) {
 					pool.L2Advertisements = append(pool.L2Advertisements, adv)
 				}
@@ -686,6 +1395,9 @@ func setL2AdvertisementsToPools(ipPools []metallbv1beta1.IPAddressPool, l2Advs [
 		}
 		for _, poolName := range append(l2Adv.Spec.IPAddressPools, ipPoolsSelected...) {
 			if pool, ok := ipPoolMap[poolName]; ok {
+				if err := validateL2VRF(pool, l2Adv.Name, adv); err != nil {
+					return err
+				}
 				if !containsAdvertisement(pool.L2Advertisements, adv) {
 					pool.L2Advertisements = append(pool.L2Advertisements, adv)
 				}
@@ -727,6 +1439,14 @@ func setBGPAdvertisementsToPools(ipPools []metallbv1beta1.IPAddressPool, bgpAdvs
 			}
 		}
 	}
+	for _, pool := range ipPoolMap {
+		if err := validateBGPAdvConflicts(pool); err != nil {
+			return err
+		}
+		if err := validatePairedAddresses(pool, ipPoolMap); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -747,16 +1467,54 @@ func l2AdvertisementFromCR(crdAd metallbv1beta1.L2Advertisement, nodes []corev1.
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to parse node selector for %s", crdAd.Name)
 	}
+	var notReadyGracePeriod time.Duration
+	if crdAd.Spec.NotReadyGracePeriod != nil {
+		notReadyGracePeriod = crdAd.Spec.NotReadyGracePeriod.Duration
+	}
+	if notReadyGracePeriod < 0 {
+		return nil, fmt.Errorf("invalid not ready grace period %s in L2 advertisement %s, must not be negative", notReadyGracePeriod, crdAd.Name)
+	}
 	l2 := &L2Advertisement{
-		Nodes:      selected,
-		Interfaces: crdAd.Spec.Interfaces,
+		Nodes:                   selected,
+		Interfaces:              crdAd.Spec.Interfaces,
+		FallbackToSelectedNodes: crdAd.Spec.FallbackToSelectedNodes,
+		NotReadyGracePeriod:     notReadyGracePeriod,
+		VRF:                     crdAd.Spec.VRF,
 	}
 	if len(crdAd.Spec.Interfaces) == 0 {
 		l2.AllInterfaces = true
 	}
+	if len(crdAd.Spec.NodePreferences) > 0 {
+		l2.NodeWeights, err = nodeWeights(nodes, crdAd.Spec.NodePreferences)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to parse node preferences for %s", crdAd.Name)
+		}
+	}
 	return l2, nil
 }
 
+// nodeWeights returns, for each node, the sum of the weights of the
+// preferences matching it.
+func nodeWeights(nodes []corev1.Node, preferences []metallbv1beta1.NodePreference) (map[string]int, error) {
+	res := make(map[string]int)
+	for _, p := range preferences {
+		if p.Weight < 1 || p.Weight > 100 {
+			return nil, fmt.Errorf("invalid node preference weight %d, must be between 1 and 100", p.Weight)
+		}
+		p := p // so we can use &p.Preference
+		selector, err := metav1.LabelSelectorAsSelector(&p.Preference)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid label selector %v", p.Preference)
+		}
+		for _, node := range nodes {
+			if selector.Matches(labels.Set(node.Labels)) {
+				res[node.Name] += int(p.Weight)
+			}
+		}
+	}
+	return res, nil
+}
+
 func bgpAdvertisementFromCR(crdAd metallbv1beta1.BGPAdvertisement, communities map[string]uint32, nodes []corev1.Node) (*BGPAdvertisement, error) {
 	err := validateDuplicate(crdAd.Spec.IPAddressPools, "ipAddressPools")
 	if err != nil {
@@ -793,14 +1551,46 @@ func bgpAdvertisementFromCR(crdAd metallbv1beta1.BGPAdvertisement, communities m
 	if ad.AggregationLength > 32 {
 		return nil, fmt.Errorf("invalid aggregation length %q for IPv4", ad.AggregationLength)
 	}
+	if crdAd.Spec.PointToPointPairs {
+		if ad.AggregationLength != 32 {
+			return nil, fmt.Errorf("invalid aggregation length %d in BGP advertisement %s, can't be combined with pointToPointPairs", ad.AggregationLength, crdAd.Name)
+		}
+		ad.PointToPointPairs = true
+		ad.AggregationLength = 31
+	}
 	if crdAd.Spec.AggregationLengthV6 != nil {
 		ad.AggregationLengthV6 = int(*crdAd.Spec.AggregationLengthV6) // TODO CRD cast
 		if ad.AggregationLengthV6 > 128 {
 			return nil, fmt.Errorf("invalid aggregation length %q for IPv6", ad.AggregationLengthV6)
 		}
 	}
+	ad.AdditionalAggregationLengths, err = additionalAggregationLengths(crdAd.Spec.AdditionalAggregationLengths, ad.AggregationLength, 32, "IPv4")
+	if err != nil {
+		return nil, err
+	}
+	ad.AdditionalAggregationLengthsV6, err = additionalAggregationLengths(crdAd.Spec.AdditionalAggregationLengthsV6, ad.AggregationLengthV6, 128, "IPv6")
+	if err != nil {
+		return nil, err
+	}
+	if crdAd.Spec.SummaryOnly && ad.AggregationLength == 32 && ad.AggregationLengthV6 == 128 {
+		return nil, fmt.Errorf("invalid summaryOnly in BGP advertisement %s, requires an aggregation length shorter than 32 or 128", crdAd.Name)
+	}
+	ad.SummaryOnly = crdAd.Spec.SummaryOnly
 
 	ad.LocalPref = crdAd.Spec.LocalPref
+	if crdAd.Spec.LinkBandwidthPerEndpoint > MaxLinkBandwidth {
+		return nil, fmt.Errorf("invalid link bandwidth per endpoint %d in BGP advertisement %s, must be at most %d", crdAd.Spec.LinkBandwidthPerEndpoint, crdAd.Name, MaxLinkBandwidth)
+	}
+	ad.LinkBandwidthPerEndpoint = crdAd.Spec.LinkBandwidthPerEndpoint
+	ad.Color = crdAd.Spec.Color
+	ad.FallbackToSelectedNodes = crdAd.Spec.FallbackToSelectedNodes
+	if crdAd.Spec.NotReadyGracePeriod != nil {
+		if crdAd.Spec.NotReadyGracePeriod.Duration < 0 {
+			return nil, fmt.Errorf("invalid not ready grace period %s in BGP advertisement %s, must not be negative", crdAd.Spec.NotReadyGracePeriod.Duration, crdAd.Name)
+		}
+		ad.NotReadyGracePeriod = crdAd.Spec.NotReadyGracePeriod.Duration
+	}
+	ad.SingleNode = crdAd.Spec.SingleNode
 
 	if len(crdAd.Spec.Peers) > 0 {
 		ad.Peers = make([]string, 0, len(crdAd.Spec.Peers))
@@ -815,14 +1605,320 @@ func bgpAdvertisementFromCR(crdAd metallbv1beta1.BGPAdvertisement, communities m
 		ad.Communities[v] = true
 	}
 
+	if lc := crdAd.Spec.ServiceLabelCommunities; lc != nil {
+		if errs := validation.IsQualifiedName(lc.Label); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid service label %q in BGP advertisement %s: %s", lc.Label, crdAd.Name, strings.Join(errs, ", "))
+		}
+		ad.ServiceLabel = lc.Label
+		ad.ServiceLabelCommunities = map[string]uint32{}
+		for value, c := range lc.Communities {
+			v, err := getCommunityValue(c, communities)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid community %q for the value %q of the service label in BGP advertisement", c, value)
+			}
+			ad.ServiceLabelCommunities[value] = v
+		}
+	}
+
+	switch ipfamily.Family(crdAd.Spec.IPFamily) {
+	case "", ipfamily.IPv4, ipfamily.IPv6:
+		ad.IPFamily = ipfamily.Family(crdAd.Spec.IPFamily)
+	default:
+		return nil, fmt.Errorf("invalid ip family %q in BGP advertisement %s, must be ipv4 or ipv6", crdAd.Spec.IPFamily, crdAd.Name)
+	}
+
+	switch crdAd.Spec.Origin {
+	case "", OriginIGP, OriginEGP, OriginIncomplete:
+		ad.Origin = crdAd.Spec.Origin
+	default:
+		return nil, fmt.Errorf("invalid origin %q in BGP advertisement %s, must be igp, egp or incomplete", crdAd.Spec.Origin, crdAd.Name)
+	}
+
+	if len(crdAd.Spec.PeerAggregations) > 0 {
+		ad.PeerAggregations, err = peerAggregationsFromCR(crdAd.Spec.PeerAggregations, ad)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid peer aggregations in BGP advertisement %s", crdAd.Name)
+		}
+	}
+
+	if len(crdAd.Spec.PeerCommunities) > 0 {
+		ad.PeerCommunities, err = peerCommunitiesFromCR(crdAd.Spec.PeerCommunities, ad, communities)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid peer communities in BGP advertisement %s", crdAd.Name)
+		}
+	}
+
+	if crdAd.Spec.EVPN != nil {
+		ad.EVPN, err = evpnFromCR(crdAd.Spec.EVPN)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid evpn settings in BGP advertisement %s", crdAd.Name)
+		}
+	}
+
 	selected, err := selectedNodes(nodes, crdAd.Spec.NodeSelectors)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to parse node selector for ls %s", crdAd.Name)
 	}
 	ad.Nodes = selected
+
+	if crdAd.Spec.TopologyKey != "" {
+		if errs := validation.IsQualifiedName(crdAd.Spec.TopologyKey); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid topology key %q in BGP advertisement %s: %s", crdAd.Spec.TopologyKey, crdAd.Name, strings.Join(errs, ", "))
+		}
+		ad.TopologyKey = crdAd.Spec.TopologyKey
+		ad.NodeGroups = map[string]string{}
+		for _, n := range nodes {
+			group, ok := n.Labels[ad.TopologyKey]
+			if ok && ad.Nodes[n.Name] {
+				ad.NodeGroups[n.Name] = group
+			}
+		}
+	}
 	return ad, nil
 }
 
+// additionalAggregationLengths returns the additional aggregation lengths
+// of an advertisement for the given family, which must be distinct from
+// each other and from the aggregation length of the advertisement.
+func additionalAggregationLengths(lengths []int32, length, maxLength int, family string) ([]int, error) {
+	var res []int
+	seen := map[int]bool{length: true}
+	for _, l := range lengths {
+		if l < 1 || int(l) > maxLength {
+			return nil, fmt.Errorf("invalid additional aggregation length %d for %s", l, family)
+		}
+		if seen[int(l)] {
+			return nil, fmt.Errorf("duplicate aggregation length %d for %s", l, family)
+		}
+		seen[int(l)] = true
+		res = append(res, int(l))
+	}
+	return res, nil
+}
+
+// peerAggregationsFromCR returns the aggregation lengths of the given
+// advertisement overridden per peer, defaulting to the ones of the
+// advertisement.
+func peerAggregationsFromCR(crs []metallbv1beta1.PeerAggregation, ad *BGPAdvertisement) (map[string]*PeerAggregation, error) {
+	res := map[string]*PeerAggregation{}
+	for _, cr := range crs {
+		if cr.Peer == "" {
+			return nil, errors.New("missing peer name")
+		}
+		if _, ok := res[cr.Peer]; ok {
+			return nil, fmt.Errorf("duplicate aggregation for peer %s", cr.Peer)
+		}
+		if len(ad.Peers) > 0 && !sets.New(ad.Peers...).Has(cr.Peer) {
+			return nil, fmt.Errorf("peer %s is not one of the peers of the advertisement", cr.Peer)
+		}
+		agg := &PeerAggregation{
+			AggregationLength:   ad.AggregationLength,
+			AggregationLengthV6: ad.AggregationLengthV6,
+		}
+		if cr.AggregationLength != nil {
+			agg.AggregationLength = int(*cr.AggregationLength)
+			if agg.AggregationLength < 1 || agg.AggregationLength > 32 {
+				return nil, fmt.Errorf("invalid aggregation length %d for IPv4 for peer %s", agg.AggregationLength, cr.Peer)
+			}
+		}
+		if cr.AggregationLengthV6 != nil {
+			agg.AggregationLengthV6 = int(*cr.AggregationLengthV6)
+			if agg.AggregationLengthV6 < 1 || agg.AggregationLengthV6 > 128 {
+				return nil, fmt.Errorf("invalid aggregation length %d for IPv6 for peer %s", agg.AggregationLengthV6, cr.Peer)
+			}
+		}
+		res[cr.Peer] = agg
+	}
+	return res, nil
+}
+
+// peerCommunitiesFromCR returns the communities the given advertisement
+// adds per peer.
+func peerCommunitiesFromCR(crs []metallbv1beta1.PeerCommunities, ad *BGPAdvertisement, communities map[string]uint32) (map[string]map[uint32]bool, error) {
+	res := map[string]map[uint32]bool{}
+	for _, cr := range crs {
+		if cr.Peer == "" {
+			return nil, errors.New("missing peer name")
+		}
+		if _, ok := res[cr.Peer]; ok {
+			return nil, fmt.Errorf("duplicate communities for peer %s", cr.Peer)
+		}
+		if len(ad.Peers) > 0 && !sets.New(ad.Peers...).Has(cr.Peer) {
+			return nil, fmt.Errorf("peer %s is not one of the peers of the advertisement", cr.Peer)
+		}
+		if len(cr.Communities) == 0 {
+			return nil, fmt.Errorf("no communities for peer %s", cr.Peer)
+		}
+		res[cr.Peer] = map[uint32]bool{}
+		for _, c := range cr.Communities {
+			v, err := getCommunityValue(c, communities)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid community %q for peer %s", c, cr.Peer)
+			}
+			res[cr.Peer][v] = true
+		}
+	}
+	return res, nil
+}
+
+func nodeAdvertisementFromCR(crdAd metallbv1beta1.NodeAdvertisement, communities map[string]uint32, nodes []corev1.Node) (*NodeAdvertisement, error) {
+	if len(crdAd.Spec.Prefixes) == 0 {
+		return nil, errors.New("at least one prefix is required")
+	}
+	err := validateDuplicate(crdAd.Spec.Prefixes, "prefixes")
+	if err != nil {
+		return nil, err
+	}
+	err = validateDuplicate(crdAd.Spec.Communities, "community")
+	if err != nil {
+		return nil, err
+	}
+	err = validateDuplicate(crdAd.Spec.Peers, "peers")
+	if err != nil {
+		return nil, err
+	}
+	err = validateLabelSelectorDuplicate(crdAd.Spec.NodeSelectors, "nodeSelectors")
+	if err != nil {
+		return nil, err
+	}
+
+	ad := &NodeAdvertisement{
+		Name:        crdAd.Name,
+		LocalPref:   crdAd.Spec.LocalPref,
+		Communities: map[uint32]bool{},
+	}
+
+	for _, p := range crdAd.Spec.Prefixes {
+		ip, prefix, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %q", p)
+		}
+		if !ip.Equal(prefix.IP) {
+			return nil, fmt.Errorf("invalid prefix %q, host bits must be zero", p)
+		}
+		ad.Prefixes = append(ad.Prefixes, prefix)
+	}
+
+	if len(crdAd.Spec.Peers) > 0 {
+		ad.Peers = make([]string, 0, len(crdAd.Spec.Peers))
+		ad.Peers = append(ad.Peers, crdAd.Spec.Peers...)
+	}
+
+	for _, c := range crdAd.Spec.Communities {
+		v, err := getCommunityValue(c, communities)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid community %q in node advertisement", c)
+		}
+		ad.Communities[v] = true
+	}
+
+	if crdAd.Spec.HealthCheck != nil {
+		ad.HealthCheck, err = nodeHealthCheckFromCR(crdAd.Spec.HealthCheck)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid health check")
+		}
+	}
+
+	ad.Nodes, err = selectedNodes(nodes, crdAd.Spec.NodeSelectors)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse node selector for node advertisement %s", crdAd.Name)
+	}
+	return ad, nil
+}
+
+func nodeHealthCheckFromCR(c *metallbv1beta1.NodeHealthCheck) (*NodeHealthCheck, error) {
+	address := "127.0.0.1"
+	if c.Address != "" {
+		if net.ParseIP(c.Address) == nil {
+			return nil, fmt.Errorf("invalid address %q", c.Address)
+		}
+		address = c.Address
+	}
+	if c.Port == 0 {
+		return nil, errors.New("port is required")
+	}
+	if c.Path != "" && !strings.HasPrefix(c.Path, "/") {
+		return nil, fmt.Errorf("invalid path %q, must start with /", c.Path)
+	}
+
+	res := &NodeHealthCheck{
+		Address:          net.JoinHostPort(address, strconv.Itoa(int(c.Port))),
+		Path:             c.Path,
+		Interval:         5 * time.Second,
+		Timeout:          time.Second,
+		FailureThreshold: 1,
+	}
+	if c.Interval != nil {
+		res.Interval = c.Interval.Duration
+	}
+	if c.Timeout != nil {
+		res.Timeout = c.Timeout.Duration
+	}
+	if res.Interval <= 0 || res.Timeout <= 0 {
+		return nil, errors.New("interval and timeout must be positive")
+	}
+	if res.Timeout > res.Interval {
+		return nil, fmt.Errorf("timeout %s can't be greater than the interval %s", res.Timeout, res.Interval)
+	}
+	if c.FailureThreshold != 0 {
+		res.FailureThreshold = int(c.FailureThreshold)
+	}
+	return res, nil
+}
+
+func evpnFromCR(e *metallbv1beta1.EVPNAdvertisement) (*EVPN, error) {
+	if e.VNI < 1 || e.VNI > 16777215 {
+		return nil, fmt.Errorf("invalid vni %d, must be between 1 and 16777215", e.VNI)
+	}
+	if e.RouteDistinguisher != "" {
+		if err := validateRouteTag(e.RouteDistinguisher); err != nil {
+			return nil, errors.Wrapf(err, "invalid route distinguisher %q", e.RouteDistinguisher)
+		}
+	}
+	if e.RouteTarget != "" {
+		if err := validateRouteTag(e.RouteTarget); err != nil {
+			return nil, errors.Wrapf(err, "invalid route target %q", e.RouteTarget)
+		}
+	}
+	return &EVPN{
+		VNI:                e.VNI,
+		RouteDistinguisher: e.RouteDistinguisher,
+		RouteTarget:        e.RouteTarget,
+	}, nil
+}
+
+// validateRouteTag validates a route distinguisher or a route target, which
+// are either of the form ASN:NN or IP:NN.
+func validateRouteTag(tag string) error {
+	fields := strings.Split(tag, ":")
+	if len(fields) != 2 {
+		return errors.New("expected the form ASN:NN or IP:NN")
+	}
+	admin, assigned := fields[0], fields[1]
+	if ip := net.ParseIP(admin); ip != nil {
+		if ip.To4() == nil {
+			return errors.New("the administrator field must be an IPv4 address")
+		}
+		if _, err := strconv.ParseUint(assigned, 10, 16); err != nil {
+			return fmt.Errorf("invalid assigned number %q: %s", assigned, err)
+		}
+		return nil
+	}
+	asn, err := strconv.ParseUint(admin, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid administrator field %q: %s", admin, err)
+	}
+	// 2 bytes ASNs leave 4 bytes for the assigned number, 4 bytes ASNs leave 2.
+	bits := 32
+	if asn > 65535 {
+		bits = 16
+	}
+	if _, err := strconv.ParseUint(assigned, 10, bits); err != nil {
+		return fmt.Errorf("invalid assigned number %q: %s", assigned, err)
+	}
+	return nil
+}
+
 func bgpAdvertisementsFromLegacyCR(ads []metallbv1beta1.LegacyBgpAdvertisement, cidrsPerAddresses map[string][]*net.IPNet, communities map[string]uint32, allNodes map[string]bool) ([]*BGPAdvertisement, error) {
 	if len(ads) == 0 {
 		return []*BGPAdvertisement{
@@ -925,13 +2021,43 @@ func validateHoldTime(ht time.Duration) error {
 }
 
 func validateBGPAdvPerPool(adv *BGPAdvertisement, pool *Pool) error {
+	length := adv.AggregationLength
+	if adv.PointToPointPairs {
+		// The paired addresses out of the pool are validated once all the
+		// pools have their advertisements.
+		length = 32
+	}
+	err := validateAggregationLengthsPerPool(length, adv.AggregationLengthV6, pool)
+	if err != nil {
+		return err
+	}
+	for peer, agg := range adv.PeerAggregations {
+		err := validateAggregationLengthsPerPool(agg.AggregationLength, agg.AggregationLengthV6, pool)
+		if err != nil {
+			return errors.Wrapf(err, "peer %s", peer)
+		}
+	}
+	for _, l := range adv.AdditionalAggregationLengths {
+		if err := validateAggregationLengthsPerPool(l, 128, pool); err != nil {
+			return err
+		}
+	}
+	for _, l := range adv.AdditionalAggregationLengthsV6 {
+		if err := validateAggregationLengthsPerPool(32, l, pool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateAggregationLengthsPerPool(length, lengthV6 int, pool *Pool) error {
 	for addr, cidrs := range pool.cidrsPerAddresses {
 		if len(cidrs) == 0 {
 			continue
 		}
-		maxLength := adv.AggregationLength
+		maxLength := length
 		if cidrs[0].IP.To4() == nil {
-			maxLength = adv.AggregationLengthV6
+			maxLength = lengthV6
 		}
 
 		// in case of range format, we may have a set of cidrs associated to a given address.
@@ -939,12 +2065,158 @@ func validateBGPAdvPerPool(adv *BGPAdvertisement, pool *Pool) error {
 		lowest := lowestMask(cidrs)
 		if maxLength < lowest {
 			return fmt.Errorf("invalid aggregation length %d: prefix %d in "+
-				"this pool is more specific than the aggregation length for addresses %s", adv.AggregationLength, lowest, addr)
+				"this pool is more specific than the aggregation length for addresses %s", maxLength, lowest, addr)
+		}
+	}
+	return nil
+}
+
+// validatePairedAddresses rejects the pools advertised as /31 pairs where
+// both the addresses of a pair could be assigned, as the two services would
+// be announced with the same prefix.
+func validatePairedAddresses(pool *Pool, pools map[string]*Pool) error {
+	var ad *BGPAdvertisement
+	for _, a := range pool.BGPAdvertisements {
+		if a.PointToPointPairs && advAppliesTo(a, ipfamily.IPv4) {
+			ad = a
+			break
+		}
+	}
+	if ad == nil {
+		return nil
+	}
+	evenStride := pool.AllocationStride > 1 && pool.AllocationStride%2 == 0
+	for _, cidr := range pool.CIDR {
+		if cidr.IP.To4() == nil {
+			continue
+		}
+		ones, _ := cidr.Mask.Size()
+		if ones < 32 && !evenStride {
+			return fmt.Errorf("pool %s is advertised as /31 pairs by BGP advertisement %s, but both the addresses of the pairs in %s can be assigned, it needs an even allocationStride", pool.Name, ad.Name, cidr)
+		}
+		// Only the first and the last addresses of the cidr can have their
+		// paired address out of it.
+		first := cidr.IP.Mask(cidr.Mask).To4()
+		last := make(net.IP, net.IPv4len)
+		for i := range first {
+			last[i] = first[i] | ^cidr.Mask[i]
+		}
+		for _, ip := range []net.IP{first, last} {
+			if !pool.allocatable(ip) {
+				continue
+			}
+			paired := make(net.IP, net.IPv4len)
+			copy(paired, ip)
+			paired[3] ^= 1
+			if cidr.Contains(paired) {
+				continue
+			}
+			for _, p := range pools {
+				if p.allocatable(paired) {
+					return fmt.Errorf("pool %s is advertised as /31 pairs by BGP advertisement %s, but the address %s paired with %s can be assigned from pool %s", pool.Name, ad.Name, paired, ip, p.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// allocatable tells if the IP can be assigned from the pool.
+func (p *Pool) allocatable(ip net.IP) bool {
+	for _, cidr := range p.CIDR {
+		if cidr.Contains(ip) {
+			return p.StrideDistance(ip) == 0 && !p.AvoidsIP(ip)
+		}
+	}
+	return false
+}
+
+// validateBGPAdvConflicts rejects the advertisements of a pool that would
+// announce the same prefix from the same node to the same peer with a
+// different LOCAL_PREF, ORIGIN, link bandwidth or color, as only one of them could be honoured.
+// Different LOCAL_PREFs are rejected only when one of the advertisements is
+// scoped to an IP family, the overlapping unscoped ones being accepted as
+// they always were.
+func validateBGPAdvConflicts(pool *Pool) error {
+	for _, family := range []ipfamily.Family{ipfamily.IPv4, ipfamily.IPv6} {
+		for i, a := range pool.BGPAdvertisements {
+			for _, b := range pool.BGPAdvertisements[i+1:] {
+				if !advAppliesTo(a, family) || !advAppliesTo(b, family) {
+					continue
+				}
+				attribute := ""
+				switch {
+				case a.LocalPref != b.LocalPref && (a.IPFamily != "" || b.IPFamily != ""):
+					attribute = "localPref"
+				case originOrDefault(a.Origin) != originOrDefault(b.Origin):
+					attribute = "origin"
+				case a.LinkBandwidthPerEndpoint != b.LinkBandwidthPerEndpoint:
+					attribute = "linkBandwidthPerEndpoint"
+				case a.Color != b.Color:
+					attribute = "color"
+				}
+				if attribute == "" || !lengthsOverlap(advAggregationLengths(a, family), advAggregationLengths(b, family)) {
+					continue
+				}
+				if !nodesOverlap(a.Nodes, b.Nodes) || !peersOverlap(a.Peers, b.Peers) {
+					continue
+				}
+				return fmt.Errorf("bgpadvertisements %s and %s set different %s to the same %s prefixes of pool %s", a.Name, b.Name, attribute, family, pool.Name)
+			}
 		}
 	}
 	return nil
 }
 
+func originOrDefault(origin string) string {
+	if origin == "" {
+		return OriginIGP
+	}
+	return origin
+}
+
+func advAppliesTo(adv *BGPAdvertisement, family ipfamily.Family) bool {
+	return adv.IPFamily == "" || adv.IPFamily == family
+}
+
+// advAggregationLengths returns all the lengths the advertisement announces
+// the prefixes of the family with.
+func advAggregationLengths(adv *BGPAdvertisement, family ipfamily.Family) []int {
+	if family == ipfamily.IPv6 {
+		return append([]int{adv.AggregationLengthV6}, adv.AdditionalAggregationLengthsV6...)
+	}
+	return append([]int{adv.AggregationLength}, adv.AdditionalAggregationLengths...)
+}
+
+func lengthsOverlap(a, b []int) bool {
+	for _, l := range a {
+		for _, m := range b {
+			if l == m {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func nodesOverlap(a, b map[string]bool) bool {
+	for n, ok := range a {
+		if ok && b[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// peersOverlap tells if two lists of peers share a peer, an empty list
+// meaning all the peers.
+func peersOverlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	return sets.New(a...).HasAny(b...)
+}
+
 var invalidCommunityValue = errors.New("invalid community value")
 var invalidCommunityFormat = errors.New("invalid community format")
 
@@ -1059,11 +2331,26 @@ func validateDuplicateBGPAdvertisements(ads []metallbv1beta1.BGPAdvertisement) e
 	return nil
 }
 
+// validateL2VRF checks that the l2 advertisement announces the pool in the
+// same VRF as the other l2 advertisements of the pool, the IPs of a pool
+// belonging to a single L2 domain.
+func validateL2VRF(pool *Pool, name string, adv *L2Advertisement) error {
+	for _, other := range pool.L2Advertisements {
+		if other.VRF != adv.VRF {
+			return fmt.Errorf("l2advertisement %s announces pool %s in vrf %q, while other l2advertisements announce it in vrf %q", name, pool.Name, adv.VRF, other.VRF)
+		}
+	}
+	return nil
+}
+
 func containsAdvertisement(advs []*L2Advertisement, toCheck *L2Advertisement) bool {
 	for _, adv := range advs {
 		if adv.AllInterfaces != toCheck.AllInterfaces {
 			continue
 		}
+		if adv.VRF != toCheck.VRF {
+			continue
+		}
 		if !reflect.DeepEqual(adv.Nodes, toCheck.Nodes) {
 			continue
 		}
@@ -1075,6 +2362,61 @@ func containsAdvertisement(advs []*L2Advertisement, toCheck *L2Advertisement) bo
 	return false
 }
 
+// nodeScores returns the scores of the nodes with a valid score annotation.
+// The score is fed by an external controller and changes often, so invalid
+// values are ignored rather than failing the whole configuration.
+func nodeScores(nodes []corev1.Node) map[string]int {
+	var res map[string]int
+	for _, n := range nodes {
+		value, ok := n.Annotations[NodeScoreAnnotation]
+		if !ok {
+			continue
+		}
+		score, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		if res == nil {
+			res = map[string]int{}
+		}
+		res[n.Name] = score
+	}
+	return res
+}
+
+// nodeL2Weights returns the weights of the nodes with the weight annotation.
+// Unlike the scores, the weights are set statically, so an invalid value is
+// reported as an error.
+func nodeL2Weights(nodes []corev1.Node) (map[string]int, error) {
+	var res map[string]int
+	for _, n := range nodes {
+		value, ok := n.Annotations[NodeL2WeightAnnotation]
+		if !ok {
+			continue
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("invalid %s annotation %q on node %s: must be a positive integer", NodeL2WeightAnnotation, value, n.Name)
+		}
+		if res == nil {
+			res = map[string]int{}
+		}
+		res[n.Name] = weight
+	}
+	return res, nil
+}
+
+func maintenances(crs []metallbv1beta1.MetalLBMaintenance) map[string]string {
+	if len(crs) == 0 {
+		return nil
+	}
+	res := map[string]string{}
+	for _, m := range crs {
+		res[m.Name] = m.Spec.Reason
+	}
+	return res
+}
+
 func selectedNodes(nodes []corev1.Node, selectors []metav1.LabelSelector) (map[string]bool, error) {
 	labelSelectors := []labels.Selector{}
 	for _, selector := range selectors {
@@ -4,12 +4,14 @@ package config
 
 import (
 	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/api/v1beta2"
+	"go.universe.tf/metallb/internal/ipfamily"
 	"go.universe.tf/metallb/internal/pointer"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -416,6 +418,308 @@ func TestParse(t *testing.T) {
 			},
 		},
 
+		{
+			desc: "ip address pool with service ports",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"30.0.0.0/8",
+							},
+							AllocateTo: &v1beta1.ServiceAllocation{
+								ServicePorts: []string{"443", "8000-8080"}},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						CIDR:       []*net.IPNet{ipnet("30.0.0.0/8")},
+						AutoAssign: true,
+						ServiceAllocations: &ServiceAllocation{
+							ServicePorts: []PortRange{{Min: 443, Max: 443}, {Min: 8000, Max: 8080}}},
+					},
+				},
+					ByServicePorts: []string{"pool1"}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+
+		{
+			desc: "ip address pool with zone",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name:   "pool1",
+							Labels: map[string]string{"topology.kubernetes.io/zone": "zone-a"},
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"30.0.0.0/8",
+							},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						CIDR:       []*net.IPNet{ipnet("30.0.0.0/8")},
+						AutoAssign: true,
+						Zone:       "zone-a",
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+
+		{
+			desc: "maintenance",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"30.0.0.0/8",
+							},
+						},
+					},
+				},
+				Maintenances: []v1beta1.MetalLBMaintenance{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "upgrade",
+						},
+						Spec: v1beta1.MetalLBMaintenanceSpec{
+							Reason: "switch upgrade",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						CIDR:       []*net.IPNet{ipnet("30.0.0.0/8")},
+						AutoAssign: true,
+					},
+				}},
+				BFDProfiles:  map[string]*BFDProfile{},
+				Peers:        map[string]*Peer{},
+				Maintenances: map[string]string{"upgrade": "switch upgrade"},
+			},
+		},
+
+		{
+			desc: "ip address pool with allocation stride",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses:        []string{"30.0.0.0/8"},
+							AllocationStride: 2,
+							AllocationOffset: 1,
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:             "pool1",
+						CIDR:             []*net.IPNet{ipnet("30.0.0.0/8")},
+						AutoAssign:       true,
+						AllocationStride: 2,
+						AllocationOffset: 1,
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+
+		{
+			desc: "ip address pool avoiding last octets",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses:       []string{"30.0.0.0/8"},
+							AvoidLastOctets: []int{5, 1, 5},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:            "pool1",
+						CIDR:            []*net.IPNet{ipnet("30.0.0.0/8")},
+						AutoAssign:      true,
+						AvoidLastOctets: []uint8{1, 5},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+
+		{
+			desc: "ip address pool avoiding an invalid last octet",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses:       []string{"30.0.0.0/8"},
+							AvoidLastOctets: []int{256},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "ip address pool with highest allocation mode",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses:      []string{"30.0.0.0/8"},
+							AllocationMode: "highest",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:           "pool1",
+						CIDR:           []*net.IPNet{ipnet("30.0.0.0/8")},
+						AutoAssign:     true,
+						AllocationMode: AllocationHighest,
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+
+		{
+			desc: "ip address pool with invalid allocation mode",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses:      []string{"30.0.0.0/8"},
+							AllocationMode: "random",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "ip address pool with allocation offset not lower than the stride",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses:        []string{"30.0.0.0/8"},
+							AllocationStride: 2,
+							AllocationOffset: 2,
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "ip address pool with allocation offset and no stride",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses:        []string{"30.0.0.0/8"},
+							AllocationStride: 0,
+							AllocationOffset: 1,
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "ip address pool with no address matching the allocation stride",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses:        []string{"30.0.0.1-30.0.0.3"},
+							AllocationStride: 4,
+							AllocationOffset: 0,
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "ip address pool with invalid service ports",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"30.0.0.0/8",
+							},
+							AllocateTo: &v1beta1.ServiceAllocation{
+								ServicePorts: []string{"8080-8000"}},
+						},
+					},
+				},
+			},
+		},
+
 		{
 			desc: "ip address pool with service selection",
 			crs: ClusterResources{
@@ -572,103 +876,152 @@ func TestParse(t *testing.T) {
 		},
 
 		{
-			desc: "invalid peer-address",
+			desc: "peer with import filter",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
 						Spec: v1beta2.BGPPeerSpec{
 							MyASN:   42,
 							ASN:     42,
-							Address: "1.2.3.400",
+							Address: "1.2.3.4",
+							ImportFilter: &v1beta2.ImportFilter{
+								Prefixes:    []string{"10.0.0.0/8 le 24", "192.168.0.0/16 ge 24 le 28", "2001:db8::/32 ge 48"},
+								Communities: []string{"1234:1", "bar"},
+							},
 						},
 					},
 				},
-			},
-		},
-
-		{
-			desc: "invalid my-asn",
-			crs: ClusterResources{
-				Peers: []v1beta2.BGPPeer{
+				Communities: []v1beta1.Community{
 					{
-						Spec: v1beta2.BGPPeerSpec{
-							ASN:     42,
-							Address: "1.2.3.4",
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "community",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "64512:1234",
+								},
+							},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						EBGPMultiHop:  false,
+						ImportFilter: &ImportFilter{
+							Prefixes: []ImportPrefix{
+								{Prefix: ipnet("10.0.0.0/8"), LE: 24},
+								{Prefix: ipnet("192.168.0.0/16"), GE: 24, LE: 28},
+								{Prefix: ipnet("2001:db8::/32"), GE: 48},
+							},
+							Communities: []uint32{1234<<16 + 1, 64512<<16 + 1234},
 						},
 					},
 				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
+
 		{
-			desc: "invalid peer-asn",
+			desc: "peer with add path",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
 						Spec: v1beta2.BGPPeerSpec{
 							MyASN:   42,
+							ASN:     42,
 							Address: "1.2.3.4",
+							AddPath: &v1beta2.AddPath{Send: true, Receive: true},
 						},
 					},
 				},
 			},
-		},
-		{
-			desc: "invalid ebgp-multihop",
-			crs: ClusterResources{
-				Peers: []v1beta2.BGPPeer{
-					{
-						Spec: v1beta2.BGPPeerSpec{
-							MyASN:        42,
-							ASN:          42,
-							Address:      "1.2.3.4",
-							EBGPMultiHop: true,
-						},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						EBGPMultiHop:  false,
+						AddPath:       &AddPath{Send: true, Receive: true},
 					},
 				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
+
 		{
-			desc: "invalid hold time (too short)",
+			desc: "peer with next hop self",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:    42,
-							ASN:      42,
-							Address:  "1.2.3.4",
-							HoldTime: v1.Duration{Duration: time.Second},
+							MyASN:       42,
+							ASN:         42,
+							Address:     "1.2.3.4",
+							NextHopSelf: true,
 						},
 					},
 				},
 			},
-		},
-		{
-			desc: "invalid RouterID",
-			crs: ClusterResources{
-				Peers: []v1beta2.BGPPeer{
-					{
-						Spec: v1beta2.BGPPeerSpec{
-							MyASN:    42,
-							ASN:      42,
-							Address:  "1.2.3.4",
-							RouterID: "oh god how do I BGP",
-						},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						EBGPMultiHop:  false,
+						NextHopSelf:   true,
 					},
 				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
+
 		{
-			desc: "empty node selector (select everything)",
+			desc: "multi hop peer with minimum ttl",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: v1.ObjectMeta{
+						ObjectMeta: metav1.ObjectMeta{
 							Name: "peer1",
 						},
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:   42,
-							ASN:     42,
-							Address: "1.2.3.4",
+							MyASN:        42,
+							ASN:          43,
+							Address:      "1.2.3.4",
+							EBGPMultiHop: true,
+							MinimumTTL:   pointer.Uint32Ptr(253),
 						},
 					},
 				},
@@ -678,473 +1031,2490 @@ func TestParse(t *testing.T) {
 					"peer1": {
 						Name:          "peer1",
 						MyASN:         42,
-						ASN:           42,
+						ASN:           43,
 						Addr:          net.ParseIP("1.2.3.4"),
 						HoldTime:      90 * time.Second,
 						KeepaliveTime: 30 * time.Second,
 						NodeSelectors: []labels.Selector{labels.Everything()},
+						EBGPMultiHop:  true,
+						MinimumTTL:    pointer.Uint32Ptr(253),
 					},
 				},
 				Pools:       &Pools{ByName: map[string]*Pool{}},
 				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
+
 		{
-			desc: "invalid expression node selector (missing key)",
+			desc: "single hop peer with minimum ttl lower than 255",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:   42,
-							ASN:     42,
-							Address: "1.2.3.4",
-							NodeSelectors: []v1.LabelSelector{
-								{
-									MatchLabels: map[string]string{
-										"foo": "bar",
-									},
-									MatchExpressions: []v1.LabelSelectorRequirement{
-										{
-											Operator: "In",
-											Values:   []string{"quux"},
-										},
-									},
-								},
-							},
+							MyASN:      42,
+							ASN:        43,
+							Address:    "1.2.3.4",
+							MinimumTTL: pointer.Uint32Ptr(253),
 						},
 					},
 				},
 			},
 		},
+
 		{
-			desc: "invalid expression node selector (missing operator)",
+			desc: "peer with minimum ttl out of range",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:   42,
-							ASN:     42,
-							Address: "1.2.3.4",
-							NodeSelectors: []v1.LabelSelector{
-								{
-									MatchLabels: map[string]string{
-										"foo": "bar",
-									},
-									MatchExpressions: []v1.LabelSelectorRequirement{
-										{
-											Key:    "bar",
-											Values: []string{"quux"},
-										},
-									},
-								},
-							},
+							MyASN:      42,
+							ASN:        42,
+							Address:    "1.2.3.4",
+							MinimumTTL: pointer.Uint32Ptr(1),
 						},
 					},
 				},
 			},
 		},
+
 		{
-			desc: "invalid expression node selector (invalid operator)",
+			desc: "peer with address families",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:   42,
-							ASN:     42,
-							Address: "1.2.3.4",
-							NodeSelectors: []v1.LabelSelector{
-								{
-									MatchLabels: map[string]string{
-										"foo": "bar",
-									},
-									MatchExpressions: []v1.LabelSelectorRequirement{
-										{
-											Key:      "bar",
-											Operator: "surrounds",
-											Values:   []string{"quux"},
-										},
-									},
-								},
-							},
+							MyASN:           42,
+							ASN:             42,
+							Address:         "1.2.3.4",
+							AddressFamilies: []v1beta2.AddressFamily{"ipv4"},
 						},
 					},
 				},
 			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:            "peer1",
+						MyASN:           42,
+						ASN:             42,
+						Addr:            net.ParseIP("1.2.3.4"),
+						HoldTime:        90 * time.Second,
+						KeepaliveTime:   30 * time.Second,
+						NodeSelectors:   []labels.Selector{labels.Everything()},
+						AddressFamilies: []ipfamily.Family{ipfamily.IPv4},
+					},
+				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
 		},
+
 		{
-			desc: "duplicate values node selector match expression",
+			desc: "peer with no address family",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "peer",
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
 						},
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:        100,
-							ASN:          200,
-							Address:      "2.3.4.5",
-							EBGPMultiHop: false,
-							NodeSelectors: []v1.LabelSelector{
-								{
-									MatchLabels: map[string]string{
-										"foo": "bar",
-									},
-									MatchExpressions: []v1.LabelSelectorRequirement{
-										{
-											Key:      "bar",
-											Operator: metav1.LabelSelectorOpIn,
-											Values:   []string{"quux", "quux"},
-										},
-									},
-								},
-							},
+							MyASN:           42,
+							ASN:             42,
+							Address:         "1.2.3.4",
+							AddressFamilies: []v1beta2.AddressFamily{},
 						},
 					},
 				},
 			},
 		},
+
 		{
-			desc: "duplicate peers",
+			desc: "peer with duplicate address families",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
-						Spec: v1beta2.BGPPeerSpec{
-							MyASN:   42,
-							ASN:     42,
-							Address: "1.2.3.4",
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
 						},
-					},
-					{
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:   42,
-							ASN:     42,
-							Address: "1.2.3.4",
+							MyASN:           42,
+							ASN:             42,
+							Address:         "1.2.3.4",
+							AddressFamilies: []v1beta2.AddressFamily{"ipv6", "ipv6"},
 						},
 					},
 				},
 			},
 		},
+
 		{
-			desc: "no pool name",
-			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
-					{},
-				},
-			},
-		},
-		{
-			desc: "address pool with no address",
+			desc: "peers with source ports",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
-						Spec:       v1beta1.IPAddressPoolSpec{},
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							SrcPortRange: "40000",
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer2",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.5",
+							SrcPortRange: "40001-40010",
+						},
 					},
 				},
 			},
-		},
-		{
-			desc: "address pool with no protocol",
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						SrcPorts:      &PortRange{Min: 40000, Max: 40000},
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+					"peer2": {
+						Name:          "peer2",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.5"),
+						SrcPorts:      &PortRange{Min: 40001, Max: 40010},
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+
+		{
+			desc: "peers with overlapping source ports",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							SrcPortRange: "40000-40005",
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer2",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.5",
+							SrcPortRange: "40005",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "invalid source port range",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							SrcPortRange: "40010-40000",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "peer with tcp keepalive",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							TCPKeepalive: &v1beta2.TCPKeepalive{
+								Idle:     metav1.Duration{Duration: 10 * time.Second},
+								Interval: metav1.Duration{Duration: 2 * time.Second},
+								Count:    3,
+							},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						TCPKeepalive:  &TCPKeepalive{Idle: 10 * time.Second, Interval: 2 * time.Second, Count: 3},
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+
+		{
+			desc: "tcp keepalive with fractional seconds",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							TCPKeepalive: &v1beta2.TCPKeepalive{
+								Idle: metav1.Duration{Duration: 1500 * time.Millisecond},
+							},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "tcp keepalive with too many probes",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							TCPKeepalive: &v1beta2.TCPKeepalive{
+								Count: 200,
+							},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "import filter with no criteria",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							ImportFilter: &v1beta2.ImportFilter{},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "import filter with invalid prefix",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							ImportFilter: &v1beta2.ImportFilter{Prefixes: []string{"10.0.0.0/33"}},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "import filter with host bits set",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							ImportFilter: &v1beta2.ImportFilter{Prefixes: []string{"10.0.0.1/8"}},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "import filter with ge not greater than the length",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							ImportFilter: &v1beta2.ImportFilter{Prefixes: []string{"10.0.0.0/8 ge 8"}},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "import filter with ge greater than le",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							ImportFilter: &v1beta2.ImportFilter{Prefixes: []string{"10.0.0.0/8 ge 24 le 16"}},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "import filter with missing length",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							ImportFilter: &v1beta2.ImportFilter{Prefixes: []string{"10.0.0.0/8 le"}},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "import filter with invalid community",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							ImportFilter: &v1beta2.ImportFilter{Communities: []string{"1234:99999"}},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "node advertisement",
+			crs: ClusterResources{
+				NodeAdvs: []v1beta1.NodeAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "dns"},
+						Spec: v1beta1.NodeAdvertisementSpec{
+							Prefixes:    []string{"10.100.0.53/32", "2001:db8::53/128"},
+							Peers:       []string{"peer1"},
+							Communities: []string{"1234:1"},
+							LocalPref:   100,
+							NodeSelectors: []metav1.LabelSelector{
+								{MatchLabels: map[string]string{"dns": "true"}},
+							},
+							HealthCheck: &v1beta1.NodeHealthCheck{
+								Port:     53,
+								Timeout:  &metav1.Duration{Duration: 2 * time.Second},
+								Interval: &metav1.Duration{Duration: 10 * time.Second},
+							},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "web"},
+						Spec: v1beta1.NodeAdvertisementSpec{
+							Prefixes: []string{"10.100.0.80/32"},
+							HealthCheck: &v1beta1.NodeHealthCheck{
+								Address:          "10.100.0.80",
+								Port:             8080,
+								Path:             "/healthz",
+								FailureThreshold: 3,
+							},
+						},
+					},
+				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: metav1.ObjectMeta{Name: "first", Labels: map[string]string{"dns": "true"}}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "second"}},
+				},
+			},
+			want: &Config{
+				Peers:       map[string]*Peer{},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+				NodeAdvertisements: map[string]*NodeAdvertisement{
+					"dns": {
+						Name:        "dns",
+						Prefixes:    []*net.IPNet{ipnet("10.100.0.53/32"), ipnet("2001:db8::53/128")},
+						LocalPref:   100,
+						Communities: map[uint32]bool{1234<<16 + 1: true},
+						Nodes:       map[string]bool{"first": true},
+						Peers:       []string{"peer1"},
+						HealthCheck: &NodeHealthCheck{
+							Address:          "127.0.0.1:53",
+							Interval:         10 * time.Second,
+							Timeout:          2 * time.Second,
+							FailureThreshold: 1,
+						},
+					},
+					"web": {
+						Name:        "web",
+						Prefixes:    []*net.IPNet{ipnet("10.100.0.80/32")},
+						Communities: map[uint32]bool{},
+						Nodes:       map[string]bool{"first": true, "second": true},
+						HealthCheck: &NodeHealthCheck{
+							Address:          "10.100.0.80:8080",
+							Path:             "/healthz",
+							Interval:         5 * time.Second,
+							Timeout:          time.Second,
+							FailureThreshold: 3,
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "node advertisement without prefixes",
+			crs: ClusterResources{
+				NodeAdvs: []v1beta1.NodeAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "dns"},
+						Spec: v1beta1.NodeAdvertisementSpec{
+							Peers: []string{"peer1"},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "node advertisement with invalid prefix",
+			crs: ClusterResources{
+				NodeAdvs: []v1beta1.NodeAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "dns"},
+						Spec: v1beta1.NodeAdvertisementSpec{
+							Prefixes: []string{"10.100.0.53"},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "node advertisement with host bits set",
+			crs: ClusterResources{
+				NodeAdvs: []v1beta1.NodeAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "dns"},
+						Spec: v1beta1.NodeAdvertisementSpec{
+							Prefixes: []string{"10.100.0.53/24"},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "node advertisement health check without port",
+			crs: ClusterResources{
+				NodeAdvs: []v1beta1.NodeAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "dns"},
+						Spec: v1beta1.NodeAdvertisementSpec{
+							Prefixes:    []string{"10.100.0.53/32"},
+							HealthCheck: &v1beta1.NodeHealthCheck{},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "node advertisement health check with timeout greater than interval",
+			crs: ClusterResources{
+				NodeAdvs: []v1beta1.NodeAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "dns"},
+						Spec: v1beta1.NodeAdvertisementSpec{
+							Prefixes: []string{"10.100.0.53/32"},
+							HealthCheck: &v1beta1.NodeHealthCheck{
+								Port:    53,
+								Timeout: &metav1.Duration{Duration: 10 * time.Second},
+							},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "node advertisement overlapping with a pool",
+			crs: ClusterResources{
+				NodeAdvs: []v1beta1.NodeAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "dns"},
+						Spec: v1beta1.NodeAdvertisementSpec{
+							Prefixes: []string{"10.20.0.53/32"},
+						},
+					},
+				},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"10.20.0.0/16"},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "invalid peer-address",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.400",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "invalid my-asn",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							ASN:     42,
+							Address: "1.2.3.4",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "invalid peer-asn",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							Address: "1.2.3.4",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "invalid ebgp-multihop",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							EBGPMultiHop: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "invalid hold time (too short)",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:    42,
+							ASN:      42,
+							Address:  "1.2.3.4",
+							HoldTime: v1.Duration{Duration: time.Second},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "invalid RouterID",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:    42,
+							ASN:      42,
+							Address:  "1.2.3.4",
+							RouterID: "oh god how do I BGP",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "empty node selector (select everything)",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+		{
+			desc: "invalid expression node selector (missing key)",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							NodeSelectors: []v1.LabelSelector{
+								{
+									MatchLabels: map[string]string{
+										"foo": "bar",
+									},
+									MatchExpressions: []v1.LabelSelectorRequirement{
+										{
+											Operator: "In",
+											Values:   []string{"quux"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "invalid expression node selector (missing operator)",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							NodeSelectors: []v1.LabelSelector{
+								{
+									MatchLabels: map[string]string{
+										"foo": "bar",
+									},
+									MatchExpressions: []v1.LabelSelectorRequirement{
+										{
+											Key:    "bar",
+											Values: []string{"quux"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "invalid expression node selector (invalid operator)",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							NodeSelectors: []v1.LabelSelector{
+								{
+									MatchLabels: map[string]string{
+										"foo": "bar",
+									},
+									MatchExpressions: []v1.LabelSelectorRequirement{
+										{
+											Key:      "bar",
+											Operator: "surrounds",
+											Values:   []string{"quux"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate values node selector match expression",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "peer",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        100,
+							ASN:          200,
+							Address:      "2.3.4.5",
+							EBGPMultiHop: false,
+							NodeSelectors: []v1.LabelSelector{
+								{
+									MatchLabels: map[string]string{
+										"foo": "bar",
+									},
+									MatchExpressions: []v1.LabelSelectorRequirement{
+										{
+											Key:      "bar",
+											Operator: metav1.LabelSelectorOpIn,
+											Values:   []string{"quux", "quux"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate peers",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+						},
+					},
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "no pool name",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{},
+				},
+			},
+		},
+		{
+			desc: "address pool with no address",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec:       v1beta1.IPAddressPoolSpec{},
+					},
+				},
+			},
+		},
+		{
+			desc: "address pool with no protocol",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+					},
+				},
+			},
+		},
+		{
+			desc: "invalid pool CIDR",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"100.200.300.400/24",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "invalid pool CIDR prefix length",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/33",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "invalid pool CIDR, first address of the range is after the second",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.10-1.2.3.1",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "simple advertisement",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "adv3",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv3",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "advertisement with default BGP settings",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "adv3",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv3",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "bad aggregation length (too long)",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.10-1.2.3.1",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: pointer.Int32Ptr(34),
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad aggregation length (incompatible with CIDR)",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.30.40/24",
+								"1.2.3.0/28",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: pointer.Int32Ptr(26),
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "aggregation length by range",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"3.3.3.2-3.3.3.254",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: pointer.Int32Ptr(26),
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR: []*net.IPNet{
+							ipnet("3.3.3.2/31"),
+							ipnet("3.3.3.4/30"),
+							ipnet("3.3.3.8/29"),
+							ipnet("3.3.3.16/28"),
+							ipnet("3.3.3.32/27"),
+							ipnet("3.3.3.64/26"),
+							ipnet("3.3.3.128/26"),
+							ipnet("3.3.3.192/27"),
+							ipnet("3.3.3.224/28"),
+							ipnet("3.3.3.240/29"),
+							ipnet("3.3.3.248/30"),
+							ipnet("3.3.3.252/31"),
+							ipnet("3.3.3.254/32"),
+						},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								AggregationLength:   26,
+								AggregationLengthV6: 128,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "aggregation length by range, too wide",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"3.3.3.2-3.3.3.254",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: pointer.Int32Ptr(24),
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate ip address pools - in L2 adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				L2Advs: []v1beta1.L2Advertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.L2AdvertisementSpec{
+							IPAddressPools: []string{testPoolName, testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate ip address pools - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName, testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate peers - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName},
+							Peers:          []string{"peer1", "peer1"},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (wrong format) - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities:    []string{"1234"},
+							IPAddressPools: []string{testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (asn part doesn't fit) - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities:    []string{"99999999:1"},
+							IPAddressPools: []string{testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (community# part doesn't fit) - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities:    []string{"1:99999999"},
+							IPAddressPools: []string{testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community ref (unknown ref) - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities:    []string{"community"},
+							IPAddressPools: []string{testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate community literal - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities:    []string{"1234:5678", "1234:5678"},
+							IPAddressPools: []string{testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "evpn with invalid vni",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName},
+							EVPN:           &v1beta1.EVPNAdvertisement{VNI: 16777216},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "evpn with invalid route distinguisher",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName},
+							EVPN:           &v1beta1.EVPNAdvertisement{VNI: 100, RouteDistinguisher: "1.2.3.4"},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "evpn with route target assigned number too big",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName},
+							EVPN:           &v1beta1.EVPNAdvertisement{VNI: 100, RouteTarget: "4200000000:70000"},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "evpn with ipv6 route target",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName},
+							EVPN:           &v1beta1.EVPNAdvertisement{VNI: 100, RouteTarget: "2001:db8::1:100"},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bgp advertisement with invalid ip family",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName},
+							IPFamily:       "dual",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bgp advertisements with conflicting localpref for the same family",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24", "2001:db8::/64"},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 100,
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv2"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 200,
+							IPFamily:  "ipv6",
+						},
+					},
+				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
+			},
+		},
+		{
+			desc: "bgp advertisement with invalid origin",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName},
+							Origin:         "unknown",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bgp advertisement with too high link bandwidth",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools:           []string{testPoolName},
+							LinkBandwidthPerEndpoint: 30000,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bgp advertisement with invalid topology key",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName},
+							TopologyKey:    "not a label",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bgp advertisement with invalid service label community",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName},
+							ServiceLabelCommunities: &v1beta1.ServiceLabelCommunities{
+								Label:       "team",
+								Communities: map[string]string{"blue": "99999999:1"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bgp advertisements with conflicting origin",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Origin: "egp",
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv2"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities: []string{"1234:5678"},
+						},
+					},
+				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
+			},
+		},
+		{
+			desc: "bgp advertisements with conflicting link bandwidth",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LinkBandwidthPerEndpoint: 100,
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv2"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities: []string{"1234:5678"},
+						},
+					},
+				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
+			},
+		},
+		{
+			desc: "bgp advertisements with conflicting color",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Color: 100,
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv2"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Color: 200,
+						},
+					},
+				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (wrong format) - in the community CR",
+			crs: ClusterResources{
+				Communities: []v1beta1.Community{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "community",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "1234",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (asn part doesn't fit) - in the community CR",
+			crs: ClusterResources{
+				Communities: []v1beta1.Community{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "community",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "99999999:1",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (community# part doesn't fit) - in the community CR",
+			crs: ClusterResources{
+				Communities: []v1beta1.Community{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "community",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "1:99999999",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate communities definition (in 2 different crs)",
+			crs: ClusterResources{
+				Communities: []v1beta1.Community{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "community1",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "1234:5678",
+								},
+							},
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "community2",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "1234:5678",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate communities definition (in the same cr)",
+			crs: ClusterResources{
+				Communities: []v1beta1.Community{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "community",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "1234:5678",
+								},
+								{
+									Name:  "bar",
+									Value: "1234:5678",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate pool definition",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec:       v1beta1.IPAddressPoolSpec{},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool2"},
+						Spec:       v1beta1.IPAddressPoolSpec{},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec:       v1beta1.IPAddressPoolSpec{},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate CIDRs",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								" 10.0.0.0/8",
+							},
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool2"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								" 10.0.0.0/8",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "overlapping CIDRs",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								" 10.0.0.0/8",
+							},
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool2"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.0.0.0/16",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "Session with default BFD Profile",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:      42,
+							ASN:        42,
+							Address:    "1.2.3.4",
+							BFDProfile: "default",
+						},
+					},
+				},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				BFDProfiles: []v1beta1.BFDProfile{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "default",
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "adv3",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						BFDProfile:    "default",
+					},
+				},
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv3",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{
+					"default": {
+						Name: "default",
+					},
+				},
+			},
+		},
+		{
+			desc: "BGP advertisement with evpn",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "adv1",
+						},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							EVPN: &v1beta1.EVPNAdvertisement{
+								VNI:                100,
+								RouteDistinguisher: "10.0.0.1:100",
+								RouteTarget:        "4200000000:100",
+							},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{},
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv1",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{},
+								EVPN: &EVPN{
+									VNI:                100,
+									RouteDistinguisher: "10.0.0.1:100",
+									RouteTarget:        "4200000000:100",
+								},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+		{
+			desc: "BGP advertisements scoped to the ip families",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24", "2001:db8::/64"},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 100,
+							IPFamily:  "ipv4",
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv2"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 200,
+							IPFamily:  "ipv6",
+						},
 					},
 				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{},
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24"), ipnet("2001:db8::/64")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv1",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								LocalPref:           100,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{"first": true},
+								IPFamily:            ipfamily.IPv4,
+							},
+							{
+								Name:                "adv2",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								LocalPref:           200,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{"first": true},
+								IPFamily:            ipfamily.IPv6,
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
 		{
-			desc: "invalid pool CIDR",
+			desc: "overlapping BGP advertisements with different localpref not scoped to an ip family",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"100.200.300.400/24",
-							},
+							Addresses: []string{"1.2.3.0/24"},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 100,
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv2"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 200,
 						},
 					},
 				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{},
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv1",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								LocalPref:           100,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{"first": true},
+							},
+							{
+								Name:                "adv2",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								LocalPref:           200,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{"first": true},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
 		{
-			desc: "invalid pool CIDR prefix length",
+			desc: "BGP advertisement with origin",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.0/33",
+							Addresses: []string{"1.2.3.0/24"},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Origin: "incomplete",
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv2"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Origin:      "incomplete",
+							Communities: []string{"1234:5678"},
+						},
+					},
+				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{},
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv1",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{"first": true},
+								Origin:              OriginIncomplete,
+							},
+							{
+								Name:                "adv2",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								Communities:         map[uint32]bool{0x04D2162E: true},
+								Nodes:               map[string]bool{"first": true},
+								Origin:              OriginIncomplete,
 							},
 						},
 					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+		{
+			desc: "BGP advertisement with link bandwidth",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24"},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LinkBandwidthPerEndpoint: 100,
+						},
+					},
+				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
 				},
 			},
+			want: &Config{
+				Peers: map[string]*Peer{},
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                     "adv1",
+								AggregationLength:        32,
+								AggregationLengthV6:      128,
+								LinkBandwidthPerEndpoint: 100,
+								Communities:              map[uint32]bool{},
+								Nodes:                    map[string]bool{"first": true},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
 		},
 		{
-			desc: "invalid pool CIDR, first address of the range is after the second",
+			desc: "BGP advertisement with topology key",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.10-1.2.3.1",
+							Addresses: []string{"1.2.3.0/24"},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: pointer.Int32Ptr(26),
+							TopologyKey:       "topology.kubernetes.io/rack",
+							NodeSelectors: []v1.LabelSelector{
+								{
+									MatchLabels: map[string]string{"bgp": "true"},
+								},
 							},
 						},
 					},
 				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first", Labels: map[string]string{"bgp": "true", "topology.kubernetes.io/rack": "r1"}}},
+					{ObjectMeta: v1.ObjectMeta{Name: "second", Labels: map[string]string{"bgp": "true", "topology.kubernetes.io/rack": "r2"}}},
+					{ObjectMeta: v1.ObjectMeta{Name: "third", Labels: map[string]string{"bgp": "true"}}},
+					{ObjectMeta: v1.ObjectMeta{Name: "fourth", Labels: map[string]string{"topology.kubernetes.io/rack": "r1"}}},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{},
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv1",
+								AggregationLength:   26,
+								AggregationLengthV6: 128,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{"first": true, "second": true, "third": true},
+								TopologyKey:         "topology.kubernetes.io/rack",
+								NodeGroups:          map[string]string{"first": "r1", "second": "r2"},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
 		{
-			desc: "simple advertisement",
+			desc: "BGP advertisement with service label communities",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.0/24",
+							Addresses: []string{"1.2.3.0/24"},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							ServiceLabelCommunities: &v1beta1.ServiceLabelCommunities{
+								Label: "example.com/team",
+								Communities: map[string]string{
+									"blue": "1234:1",
+									"red":  "team-red",
+								},
+							},
+						},
+					},
+				},
+				Communities: []v1beta1.Community{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "community"},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "team-red",
+									Value: "1234:2",
+								},
+							},
+						},
+					},
+				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{},
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                    "adv1",
+								AggregationLength:       32,
+								AggregationLengthV6:     128,
+								Communities:             map[uint32]bool{},
+								Nodes:                   map[string]bool{"first": true},
+								ServiceLabel:            "example.com/team",
+								ServiceLabelCommunities: map[string]uint32{"blue": 0x04D20001, "red": 0x04D20002},
 							},
 						},
 					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+		{
+			desc: "BGP advertisement with per peer aggregation lengths",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24", "2001:db8::/64"},
+						},
+					},
 				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "adv3",
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							PeerAggregations: []v1beta1.PeerAggregation{
+								{
+									Peer:              "transit",
+									AggregationLength: pointer.Int32Ptr(24),
+								},
+								{
+									Peer:                "reflector",
+									AggregationLengthV6: pointer.Int32Ptr(64),
+								},
+							},
 						},
 					},
 				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
 			},
 			want: &Config{
+				Peers: map[string]*Peer{},
 				Pools: &Pools{ByName: map[string]*Pool{
 					"pool1": {
 						Name:       "pool1",
 						AutoAssign: true,
-						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24"), ipnet("2001:db8::/64")},
 						BGPAdvertisements: []*BGPAdvertisement{
 							{
-								Name:                "adv3",
+								Name:                "adv1",
 								AggregationLength:   32,
 								AggregationLengthV6: 128,
 								Communities:         map[uint32]bool{},
-								Nodes:               map[string]bool{},
+								Nodes:               map[string]bool{"first": true},
+								PeerAggregations: map[string]*PeerAggregation{
+									"transit": {
+										AggregationLength:   24,
+										AggregationLengthV6: 128,
+									},
+									"reflector": {
+										AggregationLength:   32,
+										AggregationLengthV6: 64,
+									},
+								},
 							},
 						},
 					},
 				}},
 				BFDProfiles: map[string]*BFDProfile{},
-				Peers:       map[string]*Peer{},
 			},
 		},
 		{
-			desc: "advertisement with default BGP settings",
+			desc: "BGP advertisement with additional aggregation lengths",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.0/24",
-							},
+							Addresses: []string{"1.2.3.0/24", "2001:db8::/64"},
 						},
 					},
 				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "adv3",
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AdditionalAggregationLengths:   []int32{24, 28},
+							AdditionalAggregationLengthsV6: []int32{64},
 						},
 					},
 				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
 			},
 			want: &Config{
+				Peers: map[string]*Peer{},
 				Pools: &Pools{ByName: map[string]*Pool{
 					"pool1": {
 						Name:       "pool1",
 						AutoAssign: true,
-						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24"), ipnet("2001:db8::/64")},
 						BGPAdvertisements: []*BGPAdvertisement{
 							{
-								Name:                "adv3",
-								AggregationLength:   32,
-								AggregationLengthV6: 128,
-								Communities:         map[uint32]bool{},
-								Nodes:               map[string]bool{},
+								Name:                           "adv1",
+								AggregationLength:              32,
+								AggregationLengthV6:            128,
+								AdditionalAggregationLengths:   []int{24, 28},
+								AdditionalAggregationLengthsV6: []int{64},
+								Communities:                    map[uint32]bool{},
+								Nodes:                          map[string]bool{"first": true},
 							},
 						},
 					},
 				}},
 				BFDProfiles: map[string]*BFDProfile{},
-				Peers:       map[string]*Peer{},
 			},
 		},
 		{
-			desc: "bad aggregation length (too long)",
+			desc: "per peer aggregation length incompatible with the pool",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.10-1.2.3.1",
-							},
+							Addresses: []string{"1.2.3.0/24"},
 						},
 					},
 				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							AggregationLength: pointer.Int32Ptr(34),
+							PeerAggregations: []v1beta1.PeerAggregation{
+								{
+									Peer:              "transit",
+									AggregationLength: pointer.Int32Ptr(16),
+								},
+							},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "bad aggregation length (incompatible with CIDR)",
+			desc: "per peer aggregation for a peer not in the advertisement",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"10.20.30.40/24",
-								"1.2.3.0/28",
-							},
+							Addresses: []string{"1.2.3.0/24"},
 						},
 					},
 				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							AggregationLength: pointer.Int32Ptr(26),
+							Peers: []string{"reflector"},
+							PeerAggregations: []v1beta1.PeerAggregation{
+								{
+									Peer:              "transit",
+									AggregationLength: pointer.Int32Ptr(24),
+								},
+							},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "aggregation length by range",
+			desc: "duplicate per peer aggregation",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"3.3.3.2-3.3.3.254",
-							},
+							Addresses: []string{"1.2.3.0/24"},
 						},
 					},
 				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							AggregationLength: pointer.Int32Ptr(26),
-						},
-					},
-				},
-			},
-			want: &Config{
-				Pools: &Pools{ByName: map[string]*Pool{
-					"pool1": {
-						Name:       "pool1",
-						AutoAssign: true,
-						CIDR: []*net.IPNet{
-							ipnet("3.3.3.2/31"),
-							ipnet("3.3.3.4/30"),
-							ipnet("3.3.3.8/29"),
-							ipnet("3.3.3.16/28"),
-							ipnet("3.3.3.32/27"),
-							ipnet("3.3.3.64/26"),
-							ipnet("3.3.3.128/26"),
-							ipnet("3.3.3.192/27"),
-							ipnet("3.3.3.224/28"),
-							ipnet("3.3.3.240/29"),
-							ipnet("3.3.3.248/30"),
-							ipnet("3.3.3.252/31"),
-							ipnet("3.3.3.254/32"),
-						},
-						BGPAdvertisements: []*BGPAdvertisement{
-							{
-								AggregationLength:   26,
-								AggregationLengthV6: 128,
-								Communities:         map[uint32]bool{},
-								Nodes:               map[string]bool{},
+							PeerAggregations: []v1beta1.PeerAggregation{
+								{
+									Peer:              "transit",
+									AggregationLength: pointer.Int32Ptr(24),
+								},
+								{
+									Peer:              "transit",
+									AggregationLength: pointer.Int32Ptr(28),
+								},
 							},
 						},
 					},
-				}},
-				BFDProfiles: map[string]*BFDProfile{},
-				Peers:       map[string]*Peer{},
+				},
 			},
 		},
 		{
-			desc: "aggregation length by range, too wide",
+			desc: "BGP advertisement with per peer communities",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"3.3.3.2-3.3.3.254",
-							},
+							Addresses: []string{"1.2.3.0/24"},
+						},
+					},
+				},
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "transit"},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     142,
+							Address: "1.2.3.4",
 						},
 					},
 				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							AggregationLength: pointer.Int32Ptr(24),
+							Communities: []string{"1234:1"},
+							PeerCommunities: []v1beta1.PeerCommunities{
+								{
+									Peer:        "transit",
+									Communities: []string{"1234:2", "bar"},
+								},
+							},
 						},
 					},
 				},
-			},
-		},
-		{
-			desc: "duplicate ip address pools - in L2 adv",
-			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
-				L2Advs: []v1beta1.L2Advertisement{
+				Communities: []v1beta1.Community{
 					{
-						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
-						Spec: v1beta1.L2AdvertisementSpec{
-							IPAddressPools: []string{testPoolName, testPoolName},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "64512:1",
+								},
+							},
 						},
 					},
 				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
 			},
-		},
-		{
-			desc: "duplicate ip address pools - in BGP adv",
-			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
-					{
-						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
-						Spec: v1beta1.BGPAdvertisementSpec{
-							IPAddressPools: []string{testPoolName, testPoolName},
-						},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"transit": {
+						Name:          "transit",
+						MyASN:         42,
+						ASN:           142,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
 					},
 				},
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv1",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								Communities: map[uint32]bool{
+									0x04D20001: true,
+								},
+								Nodes: map[string]bool{"first": true},
+								PeerCommunities: map[string]map[uint32]bool{
+									"transit": {
+										0x04D20002: true,
+										0xFC000001: true,
+									},
+								},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
 		{
-			desc: "duplicate peers - in BGP adv",
+			desc: "evpn advertisement selecting a peer in the default vrf",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{testPool},
 				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "peer1",
-						},
+						ObjectMeta: v1.ObjectMeta{Name: "red"},
 						Spec: v1beta2.BGPPeerSpec{
 							MyASN:   42,
-							ASN:     42,
+							ASN:     142,
 							Address: "1.2.3.4",
+							VRFName: "red",
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "default"},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     143,
+							Address: "1.2.3.5",
 						},
 					},
 				},
@@ -1153,100 +3523,108 @@ func TestParse(t *testing.T) {
 						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
 						Spec: v1beta1.BGPAdvertisementSpec{
 							IPAddressPools: []string{testPoolName},
-							Peers:          []string{"peer1", "peer1"},
+							EVPN:           &v1beta1.EVPNAdvertisement{VNI: 100},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "bad community literal (wrong format) - in BGP adv",
+			desc: "per peer communities for a non existing peer",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
-						Spec: v1beta1.BGPAdvertisementSpec{
-							Communities:    []string{"1234"},
-							IPAddressPools: []string{testPoolName},
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24"},
 						},
 					},
 				},
-			},
-		},
-		{
-			desc: "bad community literal (asn part doesn't fit) - in BGP adv",
-			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							Communities:    []string{"99999999:1"},
-							IPAddressPools: []string{testPoolName},
+							PeerCommunities: []v1beta1.PeerCommunities{
+								{
+									Peer:        "transit",
+									Communities: []string{"1234:2"},
+								},
+							},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "bad community literal (community# part doesn't fit) - in BGP adv",
+			desc: "per peer communities for a peer not in the advertisement",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
-						Spec: v1beta1.BGPAdvertisementSpec{
-							Communities:    []string{"1:99999999"},
-							IPAddressPools: []string{testPoolName},
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24"},
 						},
 					},
 				},
-			},
-		},
-		{
-			desc: "bad community ref (unknown ref) - in BGP adv",
-			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
-						Spec: v1beta1.BGPAdvertisementSpec{
-							Communities:    []string{"community"},
-							IPAddressPools: []string{testPoolName},
+						ObjectMeta: metav1.ObjectMeta{Name: "transit"},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     142,
+							Address: "1.2.3.4",
 						},
 					},
 				},
-			},
-		},
-		{
-			desc: "duplicate community literal - in BGP adv",
-			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: v1.ObjectMeta{Name: testAdvName},
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							Communities:    []string{"1234:5678", "1234:5678"},
-							IPAddressPools: []string{testPoolName},
+							Peers: []string{"reflector"},
+							PeerCommunities: []v1beta1.PeerCommunities{
+								{
+									Peer:        "transit",
+									Communities: []string{"1234:2"},
+								},
+							},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "bad community literal (wrong format) - in the community CR",
+			desc: "duplicate per peer communities",
 			crs: ClusterResources{
-				Communities: []v1beta1.Community{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "community",
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24"},
 						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
+					},
+				},
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "transit"},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     142,
+							Address: "1.2.3.4",
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							PeerCommunities: []v1beta1.PeerCommunities{
 								{
-									Name:  "bar",
-									Value: "1234",
+									Peer:        "transit",
+									Communities: []string{"1234:2"},
+								},
+								{
+									Peer:        "transit",
+									Communities: []string{"1234:3"},
 								},
 							},
 						},
@@ -1255,18 +3633,34 @@ func TestParse(t *testing.T) {
 			},
 		},
 		{
-			desc: "bad community literal (asn part doesn't fit) - in the community CR",
+			desc: "invalid per peer community",
 			crs: ClusterResources{
-				Communities: []v1beta1.Community{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "community",
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24"},
 						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
+					},
+				},
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "transit"},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     142,
+							Address: "1.2.3.4",
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							PeerCommunities: []v1beta1.PeerCommunities{
 								{
-									Name:  "bar",
-									Value: "99999999:1",
+									Peer:        "transit",
+									Communities: []string{"1234"},
 								},
 							},
 						},
@@ -1275,228 +3669,273 @@ func TestParse(t *testing.T) {
 			},
 		},
 		{
-			desc: "bad community literal (community# part doesn't fit) - in the community CR",
+			desc: "additional aggregation length same as the aggregation length",
 			crs: ClusterResources{
-				Communities: []v1beta1.Community{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "community",
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24"},
 						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
-								{
-									Name:  "bar",
-									Value: "1:99999999",
-								},
-							},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength:            pointer.Int32Ptr(24),
+							AdditionalAggregationLengths: []int32{24},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "duplicate communities definition (in 2 different crs)",
+			desc: "duplicate additional aggregation length",
 			crs: ClusterResources{
-				Communities: []v1beta1.Community{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "community1",
-						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
-								{
-									Name:  "bar",
-									Value: "1234:5678",
-								},
-							},
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"2001:db8::/64"},
 						},
 					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "community2",
-						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
-								{
-									Name:  "bar",
-									Value: "1234:5678",
-								},
-							},
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AdditionalAggregationLengthsV6: []int32{64, 64},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "duplicate communities definition (in the same cr)",
+			desc: "additional aggregation length incompatible with the pool",
 			crs: ClusterResources{
-				Communities: []v1beta1.Community{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "community",
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24"},
 						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
-								{
-									Name:  "bar",
-									Value: "1234:5678",
-								},
-								{
-									Name:  "bar",
-									Value: "1234:5678",
-								},
-							},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AdditionalAggregationLengths: []int32{16},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "duplicate pool definition",
+			desc: "invalid additional aggregation length",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
-						Spec:       v1beta1.IPAddressPoolSpec{},
-					},
-					{
-						ObjectMeta: v1.ObjectMeta{Name: "pool2"},
-						Spec:       v1beta1.IPAddressPoolSpec{},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24"},
+						},
 					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
-						Spec:       v1beta1.IPAddressPoolSpec{},
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AdditionalAggregationLengths: []int32{33},
+						},
 					},
 				},
 			},
 		},
 		{
-			desc: "duplicate CIDRs",
+			desc: "summary only without aggregation",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								" 10.0.0.0/8",
-							},
+							Addresses: []string{"1.2.3.0/24"},
 						},
 					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: v1.ObjectMeta{Name: "pool2"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								" 10.0.0.0/8",
-							},
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: pointer.Int32Ptr(32),
+							SummaryOnly:       true,
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "overlapping CIDRs",
+			desc: "BGP advertisement with point to point pairs",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								" 10.0.0.0/8",
-							},
+							Addresses:        []string{"1.2.3.0/24"},
+							AllocationStride: 2,
+							AllocationOffset: 1,
 						},
 					},
 					{
 						ObjectMeta: v1.ObjectMeta{Name: "pool2"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"10.0.0.0/16",
-							},
+							Addresses: []string{"1.2.4.1/32"},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							PointToPointPairs: true,
 						},
 					},
 				},
+				Nodes: []corev1.Node{
+					{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{},
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:             "pool1",
+						AutoAssign:       true,
+						CIDR:             []*net.IPNet{ipnet("1.2.3.0/24")},
+						AllocationStride: 2,
+						AllocationOffset: 1,
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv1",
+								AggregationLength:   31,
+								AggregationLengthV6: 128,
+								PointToPointPairs:   true,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{"first": true},
+							},
+						},
+					},
+					"pool2": {
+						Name:       "pool2",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.4.1/32")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv1",
+								AggregationLength:   31,
+								AggregationLengthV6: 128,
+								PointToPointPairs:   true,
+								Communities:         map[uint32]bool{},
+								Nodes:               map[string]bool{"first": true},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
 		{
-			desc: "Session with default BFD Profile",
+			desc: "point to point pairs with both the addresses of the pairs assignable",
 			crs: ClusterResources{
-				Peers: []v1beta2.BGPPeer{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "peer1",
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/24"},
 						},
-						Spec: v1beta2.BGPPeerSpec{
-							MyASN:      42,
-							ASN:        42,
-							Address:    "1.2.3.4",
-							BFDProfile: "default",
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							PointToPointPairs: true,
 						},
 					},
 				},
+			},
+		},
+		{
+			desc: "point to point pairs with the paired address assignable from another pool",
+			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "pool1",
-						},
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.0/24",
-							},
+							Addresses: []string{"1.2.3.1/32"},
 						},
 					},
-				},
-				BFDProfiles: []v1beta1.BFDProfile{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "default",
+						ObjectMeta: v1.ObjectMeta{Name: "pool2"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"1.2.3.0/32"},
 						},
 					},
 				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: v1.ObjectMeta{
-							Name: "adv3",
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools:    []string{"pool1"},
+							PointToPointPairs: true,
 						},
 					},
 				},
 			},
-			want: &Config{
-				Peers: map[string]*Peer{
-					"peer1": {
-						Name:          "peer1",
-						MyASN:         42,
-						ASN:           42,
-						Addr:          net.ParseIP("1.2.3.4"),
-						HoldTime:      90 * time.Second,
-						KeepaliveTime: 30 * time.Second,
-						NodeSelectors: []labels.Selector{labels.Everything()},
-						BFDProfile:    "default",
+		},
+		{
+			desc: "point to point pairs with an aggregation length",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses:        []string{"1.2.3.0/24"},
+							AllocationStride: 2,
+						},
 					},
 				},
-				Pools: &Pools{ByName: map[string]*Pool{
-					"pool1": {
-						Name:       "pool1",
-						AutoAssign: true,
-						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
-						BGPAdvertisements: []*BGPAdvertisement{
-							{
-								Name:                "adv3",
-								AggregationLength:   32,
-								AggregationLengthV6: 128,
-								Communities:         map[uint32]bool{},
-								Nodes:               map[string]bool{},
-							},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: pointer.Int32Ptr(24),
+							PointToPointPairs: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "BGP Peer with both password and secret ref set",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:    42,
+							ASN:      42,
+							Address:  "1.2.3.4",
+							Password: "nopass",
+							PasswordSecret: corev1.SecretReference{Name: "nosecret",
+								Namespace: "metallb-system"},
 						},
 					},
-				}},
-				BFDProfiles: map[string]*BFDProfile{
-					"default": {
-						Name: "default",
-					},
 				},
 			},
 		},
 		{
-			desc: "BGP Peer with both password and secret ref set",
+			desc: "BGP Peer with a too long password",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
@@ -1504,9 +3943,7 @@ func TestParse(t *testing.T) {
 							MyASN:    42,
 							ASN:      42,
 							Address:  "1.2.3.4",
-							Password: "nopass",
-							PasswordSecret: corev1.SecretReference{Name: "nosecret",
-								Namespace: "metallb-system"},
+							Password: strings.Repeat("a", 81),
 						},
 					},
 				},
@@ -2758,6 +5195,309 @@ func TestParse(t *testing.T) {
 				Peers:       map[string]*Peer{},
 			},
 		},
+		{
+			desc: "l2 advertisement with node preferences",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.0.0/16",
+							},
+						},
+					},
+				},
+				L2Advs: []v1beta1.L2Advertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "l2adv1",
+						},
+						Spec: v1beta1.L2AdvertisementSpec{
+							NodePreferences: []v1beta1.NodePreference{
+								{
+									Weight: 10,
+									Preference: metav1.LabelSelector{
+										MatchLabels: map[string]string{
+											"spare": "true",
+										},
+									},
+								},
+								{
+									Weight: 5,
+									Preference: metav1.LabelSelector{
+										MatchLabels: map[string]string{
+											"first": "true",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				Nodes: []corev1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "first",
+							Labels: map[string]string{
+								"first": "true",
+								"spare": "true",
+							},
+						},
+					}, {
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "second",
+							Labels: map[string]string{
+								"spare": "true",
+							},
+						},
+					}, {
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "third",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						CIDR:       []*net.IPNet{ipnet("10.20.0.0/16")},
+						AutoAssign: true,
+						L2Advertisements: []*L2Advertisement{{
+							Nodes: map[string]bool{
+								"first":  true,
+								"second": true,
+								"third":  true,
+							},
+							AllInterfaces: true,
+							NodeWeights: map[string]int{
+								"first":  15,
+								"second": 10,
+							},
+						}},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "l2 advertisement with invalid node preference weight",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				L2Advs: []v1beta1.L2Advertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "l2adv1",
+						},
+						Spec: v1beta1.L2AdvertisementSpec{
+							NodePreferences: []v1beta1.NodePreference{
+								{
+									Weight: 0,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "l2 advertisements with a vrf",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				L2Advs: []v1beta1.L2Advertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "l2adv1",
+						},
+						Spec: v1beta1.L2AdvertisementSpec{
+							VRF: "red",
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "l2adv2",
+						},
+						Spec: v1beta1.L2AdvertisementSpec{
+							Interfaces: []string{"eth1"},
+							VRF:        "red",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					testPoolName: {
+						Name:       testPoolName,
+						CIDR:       []*net.IPNet{ipnet("10.20.0.0/16")},
+						AutoAssign: true,
+						L2Advertisements: []*L2Advertisement{
+							{
+								Nodes:         map[string]bool{},
+								AllInterfaces: true,
+								VRF:           "red",
+							},
+							{
+								Nodes:      map[string]bool{},
+								Interfaces: []string{"eth1"},
+								VRF:        "red",
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "l2 advertisements announcing a pool in different vrfs",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				L2Advs: []v1beta1.L2Advertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "l2adv1",
+						},
+						Spec: v1beta1.L2AdvertisementSpec{
+							VRF: "red",
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "l2adv2",
+						},
+						Spec: v1beta1.L2AdvertisementSpec{
+							IPAddressPools: []string{testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "nodes with a score annotation",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				L2Advs: []v1beta1.L2Advertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "l2adv1",
+						},
+					},
+				},
+				Nodes: []corev1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "first",
+							Annotations: map[string]string{NodeScoreAnnotation: "10"},
+						},
+					}, {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "second",
+							Annotations: map[string]string{NodeScoreAnnotation: "not-a-number"},
+						},
+					}, {
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "third",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					testPoolName: {
+						Name:       testPoolName,
+						CIDR:       []*net.IPNet{ipnet("10.20.0.0/16")},
+						AutoAssign: true,
+						L2Advertisements: []*L2Advertisement{{
+							Nodes: map[string]bool{
+								"first":  true,
+								"second": true,
+								"third":  true,
+							},
+							AllInterfaces: true,
+						}},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+				NodeScores:  map[string]int{"first": 10},
+			},
+		},
+		{
+			desc: "nodes with a weight annotation",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				L2Advs: []v1beta1.L2Advertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "l2adv1",
+						},
+					},
+				},
+				Nodes: []corev1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "first",
+							Annotations: map[string]string{NodeL2WeightAnnotation: "3"},
+						},
+					}, {
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "second",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					testPoolName: {
+						Name:       testPoolName,
+						CIDR:       []*net.IPNet{ipnet("10.20.0.0/16")},
+						AutoAssign: true,
+						L2Advertisements: []*L2Advertisement{{
+							Nodes: map[string]bool{
+								"first":  true,
+								"second": true,
+							},
+							AllInterfaces: true,
+						}},
+					},
+				}},
+				BFDProfiles:   map[string]*BFDProfile{},
+				Peers:         map[string]*Peer{},
+				NodeL2Weights: map[string]int{"first": 3},
+			},
+		},
+		{
+			desc: "node with a non positive weight annotation",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				Nodes: []corev1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "first",
+							Annotations: map[string]string{NodeL2WeightAnnotation: "0"},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "node with a non numeric weight annotation",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				Nodes: []corev1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "first",
+							Annotations: map[string]string{NodeL2WeightAnnotation: "big"},
+						},
+					},
+				},
+			},
+		},
 		{
 			desc: "use duplicate match labels in node selectors",
 			crs: ClusterResources{
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// ResourcesFromFile reads the MetalLB resources from the given file, holding
+// the same objects as the ones applied to the cluster, separated by "---".
+// Only the MetalLB resources are filled: the nodes, the namespaces and the
+// secrets referenced by the peers still come from the cluster.
+func ResourcesFromFile(path string) (ClusterResources, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ClusterResources{}, err
+	}
+	defer f.Close()
+	res, err := resourcesFromYAML(f)
+	if err != nil {
+		return ClusterResources{}, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return res, nil
+}
+
+func resourcesFromYAML(r io.Reader) (ClusterResources, error) {
+	res := ClusterResources{}
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for i := 1; ; i++ {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return ClusterResources{}, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		if err := addResource(&res, doc); err != nil {
+			return ClusterResources{}, fmt.Errorf("document %d: %w", i, err)
+		}
+	}
+}
+
+// addResource decodes the given document according to its kind and adds it
+// to the resources.
+func addResource(res *ClusterResources, doc []byte) error {
+	var meta metav1.TypeMeta
+	if err := yaml.Unmarshal(doc, &meta); err != nil {
+		return err
+	}
+	if meta.Kind == "" {
+		// A document with comments only.
+		return nil
+	}
+	want := metallbv1beta1.GroupVersion.String()
+	if meta.Kind == "BGPPeer" {
+		want = metallbv1beta2.GroupVersion.String()
+	}
+	if meta.APIVersion != want {
+		return fmt.Errorf("unsupported apiVersion %q for %s, must be %s", meta.APIVersion, meta.Kind, want)
+	}
+
+	var err error
+	switch meta.Kind {
+	case "IPAddressPool":
+		res.Pools, err = decodeAppend(doc, res.Pools)
+	case "AddressPool":
+		res.LegacyAddressPools, err = decodeAppend(doc, res.LegacyAddressPools)
+	case "BGPPeer":
+		res.Peers, err = decodeAppend(doc, res.Peers)
+	case "BFDProfile":
+		res.BFDProfiles, err = decodeAppend(doc, res.BFDProfiles)
+	case "BGPAdvertisement":
+		res.BGPAdvs, err = decodeAppend(doc, res.BGPAdvs)
+	case "L2Advertisement":
+		res.L2Advs, err = decodeAppend(doc, res.L2Advs)
+	case "Community":
+		res.Communities, err = decodeAppend(doc, res.Communities)
+	case "NodeAdvertisement":
+		res.NodeAdvs, err = decodeAppend(doc, res.NodeAdvs)
+	case "MetalLBMaintenance":
+		res.Maintenances, err = decodeAppend(doc, res.Maintenances)
+	default:
+		return fmt.Errorf("unsupported kind %q", meta.Kind)
+	}
+	return err
+}
+
+func decodeAppend[T any](doc []byte, items []T) ([]T, error) {
+	var item T
+	if err := yaml.UnmarshalStrict(doc, &item); err != nil {
+		return nil, err
+	}
+	return append(items, item), nil
+}
@@ -0,0 +1,188 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/api/v1beta1"
+	"go.universe.tf/metallb/api/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResourcesFromYAML(t *testing.T) {
+	poolMeta := metav1.TypeMeta{Kind: "IPAddressPool", APIVersion: "metallb.io/v1beta1"}
+	peerMeta := metav1.TypeMeta{Kind: "BGPPeer", APIVersion: "metallb.io/v1beta2"}
+	l2Meta := metav1.TypeMeta{Kind: "L2Advertisement", APIVersion: "metallb.io/v1beta1"}
+
+	tests := []struct {
+		desc string
+		yaml string
+		want *ClusterResources
+	}{
+		{
+			desc: "multiple documents",
+			yaml: `
+# The pools first.
+apiVersion: metallb.io/v1beta1
+kind: IPAddressPool
+metadata:
+  name: pool1
+  namespace: metallb-system
+spec:
+  addresses:
+  - 10.20.0.0/16
+---
+apiVersion: metallb.io/v1beta2
+kind: BGPPeer
+metadata:
+  name: peer1
+  namespace: metallb-system
+spec:
+  myASN: 42
+  peerASN: 142
+  peerAddress: 1.2.3.4
+---
+---
+# Nothing here.
+---
+apiVersion: metallb.io/v1beta1
+kind: L2Advertisement
+metadata:
+  name: l2adv1
+  namespace: metallb-system
+spec:
+  ipAddressPools:
+  - pool1
+`,
+			want: &ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						TypeMeta:   poolMeta,
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1", Namespace: "metallb-system"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{"10.20.0.0/16"},
+						},
+					},
+				},
+				Peers: []v1beta2.BGPPeer{
+					{
+						TypeMeta:   peerMeta,
+						ObjectMeta: metav1.ObjectMeta{Name: "peer1", Namespace: "metallb-system"},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     142,
+							Address: "1.2.3.4",
+						},
+					},
+				},
+				L2Advs: []v1beta1.L2Advertisement{
+					{
+						TypeMeta:   l2Meta,
+						ObjectMeta: metav1.ObjectMeta{Name: "l2adv1", Namespace: "metallb-system"},
+						Spec: v1beta1.L2AdvertisementSpec{
+							IPAddressPools: []string{"pool1"},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "empty file",
+			yaml: "",
+			want: &ClusterResources{},
+		},
+		{
+			desc: "unsupported kind",
+			yaml: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`,
+		},
+		{
+			desc: "peer with the v1beta1 version",
+			yaml: `
+apiVersion: metallb.io/v1beta1
+kind: BGPPeer
+metadata:
+  name: peer1
+spec:
+  myASN: 42
+  peerASN: 142
+  peerAddress: 1.2.3.4
+`,
+		},
+		{
+			desc: "unknown field",
+			yaml: `
+apiVersion: metallb.io/v1beta1
+kind: IPAddressPool
+metadata:
+  name: pool1
+spec:
+  adresses:
+  - 10.20.0.0/16
+`,
+		},
+		{
+			desc: "invalid yaml",
+			yaml: `
+apiVersion: metallb.io/v1beta1
+kind: IPAddressPool
+metadata: [
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := resourcesFromYAML(strings.NewReader(test.yaml))
+			if err != nil && test.want != nil {
+				t.Errorf("%q: parse failed: %s", test.desc, err)
+				return
+			}
+			if test.want == nil && err == nil {
+				t.Errorf("%q: parse unexpectedly succeeded", test.desc)
+				return
+			}
+			if test.want != nil {
+				if diff := cmp.Diff(*test.want, got); diff != "" {
+					t.Errorf("%q: parse returned wrong result (-want, +got)\n%s", test.desc, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestResourcesFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if _, err := ResourcesFromFile(path); err == nil {
+		t.Fatalf("reading a missing file unexpectedly succeeded")
+	}
+
+	content := `
+apiVersion: metallb.io/v1beta1
+kind: IPAddressPool
+metadata:
+  name: pool1
+spec:
+  addresses:
+  - 10.20.0.0/16
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write the config file: %s", err)
+	}
+	res, err := ResourcesFromFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the config file: %s", err)
+	}
+	if len(res.Pools) != 1 || res.Pools[0].Name != "pool1" {
+		t.Fatalf("unexpected pools read from the config file: %v", res.Pools)
+	}
+}
@@ -32,13 +32,36 @@ func DiscardFRROnly(c ClusterResources) error {
 		if p.Spec.KeepaliveTime.Duration != 0 {
 			return fmt.Errorf("peer %s has keepalive-time set on native bgp mode", p.Spec.Address)
 		}
+		if p.Spec.MinimumTTL != nil {
+			return fmt.Errorf("peer %s has minimum ttl set on native bgp mode", p.Spec.Address)
+		}
 		if p.Spec.VRFName != "" {
 			return fmt.Errorf("peer %s has vrf set on native bgp mode", p.Spec.Address)
 		}
+		if p.Spec.ImportFilter != nil {
+			return fmt.Errorf("peer %s has import filter set on native bgp mode", p.Spec.Address)
+		}
+		if p.Spec.AddPath != nil {
+			return fmt.Errorf("peer %s has add path set on native bgp mode", p.Spec.Address)
+		}
+		if p.Spec.NextHopSelf {
+			return fmt.Errorf("peer %s has next hop self set on native bgp mode", p.Spec.Address)
+		}
 	}
 	if len(c.BFDProfiles) > 0 {
 		return errors.New("bfd profiles section set")
 	}
+	for _, adv := range c.BGPAdvs {
+		if adv.Spec.EVPN != nil {
+			return fmt.Errorf("bgpadvertisement %s has evpn set on native bgp mode", adv.Name)
+		}
+		if adv.Spec.LinkBandwidthPerEndpoint != 0 {
+			return fmt.Errorf("bgpadvertisement %s has link bandwidth set on native bgp mode", adv.Name)
+		}
+		if adv.Spec.Color != 0 {
+			return fmt.Errorf("bgpadvertisement %s has color set on native bgp mode", adv.Name)
+		}
+	}
 	if len(c.BGPAdvs) == 0 {
 		return nil
 	}
@@ -85,6 +108,19 @@ func DiscardNativeOnly(c ClusterResources) error {
 			peerAddr[peerKey] = true
 		}
 	}
+	for _, p := range c.Peers {
+		if p.Spec.SrcPortRange != "" {
+			return fmt.Errorf("peer %s has source port range set on frr bgp mode", p.Spec.Address)
+		}
+		if p.Spec.TCPKeepalive != nil {
+			return fmt.Errorf("peer %s has tcp keepalive set on frr bgp mode", p.Spec.Address)
+		}
+	}
+	for _, p := range c.Pools {
+		if p.Spec.PreferBGP {
+			return fmt.Errorf("pool %s has preferBGP set on frr bgp mode", p.Name)
+		}
+	}
 	for _, p := range c.Peers {
 		for _, p1 := range c.Peers[1:] {
 			if p.Spec.MyASN != p1.Spec.MyASN &&
@@ -98,8 +134,28 @@ func DiscardNativeOnly(c ClusterResources) error {
 }
 
 // validateConfig is meant to validate all the inter-dependencies of a parsed configuration.
-// In this case, we ensure that bfd echo is not enabled on a v6 pool.
+// In this case, we ensure that bfd echo is not enabled on a v6 pool, that the peers
+// the advertisements add communities for exist, and that the advertisements exported
+// as EVPN routes select only peers in a vrf.
 func validateConfig(cfg *Config) error {
+	for _, p := range cfg.Pools.ByName {
+		for _, a := range p.BGPAdvertisements {
+			for peerName := range a.PeerCommunities {
+				if _, ok := cfg.Peers[peerName]; !ok {
+					return TransientError{fmt.Sprintf("bgpadvertisement %s adds communities for non existing peer %s", a.Name, peerName)}
+				}
+			}
+			if a.EVPN == nil {
+				continue
+			}
+			for _, peer := range cfg.Peers {
+				if peer.VRF != "" || !peersOverlap(a.Peers, []string{peer.Name}) {
+					continue
+				}
+				return fmt.Errorf("bgpadvertisement %s has evpn settings but selects peer %s in the default vrf, the evpn routes are exported only from a vrf", a.Name, peer.Name)
+			}
+		}
+	}
 	for _, p := range cfg.Pools.ByName {
 		containsV6 := false
 		for _, cidr := range p.CIDR {
@@ -132,6 +188,34 @@ func validateConfig(cfg *Config) error {
 	return nil
 }
 
+// ValidateDualMode checks that a pool advertised via both L2 and BGP is not
+// announced via L2 and via BGP from disjoint sets of nodes, in which case no
+// node serves both the local and the remote clients. Such a configuration is
+// valid, so the error is meant to be reported as a warning.
+func ValidateDualMode(p *Pool) error {
+	if !p.DualMode() {
+		return nil
+	}
+	l2Nodes, bgpNodes := map[string]bool{}, map[string]bool{}
+	for _, a := range p.L2Advertisements {
+		for n, ok := range a.Nodes {
+			l2Nodes[n] = l2Nodes[n] || ok
+		}
+	}
+	for _, a := range p.BGPAdvertisements {
+		for n, ok := range a.Nodes {
+			bgpNodes[n] = bgpNodes[n] || ok
+		}
+	}
+	if len(l2Nodes) == 0 || len(bgpNodes) == 0 {
+		return nil
+	}
+	if !nodesOverlap(l2Nodes, bgpNodes) {
+		return fmt.Errorf("pool %s is advertised via L2 and via BGP from disjoint sets of nodes", p.Name)
+	}
+	return nil
+}
+
 func hasBFDEcho(peer *Peer, bfdProfiles map[string]*BFDProfile) bool {
 	profile, ok := bfdProfiles[peer.BFDProfile]
 	if !ok {
@@ -8,6 +8,7 @@ import (
 
 	"go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/api/v1beta2"
+	"go.universe.tf/metallb/internal/pointer"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -82,6 +83,110 @@ func TestValidate(t *testing.T) {
 			},
 			mustFail: true,
 		},
+		{
+			desc: "evpn advertisement",
+			config: ClusterResources{
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "foo",
+						},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							EVPN: &v1beta1.EVPNAdvertisement{VNI: 100},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "link bandwidth advertisement",
+			config: ClusterResources{
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "foo",
+						},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LinkBandwidthPerEndpoint: 100,
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "color advertisement",
+			config: ClusterResources{
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "foo",
+						},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Color: 100,
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "import filter set",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address:      "1.2.3.4",
+							ImportFilter: &v1beta2.ImportFilter{Prefixes: []string{"10.0.0.0/8"}},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "add path set",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address: "1.2.3.4",
+							AddPath: &v1beta2.AddPath{Send: true},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "next hop self set",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address:     "1.2.3.4",
+							NextHopSelf: true,
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "minimum ttl set",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address:    "1.2.3.4",
+							MinimumTTL: pointer.Uint32Ptr(255),
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
 		{
 			desc: "should pass",
 			config: ClusterResources{
@@ -367,6 +472,47 @@ func TestValidateFRR(t *testing.T) {
 			},
 			mustFail: true,
 		},
+		{
+			desc: "source port range set",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address:      "1.2.3.4",
+							SrcPortRange: "40000",
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "tcp keepalive set",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address:      "1.2.3.4",
+							TCPKeepalive: &v1beta2.TCPKeepalive{Count: 3},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "pool preferring bgp",
+			config: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						Spec: v1beta1.IPAddressPoolSpec{
+							PreferBGP: true,
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -381,3 +527,51 @@ func TestValidateFRR(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateDualMode(t *testing.T) {
+	tests := []struct {
+		desc     string
+		pool     *Pool
+		mustFail bool
+	}{
+		{
+			desc: "l2 only",
+			pool: &Pool{
+				L2Advertisements: []*L2Advertisement{{Nodes: map[string]bool{"first": true}}},
+			},
+		},
+		{
+			desc: "overlapping nodes",
+			pool: &Pool{
+				L2Advertisements:  []*L2Advertisement{{Nodes: map[string]bool{"first": true}}},
+				BGPAdvertisements: []*BGPAdvertisement{{Nodes: map[string]bool{"first": true, "second": true}}},
+			},
+		},
+		{
+			desc: "disjoint nodes",
+			pool: &Pool{
+				L2Advertisements:  []*L2Advertisement{{Nodes: map[string]bool{"first": true}}},
+				BGPAdvertisements: []*BGPAdvertisement{{Nodes: map[string]bool{"second": true}}},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "no nodes",
+			pool: &Pool{
+				L2Advertisements:  []*L2Advertisement{{Nodes: map[string]bool{}}},
+				BGPAdvertisements: []*BGPAdvertisement{{Nodes: map[string]bool{"second": true}}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := ValidateDualMode(test.pool)
+			if test.mustFail && err == nil {
+				t.Fatalf("Expected error for %s", test.desc)
+			}
+			if !test.mustFail && err != nil {
+				t.Fatalf("Not expected error %s for %s", err, test.desc)
+			}
+		})
+	}
+}
@@ -36,6 +36,7 @@ func (v *validator) Validate(resources ...client.ObjectList) error {
 		L2Advs:             make([]metallbv1beta1.L2Advertisement, 0),
 		LegacyAddressPools: make([]metallbv1beta1.AddressPool, 0),
 		Communities:        make([]metallbv1beta1.Community, 0),
+		NodeAdvs:           make([]metallbv1beta1.NodeAdvertisement, 0),
 	}
 	for _, list := range resources {
 		switch list := list.(type) {
@@ -53,6 +54,8 @@ func (v *validator) Validate(resources ...client.ObjectList) error {
 			clusterResources.LegacyAddressPools = append(clusterResources.LegacyAddressPools, list.Items...)
 		case *metallbv1beta1.CommunityList:
 			clusterResources.Communities = append(clusterResources.Communities, list.Items...)
+		case *metallbv1beta1.NodeAdvertisementList:
+			clusterResources.NodeAdvs = append(clusterResources.NodeAdvs, list.Items...)
 		}
 	}
 	_, err := For(clusterResources, v.validate)
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"go.universe.tf/metallb/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// maxAnnouncingNodes is the number of nodes listed at most in the announcing
+// nodes annotation of a service, the others being only counted.
+const maxAnnouncingNodes = 10
+
+// ServiceAnnouncedReconciler aggregates the services the speakers report
+// announcing in the SpeakerReport of their node, telling for each
+// LoadBalancer service with assigned IPs if at least one node announces it.
+type ServiceAnnouncedReconciler struct {
+	client.Client
+	Logger            log.Logger
+	Scheme            *runtime.Scheme
+	Namespace         string
+	LoadBalancerClass string
+	ClaimClassless    bool
+	// AnnotateNodes enables listing the nodes announcing each service
+	// in the announcing nodes annotation of the service.
+	AnnotateNodes bool
+}
+
+func (r *ServiceAnnouncedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	level.Debug(r.Logger).Log("controller", "ServiceAnnouncedReconciler", "start reconcile", req.NamespacedName.String())
+	defer level.Debug(r.Logger).Log("controller", "ServiceAnnouncedReconciler", "end reconcile", req.NamespacedName.String())
+
+	var services corev1.ServiceList
+	if err := r.List(ctx, &services); err != nil {
+		level.Error(r.Logger).Log("controller", "ServiceAnnouncedReconciler", "message", "failed to get services", "error", err)
+		return ctrl.Result{}, err
+	}
+
+	var reports metallbv1beta1.SpeakerReportList
+	if err := r.List(ctx, &reports, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ServiceAnnouncedReconciler", "message", "failed to get speaker reports", "error", err)
+		return ctrl.Result{}, err
+	}
+
+	announcing := map[string][]string{}
+	// When a node announces more services than it can list, the services
+	// not listed by any node may still be announced.
+	truncated := false
+	for _, report := range reports.Items {
+		for _, name := range report.Status.AnnouncedServices {
+			announcing[name] = append(announcing[name], report.Status.Node)
+		}
+		if report.Status.AnnouncedServicesTruncated {
+			level.Warn(r.Logger).Log("controller", "ServiceAnnouncedReconciler", "node", report.Status.Node, "message", "the node announces too many services to list them all, the services not listed are not reported")
+			truncated = true
+		}
+	}
+
+	serviceAnnounced.Reset()
+	var errs []error
+	for i := range services.Items {
+		svc := &services.Items[i]
+		if filterByLoadBalancerClass(svc, r.LoadBalancerClass, r.ClaimClassless) {
+			continue
+		}
+		nodes := announcing[svc.Namespace+"/"+svc.Name]
+		if svc.Spec.Type != corev1.ServiceTypeLoadBalancer || len(svc.Status.LoadBalancer.Ingress) == 0 {
+			nodes = nil
+		} else {
+			switch {
+			case len(nodes) > 0:
+				serviceAnnounced.WithLabelValues(svc.Namespace, svc.Name).Set(1)
+			case !truncated:
+				serviceAnnounced.WithLabelValues(svc.Namespace, svc.Name).Set(0)
+			}
+		}
+		if !r.AnnotateNodes {
+			continue
+		}
+		// A failure must not prevent the other services from being
+		// annotated, the request is retried for all of them anyway.
+		if err := r.annotateNodes(ctx, svc, nodes); err != nil {
+			level.Error(r.Logger).Log("controller", "ServiceAnnouncedReconciler", "service", svc.Namespace+"/"+svc.Name, "message", "failed to annotate the announcing nodes", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return ctrl.Result{}, utilerrors.NewAggregate(errs)
+}
+
+// annotateNodes sets the announcing nodes annotation of the service to the
+// given nodes, removing it when there is none. The service is not patched
+// when the annotation already holds the nodes.
+func (r *ServiceAnnouncedReconciler) annotateNodes(ctx context.Context, svc *corev1.Service, nodes []string) error {
+	value := announcingNodesValue(nodes)
+	current, ok := svc.Annotations[config.ServiceAnnouncingNodesAnnotation]
+	if value == current && ok == (value != "") {
+		return nil
+	}
+	patch := client.MergeFrom(svc.DeepCopy())
+	if value == "" {
+		delete(svc.Annotations, config.ServiceAnnouncingNodesAnnotation)
+	} else {
+		if svc.Annotations == nil {
+			svc.Annotations = map[string]string{}
+		}
+		svc.Annotations[config.ServiceAnnouncingNodesAnnotation] = value
+	}
+	return r.Patch(ctx, svc, patch)
+}
+
+// announcingNodesValue returns the sorted, comma separated names of the
+// nodes, the ones beyond maxAnnouncingNodes being replaced by their count
+// so that the annotation stays small for the services announced from many
+// nodes.
+func announcingNodesValue(nodes []string) string {
+	sorted := append([]string{}, nodes...)
+	sort.Strings(sorted)
+	if len(sorted) <= maxAnnouncingNodes {
+		return strings.Join(sorted, ",")
+	}
+	return fmt.Sprintf("%s (+%d more)", strings.Join(sorted[:maxAnnouncingNodes], ","), len(sorted)-maxAnnouncingNodes)
+}
+
+// onlyAnnouncingNodesChanged tells if the announcing nodes annotation,
+// written by this reconciler, is the only change between the two versions of
+// the service.
+func onlyAnnouncingNodesChanged(oldSvc, newSvc *corev1.Service) bool {
+	oldValue, oldOk := oldSvc.Annotations[config.ServiceAnnouncingNodesAnnotation]
+	newValue, newOk := newSvc.Annotations[config.ServiceAnnouncingNodesAnnotation]
+	if oldValue == newValue && oldOk == newOk {
+		return false
+	}
+	withoutAnnotation := func(svc *corev1.Service) *corev1.Service {
+		res := svc.DeepCopy()
+		delete(res.Annotations, config.ServiceAnnouncingNodesAnnotation)
+		if len(res.Annotations) == 0 {
+			res.Annotations = nil
+		}
+		res.ResourceVersion = ""
+		res.ManagedFields = nil
+		return res
+	}
+	return reflect.DeepEqual(withoutAnnotation(oldSvc), withoutAnnotation(newSvc))
+}
+
+func (r *ServiceAnnouncedReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	p := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			// The patches of the announcing nodes annotation must not
+			// trigger another reconcile.
+			if newSvc, ok := e.ObjectNew.(*corev1.Service); ok {
+				oldSvc, ok := e.ObjectOld.(*corev1.Service)
+				return !ok || !onlyAnnouncingNodesChanged(oldSvc, newSvc)
+			}
+			newReport, ok := e.ObjectNew.(*metallbv1beta1.SpeakerReport)
+			if !ok {
+				return true
+			}
+			oldReport, ok := e.ObjectOld.(*metallbv1beta1.SpeakerReport)
+			if !ok {
+				return true
+			}
+			return !reflect.DeepEqual(oldReport.Status, newReport.Status)
+		},
+	}
+	// All the changes are coalesced into a single request, each reconcile
+	// covering all the services.
+	enqueueAll := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{}}
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("serviceannounced").
+		Watches(&source.Kind{Type: &corev1.Service{}}, enqueueAll).
+		Watches(&source.Kind{Type: &metallbv1beta1.SpeakerReport{}}, enqueueAll).
+		WithEventFilter(p).
+		Complete(r)
+}
@@ -0,0 +1,266 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"go.universe.tf/metallb/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestServiceAnnouncedReconciler(t *testing.T) {
+	node := func(name string, announced ...string) *metallbv1beta1.SpeakerReport {
+		return &metallbv1beta1.SpeakerReport{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Status: metallbv1beta1.SpeakerReportStatus{
+				Node:              name,
+				AnnouncedServices: announced,
+			},
+		}
+	}
+	service := func(name string, svcType corev1.ServiceType, ip string) *corev1.Service {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			Spec:       corev1.ServiceSpec{Type: svcType},
+		}
+		if ip != "" {
+			svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: ip}}
+		}
+		return svc
+	}
+
+	initObjects := []client.Object{
+		node("nodeA", "ns/bgp", "ns/both"),
+		node("nodeB", "ns/both", "ns/l2"),
+		node("nodeC"),
+		service("bgp", corev1.ServiceTypeLoadBalancer, "10.0.0.1"),
+		service("l2", corev1.ServiceTypeLoadBalancer, "10.0.0.2"),
+		service("both", corev1.ServiceTypeLoadBalancer, "10.0.0.3"),
+		service("unannounced", corev1.ServiceTypeLoadBalancer, "10.0.0.4"),
+		service("pending", corev1.ServiceTypeLoadBalancer, ""),
+		service("clusterip", corev1.ServiceTypeClusterIP, ""),
+	}
+	fakeClient, err := newFakeClient(initObjects)
+	if err != nil {
+		t.Fatalf("test failed to create fake client: %v", err)
+	}
+
+	r := &ServiceAnnouncedReconciler{
+		Client:    fakeClient,
+		Logger:    log.NewNopLogger(),
+		Scheme:    scheme,
+		Namespace: testNamespace,
+	}
+	_, err = r.Reconcile(context.TODO(), reconcile.Request{})
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	expected := map[string]float64{
+		"bgp":         1,
+		"l2":          1,
+		"both":        1,
+		"unannounced": 0,
+	}
+	for name, want := range expected {
+		if got := testutil.ToFloat64(serviceAnnounced.WithLabelValues("ns", name)); got != want {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+		}
+	}
+	// Only the LoadBalancer services with IPs have the metric.
+	if got := testutil.CollectAndCount(serviceAnnounced); got != 4 {
+		t.Errorf("expected the metric of 4 services, got %d", got)
+	}
+
+	// The metric follows the services reported by the nodes.
+	var n metallbv1beta1.SpeakerReport
+	if err := fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "nodeB"}, &n); err != nil {
+		t.Fatalf("get failed on nodeB: %v", err)
+	}
+	n.Status.AnnouncedServices = []string{"ns/both"}
+	if err := fakeClient.Update(context.TODO(), &n); err != nil {
+		t.Fatalf("update failed on nodeB: %v", err)
+	}
+	_, err = r.Reconcile(context.TODO(), reconcile.Request{})
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if got := testutil.ToFloat64(serviceAnnounced.WithLabelValues("ns", "l2")); got != 0 {
+		t.Errorf("l2: expected 0 after nodeB stopped announcing it, got %v", got)
+	}
+
+	// The services not listed are not reported as unannounced while a
+	// node lists only part of the services it announces.
+	n.Status.AnnouncedServicesTruncated = true
+	if err := fakeClient.Update(context.TODO(), &n); err != nil {
+		t.Fatalf("update failed on nodeB: %v", err)
+	}
+	_, err = r.Reconcile(context.TODO(), reconcile.Request{})
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if got := testutil.CollectAndCount(serviceAnnounced); got != 2 {
+		t.Errorf("expected the metric of the 2 announced services only, got %d", got)
+	}
+}
+
+func TestServiceAnnouncingNodes(t *testing.T) {
+	node := func(name string, announced ...string) *metallbv1beta1.SpeakerReport {
+		return &metallbv1beta1.SpeakerReport{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Status: metallbv1beta1.SpeakerReportStatus{
+				Node:              name,
+				AnnouncedServices: announced,
+			},
+		}
+	}
+	service := func(name, ip string) *corev1.Service {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+		}
+		if ip != "" {
+			svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: ip}}
+		}
+		return svc
+	}
+
+	initObjects := []client.Object{
+		service("svc", "10.0.0.1"),
+		service("many", "10.0.0.2"),
+		service("pending", ""),
+	}
+	initObjects = append(initObjects, node("nodeB", "ns/svc", "ns/many"), node("nodeA", "ns/svc", "ns/many", "ns/pending"))
+	for i := 0; i < maxAnnouncingNodes; i++ {
+		initObjects = append(initObjects, node(fmt.Sprintf("other%02d", i), "ns/many"))
+	}
+	fakeClient, err := newFakeClient(initObjects)
+	if err != nil {
+		t.Fatalf("test failed to create fake client: %v", err)
+	}
+
+	r := &ServiceAnnouncedReconciler{
+		Client:        fakeClient,
+		Logger:        log.NewNopLogger(),
+		Scheme:        scheme,
+		Namespace:     testNamespace,
+		AnnotateNodes: true,
+	}
+	annotation := func(name string) (string, bool) {
+		var svc corev1.Service
+		if err := fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: name}, &svc); err != nil {
+			t.Fatalf("get failed on %s: %v", name, err)
+		}
+		v, ok := svc.Annotations[config.ServiceAnnouncingNodesAnnotation]
+		return v, ok
+	}
+
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{}); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if got, _ := annotation("svc"); got != "nodeA,nodeB" {
+		t.Errorf("svc: expected nodeA,nodeB, got %q", got)
+	}
+	want := "nodeA,nodeB,other00,other01,other02,other03,other04,other05,other06,other07 (+2 more)"
+	if got, _ := annotation("many"); got != want {
+		t.Errorf("many: expected %q, got %q", want, got)
+	}
+	if got, ok := annotation("pending"); ok {
+		t.Errorf("pending: expected no annotation, got %q", got)
+	}
+
+	// The services already holding the right annotation are not patched.
+	resourceVersion := func(name string) string {
+		var svc corev1.Service
+		if err := fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: name}, &svc); err != nil {
+			t.Fatalf("get failed on %s: %v", name, err)
+		}
+		return svc.ResourceVersion
+	}
+	before := resourceVersion("svc")
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{}); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if after := resourceVersion("svc"); after != before {
+		t.Errorf("svc: expected no patch with the annotation unchanged, resource version went from %s to %s", before, after)
+	}
+
+	// The annotation is removed once no node announces the service.
+	var n metallbv1beta1.SpeakerReport
+	for _, name := range []string{"nodeA", "nodeB"} {
+		if err := fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: name}, &n); err != nil {
+			t.Fatalf("get failed on %s: %v", name, err)
+		}
+		n.Status.AnnouncedServices = []string{"ns/many"}
+		if err := fakeClient.Update(context.TODO(), &n); err != nil {
+			t.Fatalf("update failed on %s: %v", name, err)
+		}
+	}
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{}); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if got, ok := annotation("svc"); ok {
+		t.Errorf("svc: expected no annotation once not announced, got %q", got)
+	}
+}
+
+func TestOnlyAnnouncingNodesChanged(t *testing.T) {
+	service := func(annotations map[string]string, ip string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "ns", Annotations: annotations},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: ip}}},
+			},
+		}
+	}
+	annotated := map[string]string{config.ServiceAnnouncingNodesAnnotation: "nodeA"}
+
+	tests := []struct {
+		desc     string
+		old, new *corev1.Service
+		expected bool
+	}{
+		{
+			desc:     "annotation added",
+			old:      service(nil, "10.0.0.1"),
+			new:      service(annotated, "10.0.0.1"),
+			expected: true,
+		},
+		{
+			desc:     "annotation removed",
+			old:      service(annotated, "10.0.0.1"),
+			new:      service(nil, "10.0.0.1"),
+			expected: true,
+		},
+		{
+			desc:     "annotation and status changed",
+			old:      service(nil, "10.0.0.1"),
+			new:      service(annotated, "10.0.0.2"),
+			expected: false,
+		},
+		{
+			desc:     "annotation unchanged",
+			old:      service(annotated, "10.0.0.1"),
+			new:      service(annotated, "10.0.0.2"),
+			expected: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := onlyAnnouncingNodesChanged(test.old, test.new); got != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -45,7 +45,12 @@ type ConfigReconciler struct {
 	ValidateConfig config.Validate
 	ForceReload    func()
 	BGPType        string
-	currentConfig  *config.Config
+	// ConfigFile, when set, is the file the MetalLB resources are read
+	// from instead of the cluster.
+	ConfigFile string
+	// Applied, when set, holds the last configuration applied.
+	Applied       *AppliedConfig
+	currentConfig *config.Config
 }
 
 func (r *ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -57,49 +62,23 @@ var requestHandler = func(r *ConfigReconciler, ctx context.Context, req ctrl.Req
 	defer level.Info(r.Logger).Log("controller", "ConfigReconciler", "end reconcile", req.NamespacedName.String())
 	updates.Inc()
 
-	var addressPools metallbv1beta1.AddressPoolList
-	if err := r.List(ctx, &addressPools, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get addresspools", "error", err)
-		return ctrl.Result{}, err
-	}
-
-	var ipAddressPools metallbv1beta1.IPAddressPoolList
-	if err := r.List(ctx, &ipAddressPools, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "ConfigReconciler", "error", "failed to get ipaddresspools", "error", err)
-		return ctrl.Result{}, err
+	var resources config.ClusterResources
+	var err error
+	if r.ConfigFile != "" {
+		resources, err = config.ResourcesFromFile(r.ConfigFile)
+		if err != nil {
+			configStale.Set(1)
+			level.Error(r.Logger).Log("controller", "ConfigReconciler", "error", "failed to read the configuration file", "file", r.ConfigFile, "error", err)
+			return ctrl.Result{}, nil
+		}
+	} else {
+		resources, err = r.listResources(ctx)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
-	var bgpPeers metallbv1beta2.BGPPeerList
-	if err := r.List(ctx, &bgpPeers, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get bgppeers", "error", err)
-		return ctrl.Result{}, err
-	}
-
-	var bfdProfiles metallbv1beta1.BFDProfileList
-	if err := r.List(ctx, &bfdProfiles, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get bfdprofiles", "error", err)
-		return ctrl.Result{}, err
-	}
-
-	var l2Advertisements metallbv1beta1.L2AdvertisementList
-	if err := r.List(ctx, &l2Advertisements, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get l2 advertisements", "error", err)
-		return ctrl.Result{}, err
-	}
-
-	var bgpAdvertisements metallbv1beta1.BGPAdvertisementList
-	if err := r.List(ctx, &bgpAdvertisements, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get bgp advertisements", "error", err)
-		return ctrl.Result{}, err
-	}
-
-	var communities metallbv1beta1.CommunityList
-	if err := r.List(ctx, &communities, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "ConfigReconciler", "error", "failed to get communities", "error", err)
-		return ctrl.Result{}, err
-	}
-
-	secrets, err := r.getSecrets(ctx)
+	resources.PasswordSecrets, err = r.getSecrets(ctx)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -116,18 +95,8 @@ var requestHandler = func(r *ConfigReconciler, ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, err
 	}
 
-	resources := config.ClusterResources{
-		Pools:              ipAddressPools.Items,
-		Peers:              bgpPeers.Items,
-		BFDProfiles:        bfdProfiles.Items,
-		L2Advs:             l2Advertisements.Items,
-		BGPAdvs:            bgpAdvertisements.Items,
-		LegacyAddressPools: addressPools.Items,
-		Communities:        communities.Items,
-		PasswordSecrets:    secrets,
-		Nodes:              nodes.Items,
-		Namespaces:         namespaces.Items,
-	}
+	resources.Nodes = nodes.Items
+	resources.Namespaces = namespaces.Items
 
 	level.Debug(r.Logger).Log("controller", "ConfigReconciler", "metallb CRs and Secrets", dumpClusterResources(&resources))
 
@@ -167,18 +136,106 @@ var requestHandler = func(r *ConfigReconciler, ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, nil
 	}
 
+	if r.Applied != nil {
+		r.Applied.set(resources, cfg)
+	}
 	configLoaded.Set(1)
 	configStale.Set(0)
 	level.Info(r.Logger).Log("controller", "ConfigReconciler", "event", "config reloaded")
 	return ctrl.Result{}, nil
 }
 
+// listResources lists the MetalLB resources from the cluster.
+func (r *ConfigReconciler) listResources(ctx context.Context) (config.ClusterResources, error) {
+	var addressPools metallbv1beta1.AddressPoolList
+	if err := r.List(ctx, &addressPools, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get addresspools", "error", err)
+		return config.ClusterResources{}, err
+	}
+
+	var ipAddressPools metallbv1beta1.IPAddressPoolList
+	if err := r.List(ctx, &ipAddressPools, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "error", "failed to get ipaddresspools", "error", err)
+		return config.ClusterResources{}, err
+	}
+
+	var bgpPeers metallbv1beta2.BGPPeerList
+	if err := r.List(ctx, &bgpPeers, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get bgppeers", "error", err)
+		return config.ClusterResources{}, err
+	}
+
+	var bfdProfiles metallbv1beta1.BFDProfileList
+	if err := r.List(ctx, &bfdProfiles, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get bfdprofiles", "error", err)
+		return config.ClusterResources{}, err
+	}
+
+	var l2Advertisements metallbv1beta1.L2AdvertisementList
+	if err := r.List(ctx, &l2Advertisements, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get l2 advertisements", "error", err)
+		return config.ClusterResources{}, err
+	}
+
+	var bgpAdvertisements metallbv1beta1.BGPAdvertisementList
+	if err := r.List(ctx, &bgpAdvertisements, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get bgp advertisements", "error", err)
+		return config.ClusterResources{}, err
+	}
+
+	var communities metallbv1beta1.CommunityList
+	if err := r.List(ctx, &communities, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "error", "failed to get communities", "error", err)
+		return config.ClusterResources{}, err
+	}
+
+	var nodeAdvertisements metallbv1beta1.NodeAdvertisementList
+	if err := r.List(ctx, &nodeAdvertisements, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get node advertisements", "error", err)
+		return config.ClusterResources{}, err
+	}
+
+	var maintenances metallbv1beta1.MetalLBMaintenanceList
+	if err := r.List(ctx, &maintenances); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get metallb maintenances", "error", err)
+		return config.ClusterResources{}, err
+	}
+
+	return config.ClusterResources{
+		Pools:              ipAddressPools.Items,
+		Peers:              bgpPeers.Items,
+		BFDProfiles:        bfdProfiles.Items,
+		L2Advs:             l2Advertisements.Items,
+		BGPAdvs:            bgpAdvertisements.Items,
+		LegacyAddressPools: addressPools.Items,
+		Communities:        communities.Items,
+		NodeAdvs:           nodeAdvertisements.Items,
+		Maintenances:       maintenances.Items,
+	}, nil
+}
+
 func (r *ConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	p := predicate.Funcs{
 		UpdateFunc: func(e event.UpdateEvent) bool {
-			return filterNodeEvent(e) && filterNamespaceEvent(e)
+			return filterNodeEvent(e) && filterNamespaceEvent(e) && filterPoolStatusEvent(e)
 		},
 	}
+	if r.ConfigFile != "" {
+		// The MetalLB CRDs may not be installed, only the resources
+		// the configuration file depends on are watched.
+		fileSource, err := configFileSource(mgr, r.Logger, r.ConfigFile)
+		if err != nil {
+			return err
+		}
+		return ctrl.NewControllerManagedBy(mgr).
+			Named("configfile").
+			Watches(fileSource, &handler.EnqueueRequestForObject{}).
+			Watches(&source.Kind{Type: &corev1.Node{}}, &handler.EnqueueRequestForObject{}).
+			Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestForObject{}).
+			Watches(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestForObject{}).
+			WithEventFilter(p).
+			Complete(r)
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&metallbv1beta2.BGPPeer{}).
 		Watches(&source.Kind{Type: &metallbv1beta1.IPAddressPool{}}, &handler.EnqueueRequestForObject{}).
@@ -188,6 +245,8 @@ func (r *ConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Watches(&source.Kind{Type: &metallbv1beta1.BFDProfile{}}, &handler.EnqueueRequestForObject{}).
 		Watches(&source.Kind{Type: &metallbv1beta1.AddressPool{}}, &handler.EnqueueRequestForObject{}).
 		Watches(&source.Kind{Type: &metallbv1beta1.Community{}}, &handler.EnqueueRequestForObject{}).
+		Watches(&source.Kind{Type: &metallbv1beta1.NodeAdvertisement{}}, &handler.EnqueueRequestForObject{}).
+		Watches(&source.Kind{Type: &metallbv1beta1.MetalLBMaintenance{}}, &handler.EnqueueRequestForObject{}).
 		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestForObject{}).
 		Watches(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestForObject{}).
 		WithEventFilter(p).
@@ -203,7 +262,9 @@ func filterNodeEvent(e event.UpdateEvent) bool {
 	if !ok {
 		return true
 	}
-	if labels.Equals(labels.Set(oldNodeObj.Labels), labels.Set(newNodeObj.Labels)) {
+	if labels.Equals(labels.Set(oldNodeObj.Labels), labels.Set(newNodeObj.Labels)) &&
+		oldNodeObj.Annotations[config.NodeScoreAnnotation] == newNodeObj.Annotations[config.NodeScoreAnnotation] &&
+		oldNodeObj.Annotations[config.NodeL2WeightAnnotation] == newNodeObj.Annotations[config.NodeL2WeightAnnotation] {
 		return false
 	}
 	return true
@@ -225,6 +286,20 @@ func filterNamespaceEvent(e event.UpdateEvent) bool {
 	return true
 }
 
+// filterPoolStatusEvent filters out the updates of the IPAddressPools
+// not changing their spec, such as the ones to the allocation status.
+func filterPoolStatusEvent(e event.UpdateEvent) bool {
+	newPoolObj, ok := e.ObjectNew.(*metallbv1beta1.IPAddressPool)
+	if !ok {
+		return true
+	}
+	oldPoolObj, ok := e.ObjectOld.(*metallbv1beta1.IPAddressPool)
+	if !ok {
+		return true
+	}
+	return oldPoolObj.Generation != newPoolObj.Generation
+}
+
 func (r *ConfigReconciler) getSecrets(ctx context.Context) (map[string]corev1.Secret, error) {
 	var secrets corev1.SecretList
 	if err := r.List(ctx, &secrets, client.InNamespace(r.Namespace)); err != nil {
@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"sync"
 	"testing"
@@ -219,6 +220,78 @@ func TestSecretShouldntTrigger(t *testing.T) {
 	}
 }
 
+func TestConfigFile(t *testing.T) {
+	initObjects := objectsFromResources(configControllerValidResources)
+	fakeClient, err := newFakeClient(initObjects)
+	if err != nil {
+		t.Fatalf("test failed to create fake client: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+apiVersion: metallb.io/v1beta1
+kind: IPAddressPool
+metadata:
+  name: filepool
+  namespace: metallb-system
+spec:
+  addresses:
+  - 10.30.0.0/16
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write the config file: %v", err)
+	}
+
+	var handlerCfg *config.Config
+	mockHandler := func(l log.Logger, cfg *config.Config) SyncState {
+		handlerCfg = cfg
+		return SyncStateSuccess
+	}
+
+	r := &ConfigReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: config.DontValidate,
+		Handler:        mockHandler,
+		ForceReload:    func() {},
+		ConfigFile:     path,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: filepath.Base(path),
+		},
+	}
+
+	_, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if handlerCfg == nil {
+		t.Fatalf("handler not called")
+	}
+	if len(handlerCfg.Pools.ByName) != 1 || handlerCfg.Pools.ByName["filepool"] == nil {
+		t.Fatalf("expected only the pool of the file, got %v", handlerCfg.Pools.ByName)
+	}
+	if len(handlerCfg.Peers) != 0 {
+		t.Fatalf("expected no peers, the ones in the cluster must be ignored, got %v", handlerCfg.Peers)
+	}
+
+	// An invalid file keeps the current config.
+	handlerCfg = nil
+	if err := os.WriteFile(path, []byte("kind: Foo\n"), 0644); err != nil {
+		t.Fatalf("failed to write the config file: %v", err)
+	}
+	_, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if handlerCfg != nil {
+		t.Fatalf("handler called with an invalid config file")
+	}
+}
+
 func TestNodeEvent(t *testing.T) {
 	g := NewGomegaWithT(t)
 	testEnv := &envtest.Environment{
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// configFileSource returns a source triggering a reconciliation each time
+// the given configuration file changes or the process receives a SIGHUP.
+// The watcher is run by the manager.
+func configFileSource(mgr ctrl.Manager, l log.Logger, path string) (source.Source, error) {
+	events := make(chan event.GenericEvent)
+	err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		return watchConfigFile(ctx, l, path, func() {
+			select {
+			case events <- configFileEvent(path):
+			case <-ctx.Done():
+			}
+		})
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return &source.Channel{Source: events}, nil
+}
+
+// configFileEvent is the event for the reconcilers to reload the
+// configuration file.
+func configFileEvent(path string) event.GenericEvent {
+	return event.GenericEvent{Object: &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: filepath.Base(path)},
+	}}
+}
+
+// watchConfigFile calls changed each time the configuration file changes or
+// the process receives a SIGHUP, until ctx is done. The directory of
+// the file is watched rather than the file itself, so that replacing the
+// file, as done when a ConfigMap is updated, is noticed too.
+func watchConfigFile(ctx context.Context, l log.Logger, path string, changed func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			level.Info(l).Log("event", "sighup", "file", path, "msg", "reloading the configuration file")
+			changed()
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			level.Debug(l).Log("event", "configFileChanged", "file", ev.Name, "op", ev.Op.String())
+			changed()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			level.Error(l).Log("op", "watchConfigFile", "file", path, "error", err, "msg", "failed to watch the configuration file")
+		}
+	}
+}
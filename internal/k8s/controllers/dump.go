@@ -4,14 +4,29 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
 	"go.universe.tf/metallb/internal/config"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
 )
 
 func dumpClusterResources(c *config.ClusterResources) string {
+	return dumpResource(sanitizeClusterResources(c))
+}
+
+// sanitizeClusterResources returns the MetalLB resources with the passwords
+// and the data of the secrets retracted, without the nodes and the
+// namespaces.
+func sanitizeClusterResources(c *config.ClusterResources) config.ClusterResources {
 	withNoSecret := config.ClusterResources{
 		Pools:              c.Pools,
 		Peers:              sanitizeBGPPeer(c.Peers...),
@@ -20,6 +35,8 @@ func dumpClusterResources(c *config.ClusterResources) string {
 		BGPAdvs:            c.BGPAdvs,
 		LegacyAddressPools: c.LegacyAddressPools,
 		Communities:        c.Communities,
+		NodeAdvs:           c.NodeAdvs,
+		Maintenances:       c.Maintenances,
 	}
 	withNoSecret.PasswordSecrets = make(map[string]corev1.Secret)
 	for k, s := range c.PasswordSecrets {
@@ -27,10 +44,14 @@ func dumpClusterResources(c *config.ClusterResources) string {
 		secretToDump.Data = nil
 		withNoSecret.PasswordSecrets[k] = secretToDump
 	}
-	return dumpResource(withNoSecret)
+	return withNoSecret
 }
 
 func dumpConfig(cfg *config.Config) string {
+	return spew.Sdump(sanitizeConfig(cfg))
+}
+
+func sanitizeConfig(cfg *config.Config) config.Config {
 	toDump := *cfg
 	toDump.Peers = make(map[string]*config.Peer, 0)
 	for _, p := range cfg.Peers {
@@ -38,7 +59,7 @@ func dumpConfig(cfg *config.Config) string {
 		p1.Password = "<retracted>"
 		toDump.Peers[p.Name] = &p1
 	}
-	return spew.Sdump(toDump)
+	return toDump
 }
 
 func dumpResource(i interface{}) string {
@@ -58,3 +79,136 @@ func sanitizeBGPPeer(peers ...metallbv1beta2.BGPPeer) []metallbv1beta2.BGPPeer {
 	}
 	return res
 }
+
+// AppliedConfig holds the last configuration applied and the resources it
+// was parsed from, served for troubleshooting.
+type AppliedConfig struct {
+	sync.Mutex
+	resources *config.ClusterResources
+	cfg       *config.Config
+}
+
+// appliedConfigDump is the serialized form of the applied configuration.
+type appliedConfigDump struct {
+	Config    interface{}              `json:"config"`
+	Resources *config.ClusterResources `json:"resources"`
+}
+
+func (a *AppliedConfig) set(resources config.ClusterResources, cfg *config.Config) {
+	a.Lock()
+	defer a.Unlock()
+	sanitized := sanitizeClusterResources(&resources)
+	a.resources = &sanitized
+	a.cfg = cfg
+}
+
+// ServeHTTP serves the applied configuration as JSON, or as YAML when the
+// format query parameter is yaml. The configuration is the one parsed and
+// validated from the resources, which are served along with it.
+func (a *AppliedConfig) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	a.Lock()
+	if a.cfg == nil {
+		a.Unlock()
+		http.Error(w, "no configuration applied yet", http.StatusServiceUnavailable)
+		return
+	}
+	sanitized := sanitizeConfig(a.cfg)
+	toDump := appliedConfigDump{
+		Config:    dumpable(reflect.ValueOf(sanitized)),
+		Resources: a.resources,
+	}
+	a.Unlock()
+
+	res, err := json.MarshalIndent(toDump, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	contentType := "application/json"
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+	case "yaml":
+		res, err = yaml.JSONToYAML(res)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		contentType = "application/yaml"
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %q, must be json or yaml", format), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(res)
+}
+
+var (
+	selectorType = reflect.TypeOf((*labels.Selector)(nil)).Elem()
+	ipType       = reflect.TypeOf(net.IP{})
+	ipNetType    = reflect.TypeOf(net.IPNet{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// dumpable converts the parsed configuration to values that serialize to
+// readable JSON: the selectors, the IPs, the CIDRs and the durations are
+// rendered as strings, and the unexported fields are skipped.
+func dumpable(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type() == selectorType {
+			return v.Interface().(labels.Selector).String()
+		}
+		return dumpable(v.Elem())
+	}
+	switch v.Type() {
+	case ipType:
+		if v.Len() == 0 {
+			return nil
+		}
+		return v.Interface().(net.IP).String()
+	case ipNetType:
+		n := v.Interface().(net.IPNet)
+		return n.String()
+	case durationType:
+		return v.Interface().(time.Duration).String()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		res := map[string]interface{}{}
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			res[v.Type().Field(i).Name] = dumpable(v.Field(i))
+		}
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		res := map[string]interface{}{}
+		iter := v.MapRange()
+		for iter.Next() {
+			res[fmt.Sprint(iter.Key().Interface())] = dumpable(iter.Value())
+		}
+		return res
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		res := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			res[i] = dumpable(v.Index(i))
+		}
+		return res
+	case reflect.Func, reflect.Chan:
+		return nil
+	}
+	return v.Interface()
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	"go.universe.tf/metallb/internal/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestAppliedConfig(t *testing.T) {
+	applied := &AppliedConfig{}
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		applied.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/config"+query, nil))
+		return rec
+	}
+	if rec := get(""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d before the configuration is applied, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	_, cidr, _ := net.ParseCIDR("192.168.10.0/24")
+	resources := config.ClusterResources{
+		Pools: []metallbv1beta1.IPAddressPool{{ObjectMeta: metav1.ObjectMeta{Name: "pool1"}}},
+		Peers: []metallbv1beta2.BGPPeer{{
+			ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+			Spec:       metallbv1beta2.BGPPeerSpec{Password: "s3cr3t"},
+		}},
+	}
+	applied.set(resources, &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Name:          "peer1",
+				Addr:          net.ParseIP("10.0.0.1"),
+				HoldTime:      90 * time.Second,
+				Password:      "s3cr3t",
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"pool1": {Name: "pool1", CIDR: []*net.IPNet{cidr}},
+		}},
+	})
+
+	rec := get("")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "s3cr3t") {
+		t.Fatalf("expected the passwords to be retracted, got %s", rec.Body.String())
+	}
+	var got struct {
+		Config struct {
+			Peers map[string]struct {
+				Addr     string
+				HoldTime string
+				Password string
+			}
+			Pools struct {
+				ByName map[string]struct {
+					CIDR []string
+				}
+			}
+		} `json:"config"`
+		Resources struct {
+			Pools []metallbv1beta1.IPAddressPool `json:"ipaddresspools"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to parse the dump: %s", err)
+	}
+	peer := got.Config.Peers["peer1"]
+	if peer.Addr != "10.0.0.1" || peer.HoldTime != "1m30s" || peer.Password != "<retracted>" {
+		t.Fatalf("unexpected peer in the dump: %+v", peer)
+	}
+	if diff := cmp.Diff([]string{"192.168.10.0/24"}, got.Config.Pools.ByName["pool1"].CIDR); diff != "" {
+		t.Fatalf("unexpected pool cidrs in the dump (-want +got)\n%s", diff)
+	}
+	if len(got.Resources.Pools) != 1 || got.Resources.Pools[0].Name != "pool1" {
+		t.Fatalf("unexpected pools in the resources of the dump: %+v", got.Resources.Pools)
+	}
+
+	rec = get("?format=yaml")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "HoldTime: 1m30s") {
+		t.Fatalf("expected the dump as yaml, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := get("?format=xml"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d for an unknown format, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
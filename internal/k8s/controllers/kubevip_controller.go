@@ -0,0 +1,183 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// ImportedFromLabel marks the IPAddressPools materialized from an
+	// external source, with the source as value. Only the pools carrying
+	// it are updated and deleted by the importers.
+	ImportedFromLabel = "metallb.universe.tf/imported-from"
+	kubeVIPSource     = "kube-vip"
+	kubeVIPPoolPrefix = "kubevip-"
+	kubeVIPGlobal     = "global"
+)
+
+// KubeVIPImportReconciler materializes the address ranges of the kube-vip
+// cloud provider ConfigMap as IPAddressPools, keeping them in sync with it.
+// The cidr-<namespace> and range-<namespace> entries of the ConfigMap
+// become the kubevip-<namespace> pool, restricted to the namespace, and the
+// cidr-global and range-global ones the kubevip-global pool.
+type KubeVIPImportReconciler struct {
+	client.Client
+	Logger    log.Logger
+	Scheme    *runtime.Scheme
+	Namespace string
+	// Source is the kube-vip ConfigMap the pools are imported from.
+	Source types.NamespacedName
+}
+
+func (r *KubeVIPImportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	level.Info(r.Logger).Log("controller", "KubeVIPImportReconciler", "start reconcile", r.Source.String())
+	defer level.Info(r.Logger).Log("controller", "KubeVIPImportReconciler", "end reconcile", r.Source.String())
+
+	var cm corev1.ConfigMap
+	err := r.Get(ctx, r.Source, &cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		level.Error(r.Logger).Log("controller", "KubeVIPImportReconciler", "message", "failed to get the kube-vip configmap", "error", err)
+		return ctrl.Result{}, err
+	}
+	// A deleted ConfigMap has no data, all the imported pools are removed.
+	desired := kubeVIPPools(cm.Data, r.Namespace)
+
+	var pools metallbv1beta1.IPAddressPoolList
+	if err := r.List(ctx, &pools, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "KubeVIPImportReconciler", "message", "failed to get ipaddresspools", "error", err)
+		return ctrl.Result{}, err
+	}
+	existing := map[string]*metallbv1beta1.IPAddressPool{}
+	for i := range pools.Items {
+		existing[pools.Items[i].Name] = &pools.Items[i]
+	}
+
+	for _, want := range desired {
+		current, ok := existing[want.Name]
+		if !ok {
+			if err := r.Create(ctx, want); err != nil {
+				level.Error(r.Logger).Log("controller", "KubeVIPImportReconciler", "ipaddresspool", want.Name, "message", "failed to create the imported pool", "error", err)
+				return ctrl.Result{}, err
+			}
+			level.Info(r.Logger).Log("controller", "KubeVIPImportReconciler", "ipaddresspool", want.Name, "addresses", strings.Join(want.Spec.Addresses, ","), "event", "imported pool created")
+			continue
+		}
+		if current.Labels[ImportedFromLabel] != kubeVIPSource {
+			level.Warn(r.Logger).Log("controller", "KubeVIPImportReconciler", "ipaddresspool", want.Name, "message", "a pool not imported from kube-vip has the same name, skipping it")
+			continue
+		}
+		if reflect.DeepEqual(current.Spec, want.Spec) {
+			continue
+		}
+		current.Spec = want.Spec
+		if err := r.Update(ctx, current); err != nil {
+			level.Error(r.Logger).Log("controller", "KubeVIPImportReconciler", "ipaddresspool", want.Name, "message", "failed to update the imported pool", "error", err)
+			return ctrl.Result{}, err
+		}
+		level.Info(r.Logger).Log("controller", "KubeVIPImportReconciler", "ipaddresspool", want.Name, "addresses", strings.Join(want.Spec.Addresses, ","), "event", "imported pool updated")
+	}
+
+	for name, current := range existing {
+		if current.Labels[ImportedFromLabel] != kubeVIPSource {
+			continue
+		}
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		if err := r.Delete(ctx, current); err != nil && !apierrors.IsNotFound(err) {
+			level.Error(r.Logger).Log("controller", "KubeVIPImportReconciler", "ipaddresspool", name, "message", "failed to delete the imported pool", "error", err)
+			return ctrl.Result{}, err
+		}
+		level.Info(r.Logger).Log("controller", "KubeVIPImportReconciler", "ipaddresspool", name, "event", "imported pool deleted")
+	}
+	return ctrl.Result{}, nil
+}
+
+// kubeVIPPools returns the IPAddressPools matching the entries of the
+// kube-vip ConfigMap, keyed by name. The entries not about addresses, such
+// as allow-share-<namespace>, are ignored.
+func kubeVIPPools(data map[string]string, namespace string) map[string]*metallbv1beta1.IPAddressPool {
+	addresses := map[string][]string{} // kube-vip namespace -> addresses
+	for key, value := range data {
+		var scope string
+		switch {
+		case strings.HasPrefix(key, "cidr-"):
+			scope = strings.TrimPrefix(key, "cidr-")
+		case strings.HasPrefix(key, "range-"):
+			scope = strings.TrimPrefix(key, "range-")
+		default:
+			continue
+		}
+		if scope == "" {
+			continue
+		}
+		for _, a := range strings.Split(value, ",") {
+			a = strings.TrimSpace(a)
+			if a == "" {
+				continue
+			}
+			addresses[scope] = append(addresses[scope], a)
+		}
+	}
+
+	res := map[string]*metallbv1beta1.IPAddressPool{}
+	for scope, addrs := range addresses {
+		sort.Strings(addrs)
+		pool := &metallbv1beta1.IPAddressPool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      kubeVIPPoolPrefix + scope,
+				Namespace: namespace,
+				Labels:    map[string]string{ImportedFromLabel: kubeVIPSource},
+			},
+			Spec: metallbv1beta1.IPAddressPoolSpec{
+				Addresses: addrs,
+			},
+		}
+		if scope != kubeVIPGlobal {
+			pool.Spec.AllocateTo = &metallbv1beta1.ServiceAllocation{
+				Namespaces: []string{scope},
+			}
+		}
+		res[pool.Name] = pool
+	}
+	return res
+}
+
+func (r *KubeVIPImportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// All the changes are coalesced into a single request for the source,
+	// the imported pools being restored if edited or deleted by hand.
+	enqueueSource := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: r.Source}}
+	})
+	isSource := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == r.Source.Namespace && obj.GetName() == r.Source.Name
+	})
+	isImported := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetLabels()[ImportedFromLabel] == kubeVIPSource
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("kubevipimport").
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, enqueueSource, builder.WithPredicates(isSource)).
+		Watches(&source.Kind{Type: &metallbv1beta1.IPAddressPool{}}, enqueueSource, builder.WithPredicates(isImported)).
+		Complete(r)
+}
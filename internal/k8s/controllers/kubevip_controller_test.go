@@ -0,0 +1,107 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestKubeVIPImportReconciler(t *testing.T) {
+	source := types.NamespacedName{Namespace: "kube-system", Name: "kubevip"}
+	imported := func(name string, allocateTo []string, addresses ...string) *metallbv1beta1.IPAddressPool {
+		pool := &metallbv1beta1.IPAddressPool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: testNamespace,
+				Labels:    map[string]string{ImportedFromLabel: kubeVIPSource},
+			},
+			Spec: metallbv1beta1.IPAddressPoolSpec{Addresses: addresses},
+		}
+		if allocateTo != nil {
+			pool.Spec.AllocateTo = &metallbv1beta1.ServiceAllocation{Namespaces: allocateTo}
+		}
+		return pool
+	}
+
+	initObjects := []client.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: source.Name, Namespace: source.Namespace},
+			Data: map[string]string{
+				"cidr-global":        "192.168.0.0/29",
+				"range-global":       "192.168.1.10-192.168.1.20, 192.168.1.30-192.168.1.40",
+				"cidr-team-a":        "10.0.0.0/28",
+				"allow-share-team-a": "true",
+			},
+		},
+		// Stale, the team-b entries were removed from the ConfigMap.
+		imported("kubevip-team-b", []string{"team-b"}, "10.1.0.0/28"),
+		// Edited by hand, restored to the ConfigMap content.
+		imported("kubevip-team-a", []string{"team-a"}, "10.9.0.0/28"),
+		// Not imported, left untouched.
+		&metallbv1beta1.IPAddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "manual", Namespace: testNamespace},
+			Spec:       metallbv1beta1.IPAddressPoolSpec{Addresses: []string{"172.16.0.0/24"}},
+		},
+	}
+	fakeClient, err := newFakeClient(initObjects)
+	if err != nil {
+		t.Fatalf("test failed to create fake client: %v", err)
+	}
+
+	r := &KubeVIPImportReconciler{
+		Client:    fakeClient,
+		Logger:    log.NewNopLogger(),
+		Scheme:    scheme,
+		Namespace: testNamespace,
+		Source:    source,
+	}
+	_, err = r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: source})
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	var pools metallbv1beta1.IPAddressPoolList
+	if err := fakeClient.List(context.TODO(), &pools, client.InNamespace(testNamespace)); err != nil {
+		t.Fatalf("failed to list the pools: %v", err)
+	}
+	got := map[string]metallbv1beta1.IPAddressPoolSpec{}
+	for _, p := range pools.Items {
+		got[p.Name] = p.Spec
+	}
+	expected := map[string]metallbv1beta1.IPAddressPoolSpec{
+		"kubevip-global": {Addresses: []string{"192.168.0.0/29", "192.168.1.10-192.168.1.20", "192.168.1.30-192.168.1.40"}},
+		"kubevip-team-a": {
+			Addresses:  []string{"10.0.0.0/28"},
+			AllocateTo: &metallbv1beta1.ServiceAllocation{Namespaces: []string{"team-a"}},
+		},
+		"manual": {Addresses: []string{"172.16.0.0/24"}},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatalf("unexpected pools (-want +got):\n%s", diff)
+	}
+
+	// Deleting the ConfigMap removes all the imported pools.
+	if err := fakeClient.Delete(context.TODO(), initObjects[0]); err != nil {
+		t.Fatalf("failed to delete the configmap: %v", err)
+	}
+	_, err = r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: source})
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if err := fakeClient.List(context.TODO(), &pools, client.InNamespace(testNamespace)); err != nil {
+		t.Fatalf("failed to list the pools: %v", err)
+	}
+	if len(pools.Items) != 1 || pools.Items[0].Name != "manual" {
+		t.Fatalf("expected only the manual pool to be left, got %v", pools.Items)
+	}
+}
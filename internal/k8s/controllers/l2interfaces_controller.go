@@ -0,0 +1,155 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// L2InterfacesReconciler checks the interfaces of the L2Advertisements
+// against the ones the speakers report in the SpeakerReport of their node,
+// flagging the advertisements none of the nodes they select has any
+// interface of. The advertisements are not blocked, as the interfaces
+// may appear later.
+type L2InterfacesReconciler struct {
+	client.Client
+	Logger    log.Logger
+	Scheme    *runtime.Scheme
+	Namespace string
+}
+
+func (r *L2InterfacesReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	level.Info(r.Logger).Log("controller", "L2InterfacesReconciler", "start reconcile", req.NamespacedName.String())
+	defer level.Info(r.Logger).Log("controller", "L2InterfacesReconciler", "end reconcile", req.NamespacedName.String())
+
+	var l2Advertisements metallbv1beta1.L2AdvertisementList
+	if err := r.List(ctx, &l2Advertisements, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "L2InterfacesReconciler", "message", "failed to get l2 advertisements", "error", err)
+		return ctrl.Result{}, err
+	}
+
+	var nodes corev1.NodeList
+	if err := r.List(ctx, &nodes); err != nil {
+		level.Error(r.Logger).Log("controller", "L2InterfacesReconciler", "message", "failed to get nodes", "error", err)
+		return ctrl.Result{}, err
+	}
+
+	var reports metallbv1beta1.SpeakerReportList
+	if err := r.List(ctx, &reports, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "L2InterfacesReconciler", "message", "failed to get speaker reports", "error", err)
+		return ctrl.Result{}, err
+	}
+	interfaces := map[string][]string{}
+	for _, report := range reports.Items {
+		if report.Status.Interfaces != nil {
+			interfaces[report.Status.Node] = report.Status.Interfaces
+		}
+	}
+
+	l2NoMatchingInterface.Reset()
+	for _, adv := range l2Advertisements.Items {
+		missing, err := noMatchingInterface(adv, nodes.Items, interfaces)
+		if err != nil {
+			level.Error(r.Logger).Log("controller", "L2InterfacesReconciler", "l2advertisement", adv.Name, "error", err, "message", "failed to check the interfaces")
+			continue
+		}
+		if !missing {
+			l2NoMatchingInterface.WithLabelValues(adv.Name).Set(0)
+			continue
+		}
+		level.Warn(r.Logger).Log("controller", "L2InterfacesReconciler", "l2advertisement", adv.Name, "interfaces", strings.Join(adv.Spec.Interfaces, ","), "message", "none of the nodes selected by the l2 advertisement has any of its interfaces")
+		l2NoMatchingInterface.WithLabelValues(adv.Name).Set(1)
+	}
+	return ctrl.Result{}, nil
+}
+
+// noMatchingInterface tells if none of the nodes selected by the
+// advertisement reports any of its interfaces, given the interfaces
+// reported by node. The nodes not reporting their interfaces are not
+// considered, and an advertisement not selecting any node reporting them
+// is not flagged.
+func noMatchingInterface(adv metallbv1beta1.L2Advertisement, nodes []corev1.Node, interfaces map[string][]string) (bool, error) {
+	if len(adv.Spec.Interfaces) == 0 {
+		return false, nil
+	}
+	selectors := []labels.Selector{}
+	for _, s := range adv.Spec.NodeSelectors {
+		s := s // so we can use &s
+		selector, err := metav1.LabelSelectorAsSelector(&s)
+		if err != nil {
+			return false, err
+		}
+		selectors = append(selectors, selector)
+	}
+
+	reported := false
+	for _, node := range nodes {
+		if !nodeSelected(node, selectors) {
+			continue
+		}
+		inventory, ok := interfaces[node.Name]
+		if !ok {
+			continue
+		}
+		reported = true
+		for _, iface := range inventory {
+			for _, want := range adv.Spec.Interfaces {
+				if iface == want {
+					return false, nil
+				}
+			}
+		}
+	}
+	return reported, nil
+}
+
+func nodeSelected(node corev1.Node, selectors []labels.Selector) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	for _, s := range selectors {
+		if s.Matches(labels.Set(node.Labels)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *L2InterfacesReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	p := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			switch newObj := e.ObjectNew.(type) {
+			case *corev1.Node:
+				oldObj, ok := e.ObjectOld.(*corev1.Node)
+				return !ok || !labels.Equals(labels.Set(oldObj.Labels), labels.Set(newObj.Labels))
+			case *metallbv1beta1.SpeakerReport:
+				oldObj, ok := e.ObjectOld.(*metallbv1beta1.SpeakerReport)
+				return !ok || !reflect.DeepEqual(oldObj.Status.Interfaces, newObj.Status.Interfaces)
+			}
+			return true
+		},
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("l2interfaces").
+		For(&metallbv1beta1.L2Advertisement{}).
+		Watches(&source.Kind{Type: &corev1.Node{}}, &handler.EnqueueRequestForObject{}).
+		Watches(&source.Kind{Type: &metallbv1beta1.SpeakerReport{}}, &handler.EnqueueRequestForObject{}).
+		WithEventFilter(p).
+		Complete(r)
+}
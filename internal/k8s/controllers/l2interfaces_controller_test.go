@@ -0,0 +1,117 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1beta1 "go.universe.tf/metallb/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestL2InterfacesReconciler(t *testing.T) {
+	node := func(name, zone string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"zone": zone},
+			},
+		}
+	}
+	report := func(node string, interfaces ...string) *v1beta1.SpeakerReport {
+		return &v1beta1.SpeakerReport{
+			ObjectMeta: metav1.ObjectMeta{Name: node, Namespace: testNamespace},
+			Status: v1beta1.SpeakerReportStatus{
+				Node:       node,
+				Interfaces: interfaces,
+			},
+		}
+	}
+	adv := func(name string, interfaces []string, zone string) *v1beta1.L2Advertisement {
+		a := &v1beta1.L2Advertisement{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Spec:       v1beta1.L2AdvertisementSpec{Interfaces: interfaces},
+		}
+		if zone != "" {
+			a.Spec.NodeSelectors = []metav1.LabelSelector{{MatchLabels: map[string]string{"zone": zone}}}
+		}
+		return a
+	}
+
+	initObjects := []client.Object{
+		node("nodeA", "a"),
+		report("nodeA", "eth0", "eth1", "lo"),
+		node("nodeB", "b"),
+		report("nodeB", "bond0", "lo"),
+		// Not reporting its interfaces.
+		node("nodeC", "c"),
+		adv("all-interfaces", nil, ""),
+		adv("existing", []string{"eth1"}, ""),
+		adv("existing-elsewhere", []string{"bond0"}, "a"),
+		adv("missing", []string{"eth2", "bond1"}, ""),
+		adv("unreported", []string{"eth2"}, "c"),
+	}
+	fakeClient, err := newFakeClient(initObjects)
+	if err != nil {
+		t.Fatalf("test failed to create fake client: %v", err)
+	}
+
+	r := &L2InterfacesReconciler{
+		Client:    fakeClient,
+		Logger:    log.NewNopLogger(),
+		Scheme:    scheme,
+		Namespace: testNamespace,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+	_, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	expected := map[string]float64{
+		"all-interfaces":     0,
+		"existing":           0,
+		"existing-elsewhere": 1,
+		"missing":            1,
+		"unreported":         0,
+	}
+	for name, want := range expected {
+		if got := testutil.ToFloat64(l2NoMatchingInterface.WithLabelValues(name)); got != want {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+		}
+	}
+
+	// The metric follows the interfaces reported by the nodes.
+	var n v1beta1.SpeakerReport
+	if err := fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "nodeB"}, &n); err != nil {
+		t.Fatalf("get failed on nodeB: %v", err)
+	}
+	n.Status.Interfaces = []string{"bond0", "eth2", "lo"}
+	if err := fakeClient.Update(context.TODO(), &n); err != nil {
+		t.Fatalf("update failed on nodeB: %v", err)
+	}
+	if err := fakeClient.Delete(context.TODO(), adv("existing", nil, "")); err != nil {
+		t.Fatalf("delete failed on existing: %v", err)
+	}
+	_, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if got := testutil.ToFloat64(l2NoMatchingInterface.WithLabelValues("missing")); got != 0 {
+		t.Errorf("missing: expected 0 after nodeB reported eth2, got %v", got)
+	}
+	if got := testutil.CollectAndCount(l2NoMatchingInterface); got != 4 {
+		t.Errorf("expected the metric of 4 advertisements after deleting one, got %d", got)
+	}
+}
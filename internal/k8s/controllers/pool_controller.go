@@ -18,21 +18,29 @@ package controllers
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/pointer"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// poolDeletionRetryInterval is how often the pools waiting for their IPs to
+// be released before being deleted are checked.
+var poolDeletionRetryInterval = 10 * time.Second
+
 type PoolReconciler struct {
 	client.Client
 	Logger         log.Logger
@@ -41,6 +49,9 @@ type PoolReconciler struct {
 	Handler        func(log.Logger, *config.Pools) SyncState
 	ValidateConfig config.Validate
 	ForceReload    func()
+	// ConfigFile, when set, is the file the pools are read from instead
+	// of the cluster.
+	ConfigFile string
 }
 
 func (r *PoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -48,22 +59,26 @@ func (r *PoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	defer level.Info(r.Logger).Log("controller", "PoolReconciler", "end reconcile", req.NamespacedName.String())
 	updates.Inc()
 
-	var addressPools metallbv1beta1.AddressPoolList
-	if err := r.List(ctx, &addressPools, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get addresspools", "error", err)
-		return ctrl.Result{}, err
-	}
-
-	var ipAddressPools metallbv1beta1.IPAddressPoolList
-	if err := r.List(ctx, &ipAddressPools, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get ipaddresspools", "error", err)
-		return ctrl.Result{}, err
-	}
-
-	var communities metallbv1beta1.CommunityList
-	if err := r.List(ctx, &communities, client.InNamespace(r.Namespace)); err != nil {
-		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get communities", "error", err)
-		return ctrl.Result{}, err
+	var resources config.ClusterResources
+	result := ctrl.Result{}
+	if r.ConfigFile != "" {
+		fileResources, err := config.ResourcesFromFile(r.ConfigFile)
+		if err != nil {
+			configStale.Set(1)
+			level.Error(r.Logger).Log("controller", "PoolReconciler", "error", "failed to read the configuration file", "file", r.ConfigFile, "error", err)
+			return ctrl.Result{}, nil
+		}
+		resources = config.ClusterResources{
+			Pools:              fileResources.Pools,
+			LegacyAddressPools: fileResources.LegacyAddressPools,
+			Communities:        fileResources.Communities,
+		}
+	} else {
+		var err error
+		resources, result, err = r.listPools(ctx)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
 	var namespaces corev1.NamespaceList
@@ -72,12 +87,7 @@ func (r *PoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{}, err
 	}
 
-	resources := config.ClusterResources{
-		Pools:              ipAddressPools.Items,
-		LegacyAddressPools: addressPools.Items,
-		Communities:        communities.Items,
-		Namespaces:         namespaces.Items,
-	}
+	resources.Namespaces = namespaces.Items
 
 	level.Debug(r.Logger).Log("controller", "PoolReconciler", "metallb CRs", dumpClusterResources(&resources))
 
@@ -85,7 +95,7 @@ func (r *PoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	if err != nil {
 		configStale.Set(1)
 		level.Error(r.Logger).Log("controller", "PoolReconciler", "error", "failed to parse the configuration", "error", err)
-		return ctrl.Result{}, nil
+		return result, nil
 	}
 
 	level.Debug(r.Logger).Log("controller", "PoolReconciler", "rendered config", dumpConfig(cfg))
@@ -104,21 +114,116 @@ func (r *PoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		updateErrors.Inc()
 		configStale.Set(1)
 		level.Error(r.Logger).Log("controller", "PoolReconciler", "metallb CRs and Secrets", dumpClusterResources(&resources), "event", "reload failed, no retry")
-		return ctrl.Result{}, nil
+		return result, nil
 	}
 
 	configLoaded.Set(1)
 	configStale.Set(0)
 	level.Info(r.Logger).Log("controller", "PoolReconciler", "event", "config reloaded")
-	return ctrl.Result{}, nil
+	return result, nil
+}
+
+// listPools lists the pools and the communities from the cluster, releasing
+// the pools being deleted when possible. The returned result requeues the
+// reconciliation while some pools are waiting to be deleted.
+func (r *PoolReconciler) listPools(ctx context.Context) (config.ClusterResources, ctrl.Result, error) {
+	var addressPools metallbv1beta1.AddressPoolList
+	if err := r.List(ctx, &addressPools, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get addresspools", "error", err)
+		return config.ClusterResources{}, ctrl.Result{}, err
+	}
+
+	var ipAddressPools metallbv1beta1.IPAddressPoolList
+	if err := r.List(ctx, &ipAddressPools, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get ipaddresspools", "error", err)
+		return config.ClusterResources{}, ctrl.Result{}, err
+	}
+
+	var communities metallbv1beta1.CommunityList
+	if err := r.List(ctx, &communities, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get communities", "error", err)
+		return config.ClusterResources{}, ctrl.Result{}, err
+	}
+
+	waitingDeletion, err := r.releaseDeletedPools(ctx, ipAddressPools.Items)
+	if err != nil {
+		return config.ClusterResources{}, ctrl.Result{}, err
+	}
+	result := ctrl.Result{}
+	if waitingDeletion {
+		result.RequeueAfter = poolDeletionRetryInterval
+	}
+
+	return config.ClusterResources{
+		Pools:              ipAddressPools.Items,
+		LegacyAddressPools: addressPools.Items,
+		Communities:        communities.Items,
+	}, result, nil
+}
+
+// releaseDeletedPools removes the protection finalizer from the pools being
+// deleted once none of their IPs is assigned to a service anymore, or when
+// the deletion is forced. The pools still waiting are kept in the
+// configuration so the services don't lose their IPs, but are not used for
+// automatic allocation anymore. It returns true if any pool is still waiting.
+func (r *PoolReconciler) releaseDeletedPools(ctx context.Context, pools []metallbv1beta1.IPAddressPool) (bool, error) {
+	var services corev1.ServiceList
+	servicesListed := false
+	waiting := false
+	for i := range pools {
+		pool := &pools[i]
+		if pool.DeletionTimestamp.IsZero() || !controllerutil.ContainsFinalizer(pool, metallbv1beta1.PoolProtectionFinalizer) {
+			continue
+		}
+
+		if !servicesListed {
+			if err := r.List(ctx, &services); err != nil {
+				level.Error(r.Logger).Log("controller", "PoolReconciler", "message", "failed to get services", "error", err)
+				return false, err
+			}
+			servicesListed = true
+		}
+		inUse, err := metallbv1beta1.ServicesWithIPsFromPool(pool, services.Items)
+		if err != nil {
+			level.Error(r.Logger).Log("controller", "PoolReconciler", "pool", pool.Name, "error", err, "message", "failed to check the services using the pool")
+			return false, err
+		}
+		forced := pool.Annotations[metallbv1beta1.ForceDeletionAnnotation] == "true"
+		if len(inUse) > 0 && !forced {
+			level.Info(r.Logger).Log("controller", "PoolReconciler", "pool", pool.Name, "services", strings.Join(inUse, ","), "event", "pool deletion waiting for the services to release its IPs")
+			pool.Spec.AutoAssign = pointer.BoolPtr(false)
+			waiting = true
+			continue
+		}
+
+		controllerutil.RemoveFinalizer(pool, metallbv1beta1.PoolProtectionFinalizer)
+		if err := r.Update(ctx, pool); err != nil {
+			level.Error(r.Logger).Log("controller", "PoolReconciler", "pool", pool.Name, "error", err, "message", "failed to remove the pool protection finalizer")
+			return false, err
+		}
+		level.Info(r.Logger).Log("controller", "PoolReconciler", "pool", pool.Name, "forced", forced, "event", "pool protection finalizer removed")
+	}
+	return waiting, nil
 }
 
 func (r *PoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	p := predicate.Funcs{
 		UpdateFunc: func(e event.UpdateEvent) bool {
-			return filterNodeEvent(e) && filterNamespaceEvent(e)
+			return filterNodeEvent(e) && filterNamespaceEvent(e) && filterPoolStatusEvent(e)
 		},
 	}
+	if r.ConfigFile != "" {
+		fileSource, err := configFileSource(mgr, r.Logger, r.ConfigFile)
+		if err != nil {
+			return err
+		}
+		return ctrl.NewControllerManagedBy(mgr).
+			Named("poolfile").
+			Watches(fileSource, &handler.EnqueueRequestForObject{}).
+			Watches(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestForObject{}).
+			WithEventFilter(p).
+			Complete(r)
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&metallbv1beta1.IPAddressPool{}).
 		Watches(&source.Kind{Type: &metallbv1beta1.AddressPool{}}, &handler.EnqueueRequestForObject{}).
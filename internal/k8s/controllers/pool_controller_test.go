@@ -27,8 +27,12 @@ import (
 	"go.universe.tf/metallb/internal/config"
 	metallbcfg "go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/pointer"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -135,6 +139,92 @@ func TestPoolController(t *testing.T) {
 	}
 }
 
+func TestPoolControllerProtectedDeletion(t *testing.T) {
+	now := v1.Now()
+	pool := v1beta1.IPAddressPool{
+		ObjectMeta: v1.ObjectMeta{
+			Name:              "pool1",
+			Namespace:         testNamespace,
+			DeletionTimestamp: &now,
+			Finalizers:        []string{v1beta1.PoolProtectionFinalizer},
+		},
+		Spec: v1beta1.IPAddressPoolSpec{
+			Addresses: []string{"10.20.0.0/16"},
+		},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{Name: "svc1", Namespace: "default"},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "10.20.0.1"}},
+			},
+		},
+	}
+	fakeClient, err := newFakeClient([]client.Object{pool.DeepCopy(), svc})
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+
+	var gotPools *config.Pools
+	r := &PoolReconciler{
+		Client:         fakeClient,
+		Logger:         log.NewNopLogger(),
+		Scheme:         scheme,
+		Namespace:      testNamespace,
+		ValidateConfig: config.DontValidate,
+		Handler: func(l log.Logger, pools *config.Pools) SyncState {
+			gotPools = pools
+			return SyncStateSuccess
+		},
+		ForceReload: func() {},
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: testNamespace,
+		},
+	}
+
+	// While a service has an IP from the pool, the deletion waits and the
+	// pool is not used for automatic allocation anymore.
+	res, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if res.RequeueAfter == 0 {
+		t.Fatalf("expected the reconcile to be requeued while the pool is in use")
+	}
+	if gotPools == nil || gotPools.ByName["pool1"] == nil || gotPools.ByName["pool1"].AutoAssign {
+		t.Fatalf("expected the pool to be kept without automatic allocation, got %v", gotPools)
+	}
+	var got v1beta1.IPAddressPool
+	if err := fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "pool1"}, &got); err != nil {
+		t.Fatalf("failed to get the pool: %v", err)
+	}
+	if !controllerutil.ContainsFinalizer(&got, v1beta1.PoolProtectionFinalizer) {
+		t.Fatalf("expected the finalizer to be kept while the pool is in use")
+	}
+
+	// Once the IP is released, the finalizer is removed.
+	svc.Status = corev1.ServiceStatus{}
+	if err := fakeClient.Status().Update(context.TODO(), svc); err != nil {
+		t.Fatalf("failed to update the service: %v", err)
+	}
+	res, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if res.RequeueAfter != 0 {
+		t.Fatalf("expected the reconcile not to be requeued once the pool is released")
+	}
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "pool1"}, &got)
+	if err == nil && controllerutil.ContainsFinalizer(&got, v1beta1.PoolProtectionFinalizer) {
+		t.Fatalf("expected the finalizer to be removed once the pool is released")
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		t.Fatalf("failed to get the pool: %v", err)
+	}
+}
+
 var (
 	poolControllerValidResources = metallbcfg.ClusterResources{
 		Pools: []v1beta1.IPAddressPool{
@@ -18,6 +18,8 @@ package controllers
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -46,16 +48,61 @@ type ServiceReconciler struct {
 	Handler           func(log.Logger, string, *v1.Service, epslices.EpsOrSlices) SyncState
 	Endpoints         NeedEndPoints
 	LoadBalancerClass string
-	Reload            chan event.GenericEvent
+	// ClaimClassless makes the services with no loadBalancerClass
+	// handled too when LoadBalancerClass is set.
+	ClaimClassless bool
+	Reload         chan event.GenericEvent
+	// DebounceWindow, when set, makes the changes to a service received
+	// within the window coalesce into a single call to the handler, made
+	// at the end of the window with the latest state of the service.
+	DebounceWindow time.Duration
+	// Priority, when set, orders the services when all of them are
+	// reprocessed, the ones with a higher priority being handled first.
+	Priority func(*v1.Service) int
+	// Reprocessed, when set, is called each time all the services were
+	// reprocessed without errors.
+	Reprocessed func()
+
+	debounceLock sync.Mutex
+	deadlines    map[types.NamespacedName]time.Time
 }
 
 func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	if !isReloadReq(req) {
+		if wait := r.debounce(req.NamespacedName); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
 		return r.reconcileService(ctx, req)
 	}
 	return r.reprocessAll(ctx, req)
 }
 
+// debounce returns how long the reconciliation of the given service must be
+// deferred. The first event for a service starts the window, the service is
+// processed once the window has elapsed.
+func (r *ServiceReconciler) debounce(name types.NamespacedName) time.Duration {
+	if r.DebounceWindow <= 0 {
+		return 0
+	}
+	r.debounceLock.Lock()
+	defer r.debounceLock.Unlock()
+	if r.deadlines == nil {
+		r.deadlines = map[types.NamespacedName]time.Time{}
+	}
+
+	now := time.Now()
+	deadline, ok := r.deadlines[name]
+	if !ok {
+		r.deadlines[name] = now.Add(r.DebounceWindow)
+		return r.DebounceWindow
+	}
+	if now.Before(deadline) {
+		return deadline.Sub(now)
+	}
+	delete(r.deadlines, name)
+	return 0
+}
+
 func (r *ServiceReconciler) reconcileService(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	level.Info(r.Logger).Log("controller", "ServiceReconciler", "start reconcile", req.NamespacedName.String())
 	defer level.Info(r.Logger).Log("controller", "ServiceReconciler", "end reconcile", req.NamespacedName.String())
@@ -69,7 +116,7 @@ func (r *ServiceReconciler) reconcileService(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{}, err
 	}
 
-	if filterByLoadBalancerClass(service, r.LoadBalancerClass) {
+	if filterByLoadBalancerClass(service, r.LoadBalancerClass, r.ClaimClassless) {
 		level.Debug(r.Logger).Log("controller", "ServiceReconciler", "filtered service", req.NamespacedName)
 		return ctrl.Result{}, nil
 	}
@@ -161,7 +208,7 @@ func (r *ServiceReconciler) serviceFor(ctx context.Context, name types.Namespace
 	return &res, nil
 }
 
-func filterByLoadBalancerClass(service *v1.Service, loadBalancerClass string) bool {
+func filterByLoadBalancerClass(service *v1.Service, loadBalancerClass string, claimClassless bool) bool {
 	// When receiving a delete, we can't make logic on the service so we
 	// rely on the application logic that will receive a delete on a service it
 	// did not handle and discard it.
@@ -169,7 +216,7 @@ func filterByLoadBalancerClass(service *v1.Service, loadBalancerClass string) bo
 		return false
 	}
 	if service.Spec.LoadBalancerClass == nil && loadBalancerClass != "" {
-		return true
+		return !claimClassless
 	}
 	if service.Spec.LoadBalancerClass == nil && loadBalancerClass == "" {
 		return false
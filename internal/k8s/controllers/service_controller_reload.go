@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"sort"
 
 	"github.com/go-kit/log/level"
 
@@ -67,10 +68,14 @@ func (r *ServiceReconciler) reprocessAll(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
+	if r.Priority != nil {
+		sortByPriority(services.Items, r.Priority)
+	}
+
 	retry := false
 	for _, service := range services.Items {
 		service := service // so we can use &service
-		if filterByLoadBalancerClass(&service, r.LoadBalancerClass) {
+		if filterByLoadBalancerClass(&service, r.LoadBalancerClass, r.ClaimClassless) {
 			level.Debug(r.Logger).Log("controller", "ServiceReconciler", "filtered service", req.NamespacedName)
 			continue
 		}
@@ -101,9 +106,29 @@ func (r *ServiceReconciler) reprocessAll(ctx context.Context, req ctrl.Request)
 		level.Info(r.Logger).Log("controller", "ServiceReconciler - reprocessAll", "event", "force service reload")
 		return ctrl.Result{}, retryError
 	}
+	if r.Reprocessed != nil {
+		r.Reprocessed()
+	}
 	return ctrl.Result{}, nil
 }
 
+// sortByPriority sorts the services by decreasing priority, and then by
+// namespace and name.
+func sortByPriority(services []v1.Service, priority func(*v1.Service) int) {
+	priorities := make(map[types.NamespacedName]int, len(services))
+	for i := range services {
+		priorities[types.NamespacedName{Namespace: services[i].Namespace, Name: services[i].Name}] = priority(&services[i])
+	}
+	sort.SliceStable(services, func(i, j int) bool {
+		ki := types.NamespacedName{Namespace: services[i].Namespace, Name: services[i].Name}
+		kj := types.NamespacedName{Namespace: services[j].Namespace, Name: services[j].Name}
+		if priorities[ki] != priorities[kj] {
+			return priorities[ki] > priorities[kj]
+		}
+		return ki.String() < kj.String()
+	})
+}
+
 func (r *ServiceReconciler) forceReload() {
 	r.Reload <- NewReloadEvent()
 }
@@ -206,14 +206,16 @@ func TestServiceController(t *testing.T) {
 
 		mockReload := make(chan event.GenericEvent, 1)
 
+		reprocessed := false
 		r := &ServiceReconciler{
-			Client:    fakeClient,
-			Logger:    log.NewNopLogger(),
-			Scheme:    scheme,
-			Namespace: testNamespace,
-			Handler:   mockHandler,
-			Endpoints: test.needEndPoints,
-			Reload:    mockReload,
+			Client:      fakeClient,
+			Logger:      log.NewNopLogger(),
+			Scheme:      scheme,
+			Namespace:   testNamespace,
+			Handler:     mockHandler,
+			Endpoints:   test.needEndPoints,
+			Reload:      mockReload,
+			Reprocessed: func() { reprocessed = true },
 		}
 
 		var req reconcile.Request
@@ -243,6 +245,11 @@ func TestServiceController(t *testing.T) {
 			t.Errorf("test %s failed: fail reconcile expected: %v, got: %v. err: %v",
 				test.desc, test.expectReconcileFails, failedReconcile, err)
 		}
+		// All the services reprocessed without errors are reported.
+		if wantReprocessed := test.shouldReprocessAll && !test.expectReconcileFails; wantReprocessed != reprocessed {
+			t.Errorf("test %s failed: reprocessed reported expected: %v, got: %v",
+				test.desc, wantReprocessed, reprocessed)
+		}
 
 		select {
 		case <-ctx.Done():
@@ -263,6 +270,7 @@ func TestLBClass(t *testing.T) {
 		desc           string
 		serviceLBClass *string
 		metallLBClass  string
+		claimClassless bool
 		shouldFilter   bool
 	}{
 		{
@@ -295,6 +303,20 @@ func TestLBClass(t *testing.T) {
 			metallLBClass:  "foo",
 			shouldFilter:   false,
 		},
+		{
+			desc:           "Empty serviceclass, metallb specific claiming classless",
+			serviceLBClass: nil,
+			metallLBClass:  "foo",
+			claimClassless: true,
+			shouldFilter:   false,
+		},
+		{
+			desc:           "Set serviceclass, metallb different claiming classless",
+			serviceLBClass: pointer.StrPtr("foo"),
+			metallLBClass:  "bar",
+			claimClassless: true,
+			shouldFilter:   true,
+		},
 	}
 	for _, test := range tests {
 		svc := &corev1.Service{
@@ -302,10 +324,78 @@ func TestLBClass(t *testing.T) {
 				LoadBalancerClass: test.serviceLBClass,
 			},
 		}
-		filters := filterByLoadBalancerClass(svc, test.metallLBClass)
+		filters := filterByLoadBalancerClass(svc, test.metallLBClass, test.claimClassless)
 		if filters != test.shouldFilter {
 			t.Errorf("test %s failed: expected filter: %v, got: %v",
 				test.desc, test.shouldFilter, filters)
 		}
 	}
 }
+
+func TestServiceDebounce(t *testing.T) {
+	r := &ServiceReconciler{
+		Logger:         log.NewNopLogger(),
+		DebounceWindow: 50 * time.Millisecond,
+	}
+	name := types.NamespacedName{Namespace: testNamespace, Name: "testObject"}
+
+	// The first event, and the ones arriving while the window is open, are deferred.
+	for i := 0; i < 3; i++ {
+		wait := r.debounce(name)
+		if wait <= 0 || wait > r.DebounceWindow {
+			t.Fatalf("event %d: expected to be deferred within the window, got %s", i, wait)
+		}
+	}
+
+	time.Sleep(r.DebounceWindow)
+	if wait := r.debounce(name); wait != 0 {
+		t.Fatalf("expected the service to be processed after the window, got %s", wait)
+	}
+
+	// A new event opens a new window.
+	if wait := r.debounce(name); wait == 0 {
+		t.Fatal("expected a new event to be deferred")
+	}
+
+	other := types.NamespacedName{Namespace: testNamespace, Name: "other"}
+	if wait := r.debounce(other); wait == 0 {
+		t.Fatal("expected the window to be per service")
+	}
+
+	r.DebounceWindow = 0
+	if wait := r.debounce(name); wait != 0 {
+		t.Fatalf("expected no debouncing with an empty window, got %s", wait)
+	}
+}
+
+func TestSortByPriority(t *testing.T) {
+	service := func(namespace, name string, priority string) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   namespace,
+				Name:        name,
+				Annotations: map[string]string{"priority": priority},
+			},
+		}
+	}
+	services := []corev1.Service{
+		service("ns2", "bulk", "0"),
+		service("ns1", "bulk", "0"),
+		service("ns1", "low", "-1"),
+		service("ns1", "critical", "10"),
+		service("ns2", "important", "5"),
+	}
+	priorities := map[string]int{"-1": -1, "0": 0, "5": 5, "10": 10}
+	sortByPriority(services, func(svc *corev1.Service) int {
+		return priorities[svc.Annotations["priority"]]
+	})
+
+	got := []string{}
+	for _, svc := range services {
+		got = append(got, svc.Namespace+"/"+svc.Name)
+	}
+	want := []string{"ns1/critical", "ns2/important", "ns1/bulk", "ns2/bulk", "ns1/low"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected order (-want +got)\n%s", diff)
+	}
+}
@@ -32,6 +32,25 @@ var (
 		Name:      "config_stale_bool",
 		Help:      "1 if running on a stale configuration, because the latest config failed to load.",
 	})
+
+	l2NoMatchingInterface = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "metallb",
+		Subsystem: "l2",
+		Name:      "advertisement_no_matching_interface",
+		Help:      "1 if none of the nodes selected by the L2Advertisement reports any of its interfaces.",
+	}, []string{
+		"l2advertisement",
+	})
+
+	serviceAnnounced = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "metallb",
+		Subsystem: "service",
+		Name:      "announced",
+		Help:      "1 if at least one speaker announces the IPs of the LoadBalancer service, via BGP or L2.",
+	}, []string{
+		"namespace",
+		"name",
+	})
 )
 
 func init() {
@@ -39,4 +58,6 @@ func init() {
 	prometheus.MustRegister(updateErrors)
 	prometheus.MustRegister(configLoaded)
 	prometheus.MustRegister(configStale)
+	prometheus.MustRegister(l2NoMatchingInterface)
+	prometheus.MustRegister(serviceAnnounced)
 }
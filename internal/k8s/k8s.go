@@ -25,19 +25,24 @@ import (
 	"go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/k8s/controllers"
 	"go.universe.tf/metallb/internal/k8s/epslices"
+	"go.universe.tf/metallb/internal/metrics"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	v1 "k8s.io/api/core/v1"
 	discovery "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	policyv1beta1 "k8s.io/kubernetes/pkg/apis/policy/v1beta1"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -54,6 +59,9 @@ const (
 	caName          = "cert"
 	caOrganization  = "metallb"
 	MLSecretKeyName = "secretkey"
+	// MLSecretAdditionalKeysName is the key of the memberlist secret holding
+	// the keys, one per line, accepted in addition to the primary one.
+	MLSecretAdditionalKeysName = "secretkeys"
 )
 
 var (
@@ -90,8 +98,11 @@ type Client struct {
 	client         *kubernetes.Clientset
 	events         record.EventRecorder
 	mgr            manager.Manager
+	namespace      string
 	validateConfig config.Validate
 	ForceSync      func()
+	// configFile is the file the configuration is read from, if any.
+	configFile string
 }
 
 // Config specifies the configuration of the Kubernetes
@@ -101,6 +112,7 @@ type Config struct {
 	NodeName            string
 	MetricsHost         string
 	MetricsPort         int
+	MetricsPrefix       string
 	EnablePprof         bool
 	ReadEndpoints       bool
 	Logger              log.Logger
@@ -112,6 +124,29 @@ type Config struct {
 	CertDir             string
 	CertServiceName     string
 	LoadBalancerClass   string
+	ServiceDebounce     time.Duration
+	// ClaimClassless makes the services with no loadBalancerClass
+	// handled too when LoadBalancerClass is set.
+	ClaimClassless bool
+	// ConfigFile, when set, is the file the MetalLB resources are read
+	// from instead of the CRs of the cluster. The file is reloaded when it
+	// changes or on SIGHUP.
+	ConfigFile string
+	// CheckL2Interfaces enables flagging the L2Advertisements none of the
+	// selected nodes reports any interface of in its SpeakerReport.
+	CheckL2Interfaces bool
+	// CheckAnnounced enables telling the LoadBalancer services none of
+	// the nodes reports announcing in its SpeakerReport.
+	CheckAnnounced bool
+	// AnnotateAnnouncingNodes enables listing the nodes announcing each
+	// service in an annotation of the service, requires CheckAnnounced.
+	AnnotateAnnouncingNodes bool
+	// KubeVIPConfigMap, when set, is the kube-vip cloud provider ConfigMap
+	// the address ranges of are imported as IPAddressPools.
+	KubeVIPConfigMap types.NamespacedName
+	// Handlers are additional handlers served on the metrics endpoint,
+	// keyed by path.
+	Handlers map[string]http.Handler
 	Listener
 }
 
@@ -126,23 +161,37 @@ func New(cfg *Config) (*Client, error) {
 		Field: fields.ParseSelectorOrDie(fmt.Sprintf("metadata.namespace=%s", cfg.Namespace)),
 	}
 
+	selectors := cache.SelectorsByObject{
+		&metallbv1beta1.AddressPool{}:       namespaceSelector,
+		&metallbv1beta1.BFDProfile{}:        namespaceSelector,
+		&metallbv1beta1.BGPAdvertisement{}:  namespaceSelector,
+		&metallbv1beta1.BGPPeer{}:           namespaceSelector,
+		&metallbv1beta1.IPAddressPool{}:     namespaceSelector,
+		&metallbv1beta1.L2Advertisement{}:   namespaceSelector,
+		&metallbv1beta2.BGPPeer{}:           namespaceSelector,
+		&metallbv1beta1.Community{}:         namespaceSelector,
+		&metallbv1beta1.NodeAdvertisement{}: namespaceSelector,
+		&metallbv1beta1.SpeakerReport{}:     namespaceSelector,
+		&corev1.Secret{}:                    namespaceSelector,
+	}
+	if cfg.KubeVIPConfigMap.Name != "" {
+		// Only the kube-vip ConfigMap is cached, not all the ConfigMaps
+		// of the cluster.
+		selectors[&corev1.ConfigMap{}] = cache.ObjectSelector{
+			Field: fields.SelectorFromSet(fields.Set{
+				"metadata.namespace": cfg.KubeVIPConfigMap.Namespace,
+				"metadata.name":      cfg.KubeVIPConfigMap.Name,
+			}),
+		}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		Port:               9443, // TODO port only with controller, for webhooks
 		LeaderElection:     false,
 		MetricsBindAddress: "0", // Disable metrics endpoint of controller manager
 		NewCache: cache.BuilderWithOptions(cache.Options{
-			SelectorsByObject: map[client.Object]cache.ObjectSelector{
-				&metallbv1beta1.AddressPool{}:      namespaceSelector,
-				&metallbv1beta1.BFDProfile{}:       namespaceSelector,
-				&metallbv1beta1.BGPAdvertisement{}: namespaceSelector,
-				&metallbv1beta1.BGPPeer{}:          namespaceSelector,
-				&metallbv1beta1.IPAddressPool{}:    namespaceSelector,
-				&metallbv1beta1.L2Advertisement{}:  namespaceSelector,
-				&metallbv1beta2.BGPPeer{}:          namespaceSelector,
-				&metallbv1beta1.Community{}:        namespaceSelector,
-				&corev1.Secret{}:                   namespaceSelector,
-			},
+			SelectorsByObject: selectors,
 		}),
 	})
 	if err != nil {
@@ -166,11 +215,15 @@ func New(cfg *Config) (*Client, error) {
 		client:         clientset,
 		events:         recorder,
 		mgr:            mgr,
+		namespace:      cfg.Namespace,
 		validateConfig: cfg.ValidateConfig,
 		ForceSync:      reload,
+		configFile:     cfg.ConfigFile,
 	}
 
+	var appliedConfig *controllers.AppliedConfig
 	if cfg.ConfigChanged != nil {
+		appliedConfig = &controllers.AppliedConfig{}
 		if err = (&controllers.ConfigReconciler{
 			Client:         mgr.GetClient(),
 			Logger:         cfg.Logger,
@@ -179,6 +232,8 @@ func New(cfg *Config) (*Client, error) {
 			ValidateConfig: cfg.ValidateConfig,
 			Handler:        cfg.ConfigHandler,
 			ForceReload:    reload,
+			ConfigFile:     cfg.ConfigFile,
+			Applied:        appliedConfig,
 		}).SetupWithManager(mgr); err != nil {
 			level.Error(c.logger).Log("error", err, "unable to create controller", "config")
 			return nil, errors.Wrap(err, "failed to create config reconciler")
@@ -194,12 +249,53 @@ func New(cfg *Config) (*Client, error) {
 			ValidateConfig: cfg.ValidateConfig,
 			Handler:        cfg.PoolHandler,
 			ForceReload:    reload,
+			ConfigFile:     cfg.ConfigFile,
 		}).SetupWithManager(mgr); err != nil {
 			level.Error(c.logger).Log("error", err, "unable to create controller", "config")
 			return nil, errors.Wrap(err, "failed to create config reconciler")
 		}
 	}
 
+	if cfg.CheckL2Interfaces {
+		if err = (&controllers.L2InterfacesReconciler{
+			Client:    mgr.GetClient(),
+			Logger:    cfg.Logger,
+			Scheme:    mgr.GetScheme(),
+			Namespace: cfg.Namespace,
+		}).SetupWithManager(mgr); err != nil {
+			level.Error(c.logger).Log("error", err, "unable to create controller", "l2interfaces")
+			return nil, errors.Wrap(err, "failed to create l2 interfaces reconciler")
+		}
+	}
+
+	if cfg.CheckAnnounced {
+		if err = (&controllers.ServiceAnnouncedReconciler{
+			Client:            mgr.GetClient(),
+			Logger:            cfg.Logger,
+			Scheme:            mgr.GetScheme(),
+			Namespace:         cfg.Namespace,
+			LoadBalancerClass: cfg.LoadBalancerClass,
+			ClaimClassless:    cfg.ClaimClassless,
+			AnnotateNodes:     cfg.AnnotateAnnouncingNodes,
+		}).SetupWithManager(mgr); err != nil {
+			level.Error(c.logger).Log("error", err, "unable to create controller", "serviceannounced")
+			return nil, errors.Wrap(err, "failed to create service announced reconciler")
+		}
+	}
+
+	if cfg.KubeVIPConfigMap.Name != "" {
+		if err = (&controllers.KubeVIPImportReconciler{
+			Client:    mgr.GetClient(),
+			Logger:    cfg.Logger,
+			Scheme:    mgr.GetScheme(),
+			Namespace: cfg.Namespace,
+			Source:    cfg.KubeVIPConfigMap,
+		}).SetupWithManager(mgr); err != nil {
+			level.Error(c.logger).Log("error", err, "unable to create controller", "kubevipimport")
+			return nil, errors.Wrap(err, "failed to create kube-vip import reconciler")
+		}
+	}
+
 	if cfg.NodeChanged != nil {
 		if err = (&controllers.NodeReconciler{
 			Client:   mgr.GetClient(),
@@ -248,6 +344,15 @@ func New(cfg *Config) (*Client, error) {
 		}
 	}
 
+	var servicePriority func(*v1.Service) int
+	if cfg.ServicePriority != nil {
+		servicePriority = cfg.ServicePriorityHandler
+	}
+	var servicesReconciled func()
+	if cfg.ServicesReconciled != nil {
+		servicesReconciled = func() { cfg.ServicesReconciledHandler(cfg.Logger) }
+	}
+
 	if cfg.ServiceChanged != nil {
 		if err = (&controllers.ServiceReconciler{
 			Client:            mgr.GetClient(),
@@ -257,6 +362,10 @@ func New(cfg *Config) (*Client, error) {
 			Endpoints:         needEndpoints,
 			Reload:            reloadChan,
 			LoadBalancerClass: cfg.LoadBalancerClass,
+			ClaimClassless:    cfg.ClaimClassless,
+			DebounceWindow:    cfg.ServiceDebounce,
+			Priority:          servicePriority,
+			Reprocessed:       servicesReconciled,
 		}).SetupWithManager(mgr); err != nil {
 			level.Error(c.logger).Log("error", err, "unable to create controller", "service")
 			return nil, errors.Wrap(err, "failed to create service reconciler")
@@ -276,7 +385,13 @@ func New(cfg *Config) (*Client, error) {
 		}
 
 		mux := http.NewServeMux()
-		mux.Handle("/metrics", promhttp.Handler())
+		mux.Handle("/metrics", metricsHandler(cfg.MetricsPrefix))
+		for path, handler := range cfg.Handlers {
+			mux.Handle(path, handler)
+		}
+		if appliedConfig != nil {
+			mux.Handle("/debug/config", appliedConfig)
+		}
 
 		if cfg.EnablePprof {
 			mux.HandleFunc("/debug/pprof/", pprof.Index)
@@ -381,6 +496,43 @@ func (c *Client) PodIPs(namespace, labels string) ([]string, error) {
 	return iplist, nil
 }
 
+// UpdateSpeakerReport sets the status of the SpeakerReport of the given
+// node with update, creating the report if missing. The report is owned by
+// the node, so that it is deleted along with it.
+func (c *Client) UpdateSpeakerReport(node string, update func(*metallbv1beta1.SpeakerReportStatus)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var report metallbv1beta1.SpeakerReport
+		err := c.mgr.GetAPIReader().Get(context.TODO(), types.NamespacedName{Namespace: c.namespace, Name: node}, &report)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			update(&report.Status)
+			return c.mgr.GetClient().Update(context.TODO(), &report)
+		}
+
+		n, err := c.client.CoreV1().Nodes().Get(context.TODO(), node, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		report = metallbv1beta1.SpeakerReport{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: c.namespace,
+				Name:      node,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "v1",
+					Kind:       "Node",
+					Name:       n.Name,
+					UID:        n.UID,
+				}},
+			},
+			Status: metallbv1beta1.SpeakerReportStatus{Node: node},
+		}
+		update(&report.Status)
+		return c.mgr.GetClient().Create(context.TODO(), &report)
+	})
+}
+
 // Run watches for events on the Kubernetes cluster, and dispatches
 // calls to the Controller.
 func (c *Client) Run(stopCh <-chan struct{}) error {
@@ -401,6 +553,28 @@ func (c *Client) UpdateStatus(svc *v1.Service) error {
 	return err
 }
 
+// UpdatePoolStatus writes the given status into the IPAddressPool with the
+// given name. Pools not backed by an IPAddressPool (i.e. legacy
+// AddressPools, or the pools read from a configuration file) are ignored.
+func (c *Client) UpdatePoolStatus(name string, status metallbv1beta1.IPAddressPoolStatus) error {
+	if c.configFile != "" {
+		return nil
+	}
+	var pool metallbv1beta1.IPAddressPool
+	err := c.mgr.GetClient().Get(context.TODO(), types.NamespacedName{Namespace: c.namespace, Name: name}, &pool)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pool.Status == status {
+		return nil
+	}
+	pool.Status = status
+	return c.mgr.GetClient().Status().Update(context.TODO(), &pool)
+}
+
 // Infof logs an informational event about svc to the Kubernetes cluster.
 func (c *Client) Infof(svc *v1.Service, kind, msg string, args ...interface{}) {
 	c.events.Eventf(svc, v1.EventTypeNormal, kind, msg, args...)
@@ -422,3 +596,15 @@ func UseEndpointSlices(kubeClient kubernetes.Interface) bool {
 	}
 	return true
 }
+
+// metricsHandler serves the metrics of the default registry, as
+// promhttp.Handler does, with the MetalLB metrics named with the given prefix.
+func metricsHandler(prefix string) http.Handler {
+	if prefix == "" {
+		prefix = metrics.DefaultPrefix
+	}
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(metrics.WithPrefix(prometheus.DefaultGatherer, prefix), promhttp.HandlerOpts{}),
+	)
+}
@@ -18,6 +18,13 @@ type Listener struct {
 	ConfigChanged  func(log.Logger, *config.Config) controllers.SyncState
 	PoolChanged    func(log.Logger, *config.Pools) controllers.SyncState
 	NodeChanged    func(log.Logger, *v1.Node) controllers.SyncState
+	// ServicePriority returns the priority of a service, the services
+	// with a higher priority being handled first when all of them are
+	// reprocessed.
+	ServicePriority func(*v1.Service) int
+	// ServicesReconciled is called each time all the services were
+	// reprocessed without errors.
+	ServicesReconciled func(log.Logger)
 }
 
 func (l *Listener) ServiceHandler(logger log.Logger, serviceName string, svc *v1.Service, endpointsOrSlices epslices.EpsOrSlices) controllers.SyncState {
@@ -43,3 +50,15 @@ func (l *Listener) PoolHandler(logger log.Logger, pools *config.Pools) controlle
 	defer l.Unlock()
 	return l.PoolChanged(logger, pools)
 }
+
+func (l *Listener) ServicePriorityHandler(svc *v1.Service) int {
+	l.Lock()
+	defer l.Unlock()
+	return l.ServicePriority(svc)
+}
+
+func (l *Listener) ServicesReconciledHandler(logger log.Logger) {
+	l.Lock()
+	defer l.Unlock()
+	l.ServicesReconciled(logger)
+}
@@ -5,7 +5,10 @@ package layer2
 import (
 	"net"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,9 +16,21 @@ import (
 	"github.com/go-kit/log/level"
 )
 
+// sysClassNet is where the network devices are described in sysfs.
+var sysClassNet = "/sys/class/net"
+
 // Announce is used to "announce" new IPs mapped to the node's MAC address.
 type Announce struct {
 	logger log.Logger
+	// minMTU is the MTU below which the interfaces are not used to
+	// announce, 0 to use all of them.
+	minMTU int
+	// virtualMAC is true if the IPs are announced with a MAC derived
+	// from them instead of the MAC of the interfaces.
+	virtualMAC bool
+	// limiter limits the rate of the replies sent to each requester,
+	// nil to not limit them.
+	limiter *replyLimiter
 
 	sync.RWMutex
 	nodeInterfaces []string // current local interfaces' name list
@@ -23,22 +38,46 @@ type Announce struct {
 	ndps           map[int]*ndpResponder
 	ips            map[string][]IPAdvertisement // svcName -> IPAdvertisements
 	ipRefcnt       map[string]int               // ip.String() -> number of uses
+	lowMTU         map[string]bool              // interfaces skipped because of their MTU
+	vrfs           map[string]bool              // VRF devices of the node
+	interfaceVRFs  map[string]string            // interface -> VRF it's enslaved to
 
 	// This channel can block - do not write to it while holding the mutex
 	// to avoid deadlocking.
 	spamCh chan IPAdvertisement
+
+	garpMux        sync.Mutex           // Mutex for lastGratuitous.
+	lastGratuitous map[string]time.Time // ip.String() -> time of the last gratuitous announcement
+
+	vmacMux     sync.Mutex                  // Mutex for virtualMACs.
+	virtualMACs map[string]net.HardwareAddr // ip.String() -> virtual MAC the ip is announced with
+
+	// foreign tracks the announcements of the IPs by other devices.
+	foreign *foreignWatcher
 }
 
-// New returns an initialized Announce.
-func New(l log.Logger) (*Announce, error) {
+// New returns an initialized Announce. The interfaces with an MTU lower
+// than minMTU are not used to announce, unless minMTU is 0. If virtualMAC
+// is true, each IP is announced with a MAC derived from it, which stays the
+// same when the IP moves to another node. At most replyRate ARP and NDP
+// replies per second are sent to each requester, unless replyRate is 0.
+func New(l log.Logger, minMTU int, virtualMAC bool, replyRate float64) (*Announce, error) {
 	ret := &Announce{
 		logger:         l,
+		minMTU:         minMTU,
+		virtualMAC:     virtualMAC,
+		limiter:        newReplyLimiter(replyRate),
+		virtualMACs:    map[string]net.HardwareAddr{},
+		lowMTU:         map[string]bool{},
+		vrfs:           map[string]bool{},
+		interfaceVRFs:  map[string]string{},
 		nodeInterfaces: []string{},
 		arps:           map[int]*arpResponder{},
 		ndps:           map[int]*ndpResponder{},
 		ips:            map[string][]IPAdvertisement{},
 		ipRefcnt:       map[string]int{},
 		spamCh:         make(chan IPAdvertisement, 1024),
+		foreign:        newForeignWatcher(),
 	}
 	go ret.interfaceScan()
 	go ret.spamLoop()
@@ -65,10 +104,15 @@ func (a *Announce) updateInterfaces() {
 
 	keepARP, keepNDP := map[int]bool{}, map[int]bool{}
 	curIfs := make([]string, 0, len(ifs))
+	vrfs, interfaceVRFs := map[string]bool{}, map[string]string{}
 	for _, intf := range ifs {
 		ifi := intf
 		curIfs = append(curIfs, ifi.Name)
 		l := log.With(a.logger, "interface", ifi.Name)
+		if isVRF(ifi.Name) {
+			vrfs[ifi.Name] = true
+			continue
+		}
 		addrs, err := ifi.Addrs()
 		if err != nil {
 			level.Error(l).Log("op", "getAddresses", "error", err, "msg", "couldn't get addresses for interface")
@@ -78,10 +122,18 @@ func (a *Announce) updateInterfaces() {
 		if ifi.Flags&net.FlagUp == 0 {
 			continue
 		}
-		if _, err = os.Stat("/sys/class/net/" + ifi.Name + "/master"); !os.IsNotExist(err) {
+		if !a.mtuAllowed(l, &ifi) {
 			continue
 		}
-		f, err := os.ReadFile("/sys/class/net/" + ifi.Name + "/flags")
+		// The interfaces enslaved to a bridge or a bond are not used, the
+		// ones enslaved to a VRF are used by the advertisements in the VRF.
+		if master := interfaceMaster(ifi.Name); master != "" {
+			if !isVRF(master) {
+				continue
+			}
+			interfaceVRFs[ifi.Name] = master
+		}
+		f, err := os.ReadFile(filepath.Join(sysClassNet, ifi.Name, "flags"))
 		if err == nil {
 			flags, _ := strconv.ParseUint(string(f)[:len(string(f))-1], 0, 32)
 			// NOARP flag
@@ -106,7 +158,7 @@ func (a *Announce) updateInterfaces() {
 		}
 
 		if keepARP[ifi.Index] && a.arps[ifi.Index] == nil {
-			resp, err := newARPResponder(a.logger, &ifi, a.shouldAnnounce)
+			resp, err := newARPResponder(a.logger, &ifi, a.shouldAnnounce, a.responseMAC, a.limiter, a.checkForeign)
 			if err != nil {
 				level.Error(l).Log("op", "createARPResponder", "error", err, "msg", "failed to create ARP responder")
 				continue
@@ -115,7 +167,7 @@ func (a *Announce) updateInterfaces() {
 			level.Info(l).Log("event", "createARPResponder", "msg", "created ARP responder for interface")
 		}
 		if keepNDP[ifi.Index] && a.ndps[ifi.Index] == nil {
-			resp, err := newNDPResponder(a.logger, &ifi, a.shouldAnnounce)
+			resp, err := newNDPResponder(a.logger, &ifi, a.shouldAnnounce, a.responseMAC, a.limiter)
 			if err != nil {
 				level.Error(l).Log("op", "createNDPResponder", "error", err, "msg", "failed to create NDP responder")
 				continue
@@ -126,6 +178,8 @@ func (a *Announce) updateInterfaces() {
 	}
 
 	a.nodeInterfaces = curIfs
+	a.vrfs = vrfs
+	a.interfaceVRFs = interfaceVRFs
 
 	for i, client := range a.arps {
 		if !keepARP[i] {
@@ -143,6 +197,52 @@ func (a *Announce) updateInterfaces() {
 	}
 }
 
+// interfaceMaster returns the name of the device the interface is enslaved
+// to, or an empty string if it's not enslaved.
+func interfaceMaster(name string) string {
+	target, err := os.Readlink(filepath.Join(sysClassNet, name, "master"))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// isVRF returns true if the device is a VRF.
+func isVRF(name string) bool {
+	uevent, err := os.ReadFile(filepath.Join(sysClassNet, name, "uevent"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(uevent), "\n") {
+		if line == "DEVTYPE=vrf" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchInterface returns true if the IP of the advertisement is to be
+// announced from the interface, the interface belonging to the VRF of the
+// advertisement. The caller must hold the lock.
+func (a *Announce) matchInterface(adv IPAdvertisement, intf string) bool {
+	return adv.vrf == a.interfaceVRFs[intf] && adv.matchInterface(intf)
+}
+
+// mtuAllowed returns true if the MTU of the interface is high enough for it
+// to be used to announce. The interfaces skipped are logged the first time.
+// The caller must hold the lock.
+func (a *Announce) mtuAllowed(l log.Logger, ifi *net.Interface) bool {
+	if a.minMTU == 0 || ifi.MTU >= a.minMTU {
+		delete(a.lowMTU, ifi.Name)
+		return true
+	}
+	if !a.lowMTU[ifi.Name] {
+		level.Warn(l).Log("op", "updateInterfaces", "mtu", ifi.MTU, "minMTU", a.minMTU, "msg", "not announcing on interface, MTU too low")
+		a.lowMTU[ifi.Name] = true
+	}
+	return false
+}
+
 func (a *Announce) spamLoop() {
 	// Map IP to spam stop time.
 	type timedSpam struct {
@@ -202,27 +302,40 @@ func (a *Announce) gratuitous(adv IPAdvertisement) {
 
 	if ip.To4() != nil {
 		for _, client := range a.arps {
-			if !adv.matchInterface(client.intf) {
+			if !a.matchInterface(adv, client.intf) {
 				level.Debug(a.logger).Log("op", "gratuitousAnnounce", "skip interfaces", client.intf)
 				continue
 			}
 			if err := client.Gratuitous(ip); err != nil {
 				level.Error(a.logger).Log("op", "gratuitousAnnounce", "error", err, "ip", ip, "msg", "failed to make gratuitous ARP announcement")
+				continue
 			}
+			a.gratuitousSent(ip)
 		}
 	} else {
 		for _, client := range a.ndps {
-			if !adv.matchInterface(client.intf) {
+			if !a.matchInterface(adv, client.intf) {
 				level.Debug(a.logger).Log("op", "gratuitousAnnounce", "skip interfaces", client.intf)
 				continue
 			}
 			if err := client.Gratuitous(ip); err != nil {
 				level.Error(a.logger).Log("op", "gratuitousAnnounce", "error", err, "ip", ip, "msg", "failed to make gratuitous NDP announcement")
+				continue
 			}
+			a.gratuitousSent(ip)
 		}
 	}
 }
 
+func (a *Announce) gratuitousSent(ip net.IP) {
+	a.garpMux.Lock()
+	defer a.garpMux.Unlock()
+	if a.lastGratuitous == nil {
+		a.lastGratuitous = map[string]time.Time{}
+	}
+	a.lastGratuitous[ip.String()] = time.Now()
+}
+
 func (a *Announce) shouldAnnounce(ip net.IP, intf string) dropReason {
 	a.RLock()
 	defer a.RUnlock()
@@ -231,7 +344,7 @@ func (a *Announce) shouldAnnounce(ip net.IP, intf string) dropReason {
 		for _, i := range ipAdvertisements {
 			if i.ip.Equal(ip) {
 				ipFound = true
-				if i.matchInterface(intf) {
+				if a.matchInterface(i, intf) {
 					return dropReasonNone
 				}
 			}
@@ -268,6 +381,7 @@ func (a *Announce) SetBalancer(name string, adv IPAdvertisement) {
 		// else to do right now.
 		return
 	}
+	a.addVirtualMAC(adv.ip)
 
 	for _, client := range a.ndps {
 		if err := client.Watch(adv.ip); err != nil {
@@ -300,6 +414,11 @@ func (a *Announce) DeleteBalancer(name string) {
 				level.Error(a.logger).Log("op", "unwatchMulticastGroup", "error", err, "ip", cur.ip, "interface", client.intf, "msg", "failed to unwatch NDP multicast group for IP")
 			}
 		}
+
+		a.garpMux.Lock()
+		delete(a.lastGratuitous, cur.ip.String())
+		a.garpMux.Unlock()
+		a.deleteVirtualMAC(cur.ip)
 	}
 }
 
@@ -321,6 +440,68 @@ func (a *Announce) GetInterfaces() []string {
 	return localInterfaces
 }
 
+// HasVRF returns true if the VRF exists on the node.
+func (a *Announce) HasVRF(vrf string) bool {
+	a.RLock()
+	defer a.RUnlock()
+	return a.vrfs[vrf]
+}
+
+// AnnouncedIP describes an IP announced by this node.
+type AnnouncedIP struct {
+	Service        string     `json:"service"`
+	IP             string     `json:"ip"`
+	VRF            string     `json:"vrf,omitempty"`
+	Interfaces     []string   `json:"interfaces"`
+	LastGratuitous *time.Time `json:"lastGratuitous,omitempty"`
+}
+
+// Dump returns the IPs currently announced, along with the interfaces
+// they are announced from and the time of the last gratuitous announcement.
+func (a *Announce) Dump() []AnnouncedIP {
+	a.RLock()
+	defer a.RUnlock()
+	a.garpMux.Lock()
+	defer a.garpMux.Unlock()
+
+	res := []AnnouncedIP{}
+	for name, advs := range a.ips {
+		for _, adv := range advs {
+			announced := AnnouncedIP{
+				Service:    name,
+				IP:         adv.ip.String(),
+				VRF:        adv.vrf,
+				Interfaces: []string{},
+			}
+			if adv.ip.To4() != nil {
+				for _, client := range a.arps {
+					if a.matchInterface(adv, client.intf) {
+						announced.Interfaces = append(announced.Interfaces, client.intf)
+					}
+				}
+			} else {
+				for _, client := range a.ndps {
+					if a.matchInterface(adv, client.intf) {
+						announced.Interfaces = append(announced.Interfaces, client.intf)
+					}
+				}
+			}
+			sort.Strings(announced.Interfaces)
+			if t, ok := a.lastGratuitous[announced.IP]; ok {
+				announced.LastGratuitous = &t
+			}
+			res = append(res, announced)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Service != res[j].Service {
+			return res[i].Service < res[j].Service
+		}
+		return res[i].IP < res[j].IP
+	})
+	return res
+}
+
 // dropReason is the reason why a layer2 protocol packet was not
 // responded to.
 type dropReason int
@@ -336,4 +517,5 @@ const (
 	dropReasonEthernetDestination
 	dropReasonAnnounceIP
 	dropReasonNotMatchInterface
+	dropReasonRateLimited
 )
@@ -4,8 +4,12 @@ package layer2
 
 import (
 	"net"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
+	"github.com/go-kit/log"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -62,3 +66,189 @@ func Test_SetBalancer_AddsToAnnouncedServices(t *testing.T) {
 		t.Fatalf("ip 192.168.1.20 has not 2 refcnt: %d", announce.ipRefcnt["192.168.1.20"])
 	}
 }
+
+func Test_Dump(t *testing.T) {
+	announce := &Announce{
+		arps: map[int]*arpResponder{
+			1: {intf: "eth0"},
+			2: {intf: "eth1"},
+		},
+		ips:      map[string][]IPAdvertisement{},
+		ipRefcnt: map[string]int{},
+		spamCh:   make(chan IPAdvertisement, 1),
+	}
+
+	announce.SetBalancer("foo", NewIPAdvertisement(net.ParseIP("192.168.1.20"), true, sets.Set[string]{}))
+	<-announce.spamCh
+	announce.SetBalancer("bar", NewIPAdvertisement(net.ParseIP("192.168.1.21"), false, sets.New("eth1")))
+	<-announce.spamCh
+	announce.gratuitousSent(net.ParseIP("192.168.1.20"))
+
+	dump := announce.Dump()
+	if len(dump) != 2 {
+		t.Fatalf("expected 2 announced ips, got %d", len(dump))
+	}
+	bar, foo := dump[0], dump[1]
+	if bar.Service != "bar" || bar.IP != "192.168.1.21" || !reflect.DeepEqual(bar.Interfaces, []string{"eth1"}) || bar.LastGratuitous != nil {
+		t.Fatalf("unexpected state for bar: %+v", bar)
+	}
+	if foo.Service != "foo" || foo.IP != "192.168.1.20" || !reflect.DeepEqual(foo.Interfaces, []string{"eth0", "eth1"}) || foo.LastGratuitous == nil {
+		t.Fatalf("unexpected state for foo: %+v", foo)
+	}
+
+	announce.DeleteBalancer("foo")
+	if _, ok := announce.lastGratuitous["192.168.1.20"]; ok {
+		t.Fatalf("last gratuitous time not cleared for deleted ip")
+	}
+}
+
+func Test_MTUAllowed(t *testing.T) {
+	tests := []struct {
+		desc   string
+		minMTU int
+		mtu    int
+		want   bool
+	}{
+		{desc: "check disabled", minMTU: 0, mtu: 68, want: true},
+		{desc: "mtu above the minimum", minMTU: 1500, mtu: 9000, want: true},
+		{desc: "mtu equal to the minimum", minMTU: 1500, mtu: 1500, want: true},
+		{desc: "mtu below the minimum", minMTU: 1500, mtu: 1280, want: false},
+	}
+	for _, test := range tests {
+		announce := &Announce{
+			minMTU: test.minMTU,
+			lowMTU: map[string]bool{},
+		}
+		ifi := &net.Interface{Name: "eth0", MTU: test.mtu}
+		if got := announce.mtuAllowed(log.NewNopLogger(), ifi); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.desc, test.want, got)
+		}
+		if announce.lowMTU["eth0"] == test.want {
+			t.Errorf("%s: expected the interface to be tracked as low mtu: %v", test.desc, !test.want)
+		}
+	}
+}
+
+func Test_InterfaceVRF(t *testing.T) {
+	oldSysClassNet := sysClassNet
+	defer func() {
+		sysClassNet = oldSysClassNet
+	}()
+	sysClassNet = t.TempDir()
+
+	device := func(name, uevent, master string) {
+		dir := filepath.Join(sysClassNet, name)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatalf("failed to create %s: %s", dir, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "uevent"), []byte(uevent), 0600); err != nil {
+			t.Fatalf("failed to write the uevent of %s: %s", name, err)
+		}
+		if master != "" {
+			if err := os.Symlink("../"+master, filepath.Join(dir, "master")); err != nil {
+				t.Fatalf("failed to link the master of %s: %s", name, err)
+			}
+		}
+	}
+	device("red", "DEVTYPE=vrf\nINTERFACE=red\nIFINDEX=5\n", "")
+	device("br0", "DEVTYPE=bridge\nINTERFACE=br0\nIFINDEX=6\n", "")
+	device("eth0", "INTERFACE=eth0\nIFINDEX=2\n", "")
+	device("eth1", "INTERFACE=eth1\nIFINDEX=3\n", "red")
+	device("eth2", "INTERFACE=eth2\nIFINDEX=4\n", "br0")
+
+	if !isVRF("red") || isVRF("br0") || isVRF("eth0") || isVRF("missing") {
+		t.Fatal("unexpected detection of the VRF devices")
+	}
+	for name, want := range map[string]string{"eth0": "", "eth1": "red", "eth2": "br0"} {
+		if got := interfaceMaster(name); got != want {
+			t.Errorf("%s: expected master %q, got %q", name, want, got)
+		}
+	}
+}
+
+func Test_VRFAnnouncement(t *testing.T) {
+	announce := &Announce{
+		arps: map[int]*arpResponder{
+			1: {intf: "eth0"},
+			2: {intf: "eth1"},
+		},
+		vrfs:          map[string]bool{"red": true},
+		interfaceVRFs: map[string]string{"eth1": "red"},
+		ips:           map[string][]IPAdvertisement{},
+		ipRefcnt:      map[string]int{},
+		spamCh:        make(chan IPAdvertisement, 1),
+	}
+
+	announce.SetBalancer("foo", NewIPAdvertisement(net.ParseIP("192.168.1.20"), true, sets.Set[string]{}))
+	<-announce.spamCh
+	announce.SetBalancer("bar", NewVRFIPAdvertisement(net.ParseIP("192.168.1.21"), "red", true, sets.Set[string]{}))
+	<-announce.spamCh
+
+	if !announce.HasVRF("red") || announce.HasVRF("blue") {
+		t.Fatal("unexpected VRFs of the node")
+	}
+	tests := []struct {
+		ip   string
+		intf string
+		want dropReason
+	}{
+		{ip: "192.168.1.20", intf: "eth0", want: dropReasonNone},
+		{ip: "192.168.1.20", intf: "eth1", want: dropReasonNotMatchInterface},
+		{ip: "192.168.1.21", intf: "eth0", want: dropReasonNotMatchInterface},
+		{ip: "192.168.1.21", intf: "eth1", want: dropReasonNone},
+	}
+	for _, test := range tests {
+		if got := announce.shouldAnnounce(net.ParseIP(test.ip), test.intf); got != test.want {
+			t.Errorf("%s on %s: expected %v, got %v", test.ip, test.intf, test.want, got)
+		}
+	}
+
+	dump := announce.Dump()
+	if len(dump) != 2 {
+		t.Fatalf("expected 2 announced ips, got %d", len(dump))
+	}
+	bar, foo := dump[0], dump[1]
+	if bar.VRF != "red" || !reflect.DeepEqual(bar.Interfaces, []string{"eth1"}) {
+		t.Fatalf("unexpected state for bar: %+v", bar)
+	}
+	if foo.VRF != "" || !reflect.DeepEqual(foo.Interfaces, []string{"eth0"}) {
+		t.Fatalf("unexpected state for foo: %+v", foo)
+	}
+}
+
+func Test_VirtualMAC(t *testing.T) {
+	announce := &Announce{
+		logger:      log.NewNopLogger(),
+		virtualMAC:  true,
+		virtualMACs: map[string]net.HardwareAddr{},
+		ips:         map[string][]IPAdvertisement{},
+		ipRefcnt:    map[string]int{},
+		spamCh:      make(chan IPAdvertisement, 1),
+	}
+	ifiMAC := net.HardwareAddr{0, 1, 2, 3, 4, 5}
+
+	v4 := net.ParseIP("192.168.1.20")
+	announce.SetBalancer("foo", NewIPAdvertisement(v4, true, sets.Set[string]{}))
+	<-announce.spamCh
+	if got := announce.responseMAC(v4, ifiMAC); got.String() != "02:00:c0:a8:01:14" {
+		t.Fatalf("expected the virtual MAC derived from %s, got %s", v4, got)
+	}
+
+	v6 := net.ParseIP("2001:db8::1")
+	if !reflect.DeepEqual(virtualMACFor(v6), virtualMACFor(net.ParseIP("2001:db8::1"))) {
+		t.Fatalf("expected the virtual MAC of %s to be stable", v6)
+	}
+	// Another IP, announced by the node, colliding with the virtual MAC
+	// of v6 makes it fall back to the MAC of the interface.
+	announce.virtualMACs["2001:db8::2"] = virtualMACFor(v6)
+	announce.SetBalancer("bar", NewIPAdvertisement(v6, true, sets.Set[string]{}))
+	<-announce.spamCh
+	if got := announce.responseMAC(v6, ifiMAC); !reflect.DeepEqual(got, ifiMAC) {
+		t.Fatalf("expected the MAC of the interface for colliding %s, got %s", v6, got)
+	}
+
+	announce.DeleteBalancer("foo")
+	if got := announce.responseMAC(v4, ifiMAC); !reflect.DeepEqual(got, ifiMAC) {
+		t.Fatalf("expected the virtual MAC of %s to be released, got %s", v4, got)
+	}
+}
@@ -16,6 +16,10 @@ import (
 
 type announceFunc func(net.IP, string) dropReason
 
+// macFunc returns the MAC to announce an IP with, given the MAC of the
+// interface.
+type macFunc func(net.IP, net.HardwareAddr) net.HardwareAddr
+
 type arpResponder struct {
 	logger       log.Logger
 	intf         string
@@ -23,9 +27,12 @@ type arpResponder struct {
 	conn         *arp.Client
 	closed       chan struct{}
 	announce     announceFunc
+	macFor       macFunc
+	limiter      *replyLimiter
+	foreign      foreignFunc
 }
 
-func newARPResponder(logger log.Logger, ifi *net.Interface, ann announceFunc) (*arpResponder, error) {
+func newARPResponder(logger log.Logger, ifi *net.Interface, ann announceFunc, macFor macFunc, limiter *replyLimiter, foreign foreignFunc) (*arpResponder, error) {
 	client, err := arp.Dial(ifi)
 	if err != nil {
 		return nil, fmt.Errorf("creating ARP responder for %q: %s", ifi.Name, err)
@@ -38,6 +45,9 @@ func newARPResponder(logger log.Logger, ifi *net.Interface, ann announceFunc) (*
 		conn:         client,
 		closed:       make(chan struct{}),
 		announce:     ann,
+		macFor:       macFor,
+		limiter:      limiter,
+		foreign:      foreign,
 	}
 	go ret.run()
 	return ret, nil
@@ -51,8 +61,9 @@ func (a *arpResponder) Close() error {
 }
 
 func (a *arpResponder) Gratuitous(ip net.IP) error {
+	mac := a.macFor(ip, a.hardwareAddr)
 	for _, op := range []arp.Operation{arp.OperationRequest, arp.OperationReply} {
-		pkt, err := arp.NewPacket(op, a.hardwareAddr, ip, ethernet.Broadcast, ip)
+		pkt, err := arp.NewPacket(op, mac, ip, ethernet.Broadcast, ip)
 		if err != nil {
 			return fmt.Errorf("assembling %q gratuitous packet for %q: %s", op, ip, err)
 		}
@@ -86,13 +97,19 @@ func (a *arpResponder) processRequest() dropReason {
 		return dropReasonError
 	}
 
-	// Ignore ARP replies.
+	// Ignore ARP replies, after checking whether they announce one of our
+	// IPs from another device.
 	if pkt.Operation != arp.OperationRequest {
+		if pkt.Operation == arp.OperationReply && a.foreign != nil {
+			a.foreign(pkt.SenderIP, pkt.SenderHardwareAddr, a.intf)
+		}
 		return dropReasonARPReply
 	}
 
-	// Ignore ARP requests which are not broadcast or bound directly for this machine.
-	if !bytes.Equal(eth.Destination, ethernet.Broadcast) && !bytes.Equal(eth.Destination, a.hardwareAddr) {
+	// Ignore ARP requests which are not broadcast or bound directly for this
+	// machine, or for the virtual MAC of the requested IP.
+	mac := a.macFor(pkt.TargetIP, a.hardwareAddr)
+	if !bytes.Equal(eth.Destination, ethernet.Broadcast) && !bytes.Equal(eth.Destination, a.hardwareAddr) && !bytes.Equal(eth.Destination, mac) {
 		return dropReasonEthernetDestination
 	}
 
@@ -106,10 +123,17 @@ func (a *arpResponder) processRequest() dropReason {
 	}
 
 	stats.GotRequest(pkt.TargetIP.String())
-	level.Debug(a.logger).Log("interface", a.intf, "ip", pkt.TargetIP, "senderIP", pkt.SenderIP, "senderMAC", pkt.SenderHardwareAddr, "responseMAC", a.hardwareAddr, "msg", "got ARP request for service IP, sending response")
+	if allowed, started := a.limiter.allow(requesterKey(pkt.SenderHardwareAddr, pkt.SenderIP)); !allowed {
+		if started {
+			level.Warn(a.logger).Log("op", "arpRequestIgnore", "interface", a.intf, "ip", pkt.TargetIP, "senderIP", pkt.SenderIP, "senderMAC", pkt.SenderHardwareAddr, "reason", "rateLimited", "msg", "too many ARP requests from the sender, dropping the excess")
+		}
+		stats.RateLimited(pkt.TargetIP.String())
+		return dropReasonRateLimited
+	}
+	level.Debug(a.logger).Log("interface", a.intf, "ip", pkt.TargetIP, "senderIP", pkt.SenderIP, "senderMAC", pkt.SenderHardwareAddr, "responseMAC", mac, "msg", "got ARP request for service IP, sending response")
 
-	if err := a.conn.Reply(pkt, a.hardwareAddr, pkt.TargetIP); err != nil {
-		level.Error(a.logger).Log("op", "arpReply", "interface", a.intf, "ip", pkt.TargetIP, "senderIP", pkt.SenderIP, "senderMAC", pkt.SenderHardwareAddr, "responseMAC", a.hardwareAddr, "error", err, "msg", "failed to send ARP reply")
+	if err := a.conn.Reply(pkt, mac, pkt.TargetIP); err != nil {
+		level.Error(a.logger).Log("op", "arpReply", "interface", a.intf, "ip", pkt.TargetIP, "senderIP", pkt.SenderIP, "senderMAC", pkt.SenderHardwareAddr, "responseMAC", mac, "error", err, "msg", "failed to send ARP reply")
 	} else {
 		stats.SentResponse(pkt.TargetIP.String())
 	}
@@ -21,6 +21,8 @@ func TestARPResponder(t *testing.T) {
 		arpTgt         net.IP
 		arpOp          arp.Operation
 		shouldAnnounce announceFunc
+		virtualMAC     net.HardwareAddr
+		limiter        *replyLimiter
 		reason         dropReason
 	}{
 		{
@@ -42,6 +44,12 @@ func TestARPResponder(t *testing.T) {
 			dstMAC: ethernet.Broadcast,
 			reason: dropReasonNone,
 		},
+		{
+			name:       "OK (virtual MAC)",
+			dstMAC:     net.HardwareAddr{2, 0, 192, 168, 1, 10},
+			virtualMAC: net.HardwareAddr{2, 0, 192, 168, 1, 10},
+			reason:     dropReasonNone,
+		},
 		{
 			name: "shouldAnnounce denies request",
 			shouldAnnounce: func(ip net.IP, intf string) dropReason {
@@ -63,6 +71,15 @@ func TestARPResponder(t *testing.T) {
 			},
 			reason: dropReasonNone,
 		},
+		{
+			name: "rate limited",
+			limiter: func() *replyLimiter {
+				r := newReplyLimiter(1)
+				r.allow(requesterKey(net.HardwareAddr{1, 2, 3, 4, 5, 6}, net.IPv4(192, 168, 1, 1)))
+				return r
+			}(),
+			reason: dropReasonRateLimited,
+		},
 	}
 
 	for _, tt := range tests {
@@ -75,6 +92,12 @@ func TestARPResponder(t *testing.T) {
 			}
 			a, conn, done := newTestARP(t, shouldAnnounce)
 			defer done()
+			a.limiter = tt.limiter
+			if tt.virtualMAC != nil {
+				a.macFor = func(net.IP, net.HardwareAddr) net.HardwareAddr {
+					return tt.virtualMAC
+				}
+			}
 
 			// Defaults for test params
 			if tt.dstMAC == nil {
@@ -163,6 +186,9 @@ func newTestARP(t *testing.T, shouldAnnounce announceFunc) (*arpResponder, *net.
 			conn:         c,
 			closed:       make(chan struct{}),
 			announce:     shouldAnnounce,
+			macFor: func(_ net.IP, mac net.HardwareAddr) net.HardwareAddr {
+				return mac
+			},
 		}
 	}
 
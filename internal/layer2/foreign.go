@@ -0,0 +1,145 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package layer2
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// foreignCheckRate is the maximum number of ARP replies for the
+	// announced IPs per second checked for foreign announcements, the
+	// excess being ignored, so that a flood of replies does not load the
+	// speaker.
+	foreignCheckRate = 100
+	// foreignReportInterval is how often a foreign announcement of the
+	// same IP by the same MAC is reported at most.
+	foreignReportInterval = time.Minute
+)
+
+// foreignFunc is called with the IP and the MAC of the ARP replies
+// received on an interface.
+type foreignFunc func(ip net.IP, mac net.HardwareAddr, intf string)
+
+// ForeignAnnouncement describes an IP announced by this node also
+// announced by another device.
+type ForeignAnnouncement struct {
+	// The services the IP is announced for.
+	Services  []string
+	IP        net.IP
+	MAC       net.HardwareAddr
+	Interface string
+}
+
+// foreignWatcher tracks the foreign announcements of the announced IPs.
+type foreignWatcher struct {
+	limiter *rate.Limiter
+
+	sync.Mutex
+	onForeign    func(ForeignAnnouncement)
+	lastReported map[string]time.Time // ip/mac -> time of the last report
+	lastPrune    time.Time
+}
+
+func newForeignWatcher() *foreignWatcher {
+	return &foreignWatcher{
+		limiter:      rate.NewLimiter(foreignCheckRate, foreignCheckRate),
+		lastReported: map[string]time.Time{},
+	}
+}
+
+// report tells if the foreign announcement of the IP by the MAC is to be
+// reported, returning the function to report it with.
+func (w *foreignWatcher) report(ip net.IP, mac net.HardwareAddr, now time.Time) (bool, func(ForeignAnnouncement)) {
+	w.Lock()
+	defer w.Unlock()
+
+	if now.Sub(w.lastPrune) > foreignReportInterval {
+		for k, t := range w.lastReported {
+			if now.Sub(t) > foreignReportInterval {
+				delete(w.lastReported, k)
+			}
+		}
+		w.lastPrune = now
+	}
+	key := ip.String() + "/" + mac.String()
+	if t, ok := w.lastReported[key]; ok && now.Sub(t) <= foreignReportInterval {
+		return false, nil
+	}
+	w.lastReported[key] = now
+	return true, w.onForeign
+}
+
+// OnForeignAnnouncement sets the function called when an IP announced by
+// this node is also announced by another device, at most once a minute for
+// the same IP and MAC.
+func (a *Announce) OnForeignAnnouncement(f func(ForeignAnnouncement)) {
+	a.foreign.Lock()
+	defer a.foreign.Unlock()
+	a.foreign.onForeign = f
+}
+
+// checkForeign reports the ARP replies for an announced IP sent from a MAC
+// which is not the one the IP is announced with by this node. This happens
+// when another device claims the IP, or transiently when the IP moves to
+// another node. Only the replies for the announced IPs count towards the
+// rate limit of the checks.
+func (a *Announce) checkForeign(ip net.IP, mac net.HardwareAddr, intf string) {
+	if !a.isForeign(ip, mac) || !a.foreign.limiter.Allow() {
+		return
+	}
+
+	a.RLock()
+	services := []string{}
+	for name, advs := range a.ips {
+		for _, adv := range advs {
+			if adv.ip.Equal(ip) {
+				services = append(services, name)
+				break
+			}
+		}
+	}
+	a.RUnlock()
+	sort.Strings(services)
+	if len(services) == 0 {
+		// The IP stopped being announced meanwhile.
+		return
+	}
+
+	stats.ForeignAnnouncement(ip.String())
+	report, onForeign := a.foreign.report(ip, mac, time.Now())
+	if !report {
+		return
+	}
+	level.Warn(a.logger).Log("op", "foreignAnnouncement", "ip", ip, "mac", mac, "interface", intf, "services", services, "msg", "another device announces an IP announced by this node")
+	if onForeign != nil {
+		onForeign(ForeignAnnouncement{
+			Services:  services,
+			IP:        ip,
+			MAC:       mac,
+			Interface: intf,
+		})
+	}
+}
+
+// isForeign tells if the IP is announced by this node with another MAC.
+func (a *Announce) isForeign(ip net.IP, mac net.HardwareAddr) bool {
+	a.RLock()
+	defer a.RUnlock()
+	if a.ipRefcnt[ip.String()] <= 0 {
+		return false
+	}
+	for _, client := range a.arps {
+		if bytes.Equal(mac, client.hardwareAddr) || bytes.Equal(mac, a.responseMAC(ip, client.hardwareAddr)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package layer2
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestCheckForeign(t *testing.T) {
+	ourMAC := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	foreignMAC := net.HardwareAddr{2, 0, 0, 0, 0, 2}
+	ip := net.IPv4(192, 168, 1, 20)
+
+	announce := &Announce{
+		logger:      log.NewNopLogger(),
+		arps:        map[int]*arpResponder{1: {intf: "eth0", hardwareAddr: ourMAC}},
+		ips:         map[string][]IPAdvertisement{},
+		ipRefcnt:    map[string]int{},
+		virtualMACs: map[string]net.HardwareAddr{},
+		spamCh:      make(chan IPAdvertisement, 2),
+		foreign:     newForeignWatcher(),
+	}
+	announce.SetBalancer("default/foo", NewIPAdvertisement(ip, true, sets.Set[string]{}))
+	announce.SetBalancer("default/bar", NewIPAdvertisement(ip, true, sets.Set[string]{}))
+
+	var reported []ForeignAnnouncement
+	announce.OnForeignAnnouncement(func(f ForeignAnnouncement) {
+		reported = append(reported, f)
+	})
+	count := func() float64 {
+		return testutil.ToFloat64(stats.foreign.WithLabelValues(ip.String()))
+	}
+	before := count()
+
+	// Our own replies and the ones for the IPs not announced are ignored.
+	announce.checkForeign(ip, ourMAC, "eth0")
+	announce.checkForeign(net.IPv4(192, 168, 1, 30), foreignMAC, "eth0")
+	if len(reported) != 0 || count() != before {
+		t.Fatalf("expected no foreign announcement, got %+v", reported)
+	}
+
+	// The replies for the IPs not announced do not use up the checks.
+	for i := 0; i < 2*foreignCheckRate; i++ {
+		announce.checkForeign(net.IPv4(192, 168, 1, 30), foreignMAC, "eth0")
+	}
+
+	announce.checkForeign(ip, foreignMAC, "eth0")
+	want := []ForeignAnnouncement{{
+		Services:  []string{"default/bar", "default/foo"},
+		IP:        ip,
+		MAC:       foreignMAC,
+		Interface: "eth0",
+	}}
+	if diff := cmp.Diff(want, reported); diff != "" {
+		t.Fatalf("unexpected foreign announcements (-want +got)\n%s", diff)
+	}
+
+	// Counted again, but not reported again within the report interval.
+	announce.checkForeign(ip, foreignMAC, "eth0")
+	if len(reported) != 1 {
+		t.Fatalf("expected the foreign announcement to be reported once, got %d", len(reported))
+	}
+	if count() != before+2 {
+		t.Fatalf("expected 2 foreign announcements to be counted, got %v", count()-before)
+	}
+}
+
+func TestForeignWatcherReport(t *testing.T) {
+	w := newForeignWatcher()
+	ip := net.IPv4(192, 168, 1, 20)
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 2}
+	now := time.Now()
+
+	if report, _ := w.report(ip, mac, now); !report {
+		t.Fatal("expected the first announcement to be reported")
+	}
+	if report, _ := w.report(ip, mac, now.Add(time.Second)); report {
+		t.Fatal("expected the announcement not to be reported again")
+	}
+	if report, _ := w.report(ip, net.HardwareAddr{2, 0, 0, 0, 0, 3}, now.Add(time.Second)); !report {
+		t.Fatal("expected the announcement by another MAC to be reported")
+	}
+	if report, _ := w.report(ip, mac, now.Add(foreignReportInterval+time.Second)); !report {
+		t.Fatal("expected the announcement to be reported again after the interval")
+	}
+}
@@ -13,13 +13,22 @@ type IPAdvertisement struct {
 	ip            net.IP
 	interfaces    sets.Set[string]
 	allInterfaces bool
+	// vrf is the host VRF the IP is announced in, empty for the default one.
+	vrf string
 }
 
 func NewIPAdvertisement(ip net.IP, allInterfaces bool, interfaces sets.Set[string]) IPAdvertisement {
+	return NewVRFIPAdvertisement(ip, "", allInterfaces, interfaces)
+}
+
+// NewVRFIPAdvertisement returns an advertisement of the IP from the
+// interfaces enslaved to the given VRF.
+func NewVRFIPAdvertisement(ip net.IP, vrf string, allInterfaces bool, interfaces sets.Set[string]) IPAdvertisement {
 	return IPAdvertisement{
 		ip:            ip,
 		interfaces:    interfaces,
 		allInterfaces: allInterfaces,
+		vrf:           vrf,
 	}
 }
 
@@ -36,6 +45,9 @@ func (i1 *IPAdvertisement) Equal(i2 *IPAdvertisement) bool {
 	if i1.allInterfaces != i2.allInterfaces {
 		return false
 	}
+	if i1.vrf != i2.vrf {
+		return false
+	}
 	if i1.allInterfaces {
 		return true
 	}
@@ -63,3 +75,13 @@ func (i *IPAdvertisement) matchInterface(intf string) bool {
 	}
 	return i.interfaces.Has(intf)
 }
+
+// AllInterfaces tells if the IP is announced on all the interfaces.
+func (i *IPAdvertisement) AllInterfaces() bool {
+	return i.allInterfaces
+}
+
+// VRF returns the VRF the IP is announced in, empty for the default one.
+func (i *IPAdvertisement) VRF() string {
+	return i.vrf
+}
@@ -19,12 +19,14 @@ type ndpResponder struct {
 	conn         *ndp.Conn
 	closed       chan struct{}
 	announce     announceFunc
+	macFor       macFunc
+	limiter      *replyLimiter
 	// Refcount of how many watchers for each solicited node
 	// multicast group.
 	solicitedNodeGroups map[string]int64
 }
 
-func newNDPResponder(logger log.Logger, ifi *net.Interface, ann announceFunc) (*ndpResponder, error) {
+func newNDPResponder(logger log.Logger, ifi *net.Interface, ann announceFunc, macFor macFunc, limiter *replyLimiter) (*ndpResponder, error) {
 	// Use link-local address as the source IPv6 address for NDP communications.
 	conn, _, err := ndp.Dial(ifi, ndp.LinkLocal)
 	if err != nil {
@@ -38,6 +40,8 @@ func newNDPResponder(logger log.Logger, ifi *net.Interface, ann announceFunc) (*
 		conn:                conn,
 		closed:              make(chan struct{}),
 		announce:            ann,
+		macFor:              macFor,
+		limiter:             limiter,
 		solicitedNodeGroups: map[string]int64{},
 	}
 	go ret.run()
@@ -144,10 +148,17 @@ func (n *ndpResponder) processRequest() dropReason {
 	}
 
 	stats.GotRequest(ns.TargetAddress.String())
-	level.Debug(n.logger).Log("interface", n.intf, "ip", ns.TargetAddress, "senderIP", src, "senderLLAddr", nsLLAddr, "responseMAC", n.hardwareAddr, "msg", "got NDP request for service IP, sending response")
+	if allowed, started := n.limiter.allow(requesterKey(nsLLAddr, src)); !allowed {
+		if started {
+			level.Warn(n.logger).Log("op", "ndpRequestIgnore", "interface", n.intf, "ip", ns.TargetAddress, "senderIP", src, "senderLLAddr", nsLLAddr, "reason", "rateLimited", "msg", "too many NDP requests from the sender, dropping the excess")
+		}
+		stats.RateLimited(ns.TargetAddress.String())
+		return dropReasonRateLimited
+	}
+	level.Debug(n.logger).Log("interface", n.intf, "ip", ns.TargetAddress, "senderIP", src, "senderLLAddr", nsLLAddr, "responseMAC", n.macFor(ns.TargetAddress, n.hardwareAddr), "msg", "got NDP request for service IP, sending response")
 
 	if err := n.advertise(src, ns.TargetAddress, false); err != nil {
-		level.Error(n.logger).Log("op", "ndpReply", "interface", n.intf, "ip", ns.TargetAddress, "senderIP", src, "senderLLAddr", nsLLAddr, "responseMAC", n.hardwareAddr, "error", err, "msg", "failed to send ARP reply")
+		level.Error(n.logger).Log("op", "ndpReply", "interface", n.intf, "ip", ns.TargetAddress, "senderIP", src, "senderLLAddr", nsLLAddr, "responseMAC", n.macFor(ns.TargetAddress, n.hardwareAddr), "error", err, "msg", "failed to send ARP reply")
 	} else {
 		stats.SentResponse(ns.TargetAddress.String())
 	}
@@ -162,7 +173,7 @@ func (n *ndpResponder) advertise(dst, target net.IP, gratuitous bool) error {
 		Options: []ndp.Option{
 			&ndp.LinkLayerAddress{
 				Direction: ndp.Target,
-				Addr:      n.hardwareAddr,
+				Addr:      n.macFor(target, n.hardwareAddr),
 			},
 		},
 	}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package layer2
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// limiterPruneInterval is how often the limiters of the requesters
+	// which are not limited anymore are forgotten.
+	limiterPruneInterval = time.Minute
+	// maxTrackedRequesters is the maximum number of requesters limited
+	// each on their own, the least recently seen one being forgotten to
+	// make room for a new one, so that a flood of requests from random
+	// senders does not grow the limiters without bound.
+	maxTrackedRequesters = 4096
+)
+
+// replyLimiter limits the rate of the replies sent to each requester, so
+// that a flood of requests for the announced IPs does not load the
+// speaker. A requester sending requests within the rate is never limited.
+type replyLimiter struct {
+	rate  rate.Limit
+	burst int
+
+	sync.Mutex
+	limiters map[string]*list.Element // requester -> element of recent
+	// recent holds the limiters of the requesters, from the most to the
+	// least recently seen.
+	recent    *list.List
+	lastPrune time.Time
+}
+
+type requesterLimiter struct {
+	*rate.Limiter
+	requester string
+	// limited is true once a request of the requester was dropped,
+	// until one is allowed again.
+	limited bool
+}
+
+// newReplyLimiter returns a limiter allowing perSecond replies per second
+// to each requester, or nil to not limit them if perSecond is 0. Up to
+// perSecond replies, and at least one, can be sent at once.
+func newReplyLimiter(perSecond float64) *replyLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &replyLimiter{
+		rate:     rate.Limit(perSecond),
+		burst:    int(math.Max(1, math.Ceil(perSecond))),
+		limiters: map[string]*list.Element{},
+		recent:   list.New(),
+	}
+}
+
+// requesterKey returns the key the requests of a sender are limited with.
+func requesterKey(mac net.HardwareAddr, ip net.IP) string {
+	return mac.String() + "/" + ip.String()
+}
+
+// allow tells if a reply can be sent to the requester, and if the requester
+// just started being limited. A nil limiter allows all the replies.
+func (r *replyLimiter) allow(requester string) (bool, bool) {
+	if r == nil {
+		return true, false
+	}
+	return r.allowAt(requester, time.Now())
+}
+
+func (r *replyLimiter) allowAt(requester string, now time.Time) (bool, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if now.Sub(r.lastPrune) > limiterPruneInterval {
+		for k, e := range r.limiters {
+			if e.Value.(*requesterLimiter).TokensAt(now) >= float64(r.burst) {
+				r.recent.Remove(e)
+				delete(r.limiters, k)
+			}
+		}
+		r.lastPrune = now
+	}
+
+	var l *requesterLimiter
+	if e, ok := r.limiters[requester]; ok {
+		r.recent.MoveToFront(e)
+		l = e.Value.(*requesterLimiter)
+	} else {
+		if len(r.limiters) >= maxTrackedRequesters {
+			oldest := r.recent.Back()
+			r.recent.Remove(oldest)
+			delete(r.limiters, oldest.Value.(*requesterLimiter).requester)
+		}
+		l = &requesterLimiter{Limiter: rate.NewLimiter(r.rate, r.burst), requester: requester}
+		r.limiters[requester] = r.recent.PushFront(l)
+	}
+	if l.AllowN(now, 1) {
+		l.limited = false
+		return true, false
+	}
+	started := !l.limited
+	l.limited = true
+	return false, started
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package layer2
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReplyLimiter(t *testing.T) {
+	if allowed, _ := newReplyLimiter(0).allow("192.168.1.1"); !allowed {
+		t.Fatal("expected a disabled limiter to allow the replies")
+	}
+
+	r := newReplyLimiter(2)
+	now := time.Now()
+	// Sparse requests are never limited.
+	for i := 0; i < 10; i++ {
+		if allowed, _ := r.allowAt("192.168.1.1", now.Add(time.Duration(i)*time.Second)); !allowed {
+			t.Fatalf("expected request %d one second apart to be allowed", i)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		if allowed, _ := r.allowAt("192.168.1.1", now); !allowed {
+			t.Fatalf("expected request %d of the burst to be allowed", i)
+		}
+	}
+	allowed, started := r.allowAt("192.168.1.1", now)
+	if allowed || !started {
+		t.Fatalf("expected the request above the burst to start the limit, got allowed %v started %v", allowed, started)
+	}
+	allowed, started = r.allowAt("192.168.1.1", now)
+	if allowed || started {
+		t.Fatalf("expected the request to be limited without starting the limit again, got allowed %v started %v", allowed, started)
+	}
+	// The limit is per requester.
+	if allowed, _ := r.allowAt("192.168.1.2", now); !allowed {
+		t.Fatal("expected the request of another requester to be allowed")
+	}
+	if allowed, _ := r.allowAt("192.168.1.1", now.Add(time.Second)); !allowed {
+		t.Fatal("expected the request to be allowed once the rate allows it")
+	}
+
+	// The idle requesters are forgotten.
+	r.allowAt("192.168.1.3", now.Add(time.Hour))
+	if len(r.limiters) != 1 {
+		t.Fatalf("expected the idle requesters to be forgotten, got %d limiters", len(r.limiters))
+	}
+}
+
+func TestReplyLimiterMaxRequesters(t *testing.T) {
+	r := newReplyLimiter(1)
+	now := time.Now()
+	for i := 0; i < maxTrackedRequesters; i++ {
+		if allowed, _ := r.allowAt(fmt.Sprintf("requester%d", i), now); !allowed {
+			t.Fatalf("expected the first request of requester %d to be allowed", i)
+		}
+	}
+	// requester0 is seen again, requester1 becomes the least recently seen.
+	if allowed, _ := r.allowAt("requester0", now); allowed {
+		t.Fatal("expected the second request of requester0 to be limited")
+	}
+
+	// The requesters above the limit get their own limiter, in place of
+	// the least recently seen requesters.
+	for _, requester := range []string{"other1", "other2"} {
+		if allowed, _ := r.allowAt(requester, now); !allowed {
+			t.Fatalf("expected the first request of %s to be allowed", requester)
+		}
+	}
+	if len(r.limiters) != maxTrackedRequesters {
+		t.Fatalf("expected %d limiters, got %d", maxTrackedRequesters, len(r.limiters))
+	}
+	for _, requester := range []string{"requester1", "requester2"} {
+		if _, ok := r.limiters[requester]; ok {
+			t.Fatalf("expected %s to be forgotten", requester)
+		}
+	}
+
+	// The recently seen requesters keep their own limiter.
+	allowed, started := r.allowAt("requester0", now)
+	if allowed || started {
+		t.Fatalf("expected requester0 to be still limited, got allowed %v started %v", allowed, started)
+	}
+}
@@ -31,18 +31,40 @@ var stats = metrics{
 	}, []string{
 		"ip",
 	}),
+
+	limited: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "metallb",
+		Subsystem: "layer2",
+		Name:      "requests_rate_limited",
+		Help:      "Number of layer2 requests received for owned IPs not responded to because of the reply rate limit",
+	}, []string{
+		"ip",
+	}),
+
+	foreign: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "metallb",
+		Subsystem: "layer2",
+		Name:      "foreign_announcement_total",
+		Help:      "Number of ARP replies received for owned IPs from MACs other than the ones of the node",
+	}, []string{
+		"ip",
+	}),
 }
 
 type metrics struct {
 	in         *prometheus.CounterVec
 	out        *prometheus.CounterVec
 	gratuitous *prometheus.CounterVec
+	limited    *prometheus.CounterVec
+	foreign    *prometheus.CounterVec
 }
 
 func init() {
 	prometheus.MustRegister(stats.in)
 	prometheus.MustRegister(stats.out)
 	prometheus.MustRegister(stats.gratuitous)
+	prometheus.MustRegister(stats.limited)
+	prometheus.MustRegister(stats.foreign)
 }
 
 func (m *metrics) GotRequest(addr string) {
@@ -56,3 +78,11 @@ func (m *metrics) SentResponse(addr string) {
 func (m *metrics) SentGratuitous(addr string) {
 	m.gratuitous.WithLabelValues(addr).Add(1)
 }
+
+func (m *metrics) RateLimited(addr string) {
+	m.limited.WithLabelValues(addr).Add(1)
+}
+
+func (m *metrics) ForeignAnnouncement(addr string) {
+	m.foreign.WithLabelValues(addr).Add(1)
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package layer2
+
+import (
+	"bytes"
+	"hash/fnv"
+	"net"
+
+	"github.com/go-kit/log/level"
+)
+
+// virtualMACFor returns the locally administered MAC address derived from
+// the given IP. The IPv4 addresses are embedded as they are, so that no two
+// of them share a MAC, while the IPv6 ones are hashed.
+func virtualMACFor(ip net.IP) net.HardwareAddr {
+	if ip4 := ip.To4(); ip4 != nil {
+		return net.HardwareAddr{0x02, 0x00, ip4[0], ip4[1], ip4[2], ip4[3]}
+	}
+	h := fnv.New32a()
+	h.Write(ip.To16())
+	sum := h.Sum(nil)
+	return net.HardwareAddr{0x02, 0x01, sum[0], sum[1], sum[2], sum[3]}
+}
+
+// addVirtualMAC assigns its virtual MAC to the given IP, unless another
+// announced IP already uses the same one, in which case the IP is announced
+// with the MAC of the interfaces. The caller must hold the lock.
+func (a *Announce) addVirtualMAC(ip net.IP) {
+	if !a.virtualMAC {
+		return
+	}
+	mac := virtualMACFor(ip)
+	a.vmacMux.Lock()
+	defer a.vmacMux.Unlock()
+	for other, otherMAC := range a.virtualMACs {
+		if other != ip.String() && bytes.Equal(mac, otherMAC) {
+			level.Error(a.logger).Log("op", "setBalancer", "ip", ip, "collidingIP", other, "mac", mac, "msg", "virtual MAC already in use by another IP, announcing with the MAC of the interfaces")
+			return
+		}
+	}
+	a.virtualMACs[ip.String()] = mac
+}
+
+// deleteVirtualMAC releases the virtual MAC of the given IP.
+func (a *Announce) deleteVirtualMAC(ip net.IP) {
+	a.vmacMux.Lock()
+	defer a.vmacMux.Unlock()
+	delete(a.virtualMACs, ip.String())
+}
+
+// responseMAC returns the MAC to announce the given IP with, being its
+// virtual MAC if any, or the given MAC of the interface otherwise.
+func (a *Announce) responseMAC(ip net.IP, ifiMAC net.HardwareAddr) net.HardwareAddr {
+	a.vmacMux.Lock()
+	defer a.vmacMux.Unlock()
+	if mac, ok := a.virtualMACs[ip.String()]; ok {
+		return mac
+	}
+	return ifiMAC
+}
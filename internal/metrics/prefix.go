@@ -0,0 +1,52 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// DefaultPrefix is the prefix of the names of all the MetalLB metrics.
+const DefaultPrefix = "metallb"
+
+var prefixRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValidatePrefix returns an error if the given prefix is not a valid
+// component of a Prometheus metric name.
+func ValidatePrefix(prefix string) error {
+	if !prefixRegexp.MatchString(prefix) {
+		return fmt.Errorf("invalid metrics prefix %q, must match %s", prefix, prefixRegexp)
+	}
+	return nil
+}
+
+// WithPrefix returns a gatherer that replaces the default prefix of the
+// MetalLB metrics gathered by g with the given one. The other metrics, such
+// as the go runtime ones, are left untouched.
+func WithPrefix(g prometheus.Gatherer, prefix string) prometheus.Gatherer {
+	if prefix == DefaultPrefix {
+		return g
+	}
+	return prefixGatherer{g, prefix}
+}
+
+type prefixGatherer struct {
+	prometheus.Gatherer
+	prefix string
+}
+
+func (g prefixGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	for _, f := range families {
+		if name := f.GetName(); strings.HasPrefix(name, DefaultPrefix+"_") {
+			renamed := g.prefix + strings.TrimPrefix(name, DefaultPrefix)
+			f.Name = &renamed
+		}
+	}
+	return families, err
+}
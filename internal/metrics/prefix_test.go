@@ -0,0 +1,55 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package metrics
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestValidatePrefix(t *testing.T) {
+	for _, prefix := range []string{"metallb", "metallb_fork", "_lb", "LB2"} {
+		if err := ValidatePrefix(prefix); err != nil {
+			t.Errorf("expected %q to be valid, got %s", prefix, err)
+		}
+	}
+	for _, prefix := range []string{"", "2lb", "metal-lb", "metal:lb", "metal lb"} {
+		if err := ValidatePrefix(prefix); err == nil {
+			t.Errorf("expected %q to be invalid", prefix)
+		}
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewCounter(prometheus.CounterOpts{Namespace: "metallb", Subsystem: "bgp", Name: "updates_total", Help: "updates"}),
+		prometheus.NewGauge(prometheus.GaugeOpts{Namespace: "metallbfork", Name: "up", Help: "up"}),
+		prometheus.NewGauge(prometheus.GaugeOpts{Namespace: "go", Name: "threads", Help: "threads"}),
+	)
+
+	names := func(g prometheus.Gatherer) []string {
+		families, err := g.Gather()
+		if err != nil {
+			t.Fatalf("gather failed: %s", err)
+		}
+		res := []string{}
+		for _, f := range families {
+			res = append(res, f.GetName())
+		}
+		sort.Strings(res)
+		return res
+	}
+
+	want := []string{"go_threads", "metallb_bgp_updates_total", "metallbfork_up"}
+	if diff := cmp.Diff(want, names(WithPrefix(registry, DefaultPrefix))); diff != "" {
+		t.Errorf("unexpected metrics with the default prefix (-want +got)\n%s", diff)
+	}
+	want = []string{"go_threads", "lb_bgp_updates_total", "metallbfork_up"}
+	if diff := cmp.Diff(want, names(WithPrefix(registry, "lb"))); diff != "" {
+		t.Errorf("unexpected metrics with a custom prefix (-want +got)\n%s", diff)
+	}
+}
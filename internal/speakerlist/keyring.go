@@ -0,0 +1,157 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package speakerlist
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/hashicorp/memberlist"
+	"go.universe.tf/metallb/internal/k8s"
+)
+
+// keysPollInterval is how often the mounted memberlist secret is checked
+// for changes.
+var keysPollInterval = 10 * time.Second
+
+// secretKeys are the memberlist encryption keys read from the memberlist
+// secret: the primary one is used to encrypt the traffic, the others are
+// accepted to decrypt it while rotating the keys.
+type secretKeys struct {
+	primary []byte
+	others  [][]byte
+}
+
+func (k *secretKeys) all() [][]byte {
+	return append([][]byte{k.primary}, k.others...)
+}
+
+func (k *secretKeys) equal(other *secretKeys) bool {
+	if other == nil || !bytes.Equal(k.primary, other.primary) || len(k.others) != len(other.others) {
+		return false
+	}
+	for i := range k.others {
+		if !bytes.Equal(k.others[i], other.others[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// deriveKey returns the memberlist key corresponding to the given secret.
+func deriveKey(secret string) []byte {
+	sha := sha256.New()
+	return sha.Sum([]byte(secret))[:16]
+}
+
+// readSecretKeys reads the keys from the directory the memberlist secret
+// is mounted in. The primary key is mandatory, the additional ones are
+// optional and listed one per line.
+func readSecretKeys(path string) (*secretKeys, error) {
+	primary, err := os.ReadFile(filepath.Join(path, k8s.MLSecretKeyName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memberlist secret key file: %w", err)
+	}
+	if len(bytes.TrimSpace(primary)) == 0 {
+		return nil, fmt.Errorf("memberlist secret key %s is empty", k8s.MLSecretKeyName)
+	}
+	secrets := []string{string(primary)}
+
+	additional, err := os.ReadFile(filepath.Join(path, k8s.MLSecretAdditionalKeysName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read memberlist additional keys file: %w", err)
+	}
+	for _, line := range strings.Split(string(additional), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		secrets = append(secrets, line)
+	}
+
+	res := &secretKeys{}
+	derivedFrom := map[string]int{}
+	for i, s := range secrets {
+		key := deriveKey(s)
+		if err := memberlist.ValidateKey(key); err != nil {
+			return nil, fmt.Errorf("invalid memberlist key #%d: %w", i, err)
+		}
+		// Only the first 16 bytes of a secret are used to derive the key,
+		// so two secrets sharing them would not rotate anything.
+		if j, ok := derivedFrom[string(key)]; ok {
+			if strings.TrimSpace(secrets[j]) != strings.TrimSpace(s) {
+				return nil, fmt.Errorf("memberlist keys #%d and #%d differ but derive the same key, the first 16 bytes must differ", j, i)
+			}
+			continue
+		}
+		derivedFrom[string(key)] = i
+		if i == 0 {
+			res.primary = key
+			continue
+		}
+		res.others = append(res.others, key)
+	}
+	return res, nil
+}
+
+// syncKeyring makes the keyring hold exactly the given keys, installing the
+// new ones before switching the primary key and removing the stale ones
+// last, so the traffic encrypted with any of them keeps being accepted.
+func syncKeyring(keyring *memberlist.Keyring, keys *secretKeys) error {
+	for _, k := range keys.all() {
+		if err := keyring.AddKey(k); err != nil {
+			return err
+		}
+	}
+	if err := keyring.UseKey(keys.primary); err != nil {
+		return err
+	}
+	wanted := keys.all()
+OUTER:
+	for _, installed := range keyring.GetKeys() {
+		for _, k := range wanted {
+			if bytes.Equal(installed, k) {
+				continue OUTER
+			}
+		}
+		if err := keyring.RemoveKey(installed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchSecretKeys polls the memberlist secret and applies the changes to
+// the keyring, keeping the current keys when the secret is not valid.
+func (sl *SpeakerList) watchSecretKeys(current *secretKeys) {
+	ticker := time.NewTicker(keysPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sl.stopCh:
+			return
+		case <-ticker.C:
+			keys, err := readSecretKeys(sl.secretKeyPath)
+			if err != nil {
+				level.Error(sl.l).Log("op", "memberlistKeys", "error", err, "msg", "failed to read memberlist keys, keeping the current ones")
+				continue
+			}
+			if keys.equal(current) {
+				continue
+			}
+			if err := syncKeyring(sl.keyring, keys); err != nil {
+				level.Error(sl.l).Log("op", "memberlistKeys", "error", err, "msg", "failed to update the memberlist keyring")
+				continue
+			}
+			current = keys
+			level.Info(sl.l).Log("op", "memberlistKeys", "keys", len(keys.all()), "msg", "memberlist keyring updated")
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package speakerlist
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/memberlist"
+	"go.universe.tf/metallb/internal/k8s"
+)
+
+func writeSecret(t *testing.T, dir string, primary string, additional *string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, k8s.MLSecretKeyName), []byte(primary), 0600); err != nil {
+		t.Fatalf("failed to write the primary key: %s", err)
+	}
+	path := filepath.Join(dir, k8s.MLSecretAdditionalKeysName)
+	if additional == nil {
+		_ = os.Remove(path)
+		return
+	}
+	if err := os.WriteFile(path, []byte(*additional), 0600); err != nil {
+		t.Fatalf("failed to write the additional keys: %s", err)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestReadSecretKeys(t *testing.T) {
+	tests := []struct {
+		desc       string
+		primary    string
+		additional *string
+		others     int
+		mustFail   bool
+	}{
+		{
+			desc:    "primary only",
+			primary: "AAAAAAAAAAAAAAAAAAAAAA",
+		},
+		{
+			desc:       "additional keys",
+			primary:    "AAAAAAAAAAAAAAAAAAAAAA",
+			additional: strPtr("BBBBBBBBBBBBBBBBBBBBBB\n\nCCCCCCCCCCCCCCCCCCCCCC\n"),
+			others:     2,
+		},
+		{
+			desc:       "additional key equal to the primary",
+			primary:    "AAAAAAAAAAAAAAAAAAAAAA",
+			additional: strPtr("AAAAAAAAAAAAAAAAAAAAAA\n"),
+			others:     0,
+		},
+		{
+			desc:     "empty primary",
+			primary:  "\n",
+			mustFail: true,
+		},
+		{
+			desc:       "keys deriving the same key",
+			primary:    "AAAAAAAAAAAAAAAAAAAAAA",
+			additional: strPtr("AAAAAAAAAAAAAAAAAAAAAB"),
+			mustFail:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			writeSecret(t, dir, test.primary, test.additional)
+			keys, err := readSecretKeys(dir)
+			if test.mustFail {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if !bytes.Equal(keys.primary, deriveKey(test.primary)) {
+				t.Fatalf("unexpected primary key")
+			}
+			if len(keys.others) != test.others {
+				t.Fatalf("expected %d additional keys, got %d", test.others, len(keys.others))
+			}
+		})
+	}
+
+	if _, err := readSecretKeys(t.TempDir()); err == nil {
+		t.Fatalf("expected error for missing primary key")
+	}
+}
+
+func TestSyncKeyring(t *testing.T) {
+	oldKey, newKey := deriveKey("AAAAAAAAAAAAAAAAAAAAAA"), deriveKey("BBBBBBBBBBBBBBBBBBBBBB")
+	keyring, err := memberlist.NewKeyring(nil, oldKey)
+	if err != nil {
+		t.Fatalf("failed to create keyring: %s", err)
+	}
+
+	steps := []struct {
+		desc    string
+		keys    *secretKeys
+		primary []byte
+		other   [][]byte
+	}{
+		{
+			desc:    "new key distributed",
+			keys:    &secretKeys{primary: oldKey, others: [][]byte{newKey}},
+			primary: oldKey,
+			other:   [][]byte{newKey},
+		},
+		{
+			desc:    "new key in use",
+			keys:    &secretKeys{primary: newKey, others: [][]byte{oldKey}},
+			primary: newKey,
+			other:   [][]byte{oldKey},
+		},
+		{
+			desc:    "old key removed",
+			keys:    &secretKeys{primary: newKey},
+			primary: newKey,
+		},
+	}
+
+	for _, step := range steps {
+		if err := syncKeyring(keyring, step.keys); err != nil {
+			t.Fatalf("%s: failed to sync the keyring: %s", step.desc, err)
+		}
+		installed := keyring.GetKeys()
+		if !bytes.Equal(installed[0], step.primary) {
+			t.Fatalf("%s: unexpected primary key", step.desc)
+		}
+		if len(installed)-1 != len(step.other) {
+			t.Fatalf("%s: expected %d additional keys, got %d", step.desc, len(step.other), len(installed)-1)
+		}
+		for i, k := range step.other {
+			if !bytes.Equal(installed[i+1], k) {
+				t.Fatalf("%s: unexpected additional key %d", step.desc, i)
+			}
+		}
+	}
+}
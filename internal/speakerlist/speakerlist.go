@@ -13,7 +13,7 @@
 package speakerlist
 
 import (
-	"crypto/sha256"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -40,10 +40,15 @@ type SpeakerList struct {
 
 	mlMux        sync.Mutex // Mutex for mlSpeakerIPs.
 	mlSpeakerIPs []string   // Speaker pod IPs.
+
+	// The following fields are empty when memberlist traffic is not encrypted.
+	secretKeyPath string
+	keyring       *memberlist.Keyring
+	keys          *secretKeys
 }
 
 // New creates a new SpeakerList and returns a pointer to it.
-func New(logger log.Logger, nodeName, bindAddr, bindPort, secret, namespace, labels string, stopCh chan struct{}) (*SpeakerList, error) {
+func New(logger log.Logger, nodeName, bindAddr, bindPort, secretKeyPath, namespace, labels string, stopCh chan struct{}) (*SpeakerList, error) {
 	sl := SpeakerList{
 		l:         logger,
 		stopCh:    stopCh,
@@ -72,11 +77,23 @@ func New(logger log.Logger, nodeName, bindAddr, bindPort, secret, namespace, lab
 		mconfig.AdvertisePort = mlport
 	}
 	mconfig.Logger = newMemberlistLogger(sl.l)
-	if secret == "" {
+	if secretKeyPath == "" {
 		level.Warn(logger).Log("op", "startup", "warning", "no ml-secret-key set, memberlist traffic will not be encrypted")
 	} else {
-		sha := sha256.New()
-		mconfig.SecretKey = sha.Sum([]byte(secret))[:16]
+		keys, err := readSecretKeys(secretKeyPath)
+		if err != nil {
+			level.Error(logger).Log("op", "startup", "error", err, "msg", "failed to read memberlist keys")
+			return nil, err
+		}
+		keyring, err := memberlist.NewKeyring(keys.others, keys.primary)
+		if err != nil {
+			level.Error(logger).Log("op", "startup", "error", err, "msg", "failed to create memberlist keyring")
+			return nil, err
+		}
+		mconfig.Keyring = keyring
+		sl.secretKeyPath = secretKeyPath
+		sl.keyring = keyring
+		sl.keys = keys
 	}
 
 	// This channel is used by the Rejoin() method which runs on k8s node
@@ -147,6 +164,9 @@ func (sl *SpeakerList) Start(client *k8s.Client) {
 
 	go sl.memberlistWatchEvents()
 	go sl.joinMembers()
+	if sl.keyring != nil {
+		go sl.watchSecretKeys(sl.keys)
+	}
 }
 
 // updateSpeakerIPs runs forever updating the sl.mlSpeakerIPs slice with the
@@ -286,6 +306,45 @@ func (sl *SpeakerList) UsableSpeakers() map[string]bool {
 	return activeNodes
 }
 
+// Member describes a member of the memberlist cluster, as seen by this speaker.
+type Member struct {
+	Name  string `json:"name"`
+	Addr  string `json:"addr"`
+	State string `json:"state"`
+}
+
+// Members returns the current view of the memberlist cluster, nil when
+// memberlist is disabled.
+func (sl *SpeakerList) Members() []Member {
+	if sl.ml == nil {
+		return nil
+	}
+	res := []Member{}
+	for _, n := range sl.ml.Members() {
+		res = append(res, Member{
+			Name:  n.Name,
+			Addr:  n.Address(),
+			State: nodeState2String(n.State),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func nodeState2String(s memberlist.NodeStateType) string {
+	switch s {
+	case memberlist.StateAlive:
+		return "alive"
+	case memberlist.StateSuspect:
+		return "suspect"
+	case memberlist.StateDead:
+		return "dead"
+	case memberlist.StateLeft:
+		return "left"
+	}
+	return "unknown"
+}
+
 // Stop stops the SpeakerList.
 func (sl *SpeakerList) Stop() {
 	if sl.ml == nil {
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+// announcedReportInterval is how often the services announced from the
+// node are checked for changes.
+const announcedReportInterval = 10 * time.Second
+
+// announcedServices holds the services announced from the node, with any
+// protocol.
+type announcedServices struct {
+	sync.Mutex
+	names map[string]bool
+}
+
+func (a *announcedServices) set(name string) {
+	a.Lock()
+	defer a.Unlock()
+	if a.names == nil {
+		a.names = map[string]bool{}
+	}
+	a.names[name] = true
+}
+
+func (a *announcedServices) delete(name string) {
+	a.Lock()
+	defer a.Unlock()
+	delete(a.names, name)
+}
+
+// maxReportedServices is the number of services listed at most in the
+// SpeakerReport of the node, so that it stays small.
+const maxReportedServices = 5000
+
+// list returns the sorted names of the services. The IPs of a service
+// being reallocated count as the service.
+func (a *announcedServices) list() []string {
+	a.Lock()
+	defer a.Unlock()
+	unique := map[string]bool{}
+	for name := range a.names {
+		unique[strings.TrimSuffix(name, reallocationName(""))] = true
+	}
+	names := make([]string, 0, len(unique))
+	for name := range unique {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// speakerReporter writes the SpeakerReport of a node.
+type speakerReporter interface {
+	UpdateSpeakerReport(node string, update func(*metallbv1beta1.SpeakerReportStatus)) error
+}
+
+// reportAnnounced publishes the services announced from the node in the
+// SpeakerReport of the node, for the controller to tell the services no
+// node announces. The report is updated when the services change, until
+// stopCh is closed.
+func reportAnnounced(l log.Logger, client speakerReporter, node string, announced *announcedServices, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(announcedReportInterval)
+	defer ticker.Stop()
+
+	var reported []string
+	first := true
+	for {
+		names := announced.list()
+		if first || !reflect.DeepEqual(names, reported) {
+			err := client.UpdateSpeakerReport(node, func(status *metallbv1beta1.SpeakerReportStatus) {
+				status.AnnouncedServices = names
+				status.AnnouncedServicesTruncated = false
+				if len(names) > maxReportedServices {
+					status.AnnouncedServices = names[:maxReportedServices]
+					status.AnnouncedServicesTruncated = true
+				}
+			})
+			if err != nil {
+				level.Error(l).Log("op", "reportAnnounced", "error", err, "msg", "failed to report the announced services")
+			} else {
+				level.Debug(l).Log("op", "reportAnnounced", "services", len(names), "msg", "reported the announced services")
+				reported = names
+				first = false
+			}
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestAnnouncedServices(t *testing.T) {
+	l2MockHandler := &MockProtocol{
+		protocol:       config.Layer2,
+		shouldAnnounce: true,
+	}
+	bgpMockHandler := &MockProtocol{
+		protocol:       config.BGP,
+		shouldAnnounce: true,
+	}
+	c := NewController(l2MockHandler, bgpMockHandler, t)
+
+	cfg := &config.Config{
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+			},
+		}},
+	}
+	if state := c.SetConfig(logger, cfg); state != controllers.SyncStateReprocessAll {
+		t.Fatalf("Set config failed")
+	}
+
+	svc := func(ip string) *v1.Service {
+		return &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: "Cluster",
+			},
+			Status: statusAssigned(ip),
+		}
+	}
+	for name, ip := range map[string]string{"ns/b": "10.20.30.1", "ns/a": "10.20.30.2"} {
+		if state := c.SetBalancer(logger, name, svc(ip), epslices.EpsOrSlices{}); state != controllers.SyncStateSuccess {
+			t.Fatalf("%s: Set balancer failed", name)
+		}
+	}
+	// The IPs of a service being reallocated count as the service.
+	c.reported.set(reallocationName("ns/a"))
+	if diff := cmp.Diff([]string{"ns/a", "ns/b"}, c.reported.list()); diff != "" {
+		t.Fatalf("unexpected announced services (-want +got)\n%s", diff)
+	}
+
+	if state := c.SetBalancer(logger, "ns/b", nil, epslices.EpsOrSlices{}); state != controllers.SyncStateSuccess {
+		t.Fatalf("ns/b: Set balancer failed")
+	}
+	if diff := cmp.Diff([]string{"ns/a"}, c.reported.list()); diff != "" {
+		t.Fatalf("unexpected announced services after deleting ns/b (-want +got)\n%s", diff)
+	}
+}
+
+type fakeReporter struct {
+	status metallbv1beta1.SpeakerReportStatus
+}
+
+func (f *fakeReporter) UpdateSpeakerReport(node string, update func(*metallbv1beta1.SpeakerReportStatus)) error {
+	f.status.Node = node
+	update(&f.status)
+	return nil
+}
+
+func TestReportAnnounced(t *testing.T) {
+	announced := &announcedServices{}
+	for i := 0; i < maxReportedServices+1; i++ {
+		announced.set(fmt.Sprintf("ns/svc%05d", i))
+	}
+	stopCh := make(chan struct{})
+	close(stopCh)
+
+	reporter := &fakeReporter{}
+	reportAnnounced(logger, reporter, "node1", announced, stopCh)
+	if len(reporter.status.AnnouncedServices) != maxReportedServices || !reporter.status.AnnouncedServicesTruncated {
+		t.Fatalf("expected the report to be truncated to %d services, got %d", maxReportedServices, len(reporter.status.AnnouncedServices))
+	}
+
+	announced.delete("ns/svc00000")
+	reportAnnounced(logger, reporter, "node1", announced, stopCh)
+	if len(reporter.status.AnnouncedServices) != maxReportedServices || reporter.status.AnnouncedServicesTruncated {
+		t.Fatalf("expected the report to list the %d services, got %d", maxReportedServices, len(reporter.status.AnnouncedServices))
+	}
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"go.universe.tf/metallb/internal/config"
+)
+
+// announcementLogger logs a line every time the speaker starts, changes or
+// stops the announcement of a service with a protocol, for audit trails.
+// It's used only when enabled with --log-announcements.
+type announcementLogger struct {
+	logger log.Logger
+	// The state of the announcements last logged, per protocol and
+	// service.
+	last map[string]string
+}
+
+func newAnnouncementLogger(l log.Logger) *announcementLogger {
+	return &announcementLogger{logger: l, last: map[string]string{}}
+}
+
+// announced logs the announcement of the IPs of the service from the
+// pool, to the given peers for BGP, if it differs from the last one logged.
+func (a *announcementLogger) announced(protocol config.Proto, name string, ips []net.IP, pool string, peers []string) {
+	key := string(protocol) + "/" + name
+	state := fmt.Sprintf("%v %s %v", ips, pool, peers)
+	last, ok := a.last[key]
+	if ok && last == state {
+		return
+	}
+	a.last[key] = state
+	reason := "announced"
+	if ok {
+		reason = "changed"
+	}
+	a.log("advertise", protocol, name, ips, pool, peers, reason)
+}
+
+// withdrawn logs the withdrawal of the announcement of the service.
+func (a *announcementLogger) withdrawn(protocol config.Proto, name string, ips []net.IP, pool string, peers []string, reason string) {
+	delete(a.last, string(protocol)+"/"+name)
+	a.log("withdraw", protocol, name, ips, pool, peers, reason)
+}
+
+func (a *announcementLogger) log(action string, protocol config.Proto, name string, ips []net.IP, pool string, peers []string, reason string) {
+	keyvals := []interface{}{"event", "announcementChanged", "action", action, "service", name, "protocol", protocol, "ips", fmt.Sprint(ips), "pool", pool}
+	if protocol == config.BGP {
+		keyvals = append(keyvals, "peers", strings.Join(peers, ","))
+	}
+	keyvals = append(keyvals, "reason", reason, "msg", action+" "+name)
+	level.Info(a.logger).Log(keyvals...)
+}
+
+// advertisedPeers returns the sorted names of the peers the advertisements
+// of the service are sent to.
+func (c *bgpController) advertisedPeers(name string) []string {
+	c.Lock()
+	defer c.Unlock()
+	peers := map[string]bool{}
+	allPeers := false
+	for _, ad := range c.svcAds[name] {
+		if len(ad.Peers) == 0 {
+			allPeers = true
+			break
+		}
+		for _, p := range ad.Peers {
+			peers[p] = true
+		}
+	}
+	res := []string{}
+	for _, p := range c.peers {
+		if p.session != nil && (allPeers || peers[p.cfg.Name]) {
+			res = append(res, p.cfg.Name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"go.universe.tf/metallb/internal/config"
+)
+
+func TestAnnouncementLogger(t *testing.T) {
+	var buf bytes.Buffer
+	a := newAnnouncementLogger(log.NewLogfmtLogger(&buf))
+	lines := func() []string {
+		t.Helper()
+		defer buf.Reset()
+		if buf.Len() == 0 {
+			return nil
+		}
+		return strings.Split(strings.TrimSpace(buf.String()), "\n")
+	}
+	ips := []net.IP{net.ParseIP("10.20.30.1")}
+
+	a.announced(config.BGP, "default/foo", ips, "pool1", []string{"peer1", "peer2"})
+	got := lines()
+	if len(got) != 1 || !strings.Contains(got[0], `action=advertise service=default/foo protocol=bgp ips=[10.20.30.1] pool=pool1 peers=peer1,peer2 reason=announced`) {
+		t.Fatalf("unexpected log of the announcement: %v", got)
+	}
+
+	// Announcing the same state again is not logged.
+	a.announced(config.BGP, "default/foo", ips, "pool1", []string{"peer1", "peer2"})
+	if got := lines(); len(got) != 0 {
+		t.Fatalf("expected no log for an unchanged announcement, got %v", got)
+	}
+
+	a.announced(config.BGP, "default/foo", ips, "pool1", []string{"peer1"})
+	got = lines()
+	if len(got) != 1 || !strings.Contains(got[0], "peers=peer1 reason=changed") {
+		t.Fatalf("unexpected log of the changed announcement: %v", got)
+	}
+
+	// The protocols are logged separately, with no peers for L2.
+	a.announced(config.Layer2, "default/foo", ips, "pool1", nil)
+	got = lines()
+	if len(got) != 1 || strings.Contains(got[0], "peers=") || !strings.Contains(got[0], "protocol=layer2") {
+		t.Fatalf("unexpected log of the L2 announcement: %v", got)
+	}
+
+	a.withdrawn(config.BGP, "default/foo", ips, "pool1", []string{"peer1"}, "noIPAllocated")
+	got = lines()
+	if len(got) != 1 || !strings.Contains(got[0], "action=withdraw service=default/foo protocol=bgp ips=[10.20.30.1] pool=pool1 peers=peer1 reason=noIPAllocated") {
+		t.Fatalf("unexpected log of the withdrawal: %v", got)
+	}
+
+	a.announced(config.BGP, "default/foo", ips, "pool1", []string{"peer1"})
+	got = lines()
+	if len(got) != 1 || !strings.Contains(got[0], "reason=announced") {
+		t.Fatalf("expected the announcement after the withdrawal to be logged, got %v", got)
+	}
+}
@@ -15,16 +15,24 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
+	"math"
 	"net"
+	"net/http"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"go.universe.tf/metallb/internal/bgp"
 	bgpfrr "go.universe.tf/metallb/internal/bgp/frr"
 	bgpnative "go.universe.tf/metallb/internal/bgp/native"
 	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/ipfamily"
 	"go.universe.tf/metallb/internal/k8s/epslices"
 	"go.universe.tf/metallb/internal/logging"
 	v1 "k8s.io/api/core/v1"
@@ -45,22 +53,64 @@ const (
 type peer struct {
 	cfg     *config.Peer
 	session bgp.Session
+	// srcAddr is the source address the session was established with.
+	srcAddr net.IP
 }
 
 type bgpController struct {
-	logger         log.Logger
-	myNode         string
-	nodeLabels     labels.Set
-	peers          []*peer
-	svcAds         map[string][]*bgp.Advertisement
+	// Protects the state below from the node health checks, which
+	// change the advertisements asynchronously.
+	sync.Mutex
+	logger     log.Logger
+	myNode     string
+	nodeLabels labels.Set
+	peers      []*peer
+	svcAds     map[string][]*bgp.Advertisement
+	// The advertisements falling back to this node because none of
+	// their nodes has a local endpoint, the ones with a topology key
+	// not made as no node of its group has a ready endpoint, and the
+	// ones electing a single node another node is elected for, per
+	// service.
+	fallbackAds    map[string][]*config.BGPAdvertisement
+	topologyAds    map[string][]*config.BGPAdvertisement
+	singleNodeAds  map[string][]*config.BGPAdvertisement
+	localEndpoints map[string]int // ready endpoints on this node, per service
 	bgpType        bgpImplementation
 	sessionManager bgp.SessionManager
+	// The node advertisements selecting this node, with the health
+	// checks running for them and the advertisements currently made.
+	nodeAdvs   map[string]*config.NodeAdvertisement
+	nodeChecks map[string]*nodeHealthChecker
+	nodeAds    map[string][]*bgp.Advertisement
+	// The advertisement used for the pools not referenced by any
+	// advertisement, nil if disabled.
+	defaultAdv *config.BGPAdvertisement
+	// The types of the node addresses used as the source address of the
+	// peers with none, and the addresses of the node of those types.
+	addressTypes []v1.NodeAddressType
+	nodeAddrs    []net.IP
+	// The selector of the labels of the peers to establish sessions
+	// with, nil to select all of them.
+	peerSelector labels.Selector
+	// The speakers the advertisements electing a single node elect
+	// among.
+	sList SpeakerList
 }
 
 func (c *bgpController) SetConfig(l log.Logger, cfg *config.Config) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if err := c.validateSourceAddresses(cfg.Peers); err != nil {
+		return err
+	}
+
 	newPeers := make([]*peer, 0, len(cfg.Peers))
 newPeers:
 	for _, p := range cfg.Peers {
+		if c.peerSelector != nil && !c.peerSelector.Matches(labels.Set(p.Labels)) {
+			continue
+		}
 		for i, ep := range c.peers {
 			if ep == nil {
 				continue
@@ -94,16 +144,40 @@ newPeers:
 		level.Debug(l).Log("event", "peerRemoved", "peer", p.cfg.Addr, "reason", "removedFromConfig", "msg", "peer deconfigured, BGP session closed")
 	}
 
+	if c.defaultAdv != nil && cfg.Pools != nil {
+		count := 0
+		for _, pool := range cfg.Pools.ByName {
+			if usesDefaultAdvertisement(pool) {
+				count++
+			}
+		}
+		defaultAdvertisementPools.Set(float64(count))
+	}
+
 	err := c.syncBFDProfiles(cfg.BFDProfiles)
 	if err != nil {
 		return errors.Wrap(err, "failed to sync bfd profiles")
 	}
+	nodeAdvertisements := cfg.NodeAdvertisements
+	if cfg.InMaintenance() {
+		nodeAdvertisements = nil
+	}
+	err = c.syncNodeAdvertisements(l, nodeAdvertisements)
+	if err != nil {
+		return errors.Wrap(err, "failed to sync node advertisements")
+	}
 	return c.syncPeers(l)
 }
 
 // hasHealthyEndpoint return true if this node has at least one healthy endpoint.
 // It only checks nodes matching the given filterNode function.
 func hasHealthyEndpoint(eps epslices.EpsOrSlices, filterNode func(*string) bool) bool {
+	return healthyEndpoints(eps, filterNode) > 0
+}
+
+// healthyEndpoints returns the number of fully healthy endpoints, skipping
+// the ones on the nodes matching the given filterNode function.
+func healthyEndpoints(eps epslices.EpsOrSlices, filterNode func(*string) bool) int {
 	ready := map[string]bool{}
 	switch eps.Type {
 	case epslices.Eps:
@@ -145,27 +219,239 @@ func hasHealthyEndpoint(eps epslices.EpsOrSlices, filterNode func(*string) bool)
 		}
 	}
 
+	count := 0
 	for _, r := range ready {
 		if r {
-			// At least one fully healthy endpoint on this machine.
-			return true
+			count++
 		}
 	}
-	return false
+	return count
 }
 
-func (c *bgpController) ShouldAnnounce(l log.Logger, name string, _ []net.IP, pool *config.Pool, svc *v1.Service, eps epslices.EpsOrSlices) string {
-	if !poolMatchesNodeBGP(pool, c.myNode) {
+func (c *bgpController) ShouldAnnounce(l log.Logger, name string, toAnnounce []net.IP, pool *config.Pool, svc *v1.Service, eps epslices.EpsOrSlices) string {
+	ads := c.advertisementsFor(pool)
+	if !poolMatchesNodeBGP(ads, c.myNode) {
 		level.Debug(l).Log("event", "skipping should announce bgp", "service", name, "reason", "pool not matching my node")
 		return "notOwner"
 	}
+
+	c.Lock()
+	defer c.Unlock()
+	delete(c.fallbackAds, name)
+	delete(c.topologyAds, name)
+	delete(c.singleNodeAds, name)
+	if c.localEndpoints == nil {
+		c.localEndpoints = map[string]int{}
+	}
+	c.localEndpoints[name] = healthyEndpoints(eps, func(toFilter *string) bool {
+		return toFilter == nil || *toFilter != c.myNode
+	})
+	var speakers map[string]bool
+	if c.sList != nil {
+		speakers = c.sList.UsableSpeakers()
+	}
+	// Using the first IP should work for both single and dual stack.
+	e := electBGP(c.myNode, speakers, toAnnounce[0], ads, svc, eps)
+	if len(e.fallback) > 0 {
+		level.Debug(l).Log("event", "fallbackToSelectedNodes", "service", name, "msg", "no selected node has a local endpoint, announcing from this node")
+		if c.fallbackAds == nil {
+			c.fallbackAds = map[string][]*config.BGPAdvertisement{}
+		}
+		c.fallbackAds[name] = e.fallback
+	}
+	if len(e.topology) > 0 {
+		level.Debug(l).Log("event", "topologyKey", "service", name, "msg", "skipping the advertisements with no endpoint in the group of this node")
+		if c.topologyAds == nil {
+			c.topologyAds = map[string][]*config.BGPAdvertisement{}
+		}
+		c.topologyAds[name] = e.topology
+	}
+	if len(e.singleNode) > 0 {
+		if c.singleNodeAds == nil {
+			c.singleNodeAds = map[string][]*config.BGPAdvertisement{}
+		}
+		c.singleNodeAds[name] = e.singleNode
+		if e.reason != "" {
+			level.Debug(l).Log("event", "singleNode", "service", name, "msg", "another node is elected to announce the service")
+		}
+	}
+	return e.reason
+}
+
+// bgpElection is the outcome of the BGP election of a node for a service.
+type bgpElection struct {
+	// The reason for the node not to announce the service, empty if it
+	// announces it.
+	reason string
+	// The advertisements falling back to the node, the ones it does not
+	// make because of their topology key and the ones it does not make
+	// because another node is elected.
+	fallback   []*config.BGPAdvertisement
+	topology   []*config.BGPAdvertisement
+	singleNode []*config.BGPAdvertisement
+}
+
+// electBGP runs the election of the given node for announcing the service
+// with the given advertisements, among the given speakers. It does not
+// depend on the state of the controller, so the outcome can be computed for
+// any node.
+func electBGP(node string, speakers map[string]bool, ip net.IP, ads []*config.BGPAdvertisement, svc *v1.Service, eps epslices.EpsOrSlices) bgpElection {
+	res := bgpElection{reason: endpointsAllowBGPAnnounce(node, svc, eps)}
+	switch res.reason {
+	case "":
+		res.topology, res.reason = filterTopology(node, ads, eps)
+	case "noLocalEndpoints":
+		res.fallback = fallbackAdvertisements(ads, node, eps)
+		if len(res.fallback) == 0 {
+			return res
+		}
+		res.reason = ""
+	}
+	if res.reason != "" {
+		return res
+	}
+	res.singleNode, res.reason = filterSingleNode(node, speakers, ip, ads, svc, eps, res.fallback, res.topology)
+	return res
+}
+
+// filterSingleNode returns the advertisements electing a single node the
+// given node must not make for the service, as another node is elected,
+// and a reason not to announce it if it is left with none. Only the
+// fallback advertisements are considered when set, and the ones skipped
+// because of their topology key are ignored.
+func filterSingleNode(node string, speakers map[string]bool, ip net.IP, ads []*config.BGPAdvertisement, svc *v1.Service, eps epslices.EpsOrSlices, fallback, topology []*config.BGPAdvertisement) ([]*config.BGPAdvertisement, string) {
+	isFallback := len(fallback) > 0
+	var skipped []*config.BGPAdvertisement
+	announced := false
+	for _, ad := range ads {
+		if !ad.Nodes[node] || containsAdvertisement(topology, ad) {
+			continue
+		}
+		if isFallback && !containsAdvertisement(fallback, ad) {
+			continue
+		}
+		if !ad.SingleNode {
+			announced = true
+			continue
+		}
+		// The nodes with a local endpoint are elected, unless the
+		// advertisement falls back to all its nodes.
+		local := svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal && !isFallback
+		if bgpLeaderFor(speakers, ip, ad, local, eps) == node {
+			announced = true
+			continue
+		}
+		skipped = append(skipped, ad)
+	}
+	if len(skipped) > 0 && !announced {
+		return skipped, "notOwner"
+	}
+	return skipped, ""
+}
+
+// bgpLeaderFor returns the node elected to make the given advertisement of
+// the ip among the speakers it selects, only the ones with a ready endpoint
+// if local is set, or an empty string if none is eligible. The nodes are
+// ordered by the hash of node + ip, as in layer2 mode.
+func bgpLeaderFor(speakers map[string]bool, ip net.IP, ad *config.BGPAdvertisement, local bool, eps epslices.EpsOrSlices) string {
+	selected := map[string]bool{}
+	for s := range speakers {
+		if ad.Nodes[s] {
+			selected[s] = true
+		}
+	}
+	var nodes []string
+	if local {
+		nodes = usableNodes(eps, selected)
+	} else {
+		nodes = nodesWithActiveSpeakers(selected)
+	}
+	if len(nodes) == 0 {
+		return ""
+	}
+	ipString := ip.String()
+	sort.Slice(nodes, func(i, j int) bool {
+		hi := sha256.Sum256([]byte(nodes[i] + "#" + ipString))
+		hj := sha256.Sum256([]byte(nodes[j] + "#" + ipString))
+		if cmp := bytes.Compare(hi[:], hj[:]); cmp != 0 {
+			return cmp < 0
+		}
+		return nodes[i] < nodes[j]
+	})
+	return nodes[0]
+}
+
+// fallbackAdvertisements returns the advertisements falling back to the
+// given node, as none of the nodes they select has a ready endpoint.
+func fallbackAdvertisements(ads []*config.BGPAdvertisement, node string, eps epslices.EpsOrSlices) []*config.BGPAdvertisement {
+	if !hasHealthyEndpoint(eps, func(toFilter *string) bool { return false }) {
+		return nil
+	}
+	var res []*config.BGPAdvertisement
+	for _, ad := range ads {
+		if !ad.FallbackToSelectedNodes || !ad.Nodes[node] {
+			continue
+		}
+		notSelected := func(toFilter *string) bool {
+			return toFilter == nil || !ad.Nodes[*toFilter]
+		}
+		if !hasHealthyEndpoint(eps, notSelected) {
+			res = append(res, ad)
+		}
+	}
+	return res
+}
+
+// filterTopology returns the advertisements with a topology key the given
+// node must not make for the service, and a reason not to announce it if it
+// is left with none.
+func filterTopology(node string, ads []*config.BGPAdvertisement, eps epslices.EpsOrSlices) ([]*config.BGPAdvertisement, string) {
+	skipped := topologySkippedAdvertisements(ads, node, eps)
+	if len(skipped) == 0 {
+		return nil, ""
+	}
+	for _, ad := range ads {
+		if ad.Nodes[node] && !containsAdvertisement(skipped, ad) {
+			return skipped, ""
+		}
+	}
+	return nil, "noEndpointsInTopology"
+}
+
+// topologySkippedAdvertisements returns the advertisements with a topology
+// key the given node must not make, as none of the nodes of its group has
+// a ready endpoint. A node without the label is not part of any group.
+func topologySkippedAdvertisements(ads []*config.BGPAdvertisement, node string, eps epslices.EpsOrSlices) []*config.BGPAdvertisement {
+	var res []*config.BGPAdvertisement
+	for _, ad := range ads {
+		if ad.TopologyKey == "" || !ad.Nodes[node] {
+			continue
+		}
+		group, ok := ad.NodeGroups[node]
+		otherGroup := func(toFilter *string) bool {
+			if !ok || toFilter == nil {
+				return true
+			}
+			g, inGroup := ad.NodeGroups[*toFilter]
+			return !inGroup || g != group
+		}
+		if !hasHealthyEndpoint(eps, otherGroup) {
+			res = append(res, ad)
+		}
+	}
+	return res
+}
+
+// endpointsAllowBGPAnnounce tells if the endpoints of the service allow the
+// given node to announce it, returning the reason why not otherwise.
+func endpointsAllowBGPAnnounce(node string, svc *v1.Service, eps epslices.EpsOrSlices) string {
 	// Should we advertise?
 	// Yes, if externalTrafficPolicy is
 	//  Cluster && any healthy endpoint exists
 	// or
 	//  Local && there's a ready local endpoint.
 	filterNode := func(toFilter *string) bool {
-		if toFilter == nil || *toFilter != c.myNode {
+		if toFilter == nil || *toFilter != node {
 			return true
 		}
 		return false
@@ -189,16 +475,7 @@ func (c *bgpController) syncPeers(l log.Logger) error {
 	for _, p := range c.peers {
 		// First, determine if the peering should be active for this
 		// node.
-		shouldRun := false
-		if len(p.cfg.NodeSelectors) == 0 {
-			shouldRun = true
-		}
-		for _, ns := range p.cfg.NodeSelectors {
-			if ns.Matches(c.nodeLabels) {
-				shouldRun = true
-				break
-			}
-		}
+		shouldRun := peerSelectsNode(p.cfg, c.nodeLabels)
 
 		// Now, compare current state to intended state, and correct.
 		if p.session != nil && !shouldRun {
@@ -212,25 +489,41 @@ func (c *bgpController) syncPeers(l log.Logger) error {
 			// Session doesn't exist, but should be running. Create
 			// it.
 			level.Info(l).Log("event", "peerAdded", "peer", p.cfg.Addr, "msg", "peer configured, starting BGP session")
+			if p.cfg.NextHopSelf && p.cfg.MyASN != p.cfg.ASN {
+				level.Warn(l).Log("event", "peerAdded", "peer", p.cfg.Addr, "msg", "next hop self set on an eBGP peer, where the speaker is already the next hop")
+			}
 			var routerID net.IP
 			if p.cfg.RouterID != nil {
 				routerID = p.cfg.RouterID
 			}
+			srcAddr, err := c.sourceAddressFor(p.cfg)
+			if err != nil {
+				level.Error(l).Log("op", "syncPeers", "error", err, "peer", p.cfg.Addr, "vrf", p.cfg.VRF, "msg", "failed to find the source address of the BGP session")
+				errs++
+				continue
+			}
 			s, err := c.sessionManager.NewSession(c.logger,
 				bgp.SessionParameters{
-					PeerAddress:   net.JoinHostPort(p.cfg.Addr.String(), strconv.Itoa(int(p.cfg.Port))),
-					SourceAddress: p.cfg.SrcAddr,
-					MyASN:         p.cfg.MyASN,
-					RouterID:      routerID,
-					PeerASN:       p.cfg.ASN,
-					HoldTime:      p.cfg.HoldTime,
-					KeepAliveTime: p.cfg.KeepaliveTime,
-					Password:      p.cfg.Password,
-					CurrentNode:   c.myNode,
-					BFDProfile:    p.cfg.BFDProfile,
-					EBGPMultiHop:  p.cfg.EBGPMultiHop,
-					SessionName:   p.cfg.Name,
-					VRFName:       p.cfg.VRF,
+					PeerAddress:     net.JoinHostPort(p.cfg.Addr.String(), strconv.Itoa(int(p.cfg.Port))),
+					SourceAddress:   srcAddr,
+					SourcePorts:     p.cfg.SrcPorts,
+					TCPKeepalive:    p.cfg.TCPKeepalive,
+					MyASN:           p.cfg.MyASN,
+					RouterID:        routerID,
+					PeerASN:         p.cfg.ASN,
+					HoldTime:        p.cfg.HoldTime,
+					KeepAliveTime:   p.cfg.KeepaliveTime,
+					Password:        p.cfg.Password,
+					CurrentNode:     c.myNode,
+					BFDProfile:      p.cfg.BFDProfile,
+					EBGPMultiHop:    p.cfg.EBGPMultiHop,
+					MinimumTTL:      p.cfg.MinimumTTL,
+					SessionName:     p.cfg.Name,
+					VRFName:         p.cfg.VRF,
+					ImportFilter:    p.cfg.ImportFilter,
+					AddPath:         p.cfg.AddPath,
+					NextHopSelf:     p.cfg.NextHopSelf,
+					AddressFamilies: p.cfg.AddressFamilies,
 				},
 			)
 
@@ -239,6 +532,7 @@ func (c *bgpController) syncPeers(l log.Logger) error {
 				errs++
 			} else {
 				p.session = s
+				p.srcAddr = srcAddr
 				needUpdateAds = true
 			}
 		}
@@ -256,6 +550,31 @@ func (c *bgpController) syncPeers(l log.Logger) error {
 	return nil
 }
 
+// aggregatedPrefix returns the prefix of the given length containing ip.
+// Pools expressed as ranges are split into the CIDRs covering them exactly,
+// and the prefix is never less specific than the CIDR containing ip, so that
+// the aggregation never covers addresses outside of the pool.
+func aggregatedPrefix(ip net.IP, length int, pool *config.Pool) *net.IPNet {
+	bits := 32
+	if ip.To4() == nil {
+		bits = 128
+	}
+	for _, cidr := range pool.CIDR {
+		if !cidr.Contains(ip) {
+			continue
+		}
+		if ones, _ := cidr.Mask.Size(); ones > length {
+			length = ones
+		}
+		break
+	}
+	m := net.CIDRMask(length, bits)
+	return &net.IPNet{
+		IP:   ip.Mask(m),
+		Mask: m,
+	}
+}
+
 func (c *bgpController) syncBFDProfiles(profiles map[string]*config.BFDProfile) error {
 	if len(profiles) == 0 {
 		return nil
@@ -264,34 +583,37 @@ func (c *bgpController) syncBFDProfiles(profiles map[string]*config.BFDProfile)
 	return c.sessionManager.SyncBFDProfiles(profiles)
 }
 
-func (c *bgpController) SetBalancer(l log.Logger, name string, lbIPs []net.IP, pool *config.Pool, _ service, _ *v1.Service) error {
+func (c *bgpController) SetBalancer(l log.Logger, name string, lbIPs []net.IP, pool *config.Pool, _ service, svc *v1.Service) error {
+	c.Lock()
+	defer c.Unlock()
+
 	c.svcAds[name] = nil
+	fallback, isFallback := c.fallbackAds[name]
 	for _, lbIP := range lbIPs {
-		for _, adCfg := range pool.BGPAdvertisements {
+		for _, adCfg := range c.advertisementsFor(pool) {
 			// skipping if this node is not enabled for this advertisement
 			if !adCfg.Nodes[c.myNode] {
 				continue
 			}
-			m := net.CIDRMask(adCfg.AggregationLength, 32)
-			if lbIP.To4() == nil {
-				m = net.CIDRMask(adCfg.AggregationLengthV6, 128)
+			// skipping if announcing only the advertisements falling back to this node
+			if isFallback && !containsAdvertisement(fallback, adCfg) {
+				continue
 			}
-			ad := &bgp.Advertisement{
-				Prefix: &net.IPNet{
-					IP:   lbIP.Mask(m),
-					Mask: m,
-				},
-				LocalPref: adCfg.LocalPref,
+			// skipping if no node of the group of this node has an endpoint
+			if containsAdvertisement(c.topologyAds[name], adCfg) {
+				continue
 			}
-			if len(adCfg.Peers) > 0 {
-				ad.Peers = make([]string, 0, len(adCfg.Peers))
-				ad.Peers = append(ad.Peers, adCfg.Peers...)
+			// skipping if another node is elected to make the advertisement
+			if containsAdvertisement(c.singleNodeAds[name], adCfg) {
+				continue
 			}
-			for comm := range adCfg.Communities {
-				ad.Communities = append(ad.Communities, comm)
+			// skipping if the advertisement is scoped to the other family
+			if !adCfg.MatchesIP(lbIP) {
+				continue
 			}
-			sort.Slice(ad.Communities, func(i, j int) bool { return ad.Communities[i] < ad.Communities[j] })
-			c.svcAds[name] = append(c.svcAds[name], ad)
+			labelCommunities := serviceLabelCommunities(l, name, adCfg, svc)
+			bandwidth := linkBandwidth(adCfg, c.localEndpoints[name])
+			c.svcAds[name] = append(c.svcAds[name], c.advertisementsForIP(lbIP, adCfg, pool, labelCommunities, bandwidth)...)
 		}
 	}
 
@@ -303,6 +625,168 @@ func (c *bgpController) SetBalancer(l log.Logger, name string, lbIPs []net.IP, p
 	return nil
 }
 
+// serviceLabelCommunities returns the communities the labels of the service
+// map to for the given advertisement configuration. A value of the label
+// not mapped to any community is skipped with a warning.
+func serviceLabelCommunities(l log.Logger, name string, adCfg *config.BGPAdvertisement, svc *v1.Service) []uint32 {
+	if adCfg.ServiceLabel == "" || svc == nil {
+		return nil
+	}
+	value, ok := svc.Labels[adCfg.ServiceLabel]
+	if !ok {
+		return nil
+	}
+	comm, ok := adCfg.ServiceLabelCommunities[value]
+	if !ok {
+		level.Warn(l).Log("event", "serviceLabelCommunities", "service", name, "label", adCfg.ServiceLabel, "value", value, "msg", "no community for the value of the label, skipping")
+		return nil
+	}
+	return []uint32{comm}
+}
+
+// linkBandwidth returns the link bandwidth the advertisements of a service
+// with the given number of ready endpoints on this node are made with, 0
+// if the advertisement configuration does not set it or there are none.
+func linkBandwidth(adCfg *config.BGPAdvertisement, endpoints int) uint32 {
+	if adCfg.LinkBandwidthPerEndpoint == 0 || endpoints == 0 {
+		return 0
+	}
+	bandwidth := uint64(adCfg.LinkBandwidthPerEndpoint) * uint64(endpoints)
+	if bandwidth > config.MaxLinkBandwidth {
+		return config.MaxLinkBandwidth
+	}
+	return uint32(bandwidth)
+}
+
+// advertisementsForIP returns the advertisements of the given IP made
+// because of the given advertisement configuration, with the extra
+// communities added and the given link bandwidth. The peers the
+// aggregation length is overridden for get their own advertisement, and
+// each additional aggregation length its own one to the peers of the
+// advertisement.
+func (c *bgpController) advertisementsForIP(lbIP net.IP, adCfg *config.BGPAdvertisement, pool *config.Pool, extraCommunities []uint32, bandwidth uint32) []*bgp.Advertisement {
+	newAd := func(length int, peers []string, peerCommunities map[uint32]bool) *bgp.Advertisement {
+		ad := &bgp.Advertisement{
+			Prefix:    aggregatedPrefix(lbIP, length, pool),
+			LocalPref: adCfg.LocalPref,
+			EVPN:      adCfg.EVPN,
+			Peers:     peers,
+			Origin:    adCfg.Origin,
+			Bandwidth: bandwidth,
+			Color:     adCfg.Color,
+		}
+		for comm := range adCfg.Communities {
+			ad.Communities = append(ad.Communities, comm)
+		}
+		for _, comm := range extraCommunities {
+			if !adCfg.Communities[comm] {
+				ad.Communities = append(ad.Communities, comm)
+			}
+		}
+		for comm := range peerCommunities {
+			if !adCfg.Communities[comm] && !containsCommunity(extraCommunities, comm) {
+				ad.Communities = append(ad.Communities, comm)
+			}
+		}
+		sort.Slice(ad.Communities, func(i, j int) bool { return ad.Communities[i] < ad.Communities[j] })
+		return ad
+	}
+	lengthFor := func(v4, v6 int) int {
+		if lbIP.To4() == nil {
+			return v6
+		}
+		return v4
+	}
+
+	// Only the aggregates suppress the more specific prefixes, not the
+	// additional aggregation lengths.
+	summary := func(ad *bgp.Advertisement) *bgp.Advertisement {
+		ad.SummaryOnly = adCfg.SummaryOnly
+		return ad
+	}
+
+	length := lengthFor(adCfg.AggregationLength, adCfg.AggregationLengthV6)
+	additional := adCfg.AdditionalAggregationLengths
+	if lbIP.To4() == nil {
+		additional = adCfg.AdditionalAggregationLengthsV6
+	}
+	var adPeers []string
+	if len(adCfg.Peers) > 0 {
+		adPeers = make([]string, 0, len(adCfg.Peers))
+		adPeers = append(adPeers, adCfg.Peers...)
+	}
+	if len(adCfg.PeerAggregations) == 0 && len(adCfg.PeerCommunities) == 0 {
+		res := []*bgp.Advertisement{summary(newAd(length, adPeers, nil))}
+		for _, l := range additional {
+			res = append(res, newAd(l, adPeers, nil))
+		}
+		return res
+	}
+
+	// An advertisement with no peers goes to all of them, so the peers not
+	// overriding the aggregation length or the communities must be listed
+	// explicitly.
+	peers := adCfg.Peers
+	if len(peers) == 0 {
+		peers = make([]string, 0, len(c.peers))
+		for _, p := range c.peers {
+			peers = append(peers, p.cfg.Name)
+		}
+	}
+	res := []*bgp.Advertisement{}
+	var others, extraPeers []string
+	var peerExtra []*bgp.Advertisement
+	for _, p := range peers {
+		agg, hasAgg := adCfg.PeerAggregations[p]
+		comms, hasComms := adCfg.PeerCommunities[p]
+		if !hasComms {
+			extraPeers = append(extraPeers, p)
+		}
+		if !hasAgg && !hasComms {
+			others = append(others, p)
+			continue
+		}
+		peerLength := length
+		if hasAgg {
+			peerLength = lengthFor(agg.AggregationLength, agg.AggregationLengthV6)
+		}
+		res = append(res, summary(newAd(peerLength, []string{p}, comms)))
+		// The additional aggregation lengths are advertised to the peer
+		// with its own communities too.
+		if hasComms {
+			for _, l := range additional {
+				peerExtra = append(peerExtra, newAd(l, []string{p}, comms))
+			}
+		}
+	}
+	if len(others) > 0 {
+		sort.Strings(others)
+		res = append(res, summary(newAd(length, others, nil)))
+	}
+	switch {
+	case len(adCfg.PeerCommunities) == 0:
+		extraPeers = adPeers
+	case len(extraPeers) == 0:
+		// All the peers have their own communities.
+		return append(res, peerExtra...)
+	default:
+		sort.Strings(extraPeers)
+	}
+	for _, l := range additional {
+		res = append(res, newAd(l, extraPeers, nil))
+	}
+	return append(res, peerExtra...)
+}
+
+func containsCommunity(communities []uint32, c uint32) bool {
+	for _, comm := range communities {
+		if comm == c {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *bgpController) updateAds() error {
 	var allAds []*bgp.Advertisement
 	for _, ads := range c.svcAds {
@@ -314,18 +798,79 @@ func (c *bgpController) updateAds() error {
 		// and detecting conflicting advertisements.
 		allAds = append(allAds, ads...)
 	}
+	for _, ads := range c.nodeAds {
+		allAds = append(allAds, ads...)
+	}
 	for _, peer := range c.peers {
 		if peer.session == nil {
 			continue
 		}
-		if err := peer.session.Set(allAds...); err != nil {
+		if err := peer.session.Set(summarized(familyAds(allAds, peer.cfg), peer.cfg.Name)...); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// familyAds returns the advertisements without the ones of the families
+// not advertised to the peer.
+func familyAds(ads []*bgp.Advertisement, p *config.Peer) []*bgp.Advertisement {
+	if len(p.AddressFamilies) == 0 {
+		return ads
+	}
+	res := make([]*bgp.Advertisement, 0, len(ads))
+	for _, ad := range ads {
+		if p.AdvertisesFamily(ipfamily.ForAddress(ad.Prefix.IP)) {
+			res = append(res, ad)
+		}
+	}
+	return res
+}
+
+// summarized returns the advertisements without the ones to the peer whose
+// prefix is more specific than the prefix of a summary only advertisement
+// to the same peer.
+func summarized(ads []*bgp.Advertisement, peer string) []*bgp.Advertisement {
+	var summaries []*net.IPNet
+	for _, ad := range ads {
+		if ad.SummaryOnly && ad.MatchesPeer(peer) {
+			summaries = append(summaries, ad.Prefix)
+		}
+	}
+	if len(summaries) == 0 {
+		return ads
+	}
+	res := make([]*bgp.Advertisement, 0, len(ads))
+	for _, ad := range ads {
+		if ad.MatchesPeer(peer) && coveredBy(ad.Prefix, summaries) {
+			continue
+		}
+		res = append(res, ad)
+	}
+	return res
+}
+
+// coveredBy tells if the prefix is more specific than one of the summaries
+// and contained in it.
+func coveredBy(prefix *net.IPNet, summaries []*net.IPNet) bool {
+	ones, bits := prefix.Mask.Size()
+	for _, s := range summaries {
+		sOnes, sBits := s.Mask.Size()
+		if bits == sBits && ones > sOnes && s.Contains(prefix.IP) {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *bgpController) DeleteBalancer(l log.Logger, name, reason string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.fallbackAds, name)
+	delete(c.topologyAds, name)
+	delete(c.singleNodeAds, name)
+	delete(c.localEndpoints, name)
 	if _, ok := c.svcAds[name]; !ok {
 		return nil
 	}
@@ -334,20 +879,232 @@ func (c *bgpController) DeleteBalancer(l log.Logger, name, reason string) error
 }
 
 func (c *bgpController) SetNode(l log.Logger, node *v1.Node) error {
+	c.Lock()
+	defer c.Unlock()
+
 	nodeLabels := node.Labels
 	if nodeLabels == nil {
 		nodeLabels = map[string]string{}
 	}
 	ns := labels.Set(nodeLabels)
-	if c.nodeLabels != nil && labels.Equals(c.nodeLabels, ns) {
-		// Node labels unchanged, no action required.
+	addrs := nodeAddresses(node, c.addressTypes)
+	labelsChanged := c.nodeLabels == nil || !labels.Equals(c.nodeLabels, ns)
+	addrsChanged := !sameIPs(c.nodeAddrs, addrs)
+	if !labelsChanged && !addrsChanged {
+		// Node labels and addresses unchanged, no action required.
 		return nil
 	}
 	c.nodeLabels = ns
-	level.Info(l).Log("event", "nodeLabelsChanged", "msg", "Node labels changed, resyncing BGP peers")
+	if addrsChanged {
+		c.nodeAddrs = addrs
+		level.Info(l).Log("event", "nodeAddressesChanged", "addresses", fmt.Sprint(addrs), "msg", "Node addresses changed, restarting the BGP sessions sourced from them")
+		c.restartMovedSessions(l)
+	}
+	if labelsChanged {
+		level.Info(l).Log("event", "nodeLabelsChanged", "msg", "Node labels changed, resyncing BGP peers")
+	}
 	return c.syncPeers(l)
 }
 
+// peerSelectsNode tells if the session with the peer is established from
+// the node with the given labels.
+func peerSelectsNode(p *config.Peer, nodeLabels labels.Set) bool {
+	if len(p.NodeSelectors) == 0 {
+		return true
+	}
+	for _, ns := range p.NodeSelectors {
+		if ns.Matches(nodeLabels) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceAddressFor returns the source address of the session with the peer,
+// its source address when set. The peers in a VRF otherwise use the address
+// of the VRF of the family of the peer, the other ones the address of the
+// node.
+func (c *bgpController) sourceAddressFor(p *config.Peer) (net.IP, error) {
+	if p.SrcAddr != nil {
+		return p.SrcAddr, nil
+	}
+	if p.VRF == "" {
+		return addressForFamily(c.nodeAddrs, p.Addr), nil
+	}
+	addrs, err := vrfAddresses(p.VRF)
+	if err != nil {
+		return nil, err
+	}
+	// If the VRF has no address of the family of the peer, FRR picks
+	// the source address.
+	return addressForFamily(addrs, p.Addr), nil
+}
+
+// validateSourceAddresses checks that the source addresses set on the peers
+// in a VRF this node establishes sessions with belong to the VRF on the
+// node. The peers whose VRF is not on the node are left to fail when their
+// session is established.
+func (c *bgpController) validateSourceAddresses(peers map[string]*config.Peer) error {
+	for _, p := range peers {
+		if p.VRF == "" || p.SrcAddr == nil || !peerSelectsNode(p, c.nodeLabels) {
+			continue
+		}
+		if c.peerSelector != nil && !c.peerSelector.Matches(labels.Set(p.Labels)) {
+			continue
+		}
+		addrs, err := vrfAddresses(p.VRF)
+		if err != nil {
+			continue
+		}
+		found := false
+		for _, addr := range addrs {
+			if addr.Equal(p.SrcAddr) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("peer %s has source address %s, not found in vrf %s on the node", p.Name, p.SrcAddr, p.VRF)
+		}
+	}
+	return nil
+}
+
+// restartMovedSessions closes the sessions whose source address changed
+// since they were established, for syncPeers to establish them again from
+// the new one. It tells if any session was closed. The caller must hold the
+// lock.
+func (c *bgpController) restartMovedSessions(l log.Logger) bool {
+	restarted := false
+	for _, p := range c.peers {
+		if p.session == nil {
+			continue
+		}
+		addr, err := c.sourceAddressFor(p.cfg)
+		if err != nil || addr.Equal(p.srcAddr) {
+			continue
+		}
+		level.Info(l).Log("event", "sourceAddressChanged", "peer", p.cfg.Addr, "old", p.srcAddr, "new", addr, "msg", "source address changed, restarting the BGP session")
+		if err := p.session.Close(); err != nil {
+			level.Error(l).Log("op", "restartMovedSessions", "error", err, "peer", p.cfg.Addr, "msg", "failed to shut down BGP session")
+		}
+		p.session = nil
+		restarted = true
+	}
+	return restarted
+}
+
+// watchSourceAddresses periodically restarts the sessions whose source
+// address changed, as the addresses of the VRFs change without any event
+// notifying the speaker, until stopCh is closed.
+func (c *bgpController) watchSourceAddresses(l log.Logger, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(sourceAddressCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+		c.Lock()
+		if c.restartMovedSessions(l) {
+			if err := c.syncPeers(l); err != nil {
+				level.Error(l).Log("op", "watchSourceAddresses", "error", err, "msg", "failed to restart the BGP sessions")
+			}
+		}
+		c.Unlock()
+	}
+}
+
+// syncNodeAdvertisements aligns the prefixes advertised by this node with
+// the node advertisements selecting it. The ones with a health check are
+// advertised only after the check succeeds.
+func (c *bgpController) syncNodeAdvertisements(l log.Logger, advs map[string]*config.NodeAdvertisement) error {
+	needUpdateAds := false
+	for name, current := range c.nodeAdvs {
+		if adv, ok := advs[name]; ok && adv.Nodes[c.myNode] && reflect.DeepEqual(adv, current) {
+			continue
+		}
+		if checker, ok := c.nodeChecks[name]; ok {
+			checker.stop()
+			delete(c.nodeChecks, name)
+		}
+		if _, ok := c.nodeAds[name]; ok {
+			delete(c.nodeAds, name)
+			needUpdateAds = true
+		}
+		delete(c.nodeAdvs, name)
+		level.Info(l).Log("event", "nodeAdvertisementRemoved", "advertisement", name, "msg", "node advertisement not applied to this node anymore")
+	}
+
+	for name, adv := range advs {
+		if !adv.Nodes[c.myNode] {
+			continue
+		}
+		if _, ok := c.nodeAdvs[name]; ok {
+			continue
+		}
+		c.nodeAdvs[name] = adv
+		level.Info(l).Log("event", "nodeAdvertisementAdded", "advertisement", name, "msg", "node advertisement applied to this node")
+		if adv.HealthCheck == nil {
+			c.nodeAds[name] = nodeAdsFor(adv)
+			needUpdateAds = true
+			continue
+		}
+		checker := newNodeHealthChecker(name, adv.HealthCheck)
+		c.nodeChecks[name] = checker
+		go checker.run(func(h *nodeHealthChecker, healthy bool) {
+			c.setNodeAdvertisementHealth(l, h, healthy)
+		})
+	}
+
+	if needUpdateAds {
+		return c.updateAds()
+	}
+	return nil
+}
+
+// setNodeAdvertisementHealth is called by the health checks when their
+// outcome changes, to start or stop advertising the prefixes.
+func (c *bgpController) setNodeAdvertisementHealth(l log.Logger, checker *nodeHealthChecker, healthy bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.nodeChecks[checker.name] != checker {
+		// The node advertisement changed in the meanwhile.
+		return
+	}
+	if healthy {
+		c.nodeAds[checker.name] = nodeAdsFor(c.nodeAdvs[checker.name])
+	} else {
+		delete(c.nodeAds, checker.name)
+	}
+	level.Info(l).Log("event", "nodeAdvertisementHealthChanged", "advertisement", checker.name, "healthy", healthy, "msg", "node health check changed, updating BGP advertisements")
+	if err := c.updateAds(); err != nil {
+		level.Error(l).Log("op", "updateAds", "error", err, "msg", "failed to update BGP advertisements")
+	}
+}
+
+func nodeAdsFor(adv *config.NodeAdvertisement) []*bgp.Advertisement {
+	res := make([]*bgp.Advertisement, 0, len(adv.Prefixes))
+	for _, prefix := range adv.Prefixes {
+		ad := &bgp.Advertisement{
+			Prefix:    prefix,
+			LocalPref: adv.LocalPref,
+		}
+		if len(adv.Peers) > 0 {
+			ad.Peers = make([]string, 0, len(adv.Peers))
+			ad.Peers = append(ad.Peers, adv.Peers...)
+		}
+		for comm := range adv.Communities {
+			ad.Communities = append(ad.Communities, comm)
+		}
+		sort.Slice(ad.Communities, func(i, j int) bool { return ad.Communities[i] < ad.Communities[j] })
+		res = append(res, ad)
+	}
+	return res
+}
+
 // Create a new 'bgp.SessionManager' of type 'bgpType'.
 var newBGP = func(bgpType bgpImplementation, l log.Logger, logLevel logging.Level) bgp.SessionManager {
 	switch bgpType {
@@ -360,8 +1117,70 @@ var newBGP = func(bgpType bgpImplementation, l log.Logger, logLevel logging.Leve
 	}
 }
 
-func poolMatchesNodeBGP(pool *config.Pool, node string) bool {
-	for _, adv := range pool.BGPAdvertisements {
+// frrReadinessHandler reports the speaker as not ready while the FRR
+// reloader persistently fails to apply the configuration.
+func frrReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := bgpfrr.ReloaderHealthy(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// defaultBGPAdvertisement returns the advertisement applied by the given node
+// to the pools not referenced by any advertisement.
+func defaultBGPAdvertisement(node string, localPref uint, communities string) (*config.BGPAdvertisement, error) {
+	if localPref > math.MaxUint32 {
+		return nil, fmt.Errorf("invalid local preference %d", localPref)
+	}
+	res := &config.BGPAdvertisement{
+		Name:                "default",
+		AggregationLength:   32,
+		AggregationLengthV6: 128,
+		LocalPref:           uint32(localPref),
+		Communities:         map[uint32]bool{},
+		Nodes:               map[string]bool{node: true},
+	}
+	for _, c := range strings.Split(communities, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		v, err := config.ParseCommunity(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid community %q: %w", c, err)
+		}
+		res.Communities[v] = true
+	}
+	return res, nil
+}
+
+// advertisementsFor returns the BGP advertisements of the pool, or the
+// default one, if enabled, for the pools not referenced by any advertisement.
+func (c *bgpController) advertisementsFor(pool *config.Pool) []*config.BGPAdvertisement {
+	if c.defaultAdv == nil || !usesDefaultAdvertisement(pool) {
+		return pool.BGPAdvertisements
+	}
+	return []*config.BGPAdvertisement{c.defaultAdv}
+}
+
+func usesDefaultAdvertisement(pool *config.Pool) bool {
+	return len(pool.BGPAdvertisements) == 0 && len(pool.L2Advertisements) == 0
+}
+
+func containsAdvertisement(ads []*config.BGPAdvertisement, ad *config.BGPAdvertisement) bool {
+	for _, a := range ads {
+		if a == ad {
+			return true
+		}
+	}
+	return false
+}
+
+func poolMatchesNodeBGP(advertisements []*config.BGPAdvertisement, node string) bool {
+	for _, adv := range advertisements {
 		if adv.Nodes[node] {
 			return true
 		}
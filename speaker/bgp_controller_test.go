@@ -8,10 +8,13 @@ import (
 	"net"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"go.universe.tf/metallb/internal/bgp"
 	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/ipfamily"
 	"go.universe.tf/metallb/internal/k8s/controllers"
 	"go.universe.tf/metallb/internal/k8s/epslices"
 	"go.universe.tf/metallb/internal/logging"
@@ -19,6 +22,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/google/go-cmp/cmp"
+	ptu "github.com/prometheus/client_golang/prometheus/testutil"
 	v1 "k8s.io/api/core/v1"
 	discovery "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -103,6 +107,8 @@ type fakeBGPSessionManager struct {
 	sync.Mutex
 	// peer IP -> advertisements
 	gotAds map[string][]*bgp.Advertisement
+	// peer IP -> source address of the session
+	gotSources map[string]net.IP
 }
 
 func (f *fakeBGPSessionManager) NewSession(_ log.Logger, args bgp.SessionParameters) (bgp.Session, error) {
@@ -116,6 +122,10 @@ func (f *fakeBGPSessionManager) NewSession(_ log.Logger, args bgp.SessionParamet
 	// Nil because we haven't programmed any routes for it yet, but
 	// the key now exists in the map.
 	f.gotAds[args.PeerAddress] = nil
+	if f.gotSources == nil {
+		f.gotSources = map[string]net.IP{}
+	}
+	f.gotSources[args.PeerAddress] = args.SourceAddress
 	return &fakeSession{
 		f:    f,
 		addr: args.PeerAddress,
@@ -2055,3 +2065,1456 @@ func TestNodeSelectors(t *testing.T) {
 		}
 	}
 }
+
+func TestPeerSelector(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+		PeerSelector:  mustSelector("segment=edge"),
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	pools := map[string]*config.Pool{
+		"default": {
+			CIDR: []*net.IPNet{ipnet("1.2.3.0/24")},
+			BGPAdvertisements: []*config.BGPAdvertisement{
+				{
+					AggregationLength: 32,
+					Nodes:             map[string]bool{"pandora": true},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		desc    string
+		config  *config.Config
+		wantAds map[string][]*bgp.Advertisement
+	}{
+		{
+			desc: "Only the matching peer",
+			config: &config.Config{
+				Peers: map[string]*config.Peer{
+					"peer1": {
+						Addr:          net.ParseIP("1.2.3.4"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						Labels:        map[string]string{"segment": "edge"},
+					},
+					"peer2": {
+						Addr:          net.ParseIP("2.3.4.5"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						Labels:        map[string]string{"segment": "core"},
+					},
+					"peer3": {
+						Addr:          net.ParseIP("3.4.5.6"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+				},
+				Pools: &config.Pools{ByName: pools},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+			},
+		},
+
+		{
+			desc: "Peer label changed so it no longer matches",
+			config: &config.Config{
+				Peers: map[string]*config.Peer{
+					"peer1": {
+						Addr:          net.ParseIP("1.2.3.4"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						Labels:        map[string]string{"segment": "core"},
+					},
+					"peer2": {
+						Addr:          net.ParseIP("2.3.4.5"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						Labels:        map[string]string{"segment": "edge"},
+					},
+				},
+				Pools: &config.Pools{ByName: pools},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"2.3.4.5:0": nil,
+			},
+		},
+	}
+
+	l := log.NewNopLogger()
+	for _, test := range tests {
+		if c.SetConfig(l, test.config) == controllers.SyncStateError {
+			t.Errorf("%q: SetConfig failed", test.desc)
+		}
+
+		gotAds := b.sessionManager.Ads()
+		sortAds(test.wantAds)
+		sortAds(gotAds)
+		if diff := cmp.Diff(test.wantAds, gotAds); diff != "" {
+			t.Errorf("%q: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestIPFamilyAdvertisements(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24"), ipnet("2001:db8::/64")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:   32,
+						AggregationLengthV6: 128,
+						LocalPref:           100,
+						Nodes:               map[string]bool{"pandora": true},
+						IPFamily:            ipfamily.IPv4,
+					},
+					{
+						AggregationLength:   32,
+						AggregationLengthV6: 128,
+						LocalPref:           200,
+						Communities:         map[uint32]bool{1234: true},
+						Nodes:               map[string]bool{"pandora": true},
+						IPFamily:            ipfamily.IPv6,
+					},
+				},
+			},
+		}},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Cluster",
+		},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{
+					{IP: "10.20.30.1"},
+					{IP: "2001:db8::1"},
+				},
+			},
+		},
+	}
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{
+						{
+							IP:       "2.3.4.5",
+							NodeName: pointer.StrPtr("pandora"),
+						},
+					},
+				},
+			},
+		},
+		Type: epslices.Eps,
+	}
+	if c.SetBalancer(l, "test1", svc, eps) != controllers.SyncStateSuccess {
+		t.Fatalf("SetBalancer failed")
+	}
+
+	wantAds := map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": {
+			{
+				Prefix:    ipnet("10.20.30.1/32"),
+				LocalPref: 100,
+			},
+			{
+				Prefix:      ipnet("2001:db8::1/128"),
+				LocalPref:   200,
+				Communities: []uint32{1234},
+			},
+		},
+	}
+	gotAds := b.sessionManager.Ads()
+	sortAds(wantAds)
+	sortAds(gotAds)
+	if diff := cmp.Diff(wantAds, gotAds); diff != "" {
+		t.Errorf("unexpected advertisement state (-want +got)\n%s", diff)
+	}
+}
+
+func TestFallbackToSelectedNodes(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:       32,
+						AggregationLengthV6:     128,
+						LocalPref:               100,
+						Nodes:                   map[string]bool{"pandora": true},
+						FallbackToSelectedNodes: true,
+					},
+					{
+						AggregationLength:   32,
+						AggregationLengthV6: 128,
+						LocalPref:           200,
+						Nodes:               map[string]bool{"pandora": true},
+					},
+				},
+			},
+		}},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Local",
+		},
+		Status: statusAssigned("10.20.30.1"),
+	}
+	epsOn := func(nodes ...string) epslices.EpsOrSlices {
+		addresses := []v1.EndpointAddress{}
+		for _, node := range nodes {
+			addresses = append(addresses, v1.EndpointAddress{IP: "2.3.4.5", NodeName: pointer.StrPtr(node)})
+		}
+		return epslices.EpsOrSlices{
+			EpVal: &v1.Endpoints{
+				Subsets: []v1.EndpointSubset{{Addresses: addresses}},
+			},
+			Type: epslices.Eps,
+		}
+	}
+
+	tests := []struct {
+		desc    string
+		eps     epslices.EpsOrSlices
+		wantAds map[string][]*bgp.Advertisement
+	}{
+		{
+			desc: "local endpoint",
+			eps:  epsOn("pandora"),
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{Prefix: ipnet("10.20.30.1/32"), LocalPref: 100},
+					{Prefix: ipnet("10.20.30.1/32"), LocalPref: 200},
+				},
+			},
+		},
+		{
+			desc: "endpoints only on not selected nodes",
+			eps:  epsOn("iris"),
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{Prefix: ipnet("10.20.30.1/32"), LocalPref: 100},
+				},
+			},
+		},
+		{
+			desc: "no endpoints",
+			eps:  epsOn(),
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if c.SetBalancer(l, "test1", svc, test.eps) != controllers.SyncStateSuccess {
+			t.Fatalf("%s: SetBalancer failed", test.desc)
+		}
+		gotAds := b.sessionManager.Ads()
+		sortAds(test.wantAds)
+		sortAds(gotAds)
+		if diff := cmp.Diff(test.wantAds, gotAds); diff != "" {
+			t.Errorf("%s: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestTopologyKey(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	nodes := map[string]bool{"pandora": true, "iris": true, "zeus": true}
+	groups := map[string]string{"pandora": "r1", "iris": "r1", "zeus": "r2"}
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:   24,
+						AggregationLengthV6: 128,
+						LocalPref:           100,
+						Nodes:               nodes,
+						TopologyKey:         "rack",
+						NodeGroups:          groups,
+					},
+					{
+						AggregationLength:   32,
+						AggregationLengthV6: 128,
+						LocalPref:           200,
+						Nodes:               nodes,
+					},
+				},
+			},
+			"racks": {
+				CIDR: []*net.IPNet{ipnet("10.20.40.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:   24,
+						AggregationLengthV6: 128,
+						Nodes:               nodes,
+						TopologyKey:         "rack",
+						NodeGroups:          groups,
+					},
+				},
+			},
+		}},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	svc := func(ip string) *v1.Service {
+		return &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: "Cluster",
+			},
+			Status: statusAssigned(ip),
+		}
+	}
+	epsOn := func(nodes ...string) epslices.EpsOrSlices {
+		addresses := []v1.EndpointAddress{}
+		for _, node := range nodes {
+			addresses = append(addresses, v1.EndpointAddress{IP: "2.3.4.5", NodeName: pointer.StrPtr(node)})
+		}
+		return epslices.EpsOrSlices{
+			EpVal: &v1.Endpoints{
+				Subsets: []v1.EndpointSubset{{Addresses: addresses}},
+			},
+			Type: epslices.Eps,
+		}
+	}
+
+	tests := []struct {
+		desc    string
+		svcs    map[string]*v1.Service
+		eps     map[string]epslices.EpsOrSlices
+		wantAds map[string][]*bgp.Advertisement
+	}{
+		{
+			desc: "endpoint in the group of the node",
+			svcs: map[string]*v1.Service{"test1": svc("10.20.30.1")},
+			eps:  map[string]epslices.EpsOrSlices{"test1": epsOn("iris")},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{Prefix: ipnet("10.20.30.0/24"), LocalPref: 100},
+					{Prefix: ipnet("10.20.30.1/32"), LocalPref: 200},
+				},
+			},
+		},
+		{
+			desc: "endpoint in another group",
+			svcs: map[string]*v1.Service{"test1": svc("10.20.30.1")},
+			eps:  map[string]epslices.EpsOrSlices{"test1": epsOn("zeus")},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{Prefix: ipnet("10.20.30.1/32"), LocalPref: 200},
+				},
+			},
+		},
+		{
+			desc: "aggregate kept while a service of the group has an endpoint in it",
+			svcs: map[string]*v1.Service{"test1": svc("10.20.30.1"), "test2": svc("10.20.30.2")},
+			eps:  map[string]epslices.EpsOrSlices{"test1": epsOn("zeus"), "test2": epsOn("pandora")},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{Prefix: ipnet("10.20.30.1/32"), LocalPref: 200},
+					{Prefix: ipnet("10.20.30.0/24"), LocalPref: 100},
+					{Prefix: ipnet("10.20.30.2/32"), LocalPref: 200},
+				},
+			},
+		},
+		{
+			desc: "aggregate withdrawn when no service of the group has an endpoint in it",
+			svcs: map[string]*v1.Service{"test1": svc("10.20.30.1"), "test2": svc("10.20.30.2")},
+			eps:  map[string]epslices.EpsOrSlices{"test1": epsOn("zeus"), "test2": epsOn("zeus")},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{Prefix: ipnet("10.20.30.1/32"), LocalPref: 200},
+					{Prefix: ipnet("10.20.30.2/32"), LocalPref: 200},
+				},
+			},
+		},
+		{
+			desc: "only advertisements with a topology key, endpoint in another group",
+			svcs: map[string]*v1.Service{"test1": svc("10.20.30.1"), "test2": svc("10.20.40.1")},
+			eps:  map[string]epslices.EpsOrSlices{"test1": epsOn("zeus"), "test2": epsOn("zeus")},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{Prefix: ipnet("10.20.30.1/32"), LocalPref: 200},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		for name, svc := range test.svcs {
+			if c.SetBalancer(l, name, svc, test.eps[name]) != controllers.SyncStateSuccess {
+				t.Fatalf("%s: SetBalancer failed", test.desc)
+			}
+		}
+		gotAds := b.sessionManager.Ads()
+		sortAds(test.wantAds)
+		sortAds(gotAds)
+		if diff := cmp.Diff(test.wantAds, gotAds); diff != "" {
+			t.Errorf("%s: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestSingleNode(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	speakers := &fakeSpeakerList{}
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+		SList:         speakers,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:   32,
+						AggregationLengthV6: 128,
+						Nodes:               map[string]bool{"pandora": true, "iris": true, "zeus": true},
+						SingleNode:          true,
+					},
+				},
+			},
+		}},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	svc := func(ip string, policy v1.ServiceExternalTrafficPolicyType) *v1.Service {
+		return &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: policy,
+			},
+			Status: statusAssigned(ip),
+		}
+	}
+	epsOn := func(nodes ...string) epslices.EpsOrSlices {
+		addresses := []v1.EndpointAddress{}
+		for _, node := range nodes {
+			addresses = append(addresses, v1.EndpointAddress{IP: "2.3.4.5", NodeName: pointer.StrPtr(node)})
+		}
+		return epslices.EpsOrSlices{
+			EpVal: &v1.Endpoints{
+				Subsets: []v1.EndpointSubset{{Addresses: addresses}},
+			},
+			Type: epslices.Eps,
+		}
+	}
+
+	// Among the three nodes, zeus is elected for 10.20.30.3 and iris for
+	// 10.20.30.1. Without zeus, pandora is elected for 10.20.30.3.
+	tests := []struct {
+		desc     string
+		speakers map[string]bool
+		svc      *v1.Service
+		eps      epslices.EpsOrSlices
+		wantAds  map[string][]*bgp.Advertisement
+	}{
+		{
+			desc:     "another node elected",
+			speakers: map[string]bool{"pandora": true, "iris": true, "zeus": true},
+			svc:      svc("10.20.30.3", "Cluster"),
+			eps:      epsOn("iris"),
+			wantAds:  map[string][]*bgp.Advertisement{"1.2.3.4:0": nil},
+		},
+		{
+			desc:     "elected once the other node fails",
+			speakers: map[string]bool{"pandora": true, "iris": true},
+			svc:      svc("10.20.30.3", "Cluster"),
+			eps:      epsOn("iris"),
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {{Prefix: ipnet("10.20.30.3/32")}},
+			},
+		},
+		{
+			desc:     "another node elected for another ip",
+			speakers: map[string]bool{"pandora": true, "iris": true},
+			svc:      svc("10.20.30.1", "Cluster"),
+			eps:      epsOn("iris"),
+			wantAds:  map[string][]*bgp.Advertisement{"1.2.3.4:0": nil},
+		},
+		{
+			desc:     "local traffic policy, elected among the nodes with an endpoint",
+			speakers: map[string]bool{"pandora": true, "iris": true, "zeus": true},
+			svc:      svc("10.20.30.3", "Local"),
+			eps:      epsOn("pandora", "iris"),
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {{Prefix: ipnet("10.20.30.3/32")}},
+			},
+		},
+		{
+			desc:     "local traffic policy, another node with an endpoint elected",
+			speakers: map[string]bool{"pandora": true, "iris": true, "zeus": true},
+			svc:      svc("10.20.30.3", "Local"),
+			eps:      epsOn("pandora", "zeus"),
+			wantAds:  map[string][]*bgp.Advertisement{"1.2.3.4:0": nil},
+		},
+	}
+
+	for _, test := range tests {
+		speakers.speakers = test.speakers
+		if c.SetBalancer(l, "test1", test.svc, test.eps) != controllers.SyncStateSuccess {
+			t.Fatalf("%s: SetBalancer failed", test.desc)
+		}
+		gotAds := b.sessionManager.Ads()
+		sortAds(test.wantAds)
+		sortAds(gotAds)
+		if diff := cmp.Diff(test.wantAds, gotAds); diff != "" {
+			t.Errorf("%s: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestServiceLabelCommunities(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:       32,
+						AggregationLengthV6:     128,
+						Communities:             map[uint32]bool{1234: true},
+						Nodes:                   map[string]bool{"pandora": true},
+						ServiceLabel:            "team",
+						ServiceLabelCommunities: map[string]uint32{"blue": 2345, "red": 1234},
+					},
+				},
+			},
+		}},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{{IP: "2.3.4.5", NodeName: pointer.StrPtr("pandora")}},
+				},
+			},
+		},
+		Type: epslices.Eps,
+	}
+
+	tests := []struct {
+		desc            string
+		labels          map[string]string
+		wantCommunities []uint32
+	}{
+		{
+			desc:            "mapped value",
+			labels:          map[string]string{"team": "blue"},
+			wantCommunities: []uint32{1234, 2345},
+		},
+		{
+			desc:            "mapped to a community of the advertisement",
+			labels:          map[string]string{"team": "red"},
+			wantCommunities: []uint32{1234},
+		},
+		{
+			desc:            "value not mapped",
+			labels:          map[string]string{"team": "green"},
+			wantCommunities: []uint32{1234},
+		},
+		{
+			desc:            "no label",
+			wantCommunities: []uint32{1234},
+		},
+	}
+
+	for _, test := range tests {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Labels: test.labels},
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: "Cluster",
+			},
+			Status: statusAssigned("10.20.30.1"),
+		}
+		if c.SetBalancer(l, "test1", svc, eps) != controllers.SyncStateSuccess {
+			t.Fatalf("%s: SetBalancer failed", test.desc)
+		}
+		wantAds := map[string][]*bgp.Advertisement{
+			"1.2.3.4:0": {
+				{Prefix: ipnet("10.20.30.1/32"), Communities: test.wantCommunities},
+			},
+		}
+		if diff := cmp.Diff(wantAds, b.sessionManager.Ads()); diff != "" {
+			t.Errorf("%s: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestNodeAdvertisements(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	var healthy atomic.Bool
+	toRestore := probeNodeHealth
+	probeNodeHealth = func(_ *config.NodeHealthCheck) error {
+		if healthy.Load() {
+			return nil
+		}
+		return errors.New("unhealthy")
+	}
+	defer func() {
+		probeNodeHealth = toRestore
+	}()
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{}},
+		NodeAdvertisements: map[string]*config.NodeAdvertisement{
+			"static": {
+				Name:        "static",
+				Prefixes:    []*net.IPNet{ipnet("10.100.0.1/32")},
+				Communities: map[uint32]bool{1234: true},
+				Nodes:       map[string]bool{"pandora": true},
+			},
+			"dns": {
+				Name:     "dns",
+				Prefixes: []*net.IPNet{ipnet("10.100.0.53/32")},
+				Nodes:    map[string]bool{"pandora": true},
+				HealthCheck: &config.NodeHealthCheck{
+					Address:          "127.0.0.1:53",
+					Interval:         10 * time.Millisecond,
+					Timeout:          10 * time.Millisecond,
+					FailureThreshold: 1,
+				},
+			},
+			"othernode": {
+				Name:     "othernode",
+				Prefixes: []*net.IPNet{ipnet("10.100.0.2/32")},
+				Nodes:    map[string]bool{"iris": true},
+			},
+		},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	staticAd := &bgp.Advertisement{
+		Prefix:      ipnet("10.100.0.1/32"),
+		Communities: []uint32{1234},
+	}
+	dnsAd := &bgp.Advertisement{
+		Prefix: ipnet("10.100.0.53/32"),
+	}
+
+	waitForAds := func(desc string, want map[string][]*bgp.Advertisement) {
+		t.Helper()
+		sortAds(want)
+		var diff string
+		for i := 0; i < 100; i++ {
+			gotAds := b.sessionManager.Ads()
+			sortAds(gotAds)
+			if diff = cmp.Diff(want, gotAds); diff == "" {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("%s: unexpected advertisement state (-want +got)\n%s", desc, diff)
+	}
+
+	waitForAds("health check failing", map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": {staticAd},
+	})
+
+	healthy.Store(true)
+	waitForAds("health check succeeding", map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": {staticAd, dnsAd},
+	})
+
+	healthy.Store(false)
+	waitForAds("health check failing again", map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": {staticAd},
+	})
+
+	cfg.NodeAdvertisements = nil
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+	waitForAds("node advertisements removed", map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": nil,
+	})
+}
+
+func TestPeerAggregations(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"reflector": {
+				Name:          "reflector",
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+			"transit": {
+				Name:          "transit",
+				Addr:          net.ParseIP("1.2.3.5"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:   32,
+						AggregationLengthV6: 128,
+						LocalPref:           100,
+						Nodes:               map[string]bool{"pandora": true},
+						PeerAggregations: map[string]*config.PeerAggregation{
+							"transit": {
+								AggregationLength:   24,
+								AggregationLengthV6: 128,
+							},
+						},
+					},
+				},
+			},
+		}},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Cluster",
+		},
+		Status: statusAssigned("10.20.30.1"),
+	}
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{
+						{
+							IP:       "2.3.4.5",
+							NodeName: pointer.StrPtr("pandora"),
+						},
+					},
+				},
+			},
+		},
+		Type: epslices.Eps,
+	}
+	if c.SetBalancer(l, "test1", svc, eps) != controllers.SyncStateSuccess {
+		t.Fatalf("SetBalancer failed")
+	}
+
+	ads := []*bgp.Advertisement{
+		{
+			Prefix:    ipnet("10.20.30.0/24"),
+			LocalPref: 100,
+			Peers:     []string{"transit"},
+		},
+		{
+			Prefix:    ipnet("10.20.30.1/32"),
+			LocalPref: 100,
+			Peers:     []string{"reflector"},
+		},
+	}
+	wantAds := map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": ads,
+		"1.2.3.5:0": ads,
+	}
+	gotAds := b.sessionManager.Ads()
+	sortAds(wantAds)
+	sortAds(gotAds)
+	if diff := cmp.Diff(wantAds, gotAds); diff != "" {
+		t.Errorf("unexpected advertisement state (-want +got)\n%s", diff)
+	}
+}
+
+func TestPeerCommunities(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	internal, err := config.ParseCommunity("64512:100")
+	if err != nil {
+		t.Fatalf("parsing community: %s", err)
+	}
+	noExport, err := config.ParseCommunity("65535:65281")
+	if err != nil {
+		t.Fatalf("parsing community: %s", err)
+	}
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"reflector": {
+				Name:          "reflector",
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+			"transit": {
+				Name:          "transit",
+				Addr:          net.ParseIP("1.2.3.5"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:            32,
+						AggregationLengthV6:          128,
+						AdditionalAggregationLengths: []int{24},
+						LocalPref:                    100,
+						Communities:                  map[uint32]bool{internal: true},
+						Nodes:                        map[string]bool{"pandora": true},
+						PeerCommunities: map[string]map[uint32]bool{
+							"transit": {noExport: true, internal: true},
+						},
+					},
+				},
+			},
+		}},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Cluster",
+		},
+		Status: statusAssigned("10.20.30.1"),
+	}
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{
+						{
+							IP:       "2.3.4.5",
+							NodeName: pointer.StrPtr("pandora"),
+						},
+					},
+				},
+			},
+		},
+		Type: epslices.Eps,
+	}
+	if c.SetBalancer(l, "test1", svc, eps) != controllers.SyncStateSuccess {
+		t.Fatalf("SetBalancer failed")
+	}
+
+	ads := []*bgp.Advertisement{
+		{
+			Prefix:      ipnet("10.20.30.1/32"),
+			LocalPref:   100,
+			Communities: []uint32{internal, noExport},
+			Peers:       []string{"transit"},
+		},
+		{
+			Prefix:      ipnet("10.20.30.1/32"),
+			LocalPref:   100,
+			Communities: []uint32{internal},
+			Peers:       []string{"reflector"},
+		},
+		{
+			Prefix:      ipnet("10.20.30.0/24"),
+			LocalPref:   100,
+			Communities: []uint32{internal},
+			Peers:       []string{"reflector"},
+		},
+		{
+			Prefix:      ipnet("10.20.30.0/24"),
+			LocalPref:   100,
+			Communities: []uint32{internal, noExport},
+			Peers:       []string{"transit"},
+		},
+	}
+	wantAds := map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": ads,
+		"1.2.3.5:0": ads,
+	}
+	gotAds := b.sessionManager.Ads()
+	sortAds(wantAds)
+	sortAds(gotAds)
+	if diff := cmp.Diff(wantAds, gotAds); diff != "" {
+		t.Errorf("unexpected advertisement state (-want +got)\n%s", diff)
+	}
+}
+
+func TestAdditionalAggregationLengths(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer": {
+				Name:          "peer",
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24"), ipnet("2001:db8::/64")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:              32,
+						AggregationLengthV6:            128,
+						AdditionalAggregationLengths:   []int{24},
+						AdditionalAggregationLengthsV6: []int{64},
+						Nodes:                          map[string]bool{"pandora": true},
+					},
+				},
+			},
+		}},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Cluster",
+		},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{{IP: "10.20.30.1"}, {IP: "2001:db8::1"}},
+			},
+		},
+	}
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{
+						{
+							IP:       "2.3.4.5",
+							NodeName: pointer.StrPtr("pandora"),
+						},
+					},
+				},
+			},
+		},
+		Type: epslices.Eps,
+	}
+	if c.SetBalancer(l, "test1", svc, eps) != controllers.SyncStateSuccess {
+		t.Fatalf("SetBalancer failed")
+	}
+
+	// Both the specific and the aggregated prefixes are advertised.
+	wantAds := map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": {
+			{Prefix: ipnet("10.20.30.0/24")},
+			{Prefix: ipnet("10.20.30.1/32")},
+			{Prefix: ipnet("2001:db8::/64")},
+			{Prefix: ipnet("2001:db8::1/128")},
+		},
+	}
+	gotAds := b.sessionManager.Ads()
+	sortAds(wantAds)
+	sortAds(gotAds)
+	if diff := cmp.Diff(wantAds, gotAds); diff != "" {
+		t.Errorf("unexpected advertisement state (-want +got)\n%s", diff)
+	}
+}
+
+func TestLinkBandwidth(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer": {
+				Name:          "peer",
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:        32,
+						AggregationLengthV6:      128,
+						LinkBandwidthPerEndpoint: 100,
+						Nodes:                    map[string]bool{"pandora": true},
+					},
+				},
+			},
+		}},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Cluster",
+		},
+		Status: statusAssigned("10.20.30.1"),
+	}
+	endpoints := func(nodes ...string) epslices.EpsOrSlices {
+		addresses := []v1.EndpointAddress{}
+		for i, node := range nodes {
+			addresses = append(addresses, v1.EndpointAddress{
+				IP:       fmt.Sprintf("2.3.4.%d", i),
+				NodeName: pointer.StrPtr(node),
+			})
+		}
+		return epslices.EpsOrSlices{
+			EpVal: &v1.Endpoints{
+				Subsets: []v1.EndpointSubset{{Addresses: addresses}},
+			},
+			Type: epslices.Eps,
+		}
+	}
+
+	many := make([]string, 300)
+	for i := range many {
+		many[i] = "pandora"
+	}
+
+	tests := []struct {
+		desc string
+		eps  epslices.EpsOrSlices
+		want uint32
+	}{
+		{
+			desc: "one local endpoint",
+			eps:  endpoints("pandora", "iris"),
+			want: 100,
+		},
+		{
+			desc: "three local endpoints",
+			eps:  endpoints("pandora", "pandora", "iris", "pandora"),
+			want: 300,
+		},
+		{
+			desc: "no local endpoint",
+			eps:  endpoints("iris"),
+			want: 0,
+		},
+		{
+			desc: "capped bandwidth",
+			eps:  endpoints(many...),
+			want: config.MaxLinkBandwidth,
+		},
+	}
+	for _, test := range tests {
+		if c.SetBalancer(l, "test1", svc, test.eps) != controllers.SyncStateSuccess {
+			t.Fatalf("%s: SetBalancer failed", test.desc)
+		}
+		wantAds := map[string][]*bgp.Advertisement{
+			"1.2.3.4:0": {
+				{Prefix: ipnet("10.20.30.1/32"), Bandwidth: test.want},
+			},
+		}
+		if diff := cmp.Diff(wantAds, b.sessionManager.Ads()); diff != "" {
+			t.Errorf("%s: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestDefaultBGPAdvertisement(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	defaultAdv, err := defaultBGPAdvertisement("pandora", 150, "65535:65282, 0:1234")
+	if err != nil {
+		t.Fatalf("creating the default advertisement: %s", err)
+	}
+	c, err := newController(controllerConfig{
+		MyNode:                  "pandora",
+		DisableLayer2:           true,
+		bgpType:                 bgpFrr,
+		DefaultBGPAdvertisement: defaultAdv,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"unreferenced": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+			},
+			"explicit": {
+				CIDR: []*net.IPNet{ipnet("10.20.40.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:   32,
+						AggregationLengthV6: 128,
+						LocalPref:           100,
+						Nodes:               map[string]bool{"pandora": true},
+					},
+				},
+			},
+			"layer2": {
+				CIDR:             []*net.IPNet{ipnet("10.20.50.0/24")},
+				L2Advertisements: []*config.L2Advertisement{{AllInterfaces: true}},
+			},
+		}},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+	if pools := ptu.ToFloat64(defaultAdvertisementPools); pools != 1 {
+		t.Errorf("expected 1 pool using the default advertisement, got %v", pools)
+	}
+
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{
+						{
+							IP:       "2.3.4.5",
+							NodeName: pointer.StrPtr("pandora"),
+						},
+					},
+				},
+			},
+		},
+		Type: epslices.Eps,
+	}
+	for name, ip := range map[string]string{"unreferenced": "10.20.30.1", "explicit": "10.20.40.1", "layer2": "10.20.50.1"} {
+		svc := &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: "Cluster",
+			},
+			Status: statusAssigned(ip),
+		}
+		if c.SetBalancer(l, name, svc, eps) != controllers.SyncStateSuccess {
+			t.Fatalf("SetBalancer failed for %s", name)
+		}
+	}
+
+	wantAds := map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": {
+			{
+				Prefix:      ipnet("10.20.30.1/32"),
+				LocalPref:   150,
+				Communities: []uint32{1234, 0xffffff02},
+			},
+			{
+				Prefix:    ipnet("10.20.40.1/32"),
+				LocalPref: 100,
+			},
+		},
+	}
+	gotAds := b.sessionManager.Ads()
+	sortAds(wantAds)
+	sortAds(gotAds)
+	if diff := cmp.Diff(wantAds, gotAds); diff != "" {
+		t.Errorf("unexpected advertisement state (-want +got)\n%s", diff)
+	}
+
+	if _, err := defaultBGPAdvertisement("pandora", 0, "notacommunity"); err == nil {
+		t.Errorf("expected an error for an invalid community")
+	}
+}
+
+func TestAggregatedPrefix(t *testing.T) {
+	rangeCIDRs, err := config.ParseCIDR("10.0.0.5-10.0.0.37")
+	if err != nil {
+		t.Fatalf("failed to parse range: %s", err)
+	}
+	rangePool := &config.Pool{CIDR: rangeCIDRs}
+	cidrPool := &config.Pool{CIDR: []*net.IPNet{ipnet("10.0.0.0/24"), ipnet("2000::/64")}}
+
+	tests := []struct {
+		desc     string
+		ip       string
+		length   int
+		pool     *config.Pool
+		expected string
+	}{
+		{"no aggregation", "10.0.0.5", 32, rangePool, "10.0.0.5/32"},
+		{"start of the range", "10.0.0.5", 24, rangePool, "10.0.0.5/32"},
+		{"second cidr of the range", "10.0.0.7", 24, rangePool, "10.0.0.6/31"},
+		{"aligned block of the range", "10.0.0.20", 24, rangePool, "10.0.0.16/28"},
+		{"aggregation more specific than the block", "10.0.0.20", 30, rangePool, "10.0.0.20/30"},
+		{"end of the range", "10.0.0.37", 26, rangePool, "10.0.0.36/31"},
+		{"plain cidr", "10.0.0.20", 26, cidrPool, "10.0.0.0/26"},
+		{"ipv6", "2000::1", 120, cidrPool, "2000::/120"},
+	}
+	for _, test := range tests {
+		got := aggregatedPrefix(net.ParseIP(test.ip), test.length, test.pool)
+		if got.String() != test.expected {
+			t.Errorf("%s: got prefix %s, expected %s", test.desc, got, test.expected)
+		}
+	}
+}
+
+func TestSummarized(t *testing.T) {
+	ads := []*bgp.Advertisement{
+		{Prefix: ipnet("10.0.0.0/24"), SummaryOnly: true, Peers: []string{"peer1"}},
+		{Prefix: ipnet("10.0.0.1/32")},
+		{Prefix: ipnet("10.0.1.1/32")},
+		{Prefix: ipnet("10.0.0.2/32"), Peers: []string{"peer2"}},
+		{Prefix: ipnet("2000::/120")},
+	}
+
+	tests := []struct {
+		peer     string
+		expected []string
+	}{
+		{"peer1", []string{"10.0.0.0/24", "10.0.1.1/32", "10.0.0.2/32", "2000::/120"}},
+		{"peer2", []string{"10.0.0.0/24", "10.0.0.1/32", "10.0.1.1/32", "10.0.0.2/32", "2000::/120"}},
+	}
+	for _, test := range tests {
+		got := []string{}
+		for _, ad := range summarized(ads, test.peer) {
+			got = append(got, ad.Prefix.String())
+		}
+		if diff := cmp.Diff(test.expected, got); diff != "" {
+			t.Errorf("%s: unexpected prefixes (-want +got)\n%s", test.peer, diff)
+		}
+	}
+}
+
+func TestFamilyAds(t *testing.T) {
+	ads := []*bgp.Advertisement{
+		{Prefix: ipnet("10.0.0.1/32")},
+		{Prefix: ipnet("2000::1/128")},
+	}
+
+	tests := []struct {
+		desc     string
+		families []ipfamily.Family
+		expected []string
+	}{
+		{"both families", nil, []string{"10.0.0.1/32", "2000::1/128"}},
+		{"ipv4 only", []ipfamily.Family{ipfamily.IPv4}, []string{"10.0.0.1/32"}},
+		{"ipv6 only", []ipfamily.Family{ipfamily.IPv6}, []string{"2000::1/128"}},
+	}
+	for _, test := range tests {
+		got := []string{}
+		for _, ad := range familyAds(ads, &config.Peer{AddressFamilies: test.families}) {
+			got = append(got, ad.Prefix.String())
+		}
+		if diff := cmp.Diff(test.expected, got); diff != "" {
+			t.Errorf("%s: unexpected prefixes (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
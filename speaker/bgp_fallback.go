@@ -0,0 +1,151 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"go.universe.tf/metallb/internal/bgp"
+	"go.universe.tf/metallb/internal/config"
+)
+
+// sessionCheckInterval is how often the state of the BGP sessions is
+// checked, for the pools preferring BGP to fall back to L2.
+const sessionCheckInterval = time.Second
+
+// sessionTracker tracks the state of the BGP sessions of the node, for the
+// pools preferring BGP to fall back to L2 when no session is established. A
+// change of the state of a session is taken into account only once the
+// session stayed in the new state for the hysteresis, so that a flapping
+// session does not make the IPs switch back and forth between the modes.
+type sessionTracker struct {
+	hysteresis time.Duration
+
+	sync.Mutex
+	sessions map[string]*trackedSession // peer name -> state
+}
+
+type trackedSession struct {
+	up       bool      // the state taken into account
+	observed bool      // the last observed state
+	since    time.Time // when the last observed state was first observed
+}
+
+// observe records the observed states of the sessions, by peer name,
+// returning the peers whose state taken into account changed along with
+// their new state.
+func (t *sessionTracker) observe(states map[string]bool, now time.Time) map[string]bool {
+	t.Lock()
+	defer t.Unlock()
+	if t.sessions == nil {
+		t.sessions = map[string]*trackedSession{}
+	}
+	for name := range t.sessions {
+		if _, ok := states[name]; !ok {
+			delete(t.sessions, name)
+		}
+	}
+
+	changed := map[string]bool{}
+	for name, up := range states {
+		s, ok := t.sessions[name]
+		if !ok {
+			s = &trackedSession{since: now}
+			t.sessions[name] = s
+		}
+		if s.observed != up {
+			s.observed = up
+			s.since = now
+		}
+		if s.up != s.observed && now.Sub(s.since) >= t.hysteresis {
+			s.up = s.observed
+			changed[name] = s.up
+		}
+	}
+	return changed
+}
+
+// anyUp returns true if the session with any of the given peers is up, or
+// with any peer if none is given.
+func (t *sessionTracker) anyUp(peers []string) bool {
+	t.Lock()
+	defer t.Unlock()
+	if len(peers) == 0 {
+		for _, s := range t.sessions {
+			if s.up {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range peers {
+		if s, ok := t.sessions[p]; ok && s.up {
+			return true
+		}
+	}
+	return false
+}
+
+// bgpPreferred returns true if the IPs of the pool are to be announced via
+// BGP only, the pool preferring BGP and the node having an established
+// session with the peers of one of its BGP advertisements selecting it.
+func (c *controller) bgpPreferred(pool *config.Pool) bool {
+	if !pool.PreferBGP {
+		return false
+	}
+	for _, adv := range pool.BGPAdvertisements {
+		if !adv.Nodes[c.myNode] {
+			continue
+		}
+		if c.sessions.anyUp(adv.Peers) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackSessions periodically checks the state of the BGP sessions, calling
+// onChange to reprocess the services when it changes so that the IPs of the
+// pools preferring BGP switch mode, until stopCh is closed.
+func (c *controller) trackSessions(l log.Logger, onChange func(), stopCh <-chan struct{}) {
+	handler, ok := c.protocolHandlers[config.BGP].(*bgpController)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(sessionCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+		changed := c.sessions.observe(handler.sessionStates(), time.Now())
+		if len(changed) == 0 {
+			continue
+		}
+		for peer, up := range changed {
+			level.Info(l).Log("event", "bgpSessionStateChanged", "peer", peer, "up", up, "msg", "reprocessing the services of the pools preferring BGP")
+		}
+		onChange()
+	}
+}
+
+// sessionStates returns, by peer name, whether the session with the peer
+// is established. The sessions not able to tell are considered down.
+func (c *bgpController) sessionStates() map[string]bool {
+	c.Lock()
+	defer c.Unlock()
+	res := map[string]bool{}
+	for _, p := range c.peers {
+		up := false
+		if r, ok := p.session.(bgp.EstablishedReporter); ok {
+			up = r.Established()
+		}
+		res[p.cfg.Name] = up
+	}
+	return res
+}
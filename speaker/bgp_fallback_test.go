@@ -0,0 +1,135 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/internal/config"
+)
+
+func TestSessionTracker(t *testing.T) {
+	tracker := sessionTracker{hysteresis: 10 * time.Second}
+	start := time.Now()
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	steps := []struct {
+		desc    string
+		at      int
+		states  map[string]bool
+		changed map[string]bool
+		up      []string
+	}{
+		{
+			desc:    "sessions start down",
+			at:      0,
+			states:  map[string]bool{"peer1": true, "peer2": false},
+			changed: map[string]bool{},
+		},
+		{
+			desc:    "up before the hysteresis",
+			at:      9,
+			states:  map[string]bool{"peer1": true, "peer2": false},
+			changed: map[string]bool{},
+		},
+		{
+			desc:    "up after the hysteresis",
+			at:      10,
+			states:  map[string]bool{"peer1": true, "peer2": false},
+			changed: map[string]bool{"peer1": true},
+			up:      []string{"peer1"},
+		},
+		{
+			desc:    "flapping session",
+			at:      15,
+			states:  map[string]bool{"peer1": false, "peer2": false},
+			changed: map[string]bool{},
+			up:      []string{"peer1"},
+		},
+		{
+			desc:    "flapping session back up",
+			at:      16,
+			states:  map[string]bool{"peer1": true, "peer2": false},
+			changed: map[string]bool{},
+			up:      []string{"peer1"},
+		},
+		{
+			desc:    "down again",
+			at:      17,
+			states:  map[string]bool{"peer1": false, "peer2": false},
+			changed: map[string]bool{},
+			up:      []string{"peer1"},
+		},
+		{
+			desc:    "down after the hysteresis",
+			at:      27,
+			states:  map[string]bool{"peer1": false, "peer2": false},
+			changed: map[string]bool{"peer1": false},
+		},
+		{
+			desc:    "removed peer",
+			at:      28,
+			states:  map[string]bool{"peer2": false},
+			changed: map[string]bool{},
+		},
+	}
+	for _, step := range steps {
+		changed := tracker.observe(step.states, at(step.at))
+		if diff := cmp.Diff(step.changed, changed); diff != "" {
+			t.Fatalf("%s: unexpected changes (-want +got)\n%s", step.desc, diff)
+		}
+		for _, peer := range step.up {
+			if !tracker.anyUp([]string{peer}) {
+				t.Fatalf("%s: expected %s to be up", step.desc, peer)
+			}
+		}
+		if got := tracker.anyUp(nil); got != (len(step.up) > 0) {
+			t.Fatalf("%s: expected any session up to be %v, got %v", step.desc, len(step.up) > 0, got)
+		}
+	}
+	if _, ok := tracker.sessions["peer1"]; ok {
+		t.Fatal("expected the removed peer to be forgotten")
+	}
+}
+
+func TestBGPPreferred(t *testing.T) {
+	c := &controller{
+		myNode:   "pandora",
+		sessions: sessionTracker{},
+	}
+	now := time.Now()
+	c.sessions.observe(map[string]bool{"peer1": true, "peer2": false}, now)
+
+	dualMode := func(preferBGP bool, nodes map[string]bool, peers ...string) *config.Pool {
+		return &config.Pool{
+			PreferBGP: preferBGP,
+			BGPAdvertisements: []*config.BGPAdvertisement{
+				{Nodes: nodes, Peers: peers},
+			},
+			L2Advertisements: []*config.L2Advertisement{
+				{Nodes: map[string]bool{"pandora": true}, AllInterfaces: true},
+			},
+		}
+	}
+	thisNode := map[string]bool{"pandora": true}
+	tests := []struct {
+		desc string
+		pool *config.Pool
+		want bool
+	}{
+		{desc: "bgp not preferred", pool: dualMode(false, thisNode), want: false},
+		{desc: "session up with all the peers", pool: dualMode(true, thisNode), want: true},
+		{desc: "session up with the peer", pool: dualMode(true, thisNode, "peer1"), want: true},
+		{desc: "session down with the peer", pool: dualMode(true, thisNode, "peer2"), want: false},
+		{desc: "advertisement not selecting the node", pool: dualMode(true, map[string]bool{"iris": true}), want: false},
+	}
+	for _, test := range tests {
+		if got := c.bgpPreferred(test.pool); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.desc, test.want, got)
+		}
+	}
+}
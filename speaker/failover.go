@@ -0,0 +1,275 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	v1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+)
+
+// balancerCache holds the last seen state of the services the speaker
+// considers for announcement, so the announcements can be computed again
+// outside of the reconcile loop.
+type balancerCache struct {
+	sync.Mutex
+	balancers map[string]*cachedBalancer
+}
+
+type cachedBalancer struct {
+	ips  []net.IP
+	pool *config.Pool
+	svc  *v1.Service
+	eps  epslices.EpsOrSlices
+}
+
+func (c *balancerCache) set(name string, b *cachedBalancer) {
+	c.Lock()
+	defer c.Unlock()
+	if c.balancers == nil {
+		c.balancers = map[string]*cachedBalancer{}
+	}
+	c.balancers[name] = b
+}
+
+func (c *balancerCache) delete(name string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.balancers, name)
+}
+
+// failoverReport describes how the announcements change if a node fails.
+type failoverReport struct {
+	Node     string            `json:"node"`
+	Services []serviceFailover `json:"services"`
+}
+
+// serviceFailover describes the announcements of a service that change if
+// the node fails.
+type serviceFailover struct {
+	Service string          `json:"service"`
+	IPs     []string        `json:"ips"`
+	Layer2  *layer2Failover `json:"layer2,omitempty"`
+	BGP     *bgpFailover    `json:"bgp,omitempty"`
+}
+
+type layer2Failover struct {
+	Current       string `json:"current"`
+	AfterFailover string `json:"afterFailover"`
+}
+
+type bgpFailover struct {
+	Current       []string `json:"current"`
+	AfterFailover []string `json:"afterFailover"`
+}
+
+// simulateFailover computes, without changing anything, the node announcing
+// each service via L2 and the nodes announcing it via BGP, before and after
+// the given node fails and its endpoints go away. Only the services whose
+// announcements change are reported.
+func (c *controller) simulateFailover(node string) failoverReport {
+	res := failoverReport{Node: node, Services: []serviceFailover{}}
+
+	var speakers map[string]bool
+	var scores, l2Weights map[string]int
+	l2, hasL2 := c.protocolHandlers[config.Layer2].(*layer2Controller)
+	bgpCtrl, hasBGP := c.protocolHandlers[config.BGP].(*bgpController)
+	switch {
+	case hasL2:
+		speakers = l2.sList.UsableSpeakers()
+		scores, l2Weights = l2.nodeWeights()
+	case hasBGP:
+		speakers = bgpCtrl.sList.UsableSpeakers()
+	}
+	var afterSpeakers map[string]bool
+	if speakers != nil {
+		afterSpeakers = map[string]bool{}
+		for s := range speakers {
+			if s != node {
+				afterSpeakers[s] = true
+			}
+		}
+	}
+
+	c.balancers.Lock()
+	defer c.balancers.Unlock()
+
+	names := make([]string, 0, len(c.balancers.balancers))
+	for name := range c.balancers.balancers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b := c.balancers.balancers[name]
+		afterEps := endpointsWithoutNode(b.eps, node)
+		minEndpoints, _ := minEndpointsFor(b.svc)
+		withdrawn := healthyEndpoints(afterEps, func(*string) bool { return false }) < minEndpoints
+
+		svcFailover := serviceFailover{Service: name}
+		for _, ip := range b.ips {
+			svcFailover.IPs = append(svcFailover.IPs, ip.String())
+		}
+		changed := false
+
+		if hasL2 && len(b.pool.L2Advertisements) > 0 {
+			current := ""
+			if activeEndpointExists(b.eps) {
+				current = l2LeaderFor(speakers, b.ips[0], b.pool, b.svc, b.eps, scores, l2Weights)
+			}
+			after := ""
+			if !withdrawn && activeEndpointExists(afterEps) {
+				after = l2LeaderFor(afterSpeakers, b.ips[0], b.pool, b.svc, afterEps, scores, l2Weights)
+			}
+			if current != after {
+				svcFailover.Layer2 = &layer2Failover{Current: current, AfterFailover: after}
+				changed = true
+			}
+		}
+
+		if hasBGP {
+			current := bgpCtrl.announcingNodes(speakers, b.ips[0], b.pool, b.svc, b.eps)
+			after := []string{}
+			if !withdrawn {
+				after = bgpCtrl.announcingNodes(afterSpeakers, b.ips[0], b.pool, b.svc, afterEps)
+				for i, n := range after {
+					if n == node {
+						after = append(after[:i], after[i+1:]...)
+						break
+					}
+				}
+			}
+			if !reflect.DeepEqual(current, after) {
+				svcFailover.BGP = &bgpFailover{Current: current, AfterFailover: after}
+				changed = true
+			}
+		}
+
+		if changed {
+			res.Services = append(res.Services, svcFailover)
+		}
+	}
+	return res
+}
+
+// announcingNodes returns the sorted list of nodes that announce the service
+// via BGP, among the given speakers or, when not known, among the nodes
+// selected by the advertisements of the pool. Each node is run through the
+// same election as the one the speaker of the node runs.
+func (c *bgpController) announcingNodes(speakers map[string]bool, ip net.IP, pool *config.Pool, svc *v1.Service, eps epslices.EpsOrSlices) []string {
+	c.Lock()
+	advs := c.advertisementsFor(pool)
+	myNode := c.myNode
+	c.Unlock()
+
+	candidates := speakers
+	if candidates == nil {
+		candidates = map[string]bool{myNode: true}
+		for _, adv := range advs {
+			for n, selected := range adv.Nodes {
+				if selected {
+					candidates[n] = true
+				}
+			}
+		}
+	}
+	if usesDefaultAdvertisement(pool) && len(advs) > 0 {
+		// The default advertisement selects only the node of the speaker
+		// making it, each speaker making its own.
+		adv := *advs[0]
+		adv.Nodes = map[string]bool{}
+		for n := range candidates {
+			adv.Nodes[n] = true
+		}
+		advs = []*config.BGPAdvertisement{&adv}
+	}
+
+	res := []string{}
+	for n := range candidates {
+		if !poolMatchesNodeBGP(advs, n) {
+			continue
+		}
+		if electBGP(n, speakers, ip, advs, svc, eps).reason != "" {
+			continue
+		}
+		res = append(res, n)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// endpointsWithoutNode returns a copy of the endpoints without the ones
+// running on the given node.
+func endpointsWithoutNode(eps epslices.EpsOrSlices, node string) epslices.EpsOrSlices {
+	onNode := func(n *string) bool {
+		return n != nil && *n == node
+	}
+	res := epslices.EpsOrSlices{Type: eps.Type}
+	switch eps.Type {
+	case epslices.Eps:
+		if eps.EpVal == nil {
+			return res
+		}
+		res.EpVal = eps.EpVal.DeepCopy()
+		for i := range res.EpVal.Subsets {
+			subset := &res.EpVal.Subsets[i]
+			addresses := []v1.EndpointAddress{}
+			for _, a := range subset.Addresses {
+				if !onNode(a.NodeName) {
+					addresses = append(addresses, a)
+				}
+			}
+			subset.Addresses = addresses
+			notReady := []v1.EndpointAddress{}
+			for _, a := range subset.NotReadyAddresses {
+				if !onNode(a.NodeName) {
+					notReady = append(notReady, a)
+				}
+			}
+			subset.NotReadyAddresses = notReady
+		}
+	case epslices.Slices:
+		for _, slice := range eps.SlicesVal {
+			s := slice.DeepCopy()
+			endpoints := []discovery.Endpoint{}
+			for _, ep := range s.Endpoints {
+				if !onNode(ep.NodeName) {
+					endpoints = append(endpoints, ep)
+				}
+			}
+			s.Endpoints = endpoints
+			res.SlicesVal = append(res.SlicesVal, *s)
+		}
+	}
+	return res
+}
+
+// failoverHandler serves the announcement changes expected if the node
+// passed as the node query parameter fails, without failing over.
+func failoverHandler(l log.Logger, c *controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		node := r.URL.Query().Get("node")
+		if node == "" {
+			http.Error(w, "the node query parameter is required", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.simulateFailover(node)); err != nil {
+			level.Error(l).Log("op", "failover", "error", err, "msg", "failed to write the failover report")
+		}
+	}
+}
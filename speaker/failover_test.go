@@ -0,0 +1,168 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	"go.universe.tf/metallb/internal/pointer"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestSimulateFailover(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:  "nodeA",
+		Logger:  log.NewNopLogger(),
+		SList:   &fakeSpeakerList{speakers: map[string]bool{"nodeA": true, "nodeB": true, "nodeC": true}},
+		bgpType: bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	allNodes := map[string]bool{"nodeA": true, "nodeB": true, "nodeC": true}
+	cfg := &config.Config{
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"l2": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				L2Advertisements: []*config.L2Advertisement{{
+					Nodes:         allNodes,
+					AllInterfaces: true,
+					NodeWeights:   map[string]int{"nodeB": 20, "nodeC": 10},
+				}},
+			},
+			"bgp": {
+				CIDR: []*net.IPNet{ipnet("10.20.40.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{{
+					AggregationLength:   32,
+					AggregationLengthV6: 128,
+					Nodes:               allNodes,
+				}},
+			},
+			"bgp-single": {
+				CIDR: []*net.IPNet{ipnet("10.20.50.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{{
+					AggregationLength:   32,
+					AggregationLengthV6: 128,
+					Nodes:               allNodes,
+					SingleNode:          true,
+				}},
+			},
+		}},
+	}
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	endpointsOn := func(nodes ...string) epslices.EpsOrSlices {
+		addresses := []v1.EndpointAddress{}
+		for i, n := range nodes {
+			addresses = append(addresses, v1.EndpointAddress{IP: net.IPv4(2, 3, 4, byte(i)).String(), NodeName: pointer.StrPtr(n)})
+		}
+		return epslices.EpsOrSlices{
+			EpVal: &v1.Endpoints{Subsets: []v1.EndpointSubset{{Addresses: addresses}}},
+			Type:  epslices.Eps,
+		}
+	}
+	services := []struct {
+		name   string
+		ip     string
+		policy v1.ServiceExternalTrafficPolicyType
+		eps    epslices.EpsOrSlices
+	}{
+		{"l2-cluster", "10.20.30.1", v1.ServiceExternalTrafficPolicyTypeCluster, endpointsOn("nodeA")},
+		{"bgp-local", "10.20.40.1", v1.ServiceExternalTrafficPolicyTypeLocal, endpointsOn("nodeA", "nodeB")},
+		{"bgp-local-unaffected", "10.20.40.2", v1.ServiceExternalTrafficPolicyTypeLocal, endpointsOn("nodeA", "nodeC")},
+		{"bgp-single", "10.20.50.1", v1.ServiceExternalTrafficPolicyTypeCluster, endpointsOn("nodeA")},
+	}
+	for _, s := range services {
+		svc := &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: s.policy,
+			},
+			Status: statusAssigned(s.ip),
+		}
+		if c.SetBalancer(l, s.name, svc, s.eps) != controllers.SyncStateSuccess {
+			t.Fatalf("SetBalancer failed for %s", s.name)
+		}
+	}
+
+	srv := httptest.NewServer(failoverHandler(l, c))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected bad request without node, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "?node=nodeB")
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	var got failoverReport
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode the report: %s", err)
+	}
+
+	want := failoverReport{
+		Node: "nodeB",
+		Services: []serviceFailover{
+			{
+				Service: "bgp-local",
+				IPs:     []string{"10.20.40.1"},
+				BGP: &bgpFailover{
+					Current:       []string{"nodeA", "nodeB"},
+					AfterFailover: []string{"nodeA"},
+				},
+			},
+			{
+				Service: "bgp-single",
+				IPs:     []string{"10.20.50.1"},
+				BGP: &bgpFailover{
+					Current:       []string{"nodeB"},
+					AfterFailover: []string{"nodeA"},
+				},
+			},
+			{
+				Service: "l2-cluster",
+				IPs:     []string{"10.20.30.1"},
+				Layer2: &layer2Failover{
+					Current:       "nodeB",
+					AfterFailover: "nodeC",
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected failover report (-want +got)\n%s", diff)
+	}
+
+	// Simulating doesn't change what the node announces.
+	if c.announced[config.Layer2]["l2-cluster"] {
+		t.Errorf("l2-cluster is not expected to be announced from nodeA")
+	}
+	if !c.announced[config.BGP]["bgp-local"] {
+		t.Errorf("bgp-local is expected to be announced from nodeA")
+	}
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+// interfacesReportInterval is how often the interfaces of the node are
+// checked for changes.
+const interfacesReportInterval = time.Minute
+
+// reportInterfaces publishes the names of the interfaces of the node in
+// the SpeakerReport of the node, for the controller to check the
+// interfaces of the L2Advertisements against. The report is updated when
+// the interfaces change, until stopCh is closed.
+func reportInterfaces(l log.Logger, client speakerReporter, node string, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interfacesReportInterval)
+	defer ticker.Stop()
+
+	var reported []string
+	for {
+		names, err := interfaceNames()
+		if err != nil {
+			level.Error(l).Log("op", "reportInterfaces", "error", err, "msg", "failed to list the interfaces")
+		} else if reported == nil || !reflect.DeepEqual(names, reported) {
+			err := client.UpdateSpeakerReport(node, func(status *metallbv1beta1.SpeakerReportStatus) {
+				status.Interfaces = names
+			})
+			if err != nil {
+				level.Error(l).Log("op", "reportInterfaces", "error", err, "msg", "failed to report the interfaces")
+			} else {
+				level.Debug(l).Log("op", "reportInterfaces", "interfaces", fmt.Sprint(names), "msg", "reported the interfaces")
+				reported = names
+			}
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// interfaceNames returns the sorted names of the interfaces of the node.
+var interfaceNames = func() ([]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		names = append(names, iface.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -17,14 +17,20 @@ package main
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"math"
 	"net"
+	"net/http"
 	"sort"
+	"sync"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/k8s/epslices"
 	"go.universe.tf/metallb/internal/layer2"
+	"go.universe.tf/metallb/internal/speakerlist"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
@@ -33,12 +39,42 @@ type layer2Controller struct {
 	announcer *layer2.Announce
 	myNode    string
 	sList     SpeakerList
+	// Protects the scores and the weights of the nodes, also read by the
+	// failover simulations outside of the reconcile loop.
+	weightsMu sync.Mutex
+	// The scores of the nodes, the lowest scoring one is preferred.
+	nodeScores map[string]int
+	// The weights of the nodes, the probability of a node to be elected
+	// is proportional to its weight.
+	nodeL2Weights map[string]int
+	// The types of the node addresses whose interfaces the IPs are
+	// announced on, instead of all the interfaces, and those interfaces.
+	addressTypes []v1.NodeAddressType
+	nodeIfs      sets.Set[string]
+
+	// The services announced and the client to emit events about them
+	// with, for the foreign announcements reported by the announcer.
+	svcsMu sync.Mutex
+	svcs   map[string]*v1.Service
+	client service
 }
 
-func (c *layer2Controller) SetConfig(log.Logger, *config.Config) error {
+func (c *layer2Controller) SetConfig(_ log.Logger, cfg *config.Config) error {
+	c.weightsMu.Lock()
+	defer c.weightsMu.Unlock()
+	c.nodeScores = cfg.NodeScores
+	c.nodeL2Weights = cfg.NodeL2Weights
 	return nil
 }
 
+// nodeWeights returns the scores and the weights of the nodes the
+// elections are run with.
+func (c *layer2Controller) nodeWeights() (map[string]int, map[string]int) {
+	c.weightsMu.Lock()
+	defer c.weightsMu.Unlock()
+	return c.nodeScores, c.nodeL2Weights
+}
+
 // usableNodes returns all nodes that have at least one fully ready
 // endpoint on them.
 // The speakers parameter is a map containing all the nodes with active speakers.
@@ -105,39 +141,87 @@ func (c *layer2Controller) ShouldAnnounce(l log.Logger, name string, toAnnounce
 		return "notOwner"
 	}
 
+	// Are we the elected node? If so, we win and should announce.
+	// Using the first IP should work for both single and dual stack.
+	scores, l2Weights := c.nodeWeights()
+	if l2LeaderFor(c.sList.UsableSpeakers(), toAnnounce[0], pool, svc, eps, scores, l2Weights) == c.myNode {
+		return ""
+	}
+
+	// Either not eligible, or lost the election entirely.
+	return "notOwner"
+}
+
+// l2LeaderFor returns the node that wins the election for announcing the
+// given ip among the given speakers, or an empty string if no node is
+// eligible.
+func l2LeaderFor(speakers map[string]bool, ip net.IP, pool *config.Pool, svc *v1.Service, eps epslices.EpsOrSlices, scores, l2Weights map[string]int) string {
 	// we select the nodes with at least one matching l2 advertisement
-	forPool := speakersForPool(c.sList.UsableSpeakers(), pool)
+	forPool := speakersForPool(speakers, pool)
 	var nodes []string
 	if svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal {
 		nodes = usableNodes(eps, forPool)
+		// none of the selected nodes has an endpoint, the advertisements
+		// falling back to their nodes elect among them instead.
+		if len(nodes) == 0 && activeEndpointExists(eps) {
+			nodes = nodesWithActiveSpeakers(fallbackSpeakersForPool(speakers, pool))
+		}
 	} else {
 		nodes = nodesWithActiveSpeakers(forPool)
 	}
-	// Using the first IP should work for both single and dual stack.
-	ipString := toAnnounce[0].String()
-	// Sort the slice by the hash of node + load balancer ips. This
-	// produces an ordering of ready nodes that is unique to all the services
-	// with the same ip.
+	ipString := ip.String()
+	// Sort the slice by the preference of the nodes first, then by their
+	// score, the nodes without a score coming last, and then by the hash of
+	// node + load balancer ips. This produces an ordering of ready nodes that
+	// is unique to all the services with the same ip. When the nodes carry
+	// a weight, the hash is weighted so that each node comes first with a
+	// probability proportional to its weight.
+	weights := nodeWeightsForPool(nodes, pool)
 	sort.Slice(nodes, func(i, j int) bool {
+		if weights[nodes[i]] != weights[nodes[j]] {
+			return weights[nodes[i]] > weights[nodes[j]]
+		}
+		si, hasScorei := scores[nodes[i]]
+		sj, hasScorej := scores[nodes[j]]
+		if hasScorei != hasScorej {
+			return hasScorei
+		}
+		if si != sj {
+			return si < sj
+		}
 		hi := sha256.Sum256([]byte(nodes[i] + "#" + ipString))
 		hj := sha256.Sum256([]byte(nodes[j] + "#" + ipString))
+		if len(l2Weights) > 0 {
+			ki, kj := weightedHash(hi, l2Weights[nodes[i]]), weightedHash(hj, l2Weights[nodes[j]])
+			if ki != kj {
+				return ki < kj
+			}
+		}
 
-		return bytes.Compare(hi[:], hj[:]) < 0
+		if cmp := bytes.Compare(hi[:], hj[:]); cmp != 0 {
+			return cmp < 0
+		}
+		return nodes[i] < nodes[j]
 	})
 
-	// Are we first in the list? If so, we win and should announce.
-	if len(nodes) > 0 && nodes[0] == c.myNode {
+	if len(nodes) == 0 {
 		return ""
 	}
-
-	// Either not eligible, or lost the election entirely.
-	return "notOwner"
+	return nodes[0]
 }
 
 func (c *layer2Controller) SetBalancer(l log.Logger, name string, lbIPs []net.IP, pool *config.Pool, client service, svc *v1.Service) error {
 	ifs := c.announcer.GetInterfaces()
 	for _, lbIP := range lbIPs {
 		ipAdv := ipAdvertisementFor(lbIP, c.myNode, pool.L2Advertisements)
+		if ipAdv.AllInterfaces() && c.nodeIfs.Len() > 0 {
+			ipAdv = layer2.NewVRFIPAdvertisement(lbIP, ipAdv.VRF(), false, c.nodeIfs)
+		}
+		if vrf := ipAdv.VRF(); vrf != "" && !c.announcer.HasVRF(vrf) {
+			level.Warn(l).Log("op", "SetBalancer", "protocol", "layer2", "service", name, "vrf", vrf, "msg", "the vrf used to announce LB IP doesn't exist")
+			client.Errorf(svc, "announceFailed", "the vrf %q used to announce LB IP %q doesn't exist in assigned node %q with protocol %q", vrf, lbIP.String(), c.myNode, config.Layer2)
+			continue
+		}
 		if !ipAdv.MatchInterfaces(ifs...) {
 			level.Warn(l).Log("op", "SetBalancer", "protocol", "layer2", "service", name, "IPAdvertisement", ipAdv,
 				"localIfs", ifs, "msg", "the specified interfaces used to announce LB IP don't exist")
@@ -146,10 +230,20 @@ func (c *layer2Controller) SetBalancer(l log.Logger, name string, lbIPs []net.IP
 		}
 		c.announcer.SetBalancer(name, ipAdv)
 	}
+	c.svcsMu.Lock()
+	if c.svcs == nil {
+		c.svcs = map[string]*v1.Service{}
+	}
+	c.svcs[name] = svc
+	c.client = client
+	c.svcsMu.Unlock()
 	return nil
 }
 
 func (c *layer2Controller) DeleteBalancer(l log.Logger, name, reason string) error {
+	c.svcsMu.Lock()
+	delete(c.svcs, name)
+	c.svcsMu.Unlock()
 	if !c.announcer.AnnounceName(name) {
 		return nil
 	}
@@ -157,23 +251,81 @@ func (c *layer2Controller) DeleteBalancer(l log.Logger, name, reason string) err
 	return nil
 }
 
-func (c *layer2Controller) SetNode(log.Logger, *v1.Node) error {
+// foreignAnnouncement emits an event on the services whose IP is also
+// announced by another device.
+func (c *layer2Controller) foreignAnnouncement(f layer2.ForeignAnnouncement) {
+	c.svcsMu.Lock()
+	defer c.svcsMu.Unlock()
+	for _, name := range f.Services {
+		svc, ok := c.svcs[name]
+		if !ok || c.client == nil {
+			continue
+		}
+		c.client.Errorf(svc, "foreignAnnouncement", "IP %q announced from node %q is also announced by MAC %s on interface %q", f.IP.String(), c.myNode, f.MAC.String(), f.Interface)
+	}
+}
+
+func (c *layer2Controller) SetNode(l log.Logger, node *v1.Node) error {
 	c.sList.Rejoin()
+	if len(c.addressTypes) == 0 {
+		return nil
+	}
+	ifs, err := interfacesWithIPs(nodeAddresses(node, c.addressTypes))
+	if err != nil {
+		level.Error(l).Log("op", "setNode", "protocol", "layer2", "error", err, "msg", "failed to find the interfaces of the node addresses")
+		return nil
+	}
+	c.nodeIfs = ifs
 	return nil
 }
 
+// ipAdvertisementFor returns the advertisement of the IP from the local
+// node. The l2 advertisements of a pool all share the same VRF.
 func ipAdvertisementFor(ip net.IP, localNode string, l2Advertisements []*config.L2Advertisement) layer2.IPAdvertisement {
 	ifs := sets.Set[string]{}
+	vrf := ""
 	for _, l2 := range l2Advertisements {
 		if matchNode := l2.Nodes[localNode]; !matchNode {
 			continue
 		}
+		vrf = l2.VRF
 		if l2.AllInterfaces {
-			return layer2.NewIPAdvertisement(ip, true, sets.Set[string]{})
+			return layer2.NewVRFIPAdvertisement(ip, vrf, true, sets.Set[string]{})
 		}
 		ifs = ifs.Insert(l2.Interfaces...)
 	}
-	return layer2.NewIPAdvertisement(ip, false, ifs)
+	return layer2.NewVRFIPAdvertisement(ip, vrf, false, ifs)
+}
+
+// nodeWeightsForPool returns the preference of each node for announcing IPs
+// from the given pool, being the highest weight among the l2 advertisements
+// of the pool the node is selected by.
+func nodeWeightsForPool(nodes []string, pool *config.Pool) map[string]int {
+	res := map[string]int{}
+	for _, node := range nodes {
+		for _, l2 := range pool.L2Advertisements {
+			if !l2.Nodes[node] {
+				continue
+			}
+			if w := l2.NodeWeights[node]; w > res[node] {
+				res[node] = w
+			}
+		}
+	}
+	return res
+}
+
+// weightedHash maps the hash of a node to the key it's sorted by in the
+// weighted election, following the weighted rendezvous hashing: the lowest
+// key among the nodes is the one of a given node with a probability
+// proportional to its weight. The nodes without a weight weigh 1.
+func weightedHash(hash [sha256.Size]byte, weight int) float64 {
+	if weight <= 0 {
+		weight = 1
+	}
+	// The 53 highest bits of the hash give a uniform value in (0, 1).
+	u := (float64(binary.BigEndian.Uint64(hash[:8])>>11) + 0.5) / (1 << 53)
+	return -math.Log(u) / float64(weight)
 }
 
 // nodesWithActiveSpeakers returns the list of nodes with active speakers.
@@ -225,3 +377,46 @@ func speakersForPool(speakers map[string]bool, pool *config.Pool) map[string]boo
 	}
 	return res
 }
+
+// fallbackSpeakersForPool returns the speakers selected by the L2
+// advertisements of the pool falling back to their nodes when none has a
+// local endpoint.
+func fallbackSpeakersForPool(speakers map[string]bool, pool *config.Pool) map[string]bool {
+	res := map[string]bool{}
+	for s := range speakers {
+		for _, adv := range pool.L2Advertisements {
+			if adv.FallbackToSelectedNodes && adv.Nodes[s] {
+				res[s] = true
+				break
+			}
+		}
+	}
+	return res
+}
+
+// layer2State is the layer2 state of the speaker, served for debugging purposes.
+type layer2State struct {
+	Node          string               `json:"node"`
+	Announcements []layer2.AnnouncedIP `json:"announcements"`
+	Members       []speakerlist.Member `json:"members"`
+}
+
+// layer2StateHandler serves the IPs announced by this node and its view of
+// the memberlist cluster.
+func layer2StateHandler(l log.Logger, node string, announcer *layer2.Announce, members func() []speakerlist.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		state := layer2State{
+			Node:          node,
+			Announcements: announcer.Dump(),
+			Members:       members(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(state); err != nil {
+			level.Error(l).Log("op", "layer2State", "error", err, "msg", "failed to write the layer2 state")
+		}
+	}
+}
@@ -3,8 +3,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"sort"
 	"strings"
@@ -15,6 +18,7 @@ import (
 	"go.universe.tf/metallb/internal/k8s/epslices"
 	"go.universe.tf/metallb/internal/layer2"
 	"go.universe.tf/metallb/internal/pointer"
+	"go.universe.tf/metallb/internal/speakerlist"
 
 	"github.com/go-kit/log"
 	v1 "k8s.io/api/core/v1"
@@ -2344,6 +2348,299 @@ func TestShouldAnnounceNodeSelector(t *testing.T) {
 	}
 }
 
+func TestShouldAnnounceNodePreference(t *testing.T) {
+	fakeSL := &fakeSpeakerList{
+		speakers: map[string]bool{
+			"iris1": true,
+			"iris2": true,
+		},
+	}
+	speakers := map[string]*controller{}
+	for _, node := range []string{"iris1", "iris2"} {
+		c, err := newController(controllerConfig{
+			MyNode:  node,
+			Logger:  log.NewNopLogger(),
+			SList:   fakeSL,
+			bgpType: bgpNative,
+		})
+		if err != nil {
+			t.Fatalf("creating controller: %s", err)
+		}
+		c.client = &testK8S{t: t}
+		speakers[node] = c
+	}
+
+	epsOn := func(nodes ...string) epslices.EpsOrSlices {
+		endpoints := []discovery.Endpoint{}
+		for _, node := range nodes {
+			endpoints = append(endpoints, discovery.Endpoint{
+				Addresses: []string{"2.3.4.5"},
+				NodeName:  pointer.StrPtr(node),
+				Conditions: discovery.EndpointConditions{
+					Ready: pointer.BoolPtr(true),
+				},
+			})
+		}
+		return epslices.EpsOrSlices{
+			SlicesVal: []discovery.EndpointSlice{{Endpoints: endpoints}},
+			Type:      epslices.Slices,
+		}
+	}
+
+	tests := []struct {
+		desc             string
+		L2Advertisements []*config.L2Advertisement
+		eps              epslices.EpsOrSlices
+		trafficPolicy    v1.ServiceExternalTrafficPolicyType
+		nodeScores       map[string]int
+		nodeL2Weights    map[string]int
+		expectedOwner    string
+	}{
+		{
+			desc: "no preferences, the hash elects iris2",
+			L2Advertisements: []*config.L2Advertisement{
+				{Nodes: map[string]bool{"iris1": true, "iris2": true}},
+			},
+			eps:           epsOn("iris1", "iris2"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			expectedOwner: "iris2",
+		},
+		{
+			desc: "iris1 preferred",
+			L2Advertisements: []*config.L2Advertisement{
+				{
+					Nodes:       map[string]bool{"iris1": true, "iris2": true},
+					NodeWeights: map[string]int{"iris1": 10},
+				},
+			},
+			eps:           epsOn("iris1", "iris2"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			expectedOwner: "iris1",
+		},
+		{
+			desc: "same weight, the hash breaks the tie",
+			L2Advertisements: []*config.L2Advertisement{
+				{
+					Nodes:       map[string]bool{"iris1": true, "iris2": true},
+					NodeWeights: map[string]int{"iris1": 10, "iris2": 10},
+				},
+			},
+			eps:           epsOn("iris1", "iris2"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			expectedOwner: "iris2",
+		},
+		{
+			desc: "highest weight among the advertisements selecting the node",
+			L2Advertisements: []*config.L2Advertisement{
+				{
+					Nodes:       map[string]bool{"iris1": true, "iris2": true},
+					NodeWeights: map[string]int{"iris1": 10, "iris2": 20},
+				},
+				{
+					Nodes:       map[string]bool{"iris1": true},
+					NodeWeights: map[string]int{"iris1": 30},
+				},
+			},
+			eps:           epsOn("iris1", "iris2"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			expectedOwner: "iris1",
+		},
+		{
+			desc: "preference does not override the local traffic policy",
+			L2Advertisements: []*config.L2Advertisement{
+				{
+					Nodes:       map[string]bool{"iris1": true, "iris2": true},
+					NodeWeights: map[string]int{"iris2": 10},
+				},
+			},
+			eps:           epsOn("iris1"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
+			expectedOwner: "iris1",
+		},
+		{
+			desc: "lowest score wins",
+			L2Advertisements: []*config.L2Advertisement{
+				{Nodes: map[string]bool{"iris1": true, "iris2": true}},
+			},
+			eps:           epsOn("iris1", "iris2"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			nodeScores:    map[string]int{"iris1": 5, "iris2": 10},
+			expectedOwner: "iris1",
+		},
+		{
+			desc: "nodes with a score preferred over the ones without",
+			L2Advertisements: []*config.L2Advertisement{
+				{Nodes: map[string]bool{"iris1": true, "iris2": true}},
+			},
+			eps:           epsOn("iris1", "iris2"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			nodeScores:    map[string]int{"iris1": 50},
+			expectedOwner: "iris1",
+		},
+		{
+			desc: "same score, the hash breaks the tie",
+			L2Advertisements: []*config.L2Advertisement{
+				{Nodes: map[string]bool{"iris1": true, "iris2": true}},
+			},
+			eps:           epsOn("iris1", "iris2"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			nodeScores:    map[string]int{"iris1": 3, "iris2": 3},
+			expectedOwner: "iris2",
+		},
+		{
+			desc: "preference takes precedence over the score",
+			L2Advertisements: []*config.L2Advertisement{
+				{
+					Nodes:       map[string]bool{"iris1": true, "iris2": true},
+					NodeWeights: map[string]int{"iris2": 10},
+				},
+			},
+			eps:           epsOn("iris1", "iris2"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			nodeScores:    map[string]int{"iris1": 1, "iris2": 100},
+			expectedOwner: "iris2",
+		},
+		{
+			desc: "the weighted hash elects the heavier iris1",
+			L2Advertisements: []*config.L2Advertisement{
+				{Nodes: map[string]bool{"iris1": true, "iris2": true}},
+			},
+			eps:           epsOn("iris1", "iris2"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			nodeL2Weights: map[string]int{"iris1": 100},
+			expectedOwner: "iris1",
+		},
+		{
+			desc: "the preferences come before the node weights",
+			L2Advertisements: []*config.L2Advertisement{
+				{
+					Nodes:       map[string]bool{"iris1": true, "iris2": true},
+					NodeWeights: map[string]int{"iris2": 10},
+				},
+			},
+			eps:           epsOn("iris1", "iris2"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			nodeL2Weights: map[string]int{"iris1": 100},
+			expectedOwner: "iris2",
+		},
+		{
+			desc: "local endpoints only on not selected nodes",
+			L2Advertisements: []*config.L2Advertisement{
+				{Nodes: map[string]bool{"iris1": true, "iris2": true}},
+			},
+			eps:           epsOn("iris3"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
+			expectedOwner: "",
+		},
+		{
+			desc: "local endpoints only on not selected nodes, falling back to the selected ones",
+			L2Advertisements: []*config.L2Advertisement{
+				{
+					Nodes:                   map[string]bool{"iris1": true, "iris2": true},
+					FallbackToSelectedNodes: true,
+				},
+			},
+			eps:           epsOn("iris3"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
+			expectedOwner: "iris2",
+		},
+		{
+			desc: "falling back only to the nodes of the advertisements allowing it",
+			L2Advertisements: []*config.L2Advertisement{
+				{Nodes: map[string]bool{"iris2": true}},
+				{
+					Nodes:                   map[string]bool{"iris1": true},
+					FallbackToSelectedNodes: true,
+				},
+			},
+			eps:           epsOn("iris3"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
+			expectedOwner: "iris1",
+		},
+		{
+			desc: "no fallback when a selected node has a local endpoint",
+			L2Advertisements: []*config.L2Advertisement{
+				{
+					Nodes:                   map[string]bool{"iris1": true, "iris2": true},
+					FallbackToSelectedNodes: true,
+				},
+			},
+			eps:           epsOn("iris1", "iris3"),
+			trafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
+			expectedOwner: "iris1",
+		},
+	}
+	l := log.NewNopLogger()
+	for _, test := range tests {
+		cfg := config.Config{
+			Pools: &config.Pools{ByName: map[string]*config.Pool{
+				"default": {
+					CIDR:             []*net.IPNet{ipnet("10.20.30.0/24")},
+					L2Advertisements: test.L2Advertisements,
+				},
+			}},
+			NodeScores:    test.nodeScores,
+			NodeL2Weights: test.nodeL2Weights,
+		}
+		svc := v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: test.trafficPolicy,
+			},
+			Status: statusAssigned("10.20.30.1"),
+		}
+		lbIP := net.ParseIP(svc.Status.LoadBalancer.Ingress[0].IP)
+		for node, c := range speakers {
+			if c.SetConfig(l, &cfg) == controllers.SyncStateError {
+				t.Errorf("%q: SetConfig failed", test.desc)
+			}
+			expected := "notOwner"
+			if node == test.expectedOwner {
+				expected = ""
+			}
+			response := c.protocolHandlers[config.Layer2].ShouldAnnounce(l, "test1", []net.IP{lbIP}, cfg.Pools.ByName["default"], &svc, test.eps)
+			if response != expected {
+				t.Errorf("%q: shouldAnnounce for %s returned incorrect result, expected '%s', but received '%s'", test.desc, node, expected, response)
+			}
+		}
+	}
+}
+
+func TestL2WeightedElection(t *testing.T) {
+	speakers := map[string]bool{"iris1": true, "iris2": true, "iris3": true}
+	pool := &config.Pool{
+		L2Advertisements: []*config.L2Advertisement{
+			{Nodes: speakers},
+		},
+	}
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+		},
+	}
+	weights := map[string]int{"iris1": 6, "iris2": 3}
+
+	elected := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		ip := net.IPv4(10, 20, byte(i/256), byte(i%256))
+		leader := l2LeaderFor(speakers, ip, pool, svc, epslices.EpsOrSlices{}, nil, weights)
+		if again := l2LeaderFor(speakers, ip, pool, svc, epslices.EpsOrSlices{}, nil, weights); again != leader {
+			t.Fatalf("%s: election not deterministic, got %s and %s", ip, leader, again)
+		}
+		elected[leader]++
+	}
+
+	// iris3 has no weight, so it weighs 1: the expected shares are 60%,
+	// 30% and 10%.
+	expected := map[string]int{"iris1": 600, "iris2": 300, "iris3": 100}
+	for node, want := range expected {
+		if got := elected[node]; got < want-60 || got > want+60 {
+			t.Errorf("%s elected for %d IPs, expected about %d", node, got, want)
+		}
+	}
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
@@ -2615,6 +2912,26 @@ func TestIPAdvertisementFor(t *testing.T) {
 				},
 			},
 			expect: layer2.NewIPAdvertisement(net.IP{192, 168, 10, 3}, true, sets.Set[string]{}),
+		}, {
+			desc:      "L2Advertisements in a VRF",
+			ip:        net.IP{192, 168, 10, 3},
+			localNode: "nodeA",
+			l2Advertisements: []*config.L2Advertisement{
+				{
+					Nodes: map[string]bool{
+						"nodeA": true,
+					},
+					Interfaces: []string{"eth1"},
+					VRF:        "red",
+				}, {
+					Nodes: map[string]bool{
+						"nodeA": true,
+					},
+					Interfaces: []string{"eth2"},
+					VRF:        "red",
+				},
+			},
+			expect: layer2.NewVRFIPAdvertisement(net.IP{192, 168, 10, 3}, "red", false, sets.New("eth1", "eth2")),
 		},
 	}
 	for _, test := range tests {
@@ -2624,3 +2941,40 @@ func TestIPAdvertisementFor(t *testing.T) {
 		}
 	}
 }
+
+func TestLayer2StateHandler(t *testing.T) {
+	announcer, err := layer2.New(log.NewNopLogger(), 0, false, 0)
+	if err != nil {
+		t.Fatalf("creating announcer: %s", err)
+	}
+	announcer.SetBalancer("default/test1", layer2.NewIPAdvertisement(net.ParseIP("10.20.30.1"), true, sets.Set[string]{}))
+	members := func() []speakerlist.Member {
+		return []speakerlist.Member{{Name: "iris1", Addr: "192.168.1.1:7946", State: "alive"}}
+	}
+	handler := layer2StateHandler(log.NewNopLogger(), "iris1", announcer, members)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/debug/layer2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	var state layer2State
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatalf("failed to decode the state: %s", err)
+	}
+	if state.Node != "iris1" {
+		t.Errorf("unexpected node %q", state.Node)
+	}
+	if len(state.Announcements) != 1 || state.Announcements[0].Service != "default/test1" || state.Announcements[0].IP != "10.20.30.1" {
+		t.Errorf("unexpected announcements %+v", state.Announcements)
+	}
+	if len(state.Members) != 1 || state.Members[0].Name != "iris1" {
+		t.Errorf("unexpected members %+v", state.Members)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/debug/layer2", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected method not allowed, got %d", rec.Code)
+	}
+}
@@ -18,15 +18,19 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.universe.tf/metallb/internal/bgp"
+	bgpfrr "go.universe.tf/metallb/internal/bgp/frr"
 	"go.universe.tf/metallb/internal/config"
 	metallbcfg "go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/k8s"
@@ -34,11 +38,26 @@ import (
 	"go.universe.tf/metallb/internal/k8s/epslices"
 	"go.universe.tf/metallb/internal/layer2"
 	"go.universe.tf/metallb/internal/logging"
+	"go.universe.tf/metallb/internal/metrics"
 	"go.universe.tf/metallb/internal/speakerlist"
 	"go.universe.tf/metallb/internal/version"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
+// annotationMinEndpoints sets the minimum number of ready endpoints a service
+// must have to be announced.
+const annotationMinEndpoints = "metallb.universe.tf/min-endpoints"
+
+// annotationAnnounce set to false keeps the IPs allocated to the service
+// from being announced.
+const annotationAnnounce = "metallb.universe.tf/announce"
+
+// annotationReallocationStarted is set by the controller while the service is
+// moved to another pool, and its status holds both the current and the new
+// IPs.
+const annotationReallocationStarted = "metallb.universe.tf/reallocation-started"
+
 var announcing = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 	Namespace: "metallb",
 	Subsystem: "speaker",
@@ -51,6 +70,30 @@ var announcing = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 	"ip",
 })
 
+var dualModeServices = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "metallb",
+	Subsystem: "speaker",
+	Name:      "dual_mode_services",
+	Help:      "Services whose pool is advertised via both L2 and BGP, announced with both protocols.",
+}, []string{
+	"service",
+	"ip",
+})
+
+var maintenanceMode = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "metallb",
+	Subsystem: "speaker",
+	Name:      "maintenance_mode",
+	Help:      "1 while MetalLB is in maintenance mode and all the announcements are withdrawn.",
+})
+
+var defaultAdvertisementPools = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "metallb",
+	Subsystem: "speaker",
+	Name:      "default_bgp_advertisement_pools",
+	Help:      "Number of address pools not referenced by any advertisement, advertised with the default BGP advertisement.",
+})
+
 // Service offers methods to mutate a Kubernetes service object.
 type service interface {
 	UpdateStatus(svc *v1.Service) error
@@ -60,6 +103,9 @@ type service interface {
 
 func main() {
 	prometheus.MustRegister(announcing)
+	prometheus.MustRegister(defaultAdvertisementPools)
+	prometheus.MustRegister(dualModeServices)
+	prometheus.MustRegister(maintenanceMode)
 
 	var (
 		namespace         = flag.String("namespace", os.Getenv("METALLB_NAMESPACE"), "config file and speakers namespace")
@@ -74,6 +120,24 @@ func main() {
 		disableEpSlices   = flag.Bool("disable-epslices", false, "Disable the usage of EndpointSlices and default to Endpoints instead of relying on the autodiscovery mechanism")
 		enablePprof       = flag.Bool("enable-pprof", false, "Enable pprof profiling")
 		loadBalancerClass = flag.String("lb-class", "", "load balancer class. When enabled, metallb will handle only services whose spec.loadBalancerClass matches the given lb class")
+		claimClassless    = flag.Bool("lb-class-claim-classless", false, "when lb-class is set, also handle the services with no spec.loadBalancerClass")
+		serviceDebounce   = flag.Duration("service-debounce", 0, "coalesce the changes to a service received within this window into a single update. Zero disables debouncing")
+		defaultBGPAdv     = flag.Bool("default-bgp-advertisement", false, "advertise the address pools not referenced by any advertisement via BGP, with the default local preference and communities")
+		defaultLocalPref  = flag.Uint("default-bgp-localpref", 0, "local preference of the default BGP advertisement")
+		metricsPrefix     = flag.String("metrics-prefix", metrics.DefaultPrefix, "prefix of the names of the exported Prometheus metrics")
+		defaultComms      = flag.String("default-bgp-communities", "", "comma separated list of the communities of the default BGP advertisement")
+		l2MinMTU          = flag.Int("l2-min-mtu", 0, "do not announce L2 IPs on the interfaces with an MTU lower than this value. Zero disables the check")
+		l2VirtualMAC      = flag.Bool("l2-virtual-mac", false, "announce each L2 IP with a MAC derived from the IP instead of the MAC of the interfaces, so that it does not change on failover")
+		l2ReplyRate       = flag.Float64("l2-reply-rate", 0, "maximum number of ARP and NDP replies per second sent to each requester, the excess requests being dropped. Zero disables the limit")
+		nodeAddrTypes     = flag.String("node-address-types", "", "comma separated list of the types of the node addresses to use, InternalIP or ExternalIP, in order of preference. The address is the BGP source address, and so the next hop, of the peers with no source address, and the L2 IPs are announced on its interface. Empty keeps the default behaviour")
+		l2Hysteresis      = flag.Duration("l2-fallback-hysteresis", 10*time.Second, "how long a BGP session must stay established or down before the IPs of the pools preferring BGP switch between BGP and L2")
+		frrHoldDown       = flag.Duration("frr-restart-hold-down", 0, "in FRR mode, withhold the advertisements for this long after FRR (re)starts, so that it can establish the sessions first. Zero disables the hold-down")
+		peerSelector      = flag.String("peer-selector", "", "label selector of the BGPPeers this speaker establishes sessions with, the others being ignored. Empty selects all the peers")
+		configFile        = flag.String("config-file", "", "path of a file holding the MetalLB resources to use instead of the ones in the cluster, reloaded when it changes or on SIGHUP")
+		logAnnouncements  = flag.Bool("log-announcements", false, "log a line at info level every time the announcement of a service starts, changes or stops, with its IPs, pool, BGP peers and the reason")
+		healthProbes      = flag.Bool("enable-health-probes", false, "run the health probes set on the services with the health-probe annotation against their endpoints, withdrawing the services failing them")
+		reportServices    = flag.Bool("report-announced-services", false, "report the services announced from the node in the SpeakerReport of the node, for the controller run with --check-announced-services to tell the services no node announces")
+		reportIfaces      = flag.Bool("report-interfaces", false, "report the names of the interfaces of the node in the SpeakerReport of the node, for the controller run with --check-l2-interfaces to flag the L2Advertisements none of the selected nodes has any interface of")
 	)
 	flag.Parse()
 
@@ -101,6 +165,43 @@ func main() {
 		*namespace = string(bs)
 	}
 
+	if err := metrics.ValidatePrefix(*metricsPrefix); err != nil {
+		level.Error(logger).Log("op", "startup", "error", err, "msg", "invalid metrics prefix")
+		os.Exit(1)
+	}
+
+	if *l2MinMTU < 0 {
+		level.Error(logger).Log("op", "startup", "error", "the minimum MTU must not be negative", "msg", "invalid --l2-min-mtu")
+		os.Exit(1)
+	}
+
+	if *l2ReplyRate < 0 {
+		level.Error(logger).Log("op", "startup", "error", "the reply rate must not be negative", "msg", "invalid --l2-reply-rate")
+		os.Exit(1)
+	}
+
+	addressTypes, err := parseNodeAddressTypes(*nodeAddrTypes)
+	if err != nil {
+		level.Error(logger).Log("op", "startup", "error", err, "msg", "invalid --node-address-types")
+		os.Exit(1)
+	}
+
+	if *l2Hysteresis < 0 {
+		level.Error(logger).Log("op", "startup", "error", "the hysteresis must not be negative", "msg", "invalid --l2-fallback-hysteresis")
+		os.Exit(1)
+	}
+
+	if *frrHoldDown < 0 {
+		level.Error(logger).Log("op", "startup", "error", "the hold-down must not be negative", "msg", "invalid --frr-restart-hold-down")
+		os.Exit(1)
+	}
+
+	peerSel, err := labels.Parse(*peerSelector)
+	if err != nil {
+		level.Error(logger).Log("op", "startup", "error", err, "msg", "invalid --peer-selector")
+		os.Exit(1)
+	}
+
 	if *myNode == "" {
 		level.Error(logger).Log("op", "startup", "error", "must specify --node-name or METALLB_NODE_NAME", "msg", "missing configuration")
 		os.Exit(1)
@@ -117,29 +218,38 @@ func main() {
 	}()
 	defer level.Info(logger).Log("op", "shutdown", "msg", "done")
 
-	var mlSecret string
+	sList, err := speakerlist.New(logger, *myNode, *mlBindAddr, *mlBindPort, *mlSecretKeyPath, *namespace, *mlLabels, stopCh)
+	if err != nil {
+		os.Exit(1)
+	}
 
-	if *mlSecretKeyPath != "" {
-		mlSecretBytes, err := os.ReadFile(filepath.Join(*mlSecretKeyPath, k8s.MLSecretKeyName))
+	var defaultAdv *config.BGPAdvertisement
+	if *defaultBGPAdv {
+		defaultAdv, err = defaultBGPAdvertisement(*myNode, *defaultLocalPref, *defaultComms)
 		if err != nil {
-			level.Error(logger).Log("op", "startup", "error", err, "msg", "failed to read memberlist secret key file")
+			level.Error(logger).Log("op", "startup", "error", err, "msg", "invalid default BGP advertisement")
 			os.Exit(1)
 		}
-		mlSecret = string(mlSecretBytes)
 	}
 
-	sList, err := speakerlist.New(logger, *myNode, *mlBindAddr, *mlBindPort, mlSecret, *namespace, *mlLabels, stopCh)
-	if err != nil {
-		os.Exit(1)
-	}
+	bgpfrr.SetRestartHoldDown(*frrHoldDown)
 
 	// Setup all clients and speakers, config decides what is being done runtime.
 	ctrl, err := newController(controllerConfig{
-		MyNode:   *myNode,
-		Logger:   logger,
-		LogLevel: logging.Level(*logLevel),
-		SList:    sList,
-		bgpType:  bgpImplementation(bgpType),
+		MyNode:                  *myNode,
+		Logger:                  logger,
+		LogLevel:                logging.Level(*logLevel),
+		SList:                   sList,
+		bgpType:                 bgpImplementation(bgpType),
+		DefaultBGPAdvertisement: defaultAdv,
+		L2MinMTU:                *l2MinMTU,
+		L2VirtualMAC:            *l2VirtualMAC,
+		L2ReplyRate:             *l2ReplyRate,
+		NodeAddressTypes:        addressTypes,
+		L2FallbackHysteresis:    *l2Hysteresis,
+		PeerSelector:            peerSel,
+		LogAnnouncements:        *logAnnouncements,
+		HealthProbes:            *healthProbes,
 	})
 	if err != nil {
 		level.Error(logger).Log("op", "startup", "error", err, "msg", "failed to create MetalLB controller")
@@ -153,6 +263,15 @@ func main() {
 		validateConfig = metallbcfg.DiscardNativeOnly
 	}
 
+	handlers := map[string]http.Handler{}
+	if l2, ok := ctrl.protocolHandlers[config.Layer2].(*layer2Controller); ok {
+		handlers["/debug/layer2"] = layer2StateHandler(logger, *myNode, l2.announcer, sList.Members)
+	}
+	if bgpType == string(bgpFrr) {
+		handlers["/readyz"] = frrReadinessHandler()
+	}
+	handlers["/debug/failover"] = failoverHandler(logger, ctrl)
+
 	client, err := k8s.New(&k8s.Config{
 		ProcessName:     "metallb-speaker",
 		NodeName:        *myNode,
@@ -161,27 +280,46 @@ func main() {
 
 		MetricsHost:   *host,
 		MetricsPort:   *port,
+		MetricsPrefix: *metricsPrefix,
 		EnablePprof:   *enablePprof,
 		ReadEndpoints: true,
 		Namespace:     *namespace,
 
 		Listener: k8s.Listener{
-			ServiceChanged: ctrl.SetBalancer,
-			ConfigChanged:  ctrl.SetConfig,
-			NodeChanged:    ctrl.SetNode,
+			ServiceChanged:  ctrl.SetBalancer,
+			ConfigChanged:   ctrl.SetConfig,
+			NodeChanged:     ctrl.SetNode,
+			ServicePriority: ctrl.servicePriority,
 		},
 		ValidateConfig:    validateConfig,
 		LoadBalancerClass: *loadBalancerClass,
+		ClaimClassless:    *claimClassless,
+		ServiceDebounce:   *serviceDebounce,
+		ConfigFile:        *configFile,
+		Handlers:          handlers,
 	})
 	if err != nil {
 		level.Error(logger).Log("op", "startup", "error", err, "msg", "failed to create k8s client")
 		os.Exit(1)
 	}
 	ctrl.client = client
+	ctrl.probes.onChange = client.ForceSync
+	ctrl.readiness.onExpire = client.ForceSync
 
 	sList.Start(client)
 	defer sList.Stop()
 
+	if *reportIfaces {
+		go reportInterfaces(logger, client, *myNode, stopCh)
+	}
+	if *reportServices {
+		go reportAnnounced(logger, client, *myNode, &ctrl.reported, stopCh)
+	}
+	go ctrl.trackSessions(logger, client.ForceSync, stopCh)
+	if bgpCtrl, ok := ctrl.protocolHandlers[config.BGP].(*bgpController); ok {
+		go bgpCtrl.watchSourceAddresses(logger, stopCh)
+	}
+
 	if err := client.Run(stopCh); err != nil {
 		level.Error(logger).Log("op", "startup", "error", err, "msg", "failed to run k8s client")
 		os.Exit(1)
@@ -200,6 +338,30 @@ type controller struct {
 	svcIPs           map[string][]net.IP              // service name -> assigned IPs
 
 	protocols []config.Proto
+
+	// The services considered for announcement, to simulate failovers.
+	balancers balancerCache
+
+	// The health probes of the services.
+	probes probeManager
+
+	// The services announced from this node, reported to the controller.
+	reported announcedServices
+
+	// The types of the node addresses used, and the addresses of the
+	// node of those types.
+	addressTypes []v1.NodeAddressType
+	nodeAddrs    []net.IP
+
+	// The state of the BGP sessions, for the pools preferring BGP.
+	sessions sessionTracker
+
+	// When the services last had a ready endpoint, for the
+	// advertisements with a not ready grace period.
+	readiness readinessTracker
+
+	// Logs the changes of the announcements, nil if disabled.
+	announcementLog *announcementLogger
 }
 
 type controllerConfig struct {
@@ -210,6 +372,41 @@ type controllerConfig struct {
 
 	bgpType bgpImplementation
 
+	// The advertisement applied to the pools not referenced by any
+	// advertisement, nil if disabled.
+	DefaultBGPAdvertisement *config.BGPAdvertisement
+
+	// The MTU below which the interfaces are not used for L2 announcements,
+	// 0 to use all of them.
+	L2MinMTU int
+
+	// Announce the L2 IPs with a MAC derived from them instead of the MAC
+	// of the interfaces.
+	L2VirtualMAC bool
+
+	// The maximum number of ARP and NDP replies sent per second to each
+	// requester, 0 to not limit them.
+	L2ReplyRate float64
+
+	// The types of the node addresses used as the BGP source address and
+	// to pick the L2 interfaces, in order of preference. Empty to keep
+	// the default behaviour.
+	NodeAddressTypes []v1.NodeAddressType
+
+	// How long a BGP session must stay in a state before the IPs of the
+	// pools preferring BGP switch mode.
+	L2FallbackHysteresis time.Duration
+
+	// The selector of the labels of the peers to establish sessions
+	// with, nil to select all of them.
+	PeerSelector labels.Selector
+
+	// Log every change of the announcements at info level.
+	LogAnnouncements bool
+
+	// Run the health probes set with the annotations of the services.
+	HealthProbes bool
+
 	// For testing only, and will be removed in a future release.
 	// See: https://github.com/metallb/metallb/issues/152.
 	DisableLayer2      bool
@@ -222,22 +419,32 @@ func newController(cfg controllerConfig) (*controller, error) {
 			logger:         cfg.Logger,
 			myNode:         cfg.MyNode,
 			svcAds:         make(map[string][]*bgp.Advertisement),
+			nodeAdvs:       make(map[string]*config.NodeAdvertisement),
+			nodeChecks:     make(map[string]*nodeHealthChecker),
+			nodeAds:        make(map[string][]*bgp.Advertisement),
 			bgpType:        cfg.bgpType,
 			sessionManager: newBGP(cfg.bgpType, cfg.Logger, cfg.LogLevel),
+			defaultAdv:     cfg.DefaultBGPAdvertisement,
+			addressTypes:   cfg.NodeAddressTypes,
+			peerSelector:   cfg.PeerSelector,
+			sList:          cfg.SList,
 		},
 	}
 	protocols := []config.Proto{config.BGP}
 
 	if !cfg.DisableLayer2 {
-		a, err := layer2.New(cfg.Logger)
+		a, err := layer2.New(cfg.Logger, cfg.L2MinMTU, cfg.L2VirtualMAC, cfg.L2ReplyRate)
 		if err != nil {
 			return nil, fmt.Errorf("making layer2 announcer: %s", err)
 		}
-		handlers[config.Layer2] = &layer2Controller{
-			announcer: a,
-			myNode:    cfg.MyNode,
-			sList:     cfg.SList,
+		l2 := &layer2Controller{
+			announcer:    a,
+			myNode:       cfg.MyNode,
+			sList:        cfg.SList,
+			addressTypes: cfg.NodeAddressTypes,
 		}
+		a.OnForeignAnnouncement(l2.foreignAnnouncement)
+		handlers[config.Layer2] = l2
 		protocols = append(protocols, config.Layer2)
 	}
 
@@ -248,6 +455,12 @@ func newController(cfg controllerConfig) (*controller, error) {
 		announced:        map[config.Proto]map[string]bool{},
 		svcIPs:           map[string][]net.IP{},
 		protocols:        protocols,
+		addressTypes:     cfg.NodeAddressTypes,
+		sessions:         sessionTracker{hysteresis: cfg.L2FallbackHysteresis},
+		probes:           probeManager{enabled: cfg.HealthProbes},
+	}
+	if cfg.LogAnnouncements {
+		ret.announcementLog = newAnnouncementLogger(cfg.Logger)
 	}
 	ret.announced[config.BGP] = map[string]bool{}
 	ret.announced[config.Layer2] = map[string]bool{}
@@ -256,11 +469,35 @@ func newController(cfg controllerConfig) (*controller, error) {
 }
 
 func (c *controller) SetBalancer(l log.Logger, name string, svc *v1.Service, eps epslices.EpsOrSlices) controllers.SyncState {
+	// The probe is run once for the service, whether its IPs are being
+	// reallocated or not.
+	if err := c.probes.update(l, name, svc, eps); err != nil {
+		level.Error(l).Log("op", "setBalancer", "error", err, "msg", "ignoring invalid annotation")
+		c.client.Errorf(svc, "invalidAnnotation", "ignoring health probe: %s", err)
+	}
+	current, reallocated := splitReallocation(svc)
+	if reallocated == nil {
+		// The IPs are announced with the service name first, so the ones
+		// moved from the reallocation are not withdrawn in between.
+		if st := c.setBalancer(l, name, svc, eps); st == controllers.SyncStateError {
+			return st
+		}
+		return c.deleteBalancer(l, reallocationName(name), "reallocationDone")
+	}
+	if st := c.setBalancer(l, name, current, eps); st == controllers.SyncStateError {
+		return st
+	}
+	return c.setBalancer(l, reallocationName(name), reallocated, eps)
+}
+
+func (c *controller) setBalancer(l log.Logger, name string, svc *v1.Service, eps epslices.EpsOrSlices) controllers.SyncState {
 	if svc == nil {
+		c.readiness.forget(name)
 		return c.deleteBalancer(l, name, "serviceDeleted")
 	}
 
 	if svc.Spec.Type != "LoadBalancer" {
+		c.readiness.forget(name)
 		return c.deleteBalancer(l, name, "notLoadBalancer")
 	}
 
@@ -272,10 +509,19 @@ func (c *controller) SetBalancer(l log.Logger, name string, svc *v1.Service, eps
 		return controllers.SyncStateSuccess
 	}
 
+	if c.config.InMaintenance() {
+		return c.deleteBalancer(l, name, "maintenance")
+	}
+
 	if len(svc.Status.LoadBalancer.Ingress) == 0 {
 		return c.deleteBalancer(l, name, "noIPAllocated")
 	}
 
+	if svc.Annotations[annotationAnnounce] == "false" {
+		level.Debug(l).Log("event", "withdraw", "msg", "announcement disabled by the announce annotation")
+		return c.deleteBalancer(l, name, "announceDisabled")
+	}
+
 	lbIPs := []net.IP{}
 	for i := range svc.Status.LoadBalancer.Ingress {
 		lbIP := net.ParseIP(svc.Status.LoadBalancer.Ingress[i].IP)
@@ -301,6 +547,23 @@ func (c *controller) SetBalancer(l log.Logger, name string, svc *v1.Service, eps
 	}
 	pool := c.config.Pools.ByName[poolName]
 
+	if minEndpoints, err := minEndpointsFor(svc); err != nil {
+		level.Error(l).Log("op", "setBalancer", "error", err, "msg", "ignoring invalid annotation")
+		c.client.Errorf(svc, "invalidAnnotation", "ignoring %s: %s", annotationMinEndpoints, err)
+	} else if ready := healthyEndpoints(eps, func(*string) bool { return false }); ready < minEndpoints {
+		level.Debug(l).Log("event", "withdraw", "msg", "not enough ready endpoints", "ready", ready, "min", minEndpoints)
+		return c.deleteBalancer(l, name, "notEnoughEndpoints")
+	}
+
+	if !c.probes.healthy(strings.TrimSuffix(name, reallocationName(""))) {
+		level.Debug(l).Log("event", "withdraw", "msg", "health probe failing")
+		return c.deleteBalancer(l, name, "healthProbeFailed")
+	}
+
+	c.readiness.observe(name, hasHealthyEndpoint(eps, func(*string) bool { return false }), time.Now())
+
+	c.balancers.set(name, &cachedBalancer{ips: lbIPs, pool: pool, svc: svc, eps: eps})
+
 	if svcIPs, ok := c.svcIPs[name]; ok && !compareIPs(lbIPs, svcIPs) {
 		if st := c.deleteBalancer(l, name, "loadBalancerIPChanged"); st == controllers.SyncStateError {
 			return st
@@ -313,9 +576,57 @@ func (c *controller) SetBalancer(l log.Logger, name string, svc *v1.Service, eps
 		}
 	}
 
+	dualModeServices.DeletePartialMatch(prometheus.Labels{"service": name})
+	if pool.DualMode() {
+		for _, ip := range lbIPs {
+			dualModeServices.WithLabelValues(name, ip.String()).Set(1)
+		}
+	}
+
 	return controllers.SyncStateSuccess
 }
 
+// splitReallocation returns, when the service is being moved to another
+// pool, a copy of the service with its current IPs and one with the IPs
+// allocated from the target pool. The controller lists the current IPs
+// first, and as many new IPs as the family of the service is immutable.
+func splitReallocation(svc *v1.Service) (*v1.Service, *v1.Service) {
+	if svc == nil {
+		return svc, nil
+	}
+	if _, ok := svc.Annotations[annotationReallocationStarted]; !ok {
+		return svc, nil
+	}
+	ingress := svc.Status.LoadBalancer.Ingress
+	if len(ingress) == 0 || len(ingress)%2 != 0 {
+		return svc, nil
+	}
+	current, reallocated := svc.DeepCopy(), svc.DeepCopy()
+	current.Status.LoadBalancer.Ingress = ingress[:len(ingress)/2]
+	reallocated.Status.LoadBalancer.Ingress = ingress[len(ingress)/2:]
+	return current, reallocated
+}
+
+// reallocationName is the name the IPs allocated from the target pool of a
+// service being reallocated are announced with.
+func reallocationName(name string) string {
+	return name + "/reallocation"
+}
+
+// minEndpointsFor returns the minimum number of ready endpoints the service
+// needs to be announced, as set by the min-endpoints annotation.
+func minEndpointsFor(svc *v1.Service) (int, error) {
+	value, ok := svc.Annotations[annotationMinEndpoints]
+	if !ok {
+		return 0, nil
+	}
+	min, err := strconv.Atoi(value)
+	if err != nil || min < 1 {
+		return 0, fmt.Errorf("invalid value %q, must be a positive integer", value)
+	}
+	return min, nil
+}
+
 func (c *controller) handleService(l log.Logger,
 	name string,
 	lbIPs []net.IP,
@@ -330,6 +641,15 @@ func (c *controller) handleService(l log.Logger,
 		return c.deleteBalancerProtocol(l, protocol, name, "internalError")
 	}
 
+	if protocol == config.Layer2 && c.bgpPreferred(pool) {
+		return c.deleteBalancerProtocol(l, protocol, name, "bgpPreferred")
+	}
+
+	if grace := notReadyGracePeriod(pool, protocol, c.myNode); grace > 0 && c.readiness.inGrace(name, grace, time.Now()) {
+		level.Debug(l).Log("event", "notReadyGracePeriod", "msg", "no endpoint is ready, announcing from the nodes with an endpoint during the grace period")
+		eps = allEndpointsReady(eps)
+	}
+
 	if deleteReason := handler.ShouldAnnounce(l, name, lbIPs, pool, svc, eps); deleteReason != "" {
 		return c.deleteBalancerProtocol(l, protocol, name, deleteReason)
 	}
@@ -343,6 +663,7 @@ func (c *controller) handleService(l log.Logger,
 		c.announced[protocol][name] = true
 		c.svcIPs[name] = lbIPs
 	}
+	c.reported.set(name)
 
 	for _, ip := range lbIPs {
 		announcing.With(prometheus.Labels{
@@ -352,12 +673,17 @@ func (c *controller) handleService(l log.Logger,
 			"ip":       ip.String(),
 		}).Set(1)
 	}
+	if c.announcementLog != nil {
+		c.announcementLog.announced(protocol, name, lbIPs, pool.Name, c.advertisedPeers(protocol, name))
+	}
 	level.Info(l).Log("event", "serviceAnnounced", "msg", "service has IP, announcing", "protocol", protocol)
 	c.client.Infof(svc, "nodeAssigned", "announcing from node %q with protocol %q", c.myNode, protocol)
 	return controllers.SyncStateSuccess
 }
 
 func (c *controller) deleteBalancer(l log.Logger, name, reason string) controllers.SyncState {
+	c.balancers.delete(name)
+	dualModeServices.DeletePartialMatch(prometheus.Labels{"service": name})
 	for _, protocol := range c.protocols {
 		if st := c.deleteBalancerProtocol(l, protocol, name, reason); st == controllers.SyncStateError {
 			return st
@@ -372,10 +698,21 @@ func (c *controller) deleteBalancerProtocol(l log.Logger, protocol config.Proto,
 		return controllers.SyncStateSuccess
 	}
 
+	var peers []string
+	if c.announcementLog != nil {
+		peers = c.advertisedPeers(protocol, name)
+	}
 	if err := c.protocolHandlers[protocol].DeleteBalancer(l, name, reason); err != nil {
 		level.Error(l).Log("op", "deleteBalancer", "error", err, "msg", "failed to clear balancer state", "protocol", protocol)
 		return controllers.SyncStateError
 	}
+	if c.announcementLog != nil {
+		pool := ""
+		if c.config != nil {
+			pool = poolFor(c.config.Pools, c.svcIPs[name])
+		}
+		c.announcementLog.withdrawn(protocol, name, c.svcIPs[name], pool, peers, reason)
+	}
 
 	for _, ip := range c.svcIPs[name] {
 		ok := announcing.Delete(prometheus.Labels{
@@ -397,11 +734,22 @@ func (c *controller) deleteBalancerProtocol(l log.Logger, protocol config.Proto,
 		}
 	}
 	delete(c.svcIPs, name)
+	c.reported.delete(name)
 	level.Info(l).Log("event", "serviceWithdrawn", "ip", c.svcIPs[name], "reason", reason, "msg", "withdrawing service announcement")
 
 	return controllers.SyncStateSuccess
 }
 
+// advertisedPeers returns the names of the peers the service is advertised
+// to with the protocol, none for L2.
+func (c *controller) advertisedPeers(protocol config.Proto, name string) []string {
+	bgpCtrl, ok := c.protocolHandlers[protocol].(*bgpController)
+	if !ok {
+		return nil
+	}
+	return bgpCtrl.advertisedPeers(name)
+}
+
 func poolFor(pools *config.Pools, ips []net.IP) string {
 	if pools == nil {
 		return ""
@@ -467,6 +815,25 @@ func (c *controller) SetConfig(l log.Logger, cfg *config.Config) controllers.Syn
 		}
 	}
 
+	for _, pool := range cfg.Pools.ByName {
+		if err := config.ValidateDualMode(pool); err != nil {
+			level.Warn(l).Log("op", "setConfig", "pool", pool.Name, "error", err, "msg", "no node announces the pool via both L2 and BGP")
+		}
+	}
+
+	if cfg.InMaintenance() != (c.config != nil && c.config.InMaintenance()) {
+		if cfg.InMaintenance() {
+			level.Info(l).Log("op", "setConfig", "maintenances", fmt.Sprint(cfg.Maintenances), "msg", "entering maintenance mode, withdrawing all the announcements")
+		} else {
+			level.Info(l).Log("op", "setConfig", "msg", "leaving maintenance mode, resuming the announcements")
+		}
+	}
+	if cfg.InMaintenance() {
+		maintenanceMode.Set(1)
+	} else {
+		maintenanceMode.Set(0)
+	}
+
 	c.config = cfg
 
 	return controllers.SyncStateReprocessAll
@@ -479,7 +846,19 @@ func (c *controller) SetNode(l log.Logger, node *v1.Node) controllers.SyncState
 			return controllers.SyncStateError
 		}
 	}
-	return controllers.SyncStateSuccess
+	if len(c.addressTypes) == 0 {
+		return controllers.SyncStateSuccess
+	}
+	addrs := nodeAddresses(node, c.addressTypes)
+	if sameIPs(c.nodeAddrs, addrs) {
+		return controllers.SyncStateSuccess
+	}
+	c.nodeAddrs = addrs
+	if len(addrs) == 0 {
+		level.Error(l).Log("op", "setNode", "types", fmt.Sprint(c.addressTypes), "msg", "the node has no address of the node address types, using the default addresses")
+	}
+	// The L2 IPs are announced on the interfaces of the new addresses.
+	return controllers.SyncStateReprocessAll
 }
 
 // A Protocol can advertise an IP address.
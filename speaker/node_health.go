@@ -0,0 +1,85 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"go.universe.tf/metallb/internal/config"
+)
+
+// nodeHealthChecker periodically runs the health check of a node
+// advertisement, reporting when its outcome changes.
+type nodeHealthChecker struct {
+	name   string
+	check  *config.NodeHealthCheck
+	stopCh chan struct{}
+}
+
+func newNodeHealthChecker(name string, check *config.NodeHealthCheck) *nodeHealthChecker {
+	return &nodeHealthChecker{
+		name:   name,
+		check:  check,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// run performs the check until the checker is stopped. The advertisement
+// starts as unhealthy, and it is considered unhealthy again after the
+// configured number of consecutive failures.
+func (h *nodeHealthChecker) run(onChange func(*nodeHealthChecker, bool)) {
+	ticker := time.NewTicker(h.check.Interval)
+	defer ticker.Stop()
+
+	healthy, failures := false, 0
+	for {
+		if err := probeNodeHealth(h.check); err != nil {
+			failures++
+			if healthy && failures >= h.check.FailureThreshold {
+				healthy = false
+				onChange(h, false)
+			}
+		} else {
+			failures = 0
+			if !healthy {
+				healthy = true
+				onChange(h, true)
+			}
+		}
+
+		select {
+		case <-h.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *nodeHealthChecker) stop() {
+	close(h.stopCh)
+}
+
+// probeNodeHealth performs the given check once, returning an error if it fails.
+var probeNodeHealth = func(check *config.NodeHealthCheck) error {
+	if check.Path == "" {
+		conn, err := net.DialTimeout("tcp", check.Address, check.Timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	client := http.Client{Timeout: check.Timeout}
+	resp, err := client.Get("http://" + check.Address + check.Path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// parseNodeAddressTypes parses the comma separated list of the types of the
+// node addresses the speaker uses, in order of preference.
+func parseNodeAddressTypes(s string) ([]v1.NodeAddressType, error) {
+	if s == "" {
+		return nil, nil
+	}
+	res := []v1.NodeAddressType{}
+	seen := map[v1.NodeAddressType]bool{}
+	for _, t := range strings.Split(s, ",") {
+		addrType := v1.NodeAddressType(strings.TrimSpace(t))
+		if addrType != v1.NodeInternalIP && addrType != v1.NodeExternalIP {
+			return nil, fmt.Errorf("invalid node address type %q, must be %s or %s", addrType, v1.NodeInternalIP, v1.NodeExternalIP)
+		}
+		if seen[addrType] {
+			return nil, fmt.Errorf("duplicate node address type %q", addrType)
+		}
+		seen[addrType] = true
+		res = append(res, addrType)
+	}
+	return res, nil
+}
+
+// nodeAddresses returns the addresses of the node the speaker uses, the
+// first one of each family in the order of the given types.
+func nodeAddresses(node *v1.Node, types []v1.NodeAddressType) []net.IP {
+	var v4, v6 net.IP
+	for _, t := range types {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != t {
+				continue
+			}
+			ip := net.ParseIP(addr.Address)
+			switch {
+			case ip == nil:
+			case ip.To4() != nil && v4 == nil:
+				v4 = ip
+			case ip.To4() == nil && v6 == nil:
+				v6 = ip
+			}
+		}
+	}
+	var res []net.IP
+	if v4 != nil {
+		res = append(res, v4)
+	}
+	if v6 != nil {
+		res = append(res, v6)
+	}
+	return res
+}
+
+// addressForFamily returns the address among addrs of the family of ip,
+// nil if there is none.
+func addressForFamily(addrs []net.IP, ip net.IP) net.IP {
+	for _, addr := range addrs {
+		if (addr.To4() == nil) == (ip.To4() == nil) {
+			return addr
+		}
+	}
+	return nil
+}
+
+func sameIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// interfacesWithIPs returns the names of the local interfaces holding any
+// of the given IPs.
+var interfacesWithIPs = func(ips []net.IP) (sets.Set[string], error) {
+	res := sets.Set[string]{}
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, intf := range ifs {
+		addrs, err := intf.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			for _, ip := range ips {
+				if ipnet.IP.Equal(ip) {
+					res.Insert(intf.Name)
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// sysClassNet is where the network devices are described in sysfs.
+var sysClassNet = "/sys/class/net"
+
+// sourceAddressCheckInterval is how often the source addresses of the BGP
+// sessions are checked for changes.
+var sourceAddressCheckInterval = 10 * time.Second
+
+// vrfAddresses returns the addresses of the given VRF on the node: the ones
+// of the VRF device, which acts as the loopback of the VRF, followed by the
+// ones of the interfaces enslaved to it. The link local addresses are
+// skipped, as they can't be used as the source of the BGP sessions.
+var vrfAddresses = func(vrf string) ([]net.IP, error) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	var own, enslaved []net.IP
+	for _, intf := range ifs {
+		isVRF := intf.Name == vrf
+		if !isVRF && interfaceMaster(intf.Name) != vrf {
+			continue
+		}
+		found = found || isVRF
+		addrs, err := intf.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			if isVRF {
+				own = append(own, ipnet.IP)
+				continue
+			}
+			enslaved = append(enslaved, ipnet.IP)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("vrf %s not found on the node", vrf)
+	}
+	return append(own, enslaved...), nil
+}
+
+// interfaceMaster returns the name of the device the interface is enslaved
+// to, or an empty string if it's not enslaved.
+func interfaceMaster(name string) string {
+	target, err := os.Readlink(filepath.Join(sysClassNet, name, "master"))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
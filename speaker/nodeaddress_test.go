@@ -0,0 +1,341 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestParseNodeAddressTypes(t *testing.T) {
+	tests := []struct {
+		desc    string
+		value   string
+		want    []v1.NodeAddressType
+		wantErr bool
+	}{
+		{
+			desc: "empty",
+		},
+		{
+			desc:  "ordered types",
+			value: "ExternalIP, InternalIP",
+			want:  []v1.NodeAddressType{v1.NodeExternalIP, v1.NodeInternalIP},
+		},
+		{
+			desc:    "not an IP type",
+			value:   "Hostname",
+			wantErr: true,
+		},
+		{
+			desc:    "duplicate type",
+			value:   "InternalIP,InternalIP",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		got, err := parseNodeAddressTypes(test.value)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.desc, err)
+			continue
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: unexpected types (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestNodeAddresses(t *testing.T) {
+	node := &v1.Node{
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "pandora"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: v1.NodeInternalIP, Address: "fd00::1"},
+				{Type: v1.NodeExternalIP, Address: "192.0.2.1"},
+			},
+		},
+	}
+	tests := []struct {
+		desc  string
+		types []v1.NodeAddressType
+		want  []net.IP
+	}{
+		{
+			desc: "no types",
+		},
+		{
+			desc:  "internal first",
+			types: []v1.NodeAddressType{v1.NodeInternalIP, v1.NodeExternalIP},
+			want:  []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")},
+		},
+		{
+			desc:  "external first, internal for the other family",
+			types: []v1.NodeAddressType{v1.NodeExternalIP, v1.NodeInternalIP},
+			want:  []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("fd00::1")},
+		},
+		{
+			desc:  "external only",
+			types: []v1.NodeAddressType{v1.NodeExternalIP},
+			want:  []net.IP{net.ParseIP("192.0.2.1")},
+		},
+	}
+	for _, test := range tests {
+		if got := nodeAddresses(node, test.types); !sameIPs(got, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.desc, test.want, got)
+		}
+	}
+}
+
+func TestNodeAddressSourceAddress(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:           "pandora",
+		DisableLayer2:    true,
+		bgpType:          bgpFrr,
+		NodeAddressTypes: []v1.NodeAddressType{v1.NodeExternalIP},
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+			"peer2": {
+				Addr:          net.ParseIP("1.2.3.5"),
+				SrcAddr:       net.ParseIP("10.0.0.2"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{}},
+	}
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+
+	node := func(external string) *v1.Node {
+		return &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "pandora"},
+			Status: v1.NodeStatus{
+				Addresses: []v1.NodeAddress{
+					{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+					{Type: v1.NodeExternalIP, Address: external},
+				},
+			},
+		}
+	}
+	sources := func(want map[string]string) {
+		t.Helper()
+		b.sessionManager.Lock()
+		defer b.sessionManager.Unlock()
+		for peer, src := range want {
+			if got := b.sessionManager.gotSources[peer]; !got.Equal(net.ParseIP(src)) {
+				t.Errorf("expected the source address of %s to be %s, got %s", peer, src, got)
+			}
+		}
+	}
+
+	if c.SetNode(l, node("192.0.2.1")) != controllers.SyncStateReprocessAll {
+		t.Fatal("expected the services to be reprocessed when the node address is set")
+	}
+	sources(map[string]string{"1.2.3.4:0": "192.0.2.1", "1.2.3.5:0": "10.0.0.2"})
+
+	if c.SetNode(l, node("192.0.2.1")) != controllers.SyncStateSuccess {
+		t.Fatal("expected no reprocessing when the node address does not change")
+	}
+
+	// The sessions with no source address restart with the new one.
+	if c.SetNode(l, node("192.0.2.2")) != controllers.SyncStateReprocessAll {
+		t.Fatal("expected the services to be reprocessed when the node address changes")
+	}
+	sources(map[string]string{"1.2.3.4:0": "192.0.2.2", "1.2.3.5:0": "10.0.0.2"})
+}
+
+func TestVRFSourceAddress(t *testing.T) {
+	oldVRFAddresses := vrfAddresses
+	defer func() { vrfAddresses = oldVRFAddresses }()
+	vrfAddresses = func(vrf string) ([]net.IP, error) {
+		switch vrf {
+		case "red":
+			return []net.IP{net.ParseIP("10.1.1.1"), net.ParseIP("fc00:1::1"), net.ParseIP("10.1.2.1")}, nil
+		case "blue":
+			return []net.IP{net.ParseIP("10.2.1.1")}, nil
+		}
+		return nil, fmt.Errorf("vrf %s not found on the node", vrf)
+	}
+
+	c := &bgpController{nodeAddrs: []net.IP{net.ParseIP("192.0.2.1")}}
+	tests := []struct {
+		desc    string
+		peer    *config.Peer
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "default vrf, node address",
+			peer: &config.Peer{Addr: net.ParseIP("1.2.3.4")},
+			want: "192.0.2.1",
+		},
+		{
+			desc: "address of the vrf",
+			peer: &config.Peer{Addr: net.ParseIP("1.2.3.4"), VRF: "red"},
+			want: "10.1.1.1",
+		},
+		{
+			desc: "address of the vrf of the family of the peer",
+			peer: &config.Peer{Addr: net.ParseIP("fc00:f::1"), VRF: "red"},
+			want: "fc00:1::1",
+		},
+		{
+			desc: "address of another vrf",
+			peer: &config.Peer{Addr: net.ParseIP("1.2.3.4"), VRF: "blue"},
+			want: "10.2.1.1",
+		},
+		{
+			desc: "no address of the family of the peer in the vrf",
+			peer: &config.Peer{Addr: net.ParseIP("fc00:f::1"), VRF: "blue"},
+		},
+		{
+			desc: "source address in the vrf",
+			peer: &config.Peer{Addr: net.ParseIP("1.2.3.4"), VRF: "red", SrcAddr: net.ParseIP("10.1.2.1")},
+			want: "10.1.2.1",
+		},
+		{
+			desc: "source address not in the vrf, rejected by the validation",
+			peer: &config.Peer{Addr: net.ParseIP("1.2.3.4"), VRF: "blue", SrcAddr: net.ParseIP("10.1.1.1")},
+			want: "10.1.1.1",
+		},
+		{
+			desc:    "vrf not on the node",
+			peer:    &config.Peer{Addr: net.ParseIP("1.2.3.4"), VRF: "green"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		got, err := c.sourceAddressFor(test.peer)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.desc, err)
+			continue
+		}
+		if (test.want == "" && got != nil) || (test.want != "" && !got.Equal(net.ParseIP(test.want))) {
+			t.Errorf("%s: expected %q, got %s", test.desc, test.want, got)
+		}
+	}
+}
+
+func TestVRFSourceAddressChanges(t *testing.T) {
+	oldVRFAddresses := vrfAddresses
+	defer func() { vrfAddresses = oldVRFAddresses }()
+	redAddrs := []net.IP{net.ParseIP("10.1.1.1")}
+	vrfAddresses = func(vrf string) ([]net.IP, error) {
+		if vrf == "red" {
+			return redAddrs, nil
+		}
+		return nil, fmt.Errorf("vrf %s not found on the node", vrf)
+	}
+
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+	l := log.NewNopLogger()
+
+	peers := func(src string) *config.Config {
+		return &config.Config{
+			Peers: map[string]*config.Peer{
+				"red": {
+					Name:          "red",
+					Addr:          net.ParseIP("1.2.3.4"),
+					VRF:           "red",
+					SrcAddr:       net.ParseIP(src),
+					NodeSelectors: []labels.Selector{labels.Everything()},
+				},
+				"other": {
+					Name:          "other",
+					Addr:          net.ParseIP("1.2.3.5"),
+					VRF:           "red",
+					NodeSelectors: []labels.Selector{labels.Everything()},
+				},
+			},
+			Pools: &config.Pools{ByName: map[string]*config.Pool{}},
+		}
+	}
+
+	// A source address not in the vrf on the node rejects the configuration.
+	if c.SetConfig(l, peers("10.9.9.9")) != controllers.SyncStateErrorNoRetry {
+		t.Fatal("expected the configuration with a source address not in the vrf to be rejected")
+	}
+	if c.SetConfig(l, peers("10.1.1.1")) != controllers.SyncStateReprocessAll {
+		t.Fatal("SetConfig failed")
+	}
+	source := func(peer, want string) {
+		t.Helper()
+		b.sessionManager.Lock()
+		defer b.sessionManager.Unlock()
+		if got := b.sessionManager.gotSources[peer]; !got.Equal(net.ParseIP(want)) {
+			t.Errorf("expected the source address of %s to be %s, got %s", peer, want, got)
+		}
+	}
+	source("1.2.3.5:0", "10.1.1.1")
+
+	bgpCtrl := c.protocolHandlers[config.BGP].(*bgpController)
+	bgpCtrl.Lock()
+	restarted := bgpCtrl.restartMovedSessions(l)
+	bgpCtrl.Unlock()
+	if restarted {
+		t.Fatal("expected no session to be restarted with the vrf addresses unchanged")
+	}
+
+	// The sessions with no source address follow the address of the vrf.
+	redAddrs = []net.IP{net.ParseIP("10.1.1.2")}
+	bgpCtrl.Lock()
+	restarted = bgpCtrl.restartMovedSessions(l)
+	if err := bgpCtrl.syncPeers(l); err != nil {
+		t.Errorf("syncPeers failed: %s", err)
+	}
+	bgpCtrl.Unlock()
+	if !restarted {
+		t.Fatal("expected the session to be restarted when the address of the vrf changes")
+	}
+	source("1.2.3.5:0", "10.1.1.2")
+	source("1.2.3.4:0", "10.1.1.1")
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+)
+
+// readinessTracker records when the services last had a ready endpoint, for
+// the advertisements with a not ready grace period to keep announcing them
+// for that period once none is ready.
+type readinessTracker struct {
+	sync.Mutex
+	services map[string]*trackedReadiness
+	// onExpire is called when the grace period of a service expires, to
+	// reprocess it.
+	onExpire func()
+}
+
+type trackedReadiness struct {
+	ready     bool
+	lastReady time.Time
+	timer     *time.Timer
+}
+
+// observe records whether the service has a ready endpoint at the given
+// time.
+func (t *readinessTracker) observe(name string, ready bool, now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	if t.services == nil {
+		t.services = map[string]*trackedReadiness{}
+	}
+	s, ok := t.services[name]
+	if !ok {
+		if !ready {
+			// Never seen ready, there is no grace period to give.
+			return
+		}
+		s = &trackedReadiness{}
+		t.services[name] = s
+	}
+	s.ready = ready
+	if ready {
+		s.lastReady = now
+		if s.timer != nil {
+			s.timer.Stop()
+			s.timer = nil
+		}
+	}
+}
+
+// inGrace returns true if none of the endpoints of the service is ready,
+// but one was less than grace ago. The service is reprocessed once the
+// grace period expires.
+func (t *readinessTracker) inGrace(name string, grace time.Duration, now time.Time) bool {
+	t.Lock()
+	defer t.Unlock()
+	s, ok := t.services[name]
+	if !ok || s.ready {
+		return false
+	}
+	left := grace - now.Sub(s.lastReady)
+	if left <= 0 {
+		return false
+	}
+	if s.timer == nil && t.onExpire != nil {
+		s.timer = time.AfterFunc(left, t.onExpire)
+	}
+	return true
+}
+
+// forget stops tracking the service.
+func (t *readinessTracker) forget(name string) {
+	t.Lock()
+	defer t.Unlock()
+	if s, ok := t.services[name]; ok && s.timer != nil {
+		s.timer.Stop()
+	}
+	delete(t.services, name)
+}
+
+// notReadyGracePeriod returns the longest not ready grace period of the
+// advertisements of the pool for the given protocol. For BGP, only the
+// advertisements selecting the node are considered. For L2, all of them
+// are, for all the speakers to elect among the same nodes.
+func notReadyGracePeriod(pool *config.Pool, protocol config.Proto, node string) time.Duration {
+	var res time.Duration
+	switch protocol {
+	case config.BGP:
+		for _, adv := range pool.BGPAdvertisements {
+			if adv.Nodes[node] && adv.NotReadyGracePeriod > res {
+				res = adv.NotReadyGracePeriod
+			}
+		}
+	case config.Layer2:
+		for _, adv := range pool.L2Advertisements {
+			if adv.NotReadyGracePeriod > res {
+				res = adv.NotReadyGracePeriod
+			}
+		}
+	}
+	return res
+}
+
+// allEndpointsReady returns a copy of the endpoints with all of them
+// considered ready.
+func allEndpointsReady(eps epslices.EpsOrSlices) epslices.EpsOrSlices {
+	res := epslices.EpsOrSlices{Type: eps.Type}
+	switch eps.Type {
+	case epslices.Eps:
+		if eps.EpVal == nil {
+			return res
+		}
+		res.EpVal = eps.EpVal.DeepCopy()
+		for i := range res.EpVal.Subsets {
+			subset := &res.EpVal.Subsets[i]
+			subset.Addresses = append(subset.Addresses, subset.NotReadyAddresses...)
+			subset.NotReadyAddresses = nil
+		}
+	case epslices.Slices:
+		ready := true
+		for _, slice := range eps.SlicesVal {
+			slice := *slice.DeepCopy()
+			for i := range slice.Endpoints {
+				slice.Endpoints[i].Conditions.Ready = &ready
+			}
+			res.SlicesVal = append(res.SlicesVal, slice)
+		}
+	}
+	return res
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	"go.universe.tf/metallb/internal/pointer"
+	v1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+)
+
+func TestReadinessTracker(t *testing.T) {
+	expired := make(chan struct{}, 1)
+	tracker := readinessTracker{onExpire: func() { expired <- struct{}{} }}
+	start := time.Now()
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+	grace := 10 * time.Second
+
+	tracker.observe("never-ready", false, at(0))
+	if tracker.inGrace("never-ready", grace, at(0)) {
+		t.Fatal("expected a service never seen ready to have no grace period")
+	}
+
+	tracker.observe("svc", true, at(0))
+	if tracker.inGrace("svc", grace, at(1)) {
+		t.Fatal("expected a ready service not to be in its grace period")
+	}
+	tracker.observe("svc", false, at(5))
+	if !tracker.inGrace("svc", grace, at(9)) {
+		t.Fatal("expected the service to be in its grace period")
+	}
+	if tracker.inGrace("svc", grace, at(10)) {
+		t.Fatal("expected the grace period to be counted from the last time the service was ready")
+	}
+
+	// Being ready again restarts the grace period, and cancels the
+	// reprocessing of the service.
+	tracker.observe("svc", true, at(20))
+	tracker.observe("svc", false, at(21))
+	if !tracker.inGrace("svc", 50*time.Millisecond, at(20)) {
+		t.Fatal("expected the service to be in its new grace period")
+	}
+	select {
+	case <-expired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the service to be reprocessed once the grace period expires")
+	}
+
+	tracker.forget("svc")
+	if tracker.inGrace("svc", grace, at(21)) {
+		t.Fatal("expected a forgotten service to have no grace period")
+	}
+}
+
+func TestNotReadyGracePeriod(t *testing.T) {
+	pool := &config.Pool{
+		BGPAdvertisements: []*config.BGPAdvertisement{
+			{Nodes: map[string]bool{"pandora": true}, NotReadyGracePeriod: 5 * time.Second},
+			{Nodes: map[string]bool{"iris": true}, NotReadyGracePeriod: 20 * time.Second},
+		},
+		L2Advertisements: []*config.L2Advertisement{
+			{Nodes: map[string]bool{"pandora": true}},
+			{Nodes: map[string]bool{"iris": true}, NotReadyGracePeriod: 15 * time.Second},
+		},
+	}
+	if got := notReadyGracePeriod(pool, config.BGP, "pandora"); got != 5*time.Second {
+		t.Errorf("expected the BGP grace period of the advertisement selecting the node, got %s", got)
+	}
+	if got := notReadyGracePeriod(pool, config.Layer2, "pandora"); got != 15*time.Second {
+		t.Errorf("expected the longest L2 grace period of the pool, got %s", got)
+	}
+}
+
+func TestAllEndpointsReady(t *testing.T) {
+	slices := epslices.EpsOrSlices{
+		Type: epslices.Slices,
+		SlicesVal: []discovery.EndpointSlice{
+			{
+				Endpoints: []discovery.Endpoint{
+					{
+						Addresses:  []string{"2.3.4.5"},
+						NodeName:   pointer.StrPtr("iris"),
+						Conditions: discovery.EndpointConditions{Ready: pointer.BoolPtr(false)},
+					},
+				},
+			},
+		},
+	}
+	eps := epslices.EpsOrSlices{
+		Type: epslices.Eps,
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					NotReadyAddresses: []v1.EndpointAddress{
+						{IP: "2.3.4.5", NodeName: pointer.StrPtr("iris")},
+					},
+				},
+			},
+		},
+	}
+	for _, e := range []epslices.EpsOrSlices{slices, eps} {
+		if activeEndpointExists(e) {
+			t.Fatal("expected no active endpoint")
+		}
+		ready := allEndpointsReady(e)
+		if !activeEndpointExists(ready) {
+			t.Error("expected the not ready endpoint to be active")
+		}
+		if nodes := usableNodes(ready, nil); len(nodes) != 1 || nodes[0] != "iris" {
+			t.Errorf("expected iris to be usable, got %v", nodes)
+		}
+		if activeEndpointExists(e) {
+			t.Error("expected the endpoints not to be modified")
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"net"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// annotationAnnouncementPriority overrides the announcement priority of
+// the pool of the service.
+const annotationAnnouncementPriority = "metallb.universe.tf/announcement-priority"
+
+// servicePriority returns the priority the service is announced with when
+// all the services are processed, for example after a restart: the one set
+// with the announcement-priority annotation, or else the one of its pool.
+// The services with an invalid annotation or no pool get the default
+// priority, 0.
+func (c *controller) servicePriority(svc *v1.Service) int {
+	if value, ok := svc.Annotations[annotationAnnouncementPriority]; ok {
+		if priority, err := strconv.Atoi(value); err == nil {
+			return priority
+		}
+	}
+	if c.config == nil || c.config.Pools == nil || len(svc.Status.LoadBalancer.Ingress) == 0 {
+		return 0
+	}
+	ips := make([]net.IP, 0, len(svc.Status.LoadBalancer.Ingress))
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		ip := net.ParseIP(ingress.IP)
+		if ip == nil {
+			return 0
+		}
+		ips = append(ips, ip)
+	}
+	pool := c.config.Pools.ByName[poolFor(c.config.Pools, ips)]
+	if pool == nil {
+		return 0
+	}
+	return pool.AnnouncementPriority
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"net"
+	"testing"
+
+	"go.universe.tf/metallb/internal/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestServicePriority(t *testing.T) {
+	c := &controller{
+		config: &config.Config{
+			Pools: &config.Pools{ByName: map[string]*config.Pool{
+				"critical": {
+					CIDR:                 []*net.IPNet{ipnet("10.20.30.0/24")},
+					AnnouncementPriority: 100,
+				},
+				"bulk": {
+					CIDR: []*net.IPNet{ipnet("10.20.40.0/24")},
+				},
+			}},
+		},
+	}
+	svc := func(ip string, annotations map[string]string) *v1.Service {
+		res := &v1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+		if ip != "" {
+			res.Status = statusAssigned(ip)
+		}
+		return res
+	}
+
+	tests := []struct {
+		desc string
+		svc  *v1.Service
+		want int
+	}{
+		{
+			desc: "priority of the pool",
+			svc:  svc("10.20.30.1", nil),
+			want: 100,
+		},
+		{
+			desc: "pool without priority",
+			svc:  svc("10.20.40.1", nil),
+			want: 0,
+		},
+		{
+			desc: "annotation overriding the pool",
+			svc:  svc("10.20.30.1", map[string]string{annotationAnnouncementPriority: "-10"}),
+			want: -10,
+		},
+		{
+			desc: "invalid annotation",
+			svc:  svc("10.20.30.1", map[string]string{annotationAnnouncementPriority: "high"}),
+			want: 100,
+		},
+		{
+			desc: "no ip assigned",
+			svc:  svc("", nil),
+			want: 0,
+		},
+		{
+			desc: "ip not in any pool",
+			svc:  svc("10.20.50.1", nil),
+			want: 0,
+		},
+	}
+	for _, test := range tests {
+		if got := c.servicePriority(test.svc); got != test.want {
+			t.Errorf("%s: expected priority %d, got %d", test.desc, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// annotationHealthProbe is the probe telling if the service is healthy
+	// and must be announced, as <scheme>:<port>[<path>] with the scheme one
+	// of http, https or tcp and the port a port of the service, by name or
+	// number. The endpoints of the service are probed on the matching port.
+	annotationHealthProbe = "metallb.universe.tf/health-probe"
+	// annotationHealthProbeInterval is the interval between two probes.
+	annotationHealthProbeInterval = "metallb.universe.tf/health-probe-interval"
+	// annotationHealthProbeThreshold is the number of consecutive failed
+	// probes after which the service is withdrawn, and of consecutive
+	// successful ones after which it's announced again.
+	annotationHealthProbeThreshold = "metallb.universe.tf/health-probe-threshold"
+
+	defaultProbeInterval  = 10 * time.Second
+	defaultProbeThreshold = 3
+)
+
+// runProbe checks the targets of the probe once, returning an error if none
+// of them is healthy. Overridden in tests.
+var runProbe = checkProbe
+
+type probeSpec struct {
+	scheme    string
+	port      string
+	path      string
+	interval  time.Duration
+	threshold int
+}
+
+// probeSpecFor returns the health probe set with the annotations of the
+// service, nil if none is set.
+func probeSpecFor(svc *v1.Service) (*probeSpec, error) {
+	value, ok := svc.Annotations[annotationHealthProbe]
+	if !ok {
+		return nil, nil
+	}
+	scheme, port, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid %s %q, must be <scheme>:<port>[<path>]", annotationHealthProbe, value)
+	}
+	res := &probeSpec{
+		scheme:    scheme,
+		port:      port,
+		interval:  defaultProbeInterval,
+		threshold: defaultProbeThreshold,
+	}
+	if i := strings.Index(port, "/"); i >= 0 {
+		res.port, res.path = port[:i], port[i:]
+	}
+	switch res.scheme {
+	case "http", "https":
+	case "tcp":
+		if res.path != "" {
+			return nil, fmt.Errorf("invalid %s %q: a tcp probe has no path", annotationHealthProbe, value)
+		}
+	default:
+		return nil, fmt.Errorf("invalid %s %q: the scheme must be one of http, https, tcp", annotationHealthProbe, value)
+	}
+	if servicePort(svc, res.port) == nil {
+		return nil, fmt.Errorf("invalid %s %q: %q is not a port of the service", annotationHealthProbe, value, res.port)
+	}
+
+	var err error
+	if value, ok := svc.Annotations[annotationHealthProbeInterval]; ok {
+		res.interval, err = time.ParseDuration(value)
+		if err != nil || res.interval <= 0 {
+			return nil, fmt.Errorf("invalid %s %q, must be a positive duration", annotationHealthProbeInterval, value)
+		}
+	}
+	if value, ok := svc.Annotations[annotationHealthProbeThreshold]; ok {
+		res.threshold, err = strconv.Atoi(value)
+		if err != nil || res.threshold < 1 {
+			return nil, fmt.Errorf("invalid %s %q, must be a positive integer", annotationHealthProbeThreshold, value)
+		}
+	}
+	return res, nil
+}
+
+// servicePort returns the TCP port of the service with the given name or
+// number, nil if there is none.
+func servicePort(svc *v1.Service, port string) *v1.ServicePort {
+	for i, p := range svc.Spec.Ports {
+		if p.Protocol != "" && p.Protocol != v1.ProtocolTCP {
+			continue
+		}
+		if p.Name == port || strconv.Itoa(int(p.Port)) == port {
+			return &svc.Spec.Ports[i]
+		}
+	}
+	return nil
+}
+
+// probeTargets returns the host:port addresses of the ready endpoints of the
+// service on the port the probe targets, sorted.
+func probeTargets(spec *probeSpec, svc *v1.Service, eps epslices.EpsOrSlices) []string {
+	sp := servicePort(svc, spec.port)
+	if sp == nil {
+		return nil
+	}
+	unique := map[string]bool{}
+	switch eps.Type {
+	case epslices.Eps:
+		for _, subset := range eps.EpVal.Subsets {
+			for _, p := range subset.Ports {
+				if p.Name != sp.Name || (p.Protocol != "" && p.Protocol != v1.ProtocolTCP) {
+					continue
+				}
+				for _, ep := range subset.Addresses {
+					unique[net.JoinHostPort(ep.IP, strconv.Itoa(int(p.Port)))] = true
+				}
+			}
+		}
+	case epslices.Slices:
+		for _, slice := range eps.SlicesVal {
+			for _, p := range slice.Ports {
+				if p.Port == nil || (p.Name != nil && *p.Name != sp.Name) || (p.Name == nil && sp.Name != "") ||
+					(p.Protocol != nil && *p.Protocol != v1.ProtocolTCP) {
+					continue
+				}
+				for _, ep := range slice.Endpoints {
+					if !epslices.IsConditionReady(ep.Conditions) {
+						continue
+					}
+					for _, addr := range ep.Addresses {
+						unique[net.JoinHostPort(addr, strconv.Itoa(int(*p.Port)))] = true
+					}
+				}
+			}
+		}
+	}
+	res := make([]string, 0, len(unique))
+	for t := range unique {
+		res = append(res, t)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// checkProbe succeeds if one of the targets is healthy: for http(s) if it
+// answers with a 2xx or 3xx status, for tcp if it accepts the connection,
+// within the interval of the probe.
+func checkProbe(spec probeSpec, targets []string) error {
+	if len(targets) == 0 {
+		return errors.New("no ready endpoint to probe")
+	}
+	var err error
+	for _, t := range targets {
+		if err = checkTarget(spec, t); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func checkTarget(spec probeSpec, target string) error {
+	if spec.scheme == "tcp" {
+		conn, err := net.DialTimeout("tcp", target, spec.interval)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	client := http.Client{
+		Timeout: spec.interval,
+		// The redirects are considered a success, not followed.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	u := url.URL{Scheme: spec.scheme, Host: target, Path: spec.path}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, target)
+	}
+	return nil
+}
+
+// serviceProbe periodically probes the health of a service.
+type serviceProbe struct {
+	spec probeSpec
+	stop chan struct{}
+
+	sync.Mutex
+	// targets are the endpoints probed, updated as they change.
+	targets []string
+	healthy bool
+	// failures and successes are the consecutive results opposite to the
+	// current health.
+	failures  int
+	successes int
+}
+
+// record updates the health with the result of a probe, returning true if
+// it changed.
+func (p *serviceProbe) record(err error) bool {
+	p.Lock()
+	defer p.Unlock()
+	if err == nil {
+		p.failures = 0
+		if p.healthy {
+			return false
+		}
+		p.successes++
+		if p.successes < p.spec.threshold {
+			return false
+		}
+		p.successes = 0
+		p.healthy = true
+		return true
+	}
+	p.successes = 0
+	if !p.healthy {
+		return false
+	}
+	p.failures++
+	if p.failures < p.spec.threshold {
+		return false
+	}
+	p.failures = 0
+	p.healthy = false
+	return true
+}
+
+func (p *serviceProbe) isHealthy() bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.healthy
+}
+
+func (p *serviceProbe) setTargets(targets []string) {
+	p.Lock()
+	defer p.Unlock()
+	p.targets = targets
+}
+
+func (p *serviceProbe) getTargets() []string {
+	p.Lock()
+	defer p.Unlock()
+	return p.targets
+}
+
+// probeManager runs the health probes of the services, keyed by service.
+// The services are considered healthy until their probe fails.
+type probeManager struct {
+	sync.Mutex
+	// enabled tells if the probes are run, they are ignored otherwise.
+	enabled bool
+	probes  map[string]*serviceProbe
+	// onChange is called when the health of a service changes, to
+	// reprocess it.
+	onChange func()
+}
+
+// update starts, updates or stops the probe of the service as set with its
+// annotations, probing its current endpoints.
+func (m *probeManager) update(l log.Logger, name string, svc *v1.Service, eps epslices.EpsOrSlices) error {
+	if !m.enabled || svc == nil || svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+		m.stop(name)
+		return nil
+	}
+	spec, err := probeSpecFor(svc)
+	if err != nil || spec == nil {
+		m.stop(name)
+		return err
+	}
+	targets := probeTargets(spec, svc, eps)
+
+	m.Lock()
+	defer m.Unlock()
+	if p, ok := m.probes[name]; ok {
+		if p.spec == *spec {
+			p.setTargets(targets)
+			return nil
+		}
+		close(p.stop)
+	}
+	if m.probes == nil {
+		m.probes = map[string]*serviceProbe{}
+	}
+	p := &serviceProbe{spec: *spec, stop: make(chan struct{}), targets: targets, healthy: true}
+	m.probes[name] = p
+	go m.run(log.With(l, "probe", svc.Annotations[annotationHealthProbe]), p)
+	return nil
+}
+
+// healthy returns whether the service is healthy according to its probe,
+// true if it has none.
+func (m *probeManager) healthy(name string) bool {
+	m.Lock()
+	defer m.Unlock()
+	p, ok := m.probes[name]
+	if !ok {
+		return true
+	}
+	return p.isHealthy()
+}
+
+func (m *probeManager) run(l log.Logger, p *serviceProbe) {
+	ticker := time.NewTicker(p.spec.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+		err := runProbe(p.spec, p.getTargets())
+		if !p.record(err) {
+			continue
+		}
+		if p.isHealthy() {
+			level.Info(l).Log("event", "healthProbeSucceeded", "msg", "service healthy again, announcing")
+		} else {
+			level.Info(l).Log("event", "healthProbeFailed", "error", err, "msg", "service unhealthy, withdrawing")
+		}
+		if m.onChange != nil {
+			m.onChange()
+		}
+	}
+}
+
+// stop stops the probe of the service, if any.
+func (m *probeManager) stop(name string) {
+	m.Lock()
+	defer m.Unlock()
+	if p, ok := m.probes[name]; ok {
+		close(p.stop)
+		delete(m.probes, name)
+	}
+}
@@ -0,0 +1,350 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	"go.universe.tf/metallb/internal/pointer"
+	v1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProbeSpecFor(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        *probeSpec
+		wantErr     bool
+	}{
+		{
+			desc: "no probe",
+		},
+		{
+			desc:        "defaults",
+			annotations: map[string]string{annotationHealthProbe: "http:web/healthz"},
+			want:        &probeSpec{scheme: "http", port: "web", path: "/healthz", interval: defaultProbeInterval, threshold: defaultProbeThreshold},
+		},
+		{
+			desc: "tcp with interval and threshold",
+			annotations: map[string]string{
+				annotationHealthProbe:          "tcp:5432",
+				annotationHealthProbeInterval:  "2s",
+				annotationHealthProbeThreshold: "5",
+			},
+			want: &probeSpec{scheme: "tcp", port: "5432", interval: 2 * time.Second, threshold: 5},
+		},
+		{
+			desc:        "free form url",
+			annotations: map[string]string{annotationHealthProbe: "http://169.254.169.254/latest"},
+			wantErr:     true,
+		},
+		{
+			desc:        "not a port of the service",
+			annotations: map[string]string{annotationHealthProbe: "http:8080/healthz"},
+			wantErr:     true,
+		},
+		{
+			desc:        "udp port of the service",
+			annotations: map[string]string{annotationHealthProbe: "tcp:dns"},
+			wantErr:     true,
+		},
+		{
+			desc:        "unsupported scheme",
+			annotations: map[string]string{annotationHealthProbe: "udp:5432"},
+			wantErr:     true,
+		},
+		{
+			desc:        "tcp with a path",
+			annotations: map[string]string{annotationHealthProbe: "tcp:5432/healthz"},
+			wantErr:     true,
+		},
+		{
+			desc:        "missing port",
+			annotations: map[string]string{annotationHealthProbe: "http"},
+			wantErr:     true,
+		},
+		{
+			desc: "invalid interval",
+			annotations: map[string]string{
+				annotationHealthProbe:         "http:web/healthz",
+				annotationHealthProbeInterval: "-1s",
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalid threshold",
+			annotations: map[string]string{
+				annotationHealthProbe:          "http:web/healthz",
+				annotationHealthProbeThreshold: "0",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			svc := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations},
+				Spec: v1.ServiceSpec{Ports: []v1.ServicePort{
+					{Name: "web", Port: 80},
+					{Name: "db", Port: 5432, Protocol: v1.ProtocolTCP},
+					{Name: "dns", Port: 53, Protocol: v1.ProtocolUDP},
+				}},
+			}
+			got, err := probeSpecFor(svc)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestProbeTargets(t *testing.T) {
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{Ports: []v1.ServicePort{
+			{Name: "web", Port: 80},
+			{Name: "metrics", Port: 9090},
+		}},
+	}
+	spec := &probeSpec{scheme: "http", port: "80", path: "/healthz"}
+	ready, notReady := true, false
+	port := func(name string, p int32) discovery.EndpointPort {
+		return discovery.EndpointPort{Name: pointer.StrPtr(name), Port: pointer.Int32Ptr(p)}
+	}
+
+	tests := []struct {
+		desc string
+		eps  epslices.EpsOrSlices
+		want []string
+	}{
+		{
+			desc: "endpoints",
+			eps: epslices.EpsOrSlices{
+				Type: epslices.Eps,
+				EpVal: &v1.Endpoints{
+					Subsets: []v1.EndpointSubset{
+						{
+							Addresses:         []v1.EndpointAddress{{IP: "10.1.1.2"}, {IP: "10.1.1.1"}},
+							NotReadyAddresses: []v1.EndpointAddress{{IP: "10.1.1.3"}},
+							Ports:             []v1.EndpointPort{{Name: "web", Port: 8080}, {Name: "metrics", Port: 9090}},
+						},
+					},
+				},
+			},
+			want: []string{"10.1.1.1:8080", "10.1.1.2:8080"},
+		},
+		{
+			desc: "slices",
+			eps: epslices.EpsOrSlices{
+				Type: epslices.Slices,
+				SlicesVal: []discovery.EndpointSlice{
+					{
+						Endpoints: []discovery.Endpoint{
+							{Addresses: []string{"10.1.1.1"}, Conditions: discovery.EndpointConditions{Ready: &ready}},
+							{Addresses: []string{"10.1.1.2"}, Conditions: discovery.EndpointConditions{Ready: &notReady}},
+						},
+						Ports: []discovery.EndpointPort{port("web", 8080), port("metrics", 9090)},
+					},
+				},
+			},
+			want: []string{"10.1.1.1:8080"},
+		},
+		{
+			desc: "no endpoints",
+			eps:  epslices.EpsOrSlices{},
+			want: []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := probeTargets(spec, svc, test.eps)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("unexpected targets (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckProbe(t *testing.T) {
+	var status int32 = http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer srv.Close()
+	target := strings.TrimPrefix(srv.URL, "http://")
+
+	spec := probeSpec{scheme: "http", path: "/healthz", interval: time.Second}
+	if err := checkProbe(spec, []string{target}); err != nil {
+		t.Fatalf("expected http probe to succeed, got %s", err)
+	}
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	if err := checkProbe(spec, []string{target}); err == nil {
+		t.Fatalf("expected http probe to fail")
+	}
+	if err := checkProbe(spec, nil); err == nil {
+		t.Fatalf("expected probe with no endpoints to fail")
+	}
+
+	tcpSpec := probeSpec{scheme: "tcp", interval: time.Second}
+	if err := checkProbe(tcpSpec, []string{target}); err != nil {
+		t.Fatalf("expected tcp probe to succeed, got %s", err)
+	}
+	srv.Close()
+	if err := checkProbe(tcpSpec, []string{target}); err == nil {
+		t.Fatalf("expected tcp probe to fail")
+	}
+}
+
+func TestHealthProbeWithdrawal(t *testing.T) {
+	var failing atomic.Bool
+	runProbe = func(probeSpec, []string) error {
+		if failing.Load() {
+			return errors.New("failing")
+		}
+		return nil
+	}
+	defer func() { runProbe = checkProbe }()
+
+	l2MockHandler := &MockProtocol{
+		protocol:       config.Layer2,
+		shouldAnnounce: true,
+	}
+	bgpMockHandler := &MockProtocol{
+		protocol:       config.BGP,
+		shouldAnnounce: true,
+	}
+	c := NewController(l2MockHandler, bgpMockHandler, t)
+	c.probes.enabled = true
+	changed := make(chan struct{}, 1)
+	c.probes.onChange = func() { changed <- struct{}{} }
+
+	cfg := &config.Config{
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+			},
+		}},
+	}
+	if c.SetConfig(logger, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("Set config failed")
+	}
+
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "testsvc",
+			Annotations: map[string]string{
+				annotationHealthProbe:          "http:web/healthz",
+				annotationHealthProbeInterval:  "10ms",
+				annotationHealthProbeThreshold: "2",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Cluster",
+			Ports:                 []v1.ServicePort{{Name: "web", Port: 80}},
+		},
+		Status: statusAssigned("10.20.30.1"),
+	}
+	setBalancer := func() {
+		t.Helper()
+		if c.SetBalancer(logger, "testsvc", svc, epslices.EpsOrSlices{}) != controllers.SyncStateSuccess {
+			t.Fatalf("Set balancer failed")
+		}
+	}
+	waitForChange := func() {
+		t.Helper()
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for the health of the service to change")
+		}
+	}
+
+	// The service is healthy until the probe fails.
+	setBalancer()
+	if _, ok := c.svcIPs["testsvc"]; !ok {
+		t.Fatalf("expected the service to be announced")
+	}
+
+	failing.Store(true)
+	waitForChange()
+	setBalancer()
+	if _, ok := c.svcIPs["testsvc"]; ok {
+		t.Fatalf("expected the service to be withdrawn while the probe fails")
+	}
+
+	failing.Store(false)
+	waitForChange()
+	setBalancer()
+	if _, ok := c.svcIPs["testsvc"]; !ok {
+		t.Fatalf("expected the service to be announced again once the probe recovers")
+	}
+
+	// A single probe runs for the service while its IPs are reallocated.
+	svc.Annotations[annotationReallocationStarted] = "2023-01-01T00:00:00Z"
+	svc.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "10.20.30.1"}, {IP: "10.20.30.2"}}
+	setBalancer()
+	c.probes.Lock()
+	if len(c.probes.probes) != 1 || c.probes.probes["testsvc"] == nil {
+		t.Fatalf("expected a single probe for the service, got %v", c.probes.probes)
+	}
+	c.probes.Unlock()
+
+	// Removing the annotation stops the probe.
+	delete(svc.Annotations, annotationHealthProbe)
+	setBalancer()
+	c.probes.Lock()
+	defer c.probes.Unlock()
+	if _, ok := c.probes.probes["testsvc"]; ok {
+		t.Fatalf("expected the probe to be stopped")
+	}
+}
+
+func TestHealthProbeDisabled(t *testing.T) {
+	c := NewController(&MockProtocol{protocol: config.Layer2, shouldAnnounce: true}, &MockProtocol{protocol: config.BGP, shouldAnnounce: true}, t)
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "testsvc",
+			Annotations: map[string]string{annotationHealthProbe: "http:web/healthz"},
+		},
+		Spec: v1.ServiceSpec{
+			Type:  "LoadBalancer",
+			Ports: []v1.ServicePort{{Name: "web", Port: 80}},
+		},
+	}
+	if err := c.probes.update(logger, "testsvc", svc, epslices.EpsOrSlices{}); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(c.probes.probes) != 0 {
+		t.Fatalf("expected no probe to run when disabled, got %v", c.probes.probes)
+	}
+	if !c.probes.healthy("testsvc") {
+		t.Fatalf("expected the service to be healthy when the probes are disabled")
+	}
+}
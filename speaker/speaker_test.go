@@ -7,9 +7,11 @@ import (
 	"testing"
 
 	"github.com/go-kit/log"
+	ptu "github.com/prometheus/client_golang/prometheus/testutil"
 	"go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/k8s/controllers"
 	"go.universe.tf/metallb/internal/k8s/epslices"
+	"go.universe.tf/metallb/internal/pointer"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -159,6 +161,373 @@ func TestLoadBalancerCreation(t *testing.T) {
 	}
 }
 
+func TestMinEndpoints(t *testing.T) {
+	l2MockHandler := &MockProtocol{
+		protocol:       config.Layer2,
+		shouldAnnounce: true,
+	}
+	bgpMockHandler := &MockProtocol{
+		protocol:       config.BGP,
+		shouldAnnounce: true,
+	}
+	c := NewController(l2MockHandler, bgpMockHandler, t)
+
+	cfg := &config.Config{
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+			},
+		}},
+	}
+	if state := c.SetConfig(logger, cfg); state != controllers.SyncStateReprocessAll {
+		t.Fatalf("Set config failed")
+	}
+
+	endpoints := func(ready ...string) epslices.EpsOrSlices {
+		addresses := []v1.EndpointAddress{}
+		for _, ip := range ready {
+			addresses = append(addresses, v1.EndpointAddress{IP: ip, NodeName: pointer.StrPtr("nodeName")})
+		}
+		return epslices.EpsOrSlices{
+			EpVal: &v1.Endpoints{
+				Subsets: []v1.EndpointSubset{
+					{
+						Addresses:         addresses,
+						NotReadyAddresses: []v1.EndpointAddress{{IP: "2.3.4.99", NodeName: pointer.StrPtr("nodeName")}},
+					},
+				},
+			},
+			Type: epslices.Eps,
+		}
+	}
+
+	tests := []struct {
+		desc       string
+		annotation string
+		eps        epslices.EpsOrSlices
+		announced  bool
+	}{
+		{
+			desc:       "below the threshold",
+			annotation: "2",
+			eps:        endpoints("2.3.4.5"),
+			announced:  false,
+		},
+		{
+			desc:       "reaching the threshold",
+			annotation: "2",
+			eps:        endpoints("2.3.4.5", "2.3.4.6"),
+			announced:  true,
+		},
+		{
+			desc:       "dropping below the threshold",
+			annotation: "2",
+			eps:        endpoints("2.3.4.5"),
+			announced:  false,
+		},
+		{
+			desc:       "invalid annotation is ignored",
+			annotation: "0",
+			eps:        endpoints("2.3.4.5"),
+			announced:  true,
+		},
+		{
+			desc:       "non numeric annotation is ignored",
+			annotation: "two",
+			eps:        endpoints("2.3.4.5"),
+			announced:  true,
+		},
+	}
+
+	for _, test := range tests {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "testsvc",
+				Annotations: map[string]string{annotationMinEndpoints: test.annotation},
+			},
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: "Cluster",
+			},
+			Status: statusAssigned("10.20.30.1"),
+		}
+		if state := c.SetBalancer(logger, "testsvc", svc, test.eps); state != controllers.SyncStateSuccess {
+			t.Fatalf("%s: Set balancer failed", test.desc)
+		}
+		for _, p := range config.Protocols {
+			if c.announced[p]["testsvc"] != test.announced {
+				t.Errorf("%s: announced with %s is %v, expected %v", test.desc, p, c.announced[p]["testsvc"], test.announced)
+			}
+		}
+	}
+}
+
+func TestAnnounceAnnotation(t *testing.T) {
+	l2MockHandler := &MockProtocol{
+		protocol:       config.Layer2,
+		shouldAnnounce: true,
+	}
+	bgpMockHandler := &MockProtocol{
+		protocol:       config.BGP,
+		shouldAnnounce: true,
+	}
+	c := NewController(l2MockHandler, bgpMockHandler, t)
+
+	cfg := &config.Config{
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+			},
+		}},
+	}
+	if state := c.SetConfig(logger, cfg); state != controllers.SyncStateReprocessAll {
+		t.Fatalf("Set config failed")
+	}
+
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		announced   bool
+	}{
+		{
+			desc:      "no annotation",
+			announced: true,
+		},
+		{
+			desc:        "announcement disabled",
+			annotations: map[string]string{annotationAnnounce: "false"},
+			announced:   false,
+		},
+		{
+			desc:        "announcement enabled again",
+			annotations: map[string]string{annotationAnnounce: "true"},
+			announced:   true,
+		},
+	}
+
+	for _, test := range tests {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "testsvc",
+				Annotations: test.annotations,
+			},
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: "Cluster",
+			},
+			Status: statusAssigned("10.20.30.1"),
+		}
+		if state := c.SetBalancer(logger, "testsvc", svc, epslices.EpsOrSlices{}); state != controllers.SyncStateSuccess {
+			t.Fatalf("%s: Set balancer failed", test.desc)
+		}
+		for _, p := range config.Protocols {
+			if c.announced[p]["testsvc"] != test.announced {
+				t.Errorf("%s: announced with %s is %v, expected %v", test.desc, p, c.announced[p]["testsvc"], test.announced)
+			}
+		}
+	}
+}
+
+func TestDualModeServices(t *testing.T) {
+	l2MockHandler := &MockProtocol{
+		protocol:       config.Layer2,
+		shouldAnnounce: true,
+	}
+	bgpMockHandler := &MockProtocol{
+		protocol:       config.BGP,
+		shouldAnnounce: true,
+	}
+	c := NewController(l2MockHandler, bgpMockHandler, t)
+
+	cfg := &config.Config{
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"dual": {
+				Name:              "dual",
+				CIDR:              []*net.IPNet{ipnet("10.20.30.0/24")},
+				L2Advertisements:  []*config.L2Advertisement{{Nodes: map[string]bool{"nodeName": true}}},
+				BGPAdvertisements: []*config.BGPAdvertisement{{Nodes: map[string]bool{"nodeName": true}}},
+			},
+			"l2": {
+				Name:             "l2",
+				CIDR:             []*net.IPNet{ipnet("10.20.40.0/24")},
+				L2Advertisements: []*config.L2Advertisement{{Nodes: map[string]bool{"nodeName": true}}},
+			},
+		}},
+	}
+	if state := c.SetConfig(logger, cfg); state != controllers.SyncStateReprocessAll {
+		t.Fatalf("Set config failed")
+	}
+
+	service := func(ip string) *v1.Service {
+		return &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: "Cluster",
+			},
+			Status: statusAssigned(ip),
+		}
+	}
+	if state := c.SetBalancer(logger, "dualsvc", service("10.20.30.1"), epslices.EpsOrSlices{}); state != controllers.SyncStateSuccess {
+		t.Fatalf("Set balancer failed")
+	}
+	if state := c.SetBalancer(logger, "l2svc", service("10.20.40.1"), epslices.EpsOrSlices{}); state != controllers.SyncStateSuccess {
+		t.Fatalf("Set balancer failed")
+	}
+	for _, p := range config.Protocols {
+		if !c.announced[p]["dualsvc"] {
+			t.Errorf("expected dualsvc to be announced with %s", p)
+		}
+	}
+	if got := ptu.ToFloat64(dualModeServices.WithLabelValues("dualsvc", "10.20.30.1")); got != 1 {
+		t.Errorf("expected dualsvc to be reported as dual mode, got %f", got)
+	}
+	if got := ptu.CollectAndCount(dualModeServices); got != 1 {
+		t.Errorf("expected only dualsvc to be reported as dual mode, got %d services", got)
+	}
+
+	if state := c.SetBalancer(logger, "dualsvc", nil, epslices.EpsOrSlices{}); state != controllers.SyncStateSuccess {
+		t.Fatalf("Delete balancer failed")
+	}
+	if got := ptu.CollectAndCount(dualModeServices); got != 0 {
+		t.Errorf("expected no dual mode services once dualsvc is deleted, got %d", got)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	l2MockHandler := &MockProtocol{
+		protocol:       config.Layer2,
+		shouldAnnounce: true,
+	}
+	bgpMockHandler := &MockProtocol{
+		protocol:       config.BGP,
+		shouldAnnounce: true,
+	}
+	c := NewController(l2MockHandler, bgpMockHandler, t)
+
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+		},
+	}}
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Cluster",
+		},
+		Status: statusAssigned("10.20.30.1"),
+	}
+
+	tests := []struct {
+		desc         string
+		maintenances map[string]string
+		announced    bool
+		gauge        float64
+	}{
+		{
+			desc:      "no maintenance",
+			announced: true,
+			gauge:     0,
+		},
+		{
+			desc:         "entering maintenance",
+			maintenances: map[string]string{"upgrade": "switch upgrade"},
+			announced:    false,
+			gauge:        1,
+		},
+		{
+			desc:      "leaving maintenance",
+			announced: true,
+			gauge:     0,
+		},
+	}
+
+	for _, test := range tests {
+		cfg := &config.Config{Pools: pools, Maintenances: test.maintenances}
+		if state := c.SetConfig(logger, cfg); state != controllers.SyncStateReprocessAll {
+			t.Fatalf("%s: Set config failed", test.desc)
+		}
+		if state := c.SetBalancer(logger, "testsvc", svc, epslices.EpsOrSlices{}); state != controllers.SyncStateSuccess {
+			t.Fatalf("%s: Set balancer failed", test.desc)
+		}
+		for _, p := range config.Protocols {
+			if c.announced[p]["testsvc"] != test.announced {
+				t.Errorf("%s: announced with %s is %v, expected %v", test.desc, p, c.announced[p]["testsvc"], test.announced)
+			}
+		}
+		if got := ptu.ToFloat64(maintenanceMode); got != test.gauge {
+			t.Errorf("%s: expected maintenance mode gauge %f, got %f", test.desc, test.gauge, got)
+		}
+	}
+}
+
+func TestReallocationAnnouncement(t *testing.T) {
+	l2MockHandler := &MockProtocol{
+		protocol:       config.Layer2,
+		shouldAnnounce: true,
+	}
+	bgpMockHandler := &MockProtocol{
+		protocol:       config.BGP,
+		shouldAnnounce: true,
+	}
+	c := NewController(l2MockHandler, bgpMockHandler, t)
+
+	cfg := &config.Config{
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"old": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+			},
+			"new": {
+				CIDR: []*net.IPNet{ipnet("10.20.40.0/24")},
+			},
+		}},
+	}
+	if c.SetConfig(logger, cfg) != controllers.SyncStateReprocessAll {
+		t.Fatalf("Set config failed")
+	}
+
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "testsvc",
+			Annotations: map[string]string{annotationReallocationStarted: "2023-01-01T00:00:00Z"},
+		},
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Cluster",
+		},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{{IP: "10.20.30.1"}, {IP: "10.20.40.1"}},
+			},
+		},
+	}
+
+	// While reallocating, both the current and the new IPs are announced.
+	if c.SetBalancer(logger, "testsvc", svc, epslices.EpsOrSlices{}) != controllers.SyncStateSuccess {
+		t.Fatalf("Set balancer failed")
+	}
+	if ips := c.svcIPs["testsvc"]; len(ips) != 1 || !ips[0].Equal(net.ParseIP("10.20.30.1")) {
+		t.Fatalf("expected the current ip to be announced, got %v", ips)
+	}
+	if ips := c.svcIPs[reallocationName("testsvc")]; len(ips) != 1 || !ips[0].Equal(net.ParseIP("10.20.40.1")) {
+		t.Fatalf("expected the new ip to be announced, got %v", ips)
+	}
+
+	// Once reallocated, only the new IP is announced.
+	delete(svc.Annotations, annotationReallocationStarted)
+	svc.Status = statusAssigned("10.20.40.1")
+	if c.SetBalancer(logger, "testsvc", svc, epslices.EpsOrSlices{}) != controllers.SyncStateSuccess {
+		t.Fatalf("Set balancer failed")
+	}
+	if ips := c.svcIPs["testsvc"]; len(ips) != 1 || !ips[0].Equal(net.ParseIP("10.20.40.1")) {
+		t.Fatalf("expected the new ip to be announced, got %v", ips)
+	}
+	for _, p := range config.Protocols {
+		if c.announced[p][reallocationName("testsvc")] {
+			t.Fatalf("expected the reallocation not to be announced anymore with %s", p)
+		}
+	}
+}
+
 type MockProtocol struct {
 	config               *config.Config
 	protocol             config.Proto
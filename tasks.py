@@ -739,10 +739,13 @@ def helmdocs(ctx, env="container"):
     "prometheus_namespace": "the namespace prometheus is deployed to, to validate metrics against prometheus.",
     "node_nics": "a list of node's interfaces separated by comma, default is kind",
     "local_nics": "a list of bridges related node's interfaces separated by comma, default is kind",
+    "node_nics_v6": "a list of node's interfaces separated by comma used for the IPv6 services, default is node_nics",
+    "local_nics_v6": "a list of bridges related node's interfaces separated by comma used for the IPv6 services, default is local_nics",
+    "nic_pair": "the index in the interfaces lists of the single pair of interfaces used by the interface selector tests, default is all of them",
     "external_containers": "a comma separated list of external containers names to use for the test. (valid parameters are: ibgp-single-hop / ibgp-multi-hop / ebgp-single-hop / ebgp-multi-hop)",
     "native_bgp": "tells if the given cluster is deployed using native bgp mode ",
 })
-def e2etest(ctx, name="kind", export=None, kubeconfig=None, system_namespaces="kube-system,metallb-system", service_pod_port=80, skip_docker=False, focus="", skip="", ipv4_service_range=None, ipv6_service_range=None, prometheus_namespace="", node_nics="kind", local_nics="kind", external_containers="", native_bgp=False,):
+def e2etest(ctx, name="kind", export=None, kubeconfig=None, system_namespaces="kube-system,metallb-system", service_pod_port=80, skip_docker=False, focus="", skip="", ipv4_service_range=None, ipv6_service_range=None, prometheus_namespace="", node_nics="kind", local_nics="kind", node_nics_v6="", local_nics_v6="", nic_pair=-1, external_containers="", native_bgp=False,):
     """Run E2E tests against development cluster."""
     if skip_docker:
         opt_skip_docker = "--skip-docker"
@@ -800,8 +803,16 @@ def e2etest(ctx, name="kind", export=None, kubeconfig=None, system_namespaces="k
     if external_containers != "":
         external_containers = "--external-containers="+(external_containers)
 
+    nics_v6 = ""
+    if node_nics_v6 != "" or local_nics_v6 != "":
+        nics_v6 = "-node-nics-v6 {} -local-nics-v6 {}".format(node_nics_v6, local_nics_v6)
+
+    opt_nic_pair = ""
+    if int(nic_pair) >= 0:
+        opt_nic_pair = "-nic-pair {}".format(nic_pair)
+
     testrun = run("cd `git rev-parse --show-toplevel`/e2etest &&"
-            "KUBECONFIG={} ginkgo --timeout=3h {} {} -- --provider=local --kubeconfig={} --service-pod-port={} -ipv4-service-range={} -ipv6-service-range={} {} --report-path {} {} -node-nics {} -local-nics {} {}  -bgp-native-mode={}".format(kubeconfig, ginkgo_focus, ginkgo_skip, kubeconfig, service_pod_port, ipv4_service_range, ipv6_service_range, opt_skip_docker, report_path, prometheus_namespace, node_nics, local_nics, external_containers, native_bgp), warn="True")
+            "KUBECONFIG={} ginkgo --timeout=3h {} {} -- --provider=local --kubeconfig={} --service-pod-port={} -ipv4-service-range={} -ipv6-service-range={} {} --report-path {} {} -node-nics {} -local-nics {} {} {} {}  -bgp-native-mode={}".format(kubeconfig, ginkgo_focus, ginkgo_skip, kubeconfig, service_pod_port, ipv4_service_range, ipv6_service_range, opt_skip_docker, report_path, prometheus_namespace, node_nics, local_nics, nics_v6, opt_nic_pair, external_containers, native_bgp), warn="True")
 
     if export != None:
         run("kind export logs {}".format(export))